@@ -130,6 +130,7 @@ type API struct {
 	tpool    modules.TransactionPool
 	wallet   modules.Wallet
 
+	jobs   *jobManager
 	router http.Handler
 }
 
@@ -139,9 +140,11 @@ func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // New creates a new Sia API from the provided modules.  The API will require
-// authentication using HTTP basic auth for certain endpoints of the supplied
+// authentication using HTTP basic auth for certain endpoints if the supplied
 // password is not the empty string.  Usernames are ignored for authentication.
-func New(requiredUserAgent string, requiredPassword string, cs modules.ConsensusSet, e modules.Explorer, g modules.Gateway, h modules.Host, m modules.Miner, r modules.Renter, tp modules.TransactionPool, w modules.Wallet) *API {
+// API tokens may be used in place of the password for the endpoints of their
+// scopes.
+func New(requiredUserAgent string, apiAuth Auth, cs modules.ConsensusSet, e modules.Explorer, g modules.Gateway, h modules.Host, m modules.Miner, r modules.Renter, tp modules.TransactionPool, w modules.Wallet) *API {
 	api := &API{
 		cs:       cs,
 		explorer: e,
@@ -151,82 +154,126 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		renter:   r,
 		tpool:    tp,
 		wallet:   w,
+		jobs:     newJobManager(),
 	}
 
 	// Register API handlers
+	auth := newAuthenticator(apiAuth)
 	router := httprouter.New()
 	router.NotFound = http.HandlerFunc(UnrecognizedCallHandler)
 
 	// Consensus API Calls
 	if api.cs != nil {
 		router.GET("/consensus", api.consensusHandler)
+		router.GET("/consensus/consistency", api.consensusConsistencyHandlerGET)
+		router.POST("/consensus/consistency", auth.requireScope(api.consensusConsistencyHandlerPOST, scopeAdmin))
+		router.GET("/consensus/checkpoint", api.consensusCheckpointHandlerGET)
+		router.GET("/consensus/reorgs", api.consensusReorgsHandlerGET)
+		router.GET("/consensus/utxos", api.consensusUTXOsHandlerGET)
+		router.POST("/consensus/utxos/export", auth.requireScope(api.consensusUTXOsExportHandlerPOST, scopeAdmin))
+		router.POST("/consensus/utxos/import", auth.requireScope(api.consensusUTXOsImportHandlerPOST, scopeAdmin))
 	}
 
 	// Explorer API Calls
 	if api.explorer != nil {
 		router.GET("/explorer", api.explorerHandler)
+		router.GET("/explorer/addresses/:address", api.explorerAddressHandler)
+		router.GET("/explorer/contracts/:id", api.explorerContractHandler)
 		router.GET("/explorer/blocks/:height", api.explorerBlocksHandler)
 		router.GET("/explorer/hashes/:hash", api.explorerHashHandler)
+		router.GET("/explorer/storage", api.explorerStorageHandler)
+		router.GET("/explorer/storage/history", api.explorerStorageHistoryHandler)
+		router.GET("/explorer/consistency", api.explorerConsistencyHandler)
+		router.GET("/explorer/siafunds", api.explorerSiafundsHandler)
+		router.GET("/explorer/siafunds/:address", api.explorerSiafundsAddressHandler)
 	}
 
 	// Gateway API Calls
 	if api.gateway != nil {
 		router.GET("/gateway", api.gatewayHandler)
-		router.POST("/gateway/connect/:netaddress", RequirePassword(api.gatewayConnectHandler, requiredPassword))
-		router.POST("/gateway/disconnect/:netaddress", RequirePassword(api.gatewayDisconnectHandler, requiredPassword))
+		router.POST("/gateway", auth.requireScope(api.gatewayHandlerPOST, scopeAdmin))
+		router.GET("/gateway/bandwidth", api.gatewayBandwidthHandlerGET)
+		router.POST("/gateway/bandwidth", auth.requireScope(api.gatewayBandwidthHandlerPOST, scopeAdmin))
+		router.POST("/gateway/connect/:netaddress", auth.requireScope(api.gatewayConnectHandler, scopeAdmin))
+		router.POST("/gateway/disconnect/:netaddress", auth.requireScope(api.gatewayDisconnectHandler, scopeAdmin))
+		router.POST("/gateway/unpin/:netaddress", auth.requireScope(api.gatewayUnpinHandler, scopeAdmin))
 	}
 
 	// Host API Calls
 	if api.host != nil {
 		// Calls directly pertaining to the host.
-		router.GET("/host", api.hostHandlerGET)                                                   // Get the host status.
-		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))              // Change the settings of the host.
-		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword)) // Announce the host to the network.
+		router.GET("/host", api.hostHandlerGET)                                                              // Get the host status.
+		router.POST("/host", auth.requireScope(api.hostHandlerPOST, ScopeHostAdmin))                         // Change the settings of the host.
+		router.GET("/host/alerts", api.hostAlertsHandlerGET)                                                 // Get the operational problems of the host.
+		router.POST("/host/alerts/dismiss", auth.requireScope(api.hostAlertsDismissHandler, ScopeHostAdmin)) // Dismiss an alert about a past problem.
+		router.POST("/host/announce", auth.requireScope(api.hostAnnounceHandler, ScopeHostAdmin))            // Announce the host to the network.
+		router.POST("/host/audit", auth.requireScope(api.hostAuditHandler, ScopeHostAdmin))                  // Check that the host's sectors would pass a storage proof.
+		router.POST("/host/backup", auth.requireScope(api.hostBackupHandler, ScopeHostAdmin))                // Write a snapshot of the host's metadata to a directory.
+		router.GET("/host/summary", api.hostSummaryHandlerGET)                                               // Get the operational state of the host.
 
 		// Calls pertaining to the storage manager that the host uses.
 		router.GET("/host/storage", api.storageHandler)
-		router.POST("/host/storage/folders/add", RequirePassword(api.storageFoldersAddHandler, requiredPassword))
-		router.POST("/host/storage/folders/remove", RequirePassword(api.storageFoldersRemoveHandler, requiredPassword))
-		router.POST("/host/storage/folders/resize", RequirePassword(api.storageFoldersResizeHandler, requiredPassword))
-		router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(api.storageSectorsDeleteHandler, requiredPassword))
+		router.POST("/host/storage/folders/add", auth.requireScope(api.storageFoldersAddHandler, ScopeHostAdmin))
+		router.POST("/host/storage/folders/relink", auth.requireScope(api.storageFoldersRelinkHandler, ScopeHostAdmin))
+		router.POST("/host/storage/folders/remove", auth.requireScope(api.storageFoldersRemoveHandler, ScopeHostAdmin))
+		router.POST("/host/storage/folders/resize", auth.requireScope(api.storageFoldersResizeHandler, ScopeHostAdmin))
+		router.POST("/host/storage/sectors/delete/:merkleroot", auth.requireScope(api.storageSectorsDeleteHandler, ScopeHostAdmin))
 	}
 
 	// Miner API Calls
 	if api.miner != nil {
 		router.GET("/miner", api.minerHandler)
-		router.GET("/miner/header", RequirePassword(api.minerHeaderHandlerGET, requiredPassword))
-		router.POST("/miner/header", RequirePassword(api.minerHeaderHandlerPOST, requiredPassword))
-		router.GET("/miner/start", RequirePassword(api.minerStartHandler, requiredPassword))
-		router.GET("/miner/stop", RequirePassword(api.minerStopHandler, requiredPassword))
+		router.GET("/miner/cpu", api.minerCPUHandlerGET)
+		router.POST("/miner/cpu", auth.requireScope(api.minerCPUHandlerPOST, scopeAdmin))
+		router.GET("/miner/header", auth.requireScope(api.minerHeaderHandlerGET, scopeAdmin))
+		router.POST("/miner/header", auth.requireScope(api.minerHeaderHandlerPOST, scopeAdmin))
+		router.GET("/miner/start", auth.requireScope(api.minerStartHandler, scopeAdmin))
+		router.GET("/miner/stop", auth.requireScope(api.minerStopHandler, scopeAdmin))
+		router.GET("/miner/template", api.minerTemplateHandler)
+		router.GET("/miner/payouts", api.minerPayoutsHandlerGET)
+		router.POST("/miner/payouts", auth.requireScope(api.minerPayoutsHandlerPOST, scopeAdmin))
 	}
 
 	// Renter API Calls
 	if api.renter != nil {
 		router.GET("/renter", api.renterHandlerGET)
-		router.POST("/renter", RequirePassword(api.renterHandlerPOST, requiredPassword))
+		router.POST("/renter", auth.requireScope(api.renterHandlerPOST, scopeAdmin))
+		router.GET("/renter/consistency", api.renterConsistencyHandlerGET)
+		router.POST("/renter/consistency", auth.requireScope(api.renterConsistencyHandlerPOST, scopeAdmin))
 		router.GET("/renter/contracts", api.renterContractsHandler)
 		router.GET("/renter/downloads", api.renterDownloadsHandler)
 		router.GET("/renter/files", api.renterFilesHandler)
+		router.GET("/renter/history", api.renterHistoryHandler)
 
 		// TODO: re-enable these routes once the new .sia format has been
 		// standardized and implemented.
-		// router.POST("/renter/load", RequirePassword(api.renterLoadHandler, requiredPassword))
-		// router.POST("/renter/loadascii", RequirePassword(api.renterLoadAsciiHandler, requiredPassword))
-		// router.GET("/renter/share", RequirePassword(api.renterShareHandler, requiredPassword))
-		// router.GET("/renter/shareascii", RequirePassword(api.renterShareAsciiHandler, requiredPassword))
-
-		router.POST("/renter/delete/*siapath", RequirePassword(api.renterDeleteHandler, requiredPassword))
-		router.GET("/renter/download/*siapath", RequirePassword(api.renterDownloadHandler, requiredPassword))
-		router.POST("/renter/rename/*siapath", RequirePassword(api.renterRenameHandler, requiredPassword))
-		router.POST("/renter/upload/*siapath", RequirePassword(api.renterUploadHandler, requiredPassword))
+		// router.POST("/renter/load", auth.requireScope(api.renterLoadHandler, scopeAdmin))
+		// router.POST("/renter/loadascii", auth.requireScope(api.renterLoadAsciiHandler, scopeAdmin))
+		// router.GET("/renter/share", auth.requireScope(api.renterShareHandler, scopeAdmin))
+		// router.GET("/renter/shareascii", auth.requireScope(api.renterShareAsciiHandler, scopeAdmin))
+
+		router.POST("/renter/batch/download", auth.requireScope(api.renterBatchDownloadHandler, scopeAdmin))
+		router.GET("/renter/batch/progress", api.renterBatchProgressHandler)
+		router.POST("/renter/batch/upload", auth.requireScope(api.renterBatchUploadHandler, scopeAdmin))
+		router.POST("/renter/copy/*siapath", auth.requireScope(api.renterCopyHandler, scopeAdmin))
+		router.POST("/renter/delete/*siapath", auth.requireScope(api.renterDeleteHandler, scopeAdmin))
+		router.GET("/renter/download/*siapath", auth.requireScope(api.renterDownloadHandler, scopeAdmin))
+		router.POST("/renter/keys/*siapath", auth.requireScope(api.renterKeysHandler, scopeAdmin))
+		router.POST("/renter/rename/*siapath", auth.requireScope(api.renterRenameHandler, scopeAdmin))
+		router.POST("/renter/retention/*siapath", auth.requireScope(api.renterRetentionHandler, scopeAdmin))
+		router.POST("/renter/tags/*siapath", auth.requireScope(api.renterTagsHandler, scopeAdmin))
+		router.POST("/renter/upload/*siapath", auth.requireScope(api.renterUploadHandler, scopeAdmin))
 
 		// HostDB endpoints.
 		router.GET("/hostdb/active", api.renterHostsActiveHandler)
 		router.GET("/hostdb/all", api.renterHostsAllHandler)
+		router.GET("/hostdb/history/:netaddress", api.renterHostsHistoryHandler)
 	}
 
 	// TransactionPool API Calls
 	if api.tpool != nil {
+		router.GET("/transactionpool/fee", api.transactionpoolFeeHandler)
+		router.GET("/transactionpool/metrics", api.transactionpoolMetricsHandler)
 		// TODO: re-enable this route once the transaction pool API has been finalized
 		//router.GET("/transactionpool/transactions", api.transactionpoolTransactionsHandler)
 	}
@@ -234,25 +281,57 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	// Wallet API Calls
 	if api.wallet != nil {
 		router.GET("/wallet", api.walletHandler)
-		router.POST("/wallet/033x", RequirePassword(api.wallet033xHandler, requiredPassword))
-		router.GET("/wallet/address", RequirePassword(api.walletAddressHandler, requiredPassword))
+		router.POST("/wallet/033x", auth.requireScope(api.wallet033xHandler, scopeAdmin))
+		router.GET("/wallet/accounts", api.walletAccountsHandler)
+		router.GET("/wallet/accounts/address/:name", auth.requireScope(api.walletAccountsAddressHandler, ScopeWalletSpend))
+		router.POST("/wallet/accounts/create", auth.requireScope(api.walletAccountsCreateHandler, scopeAdmin))
+		router.POST("/wallet/accounts/siacoins/:name", auth.requireScope(api.walletAccountsSiacoinsHandler, ScopeWalletSpend))
+		router.GET("/wallet/accounts/transactions/:name", api.walletAccountsTransactionsHandler)
+		router.GET("/wallet/address", auth.requireScope(api.walletAddressHandler, ScopeWalletSpend))
 		router.GET("/wallet/addresses", api.walletAddressesHandler)
-		router.GET("/wallet/backup", RequirePassword(api.walletBackupHandler, requiredPassword))
-		router.POST("/wallet/init", RequirePassword(api.walletInitHandler, requiredPassword))
-		router.POST("/wallet/lock", RequirePassword(api.walletLockHandler, requiredPassword))
-		router.POST("/wallet/seed", RequirePassword(api.walletSeedHandler, requiredPassword))
-		router.GET("/wallet/seeds", RequirePassword(api.walletSeedsHandler, requiredPassword))
-		router.POST("/wallet/siacoins", RequirePassword(api.walletSiacoinsHandler, requiredPassword))
-		router.POST("/wallet/siafunds", RequirePassword(api.walletSiafundsHandler, requiredPassword))
-		router.POST("/wallet/siagkey", RequirePassword(api.walletSiagkeyHandler, requiredPassword))
+		router.GET("/wallet/backup", auth.requireScope(api.walletBackupHandler, scopeAdmin))
+		router.POST("/wallet/init", auth.requireScope(api.walletInitHandler, scopeAdmin))
+		router.GET("/wallet/keypair", auth.requireScope(api.walletKeyPairHandler, scopeAdmin))
+		router.POST("/wallet/lock", auth.requireScope(api.walletLockHandler, ScopeWalletSpend))
+		router.POST("/wallet/loosekey", auth.requireScope(api.walletLooseKeyHandler, scopeAdmin))
+		router.GET("/wallet/scheduled", api.walletScheduledHandler)
+		router.POST("/wallet/scheduled/cancel/:id", auth.requireScope(api.walletScheduledCancelHandler, ScopeWalletSpend))
+		router.POST("/wallet/seed", auth.requireScope(api.walletSeedHandler, scopeAdmin))
+		router.GET("/wallet/seeds", auth.requireScope(api.walletSeedsHandler, scopeAdmin))
+		router.POST("/wallet/siacoins", auth.requireScope(api.walletSiacoinsHandler, ScopeWalletSpend))
+		router.GET("/wallet/siacoins/max", api.walletSiacoinsMaxHandler)
+		router.POST("/wallet/siacoins/schedule", auth.requireScope(api.walletSiacoinsScheduleHandler, ScopeWalletSpend))
+		router.POST("/wallet/siafunds", auth.requireScope(api.walletSiafundsHandler, ScopeWalletSpend))
+		router.POST("/wallet/siagkey", auth.requireScope(api.walletSiagkeyHandler, scopeAdmin))
+		router.POST("/wallet/sweep", auth.requireScope(api.walletSweepHandler, scopeAdmin))
 		router.GET("/wallet/transaction/:id", api.walletTransactionHandler)
 		router.GET("/wallet/transactions", api.walletTransactionsHandler)
 		router.GET("/wallet/transactions/:addr", api.walletTransactionsAddrHandler)
-		router.POST("/wallet/unlock", RequirePassword(api.walletUnlockHandler, requiredPassword))
+		router.POST("/wallet/unlock", auth.requireScope(api.walletUnlockHandler, ScopeWalletSpend))
 	}
 
-	// Apply UserAgent middleware and return the API
-	api.router = RequireUserAgent(router, requiredUserAgent)
+	// Event API Calls
+	router.GET("/events", api.eventsHandler)
+
+	// Job API Calls
+	router.GET("/jobs", api.jobsHandler)
+	router.GET("/jobs/:id", api.jobsIDHandler)
+
+	// Metrics API Calls
+	router.GET("/metrics", api.metricsHandler)
+
+	// Apply UserAgent and authentication middleware and return the API.
+	// Monitoring systems cannot set the User-Agent, so /metrics is exempt
+	// from the User-Agent check.
+	reads := auth.requireReads(router)
+	checked := RequireUserAgent(reads, requiredUserAgent)
+	api.router = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/metrics" {
+			reads.ServeHTTP(w, req)
+			return
+		}
+		checked.ServeHTTP(w, req)
+	})
 	return api
 }
 
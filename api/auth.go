@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// A Scope is a set of API calls that an API token is allowed to make. The API
+// password grants every scope, and is the only credential that can make
+// calls outside of the scopes, such as changing the wallet seed.
+type Scope string
+
+const (
+	// ScopeRead allows a token to make calls that only read data. Every
+	// token has this scope, and it only needs to be listed for tokens that
+	// have no other scope.
+	ScopeRead Scope = "read"
+
+	// ScopeWalletSpend allows a token to unlock the wallet, generate
+	// addresses, and send siacoins and siafunds.
+	ScopeWalletSpend Scope = "wallet-spend"
+
+	// ScopeHostAdmin allows a token to change the settings of the host,
+	// announce it, and manage its storage.
+	ScopeHostAdmin Scope = "host-admin"
+)
+
+const (
+	// scopeAdmin is required by the API calls that only the API password
+	// can make. It cannot be granted to a token.
+	scopeAdmin Scope = "admin"
+)
+
+var (
+	errDuplicateToken = errors.New("API token is listed more than once")
+)
+
+// A Token is a secret that grants access to the API calls of its scopes. A
+// token must not be the same as the API password.
+type Token struct {
+	Secret string
+	Scopes []Scope
+}
+
+// An Auth describes the credentials accepted by the API. If the password is
+// empty, authentication is disabled and the tokens are ignored. If
+// AuthenticateReads is true, every API call requires a credential, not just
+// the calls that expose sensitive information or modify state.
+type Auth struct {
+	Password          string
+	Tokens            []Token
+	AuthenticateReads bool
+}
+
+// ParseScopes parses a comma-separated list of scopes.
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, name := range strings.Split(s, ",") {
+		scope := Scope(strings.TrimSpace(name))
+		switch scope {
+		case ScopeRead, ScopeWalletSpend, ScopeHostAdmin:
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("unknown API scope %q", scope)
+		}
+	}
+	return scopes, nil
+}
+
+// LoadTokens reads API tokens from a file. Each line of the file holds a token
+// followed by a comma-separated list of its scopes, e.g.
+//
+//	dashboard-secret read
+//	payments-secret wallet-spend
+//
+// Blank lines and lines starting with '#' are ignored.
+func LoadTokens(filename string) ([]Token, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tokens []Token
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %v: expected a token and a list of scopes", line)
+		}
+		if _, exists := seen[fields[0]]; exists {
+			return nil, fmt.Errorf("line %v: %v", line, errDuplicateToken)
+		}
+		seen[fields[0]] = struct{}{}
+		scopes, err := ParseScopes(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		tokens = append(tokens, Token{Secret: fields[0], Scopes: scopes})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// authenticator checks the credentials of API requests.
+type authenticator struct {
+	password          string
+	tokens            map[string][]Scope
+	authenticateReads bool
+}
+
+// newAuthenticator creates an authenticator that accepts the credentials
+// described by 'auth'.
+func newAuthenticator(auth Auth) authenticator {
+	a := authenticator{
+		password:          auth.Password,
+		tokens:            make(map[string][]Scope),
+		authenticateReads: auth.AuthenticateReads,
+	}
+	for _, t := range auth.Tokens {
+		a.tokens[t.Secret] = t.Scopes
+	}
+	return a
+}
+
+// credentials returns the secret presented by a request, either as the
+// password of HTTP basic auth or as a bearer token.
+func credentials(req *http.Request) (string, bool) {
+	if _, pass, ok := req.BasicAuth(); ok {
+		return pass, true
+	}
+	const prefix = "Bearer "
+	if h := req.Header.Get("Authorization"); strings.HasPrefix(h, prefix) {
+		return strings.TrimPrefix(h, prefix), true
+	}
+	return "", false
+}
+
+// authorized returns whether a request presented a credential with the
+// provided scope, and whether it presented a valid credential at all. An
+// empty scope is granted to every valid credential.
+func (a authenticator) authorized(req *http.Request, scope Scope) (allowed, valid bool) {
+	secret, ok := credentials(req)
+	if !ok {
+		return false, false
+	}
+	if secret == a.password {
+		return true, true
+	}
+	scopes, exists := a.tokens[secret]
+	if !exists {
+		return false, false
+	}
+	if scope == "" || scope == ScopeRead {
+		return true, true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// writeUnauthorized writes the response to a request that did not present a
+// valid credential.
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Basic realm=\"SiaAPI\"")
+	WriteError(w, Error{"API authentication failed."}, http.StatusUnauthorized)
+}
+
+// requireScope is middleware that requires a request to present the API
+// password or a token with the provided scope.
+func (a authenticator) requireScope(h httprouter.Handle, scope Scope) httprouter.Handle {
+	if a.password == "" {
+		return h
+	}
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		allowed, valid := a.authorized(req, scope)
+		if !valid {
+			writeUnauthorized(w)
+			return
+		}
+		if !allowed {
+			WriteError(w, Error{"API token does not have the " + string(scope) + " scope."}, http.StatusForbidden)
+			return
+		}
+		h(w, req, ps)
+	}
+}
+
+// requireReads is middleware that requires every request to present a valid
+// credential if reads are authenticated.
+func (a authenticator) requireReads(h http.Handler) http.Handler {
+	if a.password == "" || !a.authenticateReads {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, valid := a.authorized(req, ScopeRead); !valid {
+			writeUnauthorized(w)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
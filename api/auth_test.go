@@ -0,0 +1,110 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestLoadTokens probes the LoadTokens function.
+func TestLoadTokens(t *testing.T) {
+	dir := build.TempDir("api", "TestLoadTokens")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		contents string
+		tokens   int
+		valid    bool
+	}{
+		{"", 0, true},
+		{"# dashboard\ndash read\n\npay wallet-spend,host-admin\n", 2, true},
+		{"dash read\ndash wallet-spend\n", 0, false},
+		{"dash\n", 0, false},
+		{"dash admin\n", 0, false},
+		{"dash read,\n", 0, false},
+	}
+	for i, test := range tests {
+		filename := filepath.Join(dir, "tokens")
+		if err := ioutil.WriteFile(filename, []byte(test.contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := LoadTokens(filename)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: expected valid=%v, got %v", i, test.valid, err)
+		} else if len(tokens) != test.tokens {
+			t.Errorf("%v: expected %v tokens, got %v", i, test.tokens, len(tokens))
+		}
+	}
+}
+
+// TestAuthenticatorScopes checks that the password can make every call, and
+// that tokens can only make the calls of their scopes.
+func TestAuthenticatorScopes(t *testing.T) {
+	auth := newAuthenticator(Auth{
+		Password: "password",
+		Tokens: []Token{
+			{Secret: "reader", Scopes: []Scope{ScopeRead}},
+			{Secret: "spender", Scopes: []Scope{ScopeWalletSpend}},
+		},
+		AuthenticateReads: true,
+	})
+	ok := func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		WriteSuccess(w)
+	}
+	router := httprouter.New()
+	router.GET("/read", ok)
+	router.POST("/spend", auth.requireScope(ok, ScopeWalletSpend))
+	router.POST("/host", auth.requireScope(ok, ScopeHostAdmin))
+	router.POST("/admin", auth.requireScope(ok, scopeAdmin))
+	handler := auth.requireReads(router)
+
+	tests := []struct {
+		method, path, secret string
+		code                 int
+	}{
+		{"GET", "/read", "", http.StatusUnauthorized},
+		{"GET", "/read", "wrong", http.StatusUnauthorized},
+		{"GET", "/read", "reader", http.StatusNoContent},
+		{"GET", "/read", "spender", http.StatusNoContent},
+		{"POST", "/spend", "reader", http.StatusForbidden},
+		{"POST", "/spend", "spender", http.StatusNoContent},
+		{"POST", "/host", "spender", http.StatusForbidden},
+		{"POST", "/admin", "spender", http.StatusForbidden},
+		{"POST", "/admin", "password", http.StatusNoContent},
+		{"POST", "/host", "password", http.StatusNoContent},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.secret != "" {
+			req.SetBasicAuth("", test.secret)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Errorf("%v %v with %q: expected %v, got %v", test.method, test.path, test.secret, test.code, w.Code)
+		}
+	}
+
+	// Tokens may also be presented as bearer tokens.
+	req, err := http.NewRequest("POST", "/spend", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer spender")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Error("bearer token was rejected:", w.Code)
+	}
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"path/filepath"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
@@ -17,6 +21,49 @@ type ConsensusGET struct {
 	Target       types.Target      `json:"target"`
 }
 
+// ConsensusConsistencyGET contains the results of a consistency check of the
+// consensus database.
+type ConsensusConsistencyGET struct {
+	Errors   []string `json:"errors"`
+	Repaired bool     `json:"repaired"`
+}
+
+// ConsensusReorgsGET contains the reorgs of the blockchain that were seen by
+// the consensus set.
+type ConsensusReorgsGET struct {
+	Reorgs []modules.ReorgEvent `json:"reorgs"`
+}
+
+// ConsensusCheckpointGET contains an unsigned checkpoint committing to the
+// current block and the current state of the consensus set.
+type ConsensusCheckpointGET struct {
+	Height        types.BlockHeight `json:"height"`
+	BlockID       types.BlockID     `json:"blockid"`
+	ConsensusHash crypto.Hash       `json:"consensushash"`
+}
+
+// ConsensusUTXOsGET describes the set of unspent siacoin and siafund outputs
+// of the consensus set.
+type ConsensusUTXOsGET struct {
+	Height         types.BlockHeight `json:"height"`
+	BlockID        types.BlockID     `json:"blockid"`
+	SiacoinOutputs uint64            `json:"siacoinoutputs"`
+	SiafundOutputs uint64            `json:"siafundoutputs"`
+	Hash           crypto.Hash       `json:"hash"`
+}
+
+// ConsensusUTXOsImportPOST contains the results of comparing an imported UTXO
+// set against the UTXO set of the consensus set.
+type ConsensusUTXOsImportPOST struct {
+	Height         types.BlockHeight       `json:"height"`
+	BlockID        types.BlockID           `json:"blockid"`
+	Hash           crypto.Hash             `json:"hash"`
+	Matches        bool                    `json:"matches"`
+	Differences    uint64                  `json:"differences"`
+	SiacoinOutputs []types.SiacoinOutputID `json:"siacoinoutputs"`
+	SiafundOutputs []types.SiafundOutputID `json:"siafundoutputs"`
+}
+
 // consensusHandler handles the API calls to /consensus.
 func (api *API) consensusHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	cbid := api.cs.CurrentBlock().ID()
@@ -28,3 +75,127 @@ func (api *API) consensusHandler(w http.ResponseWriter, req *http.Request, _ htt
 		Target:       currentTarget,
 	})
 }
+
+// consensusConsistencyHandlerGET handles the API calls to
+// /consensus/consistency, checking the consensus database for
+// inconsistencies.
+func (api *API) consensusConsistencyHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	api.writeConsistencyReport(w, false)
+}
+
+// consensusConsistencyHandlerPOST handles the API calls to
+// /consensus/consistency, checking the consensus database for inconsistencies
+// and repairing any that are found.
+func (api *API) consensusConsistencyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	api.writeConsistencyReport(w, true)
+}
+
+// writeConsistencyReport runs a consistency check of the consensus database
+// and writes the report.
+func (api *API) writeConsistencyReport(w http.ResponseWriter, repair bool) {
+	report, err := api.cs.ConsistencyCheck(repair)
+	if err != nil {
+		WriteError(w, Error{"consistency check failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, ConsensusConsistencyGET{
+		Errors:   report.Errors,
+		Repaired: report.Repaired,
+	})
+}
+
+// consensusReorgsHandlerGET handles the API calls to /consensus/reorgs,
+// returning the reorgs that were seen after the reorg with index 'since'.
+func (api *API) consensusReorgsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var since uint64
+	if req.FormValue("since") != "" {
+		_, err := fmt.Sscan(req.FormValue("since"), &since)
+		if err != nil {
+			WriteError(w, Error{"could not parse since: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	reorgs := api.cs.Reorgs(since)
+	if reorgs == nil {
+		reorgs = []modules.ReorgEvent{}
+	}
+	WriteJSON(w, ConsensusReorgsGET{
+		Reorgs: reorgs,
+	})
+}
+
+// consensusCheckpointHandlerGET handles the API calls to
+// /consensus/checkpoint.
+func (api *API) consensusCheckpointHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	cp, err := api.cs.CurrentCheckpoint()
+	if err != nil {
+		WriteError(w, Error{"could not compute the checkpoint: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, ConsensusCheckpointGET{
+		Height:        cp.Height,
+		BlockID:       cp.BlockID,
+		ConsensusHash: cp.ConsensusHash,
+	})
+}
+
+// utxoSetGET converts a UTXO set header to a ConsensusUTXOsGET.
+func utxoSetGET(header modules.UTXOSetHeader) ConsensusUTXOsGET {
+	return ConsensusUTXOsGET{
+		Height:         header.Height,
+		BlockID:        header.BlockID,
+		SiacoinOutputs: header.SiacoinOutputs,
+		SiafundOutputs: header.SiafundOutputs,
+		Hash:           header.Hash,
+	}
+}
+
+// consensusUTXOsHandlerGET handles the API calls to /consensus/utxos.
+func (api *API) consensusUTXOsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	header, err := api.cs.UTXOSet()
+	if err != nil {
+		WriteError(w, Error{"could not compute the UTXO set: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, utxoSetGET(header))
+}
+
+// consensusUTXOsExportHandlerPOST handles the API calls to
+// /consensus/utxos/export.
+func (api *API) consensusUTXOsExportHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	destination := req.FormValue("destination")
+	if !filepath.IsAbs(destination) {
+		WriteError(w, Error{"error when calling /consensus/utxos/export: destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	header, err := api.cs.ExportUTXOSet(destination)
+	if err != nil {
+		WriteError(w, Error{"error when calling /consensus/utxos/export: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, utxoSetGET(header))
+}
+
+// consensusUTXOsImportHandlerPOST handles the API calls to
+// /consensus/utxos/import.
+func (api *API) consensusUTXOsImportHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	source := req.FormValue("source")
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"error when calling /consensus/utxos/import: source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	comparison, err := api.cs.ImportUTXOSet(source)
+	if err != nil {
+		WriteError(w, Error{"error when calling /consensus/utxos/import: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ConsensusUTXOsImportPOST{
+		Height:         comparison.Header.Height,
+		BlockID:        comparison.Header.BlockID,
+		Hash:           comparison.Header.Hash,
+		Matches:        comparison.Matches,
+		Differences:    comparison.Differences,
+		SiacoinOutputs: comparison.SiacoinOutputs,
+		SiafundOutputs: comparison.SiafundOutputs,
+	})
+}
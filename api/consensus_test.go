@@ -1,6 +1,8 @@
 package api
 
 import (
+	"net/url"
+	"path/filepath"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/types"
@@ -33,3 +35,103 @@ func TestIntegrationConsensusGET(t *testing.T) {
 		t.Error("wrong target returned in consensus GET call")
 	}
 }
+
+// TestIntegrationConsensusConsistency probes the GET and POST calls to
+// /consensus/consistency.
+func TestIntegrationConsensusConsistency(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	st, err := createServerTester("TestIntegrationConsensusConsistency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	var ccg ConsensusConsistencyGET
+	err = st.getAPI("/consensus/consistency", &ccg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ccg.Errors) != 0 || ccg.Repaired {
+		t.Error("consistent consensus set reported inconsistencies:", ccg.Errors)
+	}
+	err = st.postAPI("/consensus/consistency", nil, &ccg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ccg.Errors) != 0 || ccg.Repaired {
+		t.Error("consistent consensus set was repaired:", ccg.Errors)
+	}
+}
+
+// TestIntegrationConsensusReorgs probes the GET call to /consensus/reorgs.
+func TestIntegrationConsensusReorgs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	st, err := createServerTester("TestIntegrationConsensusReorgs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	var crg ConsensusReorgsGET
+	err = st.getAPI("/consensus/reorgs", &crg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crg.Reorgs == nil || len(crg.Reorgs) != 0 {
+		t.Error("expected an empty list of reorgs, got", crg.Reorgs)
+	}
+	err = st.getAPI("/consensus/reorgs?since=foo", &crg)
+	if err == nil {
+		t.Error("expected an error for an invalid since parameter")
+	}
+}
+
+// TestIntegrationConsensusUTXOs probes the calls to /consensus/utxos.
+func TestIntegrationConsensusUTXOs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	st, err := createServerTester("TestIntegrationConsensusUTXOs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	var cug ConsensusUTXOsGET
+	err = st.getAPI("/consensus/utxos", &cug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cug.BlockID != st.cs.CurrentBlock().ID() || cug.SiacoinOutputs == 0 {
+		t.Error("wrong UTXO set returned:", cug)
+	}
+
+	// Export the set and import it again.
+	filename := filepath.Join(st.dir, "utxos")
+	var export ConsensusUTXOsGET
+	err = st.postAPI("/consensus/utxos/export", url.Values{"destination": {filename}}, &export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export != cug {
+		t.Error("exported UTXO set does not match:", export, cug)
+	}
+	var cuip ConsensusUTXOsImportPOST
+	err = st.postAPI("/consensus/utxos/import", url.Values{"source": {filename}}, &cuip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cuip.Matches || cuip.Hash != cug.Hash {
+		t.Error("imported UTXO set does not match:", cuip)
+	}
+
+	// Relative paths should be rejected.
+	err = st.postAPI("/consensus/utxos/export", url.Values{"destination": {"utxos"}}, &export)
+	if err == nil {
+		t.Error("export to a relative path succeeded")
+	}
+}
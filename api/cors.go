@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORS is middleware that allows browsers to call the API from the provided
+// origins, such as "https://dashboard.example.com". Browsers cannot set the
+// User-Agent of a request, so requests from an allowed origin are exempt from
+// the User-Agent check; the origin check guards against the same cross-site
+// requests. Authentication is still required where it is enabled.
+func CORS(h http.Handler, origins []string, requiredUserAgent string) http.Handler {
+	if len(origins) == 0 {
+		return h
+	}
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if !allowed[origin] {
+			h.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+
+		// Answer preflight requests without passing them to the API.
+		if req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !strings.Contains(req.UserAgent(), requiredUserAgent) {
+			req.Header.Set("User-Agent", strings.TrimSpace(req.UserAgent()+" "+requiredUserAgent))
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCORS probes the CORS middleware.
+func TestCORS(t *testing.T) {
+	h := CORS(RequireUserAgent(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		WriteSuccess(w)
+	}), "Sia-Agent"), []string{"https://dashboard.example.com"}, "Sia-Agent")
+	call := func(method, origin, ua string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/wallet", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		req.Header.Set("User-Agent", ua)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Requests without an allowed origin are unchanged.
+	if rec := call("GET", "", "Sia-Agent"); rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("request without an origin was changed:", rec.Code, rec.Header())
+	}
+	if rec := call("GET", "https://evil.example.com", "Mozilla"); rec.Code != http.StatusBadRequest || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("request from a disallowed origin was allowed:", rec.Code, rec.Header())
+	}
+
+	// Requests from an allowed origin do not need the User-Agent.
+	rec := call("GET", "https://dashboard.example.com", "Mozilla")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" {
+		t.Fatal("request from an allowed origin was rejected:", rec.Code, rec.Header())
+	}
+	rec = call("OPTIONS", "https://dashboard.example.com", "Mozilla")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Fatal("preflight request was not answered:", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/websocket"
+)
+
+// eventPublishers returns the modules of the API that publish events.
+func (api *API) eventPublishers() []modules.EventPublisher {
+	var publishers []modules.EventPublisher
+	for _, m := range []interface{}{api.cs, api.explorer, api.gateway, api.host, api.miner, api.renter, api.tpool, api.wallet} {
+		if p, ok := m.(modules.EventPublisher); ok {
+			publishers = append(publishers, p)
+		}
+	}
+	return publishers
+}
+
+// eventsHandler handles the API call that upgrades the connection to a
+// WebSocket and streams the events published by the modules.
+func (api *API) eventsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// websocket.Server is used instead of websocket.Handler so that clients
+	// that do not send an Origin header are accepted. Browsers cannot set
+	// the required User-Agent, so cross-site requests are already refused.
+	websocket.Server{Handler: api.streamEvents}.ServeHTTP(w, req)
+}
+
+// streamEvents sends the events published by the modules to a WebSocket
+// client as JSON objects, one per message, until the client disconnects.
+func (api *API) streamEvents(ws *websocket.Conn) {
+	defer ws.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// Merge the events of every module into a single stream.
+	events := make(chan modules.Event)
+	for _, p := range api.eventPublishers() {
+		c, unsubscribe := p.SubscribeEvents()
+		defer unsubscribe()
+		go func(c <-chan modules.Event) {
+			for e := range c {
+				select {
+				case events <- e:
+				case <-stop:
+					return
+				}
+			}
+		}(c)
+	}
+
+	// The client does not send anything, so the connection is read only to
+	// learn when it has been closed.
+	disconnected := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		close(disconnected)
+	}()
+
+	for {
+		select {
+		case e := <-events:
+			if err := websocket.JSON.Send(ws, e); err != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
@@ -12,6 +12,14 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// defaultAddressHistoryLimit is the number of transactions returned by
+	// /explorer/addresses/:address if no limit is provided, and
+	// maxAddressHistoryLimit is the most that can be requested at once.
+	defaultAddressHistoryLimit = 50
+	maxAddressHistoryLimit     = 500
+)
+
 type (
 	// ExplorerBlock is a block with some extra information such as the id and
 	// height. This information is provided for programs that may not be
@@ -74,6 +82,57 @@ type (
 		Transaction  ExplorerTransaction   `json:"transaction"`
 		Transactions []ExplorerTransaction `json:"transactions"`
 	}
+
+	// ExplorerAddressGET is the object returned as a response to a GET
+	// request to /explorer/addresses/:address. History lists a page of the
+	// transactions that involve the address, from the most recent to the
+	// oldest, and Transactions and Blocks contain those transactions and the
+	// blocks of any miner payouts. Total is the number of transactions that
+	// involve the address.
+	ExplorerAddressGET struct {
+		Total        int                             `json:"total"`
+		History      []modules.UnlockHashTransaction `json:"history"`
+		Blocks       []ExplorerBlock                 `json:"blocks"`
+		Transactions []ExplorerTransaction           `json:"transactions"`
+	}
+
+	// ExplorerConsistencyGET contains the results of a consistency check of
+	// the explorer's database against the consensus set.
+	ExplorerConsistencyGET struct {
+		Errors []string `json:"errors"`
+	}
+
+	// ExplorerContractGET is the object returned as a response to a GET
+	// request to /explorer/contracts/:id.
+	ExplorerContractGET struct {
+		modules.ContractLifecycle
+	}
+
+	// ExplorerStorageGET is the object returned as a response to a GET request
+	// to /explorer/storage.
+	ExplorerStorageGET struct {
+		modules.StorageStats
+	}
+
+	// ExplorerStorageHistoryGET is the object returned as a response to a GET
+	// request to /explorer/storage/history.
+	ExplorerStorageHistoryGET struct {
+		Stats []modules.StorageStats `json:"stats"`
+	}
+
+	// ExplorerSiafundsGET is the object returned as a response to a GET
+	// request to /explorer/siafunds.
+	ExplorerSiafundsGET struct {
+		modules.SiafundDistribution
+	}
+
+	// ExplorerSiafundsAddressGET is the object returned as a response to a
+	// GET request to /explorer/siafunds/:address.
+	ExplorerSiafundsAddressGET struct {
+		modules.SiafundHolder
+		Transfers []modules.SiafundTransfer `json:"transfers"`
+		Claims    []modules.SiafundClaim    `json:"claims"`
+	}
 )
 
 // buildExplorerTransaction takes a transaction and the height + id of the
@@ -351,6 +410,56 @@ func (api *API) explorerHashHandler(w http.ResponseWriter, req *http.Request, ps
 	WriteError(w, Error{"unrecognized hash used as input to /explorer/hash"}, http.StatusBadRequest)
 }
 
+// explorerAddressHandler handles API calls to /explorer/addresses/:address.
+func (api *API) explorerAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("address"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	offset, limit := 0, defaultAddressHistoryLimit
+	if err := parsePagination(req, &offset, &limit); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if limit > maxAddressHistoryLimit {
+		limit = maxAddressHistoryLimit
+	}
+
+	history, total := api.explorer.UnlockHashHistory(addr, offset, limit)
+	txids := make([]types.TransactionID, len(history))
+	for i, ut := range history {
+		txids[i] = ut.ID
+	}
+	txns, blocks := api.buildTransactionSet(txids)
+	if history == nil {
+		history = []modules.UnlockHashTransaction{}
+	}
+	WriteJSON(w, ExplorerAddressGET{
+		Total:        total,
+		History:      history,
+		Blocks:       blocks,
+		Transactions: txns,
+	})
+}
+
+// explorerContractHandler handles API calls to /explorer/contracts/:id.
+func (api *API) explorerContractHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	hash, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	cl, exists := api.explorer.ContractLifecycle(types.FileContractID(hash))
+	if !exists {
+		WriteError(w, Error{"file contract not found"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerContractGET{
+		ContractLifecycle: cl,
+	})
+}
+
 // explorerHandler handles API calls to /explorer
 func (api *API) explorerHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	facts := api.explorer.LatestBlockFacts()
@@ -358,3 +467,80 @@ func (api *API) explorerHandler(w http.ResponseWriter, req *http.Request, _ http
 		BlockFacts: facts,
 	})
 }
+
+// explorerSiafundsHandler handles API calls to /explorer/siafunds.
+func (api *API) explorerSiafundsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, ExplorerSiafundsGET{
+		SiafundDistribution: api.explorer.SiafundDistribution(),
+	})
+}
+
+// explorerSiafundsAddressHandler handles API calls to
+// /explorer/siafunds/:address.
+func (api *API) explorerSiafundsAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("address"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerSiafundsAddressGET{
+		SiafundHolder: api.explorer.SiafundHolder(addr),
+		Transfers:     api.explorer.SiafundTransfers(addr),
+		Claims:        api.explorer.SiafundClaims(addr),
+	})
+}
+
+// explorerStorageHandler handles API calls to /explorer/storage.
+func (api *API) explorerStorageHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, ExplorerStorageGET{
+		StorageStats: api.explorer.StorageStats(),
+	})
+}
+
+// explorerStorageHistoryHandler handles API calls to
+// /explorer/storage/history. By default, the statistics of every 144th block
+// (about one day) of the blockchain are returned.
+func (api *API) explorerStorageHistoryHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	start := types.BlockHeight(0)
+	end := api.explorer.StorageStats().Height
+	step := types.BlockHeight(144)
+	params := []struct {
+		name  string
+		value *types.BlockHeight
+	}{
+		{"start", &start},
+		{"end", &end},
+		{"step", &step},
+	}
+	for _, param := range params {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		_, err := fmt.Sscan(req.FormValue(param.name), param.value)
+		if err != nil {
+			WriteError(w, Error{"could not parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	stats, err := api.explorer.StorageStatsHistory(start, end, step)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerStorageHistoryGET{
+		Stats: stats,
+	})
+}
+
+// explorerConsistencyHandler handles API calls to /explorer/consistency,
+// comparing the explorer's database against the consensus set.
+func (api *API) explorerConsistencyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	report, err := api.explorer.ConsistencyCheck()
+	if err != nil {
+		WriteError(w, Error{"consistency check failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, ExplorerConsistencyGET{
+		Errors: report.Errors,
+	})
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 
@@ -10,8 +12,20 @@ import (
 
 // GatewayGET contains the fields returned by a GET call to "/gateway".
 type GatewayGET struct {
-	NetAddress modules.NetAddress `json:"netaddress"`
-	Peers      []modules.Peer     `json:"peers"`
+	NetAddress        modules.NetAddress `json:"netaddress"`
+	Peers             []modules.Peer     `json:"peers"`
+	Bans              []modules.PeerBan  `json:"bans"`
+	NAT               modules.NATStatus  `json:"nat"`
+	MaxPeers          int                `json:"maxpeers"`
+	MaxInboundPeers   int                `json:"maxinboundpeers"`
+	MaxPeersPerSubnet int                `json:"maxpeerspersubnet"`
+	BanDuration       uint64             `json:"banduration"` // seconds
+}
+
+// GatewayBandwidthGET contains the fields returned by a GET call to
+// "/gateway/bandwidth".
+type GatewayBandwidthGET struct {
+	RPCs []modules.RPCBandwidth `json:"rpcs"`
 }
 
 // gatewayHandler handles the API call asking for the gatway status.
@@ -23,7 +37,92 @@ func (api *API) gatewayHandler(w http.ResponseWriter, req *http.Request, _ httpr
 	if peers == nil {
 		peers = make([]modules.Peer, 0)
 	}
-	WriteJSON(w, GatewayGET{api.gateway.Address(), peers})
+	settings := api.gateway.Settings()
+	WriteJSON(w, GatewayGET{
+		NetAddress:        api.gateway.Address(),
+		Peers:             peers,
+		Bans:              api.gateway.Bans(),
+		NAT:               api.gateway.NATStatus(),
+		MaxPeers:          settings.MaxPeers,
+		MaxInboundPeers:   settings.MaxInboundPeers,
+		MaxPeersPerSubnet: settings.MaxPeersPerSubnet,
+		BanDuration:       uint64(settings.BanDuration / time.Second),
+	})
+}
+
+// gatewayHandlerPOST handles the API call to change the gateway's settings.
+func (api *API) gatewayHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Settings that are not provided keep their current value.
+	settings := api.gateway.Settings()
+	limits := []struct {
+		name  string
+		value *int
+	}{
+		{"maxpeers", &settings.MaxPeers},
+		{"maxinboundpeers", &settings.MaxInboundPeers},
+		{"maxpeerspersubnet", &settings.MaxPeersPerSubnet},
+	}
+	for _, limit := range limits {
+		if req.FormValue(limit.name) == "" {
+			continue
+		}
+		_, err := fmt.Sscan(req.FormValue(limit.name), limit.value)
+		if err != nil {
+			WriteError(w, Error{"could not parse " + limit.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("banduration") != "" {
+		var seconds uint64
+		_, err := fmt.Sscan(req.FormValue("banduration"), &seconds)
+		if err != nil {
+			WriteError(w, Error{"could not parse banduration: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.BanDuration = time.Duration(seconds) * time.Second
+	}
+	err := api.gateway.SetSettings(settings)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayBandwidthHandlerGET handles the API call asking for the data
+// transferred by the gateway for each RPC.
+func (api *API) gatewayBandwidthHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayBandwidthGET{
+		RPCs: api.gateway.Bandwidth(),
+	})
+}
+
+// gatewayBandwidthHandlerPOST handles the API call to change the bandwidth
+// limit of an RPC.
+func (api *API) gatewayBandwidthHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	rpc := req.FormValue("rpc")
+	if rpc == "" {
+		WriteError(w, Error{"rpc must be specified"}, http.StatusBadRequest)
+		return
+	}
+	var limit uint64
+	_, err := fmt.Sscan(req.FormValue("limit"), &limit)
+	if err != nil {
+		WriteError(w, Error{"could not parse limit: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	settings := api.gateway.Settings()
+	if settings.BandwidthLimits == nil {
+		settings.BandwidthLimits = make(map[string]uint64)
+	}
+	settings.BandwidthLimits[rpc] = limit
+	err = api.gateway.SetSettings(settings)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
 }
 
 // gatewayConnectHandler handles the API call to add a peer to the gateway.
@@ -49,3 +148,16 @@ func (api *API) gatewayDisconnectHandler(w http.ResponseWriter, req *http.Reques
 
 	WriteSuccess(w)
 }
+
+// gatewayUnpinHandler handles the API call to clear the public key pinned for
+// a node.
+func (api *API) gatewayUnpinHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr := modules.NetAddress(ps.ByName("netaddress"))
+	err := api.gateway.UnpinNode(addr)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/gateway"
 )
 
@@ -93,3 +96,167 @@ func TestGatewayPeerDisconnect(t *testing.T) {
 		t.Fatal("/gateway/disconnect did not disconnect from peer", peer.Address())
 	}
 }
+
+// TestGatewayUnpin checks that POST /gateway/unpin clears the key pinned for a
+// node that the gateway has dialed.
+func TestGatewayUnpin(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestGatewayUnpin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	peer, err := gateway.New("localhost:0", false, build.TempDir("api", "TestGatewayUnpin2", "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	err = st.stdPostAPI("/gateway/connect/"+string(peer.Address()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = st.stdPostAPI("/gateway/unpin/"+string(peer.Address()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The key is no longer pinned.
+	err = st.stdPostAPI("/gateway/unpin/"+string(peer.Address()), nil)
+	if err == nil {
+		t.Fatal("expected an error when unpinning a node without a pinned key")
+	}
+}
+
+// TestGatewaySettings checks that POST /gateway changes the gateway's
+// connection limits and the duration that misbehaving hosts are banned for.
+func TestGatewaySettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestGatewaySettings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var info GatewayGET
+	err = st.getAPI("/gateway", &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Bans == nil || len(info.Bans) != 0 {
+		t.Fatal("expected an empty ban list, got", info.Bans)
+	}
+
+	values := url.Values{}
+	values.Set("maxpeers", "6")
+	values.Set("maxinboundpeers", "2")
+	values.Set("maxpeerspersubnet", "1")
+	values.Set("banduration", "3600")
+	err = st.stdPostAPI("/gateway", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.getAPI("/gateway", &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MaxPeers != 6 || info.MaxInboundPeers != 2 || info.MaxPeersPerSubnet != 1 {
+		t.Fatalf("limits were not updated: %v %v %v", info.MaxPeers, info.MaxInboundPeers, info.MaxPeersPerSubnet)
+	}
+	if info.BanDuration != 3600 {
+		t.Fatal("ban duration was not updated:", info.BanDuration)
+	}
+
+	// Settings that are not provided should be left unchanged.
+	err = st.stdPostAPI("/gateway", url.Values{"maxinboundpeers": {"0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.getAPI("/gateway", &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MaxPeers != 6 || info.MaxInboundPeers != 0 || info.BanDuration != 3600 {
+		t.Fatal("unexpected settings after partial update:", info)
+	}
+
+	invalid := []url.Values{
+		{"banduration": {"-1"}},
+		{"maxpeers": {"0"}},
+		{"maxinboundpeers": {"7"}},
+		{"maxpeerspersubnet": {"foo"}},
+	}
+	for _, values := range invalid {
+		err = st.stdPostAPI("/gateway", values)
+		if err == nil {
+			t.Error("expected invalid settings to be rejected:", values)
+		}
+	}
+}
+
+// TestGatewayBandwidth checks that /gateway/bandwidth reports the data
+// transferred for each RPC, and that POST /gateway/bandwidth sets the limit of
+// an RPC.
+func TestGatewayBandwidth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestGatewayBandwidth1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	peer, err := gateway.New("localhost:0", false, build.TempDir("api", "TestGatewayBandwidth2", "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	// Connecting calls the ShareNodes RPC on the peer.
+	err = st.stdPostAPI("/gateway/connect/"+string(peer.Address()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bg GatewayBandwidthGET
+	for i := 0; i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if err = st.getAPI("/gateway/bandwidth", &bg); err != nil {
+			t.Fatal(err)
+		}
+		if rpcBandwidthMatches(bg.RPCs, "ShareNodes", func(rb modules.RPCBandwidth) bool { return rb.Sent > 0 && rb.Received > 0 }) {
+			break
+		}
+	}
+	if !rpcBandwidthMatches(bg.RPCs, "ShareNodes", func(rb modules.RPCBandwidth) bool { return rb.Sent > 0 && rb.Received > 0 }) {
+		t.Fatal("ShareNodes traffic was not recorded:", bg.RPCs)
+	}
+
+	err = st.stdPostAPI("/gateway/bandwidth", url.Values{"rpc": {"RelayBlock"}, "limit": {"1000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = st.getAPI("/gateway/bandwidth", &bg); err != nil {
+		t.Fatal(err)
+	}
+	if !rpcBandwidthMatches(bg.RPCs, "RelayBlock", func(rb modules.RPCBandwidth) bool { return rb.Limit == 1000 }) {
+		t.Fatal("RelayBlock limit was not set:", bg.RPCs)
+	}
+
+	err = st.stdPostAPI("/gateway/bandwidth", url.Values{"limit": {"1000"}})
+	if err == nil {
+		t.Fatal("expected an error when no rpc is specified")
+	}
+}
+
+// rpcBandwidthMatches returns whether the bandwidth of the named RPC satisfies fn.
+func rpcBandwidthMatches(rpcs []modules.RPCBandwidth, name string, fn func(modules.RPCBandwidth) bool) bool {
+	for _, rb := range rpcs {
+		if rb.RPC == name {
+			return fn(rb)
+		}
+	}
+	return false
+}
@@ -4,12 +4,20 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// defaultAuditSectors is the number of sectors checked by a host audit
+	// if the call does not specify the number.
+	defaultAuditSectors = 10
+)
+
 var (
 	// errNoPath is returned when a call fails to provide a nonempty string
 	// for the path parameter.
@@ -29,6 +37,31 @@ type (
 		FinancialMetrics modules.HostFinancialMetrics `json:"financialmetrics"`
 		InternalSettings modules.HostInternalSettings `json:"internalsettings"`
 		NetworkMetrics   modules.HostNetworkMetrics   `json:"networkmetrics"`
+
+		// RemainingCollateralBudget is the amount of collateral that the
+		// host can still lock into new file contracts before reaching its
+		// collateral budget.
+		RemainingCollateralBudget types.Currency `json:"remainingcollateralbudget"`
+	}
+
+	// HostAlertsGET contains the information that is returned after a GET
+	// request to /host/alerts - the operational problems of the host, most
+	// severe first.
+	HostAlertsGET struct {
+		Alerts []modules.HostAlert `json:"alerts"`
+	}
+
+	// HostSummaryGET contains the information that is returned after a GET
+	// request to /host/summary - an aggregate of the operational state of the
+	// host, so that monitoring tools do not need to query several endpoints.
+	HostSummaryGET struct {
+		BlockHeight      types.BlockHeight               `json:"blockheight"`
+		FinancialMetrics modules.HostFinancialMetrics    `json:"financialmetrics"`
+		Folders          []modules.StorageFolderMetadata `json:"folders"`
+		NetworkMetrics   modules.HostNetworkMetrics      `json:"networkmetrics"`
+		Obligations      modules.HostObligationSummary   `json:"obligations"`
+		Reachability     modules.HostReachability        `json:"reachability"`
+		RecentErrors     []modules.HostError             `json:"recenterrors"`
 	}
 
 	// StorageGET contains the information that is returned after a GET request
@@ -63,9 +96,31 @@ func (api *API) hostHandlerGET(w http.ResponseWriter, req *http.Request, _ httpr
 		InternalSettings: is,
 		NetworkMetrics:   nm,
 	}
+	if is.CollateralBudget.Cmp(fm.LockedStorageCollateral) > 0 {
+		hg.RemainingCollateralBudget = is.CollateralBudget.Sub(fm.LockedStorageCollateral)
+	}
 	WriteJSON(w, hg)
 }
 
+// hostSummaryHandlerGET handles GET requests to the /host/summary API
+// endpoint, returning the operational state of the host.
+func (api *API) hostSummaryHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	obligations, err := api.host.ObligationSummary()
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, HostSummaryGET{
+		BlockHeight:      api.cs.Height(),
+		FinancialMetrics: api.host.FinancialMetrics(),
+		Folders:          api.host.StorageFolders(),
+		NetworkMetrics:   api.host.NetworkMetrics(),
+		Obligations:      obligations,
+		Reachability:     api.host.Reachability(),
+		RecentErrors:     api.host.RecentErrors(),
+	})
+}
+
 // hostHandlerPOST handles POST request to the /host API endpoint, which sets
 // the internal settings of the host.
 func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -124,6 +179,62 @@ func (api *API) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _
 	WriteSuccess(w)
 }
 
+// hostAuditHandler handles the API call that checks that the host could
+// build valid storage proofs for a random selection of its sectors.
+func (api *API) hostAuditHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sectors := uint64(defaultAuditSectors)
+	if s := req.FormValue("sectors"); s != "" {
+		_, err := fmt.Sscan(s, &sectors)
+		if err != nil {
+			WriteError(w, Error{"could not parse sectors: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	audit, err := api.host.Audit(sectors)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, audit)
+}
+
+// hostBackupHandler handles the API call that writes a snapshot of the
+// host's metadata to a directory.
+func (api *API) hostBackupHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	destination := req.FormValue("destination")
+	if !filepath.IsAbs(destination) {
+		WriteError(w, Error{"error when calling /host/backup: destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	err := api.host.Backup(destination)
+	if err != nil {
+		WriteError(w, Error{"error after call to /host/backup: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostAlertsHandlerGET handles GET requests to the /host/alerts API endpoint,
+// returning the operational problems of the host.
+func (api *API) hostAlertsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	alerts := api.host.Alerts()
+	if alerts == nil {
+		alerts = []modules.HostAlert{}
+	}
+	WriteJSON(w, HostAlertsGET{Alerts: alerts})
+}
+
+// hostAlertsDismissHandler handles POST requests to the /host/alerts/dismiss
+// API endpoint, removing an alert about a past problem.
+func (api *API) hostAlertsDismissHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	err := api.host.DismissAlert(req.FormValue("id"))
+	if err != nil {
+		WriteError(w, Error{"error after call to /host/alerts/dismiss: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageHandler returns a bunch of information about storage management on
 // the host.
 func (api *API) storageHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -141,12 +252,9 @@ func (api *API) storageFoldersAddHandler(w http.ResponseWriter, req *http.Reques
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
-	err = api.host.AddStorageFolder(folderPath, folderSize)
-	if err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
-		return
-	}
-	WriteSuccess(w)
+	api.runOperation(w, req, "host/storage/folders/add", func(progress modules.ProgressFunc) error {
+		return api.host.AddStorageFolder(folderPath, folderSize, progress)
+	})
 }
 
 // storageFoldersResizeHandler resizes a storage folder in the storage manager.
@@ -170,12 +278,9 @@ func (api *API) storageFoldersResizeHandler(w http.ResponseWriter, req *http.Req
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
-	err = api.host.ResizeStorageFolder(folderIndex, newSize)
-	if err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
-		return
-	}
-	WriteSuccess(w)
+	api.runOperation(w, req, "host/storage/folders/resize", func(progress modules.ProgressFunc) error {
+		return api.host.ResizeStorageFolder(folderIndex, newSize, progress)
+	})
 }
 
 // storageFoldersRemoveHandler removes a storage folder from the storage
@@ -195,12 +300,35 @@ func (api *API) storageFoldersRemoveHandler(w http.ResponseWriter, req *http.Req
 	}
 
 	force := req.FormValue("force") == "true"
-	err = api.host.RemoveStorageFolder(folderIndex, force)
+	api.runOperation(w, req, "host/storage/folders/remove", func(modules.ProgressFunc) error {
+		return api.host.RemoveStorageFolder(folderIndex, force)
+	})
+}
+
+// storageFoldersRelinkHandler points a storage folder that has been moved at
+// its new path.
+func (api *API) storageFoldersRelinkHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+
+	storageFolders := api.host.StorageFolders()
+	folderIndex, err := folderIndex(folderPath, storageFolders)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
-	WriteSuccess(w)
+
+	newPath := req.FormValue("newpath")
+	if newPath == "" {
+		WriteError(w, Error{"newpath parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	api.runOperation(w, req, "host/storage/folders/relink", func(modules.ProgressFunc) error {
+		return api.host.RelinkStorageFolder(folderIndex, newPath)
+	})
 }
 
 // storageSectorsDeleteHandler handles the call to delete a sector from the
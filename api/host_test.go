@@ -113,6 +113,110 @@ func TestStorageHandler(t *testing.T) {
 	}
 }
 
+// TestHostSummaryHandler tests that the host summary aggregates the storage
+// folders, obligations, and reachability of the host.
+func TestHostSummaryHandler(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostSummaryHandler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	if err := st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+
+	var hsg HostSummaryGET
+	if err := st.getAPI("/host/summary", &hsg); err != nil {
+		t.Fatal(err)
+	}
+	if hsg.BlockHeight != st.server.api.cs.Height() {
+		t.Errorf("expected block height %v, got %v", st.server.api.cs.Height(), hsg.BlockHeight)
+	}
+	if len(hsg.Folders) != 1 {
+		t.Fatalf("expected 1 storage folder, got %v", len(hsg.Folders))
+	}
+	if hsg.Obligations.Unresolved != 0 || len(hsg.Obligations.UpcomingProofs) != 0 {
+		t.Error("host should not have any storage obligations:", hsg.Obligations)
+	}
+	if !hsg.Reachability.Announced {
+		t.Error("host should be reported as announced")
+	}
+}
+
+// TestHostAlertsHandler checks that /host/alerts reports a host that accepts
+// contracts without being announced, and that alerts about present problems
+// cannot be dismissed.
+func TestHostAlertsHandler(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostAlertsHandler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var hag HostAlertsGET
+	if err := st.getAPI("/host/alerts", &hag); err != nil {
+		t.Fatal(err)
+	}
+	if len(hag.Alerts) != 0 {
+		t.Fatal("host should start without alerts:", hag.Alerts)
+	}
+
+	if err := st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/host/alerts", &hag); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, alert := range hag.Alerts {
+		found = found || (alert.ID == "unreachable" && alert.Severity == modules.HostAlertWarning)
+	}
+	if !found {
+		t.Fatal("expected an alert for a host that has not been announced:", hag.Alerts)
+	}
+	if err := st.stdPostAPI("/host/alerts/dismiss", url.Values{"id": {"unreachable"}}); err == nil {
+		t.Error("alert about a present problem was dismissed")
+	}
+	if err := st.stdPostAPI("/host/alerts/dismiss", url.Values{"id": {"foo"}}); err == nil {
+		t.Error("unknown alert was dismissed")
+	}
+}
+
+// TestHostAuditHandler checks that /host/audit reports a host without
+// storage obligations as having nothing to audit, and rejects a malformed
+// number of sectors.
+func TestHostAuditHandler(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestHostAuditHandler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var audit modules.HostAudit
+	if err := st.postAPI("/host/audit", url.Values{"sectors": {"5"}}, &audit); err != nil {
+		t.Fatal(err)
+	}
+	if audit.Obligations != 0 || audit.SectorsChecked != 0 || len(audit.Failures) != 0 {
+		t.Error("host without storage obligations has nothing to audit:", audit)
+	}
+	if err := st.stdPostAPI("/host/audit", url.Values{"sectors": {"five"}}); err == nil {
+		t.Error("malformed number of sectors was accepted")
+	}
+}
+
 // TestAddFolderNoPath tests that an API call to add a storage folder fails if
 // no path was provided.
 func TestAddFolderNoPath(t *testing.T) {
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// JobStatusRunning, JobStatusSucceeded, and JobStatusFailed are the
+	// statuses of a job.
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+
+	// maxFinishedJobs is the number of finished jobs that are remembered.
+	// The oldest finished jobs are forgotten first.
+	maxFinishedJobs = 100
+)
+
+type (
+	// A Job is an operation of an API call that runs in the background.
+	// Progress is the fraction of the operation that has completed, as
+	// reported by the operation. EndTime is zero until the job has finished,
+	// Error is set if the job failed, and Result is set if the job succeeded
+	// and its API call returns an object.
+	Job struct {
+		ID        string      `json:"id"`
+		Operation string      `json:"operation"`
+		Status    string      `json:"status"`
+		Progress  float64     `json:"progress"`
+		Error     string      `json:"error,omitempty"`
+		Result    interface{} `json:"result,omitempty"`
+		StartTime time.Time   `json:"starttime"`
+		EndTime   time.Time   `json:"endtime"`
+	}
+
+	// JobStarted is the object returned by API calls that start a job.
+	JobStarted struct {
+		ID string `json:"id"`
+	}
+
+	// JobsGET lists the jobs that are running, and the most recently
+	// finished jobs, from the oldest to the most recent.
+	JobsGET struct {
+		Jobs []Job `json:"jobs"`
+	}
+
+	// jobManager tracks the jobs started through the API.
+	jobManager struct {
+		jobs  map[string]*Job
+		order []string // ids of the jobs, from the oldest to the most recent
+		mu    sync.Mutex
+	}
+)
+
+// newJobManager returns an empty jobManager.
+func newJobManager() *jobManager {
+	return &jobManager{
+		jobs: make(map[string]*Job),
+	}
+}
+
+// start runs an operation in the background, returning the id of its job. The
+// operation reports its progress to the ProgressFunc it is called with.
+func (jm *jobManager) start(operation string, fn func(modules.ProgressFunc) error) string {
+	return jm.startResult(operation, func(progress modules.ProgressFunc) (interface{}, error) {
+		return nil, fn(progress)
+	})
+}
+
+// startResult is like start, but the result of the operation is recorded in
+// its job.
+func (jm *jobManager) startResult(operation string, fn func(modules.ProgressFunc) (interface{}, error)) string {
+	idBytes, err := crypto.RandBytes(8)
+	if err != nil {
+		build.Critical("could not generate a job id:", err)
+	}
+	job := &Job{
+		ID:        hex.EncodeToString(idBytes),
+		Operation: operation,
+		Status:    JobStatusRunning,
+		StartTime: time.Now(),
+	}
+	jm.mu.Lock()
+	jm.jobs[job.ID] = job
+	jm.order = append(jm.order, job.ID)
+	jm.mu.Unlock()
+
+	progress := func(fraction float64) {
+		jm.mu.Lock()
+		if job.Status == JobStatusRunning {
+			job.Progress = fraction
+		}
+		jm.mu.Unlock()
+	}
+	go func() {
+		result, err := fn(progress)
+		jm.mu.Lock()
+		defer jm.mu.Unlock()
+		job.EndTime = time.Now()
+		if err != nil {
+			// The progress of a failed job shows how far it got.
+			job.Status = JobStatusFailed
+			job.Error = err.Error()
+		} else {
+			job.Status = JobStatusSucceeded
+			job.Progress = 1
+			job.Result = result
+		}
+		jm.prune()
+	}()
+	return job.ID
+}
+
+// prune forgets the oldest finished jobs beyond maxFinishedJobs. The caller
+// must hold the lock.
+func (jm *jobManager) prune() {
+	finished := 0
+	for _, id := range jm.order {
+		if jm.jobs[id].Status != JobStatusRunning {
+			finished++
+		}
+	}
+	kept := jm.order[:0]
+	for _, id := range jm.order {
+		if finished > maxFinishedJobs && jm.jobs[id].Status != JobStatusRunning {
+			delete(jm.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	jm.order = kept
+}
+
+// job returns the job with the provided id.
+func (jm *jobManager) job(id string) (Job, bool) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	job, exists := jm.jobs[id]
+	if !exists {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// list returns the jobs that are remembered, from the oldest to the most
+// recent.
+func (jm *jobManager) list() []Job {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	jobs := make([]Job, len(jm.order))
+	for i, id := range jm.order {
+		jobs[i] = *jm.jobs[id]
+	}
+	return jobs
+}
+
+// runOperation runs the operation of an API call. If the call sets the async
+// parameter, the operation is started as a job and the id of the job is
+// returned immediately. Otherwise the call blocks until the operation has
+// finished, and its progress is ignored.
+func (api *API) runOperation(w http.ResponseWriter, req *http.Request, operation string, fn func(modules.ProgressFunc) error) {
+	api.runOperationResult(w, req, operation, func(progress modules.ProgressFunc) (interface{}, error) {
+		return nil, fn(progress)
+	})
+}
+
+// runOperationResult is like runOperation, but the operation returns an
+// object. The object is the response of a blocking call, and the result of
+// the job of an asynchronous call.
+func (api *API) runOperationResult(w http.ResponseWriter, req *http.Request, operation string, fn func(modules.ProgressFunc) (interface{}, error)) {
+	if req.FormValue("async") == "true" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		WriteJSON(w, JobStarted{ID: api.jobs.startResult(operation, fn)})
+		return
+	}
+	result, err := fn(nil)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if result == nil {
+		WriteSuccess(w)
+		return
+	}
+	WriteJSON(w, result)
+}
+
+// jobsHandler handles the API call that lists the jobs.
+func (api *API) jobsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, JobsGET{
+		Jobs: api.jobs.list(),
+	})
+}
+
+// jobsIDHandler handles the API call that reports the status of a job.
+func (api *API) jobsIDHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	job, exists := api.jobs.job(ps.ByName("id"))
+	if !exists {
+		WriteError(w, Error{"no job with that id"}, http.StatusNotFound)
+		return
+	}
+	WriteJSON(w, job)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// waitForJob waits for a job to finish, returning its final state.
+func waitForJob(t *testing.T, jm *jobManager, id string) Job {
+	for i := 0; i < 100; i++ {
+		job, exists := jm.job(id)
+		if !exists {
+			t.Fatal("job does not exist")
+		}
+		if job.Status != JobStatusRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job did not finish")
+	return Job{}
+}
+
+// TestJobManager probes the starting, reporting, and pruning of jobs.
+func TestJobManager(t *testing.T) {
+	jm := newJobManager()
+
+	reported := make(chan struct{})
+	release := make(chan struct{})
+	id := jm.start("foo", func(progress modules.ProgressFunc) error {
+		progress.Report(0.5)
+		close(reported)
+		<-release
+		return nil
+	})
+	<-reported
+	if job, exists := jm.job(id); !exists || job.Status != JobStatusRunning || job.Operation != "foo" || !job.EndTime.IsZero() {
+		t.Fatal("job is not running:", job)
+	} else if job.Progress != 0.5 {
+		t.Fatal("job progress was not reported:", job.Progress)
+	}
+	close(release)
+	if job := waitForJob(t, jm, id); job.Status != JobStatusSucceeded || job.Error != "" || job.EndTime.IsZero() || job.Progress != 1 {
+		t.Fatal("job did not succeed:", job)
+	}
+
+	failed := jm.start("bar", func(progress modules.ProgressFunc) error {
+		progress.Report(0.25)
+		return errors.New("bar failed")
+	})
+	if job := waitForJob(t, jm, failed); job.Status != JobStatusFailed || job.Error != "bar failed" || job.Progress != 0.25 {
+		t.Fatal("job did not fail:", job)
+	}
+	withResult := jm.startResult("qux", func(modules.ProgressFunc) (interface{}, error) {
+		return "qux result", nil
+	})
+	if job := waitForJob(t, jm, withResult); job.Status != JobStatusSucceeded || job.Result != "qux result" {
+		t.Fatal("job result was not recorded:", job)
+	}
+	if _, exists := jm.job("baz"); exists {
+		t.Fatal("unknown job exists")
+	}
+
+	// Only the most recent finished jobs are remembered, while running jobs
+	// are kept regardless of their age.
+	running := make(chan struct{})
+	defer close(running)
+	oldest := jm.start("running", func(modules.ProgressFunc) error {
+		<-running
+		return nil
+	})
+	var last string
+	for i := 0; i < maxFinishedJobs; i++ {
+		last = jm.start("filler", func(modules.ProgressFunc) error { return nil })
+		waitForJob(t, jm, last)
+	}
+	if _, exists := jm.job(id); exists {
+		t.Fatal("oldest finished job was not forgotten")
+	}
+	jobs := jm.list()
+	if len(jobs) != maxFinishedJobs+1 || jobs[0].ID != oldest || jobs[len(jobs)-1].ID != last {
+		t.Fatal("wrong jobs remembered:", len(jobs))
+	}
+}
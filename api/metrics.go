@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// A metric is a counter or gauge in the Prometheus text format.
+	metric struct {
+		name    string
+		kind    string // "counter" or "gauge"
+		help    string
+		samples []metricSample
+	}
+
+	// A metricSample is a value of a metric, along with the label that
+	// distinguishes it from the other values of the metric.
+	metricSample struct {
+		label string
+		value float64
+	}
+
+	// metricSet is the list of metrics reported by /metrics.
+	metricSet []metric
+)
+
+// add adds a metric with a single unlabeled value to the set.
+func (ms *metricSet) add(name, kind, help string, value float64) {
+	*ms = append(*ms, metric{name, kind, help, []metricSample{{value: value}}})
+}
+
+// boolMetric converts a boolean to the value of a gauge.
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// siacoinMetric converts an amount of hastings to siacoins.
+func siacoinMetric(c types.Currency) float64 {
+	sc, _ := new(big.Rat).SetFrac(c.Big(), types.SiacoinPrecision.Big()).Float64()
+	return sc
+}
+
+// write writes the metrics in the Prometheus text format.
+func (ms metricSet) write(w *bytes.Buffer) {
+	for _, m := range ms {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+		for _, s := range m.samples {
+			w.WriteString(m.name)
+			if s.label != "" {
+				w.WriteString("{" + s.label + "}")
+			}
+			w.WriteString(" " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+		}
+	}
+}
+
+// metrics collects the metrics of the modules of the API.
+func (api *API) metrics() metricSet {
+	var ms metricSet
+	if api.cs != nil {
+		ms.add("sia_consensus_height", "gauge", "Height of the current block.", float64(api.cs.Height()))
+		ms.add("sia_consensus_synced", "gauge", "Whether the consensus set is synced with the network.", boolMetric(api.cs.Synced()))
+	}
+	if api.gateway != nil {
+		ms.add("sia_gateway_peers", "gauge", "Number of peers of the gateway.", float64(len(api.gateway.Peers())))
+		sent := metric{"sia_gateway_sent_bytes_total", "counter", "Bytes sent by the gateway for each RPC.", nil}
+		received := metric{"sia_gateway_received_bytes_total", "counter", "Bytes received by the gateway for each RPC.", nil}
+		for _, b := range api.gateway.Bandwidth() {
+			label := "rpc=" + strconv.Quote(b.RPC)
+			sent.samples = append(sent.samples, metricSample{label, float64(b.Sent)})
+			received.samples = append(received.samples, metricSample{label, float64(b.Received)})
+		}
+		ms = append(ms, sent, received)
+	}
+	if api.host != nil {
+		var total, remaining uint64
+		for _, sf := range api.host.StorageFolders() {
+			total += sf.Capacity
+			remaining += sf.CapacityRemaining
+		}
+		ms.add("sia_host_contracts", "gauge", "Number of contracts formed by the host.", float64(api.host.FinancialMetrics().ContractCount))
+		ms.add("sia_host_storage_bytes", "gauge", "Storage capacity of the host.", float64(total))
+		ms.add("sia_host_storage_used_bytes", "gauge", "Storage of the host that holds sectors.", float64(total-remaining))
+	}
+	if api.renter != nil {
+		transfers := api.renter.TransferMetrics()
+		ms.add("sia_renter_contracts", "gauge", "Number of contracts held by the renter.", float64(len(api.renter.Contracts())))
+		ms.add("sia_renter_uploaded_bytes_total", "counter", "Bytes uploaded by the renter to hosts.", float64(transfers.Uploaded))
+		ms.add("sia_renter_downloaded_bytes_total", "counter", "Bytes downloaded by the renter from hosts.", float64(transfers.Downloaded))
+	}
+	if api.wallet != nil {
+		siacoins, siafunds, _ := api.wallet.ConfirmedBalance()
+		sf, _ := new(big.Rat).SetInt(siafunds.Big()).Float64()
+		ms.add("sia_wallet_unlocked", "gauge", "Whether the wallet is unlocked.", boolMetric(api.wallet.Unlocked()))
+		ms.add("sia_wallet_siacoin_balance", "gauge", "Confirmed siacoin balance of the wallet, in siacoins.", siacoinMetric(siacoins))
+		ms.add("sia_wallet_siafund_balance", "gauge", "Confirmed siafund balance of the wallet.", sf)
+	}
+	return ms
+}
+
+// metricsHandler handles the API call that reports the metrics of the modules
+// in the Prometheus text format.
+func (api *API) metricsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var buf bytes.Buffer
+	api.metrics().write(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	buf.WriteTo(w)
+}
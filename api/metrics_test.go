@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestMetricSetWrite checks the Prometheus text format written by a
+// metricSet.
+func TestMetricSetWrite(t *testing.T) {
+	var ms metricSet
+	ms.add("sia_foo", "gauge", "Foo.", 1.5)
+	ms = append(ms, metric{"sia_bar_total", "counter", "Bar.", []metricSample{{`rpc="A"`, 2}, {`rpc="B"`, 3e9}}})
+	var buf bytes.Buffer
+	ms.write(&buf)
+	expected := `# HELP sia_foo Foo.
+# TYPE sia_foo gauge
+sia_foo 1.5
+# HELP sia_bar_total Bar.
+# TYPE sia_bar_total counter
+sia_bar_total{rpc="A"} 2
+sia_bar_total{rpc="B"} 3e+09
+`
+	if buf.String() != expected {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, buf.String())
+	}
+}
+
+// TestIntegrationMetrics probes the /metrics endpoint, which does not require
+// the Sia User-Agent.
+func TestIntegrationMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationMetrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	resp, err := http.Get("http://" + st.server.listener.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status:", resp.Status, string(body))
+	}
+	for _, line := range []string{"sia_consensus_height 4\n", "sia_wallet_unlocked 1\n", "sia_renter_contracts 0\n"} {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("metrics do not contain %q:\n%v", line, string(body))
+		}
+	}
+
+	// Other endpoints still require the User-Agent.
+	resp, err = http.Get("http://" + st.server.listener.Addr().String() + "/consensus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal("expected /consensus to require the User-Agent, got", resp.Status)
+	}
+}
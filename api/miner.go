@@ -1,37 +1,215 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// defaultTemplateTimeout is how long a call to /miner/template waits for
+	// the template to change if no timeout is provided, and
+	// maxTemplateTimeout is the longest that a caller may wait.
+	defaultTemplateTimeout = 30 * time.Second
+	maxTemplateTimeout     = 5 * time.Minute
+)
+
 type (
 	// MinerGET contains the information that is returned after a GET request
 	// to /miner.
 	MinerGET struct {
-		BlocksMined      int  `json:"blocksmined"`
-		CPUHashrate      int  `json:"cpuhashrate"`
-		CPUMining        bool `json:"cpumining"`
-		StaleBlocksMined int  `json:"staleblocksmined"`
+		BlocksMined         int     `json:"blocksmined"`
+		CPUHashrate         int     `json:"cpuhashrate"`
+		CPUMining           bool    `json:"cpumining"`
+		OrphanedBlocksMined int     `json:"orphanedblocksmined"`
+		StaleBlocksMined    int     `json:"staleblocksmined"`
+		StaleRate           float64 `json:"stalerate"`
+	}
+
+	// MinerPayoutsGET contains the addresses that block payouts are split
+	// between. If empty, block payouts go to an address from the wallet.
+	MinerPayoutsGET struct {
+		Splits []modules.MinerPayoutSplit `json:"splits"`
 	}
 )
 
 // minerHandler handles the API call that queries the miner's status.
 func (api *API) minerHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	blocksMined, staleMined := api.miner.BlocksMined()
+	var staleRate float64
+	if blocksMined+staleMined > 0 {
+		staleRate = float64(staleMined) / float64(blocksMined+staleMined)
+	}
 	mg := MinerGET{
-		BlocksMined:      blocksMined,
-		CPUHashrate:      api.miner.CPUHashrate(),
-		CPUMining:        api.miner.CPUMining(),
-		StaleBlocksMined: staleMined,
+		BlocksMined:         blocksMined,
+		CPUHashrate:         api.miner.CPUHashrate(),
+		CPUMining:           api.miner.CPUMining(),
+		OrphanedBlocksMined: api.miner.BlocksOrphaned(),
+		StaleBlocksMined:    staleMined,
+		StaleRate:           staleRate,
 	}
 	WriteJSON(w, mg)
 }
 
+// minerTemplateHandler handles the API call that returns the miner's block
+// template. If the id of a template is provided, the call waits until the
+// template changes or the timeout elapses before returning.
+func (api *API) minerTemplateHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	idStr := req.FormValue("id")
+	if idStr == "" {
+		WriteJSON(w, api.miner.BlockTemplate())
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		WriteError(w, Error{"unable to parse id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	timeout := defaultTemplateTimeout
+	if timeoutStr := req.FormValue("timeout"); timeoutStr != "" {
+		seconds, err := strconv.ParseUint(timeoutStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse timeout: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > maxTemplateTimeout {
+			timeout = maxTemplateTimeout
+		}
+	}
+	bt, err := api.miner.WaitForTemplate(id, timeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, bt)
+}
+
+// minerPayoutsHandlerGET handles the API call that returns the addresses that
+// block payouts are split between.
+func (api *API) minerPayoutsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	splits := api.miner.PayoutSplits()
+	if splits == nil {
+		splits = []modules.MinerPayoutSplit{}
+	}
+	WriteJSON(w, MinerPayoutsGET{Splits: splits})
+}
+
+// minerPayoutsHandlerPOST handles the API call that sets the addresses that
+// block payouts are split between.
+func (api *API) minerPayoutsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var splits []modules.MinerPayoutSplit
+	if req.FormValue("addresses") != "" {
+		addrs := strings.Split(req.FormValue("addresses"), ",")
+		shares := strings.Split(req.FormValue("basispoints"), ",")
+		if len(addrs) != len(shares) {
+			WriteError(w, Error{"the number of addresses and basis points must match"}, http.StatusBadRequest)
+			return
+		}
+		for i := range addrs {
+			addr, err := scanAddress(addrs[i])
+			if err != nil {
+				WriteError(w, Error{"unable to parse address: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			share, err := strconv.ParseUint(shares[i], 10, 64)
+			if err != nil {
+				WriteError(w, Error{"unable to parse basis points: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			splits = append(splits, modules.MinerPayoutSplit{UnlockHash: addr, BasisPoints: share})
+		}
+	}
+	err := api.miner.SetPayoutSplits(splits)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// parseCPUWindows parses a comma-separated list of daily mining windows, each
+// of the form HH:MM-HH:MM.
+func parseCPUWindows(s string) ([]modules.MinerCPUWindow, error) {
+	var windows []modules.MinerCPUWindow
+	for _, ws := range strings.Split(s, ",") {
+		bounds := strings.Split(ws, "-")
+		if len(bounds) != 2 {
+			return nil, errors.New("mining window " + ws + " is not of the form HH:MM-HH:MM")
+		}
+		var w modules.MinerCPUWindow
+		for i, dst := range []*int{&w.Start, &w.End} {
+			t, err := time.Parse("15:04", bounds[i])
+			if err != nil {
+				return nil, errors.New("mining window " + ws + " is not of the form HH:MM-HH:MM")
+			}
+			*dst = t.Hour()*60 + t.Minute()
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// minerCPUHandlerGET handles the API call that returns the thread count, duty
+// cycle, and schedule of the cpu miner.
+func (api *API) minerCPUHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	cs := api.miner.CPUSettings()
+	if cs.Windows == nil {
+		cs.Windows = []modules.MinerCPUWindow{}
+	}
+	WriteJSON(w, cs)
+}
+
+// minerCPUHandlerPOST handles the API call that sets the thread count, duty
+// cycle, and schedule of the cpu miner. Settings that are not provided are
+// left unchanged.
+func (api *API) minerCPUHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	req.ParseForm()
+	cs := api.miner.CPUSettings()
+	if req.FormValue("threads") != "" {
+		threads, err := strconv.Atoi(req.FormValue("threads"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse threads: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		cs.Threads = threads
+	}
+	if req.FormValue("dutycycle") != "" {
+		dutyCycle, err := strconv.Atoi(req.FormValue("dutycycle"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse dutycycle: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		cs.DutyCycle = dutyCycle
+	}
+	// An empty list of windows removes the schedule.
+	if _, ok := req.Form["windows"]; ok {
+		cs.Windows = nil
+		if req.FormValue("windows") != "" {
+			windows, err := parseCPUWindows(req.FormValue("windows"))
+			if err != nil {
+				WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+				return
+			}
+			cs.Windows = windows
+		}
+	}
+	err := api.miner.SetCPUSettings(cs)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // minerStartHandler handles the API call that starts the miner.
 func (api *API) minerStartHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	api.miner.StartCPUMining()
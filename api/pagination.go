@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// parsePagination parses the optional offset and limit parameters of a
+// request. Parameters that are not provided leave their values unchanged.
+func parsePagination(req *http.Request, offset, limit *int) error {
+	params := []struct {
+		name  string
+		value *int
+	}{
+		{"offset", offset},
+		{"limit", limit},
+	}
+	for _, param := range params {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		var v int
+		_, err := fmt.Sscan(req.FormValue(param.name), &v)
+		if err != nil || v < 0 {
+			return errors.New("could not parse " + param.name)
+		}
+		*param.value = v
+	}
+	return nil
+}
+
+// paginate returns the bounds of the page of a collection of n items that
+// starts at offset and holds at most limit items. A negative limit means that
+// the page holds every item after the offset.
+func paginate(n, offset, limit int) (start, end int) {
+	if offset > n {
+		offset = n
+	}
+	end = n
+	if limit >= 0 && offset+limit < n {
+		end = offset + limit
+	}
+	return offset, end
+}
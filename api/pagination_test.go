@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestPaginate probes the bounds returned by paginate.
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		n, offset, limit int
+		start, end       int
+	}{
+		{10, 0, -1, 0, 10},
+		{10, 3, -1, 3, 10},
+		{10, 3, 4, 3, 7},
+		{10, 8, 4, 8, 10},
+		{10, 12, 4, 10, 10},
+		{10, 0, 0, 0, 0},
+		{0, 0, 5, 0, 0},
+	}
+	for _, test := range tests {
+		start, end := paginate(test.n, test.offset, test.limit)
+		if start != test.start || end != test.end {
+			t.Errorf("paginate(%v, %v, %v): expected [%v:%v], got [%v:%v]", test.n, test.offset, test.limit, test.start, test.end, start, end)
+		}
+	}
+}
+
+// TestParsePagination probes the parsing of the offset and limit parameters.
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query         string
+		offset, limit int
+		valid         bool
+	}{
+		{"", 0, -1, true},
+		{"offset=5", 5, -1, true},
+		{"offset=5&limit=10", 5, 10, true},
+		{"limit=0", 0, 0, true},
+		{"limit=-1", 0, -1, false},
+		{"offset=foo", 0, -1, false},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "/renter/files?"+test.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset, limit := 0, -1
+		err = parsePagination(req, &offset, &limit)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: expected valid = %v, got %v", test.query, test.valid, err)
+		} else if offset != test.offset || limit != test.limit {
+			t.Errorf("%q: expected %v, %v, got %v, %v", test.query, test.offset, test.limit, offset, limit)
+		}
+	}
+}
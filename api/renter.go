@@ -1,12 +1,16 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
@@ -38,11 +42,19 @@ type (
 
 	// RenterContract represents a contract formed by the renter.
 	RenterContract struct {
-		EndHeight   types.BlockHeight    `json:"endheight"`
-		ID          types.FileContractID `json:"id"`
-		NetAddress  modules.NetAddress   `json:"netaddress"`
-		RenterFunds types.Currency       `json:"renterfunds"`
-		Size        uint64               `json:"size"`
+		EndHeight    types.BlockHeight     `json:"endheight"`
+		HostFeatures []modules.HostFeature `json:"hostfeatures"`
+		HostVersion  string                `json:"hostversion"`
+		ID           types.FileContractID  `json:"id"`
+		NetAddress   modules.NetAddress    `json:"netaddress"`
+		RenterFunds  types.Currency        `json:"renterfunds"`
+		Size         uint64                `json:"size"`
+
+		DownloadSpending types.Currency       `json:"downloadspending"`
+		StorageSpending  types.Currency       `json:"storagespending"`
+		UploadSpending   types.Currency       `json:"uploadspending"`
+		RenewedFrom      types.FileContractID `json:"renewedfrom"`
+		RenewStatus      string               `json:"renewstatus"`
 	}
 
 	// RenterContracts contains the renter's contracts.
@@ -55,9 +67,20 @@ type (
 		Downloads []modules.DownloadInfo `json:"downloads"`
 	}
 
-	// RenterFiles lists the files known to the renter.
+	// RenterFiles lists a page of the files known to the renter that match
+	// the filters of the request, ordered by siapath. Total is the number of
+	// files that match the filters.
 	RenterFiles struct {
 		Files []modules.FileInfo `json:"files"`
+		Total int                `json:"total"`
+	}
+
+	// RenterHistory lists a page of the transfers between the renter and
+	// its hosts that match the filters of the request, oldest first. Total
+	// is the number of transfers that match the filters.
+	RenterHistory struct {
+		Transfers []modules.RenterTransfer `json:"transfers"`
+		Total     int                      `json:"total"`
 	}
 
 	// RenterLoad lists files that were loaded into the renter.
@@ -75,12 +98,45 @@ type (
 		Hosts []modules.HostDBEntry `json:"hosts"`
 	}
 
-	// AllHosts lists all hosts that the renter is aware of.
+	// AllHosts lists a page of the hosts that the renter is aware of that
+	// match the filters of the request, ordered by net address. Total is the
+	// number of hosts that match the filters.
 	AllHosts struct {
 		Hosts []modules.HostDBEntry `json:"hosts"`
+		Total int                   `json:"total"`
 	}
+
+	// HostSettingsHistory lists the observed changes to a host's prices,
+	// collateral, and capacity, oldest first.
+	HostSettingsHistory struct {
+		History []modules.HostSettingsChange `json:"history"`
+	}
+
+	// filesBySiaPath implements sort.Interface, ordering files by siapath.
+	filesBySiaPath []modules.FileInfo
+
+	// hostsByNetAddress implements sort.Interface, ordering hosts by net
+	// address.
+	hostsByNetAddress []modules.HostDBEntry
 )
 
+func (fs filesBySiaPath) Len() int           { return len(fs) }
+func (fs filesBySiaPath) Swap(i, j int)      { fs[i], fs[j] = fs[j], fs[i] }
+func (fs filesBySiaPath) Less(i, j int) bool { return fs[i].SiaPath < fs[j].SiaPath }
+
+func (hs hostsByNetAddress) Len() int           { return len(hs) }
+func (hs hostsByNetAddress) Swap(i, j int)      { hs[i], hs[j] = hs[j], hs[i] }
+func (hs hostsByNetAddress) Less(i, j int) bool { return hs[i].NetAddress < hs[j].NetAddress }
+
+// fileStatusFilters maps the values of the status parameter of /renter/files
+// to the files they match.
+var fileStatusFilters = map[string]func(modules.FileInfo) bool{
+	"available":   func(fi modules.FileInfo) bool { return fi.Available },
+	"unavailable": func(fi modules.FileInfo) bool { return !fi.Available },
+	"uploading":   func(fi modules.FileInfo) bool { return fi.UploadProgress < 100 },
+	"uploaded":    func(fi modules.FileInfo) bool { return fi.UploadProgress >= 100 },
+}
+
 // renterHandlerGET handles the API call to /renter.
 func (api *API) renterHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	WriteJSON(w, RenterGET{
@@ -109,6 +165,42 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		WriteError(w, Error{"Couldn't parse period: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
+	var contractsPerHost uint64
+	if req.FormValue("contractsperhost") != "" {
+		_, err = fmt.Sscan(req.FormValue("contractsperhost"), &contractsPerHost)
+		if err != nil {
+			WriteError(w, Error{"Couldn't parse contractsperhost: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	var formation modules.ContractFormationPolicy
+	if req.FormValue("formconcurrency") != "" {
+		_, err = fmt.Sscan(req.FormValue("formconcurrency"), &formation.Concurrency)
+		if err != nil {
+			WriteError(w, Error{"Couldn't parse formconcurrency: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	timeouts := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"formdialtimeout", &formation.DialTimeout},
+		{"formsettingstimeout", &formation.SettingsTimeout},
+		{"formnegotiatetimeout", &formation.NegotiateTimeout},
+	}
+	for _, timeout := range timeouts {
+		if req.FormValue(timeout.name) == "" {
+			continue
+		}
+		var seconds uint64
+		_, err = fmt.Sscan(req.FormValue(timeout.name), &seconds)
+		if err != nil {
+			WriteError(w, Error{"Couldn't parse " + timeout.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		*timeout.value = time.Duration(seconds) * time.Second
+	}
 	// var renewWindow types.BlockHeight
 	// _, err = fmt.Sscan(req.FormValue("renewwindow"), &renewWindow)
 	// if err != nil {
@@ -118,8 +210,10 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 
 	err = api.renter.SetSettings(modules.RenterSettings{
 		Allowance: modules.Allowance{
-			Funds:  funds,
-			Period: period,
+			Funds:            funds,
+			Period:           period,
+			ContractsPerHost: contractsPerHost,
+			Formation:        formation,
 
 			// TODO: let user specify these
 			Hosts:       recommendedHosts,
@@ -133,16 +227,41 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 	WriteSuccess(w)
 }
 
+// renterConsistencyHandlerGET handles the API call that checks the renter's file
+// metadata against its contracts.
+func (api *API) renterConsistencyHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, api.renter.CheckMetadata(false))
+}
+
+// renterConsistencyHandlerPOST handles the API call that checks the renter's file
+// metadata against its contracts, repairing the issues that are found.
+func (api *API) renterConsistencyHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, api.renter.CheckMetadata(true))
+}
+
 // renterContractsHandler handles the API call to request the Renter's contracts.
 func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	metrics := make(map[types.FileContractID]modules.RenterContractMetrics)
+	for _, m := range api.renter.ContractMetrics() {
+		metrics[m.ID] = m
+	}
 	contracts := []RenterContract{}
 	for _, c := range api.renter.Contracts() {
+		m := metrics[c.ID]
 		contracts = append(contracts, RenterContract{
-			EndHeight:   c.EndHeight(),
-			ID:          c.ID,
-			NetAddress:  c.NetAddress,
-			RenterFunds: c.RenterFunds(),
-			Size:        modules.SectorSize * uint64(len(c.MerkleRoots)),
+			EndHeight:    c.EndHeight(),
+			HostFeatures: modules.HostFeatures(c.HostVersion),
+			HostVersion:  c.HostVersion,
+			ID:           c.ID,
+			NetAddress:   c.NetAddress,
+			RenterFunds:  c.RenterFunds(),
+			Size:         modules.SectorSize * uint64(len(c.MerkleRoots)),
+
+			DownloadSpending: m.DownloadSpending,
+			StorageSpending:  m.StorageSpending,
+			UploadSpending:   m.UploadSpending,
+			RenewedFrom:      m.RenewedFrom,
+			RenewStatus:      m.RenewStatus,
 		})
 	}
 	WriteJSON(w, RenterContracts{
@@ -157,6 +276,38 @@ func (api *API) renterDownloadsHandler(w http.ResponseWriter, _ *http.Request, _
 	})
 }
 
+// renterHistoryHandler handles the API call to list the renter's transfer
+// history.
+func (api *API) renterHistoryHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	prefix := req.FormValue("prefix")
+	host := modules.NetAddress(req.FormValue("host"))
+	direction := req.FormValue("direction")
+	if direction != "" && direction != modules.TransferUpload && direction != modules.TransferDownload {
+		WriteError(w, Error{"unrecognized transfer direction " + direction}, http.StatusBadRequest)
+		return
+	}
+	offset, limit := 0, -1
+	if err := parsePagination(req, &offset, &limit); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	transfers := []modules.RenterTransfer{}
+	for _, t := range api.renter.TransferHistory() {
+		if !strings.HasPrefix(t.SiaPath, prefix) ||
+			(host != "" && t.Host != host) ||
+			(direction != "" && t.Direction != direction) {
+			continue
+		}
+		transfers = append(transfers, t)
+	}
+	start, end := paginate(len(transfers), offset, limit)
+	WriteJSON(w, RenterHistory{
+		Transfers: transfers[start:end],
+		Total:     len(transfers),
+	})
+}
+
 // renterLoadHandler handles the API call to load a '.sia' file.
 func (api *API) renterLoadHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	source := req.FormValue("source")
@@ -186,6 +337,17 @@ func (api *API) renterLoadAsciiHandler(w http.ResponseWriter, req *http.Request,
 	WriteJSON(w, RenterLoad{FilesAdded: files})
 }
 
+// renterCopyHandler handles the API call to copy a file entry in the renter.
+func (api *API) renterCopyHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	err := api.renter.CopyFile(strings.TrimPrefix(ps.ByName("siapath"), "/"), req.FormValue("newsiapath"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
 // renterRenameHandler handles the API call to rename a file entry in the
 // renter.
 func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -198,13 +360,132 @@ func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps
 	WriteSuccess(w)
 }
 
-// renterFilesHandler handles the API call to list all of the files.
+// renterKeysHandler handles the API call to export the keys of a file,
+// sealed with a password.
+func (api *API) renterKeysHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	fk, err := api.renter.ExportFileKeys(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	sealed, err := modules.SealFileKeys(fk, req.FormValue("password"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, sealed)
+}
+
+// parseFileTags parses a list of tags of the form 'key:value'. If
+// allowKeyOnly is set, tags without a ':' are allowed, and are returned with
+// a value of nil.
+func parseFileTags(tagStrs []string, allowKeyOnly bool) (map[string]*string, error) {
+	tags := make(map[string]*string)
+	for _, tagStr := range tagStrs {
+		i := strings.Index(tagStr, ":")
+		if i == -1 && !allowKeyOnly {
+			return nil, fmt.Errorf("tag %q must be of the form 'key:value'", tagStr)
+		} else if i == -1 {
+			tags[tagStr] = nil
+			continue
+		}
+		value := tagStr[i+1:]
+		tags[tagStr[:i]] = &value
+	}
+	return tags, nil
+}
+
+// tagValues converts parsed file tags into a map of tag values.
+func tagValues(tags map[string]*string) map[string]string {
+	values := make(map[string]string, len(tags))
+	for k, v := range tags {
+		values[k] = *v
+	}
+	return values
+}
+
+// renterFilesHandler handles the API call to list all of the files. If any
+// 'tag' parameters are provided, only files matching all of the tags are
+// returned.
 func (api *API) renterFilesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	req.ParseForm()
+	filter, err := parseFileTags(req.Form["tag"], true)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	prefix := req.FormValue("prefix")
+	status := func(modules.FileInfo) bool { return true }
+	if s := req.FormValue("status"); s != "" {
+		var ok bool
+		status, ok = fileStatusFilters[s]
+		if !ok {
+			WriteError(w, Error{"unrecognized file status " + s}, http.StatusBadRequest)
+			return
+		}
+	}
+	offset, limit := 0, -1
+	if err := parsePagination(req, &offset, &limit); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	files := []modules.FileInfo{}
+	for _, fi := range api.renter.FileList() {
+		matches := strings.HasPrefix(fi.SiaPath, prefix) && status(fi)
+		for k, v := range filter {
+			value, exists := fi.Tags[k]
+			if !exists || (v != nil && *v != value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			files = append(files, fi)
+		}
+	}
+	sort.Sort(filesBySiaPath(files))
+	start, end := paginate(len(files), offset, limit)
 	WriteJSON(w, RenterFiles{
-		Files: api.renter.FileList(),
+		Files: files[start:end],
+		Total: len(files),
 	})
 }
 
+// renterTagsHandler handles the API call to replace the tags of a file.
+func (api *API) renterTagsHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	req.ParseForm()
+	tags, err := parseFileTags(req.Form["tag"], false)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.SetFileTags(strings.TrimPrefix(ps.ByName("siapath"), "/"), tagValues(tags))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// renterRetentionHandler handles the API call to set the time until which a
+// file is kept.
+func (api *API) renterRetentionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var until types.Timestamp
+	if _, err := fmt.Sscan(req.FormValue("until"), &until); err != nil {
+		WriteError(w, Error{"could not read until: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err := api.renter.SetFileRetention(strings.TrimPrefix(ps.ByName("siapath"), "/"), until)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
 // renterDeleteHandler handles the API call to delete a file entry from the
 // renter.
 func (api *API) renterDeleteHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -267,6 +548,66 @@ func (api *API) renterShareAsciiHandler(w http.ResponseWriter, req *http.Request
 	})
 }
 
+// parseUploadParams parses the options of an upload call that are not
+// specific to a single file.
+func parseUploadParams(req *http.Request) (modules.FileUploadParams, error) {
+	minHostVersion := req.FormValue("minhostversion")
+	if minHostVersion != "" && !build.IsVersion(minHostVersion) {
+		return modules.FileUploadParams{}, errors.New("minhostversion must be a valid version")
+	}
+	var regions []string
+	if regionsStr := req.FormValue("regions"); regionsStr != "" {
+		regions = strings.Split(regionsStr, ",")
+		if _, err := modules.ExpandRegions(regions); err != nil {
+			return modules.FileUploadParams{}, err
+		}
+	}
+	tags, err := parseFileTags(req.Form["tag"], false)
+	if err != nil {
+		return modules.FileUploadParams{}, err
+	}
+
+	var encryptionKey crypto.TwofishKey
+	if keyStr := req.FormValue("encryptionkey"); keyStr != "" {
+		encryptionKey, err = scanTwofishKey(keyStr)
+		if err != nil {
+			return modules.FileUploadParams{}, errors.New("could not read encryption key: " + err.Error())
+		}
+	}
+	cipherType := crypto.CipherType(req.FormValue("cipher"))
+	if cipherType != "" && !cipherType.Valid() {
+		return modules.FileUploadParams{}, fmt.Errorf("cipher must be one of %v", crypto.CipherTypes())
+	}
+	var retainUntil types.Timestamp
+	if retainStr := req.FormValue("retainuntil"); retainStr != "" {
+		_, err = fmt.Sscan(retainStr, &retainUntil)
+		if err != nil {
+			return modules.FileUploadParams{}, errors.New("could not read retainuntil: " + err.Error())
+		}
+	}
+	var chunkSize uint64
+	if chunkSizeStr := req.FormValue("chunksize"); chunkSizeStr != "" {
+		_, err = fmt.Sscan(chunkSizeStr, &chunkSize)
+		if err != nil {
+			return modules.FileUploadParams{}, errors.New("could not read chunk size: " + err.Error())
+		}
+	}
+
+	return modules.FileUploadParams{
+		// let the renter decide these values; eventually they will be configurable
+		ErasureCode: nil,
+
+		MinHostVersion: minHostVersion,
+		Regions:        regions,
+		Tags:           tagValues(tags),
+		RetainUntil:    retainUntil,
+		EncryptionKey:  encryptionKey,
+		PreEncrypted:   req.FormValue("preencrypted") == "true",
+		ChunkSize:      chunkSize,
+		Cipher:         cipherType,
+	}, nil
+}
+
 // renterUploadHandler handles the API call to upload a file.
 func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	source := req.FormValue("source")
@@ -274,13 +615,15 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 		WriteError(w, Error{"source must be an absolute path"}, http.StatusBadRequest)
 		return
 	}
+	params, err := parseUploadParams(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	params.Source = source
+	params.SiaPath = strings.TrimPrefix(ps.ByName("siapath"), "/")
 
-	err := api.renter.Upload(modules.FileUploadParams{
-		Source:  source,
-		SiaPath: strings.TrimPrefix(ps.ByName("siapath"), "/"),
-		// let the renter decide these values; eventually they will be configurable
-		ErasureCode: nil,
-	})
+	err = api.renter.Upload(params)
 	if err != nil {
 		WriteError(w, Error{"Upload failed: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -319,7 +662,48 @@ func (api *API) renterHostsActiveHandler(w http.ResponseWriter, req *http.Reques
 
 // renterHostsAllHandler handles the API call asking for the list of all hosts.
 func (api *API) renterHostsAllHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	status := req.FormValue("status")
+	if status != "" && status != "active" && status != "inactive" {
+		WriteError(w, Error{"unrecognized host status " + status}, http.StatusBadRequest)
+		return
+	}
+	offset, limit := 0, -1
+	if err := parsePagination(req, &offset, &limit); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	active := make(map[string]bool)
+	for _, host := range api.renter.ActiveHosts() {
+		active[host.PublicKey.String()] = true
+	}
+	hosts := []modules.HostDBEntry{}
+	for _, host := range api.renter.AllHosts() {
+		if status == "" || active[host.PublicKey.String()] == (status == "active") {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Sort(hostsByNetAddress(hosts))
+	start, end := paginate(len(hosts), offset, limit)
 	WriteJSON(w, AllHosts{
-		Hosts: api.renter.AllHosts(),
+		Hosts: hosts[start:end],
+		Total: len(hosts),
+	})
+}
+
+// renterHostsHistoryHandler handles the API call asking for the settings history
+// of a host.
+func (api *API) renterHostsHistoryHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr := modules.NetAddress(ps.ByName("netaddress"))
+	history, ok := api.renter.HostSettingsHistory(addr)
+	if !ok {
+		WriteError(w, Error{"no host with that net address"}, http.StatusBadRequest)
+		return
+	}
+	if history == nil {
+		history = []modules.HostSettingsChange{}
+	}
+	WriteJSON(w, HostSettingsHistory{
+		History: history,
 	})
 }
@@ -254,6 +254,40 @@ func TestRenterHostsAllHandler(t *testing.T) {
 	}
 }
 
+// TestRenterHostsHistoryHandler checks that the settings of a scanned host
+// appear in its settings history.
+func TestRenterHostsHistoryHandler(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestRenterHostsHistoryHandler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Unknown hosts are rejected.
+	var hh HostSettingsHistory
+	if err = st.getAPI("/hostdb/history/foo.com:1234", &hh); err == nil {
+		t.Fatal("expected an error for an unknown host")
+	}
+
+	// Announce the host. announceHost waits for the host to be scanned.
+	if err = st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	hg := st.host.ExternalSettings()
+	if err = st.getAPI("/hostdb/history/"+string(hg.NetAddress), &hh); err != nil {
+		t.Fatal(err)
+	}
+	if len(hh.History) != 1 {
+		t.Fatalf("expected 1 settings change, got %v", len(hh.History))
+	}
+	if hh.History[0].StoragePrice.Cmp(hg.StoragePrice) != 0 {
+		t.Fatal("settings history has the wrong storage price")
+	}
+}
+
 // TestRenterHandlerContracts checks that contract formation between a host and
 // renter behaves as expected, and that contract spending is the right amount.
 func TestRenterHandlerContracts(t *testing.T) {
@@ -474,8 +508,9 @@ func TestRenterLoadNonexistent(t *testing.T) {
 	}
 }
 
-// TestRenterHandlerRename checks that valid /renter/rename calls are
-// successful, and that invalid  calls fail with the appropriate error.
+// TestRenterHandlerRename checks that valid /renter/rename and /renter/copy
+// calls are successful, and that invalid  calls fail with the appropriate
+// error.
 func TestRenterHandlerRename(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
@@ -554,6 +589,25 @@ func TestRenterHandlerRename(t *testing.T) {
 	if err == nil || err.Error() != renter.ErrUnknownPath.Error() {
 		t.Errorf("expected error to be %v; got %v", renter.ErrUnknownPath, err)
 	}
+
+	// Copy the renamed file, and try copying it to a name that's already
+	// taken.
+	copyValues := url.Values{}
+	copyValues.Set("newsiapath", "copytest1")
+	if err = st.stdPostAPI("/renter/copy/newtest1", copyValues); err != nil {
+		t.Fatal(err)
+	}
+	err = st.stdPostAPI("/renter/copy/newtest1", copyValues)
+	if err == nil || err.Error() != renter.ErrPathOverload.Error() {
+		t.Errorf("expected error to be %v; got %v", renter.ErrPathOverload, err)
+	}
+	var rf RenterFiles
+	if err = st.getAPI("/renter/files", &rf); err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Files) != 3 {
+		t.Fatal("expected 3 files after copying, got", len(rf.Files))
+	}
 }
 
 // TestRenterHandlerDelete checks that deleting a valid file from the renter
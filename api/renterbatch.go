@@ -0,0 +1,212 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// RenterBatch lists the files of a batch upload or download. Failures
+	// lists the files of an upload that could not be queued, and JobID is the
+	// id of the job that runs a download.
+	RenterBatch struct {
+		SiaPaths []string             `json:"siapaths"`
+		Failures []RenterBatchFailure `json:"failures"`
+		JobID    string               `json:"jobid,omitempty"`
+	}
+
+	// RenterBatchFailure is a file of a batch that could not be queued.
+	RenterBatchFailure struct {
+		SiaPath string `json:"siapath"`
+		Error   string `json:"error"`
+	}
+
+	// RenterBatchProgress is the aggregate progress of the uploads or
+	// downloads of a set of files.
+	RenterBatchProgress struct {
+		Files       int     `json:"files"`
+		Completed   int     `json:"completed"`
+		Filesize    uint64  `json:"filesize"`    // bytes
+		Transferred uint64  `json:"transferred"` // bytes
+		Progress    float64 `json:"progress"`    // percent
+	}
+)
+
+// batchSiaPaths returns the siapaths of a batch call: the siapath parameters,
+// followed by the files whose siapaths match the match parameter, if any.
+func (api *API) batchSiaPaths(req *http.Request) ([]string, error) {
+	req.ParseForm()
+	var siapaths []string
+	for _, siapath := range req.Form["siapath"] {
+		siapaths = append(siapaths, strings.TrimPrefix(siapath, "/"))
+	}
+	if match := req.FormValue("match"); match != "" {
+		if _, err := path.Match(match, ""); err != nil {
+			return nil, errors.New("invalid match pattern: " + err.Error())
+		}
+		for _, f := range api.renter.FileList() {
+			if matched, _ := path.Match(match, f.SiaPath); matched {
+				siapaths = append(siapaths, f.SiaPath)
+			}
+		}
+	}
+	if len(siapaths) == 0 {
+		return nil, errors.New("no files were specified or matched")
+	}
+	return siapaths, nil
+}
+
+// renterBatchUploadHandler handles the API call to upload a set of files. The
+// source and siapath parameters are repeated, once for each file.
+func (api *API) renterBatchUploadHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	params, err := parseUploadParams(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	// Files should not share an encryption key.
+	if req.FormValue("encryptionkey") != "" || params.PreEncrypted {
+		WriteError(w, Error{"encryption keys cannot be used in a batch upload"}, http.StatusBadRequest)
+		return
+	}
+	sources, siapaths := req.Form["source"], req.Form["siapath"]
+	if len(sources) == 0 || len(sources) != len(siapaths) {
+		WriteError(w, Error{"each source must have exactly one siapath"}, http.StatusBadRequest)
+		return
+	}
+	for _, source := range sources {
+		if !filepath.IsAbs(source) {
+			WriteError(w, Error{"source must be an absolute path"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	batch := RenterBatch{
+		SiaPaths: []string{},
+		Failures: []RenterBatchFailure{},
+	}
+	for i := range sources {
+		params.Source = sources[i]
+		params.SiaPath = strings.TrimPrefix(siapaths[i], "/")
+		if err := api.renter.Upload(params); err != nil {
+			batch.Failures = append(batch.Failures, RenterBatchFailure{params.SiaPath, err.Error()})
+			continue
+		}
+		batch.SiaPaths = append(batch.SiaPaths, params.SiaPath)
+	}
+	WriteJSON(w, batch)
+}
+
+// renterBatchDownloadHandler handles the API call to download a set of files
+// into a directory. The files are downloaded one at a time by a job, and each
+// file is downloaded to its siapath inside the destination.
+func (api *API) renterBatchDownloadHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	destination := req.FormValue("destination")
+	if !filepath.IsAbs(destination) {
+		WriteError(w, Error{"destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	siapaths, err := api.batchSiaPaths(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	destination = filepath.Clean(destination)
+	dsts := make([]string, len(siapaths))
+	for i, siapath := range siapaths {
+		dsts[i] = filepath.Join(destination, filepath.FromSlash(siapath))
+		if !strings.HasPrefix(dsts[i], destination+string(filepath.Separator)) {
+			WriteError(w, Error{"siapath " + siapath + " is outside of the destination"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	id := api.jobs.start("renter/batch/download", func(progress modules.ProgressFunc) error {
+		var errs []error
+		for i, siapath := range siapaths {
+			err := os.MkdirAll(filepath.Dir(dsts[i]), 0700)
+			if err == nil {
+				err = api.renter.Download(siapath, dsts[i])
+			}
+			if err != nil {
+				errs = append(errs, errors.New(siapath+": "+err.Error()))
+			}
+			progress.Report(float64(i+1) / float64(len(siapaths)))
+		}
+		return build.JoinErrors(errs, "; ")
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	WriteJSON(w, RenterBatch{
+		SiaPaths: siapaths,
+		Failures: []RenterBatchFailure{},
+		JobID:    id,
+	})
+}
+
+// renterBatchProgressHandler handles the API call to report the aggregate
+// progress of the uploads or downloads of a set of files.
+func (api *API) renterBatchProgressHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	siapaths, err := api.batchSiaPaths(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	direction := req.FormValue("direction")
+	if direction != "upload" && direction != "download" {
+		WriteError(w, Error{"direction must be upload or download"}, http.StatusBadRequest)
+		return
+	}
+
+	// Gather the size and progress of each file. The download queue holds
+	// every download since startup, from the most recent to the oldest, so
+	// it is walked backwards to keep the most recent download of each file.
+	type fileProgress struct {
+		filesize    uint64
+		transferred uint64
+	}
+	files := make(map[string]fileProgress)
+	if direction == "upload" {
+		for _, f := range api.renter.FileList() {
+			var transferred uint64
+			if f.UploadProgress > 0 {
+				transferred = uint64(float64(f.Filesize) * f.UploadProgress / 100)
+			}
+			if transferred > f.Filesize {
+				transferred = f.Filesize
+			}
+			files[f.SiaPath] = fileProgress{f.Filesize, transferred}
+		}
+	} else {
+		queue := api.renter.DownloadQueue()
+		for i := len(queue) - 1; i >= 0; i-- {
+			files[queue[i].SiaPath] = fileProgress{queue[i].Filesize, queue[i].Received}
+		}
+	}
+
+	progress := RenterBatchProgress{
+		Files: len(siapaths),
+	}
+	for _, siapath := range siapaths {
+		f, started := files[siapath]
+		progress.Filesize += f.filesize
+		progress.Transferred += f.transferred
+		if started && f.transferred >= f.filesize {
+			progress.Completed++
+		}
+	}
+	progress.Progress = 100
+	if progress.Filesize > 0 {
+		progress.Progress = 100 * float64(progress.Transferred) / float64(progress.Filesize)
+	}
+	WriteJSON(w, progress)
+}
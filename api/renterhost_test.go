@@ -240,6 +240,24 @@ func TestIntegrationUploadDownload(t *testing.T) {
 		t.Fatal("data mismatch when downloading a file")
 	}
 
+	// The download should be recorded in the transfer history.
+	var rh RenterHistory
+	err = st.getAPI("/renter/history?prefix=test&direction=download", &rh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rh.Total == 0 || len(rh.Transfers) != rh.Total {
+		t.Fatal("download was not recorded in the transfer history:", rh)
+	}
+	for _, transfer := range rh.Transfers {
+		if transfer.SiaPath != "test" || transfer.Direction != modules.TransferDownload {
+			t.Fatal("history filters were not applied:", transfer)
+		}
+		if transfer.Error == "" && transfer.Bytes == 0 {
+			t.Fatal("successful download transferred no bytes")
+		}
+	}
+
 	// Wait for upload to complete.
 	for i := 0; i < 200 && (len(rf.Files) != 2 || rf.Files[0].UploadProgress < 10 || rf.Files[1].UploadProgress < 10); i++ {
 		st.getAPI("/renter/files", &rf)
@@ -249,3 +267,183 @@ func TestIntegrationUploadDownload(t *testing.T) {
 		t.Fatal("the uploading is not succeeding for some reason:", rf.Files[0], rf.Files[1])
 	}
 }
+
+// TestIntegrationRenterBatch tests uploading and downloading a set of files
+// with the batch calls, and reporting their aggregate progress.
+func TestIntegrationRenterBatch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationRenterBatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Announce the host, start accepting contracts, and form a contract.
+	if err = st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload two files in a batch. A relative source rejects the batch.
+	uploadValues := url.Values{}
+	var paths []string
+	for _, name := range []string{"a.dat", "b.dat"} {
+		path := filepath.Join(st.dir, name)
+		if err = createRandFile(path, 1024); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+		uploadValues.Add("source", path)
+		uploadValues.Add("siapath", "photos/"+name)
+	}
+	if err = st.stdPostAPI("/renter/batch/upload", url.Values{"source": {"a.dat"}, "siapath": {"a"}}); err == nil {
+		t.Fatal("batch with a relative source was accepted")
+	}
+	var batch RenterBatch
+	if err = st.postAPI("/renter/batch/upload", uploadValues, &batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.SiaPaths) != 2 || len(batch.Failures) != 0 {
+		t.Fatal("files were not queued for upload:", batch)
+	}
+
+	// Only one piece of each file will be uploaded (10% at current
+	// redundancy).
+	var progress RenterBatchProgress
+	for i := 0; i < 200 && progress.Progress < 10; i++ {
+		st.getAPI("/renter/batch/progress?direction=upload&match=photos/*", &progress)
+		time.Sleep(100 * time.Millisecond)
+	}
+	if progress.Files != 2 || progress.Progress < 10 {
+		t.Fatal("the uploading is not succeeding for some reason:", progress)
+	}
+
+	// Download the files that match a pattern.
+	downloadValues := url.Values{}
+	downloadValues.Set("match", "photos/*")
+	downloadValues.Set("destination", filepath.Join(st.dir, "downloads"))
+	if err = st.postAPI("/renter/batch/download", downloadValues, &batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.SiaPaths) != 2 || batch.JobID == "" {
+		t.Fatal("files were not queued for download:", batch)
+	}
+	var job Job
+	for i := 0; i < 200 && job.Status != JobStatusSucceeded; i++ {
+		if err = st.getAPI("/jobs/"+batch.JobID, &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == JobStatusFailed {
+			t.Fatal("batch download failed:", job.Error)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if job.Status != JobStatusSucceeded {
+		t.Fatal("batch download did not finish")
+	}
+	for i, name := range []string{"a.dat", "b.dat"} {
+		orig, err := ioutil.ReadFile(paths[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		download, err := ioutil.ReadFile(filepath.Join(st.dir, "downloads", "photos", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(orig, download) {
+			t.Fatal("data mismatch when downloading", name)
+		}
+	}
+	if err = st.getAPI("/renter/batch/progress?direction=download&match=photos/*", &progress); err != nil {
+		t.Fatal(err)
+	}
+	if progress.Completed != 2 || progress.Progress != 100 {
+		t.Fatal("batch download progress is wrong:", progress)
+	}
+}
+
+// TestIntegrationRenterKeys checks that the keys of an uploaded file can be
+// exported, and opened only with the password they were sealed with.
+func TestIntegrationRenterKeys(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationRenterKeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Announce the host, start accepting contracts, and form a contract.
+	if err = st.announceHost(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.acceptContracts(); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.setHostStorage(); err != nil {
+		t.Fatal(err)
+	}
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a file, and wait for its first piece to be uploaded.
+	path := filepath.Join(st.dir, "test.dat")
+	if err = createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.stdPostAPI("/renter/upload/test", url.Values{"source": {path}}); err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFiles
+	for i := 0; i < 200 && (len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10); i++ {
+		st.getAPI("/renter/files", &rf)
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10 {
+		t.Fatal("the uploading is not succeeding for some reason:", rf.Files)
+	}
+
+	// Keys cannot be exported without a password, or for a missing file.
+	if err = st.stdPostAPI("/renter/keys/test", url.Values{}); err == nil {
+		t.Fatal("keys were exported without a password")
+	}
+	if err = st.stdPostAPI("/renter/keys/missing", url.Values{"password": {"foo"}}); err == nil {
+		t.Fatal("keys were exported for a missing file")
+	}
+
+	var sealed modules.SealedFileKeys
+	if err = st.postAPI("/renter/keys/test", url.Values{"password": {"foo"}}, &sealed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = modules.OpenFileKeys(sealed, "bar"); err != modules.ErrBadFileKeysPassword {
+		t.Fatal("expected ErrBadFileKeysPassword, got", err)
+	}
+	fk, err := modules.OpenFileKeys(sealed, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fk.SiaPath != "test" || fk.Filesize != 1024 || !fk.Encrypted || len(fk.Pieces) == 0 {
+		t.Fatal("unexpected file keys:", fk)
+	}
+	if fk.Pieces[0].Key == "" || fk.Pieces[0].NetAddress == "" {
+		t.Error("piece is missing its key or host:", fk.Pieces[0])
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// statusRecorder is a ResponseWriter that records the status code of the
+// response. It supports hijacking so that WebSocket calls can be logged.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it.
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// Write records an implicit 200 status code if no status code has been
+// written.
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Hijack hijacks the connection of the underlying ResponseWriter.
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection cannot be hijacked")
+	}
+	sr.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+// clientAddr returns the address of the client that made a request. If the
+// request was relayed by a trusted proxy, the client is the last address of
+// the X-Forwarded-For header that is not a trusted proxy. Addresses set by
+// untrusted peers are ignored, since they can be forged.
+func clientAddr(req *http.Request, trustedProxies map[string]bool) string {
+	addr, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		addr = req.RemoteAddr
+	}
+	if !trustedProxies[addr] {
+		return addr
+	}
+	forwarded := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		addr = hop
+		if !trustedProxies[hop] {
+			break
+		}
+	}
+	return addr
+}
+
+// LogRequests is middleware that logs the client, method, path, status code,
+// and duration of every API call. Requests relayed by one of the trusted
+// proxies are logged under the client address of their X-Forwarded-For
+// header. Query strings are not logged, as they may hold secrets.
+func LogRequests(h http.Handler, log *persist.Logger, trustedProxies []string) http.Handler {
+	trusted := make(map[string]bool)
+	for _, proxy := range trustedProxies {
+		trusted[proxy] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(sr, req)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+		log.Printf("%v %v %v %v %v\n", clientAddr(req, trusted), req.Method, req.URL.Path, sr.status, time.Since(start))
+	})
+}
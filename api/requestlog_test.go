@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// TestClientAddr checks that X-Forwarded-For is only trusted when the request
+// is relayed by a trusted proxy.
+func TestClientAddr(t *testing.T) {
+	trusted := map[string]bool{"127.0.0.1": true, "10.0.0.1": true}
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		client     string
+	}{
+		{"127.0.0.1:5000", "", "127.0.0.1"},
+		{"127.0.0.1:5000", "203.0.113.7", "203.0.113.7"},
+		{"127.0.0.1:5000", "198.51.100.1, 203.0.113.7, 10.0.0.1", "203.0.113.7"},
+		{"127.0.0.1:5000", "10.0.0.1", "10.0.0.1"},
+		{"203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+	}
+	for _, test := range tests {
+		req := &http.Request{RemoteAddr: test.remoteAddr, Header: make(http.Header)}
+		if test.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if client := clientAddr(req, trusted); client != test.client {
+			t.Errorf("%v via %q: expected %v, got %v", test.remoteAddr, test.forwarded, test.client, client)
+		}
+	}
+}
+
+// TestLogRequests checks that requests are logged with their client and
+// status code, and without their query string.
+func TestLogRequests(t *testing.T) {
+	var buf bytes.Buffer
+	h := LogRequests(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		WriteError(w, Error{"no"}, http.StatusTeapot)
+	}), persist.NewLogger(&buf), []string{"127.0.0.1"})
+	req, err := http.NewRequest("GET", "/wallet/seeds?dictionary=english", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !strings.Contains(buf.String(), "203.0.113.7 GET /wallet/seeds 418") {
+		t.Fatal("request was not logged:", buf.String())
+	}
+	if strings.Contains(buf.String(), "english") {
+		t.Fatal("query string was logged:", buf.String())
+	}
+}
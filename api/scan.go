@@ -1,6 +1,8 @@
 package api
 
 import (
+	"encoding/hex"
+	"errors"
 	"math/big"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -35,3 +37,16 @@ func scanHash(s string) (h crypto.Hash, err error) {
 	}
 	return h, nil
 }
+
+// scanTwofishKey scans a crypto.TwofishKey from a hex string.
+func scanTwofishKey(s string) (key crypto.TwofishKey, err error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return crypto.TwofishKey{}, err
+	}
+	if len(b) != len(key) {
+		return crypto.TwofishKey{}, errors.New("encryption key has wrong length")
+	}
+	copy(key[:], b)
+	return key, nil
+}
@@ -55,7 +55,7 @@ func NewServer(APIaddr string, requiredUserAgent string, requiredPassword string
 		return nil, err
 	}
 
-	a := New(requiredUserAgent, requiredPassword, cs, e, g, h, m, r, tp, w)
+	a := New(requiredUserAgent, Auth{Password: requiredPassword}, cs, e, g, h, m, r, tp, w)
 	srv := &Server{
 		api: a,
 
@@ -3,17 +3,51 @@ package api
 import (
 	"net/http"
 
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
 )
 
-type TransactionPoolGET struct {
-	Transactions []types.Transaction `json:"transactions"`
-}
+type (
+	TransactionPoolGET struct {
+		Transactions []types.Transaction `json:"transactions"`
+	}
+
+	// TransactionPoolFeeGET contains the fee rates recommended by the
+	// transaction pool.
+	TransactionPoolFeeGET struct {
+		Minimum         types.Currency              `json:"minimum"`
+		Maximum         types.Currency              `json:"maximum"`
+		Recommendations []modules.FeeRecommendation `json:"recommendations"`
+	}
+
+	// TransactionPoolMetricsGET contains the utilization of the transaction
+	// pool and the limits enforced on it.
+	TransactionPoolMetricsGET struct {
+		modules.TransactionPoolMetrics
+	}
+)
 
 // transactionpoolTransactionsHandler handles the API call to get the
 // transaction pool trasactions.
 func (api *API) transactionpoolTransactionsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	WriteJSON(w, TransactionPoolGET{Transactions: api.tpool.TransactionList()})
 }
+
+// transactionpoolFeeHandler handles the API call to get the fee rates
+// recommended by the transaction pool.
+func (api *API) transactionpoolFeeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	min, max := api.tpool.FeeEstimation()
+	WriteJSON(w, TransactionPoolFeeGET{
+		Minimum:         min,
+		Maximum:         max,
+		Recommendations: api.tpool.FeeRecommendations(),
+	})
+}
+
+// transactionpoolMetricsHandler handles the API call to get the utilization
+// of the transaction pool.
+func (api *API) transactionpoolMetricsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, TransactionPoolMetricsGET{api.tpool.Metrics()})
+}
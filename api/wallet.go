@@ -1,6 +1,9 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -23,6 +26,7 @@ type (
 		ConfirmedSiacoinBalance     types.Currency `json:"confirmedsiacoinbalance"`
 		UnconfirmedOutgoingSiacoins types.Currency `json:"unconfirmedoutgoingsiacoins"`
 		UnconfirmedIncomingSiacoins types.Currency `json:"unconfirmedincomingsiacoins"`
+		ReservedSiacoins            types.Currency `json:"reservedsiacoins"`
 
 		SiafundBalance      types.Currency `json:"siafundbalance"`
 		SiacoinClaimBalance types.Currency `json:"siacoinclaimbalance"`
@@ -50,6 +54,28 @@ type (
 	// /wallet/siafunds.
 	WalletSiacoinsPOST struct {
 		TransactionIDs []types.TransactionID `json:"transactionids"`
+		Amount         types.Currency        `json:"amount"`
+	}
+
+	// WalletSiacoinsMaxGET contains the largest amount of siacoins that the
+	// wallet can send in a single transaction set, and the fee paid for
+	// sending it.
+	WalletSiacoinsMaxGET struct {
+		Amount types.Currency `json:"amount"`
+		Fee    types.Currency `json:"fee"`
+	}
+
+	// WalletScheduledGET contains the payments that the wallet will
+	// broadcast once the blockchain reaches their height.
+	WalletScheduledGET struct {
+		Payments []modules.ScheduledPayment `json:"payments"`
+	}
+
+	// WalletSiacoinsSchedulePOST contains the payment created by a POST call
+	// to /wallet/siacoins/schedule.
+	WalletSiacoinsSchedulePOST struct {
+		ID             types.TransactionID   `json:"id"`
+		TransactionIDs []types.TransactionID `json:"transactionids"`
 	}
 
 	// WalletSiafundsPOST contains the transaction sent in the POST call to
@@ -58,6 +84,19 @@ type (
 		TransactionIDs []types.TransactionID `json:"transactionids"`
 	}
 
+	// WalletSweepPOST contains the siacoins and siafunds moved into the
+	// wallet by a call to /wallet/sweep.
+	WalletSweepPOST struct {
+		Coins types.Currency `json:"coins"`
+		Funds types.Currency `json:"funds"`
+	}
+
+	// WalletKeyPairGET contains a standalone address and the secret key that
+	// spends from it.
+	WalletKeyPairGET struct {
+		modules.KeyPair
+	}
+
 	// WalletSeedsGET contains the seeds used by the wallet.
 	WalletSeedsGET struct {
 		PrimarySeed        string   `json:"primaryseed"`
@@ -71,11 +110,14 @@ type (
 		Transaction modules.ProcessedTransaction `json:"transaction"`
 	}
 
-	// WalletTransactionsGET contains the specified set of confirmed and
-	// unconfirmed transactions.
+	// WalletTransactionsGET contains a page of the confirmed transactions
+	// that match the filters of the request, and all of the unconfirmed
+	// transactions. Total is the number of confirmed transactions that match
+	// the filters.
 	WalletTransactionsGET struct {
 		ConfirmedTransactions   []modules.ProcessedTransaction `json:"confirmedtransactions"`
 		UnconfirmedTransactions []modules.ProcessedTransaction `json:"unconfirmedtransactions"`
+		Total                   int                            `json:"total"`
 	}
 
 	// WalletTransactionsGETaddr contains the set of wallet transactions
@@ -85,6 +127,17 @@ type (
 		ConfirmedTransactions   []modules.ProcessedTransaction `json:"confirmedtransactions"`
 		UnconfirmedTransactions []modules.ProcessedTransaction `json:"unconfirmedtransactions"`
 	}
+
+	// WalletAccountsGET contains the accounts of the wallet.
+	WalletAccountsGET struct {
+		Accounts []modules.WalletAccount `json:"accounts"`
+	}
+
+	// WalletAccountTransactionsGET contains the transactions that spend from
+	// or pay to an account.
+	WalletAccountTransactionsGET struct {
+		Transactions []modules.ProcessedTransaction `json:"transactions"`
+	}
 )
 
 // encryptionKeys enumerates the possible encryption keys that can be derived
@@ -102,6 +155,18 @@ func encryptionKeys(seedStr string) (validKeys []crypto.TwofishKey) {
 	return validKeys
 }
 
+// tryEncryptionKeys calls fn with each of the potential encryption keys until
+// one of them is not rejected as a bad key. modules.ErrBadEncryptionKey is
+// returned if every key is rejected.
+func tryEncryptionKeys(potentialKeys []crypto.TwofishKey, fn func(crypto.TwofishKey) error) error {
+	for _, key := range potentialKeys {
+		if err := fn(key); err != modules.ErrBadEncryptionKey {
+			return err
+		}
+	}
+	return modules.ErrBadEncryptionKey
+}
+
 // walletHander handles API calls to /wallet.
 func (api *API) walletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	siacoinBal, siafundBal, siaclaimBal := api.wallet.ConfirmedBalance()
@@ -113,6 +178,7 @@ func (api *API) walletHandler(w http.ResponseWriter, req *http.Request, _ httpro
 		ConfirmedSiacoinBalance:     siacoinBal,
 		UnconfirmedOutgoingSiacoins: siacoinsOut,
 		UnconfirmedIncomingSiacoins: siacoinsIn,
+		ReservedSiacoins:            api.wallet.ReservedSiacoins(),
 
 		SiafundBalance:      siafundBal,
 		SiacoinClaimBalance: siaclaimBal,
@@ -128,18 +194,15 @@ func (api *API) wallet033xHandler(w http.ResponseWriter, req *http.Request, _ ht
 		return
 	}
 	potentialKeys := encryptionKeys(req.FormValue("encryptionpassword"))
-	for _, key := range potentialKeys {
-		err := api.wallet.Load033xWallet(key, source)
-		if err == nil {
-			WriteSuccess(w)
-			return
-		}
+	api.runOperation(w, req, "wallet/033x", func(modules.ProgressFunc) error {
+		err := tryEncryptionKeys(potentialKeys, func(key crypto.TwofishKey) error {
+			return api.wallet.Load033xWallet(key, source)
+		})
 		if err != nil && err != modules.ErrBadEncryptionKey {
-			WriteError(w, Error{"error when calling /wallet/033x: " + err.Error()}, http.StatusBadRequest)
-			return
+			return errors.New("error when calling /wallet/033x: " + err.Error())
 		}
-	}
-	WriteError(w, Error{modules.ErrBadEncryptionKey.Error()}, http.StatusBadRequest)
+		return err
+	})
 }
 
 // walletAddressHandler handles API calls to /wallet/address.
@@ -154,6 +217,83 @@ func (api *API) walletAddressHandler(w http.ResponseWriter, req *http.Request, _
 	})
 }
 
+// walletAccountsHandler handles API calls to /wallet/accounts.
+func (api *API) walletAccountsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	accounts, err := api.wallet.Accounts()
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/accounts: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAccountsGET{
+		Accounts: accounts,
+	})
+}
+
+// walletAccountsCreateHandler handles API calls to /wallet/accounts/create.
+func (api *API) walletAccountsCreateHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	err := api.wallet.CreateAccount(req.FormValue("name"))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/accounts/create: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletAccountsAddressHandler handles API calls to
+// /wallet/accounts/address/:name.
+func (api *API) walletAccountsAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	unlockConditions, err := api.wallet.AccountAddress(ps.ByName("name"))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/accounts/address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAddressGET{
+		Address: unlockConditions.UnlockHash(),
+	})
+}
+
+// walletAccountsSiacoinsHandler handles API calls to
+// /wallet/accounts/siacoins/:name.
+func (api *API) walletAccountsSiacoinsHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	amount, ok := scanAmount(req.FormValue("amount"))
+	if !ok {
+		WriteError(w, Error{"could not read 'amount' from POST call to /wallet/accounts/siacoins"}, http.StatusBadRequest)
+		return
+	}
+	dest, err := scanAddress(req.FormValue("destination"))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/accounts/siacoins: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	txns, err := api.wallet.AccountSendSiacoins(ps.ByName("name"), amount, dest)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/accounts/siacoins: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	var txids []types.TransactionID
+	for _, txn := range txns {
+		txids = append(txids, txn.ID())
+	}
+	WriteJSON(w, WalletSiacoinsPOST{
+		TransactionIDs: txids,
+		Amount:         amount,
+	})
+}
+
+// walletAccountsTransactionsHandler handles API calls to
+// /wallet/accounts/transactions/:name.
+func (api *API) walletAccountsTransactionsHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	txns, err := api.wallet.AccountTransactions(ps.ByName("name"))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/accounts/transactions: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAccountTransactionsGET{
+		Transactions: txns,
+	})
+}
+
 // walletAddressHandler handles API calls to /wallet/addresses.
 func (api *API) walletAddressesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	WriteJSON(w, WalletAddressesGET{
@@ -217,18 +357,15 @@ func (api *API) walletSeedHandler(w http.ResponseWriter, req *http.Request, _ ht
 	}
 
 	potentialKeys := encryptionKeys(req.FormValue("encryptionpassword"))
-	for _, key := range potentialKeys {
-		err := api.wallet.LoadSeed(key, seed)
-		if err == nil {
-			WriteSuccess(w)
-			return
-		}
-		if err != nil && err != modules.ErrBadEncryptionKey {
-			WriteError(w, Error{"error when calling /wallet/seed: " + err.Error()}, http.StatusBadRequest)
-			return
+	api.runOperation(w, req, "wallet/seed", func(progress modules.ProgressFunc) error {
+		err := tryEncryptionKeys(potentialKeys, func(key crypto.TwofishKey) error {
+			return api.wallet.LoadSeed(key, seed, progress)
+		})
+		if err != nil {
+			return errors.New("error when calling /wallet/seed: " + err.Error())
 		}
-	}
-	WriteError(w, Error{"error when calling /wallet/seed: " + modules.ErrBadEncryptionKey.Error()}, http.StatusBadRequest)
+		return nil
+	})
 }
 
 // walletSiagkeyHandler handles API calls to /wallet/siagkey.
@@ -245,18 +382,45 @@ func (api *API) walletSiagkeyHandler(w http.ResponseWriter, req *http.Request, _
 		}
 	}
 
-	for _, key := range potentialKeys {
-		err := api.wallet.LoadSiagKeys(key, keyfiles)
-		if err == nil {
-			WriteSuccess(w)
-			return
-		}
-		if err != nil && err != modules.ErrBadEncryptionKey {
-			WriteError(w, Error{"error when calling /wallet/siagkey: " + err.Error()}, http.StatusBadRequest)
-			return
+	api.runOperation(w, req, "wallet/siagkey", func(modules.ProgressFunc) error {
+		err := tryEncryptionKeys(potentialKeys, func(key crypto.TwofishKey) error {
+			return api.wallet.LoadSiagKeys(key, keyfiles)
+		})
+		if err != nil {
+			return errors.New("error when calling /wallet/siagkey: " + err.Error())
 		}
+		return nil
+	})
+}
+
+// walletKeyPairHandler handles API calls to /wallet/keypair.
+func (api *API) walletKeyPairHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	kp, err := modules.NewKeyPair()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/keypair: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, WalletKeyPairGET{kp})
+}
+
+// walletLooseKeyHandler handles API calls to /wallet/loosekey.
+func (api *API) walletLooseKeyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	sk, err := modules.ParseSecretKey(req.FormValue("secretkey"))
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/loosekey: " + err.Error()}, http.StatusBadRequest)
+		return
 	}
-	WriteError(w, Error{"error when calling /wallet/siagkey: " + modules.ErrBadEncryptionKey.Error()}, http.StatusBadRequest)
+
+	potentialKeys := encryptionKeys(req.FormValue("encryptionpassword"))
+	api.runOperation(w, req, "wallet/loosekey", func(modules.ProgressFunc) error {
+		err := tryEncryptionKeys(potentialKeys, func(key crypto.TwofishKey) error {
+			return api.wallet.LoadLooseKey(key, sk)
+		})
+		if err != nil {
+			return errors.New("error when calling /wallet/loosekey: " + err.Error())
+		}
+		return nil
+	})
 }
 
 // walletLockHanlder handles API calls to /wallet/lock.
@@ -312,18 +476,45 @@ func (api *API) walletSeedsHandler(w http.ResponseWriter, req *http.Request, _ h
 
 // walletSiacoinsHandler handles API calls to /wallet/siacoins.
 func (api *API) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	amount, ok := scanAmount(req.FormValue("amount"))
-	if !ok {
-		WriteError(w, Error{"could not read 'amount' from POST call to /wallet/siacoins"}, http.StatusBadRequest)
+	if req.FormValue("outputs") != "" {
+		api.walletSiacoinsMultiHandler(w, req)
 		return
 	}
+	var sendMax bool
+	if req.FormValue("sendmax") != "" {
+		var err error
+		sendMax, err = strconv.ParseBool(req.FormValue("sendmax"))
+		if err != nil {
+			WriteError(w, Error{"could not read 'sendmax' from POST call to /wallet/siacoins: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	var amount types.Currency
+	if sendMax {
+		if req.FormValue("amount") != "" {
+			WriteError(w, Error{"'amount' cannot be specified together with 'sendmax' in POST call to /wallet/siacoins"}, http.StatusBadRequest)
+			return
+		}
+	} else {
+		var ok bool
+		amount, ok = scanAmount(req.FormValue("amount"))
+		if !ok {
+			WriteError(w, Error{"could not read 'amount' from POST call to /wallet/siacoins"}, http.StatusBadRequest)
+			return
+		}
+	}
 	dest, err := scanAddress(req.FormValue("destination"))
 	if err != nil {
 		WriteError(w, Error{"error after call to /wallet/siacoins: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	txns, err := api.wallet.SendSiacoins(amount, dest)
+	var txns []types.Transaction
+	if sendMax {
+		txns, amount, err = api.wallet.SendMaxSiacoins(dest)
+	} else {
+		txns, err = api.wallet.SendSiacoins(amount, dest)
+	}
 	if err != nil {
 		WriteError(w, Error{"error after call to /wallet/siacoins: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -334,9 +525,114 @@ func (api *API) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request,
 	}
 	WriteJSON(w, WalletSiacoinsPOST{
 		TransactionIDs: txids,
+		Amount:         amount,
 	})
 }
 
+// walletSiacoinsMultiHandler handles API calls to /wallet/siacoins that send
+// to many addresses at once using the 'outputs' parameter.
+func (api *API) walletSiacoinsMultiHandler(w http.ResponseWriter, req *http.Request) {
+	if req.FormValue("amount") != "" || req.FormValue("destination") != "" || req.FormValue("sendmax") != "" {
+		WriteError(w, Error{"'outputs' cannot be specified together with 'amount', 'destination', or 'sendmax' in POST call to /wallet/siacoins"}, http.StatusBadRequest)
+		return
+	}
+	var outputs []types.SiacoinOutput
+	err := json.Unmarshal([]byte(req.FormValue("outputs")), &outputs)
+	if err != nil {
+		WriteError(w, Error{"could not read 'outputs' from POST call to /wallet/siacoins: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	txns, err := api.wallet.SendSiacoinsMulti(outputs)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/siacoins: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	var amount types.Currency
+	for _, sco := range outputs {
+		amount = amount.Add(sco.Value)
+	}
+	var txids []types.TransactionID
+	for _, txn := range txns {
+		txids = append(txids, txn.ID())
+	}
+	WriteJSON(w, WalletSiacoinsPOST{
+		TransactionIDs: txids,
+		Amount:         amount,
+	})
+}
+
+// walletSiacoinsMaxHandler handles API calls to /wallet/siacoins/max.
+func (api *API) walletSiacoinsMaxHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	amount, fee, err := api.wallet.MaxSendableSiacoins()
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/siacoins/max: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletSiacoinsMaxGET{
+		Amount: amount,
+		Fee:    fee,
+	})
+}
+
+// walletSiacoinsScheduleHandler handles API calls to
+// /wallet/siacoins/schedule.
+func (api *API) walletSiacoinsScheduleHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	amount, ok := scanAmount(req.FormValue("amount"))
+	if !ok {
+		WriteError(w, Error{"could not read 'amount' from POST call to /wallet/siacoins/schedule"}, http.StatusBadRequest)
+		return
+	}
+	dest, err := scanAddress(req.FormValue("destination"))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/siacoins/schedule: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var height types.BlockHeight
+	if _, err := fmt.Sscan(req.FormValue("height"), &height); err != nil {
+		WriteError(w, Error{"could not read 'height' from POST call to /wallet/siacoins/schedule: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	sp, err := api.wallet.ScheduleSiacoins(amount, dest, height)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/siacoins/schedule: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	var txids []types.TransactionID
+	for _, txn := range sp.Transactions {
+		txids = append(txids, txn.ID())
+	}
+	WriteJSON(w, WalletSiacoinsSchedulePOST{
+		ID:             sp.ID,
+		TransactionIDs: txids,
+	})
+}
+
+// walletScheduledHandler handles API calls to /wallet/scheduled.
+func (api *API) walletScheduledHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, WalletScheduledGET{
+		Payments: api.wallet.ScheduledPayments(),
+	})
+}
+
+// walletScheduledCancelHandler handles API calls to
+// /wallet/scheduled/cancel/:id.
+func (api *API) walletScheduledCancelHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id types.TransactionID
+	err := id.UnmarshalJSON([]byte("\"" + ps.ByName("id") + "\""))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/scheduled/cancel: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.wallet.CancelScheduledPayment(id)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/scheduled/cancel: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // walletSiafundsHandler handles API calls to /wallet/siafunds.
 func (api *API) walletSiafundsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	amount, ok := scanAmount(req.FormValue("amount"))
@@ -364,6 +660,30 @@ func (api *API) walletSiafundsHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// walletSweepHandler handles API calls to /wallet/sweep.
+func (api *API) walletSweepHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var sks []crypto.SecretKey
+	for _, s := range strings.Split(req.FormValue("secretkeys"), ",") {
+		sk, err := modules.ParseSecretKey(s)
+		if err != nil {
+			WriteError(w, Error{"error when calling /wallet/sweep: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		sks = append(sks, sk)
+	}
+
+	api.runOperationResult(w, req, "wallet/sweep", func(modules.ProgressFunc) (interface{}, error) {
+		coins, funds, err := api.wallet.SweepKeys(sks)
+		if err != nil {
+			return nil, errors.New("error when calling /wallet/sweep: " + err.Error())
+		}
+		return WalletSweepPOST{
+			Coins: coins,
+			Funds: funds,
+		}, nil
+	})
+}
+
 // walletTransactionHandler handles API calls to /wallet/transaction/:id.
 func (api *API) walletTransactionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	// Parse the id from the url.
@@ -403,16 +723,49 @@ func (api *API) walletTransactionsHandler(w http.ResponseWriter, req *http.Reque
 		WriteError(w, Error{"parsing integer value for parameter `endheight` failed: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
-	confirmedTxns, err := api.wallet.Transactions(types.BlockHeight(start), types.BlockHeight(end))
+	// Get the optional confirmation time range.
+	startTime, endTime := types.Timestamp(0), types.Timestamp(1<<64-1)
+	for _, param := range []struct {
+		name  string
+		value *types.Timestamp
+	}{
+		{"starttime", &startTime},
+		{"endtime", &endTime},
+	} {
+		if req.FormValue(param.name) == "" {
+			continue
+		}
+		t, err := strconv.ParseUint(req.FormValue(param.name), 10, 64)
+		if err != nil {
+			WriteError(w, Error{"parsing integer value for parameter `" + param.name + "` failed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		*param.value = types.Timestamp(t)
+	}
+	offset, limit := 0, -1
+	if err := parsePagination(req, &offset, &limit); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	txns, err := api.wallet.Transactions(types.BlockHeight(start), types.BlockHeight(end))
 	if err != nil {
 		WriteError(w, Error{"error after call to /wallet/transactions: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
+	var confirmedTxns []modules.ProcessedTransaction
+	for _, pt := range txns {
+		if pt.ConfirmationTimestamp >= startTime && pt.ConfirmationTimestamp <= endTime {
+			confirmedTxns = append(confirmedTxns, pt)
+		}
+	}
 	unconfirmedTxns := api.wallet.UnconfirmedTransactions()
 
+	pageStart, pageEnd := paginate(len(confirmedTxns), offset, limit)
 	WriteJSON(w, WalletTransactionsGET{
-		ConfirmedTransactions:   confirmedTxns,
+		ConfirmedTransactions:   confirmedTxns[pageStart:pageEnd],
 		UnconfirmedTransactions: unconfirmedTxns,
+		Total:                   len(confirmedTxns),
 	})
 }
 
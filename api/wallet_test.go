@@ -503,3 +503,106 @@ func TestWalletRelativePathErrorSiag(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestWalletKeyPair probes the /wallet/keypair and /wallet/loosekey
+// endpoints.
+func TestWalletKeyPair(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestWalletKeyPair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var wkp WalletKeyPairGET
+	err = st.getAPI("/wallet/keypair", &wkp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := modules.ParseSecretKey(wkp.SecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modules.StandardUnlockConditions(sk.PublicKey()).UnlockHash() != wkp.Address {
+		t.Fatal("secret key does not belong to the address of the key pair")
+	}
+
+	// A malformed secret key is rejected before trying the password.
+	loadValues := url.Values{}
+	loadValues.Set("secretkey", wkp.SecretKey[2:])
+	loadValues.Set("encryptionpassword", "foo")
+	err = st.stdPostAPI("/wallet/loosekey", loadValues)
+	if err == nil || err.Error() != "error when calling /wallet/loosekey: "+modules.ErrBadSecretKey.Error() {
+		t.Fatal(err)
+	}
+
+	// The server tester does not use a string password, so any password is
+	// incorrect.
+	loadValues.Set("secretkey", wkp.SecretKey)
+	err = st.stdPostAPI("/wallet/loosekey", loadValues)
+	if err == nil || err.Error() != "error when calling /wallet/loosekey: provided encryption key is incorrect" {
+		t.Fatal(err)
+	}
+}
+
+// TestIntegrationWalletSiacoinsMax probes the /wallet/siacoins/max endpoint
+// and the 'sendmax' parameter of /wallet/siacoins.
+func TestIntegrationWalletSiacoinsMax(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationWalletSiacoinsMax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var wg WalletGET
+	err = st.getAPI("/wallet", &wg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wsmg WalletSiacoinsMaxGET
+	err = st.getAPI("/wallet/siacoins/max", &wsmg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wsmg.Fee.IsZero() {
+		t.Fatal("no fee was reported")
+	}
+	if wsmg.Amount.Add(wsmg.Fee).Add(wg.ReservedSiacoins).Cmp(wg.ConfirmedSiacoinBalance) != 0 {
+		t.Fatal("maximum amount does not account for the whole unreserved balance:", wsmg.Amount)
+	}
+
+	// 'amount' and 'sendmax' cannot be used together.
+	values := url.Values{}
+	values.Set("amount", "1234")
+	values.Set("sendmax", "true")
+	values.Set("destination", types.UnlockHash{}.String())
+	err = st.stdPostAPI("/wallet/siacoins", values)
+	if err == nil {
+		t.Fatal("expected an error when using both 'amount' and 'sendmax'")
+	}
+
+	// Send the maximum amount.
+	values.Del("amount")
+	var wsp WalletSiacoinsPOST
+	err = st.postAPI("/wallet/siacoins", values, &wsp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wsp.Amount.Cmp(wsmg.Amount) != 0 {
+		t.Fatalf("expected to send %v, sent %v", wsmg.Amount, wsp.Amount)
+	}
+	if len(wsp.TransactionIDs) == 0 {
+		t.Fatal("no transactions were created")
+	}
+
+	// Nothing should be left to send.
+	err = st.getAPI("/wallet/siacoins/max", &wsmg)
+	if err == nil {
+		t.Fatal("expected an error after emptying the wallet")
+	}
+}
@@ -0,0 +1,104 @@
+package crypto
+
+// cipher.go contains the ciphers that can be used to encrypt the pieces of a
+// file. Every cipher is an AEAD with a 12 byte nonce and a 16 byte tag, so the
+// ciphertexts of all ciphers are TwofishOverhead bytes longer than their
+// plaintexts.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// CipherTwofish is Twofish in GCM mode, the cipher used by every renter
+	// before the cipher could be chosen.
+	CipherTwofish CipherType = "twofish-gcm"
+
+	// CipherAES is AES-256 in GCM mode. It is much faster than Twofish on
+	// processors with AES instructions.
+	CipherAES CipherType = "aes-gcm"
+
+	// CipherChaCha20 is ChaCha20-Poly1305. It is fast on processors without
+	// AES instructions.
+	CipherChaCha20 CipherType = "chacha20-poly1305"
+)
+
+var (
+	// ErrUnknownCipher is returned when a cipher type is not recognized.
+	ErrUnknownCipher = errors.New("unknown cipher type")
+)
+
+type (
+	// CipherType names a cipher that can encrypt the pieces of a file.
+	CipherType string
+
+	// A CipherKey encrypts and decrypts byte slices. The nonce of each
+	// ciphertext is prepended to it.
+	CipherKey interface {
+		EncryptBytes([]byte) (Ciphertext, error)
+		DecryptBytes(Ciphertext) ([]byte, error)
+	}
+
+	// aeadKey is a CipherKey that uses an AEAD with random nonces.
+	aeadKey struct {
+		aead cipher.AEAD
+	}
+)
+
+// CipherTypes returns the cipher types that are supported, starting with the
+// default.
+func CipherTypes() []CipherType {
+	return []CipherType{CipherTwofish, CipherAES, CipherChaCha20}
+}
+
+// Valid returns true if the cipher type is supported.
+func (ct CipherType) Valid() bool {
+	for _, t := range CipherTypes() {
+		if ct == t {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCipherKey returns a key for the given cipher type.
+func NewCipherKey(ct CipherType, key [EntropySize]byte) (CipherKey, error) {
+	switch ct {
+	case CipherTwofish:
+		return TwofishKey(key), nil
+	case CipherAES:
+		// NOTE: NewCipher only returns an error if len(key) != 16, 24, or 32.
+		block, _ := aes.NewCipher(key[:])
+		// NOTE: NewGCM only returns an error if block.BlockSize != 16.
+		aead, _ := cipher.NewGCM(block)
+		return aeadKey{aead}, nil
+	case CipherChaCha20:
+		// NOTE: New only returns an error if len(key) != 32.
+		aead, _ := chacha20poly1305.New(key[:])
+		return aeadKey{aead}, nil
+	default:
+		return nil, ErrUnknownCipher
+	}
+}
+
+// EncryptBytes encrypts a []byte using the key, and prepends the nonce to the
+// ciphertext.
+func (key aeadKey) EncryptBytes(plaintext []byte) (Ciphertext, error) {
+	nonce, err := RandBytes(key.aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return key.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptBytes decrypts the ciphertext created by EncryptBytes.
+func (key aeadKey) DecryptBytes(ct Ciphertext) ([]byte, error) {
+	if len(ct) < key.aead.NonceSize() {
+		return nil, ErrInsufficientLen
+	}
+	return key.aead.Open(nil, ct[:key.aead.NonceSize()], ct[key.aead.NonceSize():], nil)
+}
@@ -249,3 +249,73 @@ func TestCipherNewGCMAssumption(t *testing.T) {
 		t.Errorf("cipher must have BlockSize 16, but generated cipher has BlockSize %d\n", block.BlockSize())
 	}
 }
+
+// TestCipherKeys checks that every cipher type can decrypt what it encrypts,
+// adds TwofishOverhead bytes, and cannot decrypt the ciphertexts of the other
+// ciphers.
+func TestCipherKeys(t *testing.T) {
+	var key [EntropySize]byte
+	_, err := rand.Read(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := make([]byte, 600)
+	_, err = rand.Read(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertexts := make(map[CipherType]Ciphertext)
+	for _, ct := range CipherTypes() {
+		if !ct.Valid() {
+			t.Fatal("supported cipher type is not valid:", ct)
+		}
+		ck, err := NewCipherKey(ct, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext, err := ck.EncryptBytes(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ciphertext) != len(plaintext)+TwofishOverhead {
+			t.Errorf("%v added %v bytes, expected %v", ct, len(ciphertext)-len(plaintext), TwofishOverhead)
+		}
+		decrypted, err := ck.DecryptBytes(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Fatalf("%v: encrypted and decrypted plaintext do not match", ct)
+		}
+		if _, err := ck.DecryptBytes(ciphertext[:4]); err != ErrInsufficientLen {
+			t.Errorf("%v: expected ErrInsufficientLen, got %v", ct, err)
+		}
+		ciphertexts[ct] = ciphertext
+	}
+
+	// Twofish keys are unchanged.
+	ck, _ := NewCipherKey(CipherTwofish, key)
+	if _, err := TwofishKey(key).DecryptBytes(ciphertexts[CipherTwofish]); err != nil || ck != CipherKey(TwofishKey(key)) {
+		t.Error("twofish cipher key differs from TwofishKey:", err)
+	}
+
+	for ct, ciphertext := range ciphertexts {
+		for _, other := range CipherTypes() {
+			if other == ct {
+				continue
+			}
+			ck, _ := NewCipherKey(other, key)
+			if _, err := ck.DecryptBytes(ciphertext); err == nil {
+				t.Errorf("%v decrypted a ciphertext of %v", other, ct)
+			}
+		}
+	}
+
+	if _, err := NewCipherKey("rot13", key); err != ErrUnknownCipher {
+		t.Error("expected ErrUnknownCipher, got", err)
+	}
+	if CipherType("").Valid() {
+		t.Error("empty cipher type is valid")
+	}
+}
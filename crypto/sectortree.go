@@ -0,0 +1,151 @@
+package crypto
+
+// sectortree.go contains a Merkle tree over the Merkle roots of sectors that
+// keeps the roots of all of its complete subtrees. Recomputing the Merkle root
+// of a large file contract with a CachedMerkleTree hashes every sector root
+// again; a SectorTree only rehashes the path from the changed sector to the
+// top of the tree.
+
+var (
+	// nodeHashPrefix is prepended to the two children of a node before they
+	// are hashed, matching the interior nodes of merkletree.Tree.
+	nodeHashPrefix = []byte{1}
+)
+
+// A SectorTree is a Merkle tree whose leaves are the Merkle roots of sectors.
+// Its root and proofs are the same as those of a CachedMerkleTree that has had
+// the same sector roots pushed, but appending a sector, replacing a sector,
+// taking the root, and building a proof all take O(log n) time. A SectorTree
+// is not safe for concurrent use.
+type SectorTree struct {
+	// levels[0] holds the sector roots, and levels[i+1][j] is the hash of
+	// levels[i][2j] and levels[i][2j+1]. A level only holds the nodes of
+	// complete subtrees, so level i holds len(levels[0]) >> i nodes.
+	levels [][]Hash
+}
+
+// nodeSum returns the hash of a node with the children a and b.
+func nodeSum(a, b Hash) (h Hash) {
+	hasher := NewHash()
+	hasher.Write(nodeHashPrefix)
+	hasher.Write(a[:])
+	hasher.Write(b[:])
+	copy(h[:], hasher.Sum(nil))
+	return h
+}
+
+// NewSectorTree returns a SectorTree holding the given sector roots.
+func NewSectorTree(roots []Hash) *SectorTree {
+	st := &SectorTree{levels: [][]Hash{make([]Hash, 0, len(roots))}}
+	for _, root := range roots {
+		st.Append(root)
+	}
+	return st
+}
+
+// Len returns the number of sectors in the tree.
+func (st *SectorTree) Len() uint64 {
+	return uint64(len(st.levels[0]))
+}
+
+// Append adds a sector root to the end of the tree.
+func (st *SectorTree) Append(root Hash) {
+	st.levels[0] = append(st.levels[0], root)
+	for i := 0; len(st.levels[i])%2 == 0; i++ {
+		if i+1 == len(st.levels) {
+			st.levels = append(st.levels, nil)
+		}
+		n := len(st.levels[i])
+		st.levels[i+1] = append(st.levels[i+1], nodeSum(st.levels[i][n-2], st.levels[i][n-1]))
+	}
+}
+
+// SetRoot replaces the root of the sector at the given index, which must be
+// less than Len.
+func (st *SectorTree) SetRoot(index uint64, root Hash) {
+	st.levels[0][index] = root
+	for i := 0; i+1 < len(st.levels); i++ {
+		parent := index / 2
+		if parent >= uint64(len(st.levels[i+1])) {
+			break
+		}
+		st.levels[i+1][parent] = nodeSum(st.levels[i][2*parent], st.levels[i][2*parent+1])
+		index = parent
+	}
+}
+
+// Truncate removes every sector at or after index n from the tree. It is used
+// to undo Append.
+func (st *SectorTree) Truncate(n uint64) {
+	if n >= st.Len() {
+		return
+	}
+	for i := range st.levels {
+		st.levels[i] = st.levels[i][:n>>uint(i)]
+	}
+}
+
+// Root returns the Merkle root of the tree, which is the Merkle root of the
+// data of all of its sectors. The root of an empty tree is the zero hash.
+func (st *SectorTree) Root() (root Hash) {
+	// The tree is made of perfect subtrees, one for each level that holds
+	// an odd number of nodes, with the largest subtree on the left. The
+	// subtrees are joined from the smallest to the largest.
+	found := false
+	for i := range st.levels {
+		if len(st.levels[i])%2 == 0 {
+			continue
+		}
+		peak := st.levels[i][len(st.levels[i])-1]
+		if !found {
+			root, found = peak, true
+		} else {
+			root = nodeSum(peak, root)
+		}
+	}
+	return root
+}
+
+// Prove returns the hashes that prove that the sector at the given index,
+// which must be less than Len, is part of the root of the tree. To prove a
+// segment of the sector, they are appended to the proof of the segment within
+// the sector, as returned by MerkleProof.
+func (st *SectorTree) Prove(index uint64) (hashSet []Hash) {
+	// Add the siblings of the sector's ancestors within the perfect subtree
+	// that holds the sector.
+	height := 0
+	for ; height < len(st.levels); height++ {
+		sibling := index ^ 1
+		if sibling >= uint64(len(st.levels[height])) {
+			break
+		}
+		hashSet = append(hashSet, st.levels[height][sibling])
+		index /= 2
+	}
+
+	// Add the root of the smaller subtrees to the right of that subtree,
+	// joined together, followed by the larger subtrees to its left, from the
+	// smallest to the largest.
+	var smaller Hash
+	found := false
+	for i := 0; i < height; i++ {
+		if len(st.levels[i])%2 == 0 {
+			continue
+		}
+		peak := st.levels[i][len(st.levels[i])-1]
+		if !found {
+			smaller, found = peak, true
+		} else {
+			smaller = nodeSum(peak, smaller)
+		}
+	}
+	if found {
+		hashSet = append(hashSet, smaller)
+	}
+	for i := height + 1; i < len(st.levels); i++ {
+		if len(st.levels[i])%2 == 1 {
+			hashSet = append(hashSet, st.levels[i][len(st.levels[i])-1])
+		}
+	}
+	return hashSet
+}
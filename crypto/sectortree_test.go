@@ -0,0 +1,100 @@
+package crypto
+
+import (
+	"testing"
+)
+
+// sectorTreeData returns n random subtrees of 4 segments each, along with
+// their Merkle roots.
+func sectorTreeData(t *testing.T, n int) (data []byte, roots []Hash) {
+	for i := 0; i < n; i++ {
+		b, err := RandBytes(SegmentSize * 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+		roots = append(roots, MerkleRoot(b))
+	}
+	return data, roots
+}
+
+// TestSectorTree checks that the root and proofs of a SectorTree match the
+// Merkle root of the data of its sectors, for every number of sectors up to
+// 17.
+func TestSectorTree(t *testing.T) {
+	if NewSectorTree(nil).Root() != (Hash{}) {
+		t.Fatal("empty tree has a nonzero root")
+	}
+
+	data, roots := sectorTreeData(t, 17)
+	st := NewSectorTree(nil)
+	for n := 1; n <= len(roots); n++ {
+		st.Append(roots[n-1])
+		if st.Len() != uint64(n) {
+			t.Fatalf("tree has %v sectors, expected %v", st.Len(), n)
+		}
+		fullRoot := MerkleRoot(data[:n*SegmentSize*4])
+		if st.Root() != fullRoot {
+			t.Fatalf("root of %v sectors does not match the Merkle root of the data", n)
+		}
+		if NewSectorTree(roots[:n]).Root() != fullRoot {
+			t.Fatalf("root of %v sectors built at once does not match", n)
+		}
+
+		for i := 0; i < n; i++ {
+			segment := uint64(i*4 + (i+n)%4)
+			base, cachedHashSet := MerkleProof(data[i*SegmentSize*4:(i+1)*SegmentSize*4], segment%4)
+			hashSet := append(cachedHashSet, st.Prove(uint64(i))...)
+			if !VerifySegment(base, hashSet, uint64(n*4), segment, fullRoot) {
+				t.Fatalf("proof of sector %v of %v is invalid", i, n)
+			}
+		}
+	}
+}
+
+// TestSectorTreeUpdate checks that SetRoot and Truncate keep the tree
+// consistent with its sector roots.
+func TestSectorTreeUpdate(t *testing.T) {
+	_, roots := sectorTreeData(t, 11)
+	st := NewSectorTree(roots)
+
+	// Replace a sector.
+	_, newRoots := sectorTreeData(t, 1)
+	updated := append([]Hash(nil), roots...)
+	updated[6] = newRoots[0]
+	st.SetRoot(6, newRoots[0])
+	if st.Root() != NewSectorTree(updated).Root() {
+		t.Fatal("root does not match after replacing a sector")
+	}
+	st.SetRoot(6, roots[6])
+	if st.Root() != NewSectorTree(roots).Root() {
+		t.Fatal("root does not match after restoring a sector")
+	}
+
+	// Undo appends.
+	for n := len(roots) - 1; n >= 0; n-- {
+		st.Truncate(uint64(n))
+		if st.Len() != uint64(n) || st.Root() != NewSectorTree(roots[:n]).Root() {
+			t.Fatalf("root does not match after truncating to %v sectors", n)
+		}
+	}
+	for _, root := range roots {
+		st.Append(root)
+	}
+	if st.Root() != NewSectorTree(roots).Root() {
+		t.Fatal("root does not match after appending to a truncated tree")
+	}
+}
+
+// BenchmarkSectorTreeAppend benchmarks appending a sector to a tree of 1e6
+// sectors and taking the new root.
+func BenchmarkSectorTreeAppend(b *testing.B) {
+	roots := make([]Hash, 1e6)
+	st := NewSectorTree(roots)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		st.Append(Hash{})
+		_ = st.Root()
+		st.Truncate(uint64(len(roots)))
+	}
+}
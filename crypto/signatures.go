@@ -3,6 +3,9 @@ package crypto
 import (
 	"errors"
 	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/ed25519"
@@ -21,6 +24,11 @@ const (
 
 	// SignatureSize defines the size of signatures in bytes.
 	SignatureSize = ed25519.SignatureSize
+
+	// minBatchSizePerThread is the smallest number of signatures that
+	// VerifyBatch hands to a single goroutine. Smaller batches are verified
+	// on fewer goroutines, as the overhead would outweigh the speedup.
+	minBatchSizePerThread = 8
 )
 
 var (
@@ -39,6 +47,15 @@ type (
 	// Signature proves that data was signed by the owner of a particular
 	// public key's corresponding secret key.
 	Signature [SignatureSize]byte
+
+	// A SignedHash is a hash along with a signature of the hash and the public
+	// key that the signature is checked against. It is the input to
+	// VerifyBatch.
+	SignedHash struct {
+		Hash      Hash
+		PublicKey PublicKey
+		Signature Signature
+	}
 )
 
 // PublicKey returns the public key that corresponds to a secret key.
@@ -101,6 +118,52 @@ func VerifyHash(data Hash, pk PublicKey, sig Signature) error {
 	return nil
 }
 
+// VerifyBatch verifies a batch of signatures, returning ErrInvalidSignature if
+// any of them do not verify. The signatures are split across all CPUs, which
+// is considerably faster than calling VerifyHash for each signature when the
+// batch is large, such as when validating every transaction in a block.
+func VerifyBatch(batch []SignedHash) error {
+	threads := runtime.NumCPU()
+	if max := len(batch) / minBatchSizePerThread; threads > max {
+		threads = max
+	}
+	if threads <= 1 {
+		for _, sh := range batch {
+			if err := VerifyHash(sh.Hash, sh.PublicKey, sh.Signature); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Each thread verifies a contiguous part of the batch, stopping early if
+	// another thread has found an invalid signature.
+	var failed int32
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		start := len(batch) * i / threads
+		end := len(batch) * (i + 1) / threads
+		wg.Add(1)
+		go func(part []SignedHash) {
+			defer wg.Done()
+			for _, sh := range part {
+				if atomic.LoadInt32(&failed) != 0 {
+					return
+				}
+				if err := VerifyHash(sh.Hash, sh.PublicKey, sh.Signature); err != nil {
+					atomic.StoreInt32(&failed, 1)
+					return
+				}
+			}
+		}(batch[start:end])
+	}
+	wg.Wait()
+	if failed != 0 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
 // WriteSignedObject writes a length-prefixed object prefixed by its signature.
 func WriteSignedObject(w io.Writer, obj interface{}, sk SecretKey) error {
 	objBytes := encoding.Marshal(obj)
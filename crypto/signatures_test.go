@@ -338,3 +338,83 @@ func TestUnitPublicKey(t *testing.T) {
 		}
 	}
 }
+
+// signedHashes returns a batch of n valid signed hashes.
+func signedHashes(n int) ([]SignedHash, error) {
+	batch := make([]SignedHash, n)
+	for i := range batch {
+		sk, pk, err := GenerateKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		rand.Read(batch[i].Hash[:])
+		batch[i].PublicKey = pk
+		batch[i].Signature, err = SignHash(batch[i].Hash, sk)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// TestVerifyBatch checks that VerifyBatch accepts batches of valid signatures
+// and rejects batches containing an invalid signature, for batches that are
+// verified serially and in parallel.
+func TestVerifyBatch(t *testing.T) {
+	if err := VerifyBatch(nil); err != nil {
+		t.Fatal("empty batch should verify:", err)
+	}
+	for _, n := range []int{1, minBatchSizePerThread, 100} {
+		batch, err := signedHashes(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyBatch(batch); err != nil {
+			t.Fatal("valid batch of size", n, "did not verify:", err)
+		}
+
+		// Alter the data of the last signature.
+		batch[n-1].Hash[0]++
+		if err := VerifyBatch(batch); err != ErrInvalidSignature {
+			t.Fatal("expected ErrInvalidSignature for batch of size", n, "got", err)
+		}
+		batch[n-1].Hash[0]--
+
+		// Alter the first signature.
+		batch[0].Signature[0]++
+		if err := VerifyBatch(batch); err != ErrInvalidSignature {
+			t.Fatal("expected ErrInvalidSignature for batch of size", n, "got", err)
+		}
+	}
+}
+
+// BenchmarkVerifyHash1000 benchmarks verifying 1000 signatures one at a time.
+func BenchmarkVerifyHash1000(b *testing.B) {
+	batch, err := signedHashes(1000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, sh := range batch {
+			if err := VerifyHash(sh.Hash, sh.PublicKey, sh.Signature); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkVerifyBatch1000 benchmarks verifying 1000 signatures with
+// VerifyBatch.
+func BenchmarkVerifyBatch1000(b *testing.B) {
+	batch, err := signedHashes(1000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := VerifyBatch(batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -187,22 +187,34 @@ func WriteFile(filename string, v interface{}) error {
 
 // A Decoder reads and decodes values from an input stream.
 type Decoder struct {
-	r io.Reader
-	n int
+	r           io.Reader
+	n           int
+	maxLen      int    // maximum number of bytes read by each call to Decode
+	maxSliceLen uint64 // maximum number of bytes allocated for each slice
 }
 
 // Read implements the io.Reader interface. It also keeps track of the total
-// number of bytes decoded, and panics if that number exceeds a global
+// number of bytes decoded, and panics if that number exceeds the decoder's
 // maximum.
 func (d *Decoder) Read(p []byte) (int, error) {
 	n, err := d.r.Read(p)
 	// enforce an absolute maximum size limit
-	if d.n += n; d.n > maxDecodeLen {
+	if d.n += n; d.n > d.maxLen {
 		panic("encoded type exceeds size limit")
 	}
 	return n, err
 }
 
+// remaining returns the number of bytes that the current call to Decode may
+// still read. Byte slices and strings longer than this would exceed the size
+// limit, so they are rejected before they are allocated.
+func (d *Decoder) remaining() uint64 {
+	if d.n >= d.maxLen {
+		return 0
+	}
+	return uint64(d.maxLen - d.n)
+}
+
 // Decode reads the next encoded value from its input stream and stores it in
 // v, which must be a pointer. The decoding rules are the inverse of those
 // specified in the package docstring.
@@ -250,11 +262,13 @@ func (d *Decoder) readN(n int) []byte {
 
 // readPrefix reads a length-prefixed byte slice and panics if the read fails.
 func (d *Decoder) readPrefix() []byte {
-	b, err := ReadPrefix(d, maxSliceLen)
-	if err != nil {
-		panic(err)
+	dataLen := DecUint64(d.readN(8))
+	if dataLen > d.maxSliceLen {
+		panic(fmt.Sprintf("length %d exceeds maxLen of %d", dataLen, d.maxSliceLen))
+	} else if dataLen > d.remaining() {
+		panic("encoded type exceeds size limit")
 	}
-	return b
+	return d.readN(int(dataLen))
 }
 
 // decode reads the next encoded value from its input stream and stores it in
@@ -303,8 +317,10 @@ func (d *Decoder) decode(val reflect.Value) {
 		sliceLen := DecUint64(d.readN(8))
 		// sanity-check the sliceLen, otherwise you can crash a peer by making
 		// them allocate a massive slice
-		if sliceLen > 1<<31-1 || sliceLen*uint64(val.Type().Elem().Size()) > maxSliceLen {
+		if sliceLen > 1<<31-1 || sliceLen*uint64(val.Type().Elem().Size()) > d.maxSliceLen {
 			panic("slice is too large")
+		} else if val.Type().Elem().Kind() == reflect.Uint8 && sliceLen > d.remaining() {
+			panic("encoded type exceeds size limit")
 		} else if sliceLen == 0 {
 			return
 		}
@@ -338,7 +354,18 @@ func (d *Decoder) decode(val reflect.Value) {
 
 // NewDecoder returns a new decoder that reads from r.
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r, 0}
+	return &Decoder{r, 0, maxDecodeLen, maxSliceLen}
+}
+
+// NewLimitedDecoder returns a new decoder that reads from r. Each call to
+// Decode reads at most maxLen bytes, and allocates at most maxSliceLen bytes
+// for each slice or string that it decodes. The limits of NewDecoder are 12 MB
+// and 5 MB.
+func NewLimitedDecoder(r io.Reader, maxLen, maxSliceLen uint64) *Decoder {
+	if maxLen > 1<<31-1 {
+		maxLen = 1<<31 - 1
+	}
+	return &Decoder{r, 0, int(maxLen), maxSliceLen}
 }
 
 // Unmarshal decodes the encoded value b and stores it in v, which must be a
@@ -3,6 +3,7 @@ package encoding
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 )
 
 // ReadPrefix reads an 8-byte length prefixes, followed by the number of bytes
@@ -32,6 +33,30 @@ func ReadObject(r io.Reader, obj interface{}, maxLen uint64) error {
 	return Unmarshal(data, obj)
 }
 
+// ReadObjectStream reads and decodes a length-prefixed and marshalled object
+// like ReadObject, but decodes the object as it is read instead of reading all
+// of it into memory first, which halves the memory needed to read large
+// objects. The object may be at most maxLen bytes, and each slice or string
+// within it may be at most maxSliceLen bytes. Unlike ReadObject, objects
+// larger than 12 MB can be read if maxLen allows it. Any bytes of the object
+// that are not decoded are discarded, leaving r at the end of the object.
+func ReadObjectStream(r io.Reader, obj interface{}, maxLen, maxSliceLen uint64) error {
+	prefix := make([]byte, 8)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return err
+	}
+	dataLen := DecUint64(prefix)
+	if dataLen > maxLen {
+		return fmt.Errorf("length %d exceeds maxLen of %d", dataLen, maxLen)
+	}
+	lr := &io.LimitedReader{R: r, N: int64(dataLen)}
+	if err := NewLimitedDecoder(lr, dataLen, maxSliceLen).Decode(obj); err != nil {
+		return err
+	}
+	_, err := io.Copy(ioutil.Discard, lr)
+	return err
+}
+
 // WritePrefix writes a length-prefixed byte slice to w.
 func WritePrefix(w io.Writer, data []byte) error {
 	n, err := w.Write(EncUint64(uint64(len(data))))
@@ -94,6 +94,74 @@ func TestReadObject(t *testing.T) {
 	}
 }
 
+// TestReadObjectStream checks that ReadObjectStream decodes objects like
+// ReadObject, enforces its limits, and leaves the reader after the object.
+func TestReadObjectStream(t *testing.T) {
+	b := new(bytes.Buffer)
+	var obj string
+
+	// standard, followed by a second object
+	b.Write(EncUint64(11))
+	b.Write(append(EncUint64(3), "foo"...))
+	b.Write(EncUint64(11))
+	b.Write(append(EncUint64(3), "bar"...))
+	if err := ReadObjectStream(b, &obj, 11, 3); err != nil {
+		t.Fatal(err)
+	} else if obj != "foo" {
+		t.Errorf("expected foo, got %s", obj)
+	}
+	if err := ReadObjectStream(b, &obj, 11, 3); err != nil {
+		t.Fatal(err)
+	} else if obj != "bar" {
+		t.Errorf("expected bar, got %s", obj)
+	}
+
+	// bytes of the object that are not decoded are skipped
+	b.Write(EncUint64(13))
+	b.Write(append(EncUint64(3), "foo!!"...))
+	b.Write(EncUint64(11))
+	b.Write(append(EncUint64(3), "baz"...))
+	if err := ReadObjectStream(b, &obj, 13, 3); err != nil || obj != "foo" {
+		t.Fatal("expected foo, got", obj, err)
+	}
+	if err := ReadObjectStream(b, &obj, 11, 3); err != nil || obj != "baz" {
+		t.Fatal("expected baz, got", obj, err)
+	}
+
+	// object too large
+	b.Write(EncUint64(11))
+	b.Write(append(EncUint64(3), "foo"...))
+	if err := ReadObjectStream(b, &obj, 10, 3); err == nil {
+		t.Error("expected error for object that exceeds maxLen")
+	}
+	b.Reset()
+
+	// string too large
+	b.Write(EncUint64(11))
+	b.Write(append(EncUint64(3), "foo"...))
+	if err := ReadObjectStream(b, &obj, 11, 2); err == nil {
+		t.Error("expected error for string that exceeds maxSliceLen")
+	}
+	b.Reset()
+
+	// a byte slice that claims to be longer than the object is rejected
+	// before it is allocated
+	var data []byte
+	b.Write(EncUint64(11))
+	b.Write(append(EncUint64(1e6), "foo"...))
+	if err := ReadObjectStream(b, &data, 11, 2e6); err == nil {
+		t.Error("expected error for slice that exceeds the object")
+	}
+	b.Reset()
+
+	// truncated object
+	b.Write(EncUint64(11))
+	b.Write(append(EncUint64(3), "fo"...))
+	if err := ReadObjectStream(b, &obj, 11, 3); err == nil {
+		t.Error("expected error for truncated object")
+	}
+}
+
 func TestWritePrefix(t *testing.T) {
 	b := new(bytes.Buffer)
 
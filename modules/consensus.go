@@ -50,6 +50,14 @@ var (
 	// in a fork that is the heaviest known fork - the consensus set has not
 	// changed as a result of seeing the block.
 	ErrNonExtendingBlock = errors.New("block does not extend the longest fork")
+
+	// ErrMissingSiacoinOutput is returned if a transaction spends a siacoin
+	// output that does not exist in the consensus set.
+	ErrMissingSiacoinOutput = errors.New("transaction spends a nonexisting siacoin output")
+
+	// ErrMissingSiafundOutput is returned if a transaction spends a siafund
+	// output that does not exist in the consensus set.
+	ErrMissingSiafundOutput = errors.New("transaction spends a nonexisting siafund output")
 )
 
 type (
@@ -66,10 +74,114 @@ type (
 		// ProcessConsensusChange sends a consensus update to a module through
 		// a function call. Updates will always be sent in the correct order.
 		// There may not be any reverted blocks, but there will always be
-		// applied blocks.
+		// applied blocks. During the initial blockchain download, a single
+		// update may cover many blocks, and the final update of the download
+		// is marked as Synced.
 		ProcessConsensusChange(ConsensusChange)
 	}
 
+	// A ConsistencyReport describes the results of a consistency check of the
+	// consensus database.
+	ConsistencyReport struct {
+		// Errors contains a description of each inconsistency that was found.
+		Errors []string `json:"errors"`
+
+		// Repaired indicates that the inconsistencies were repaired by
+		// re-deriving the consensus state from the blocks in the current path.
+		Repaired bool `json:"repaired"`
+	}
+
+	// A ReorgEvent describes a reorganization of the blockchain, in which one
+	// or more blocks of the current path were reverted in favor of a heavier
+	// fork.
+	ReorgEvent struct {
+		// Index is the position of the event in the sequence of reorgs seen
+		// by the consensus set since startup. The first reorg has index 1.
+		Index uint64 `json:"index"`
+
+		// Depth is the number of blocks that were reverted.
+		Depth types.BlockHeight `json:"depth"`
+
+		// ForkHeight is the height of the last block shared by the old and
+		// the new path, and Height is the height of the new current block.
+		ForkHeight types.BlockHeight `json:"forkheight"`
+		Height     types.BlockHeight `json:"height"`
+
+		// RevertedBlocks are the ids of the reverted blocks, starting with
+		// the old current block. AppliedBlocks are the ids of the applied
+		// blocks, ending with the new current block.
+		RevertedBlocks []types.BlockID `json:"revertedblocks"`
+		AppliedBlocks  []types.BlockID `json:"appliedblocks"`
+
+		// Timestamp is the time at which the reorg was processed.
+		Timestamp types.Timestamp `json:"timestamp"`
+	}
+
+	// A ConsensusCheckpoint commits to the block at a given height, and to
+	// the checksum of the consensus set after that block has been applied.
+	// Checkpoints signed by the checkpoint key are embedded in the binary,
+	// allowing new nodes to download a snapshot of the consensus set at the
+	// checkpoint instead of every block below it.
+	ConsensusCheckpoint struct {
+		Height        types.BlockHeight `json:"height"`
+		BlockID       types.BlockID     `json:"blockid"`
+		ConsensusHash crypto.Hash       `json:"consensushash"`
+		Signature     crypto.Signature  `json:"signature"`
+	}
+
+	// A UTXOSetHeader describes the set of unspent siacoin and siafund
+	// outputs of the consensus set at a block.
+	UTXOSetHeader struct {
+		Height  types.BlockHeight `json:"height"`
+		BlockID types.BlockID     `json:"blockid"`
+
+		// SiacoinOutputs and SiafundOutputs are the number of outputs of each
+		// type in the set.
+		SiacoinOutputs uint64 `json:"siacoinoutputs"`
+		SiafundOutputs uint64 `json:"siafundoutputs"`
+
+		// Hash is the Merkle root of the ids and outputs of the set, with
+		// the siacoin outputs followed by the siafund outputs, each in
+		// ascending order of id. Two consensus sets at the same block have
+		// the same UTXO set iff they have the same hash.
+		Hash crypto.Hash `json:"hash"`
+	}
+
+	// A UTXOSetComparison is the result of comparing an imported UTXO set
+	// against the current UTXO set of the consensus set.
+	UTXOSetComparison struct {
+		// Header is the header of the imported set.
+		Header UTXOSetHeader `json:"header"`
+
+		// Matches is true if the imported set is identical to the current
+		// set.
+		Matches bool `json:"matches"`
+
+		// Differences is the number of outputs that appear in only one of
+		// the two sets, or that differ between them. The ids of a limited
+		// number of those outputs are listed in SiacoinOutputs and
+		// SiafundOutputs.
+		Differences    uint64                  `json:"differences"`
+		SiacoinOutputs []types.SiacoinOutputID `json:"siacoinoutputs"`
+		SiafundOutputs []types.SiafundOutputID `json:"siafundoutputs"`
+	}
+
+	// A TransactionProof proves that a transaction is part of a block. It is
+	// sent by full nodes to light consensus sets, which only know the
+	// headers of the blocks. See types.Block.TransactionProof.
+	TransactionProof struct {
+		BlockID     types.BlockID     `json:"blockid"`
+		Height      types.BlockHeight `json:"height"`
+		Transaction types.Transaction `json:"transaction"`
+		ProofIndex  uint64            `json:"proofindex"`
+		NumLeaves   uint64            `json:"numleaves"`
+		HashSet     []crypto.Hash     `json:"hashset"`
+	}
+
+	// A SnapshotFilter selects the unlock hashes that a snapshot subscriber
+	// is interested in. A nil filter selects every unlock hash.
+	SnapshotFilter func(types.UnlockHash) bool
+
 	// A ConsensusChange enumerates a set of changes that occurred to the consensus set.
 	ConsensusChange struct {
 		// ID is a unique id for the consensus change derived from the reverted
@@ -123,6 +235,16 @@ type (
 		// Synced indicates whether or not the ConsensusSet is synced with its
 		// peers.
 		Synced bool
+
+		// Snapshot indicates that the consensus change is a snapshot sent to a
+		// subscriber that joined using ConsensusSetSnapshotSubscribe. The
+		// diffs of a snapshot are not the diffs of the applied blocks. For a
+		// fresh subscriber they add every object in the consensus set that
+		// matches the subscriber's filter; for a subscriber resuming from an
+		// earlier change they are the net diffs of the applied blocks,
+		// reverting the objects that were spent or revised since the change.
+		// Snapshots do not carry proofs.
+		Snapshot bool
 	}
 
 	// A SiacoinOutputDiff indicates the addition or removal of a SiacoinOutput in
@@ -169,6 +291,14 @@ type (
 		Adjusted  types.Currency
 	}
 
+	// A TransactionSource provides the unconfirmed transactions that a node
+	// has already received. The consensus set uses it to rebuild relayed
+	// blocks without downloading the transactions again.
+	TransactionSource interface {
+		// TransactionList returns the unconfirmed transactions.
+		TransactionList() []types.Transaction
+	}
+
 	// A ConsensusSet accepts blocks and builds an understanding of network
 	// consensus.
 	ConsensusSet interface {
@@ -199,10 +329,41 @@ type (
 		// described by the ConsensusChangeX variables in this package.
 		ConsensusSetSubscribe(ConsensusSetSubscriber, ConsensusChangeID) error
 
+		// ConsensusSetSnapshotSubscribe adds a subscriber to the list of
+		// subscribers. Instead of replaying every consensus change since the
+		// change with the provided id, the subscriber is sent a single
+		// snapshot containing the blocks in the current path that follow the
+		// change, and the net diffs of those blocks for the outputs and file
+		// contracts that match the filter. When starting from
+		// ConsensusChangeBeginning, the diffs add the current outputs and
+		// file contracts instead. ConsensusChangeRecent sends a snapshot
+		// without blocks that adds the current outputs and file contracts.
+		// Consensus changes are sent as usual afterwards.
+		ConsensusSetSnapshotSubscribe(ConsensusSetSubscriber, ConsensusChangeID, SnapshotFilter) error
+
+		// ConsensusSetSnapshotSubscribeHeight is the same as
+		// ConsensusSetSnapshotSubscribe, except that the snapshot contains the
+		// blocks in the current path starting at the provided height.
+		ConsensusSetSnapshotSubscribeHeight(ConsensusSetSubscriber, types.BlockHeight, SnapshotFilter) error
+
+		// ConsistencyCheck walks the consensus database looking for
+		// inconsistencies. If any are found and the input bool is true, the
+		// consensus state is re-derived from the blocks in the current path.
+		ConsistencyCheck(bool) (ConsistencyReport, error)
+
 		// CurrentBlock returns the latest block in the heaviest known
 		// blockchain.
 		CurrentBlock() types.Block
 
+		// CurrentCheckpoint returns an unsigned checkpoint committing to the
+		// current block and the current state of the consensus set.
+		CurrentCheckpoint() (ConsensusCheckpoint, error)
+
+		// ExportUTXOSet writes the current set of unspent siacoin and siafund
+		// outputs to the provided file, returning the header of the set. An
+		// error is returned if the file already exists.
+		ExportUTXOSet(string) (UTXOSetHeader, error)
+
 		// Flush will cause the consensus set to finish all in-progress
 		// routines.
 		Flush() error
@@ -213,6 +374,12 @@ type (
 		// Synced returns true if the consensus set is synced with the network.
 		Synced() bool
 
+		// ImportUTXOSet reads a UTXO set written by ExportUTXOSet, possibly
+		// on another node, from the provided file and compares it against
+		// the current UTXO set. The set must have been exported at the
+		// current block. The consensus set is not modified.
+		ImportUTXOSet(string) (UTXOSetComparison, error)
+
 		// InCurrentPath returns true if the block id presented is found in the
 		// current path, false otherwise.
 		InCurrentPath(types.BlockID) bool
@@ -223,6 +390,17 @@ type (
 		// risk of mining invalid blocks.
 		MinimumValidChildTimestamp(types.BlockID) (types.Timestamp, bool)
 
+		// Reorgs returns the most recent reorgs of the blockchain that have an
+		// index greater than the provided index, oldest first. Only a limited
+		// number of reorgs are remembered, and they are not persisted across
+		// restarts.
+		Reorgs(uint64) []ReorgEvent
+
+		// SetTransactionSource sets the source of the unconfirmed transactions
+		// that relayed blocks are rebuilt from. Blocks are downloaded in full
+		// if the source is nil.
+		SetTransactionSource(TransactionSource)
+
 		// StorageProofSegment returns the segment to be used in the storage proof for
 		// a given file contract.
 		StorageProofSegment(types.FileContractID) (uint64, error)
@@ -233,11 +411,49 @@ type (
 		// transaction.
 		TryTransactionSet([]types.Transaction) (ConsensusChange, error)
 
+		// UTXOSet returns the header of the current set of unspent siacoin
+		// and siafund outputs, including the hash that commits to the set.
+		UTXOSet() (UTXOSetHeader, error)
+
 		// Unsubscribe removes a subscriber from the list of subscribers,
 		// allowing for garbage collection and rescanning. If the subscriber is
 		// not found in the subscriber database, no action is taken.
 		Unsubscribe(ConsensusSetSubscriber)
 	}
+
+	// A LightConsensusSet follows the heaviest blockchain by downloading and
+	// validating only the headers of the blocks, checking that each header
+	// meets its target and has a valid timestamp. It does not know the
+	// contents of the blocks or the set of unspent outputs; instead it
+	// fetches the transactions relevant to a wallet from full nodes, along
+	// with Merkle proofs that the transactions are part of its chain.
+	//
+	// A light consensus set trusts its peers to report every relevant
+	// transaction. A dishonest peer cannot forge a transaction, but it can
+	// omit one.
+	LightConsensusSet interface {
+		// Close shuts down the light consensus set.
+		Close() error
+
+		// CurrentHeader returns the header of the most recent block in the
+		// heaviest known chain, and its height.
+		CurrentHeader() (types.BlockHeader, types.BlockHeight)
+
+		// HeaderAtHeight returns the header of the block at the given height
+		// in the heaviest known chain.
+		HeaderAtHeight(types.BlockHeight) (types.BlockHeader, bool)
+
+		// RelevantTransactions fetches the transactions that spend from or
+		// pay to any of the addresses, in blocks at or above height 'start',
+		// from full nodes. Each transaction is verified against the header
+		// chain before it is returned, and the transactions are returned in
+		// the order in which they appear in the blockchain.
+		RelevantTransactions(addrs []types.UnlockHash, start types.BlockHeight) ([]TransactionProof, error)
+
+		// Synced returns true if the light consensus set has downloaded the
+		// headers of its peers.
+		Synced() bool
+	}
 )
 
 // Append takes to ConsensusChange objects and adds all of their diffs together.
@@ -254,3 +470,8 @@ func (cc ConsensusChange) Append(cc2 ConsensusChange) ConsensusChange {
 		DelayedSiacoinOutputDiffs: append(cc.DelayedSiacoinOutputDiffs, cc2.DelayedSiacoinOutputDiffs...),
 	}
 }
+
+// SigHash returns the hash that is signed by the checkpoint's signature.
+func (cp ConsensusCheckpoint) SigHash() crypto.Hash {
+	return crypto.HashAll(cp.Height, cp.BlockID, cp.ConsensusHash)
+}
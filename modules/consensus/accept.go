@@ -17,6 +17,23 @@ var (
 	errNoBlockMap      = errors.New("block map is not in database")
 	errInconsistentSet = errors.New("consensus set is not in a consistent state")
 	errOrphan          = errors.New("block has no known parent")
+
+	// maxBlocksPerCommit is the maximum number of blocks that are added to
+	// the consensus set in a single database transaction during IBD.
+	// Committing a transaction syncs the database to disk, which dominates
+	// the time needed to add a block on a hard drive.
+	maxBlocksPerCommit = func() int {
+		switch build.Release {
+		case "dev":
+			return 20
+		case "standard":
+			return 50
+		case "testing":
+			return 3
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
 )
 
 // managedBroadcastBlock will broadcast a block to the consensus set's peers.
@@ -155,46 +172,8 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 func (cs *ConsensusSet) addBlockToTree(b types.Block) (ce changeEntry, err error) {
 	var nonExtending bool
 	err = cs.db.Update(func(tx *bolt.Tx) error {
-		pb, err := getBlockMap(tx, b.ParentID)
-		if build.DEBUG && err != nil {
-			panic(err)
-		}
-		currentNode := currentProcessedBlock(tx)
-		newNode := cs.newChild(tx, pb, b)
-
-		// modules.ErrNonExtendingBlock should be returned if the block does
-		// not extend the current blockchain, however the changes from newChild
-		// should be committed (which means 'nil' must be returned). A flag is
-		// set to indicate that modules.ErrNonExtending should be returned.
-		nonExtending = !newNode.heavierThan(currentNode)
-		if nonExtending {
-			return nil
-		}
-		var revertedBlocks, appliedBlocks []*processedBlock
-		revertedBlocks, appliedBlocks, err = cs.forkBlockchain(tx, newNode)
-		if err != nil {
-			return err
-		}
-		for _, rn := range revertedBlocks {
-			ce.RevertedBlocks = append(ce.RevertedBlocks, rn.Block.ID())
-		}
-		for _, an := range appliedBlocks {
-			ce.AppliedBlocks = append(ce.AppliedBlocks, an.Block.ID())
-		}
-		// To have correct error handling, appendChangeLog must be called
-		// before appending to the in-memory changelog. If this call fails, the
-		// change is going to be reverted, but the in-memory changelog is not
-		// going to be reverted.
-		//
-		// Technically, if bolt fails for some other reason (such as a
-		// filesystem error), the in-memory changelog will be incorrect anyway.
-		// Restarting Sia will fix it. The in-memory changelog is being phased
-		// out.
-		err = appendChangeLog(tx, ce)
-		if err != nil {
-			return err
-		}
-		return nil
+		ce, nonExtending, err = cs.addBlockToTreeTx(tx, b)
+		return err
 	})
 	if err != nil {
 		return changeEntry{}, err
@@ -205,6 +184,56 @@ func (cs *ConsensusSet) addBlockToTree(b types.Block) (ce changeEntry, err error
 	return ce, nil
 }
 
+// addBlockToTreeTx inserts a block into the blockNode tree within 'tx'. If the
+// block does not extend the longest fork, nonExtending is set, but the block
+// is still added to the tree and no error is returned, so that the caller can
+// commit the change.
+func (cs *ConsensusSet) addBlockToTreeTx(tx *bolt.Tx, b types.Block) (ce changeEntry, nonExtending bool, err error) {
+	pb, err := getBlockMap(tx, b.ParentID)
+	if build.DEBUG && err != nil {
+		panic(err)
+	}
+	currentNode := currentProcessedBlock(tx)
+	newNode := cs.newChild(tx, pb, b)
+
+	// modules.ErrNonExtendingBlock should be returned if the block does not
+	// extend the current blockchain, however the changes from newChild should
+	// be committed (which means 'nil' must be returned). A flag is set to
+	// indicate that modules.ErrNonExtending should be returned.
+	if !newNode.heavierThan(currentNode) {
+		return changeEntry{}, true, nil
+	}
+	revertedBlocks, appliedBlocks, err := cs.forkBlockchain(tx, newNode)
+	if err != nil {
+		return changeEntry{}, false, err
+	}
+	for _, rn := range revertedBlocks {
+		ce.RevertedBlocks = append(ce.RevertedBlocks, rn.Block.ID())
+	}
+	for _, an := range appliedBlocks {
+		ce.AppliedBlocks = append(ce.AppliedBlocks, an.Block.ID())
+	}
+	// To have correct error handling, appendChangeLog must be called before
+	// appending to the in-memory changelog. If this call fails, the change is
+	// going to be reverted, but the in-memory changelog is not going to be
+	// reverted.
+	//
+	// Technically, if bolt fails for some other reason (such as a filesystem
+	// error), the in-memory changelog will be incorrect anyway. Restarting Sia
+	// will fix it. The in-memory changelog is being phased out.
+	err = appendChangeLog(tx, ce)
+	if err != nil {
+		return changeEntry{}, false, err
+	}
+	// Discard the bodies of any blocks that are now buried deeper than the
+	// prune depth.
+	err = cs.pruneBlocks(tx)
+	if err != nil {
+		return changeEntry{}, false, err
+	}
+	return ce, false, nil
+}
+
 // managedAcceptBlock will try to add a block to the consensus set. If the
 // block does not extend the longest currently known chain, an error is
 // returned but the block is still kept in memory. If the block extends a fork
@@ -272,21 +301,30 @@ func (cs *ConsensusSet) managedAcceptBlock(b types.Block) error {
 	// verification on the block before adding the block to the block tree. An
 	// error is returned if verification fails or if the block does not extend
 	// the longest fork.
-	changeEntry, err := cs.addBlockToTree(b)
+	ce, err := cs.addBlockToTree(b)
 	if err != nil {
 		cs.mu.Unlock()
 		return err
 	}
 	// If appliedBlocks is 0, revertedBlocks will also be 0.
-	if build.DEBUG && len(changeEntry.AppliedBlocks) == 0 && len(changeEntry.RevertedBlocks) != 0 {
+	if build.DEBUG && len(ce.AppliedBlocks) == 0 && len(ce.RevertedBlocks) != 0 {
 		panic("appliedBlocks and revertedBlocks are mismatched!")
 	}
 
+	cs.recordReorg(ce)
+
+	// During IBD, the change is queued so that subscribers receive batches of
+	// changes.
+	var ready []changeEntry
+	if len(ce.AppliedBlocks) > 0 {
+		ready = cs.queueChangeEntry(ce)
+	}
+
 	// Updates complete, demote the lock.
 	cs.mu.Demote()
 	defer cs.mu.DemotedUnlock()
-	if len(changeEntry.AppliedBlocks) > 0 {
-		cs.readlockUpdateSubscribers(changeEntry)
+	if len(ready) > 0 {
+		cs.readlockUpdateSubscribers(ready)
 	}
 	return nil
 }
@@ -312,3 +350,104 @@ func (cs *ConsensusSet) AcceptBlock(b types.Block) error {
 	cs.managedBroadcastBlock(b)
 	return nil
 }
+
+// managedAcceptBlocks adds a sequence of blocks to the consensus set without
+// relaying them, returning true if any of the blocks extended the current
+// path. Blocks that are already known or do not extend the current path are
+// skipped; any other error stops the sequence.
+//
+// Until the consensus set is synced, up to maxBlocksPerCommit blocks are
+// added in a single database transaction. A bolt transaction is atomic, so a
+// crash during a batch discards the whole batch and leaves the database
+// consistent; the blocks are downloaded again after a restart. Consensus
+// changes are only sent to subscribers once the batch is committed. If any
+// block of a batch fails, the batch is rolled back and replayed one block at a
+// time, so that the valid blocks before the failing block are kept.
+func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (chainExtended bool, err error) {
+	for len(blocks) > 0 {
+		cs.mu.RLock()
+		batchSize := maxBlocksPerCommit
+		if cs.synced {
+			batchSize = 1
+		}
+		cs.mu.RUnlock()
+		if batchSize > len(blocks) {
+			batchSize = len(blocks)
+		}
+		batch := blocks[:batchSize]
+		blocks = blocks[batchSize:]
+
+		if batchSize > 1 {
+			extended, err := cs.managedAcceptBatch(batch)
+			chainExtended = chainExtended || extended
+			if err == nil {
+				continue
+			}
+			cs.log.Debugln("Replaying batch of blocks after a failed commit:", err)
+		}
+		for _, b := range batch {
+			acceptErr := cs.managedAcceptBlock(b)
+			if acceptErr == nil {
+				chainExtended = true
+			}
+			if acceptErr == modules.ErrNonExtendingBlock || acceptErr == modules.ErrBlockKnown {
+				acceptErr = nil
+			}
+			if acceptErr != nil {
+				return chainExtended, acceptErr
+			}
+		}
+	}
+	return chainExtended, nil
+}
+
+// managedAcceptBatch adds a batch of blocks to the consensus set in a single
+// database transaction. If any block is invalid, nothing is committed and the
+// error is returned.
+func (cs *ConsensusSet) managedAcceptBatch(batch []types.Block) (chainExtended bool, err error) {
+	cs.mu.Lock()
+	var entries []changeEntry
+	var heights []types.BlockHeight
+	err = cs.db.Update(func(tx *bolt.Tx) error {
+		if inconsistencyDetected(tx) {
+			return errInconsistentSet
+		}
+		for _, b := range batch {
+			err := cs.validateHeaderAndBlock(boltTxWrapper{tx}, b)
+			if err == modules.ErrBlockKnown {
+				continue
+			} else if err != nil {
+				return err
+			}
+			ce, nonExtending, err := cs.addBlockToTreeTx(tx, b)
+			if err != nil {
+				return err
+			}
+			if !nonExtending {
+				entries = append(entries, ce)
+				heights = append(heights, blockHeight(tx))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		cs.mu.Unlock()
+		return false, err
+	}
+
+	var ready []changeEntry
+	for i, ce := range entries {
+		cs.recordReorgAtHeight(ce, heights[i])
+		if len(ce.AppliedBlocks) > 0 {
+			ready = append(ready, cs.queueChangeEntry(ce)...)
+		}
+	}
+
+	// Updates complete, demote the lock.
+	cs.mu.Demote()
+	defer cs.mu.DemotedUnlock()
+	if len(ready) > 0 {
+		cs.readlockUpdateSubscribers(ready)
+	}
+	return len(entries) > 0, nil
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
 )
 
 var (
@@ -1032,3 +1034,78 @@ func TestAcceptBlockBroadcasts(t *testing.T) {
 	case <-time.After(10 * time.Millisecond):
 	}
 }
+
+// TestAcceptBlocksBatched checks that blocks added in batches during IBD
+// reach the same state as blocks added one at a time, and that the valid
+// blocks of a batch are kept when a later block in the batch is invalid.
+func TestAcceptBlocksBatched(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := blankConsensusSetTester("TestAcceptBlocksBatched1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester("TestAcceptBlocksBatched2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Mine blocks on cst1 to be added to cst2 in batches.
+	numBlocks := maxBlocksPerCommit*2 + 1
+	var blocks []types.Block
+	for i := 0; i < numBlocks; i++ {
+		b, err := cst1.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b)
+	}
+	cst2.cs.mu.Lock()
+	cst2.cs.synced = false
+	cst2.cs.mu.Unlock()
+
+	// Insert an invalid block into the second batch. The blocks of the first
+	// batch and the valid blocks of the second batch should be kept.
+	bad := blocks[maxBlocksPerCommit+1]
+	bad.MinerPayouts = append(bad.MinerPayouts, types.SiacoinOutput{Value: types.NewCurrency64(1)})
+	withBad := append(append([]types.Block{}, blocks[:maxBlocksPerCommit+1]...), bad)
+	extended, err := cst2.cs.managedAcceptBlocks(withBad)
+	if err == nil {
+		t.Fatal("expected an error for the invalid block")
+	}
+	if !extended {
+		t.Fatal("expected the chain to be extended")
+	}
+	if cst2.cs.CurrentBlock().ID() != blocks[maxBlocksPerCommit].ID() {
+		t.Fatal("valid blocks before the invalid block were not kept")
+	}
+
+	// Add the remaining blocks, including ones that are already known.
+	extended, err = cst2.cs.managedAcceptBlocks(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extended {
+		t.Fatal("expected the chain to be extended")
+	}
+	if cst2.cs.CurrentBlock().ID() != cst1.cs.CurrentBlock().ID() {
+		t.Fatal("batched blocks did not reach the same current block")
+	}
+	err = cst2.cs.db.View(func(tx *bolt.Tx) error {
+		cst2.cs.checkConsistency(tx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch of known blocks does not extend the chain.
+	extended, err = cst2.cs.managedAcceptBlocks(blocks)
+	if err != nil || extended {
+		t.Fatal("known blocks should be skipped:", extended, err)
+	}
+}
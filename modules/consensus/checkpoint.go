@@ -0,0 +1,516 @@
+package consensus
+
+// checkpoint.go implements checkpoint-based bootstrapping. A checkpoint
+// commits to the id of the block at a fixed height and to the checksum of the
+// consensus set after that block has been applied, and is signed by a key
+// embedded in the binary. Nodes that apply a checkpoint block save a snapshot
+// of the consensus set at that block, which they serve to their peers. A new
+// node can download the snapshot instead of downloading and validating every
+// block up to the checkpoint.
+//
+// The snapshot contains the header of every block in the current path, and
+// every entry of the buckets that hold consensus state. The headers are
+// checked to form a chain ending in the checkpoint block, and the state is
+// checked against the checksum of the checkpoint. The bodies of the blocks
+// below the checkpoint are never downloaded, so a bootstrapped consensus set
+// treats them as pruned: they cannot be sent to peers, and subscribers cannot
+// be caught up on them.
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// maxSnapshotBatchSize is the maximum size of a batch of headers or
+	// entries sent in a snapshot.
+	maxSnapshotBatchSize = 1 << 22
+
+	// snapshotHeaderBatch is the number of headers sent in each batch of a
+	// snapshot.
+	snapshotHeaderBatch = 10e3
+)
+
+var (
+	// checkpointEntropy is the entropy of the key that signs the checkpoints
+	// of dev and testing builds, allowing local networks to produce their own
+	// checkpoints.
+	checkpointEntropy = [crypto.EntropySize]byte{'c', 'h', 'e', 'c', 'k', 'p', 'o', 'i', 'n', 't'}
+
+	// standardCheckpointPublicKey is the key that signs the checkpoints of
+	// the standard release. Checkpoints are produced with 'siac consensus
+	// checkpoint' on a fully synced node, and appended to
+	// embeddedCheckpoints.
+	standardCheckpointPublicKey = crypto.PublicKey{
+		0xb8, 0x7b, 0xa1, 0x04, 0xea, 0x63, 0xc5, 0xea, 0xf6, 0x8b, 0x5a, 0x0b, 0xe1, 0x3a, 0xd3, 0x7f,
+		0x10, 0x6d, 0x00, 0x2c, 0x50, 0x95, 0xef, 0xc0, 0x64, 0x70, 0x3e, 0xa4, 0x87, 0x6a, 0x0e, 0xb3,
+	}
+
+	// checkpointPublicKey is the key that checkpoints must be signed with.
+	checkpointPublicKey = func() crypto.PublicKey {
+		switch build.Release {
+		case "dev", "testing":
+			_, pk := crypto.GenerateKeyPairDeterministic(checkpointEntropy)
+			return pk
+		case "standard":
+			return standardCheckpointPublicKey
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	// standardCheckpoints are the signed checkpoints of the standard release,
+	// in order of increasing height. A checkpoint should be well below the
+	// current height, so that it is not reverted by a reorg.
+	standardCheckpoints = []modules.ConsensusCheckpoint{}
+
+	// embeddedCheckpoints are the checkpoints that a consensus set can
+	// bootstrap from, in order of increasing height. Checkpoints with an
+	// invalid signature are ignored.
+	embeddedCheckpoints = func() []modules.ConsensusCheckpoint {
+		switch build.Release {
+		case "dev", "testing":
+			// Local networks have no fixed blockchain to checkpoint.
+			return nil
+		case "standard":
+			return standardCheckpoints
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	// checkpointBootstrapTimeout is the amount of time that a consensus set
+	// will spend trying to download a snapshot before falling back to
+	// downloading the full blockchain.
+	checkpointBootstrapTimeout = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 2 * time.Minute
+		case "standard":
+			return 20 * time.Minute
+		case "testing":
+			return 5 * time.Second
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	// sendCheckpointTimeout is the timeout for the SendCheckpoint RPC.
+	sendCheckpointTimeout = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 5 * time.Minute
+		case "standard":
+			return 60 * time.Minute
+		case "testing":
+			return 10 * time.Second
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	errNoCheckpoint        = errors.New("no checkpoint is available at the requested height")
+	errSnapshotBucket      = errors.New("snapshot contains an entry for an unknown bucket")
+	errSnapshotChain       = errors.New("snapshot headers do not lead to the checkpoint block")
+	errSnapshotChecksum    = errors.New("snapshot does not match the checksum of the checkpoint")
+	errSnapshotNotFresh    = errors.New("cannot load a snapshot into a consensus set that already has blocks")
+	errSnapshotUnavailable = errors.New("no snapshot is available for the requested checkpoint")
+)
+
+// A snapshotEntry is a key/value pair from one of the consensus state
+// buckets.
+type snapshotEntry struct {
+	Bucket []byte
+	Key    []byte
+	Value  []byte
+}
+
+// verifyCheckpoint checks that the checkpoint has been signed by the
+// checkpoint key.
+func verifyCheckpoint(cp modules.ConsensusCheckpoint) error {
+	return crypto.VerifyHash(cp.SigHash(), checkpointPublicKey, cp.Signature)
+}
+
+// latestCheckpoint returns the highest checkpoint with a valid signature.
+func (cs *ConsensusSet) latestCheckpoint() (modules.ConsensusCheckpoint, bool) {
+	for i := len(cs.checkpoints) - 1; i >= 0; i-- {
+		if verifyCheckpoint(cs.checkpoints[i]) == nil {
+			return cs.checkpoints[i], true
+		}
+	}
+	return modules.ConsensusCheckpoint{}, false
+}
+
+// checkpointAt returns the checkpoint at the given height.
+func (cs *ConsensusSet) checkpointAt(height types.BlockHeight) (modules.ConsensusCheckpoint, bool) {
+	for _, cp := range cs.checkpoints {
+		if cp.Height == height {
+			return cp, true
+		}
+	}
+	return modules.ConsensusCheckpoint{}, false
+}
+
+// CurrentCheckpoint returns an unsigned checkpoint committing to the current
+// block and the current state of the consensus set. Signing it with the
+// checkpoint key produces a checkpoint that can be embedded in the binary.
+func (cs *ConsensusSet) CurrentCheckpoint() (cp modules.ConsensusCheckpoint, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return modules.ConsensusCheckpoint{}, err
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		cp.Height = blockHeight(tx)
+		cp.BlockID = currentBlockID(tx)
+		cp.ConsensusHash = consensusChecksum(tx)
+		return nil
+	})
+	return cp, err
+}
+
+// snapshotFilename returns the name of the file holding the snapshot of the
+// consensus set at the given height.
+func (cs *ConsensusSet) snapshotFilename(height types.BlockHeight) string {
+	return filepath.Join(cs.persistDir, fmt.Sprintf("checkpoint-%d.snapshot", height))
+}
+
+// isStateBucket returns true if the bucket with the given name holds
+// consensus state that is included in a snapshot.
+func isStateBucket(name []byte) bool {
+	for _, bucket := range [][]byte{SiacoinOutputs, FileContracts, SiafundOutputs, SiafundPool} {
+		if bytes.Equal(name, bucket) {
+			return true
+		}
+	}
+	return bytes.HasPrefix(name, prefixDSCO) || bytes.HasPrefix(name, prefixFCEX)
+}
+
+// writeSnapshot writes the headers of the current path and the contents of
+// the consensus state buckets to w. Both are written in batches, each
+// followed by a bool indicating whether more batches follow.
+func writeSnapshot(tx *bolt.Tx, w io.Writer) error {
+	// Write the headers of every block after the genesis block.
+	var headers []types.BlockHeader
+	height := blockHeight(tx)
+	for i := types.BlockHeight(1); i <= height; i++ {
+		id, err := getPath(tx, i)
+		if err != nil {
+			return err
+		}
+		h, err := getBlockHeader(tx, id)
+		if err != nil {
+			return err
+		}
+		headers = append(headers, h)
+		if len(headers) == snapshotHeaderBatch {
+			if err := encoding.WriteObject(w, headers); err != nil {
+				return err
+			}
+			if err := encoding.WriteObject(w, true); err != nil {
+				return err
+			}
+			headers = headers[:0]
+		}
+	}
+	if err := encoding.WriteObject(w, headers); err != nil {
+		return err
+	}
+	if err := encoding.WriteObject(w, false); err != nil {
+		return err
+	}
+
+	// Write the entries of the state buckets.
+	var entries []snapshotEntry
+	var batchSize int
+	err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if !isStateBucket(name) {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			entries = append(entries, snapshotEntry{
+				Bucket: append([]byte(nil), name...),
+				Key:    append([]byte(nil), k...),
+				Value:  append([]byte(nil), v...),
+			})
+			batchSize += len(name) + len(k) + len(v) + 24
+			if batchSize < maxSnapshotBatchSize/2 {
+				return nil
+			}
+			if err := encoding.WriteObject(w, entries); err != nil {
+				return err
+			}
+			entries, batchSize = entries[:0], 0
+			return encoding.WriteObject(w, true)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := encoding.WriteObject(w, entries); err != nil {
+		return err
+	}
+	return encoding.WriteObject(w, false)
+}
+
+// saveSnapshot saves a snapshot of the consensus set, which must be at the
+// checkpoint block, so that it can be served to peers.
+func (cs *ConsensusSet) saveSnapshot(tx *bolt.Tx, cp modules.ConsensusCheckpoint) error {
+	filename := cs.snapshotFilename(cp.Height)
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+	if currentBlockID(tx) != cp.BlockID || consensusChecksum(tx) != cp.ConsensusHash {
+		return errSnapshotChecksum
+	}
+
+	// Write the snapshot to a temporary file so that an interrupted write
+	// does not leave a partial snapshot behind.
+	tmpFilename := filename + "_temp"
+	f, err := os.Create(tmpFilename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = writeSnapshot(tx, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, filename)
+}
+
+// maybeSaveSnapshot saves a snapshot of the consensus set if the block that
+// was just applied is a checkpoint block.
+func (cs *ConsensusSet) maybeSaveSnapshot(tx *bolt.Tx, pb *processedBlock) {
+	cp, exists := cs.checkpointAt(pb.Height)
+	if !exists || cp.BlockID != pb.Block.ID() {
+		return
+	}
+	if err := cs.saveSnapshot(tx, cp); err != nil {
+		cs.log.Printf("WARN: could not save a snapshot of the consensus set at checkpoint %v: %v", cp.Height, err)
+	}
+}
+
+// loadSnapshot replaces the consensus set, which must only contain the
+// genesis block, with the snapshot read from r. The snapshot is checked
+// against the checkpoint, and an error is returned if it does not match, in
+// which case the transaction should not be committed.
+func (cs *ConsensusSet) loadSnapshot(tx *bolt.Tx, r io.Reader, cp modules.ConsensusCheckpoint) error {
+	if blockHeight(tx) != 0 {
+		return errSnapshotNotFresh
+	}
+
+	// Rebuild the current path from the headers. The headers are linked by
+	// their ids, so a chain ending in the checkpoint block is authentic.
+	blockMap := tx.Bucket(BlockMap)
+	parent := cs.blockRoot
+	parentID := parent.Block.ID()
+	for more := true; more; {
+		var headers []types.BlockHeader
+		if err := encoding.ReadObject(r, &headers, maxSnapshotBatchSize); err != nil {
+			return err
+		}
+		if err := encoding.ReadObject(r, &more, 1); err != nil {
+			return err
+		}
+		for _, h := range headers {
+			if h.ParentID != parentID || parent.Height >= cp.Height || !checkHeaderTarget(h, parent.ChildTarget) {
+				return errSnapshotChain
+			}
+			child := processedBlock{
+				Block: types.Block{
+					ParentID:  h.ParentID,
+					Nonce:     h.Nonce,
+					Timestamp: h.Timestamp,
+				},
+				Height: parent.Height + 1,
+				Depth:  parent.childDepth(),
+			}
+			cs.setChildTarget(blockMap, &child)
+			id := h.ID()
+			if err := blockMap.Put(id[:], encoding.Marshal(child)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(PrunedBlocks).Put(id[:], h.MerkleRoot[:]); err != nil {
+				return err
+			}
+			pushPath(tx, id)
+			parent, parentID = child, id
+		}
+	}
+	if parentID != cp.BlockID {
+		return errSnapshotChain
+	}
+
+	// Replace the genesis state with the state from the snapshot.
+	var stateBuckets [][]byte
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		if isStateBucket(name) {
+			stateBuckets = append(stateBuckets, append([]byte(nil), name...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stateBuckets {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	for more := true; more; {
+		var entries []snapshotEntry
+		if err := encoding.ReadObject(r, &entries, maxSnapshotBatchSize); err != nil {
+			return err
+		}
+		if err := encoding.ReadObject(r, &more, 1); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !isStateBucket(e.Bucket) {
+				return errSnapshotBucket
+			}
+			b, err := tx.CreateBucketIfNotExists(e.Bucket)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(e.Key, e.Value); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range [][]byte{SiacoinOutputs, FileContracts, SiafundOutputs, SiafundPool} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	if consensusChecksum(tx) != cp.ConsensusHash {
+		return errSnapshotChecksum
+	}
+
+	// Record the checksum of the checkpoint block for the consistency checks,
+	// and mark every block up to the checkpoint as pruned.
+	parent.ConsensusChecksum = cp.ConsensusHash
+	if err := blockMap.Put(parentID[:], encoding.Marshal(parent)); err != nil {
+		return err
+	}
+	err = tx.Bucket(PruneHeight).Put(PruneHeight, encoding.Marshal(cp.Height+1))
+	if err != nil {
+		return err
+	}
+
+	// Subscribers that need the blocks below the checkpoint will receive
+	// errConsensusChangePruned when they reach this entry.
+	return appendChangeLog(tx, changeEntry{AppliedBlocks: []types.BlockID{cp.BlockID}})
+}
+
+// rpcSendCheckpoint is an RPC that sends the snapshot of the consensus set at
+// the requested checkpoint to the requesting peer.
+func (cs *ConsensusSet) rpcSendCheckpoint(conn modules.PeerConn) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	var height types.BlockHeight
+	err = encoding.ReadObject(conn, &height, 8)
+	if err != nil {
+		return modules.DecodeViolation(err)
+	}
+	if _, exists := cs.checkpointAt(height); !exists {
+		return errNoCheckpoint
+	}
+	f, err := os.Open(cs.snapshotFilename(height))
+	if os.IsNotExist(err) {
+		return errSnapshotUnavailable
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(conn, f)
+	return err
+}
+
+// managedReceiveCheckpoint returns an RPCFunc that requests the snapshot of
+// the consensus set at the checkpoint and loads it. The returned function
+// should be used as the calling end of the SendCheckpoint RPC.
+func (cs *ConsensusSet) managedReceiveCheckpoint(cp modules.ConsensusCheckpoint) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		err := conn.SetDeadline(time.Now().Add(sendCheckpointTimeout))
+		if err != nil {
+			return err
+		}
+		if err := encoding.WriteObject(conn, cp.Height); err != nil {
+			return err
+		}
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		return cs.db.Update(func(tx *bolt.Tx) error {
+			return cs.loadSnapshot(tx, bufio.NewReader(conn), cp)
+		})
+	}
+}
+
+// threadedBootstrapFromCheckpoint tries to download the snapshot of the
+// consensus set at the most recent checkpoint from the consensus set's peers.
+// Nothing is done if the consensus set already has blocks beyond the genesis
+// block. If no snapshot can be downloaded before checkpointBootstrapTimeout
+// has passed, the consensus set is left untouched so that it can download
+// the full blockchain instead.
+func (cs *ConsensusSet) threadedBootstrapFromCheckpoint() {
+	err := cs.tg.AddNamed("threadedBootstrapFromCheckpoint")
+	if err != nil {
+		return
+	}
+	defer cs.tg.DoneNamed("threadedBootstrapFromCheckpoint")
+
+	cp, exists := cs.latestCheckpoint()
+	if !exists || cs.Height() != 0 {
+		return
+	}
+	deadline := time.Now().Add(checkpointBootstrapTimeout)
+	for time.Now().Before(deadline) {
+		for _, p := range cs.gateway.Peers() {
+			err := cs.gateway.RPC(p.NetAddress, "SendCheckpoint", cs.managedReceiveCheckpoint(cp))
+			if err == nil {
+				cs.log.Printf("INFO: bootstrapped from checkpoint %v using a snapshot from %v", cp.Height, p.NetAddress)
+				return
+			}
+			cs.log.Printf("WARN: could not download the snapshot at checkpoint %v from %v: %v", cp.Height, p.NetAddress, err)
+		}
+		select {
+		case <-cs.tg.StopChan():
+			return
+		case <-time.After(ibdLoopDelay):
+		}
+	}
+	cs.log.Printf("WARN: no snapshot was available at checkpoint %v, downloading the full blockchain", cp.Height)
+}
@@ -0,0 +1,153 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// signedCheckpoint returns a checkpoint at the current block of the consensus
+// set, signed with the key of the testing checkpoints.
+func signedCheckpoint(cs *ConsensusSet) (modules.ConsensusCheckpoint, error) {
+	cp, err := cs.CurrentCheckpoint()
+	if err != nil {
+		return modules.ConsensusCheckpoint{}, err
+	}
+	sk, _ := crypto.GenerateKeyPairDeterministic(checkpointEntropy)
+	cp.Signature, err = crypto.SignHash(cp.SigHash(), sk)
+	return cp, err
+}
+
+// TestStandardCheckpointKey checks that a checkpoint signed offline with the
+// standard checkpoint key verifies against the embedded public key, and that
+// every embedded standard checkpoint carries a valid signature.
+func TestStandardCheckpointKey(t *testing.T) {
+	cp := modules.ConsensusCheckpoint{Height: 100e3}
+	for i := range cp.BlockID {
+		cp.BlockID[i] = byte(i)
+		cp.ConsensusHash[i] = byte(0xff - i)
+	}
+	sig, err := hex.DecodeString("b092bddfa38a2932124fd9051fdd68cfa17d143ee340ce37a460368b78a7a3b75085a4bc51d11cb30224692fd60e14e581117b6e1eae04d196f1fc39c6797508")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(cp.Signature[:], sig)
+	if err := crypto.VerifyHash(cp.SigHash(), standardCheckpointPublicKey, cp.Signature); err != nil {
+		t.Fatal("fixture was not signed by the standard checkpoint key:", err)
+	}
+
+	// Any change to the checkpoint should invalidate the signature.
+	cp.ConsensusHash[0]++
+	if crypto.VerifyHash(cp.SigHash(), standardCheckpointPublicKey, cp.Signature) == nil {
+		t.Fatal("signature should not cover a different consensus hash")
+	}
+
+	for i, cp := range standardCheckpoints {
+		if err := crypto.VerifyHash(cp.SigHash(), standardCheckpointPublicKey, cp.Signature); err != nil {
+			t.Errorf("standard checkpoint %v at height %v has an invalid signature: %v", i, cp.Height, err)
+		}
+		if i > 0 && cp.Height <= standardCheckpoints[i-1].Height {
+			t.Errorf("standard checkpoint %v is out of order", i)
+		}
+	}
+}
+
+// TestBootstrapFromCheckpoint checks that a consensus set can be bootstrapped
+// from the snapshot of a peer, and that snapshots that do not match the
+// checkpoint are rejected.
+func TestBootstrapFromCheckpoint(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := createConsensusSetTester("TestBootstrapFromCheckpoint1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester("TestBootstrapFromCheckpoint2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Create a checkpoint at the current block of cst1 and save a snapshot.
+	cp, err := signedCheckpoint(cst1.cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyCheckpoint(cp); err != nil {
+		t.Fatal(err)
+	}
+	cst1.cs.checkpoints = []modules.ConsensusCheckpoint{cp}
+	cst2.cs.checkpoints = []modules.ConsensusCheckpoint{cp}
+	err = cst1.cs.db.View(func(tx *bolt.Tx) error {
+		return cst1.cs.saveSnapshot(tx, cp)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst2.gateway.Connect(cst1.gateway.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A snapshot that does not match the checkpoint should be rejected
+	// without modifying the consensus set.
+	badCP := cp
+	badCP.ConsensusHash[0]++
+	err = cst2.gateway.RPC(cst1.gateway.Address(), "SendCheckpoint", cst2.cs.managedReceiveCheckpoint(badCP))
+	if err != errSnapshotChecksum {
+		t.Fatalf("expected %v, got %v", errSnapshotChecksum, err)
+	}
+	if cst2.cs.Height() != 0 {
+		t.Fatal("rejected snapshot modified the consensus set")
+	}
+
+	// Load the snapshot.
+	err = cst2.gateway.RPC(cst1.gateway.Address(), "SendCheckpoint", cst2.cs.managedReceiveCheckpoint(cp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst2.cs.Height() != cp.Height {
+		t.Fatalf("expected height %v, got %v", cp.Height, cst2.cs.Height())
+	}
+	if _, exists := cst2.cs.BlockAtHeight(cp.Height); exists {
+		t.Error("blocks below the checkpoint should be pruned")
+	}
+
+	// The bootstrapped consensus set should accept blocks that build on the
+	// checkpoint, and should stay in sync with the full consensus set.
+	for i := 0; i < 3; i++ {
+		b, err := cst1.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cst2.cs.AcceptBlock(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var checksum1, checksum2 crypto.Hash
+	_ = cst1.cs.db.View(func(tx *bolt.Tx) error {
+		checksum1 = consensusChecksum(tx)
+		return nil
+	})
+	_ = cst2.cs.db.View(func(tx *bolt.Tx) error {
+		checksum2 = consensusChecksum(tx)
+		return nil
+	})
+	if checksum1 != checksum2 {
+		t.Fatal("bootstrapped consensus set diverged from the full consensus set")
+	}
+
+	// A consensus set that already has blocks cannot load a snapshot.
+	err = cst2.gateway.RPC(cst1.gateway.Address(), "SendCheckpoint", cst2.cs.managedReceiveCheckpoint(cp))
+	if err != errSnapshotNotFresh {
+		t.Fatalf("expected %v, got %v", errSnapshotNotFresh, err)
+	}
+}
@@ -0,0 +1,221 @@
+package consensus
+
+// compactblock.go implements compact block relay. When a peer relays a block
+// header, the block is requested with the SendCBlk RPC, which sends short IDs
+// in place of the block's transactions. Transactions that are already in the
+// transaction pool are taken from the pool, and only the rest are downloaded.
+// If the block cannot be rebuilt, it is downloaded in full with SendBlk.
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errCompactBlockMismatch = errors.New("rebuilt compact block does not match the requested block")
+	errBadMissingIndex      = errors.New("requested transaction index is out of bounds")
+	errWrongMissingCount    = errors.New("peer sent the wrong number of missing transactions")
+)
+
+type (
+	// A shortTxnID identifies a transaction within a compact block. It is
+	// salted with the block ID so that collisions cannot be precomputed.
+	shortTxnID [8]byte
+
+	// A compactBlock is a block whose transactions are replaced by short
+	// IDs.
+	compactBlock struct {
+		ParentID     types.BlockID
+		Nonce        types.BlockNonce
+		Timestamp    types.Timestamp
+		MinerPayouts []types.SiacoinOutput
+		ShortIDs     []shortTxnID
+	}
+)
+
+// shortID returns the short ID of a transaction in the block with the given
+// ID.
+func shortID(blockID types.BlockID, txid types.TransactionID) (sid shortTxnID) {
+	h := crypto.HashAll(blockID, txid)
+	copy(sid[:], h[:])
+	return sid
+}
+
+// newCompactBlock returns the compact form of a block.
+func newCompactBlock(b types.Block) compactBlock {
+	id := b.ID()
+	cb := compactBlock{
+		ParentID:     b.ParentID,
+		Nonce:        b.Nonce,
+		Timestamp:    b.Timestamp,
+		MinerPayouts: b.MinerPayouts,
+		ShortIDs:     make([]shortTxnID, len(b.Transactions)),
+	}
+	for i, txn := range b.Transactions {
+		cb.ShortIDs[i] = shortID(id, txn.ID())
+	}
+	return cb
+}
+
+// resolve fills in the transactions of a compact block from a pool of known
+// transactions, returning the indices of the transactions that are not in
+// the pool. Short IDs that match more than one pooled transaction are
+// treated as missing.
+func (cb compactBlock) resolve(id types.BlockID, pool []types.Transaction) (txns []types.Transaction, missing []uint64) {
+	known := make(map[shortTxnID]int, len(pool))
+	for i, txn := range pool {
+		sid := shortID(id, txn.ID())
+		if _, exists := known[sid]; exists {
+			known[sid] = -1
+			continue
+		}
+		known[sid] = i
+	}
+	txns = make([]types.Transaction, len(cb.ShortIDs))
+	for i, sid := range cb.ShortIDs {
+		j, exists := known[sid]
+		if !exists || j < 0 {
+			missing = append(missing, uint64(i))
+			continue
+		}
+		txns[i] = pool[j]
+	}
+	return txns, missing
+}
+
+// block assembles a block from a compact block and its transactions.
+func (cb compactBlock) block(txns []types.Transaction) types.Block {
+	return types.Block{
+		ParentID:     cb.ParentID,
+		Nonce:        cb.Nonce,
+		Timestamp:    cb.Timestamp,
+		MinerPayouts: cb.MinerPayouts,
+		Transactions: txns,
+	}
+}
+
+// SetTransactionSource sets the source of the unconfirmed transactions that
+// relayed blocks are rebuilt from.
+func (cs *ConsensusSet) SetTransactionSource(ts modules.TransactionSource) {
+	cs.mu.Lock()
+	cs.txnSource = ts
+	cs.mu.Unlock()
+}
+
+// managedPooledTransactions returns the transactions of the transaction
+// source, or nil if there is no source. The source is called without holding
+// the consensus set's lock, as the transaction pool calls the consensus set
+// while holding its own lock.
+func (cs *ConsensusSet) managedPooledTransactions() []types.Transaction {
+	cs.mu.RLock()
+	ts := cs.txnSource
+	cs.mu.RUnlock()
+	if ts == nil {
+		return nil
+	}
+	return ts.TransactionList()
+}
+
+// rpcSendCompactBlock is the receiving end of the SendCBlk RPC. It sends the
+// requested block in compact form, followed by the transactions that the
+// caller does not have.
+func (cs *ConsensusSet) rpcSendCompactBlock(conn modules.PeerConn) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	var id types.BlockID
+	err = encoding.ReadObject(conn, &id, crypto.HashSize)
+	if err != nil {
+		return err
+	}
+	b, err := cs.managedRelayableBlock(id)
+	if err != nil {
+		return err
+	}
+	err = encoding.WriteObject(conn, newCompactBlock(b))
+	if err != nil {
+		return err
+	}
+
+	// Send the transactions that the caller could not find.
+	var missing []uint64
+	err = encoding.ReadObject(conn, &missing, uint64(8+8*len(b.Transactions)))
+	if err != nil {
+		return err
+	}
+	txns := make([]types.Transaction, len(missing))
+	for i, index := range missing {
+		if index >= uint64(len(b.Transactions)) {
+			return errBadMissingIndex
+		}
+		txns[i] = b.Transactions[index]
+	}
+	return encoding.WriteObject(conn, txns)
+}
+
+// managedReceiveCompactBlock returns an RPCFunc that requests the block with
+// the given ID using the SendCBlk RPC, rebuilding it from 'pool' and the
+// transactions that the peer sends. The rebuilt block is stored in 'block'.
+// The returned function should be used as the calling end of the SendCBlk
+// RPC.
+func (cs *ConsensusSet) managedReceiveCompactBlock(id types.BlockID, pool []types.Transaction, block *types.Block) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		if err := encoding.WriteObject(conn, id); err != nil {
+			return err
+		}
+		var cb compactBlock
+		if err := encoding.ReadObject(conn, &cb, types.BlockSizeLimit); err != nil {
+			return err
+		}
+		txns, missing := cb.resolve(id, pool)
+		if err := encoding.WriteObject(conn, missing); err != nil {
+			return err
+		}
+		var received []types.Transaction
+		if err := encoding.ReadObject(conn, &received, types.BlockSizeLimit); err != nil {
+			return err
+		}
+		if len(received) != len(missing) {
+			return errWrongMissingCount
+		}
+		for i, index := range missing {
+			txns[index] = received[i]
+		}
+
+		// The block ID commits to the transactions, so a short ID collision
+		// results in a mismatch.
+		b := cb.block(txns)
+		if b.ID() != id {
+			return errCompactBlockMismatch
+		}
+		*block = b
+		return nil
+	}
+}
+
+// managedFetchRelayedBlock downloads the block with the given ID from a peer
+// that relayed its header, and adds it to the consensus set. The compact form
+// of the block is tried first, falling back to the full block if the peer
+// does not support compact blocks or the block cannot be rebuilt.
+func (cs *ConsensusSet) managedFetchRelayedBlock(addr modules.NetAddress, id types.BlockID) error {
+	if pool := cs.managedPooledTransactions(); pool != nil {
+		var b types.Block
+		err := cs.gateway.RPC(addr, "SendCBlk", cs.managedReceiveCompactBlock(id, pool, &b))
+		if err == nil {
+			if err := cs.managedAcceptBlock(b); err != nil {
+				return err
+			}
+			cs.managedBroadcastBlock(b)
+			return nil
+		}
+		cs.log.Debugln("WARN: failed to get compact block, downloading the full block:", err)
+	}
+	return cs.gateway.RPC(addr, "SendBlk", cs.managedReceiveBlock(id))
+}
@@ -0,0 +1,91 @@
+package consensus
+
+import (
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSendCompactBlock checks that a block can be downloaded with the
+// SendCBlk RPC, and that only the transactions missing from the caller's
+// transaction pool are sent in full.
+func TestSendCompactBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := createConsensusSetTester("TestSendCompactBlock1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester("TestSendCompactBlock2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Bring cst2 up to date with cst1.
+	for i := types.BlockHeight(1); i <= cst1.cs.Height(); i++ {
+		b, _ := cst1.cs.BlockAtHeight(i)
+		if err := cst2.cs.AcceptBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create two transaction sets, and give only the first to cst2.
+	set1, err := cst1.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst1.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cst2.tpool.AcceptTransactionSet(set1); err != nil {
+		t.Fatal(err)
+	}
+	b, err := cst1.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := b.ID()
+
+	// Only the transactions of the second set should be missing.
+	pool := cst2.cs.managedPooledTransactions()
+	if len(pool) != len(set1) {
+		t.Fatal("transaction pool was not set as the transaction source")
+	}
+	_, missing := newCompactBlock(b).resolve(id, pool)
+	if len(missing) == 0 || len(missing) != len(b.Transactions)-len(set1) {
+		t.Fatal("expected", len(b.Transactions)-len(set1), "missing transactions, got", len(missing))
+	}
+
+	// Download the block.
+	p1, p2 := net.Pipe()
+	go cst1.cs.rpcSendCompactBlock(mockPeerConn{p2})
+	var rebuilt types.Block
+	err = cst2.cs.managedReceiveCompactBlock(id, pool, &rebuilt)(mockPeerConn{p1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt.ID() != id {
+		t.Fatal("rebuilt block does not match the original")
+	}
+	if err := cst2.cs.AcceptBlock(rebuilt); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a transaction pool, every transaction is sent in full.
+	p1, p2 = net.Pipe()
+	go cst1.cs.rpcSendCompactBlock(mockPeerConn{p2})
+	rebuilt = types.Block{}
+	err = cst2.cs.managedReceiveCompactBlock(id, nil, &rebuilt)(mockPeerConn{p1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt.ID() != id {
+		t.Fatal("rebuilt block does not match the original")
+	}
+}
@@ -81,7 +81,24 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 			return err
 		}
 	}
+	err := cs.initConsensusState(tx)
+	if err != nil {
+		return err
+	}
+
+	// Add the genesis block to the block strucutres - checksum must be taken
+	// after pushing the genesis block into the path.
+	if build.DEBUG {
+		cs.blockRoot.ConsensusChecksum = consensusChecksum(tx)
+	}
+	addBlockMap(tx, &cs.blockRoot)
+	return nil
+}
 
+// initConsensusState sets the consensus state buckets to the state that
+// results from applying the genesis block. The buckets must already exist and
+// be empty.
+func (cs *ConsensusSet) initConsensusState(tx *bolt.Tx) error {
 	// Set the block height to -1, so the genesis block is at height 0.
 	blockHeight := tx.Bucket(BlockHeight)
 	underflow := types.BlockHeight(0)
@@ -108,13 +125,8 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 		UnlockHash: types.UnlockHash{},
 	})
 
-	// Add the genesis block to the block strucutres - checksum must be taken
-	// after pushing the genesis block into the path.
+	// Add the genesis block to the current path.
 	pushPath(tx, cs.blockRoot.Block.ID())
-	if build.DEBUG {
-		cs.blockRoot.ConsensusChecksum = consensusChecksum(tx)
-	}
-	addBlockMap(tx, &cs.blockRoot)
 	return nil
 }
 
@@ -45,6 +45,9 @@ type ConsensusSet struct {
 	// the function of adding a subscriber should not be exposed.
 	subscribers []modules.ConsensusSetSubscriber
 
+	// events announces the blocks that join the current path.
+	events modules.EventFeed
+
 	// dosBlocks are blocks that are invalid, but the invalidity is only
 	// discoverable during an expensive step of validation. These blocks are
 	// recorded to eliminate a DoS vector where an expensive-to-validate block
@@ -70,6 +73,31 @@ type ConsensusSet struct {
 	// whether the consensus set is synced with the network.
 	synced bool
 
+	// batchChanges is true while the initial blockchain download is in
+	// progress. While it is set, consensus changes are queued in
+	// pendingChanges and sent to subscribers in batches, instead of being
+	// sent one block at a time.
+	batchChanges   bool
+	pendingChanges []changeEntry
+
+	// reorgs contains the most recent reorgs of the blockchain, oldest
+	// first. reorgIndex is the index of the most recent reorg.
+	reorgs     []modules.ReorgEvent
+	reorgIndex uint64
+
+	// pruneDepth is the depth after which the bodies of blocks in the current
+	// path are discarded. A pruneDepth of 0 disables pruning.
+	pruneDepth types.BlockHeight
+
+	// checkpoints are the checkpoints that the consensus set saves snapshots
+	// at, and can bootstrap from if fastBootstrap is set.
+	checkpoints   []modules.ConsensusCheckpoint
+	fastBootstrap bool
+
+	// txnSource provides the unconfirmed transactions that relayed blocks
+	// are rebuilt from. It is usually the transaction pool.
+	txnSource modules.TransactionSource
+
 	// Interfaces to abstract the dependencies of the ConsensusSet.
 	marshaler       encoding.GenericMarshaler
 	blockRuleHelper blockRuleHelper
@@ -87,6 +115,35 @@ type ConsensusSet struct {
 // there is an existing block database present in the persist directory, it
 // will be loaded.
 func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*ConsensusSet, error) {
+	return newConsensusSet(gateway, bootstrap, persistDir, 0, false)
+}
+
+// NewPruned returns a new ConsensusSet that discards the bodies of blocks once
+// they are more than 'pruneDepth' blocks deep, keeping only their headers. A
+// pruned consensus set cannot follow reorgs deeper than 'pruneDepth', cannot
+// provide old blocks to peers, and cannot catch up subscribers that are
+// missing pruned blocks.
+func NewPruned(gateway modules.Gateway, bootstrap bool, persistDir string, pruneDepth types.BlockHeight) (*ConsensusSet, error) {
+	if pruneDepth < minPruneDepth {
+		return nil, errPruneDepthTooSmall
+	}
+	return newConsensusSet(gateway, bootstrap, persistDir, pruneDepth, false)
+}
+
+// NewFromCheckpoint returns a new ConsensusSet that, if it only contains the
+// genesis block, downloads a snapshot of the consensus set at the most recent
+// checkpoint from its peers instead of downloading every block up to the
+// checkpoint. The blocks below the checkpoint are treated as pruned. A
+// pruneDepth of 0 keeps every block after the checkpoint.
+func NewFromCheckpoint(gateway modules.Gateway, persistDir string, pruneDepth types.BlockHeight) (*ConsensusSet, error) {
+	if pruneDepth != 0 && pruneDepth < minPruneDepth {
+		return nil, errPruneDepthTooSmall
+	}
+	return newConsensusSet(gateway, true, persistDir, pruneDepth, true)
+}
+
+// newConsensusSet returns a new ConsensusSet with the provided prune depth.
+func newConsensusSet(gateway modules.Gateway, bootstrap bool, persistDir string, pruneDepth types.BlockHeight, fastBootstrap bool) (*ConsensusSet, error) {
 	// Check for nil dependencies.
 	if gateway == nil {
 		return nil, errNilGateway
@@ -104,7 +161,11 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 			DiffsGenerated: true,
 		},
 
-		dosBlocks: make(map[types.BlockID]struct{}),
+		dosBlocks:     make(map[types.BlockID]struct{}),
+		batchChanges:  bootstrap,
+		pruneDepth:    pruneDepth,
+		checkpoints:   embeddedCheckpoints,
+		fastBootstrap: fastBootstrap,
 
 		marshaler:       encoding.StdGenericMarshaler{},
 		blockRuleHelper: stdBlockRuleHelper{},
@@ -112,6 +173,7 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 
 		persistDir: persistDir,
 	}
+	cs.tg.SetName("consensus")
 
 	// Create the diffs for the genesis siafund outputs.
 	for i, siafundOutput := range types.GenesisBlock.Transactions[0].SiafundOutputs {
@@ -136,7 +198,10 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 		// testing.
 		if bootstrap {
 			// We are in a virgin goroutine right now, so calling the threaded
-			// function without a goroutine is okay.
+			// functions without a goroutine is okay.
+			if fastBootstrap {
+				cs.threadedBootstrapFromCheckpoint()
+			}
 			err = cs.threadedInitialBlockchainDownload()
 			if err != nil {
 				return
@@ -157,19 +222,27 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 		gateway.RegisterRPC("RelayBlock", cs.rpcRelayBlock) // COMPATv0.5.1
 		gateway.RegisterRPC("RelayHeader", cs.threadedRPCRelayHeader)
 		gateway.RegisterRPC("SendBlk", cs.rpcSendBlk)
+		gateway.RegisterRPC("SendCBlk", cs.rpcSendCompactBlock)
+		gateway.RegisterRPC("SendHeaders", cs.rpcSendHeaders)
+		gateway.RegisterRPC("SendBlks", cs.rpcSendBlks)
+		gateway.RegisterRPC("SendCheckpoint", cs.rpcSendCheckpoint)
+		gateway.RegisterRPC("SendTxnProofs", cs.rpcSendTxnProofs)
 		gateway.RegisterConnectCall("SendBlocks", cs.threadedReceiveBlocks)
 		cs.tg.OnStop(func() {
 			cs.gateway.UnregisterRPC("SendBlocks")
 			cs.gateway.UnregisterRPC("RelayBlock")
 			cs.gateway.UnregisterRPC("RelayHeader")
 			cs.gateway.UnregisterRPC("SendBlk")
+			cs.gateway.UnregisterRPC("SendCBlk")
+			cs.gateway.UnregisterRPC("SendHeaders")
+			cs.gateway.UnregisterRPC("SendBlks")
+			cs.gateway.UnregisterRPC("SendCheckpoint")
+			cs.gateway.UnregisterRPC("SendTxnProofs")
 			cs.gateway.UnregisterConnectCall("SendBlocks")
 		})
 
 		// Mark that we are synced with the network.
-		cs.mu.Lock()
-		cs.synced = true
-		cs.mu.Unlock()
+		cs.managedMarkSynced()
 	}()
 
 	return cs, nil
@@ -182,6 +255,9 @@ func (cs *ConsensusSet) BlockAtHeight(height types.BlockHeight) (block types.Blo
 		if err != nil {
 			return err
 		}
+		if isPruned(tx, id) {
+			return errBlockPruned
+		}
 		pb, err := getBlockMap(tx, id)
 		if err != nil {
 			return err
@@ -4,15 +4,22 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/NebulousLabs/bolt"
 )
 
+var (
+	errRepairConnectivity = errors.New("cannot repair a consensus set whose current path is broken")
+	errRepairPruned       = errors.New("cannot repair a pruned consensus set")
+)
+
 // manageErr handles an error detected by the consistency checks.
 func manageErr(tx *bolt.Tx, err error) {
 	markInconsistency(tx)
@@ -77,7 +84,7 @@ func consensusChecksum(tx *bolt.Tx) crypto.Hash {
 
 // checkSiacoinCount checks that the number of siacoins countable within the
 // consensus set equal the expected number of siacoins for the block height.
-func checkSiacoinCount(tx *bolt.Tx) {
+func checkSiacoinCount(tx *bolt.Tx) error {
 	// Iterate through all the buckets looking for the delayed siacoin output
 	// buckets, and check that they are for the correct heights.
 	var dscoSiacoins types.Currency
@@ -88,22 +95,18 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		}
 
 		// Sum up the delayed outputs in this bucket.
-		err := b.ForEach(func(_, delayedOutput []byte) error {
+		return b.ForEach(func(_, delayedOutput []byte) error {
 			var sco types.SiacoinOutput
 			err := encoding.Unmarshal(delayedOutput, &sco)
 			if err != nil {
-				manageErr(tx, err)
+				return err
 			}
 			dscoSiacoins = dscoSiacoins.Add(sco.Value)
 			return nil
 		})
-		if err != nil {
-			return err
-		}
-		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	// Add all of the siacoin outputs.
@@ -112,13 +115,13 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		var sco types.SiacoinOutput
 		err := encoding.Unmarshal(scoBytes, &sco)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 		scoSiacoins = scoSiacoins.Add(sco.Value)
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	// Add all of the payouts from file contracts.
@@ -127,7 +130,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		var fc types.FileContract
 		err := encoding.Unmarshal(fcBytes, &fc)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 		var fcCoins types.Currency
 		for _, output := range fc.ValidProofOutputs {
@@ -137,7 +140,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	// Add all of the siafund claims.
@@ -146,7 +149,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		var sfo types.SiafundOutput
 		err := encoding.Unmarshal(sfoBytes, &sfo)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 
 		coinsPerFund := getSiafundPool(tx).Sub(sfo.ClaimStart)
@@ -155,7 +158,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	expectedSiacoins := types.CalculateNumSiacoins(blockHeight(tx))
@@ -167,34 +170,53 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		} else {
 			diagnostics += fmt.Sprintf("total: %v\nexpected: %v\n expected is bigger: %v", totalSiacoins, expectedSiacoins, totalSiacoins.Sub(expectedSiacoins))
 		}
-		manageErr(tx, errors.New(diagnostics))
+		return errors.New(diagnostics)
 	}
+	return nil
 }
 
 // checkSiafundCount checks that the number of siafunds countable within the
 // consensus set equal the expected number of siafunds for the block height.
-func checkSiafundCount(tx *bolt.Tx) {
+func checkSiafundCount(tx *bolt.Tx) error {
 	var total types.Currency
 	err := tx.Bucket(SiafundOutputs).ForEach(func(_, siafundOutputBytes []byte) error {
 		var sfo types.SiafundOutput
 		err := encoding.Unmarshal(siafundOutputBytes, &sfo)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 		total = total.Add(sfo.Value)
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 	if total.Cmp(types.SiafundCount) != 0 {
-		manageErr(tx, errors.New("wrong number if siafunds in the consensus set"))
+		return errors.New("wrong number if siafunds in the consensus set")
 	}
+	return nil
+}
+
+// checkSiafundPool checks that no siafund output has a claim start that is
+// larger than the siafund pool, as the siafund pool can only ever grow.
+func checkSiafundPool(tx *bolt.Tx) error {
+	pool := getSiafundPool(tx)
+	return tx.Bucket(SiafundOutputs).ForEach(func(_, siafundOutputBytes []byte) error {
+		var sfo types.SiafundOutput
+		err := encoding.Unmarshal(siafundOutputBytes, &sfo)
+		if err != nil {
+			return err
+		}
+		if sfo.ClaimStart.Cmp(pool) > 0 {
+			return errors.New("siafund output has a claim start that is larger than the siafund pool")
+		}
+		return nil
+	})
 }
 
 // checkDSCOs scans the sets of delayed siacoin outputs and checks for
 // consistency.
-func checkDSCOs(tx *bolt.Tx) {
+func checkDSCOs(tx *bolt.Tx) error {
 	// Create a map to track which delayed siacoin output maps exist, and
 	// another map to track which ids have appeared in the dsco set.
 	dscoTracker := make(map[types.BlockHeight]struct{})
@@ -213,7 +235,7 @@ func checkDSCOs(tx *bolt.Tx) {
 		var height types.BlockHeight
 		err := encoding.Unmarshal(name[len(prefixDSCO):], &height)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 		_, exists := dscoTracker[height]
 		if exists {
@@ -236,7 +258,7 @@ func checkDSCOs(tx *bolt.Tx) {
 			var sco types.SiacoinOutput
 			err := encoding.Unmarshal(delayedOutput, &sco)
 			if err != nil {
-				manageErr(tx, err)
+				return err
 			}
 			total = total.Add(sco.Value)
 			return nil
@@ -254,7 +276,7 @@ func checkDSCOs(tx *bolt.Tx) {
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	// Check that all of the correct heights are represented.
@@ -266,13 +288,44 @@ func checkDSCOs(tx *bolt.Tx) {
 		}
 		_, exists := dscoTracker[i]
 		if !exists {
-			manageErr(tx, errors.New("missing a dsco bucket"))
+			return errors.New("missing a dsco bucket")
 		}
 		expectedBuckets++
 	}
 	if len(dscoTracker) != expectedBuckets {
-		manageErr(tx, errors.New("too many dsco buckets"))
+		return errors.New("too many dsco buckets")
 	}
+	return nil
+}
+
+// checkBlockConnectivity checks that every block in the current path is in
+// the block map at the correct height, and that every block points to the
+// block before it in the current path.
+func checkBlockConnectivity(tx *bolt.Tx) error {
+	height := blockHeight(tx)
+	var parentID types.BlockID
+	for i := types.BlockHeight(0); i <= height; i++ {
+		id, err := getPath(tx, i)
+		if err != nil {
+			return fmt.Errorf("block at height %v is missing from the current path: %v", i, err)
+		}
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return fmt.Errorf("block at height %v is missing from the block map: %v", i, err)
+		}
+		if pb.Height != i {
+			return fmt.Errorf("block at height %v is recorded at height %v", i, pb.Height)
+		}
+		if i > 0 && pb.Block.ParentID != parentID {
+			return fmt.Errorf("block at height %v does not point to its parent", i)
+		}
+		// The id of a pruned block cannot be recomputed.
+		if !isPruned(tx, id) && pb.Block.ID() != id {
+			return fmt.Errorf("block at height %v is stored under the wrong id", i)
+		}
+		parentID = id
+	}
+	return nil
 }
 
 // checkRevertApply reverts the most recent block, checking to see that the
@@ -286,6 +339,13 @@ func (cs *ConsensusSet) checkRevertApply(tx *bolt.Tx) {
 		return
 	}
 
+	// The blocks after a pruned block cannot be reverted, which is the case
+	// for the first block after the checkpoint of a bootstrapped consensus
+	// set.
+	if isPruned(tx, current.Block.ParentID) {
+		return
+	}
+
 	parent, err := getBlockMap(tx, current.Block.ParentID)
 	if err != nil {
 		manageErr(tx, err)
@@ -316,9 +376,11 @@ func (cs *ConsensusSet) checkConsistency(tx *bolt.Tx) {
 		return
 	}
 	cs.checkingConsistency = true
-	checkDSCOs(tx)
-	checkSiacoinCount(tx)
-	checkSiafundCount(tx)
+	for _, check := range []func(*bolt.Tx) error{checkDSCOs, checkSiacoinCount, checkSiafundCount} {
+		if err := check(tx); err != nil {
+			manageErr(tx, err)
+		}
+	}
 	if build.DEBUG {
 		cs.checkRevertApply(tx)
 	}
@@ -339,5 +401,132 @@ func (cs *ConsensusSet) maybeCheckConsistency(tx *bolt.Tx) {
 	}
 }
 
+// consistencyErrors runs every consistency check that does not modify the
+// database, returning a description of each inconsistency found.
+func consistencyErrors(tx *bolt.Tx) (errs []string) {
+	checks := []func(*bolt.Tx) error{
+		checkBlockConnectivity,
+		checkDSCOs,
+		checkSiacoinCount,
+		checkSiafundCount,
+		checkSiafundPool,
+	}
+	for _, check := range checks {
+		if err := check(tx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return errs
+}
+
+// repairConsensusState re-derives the siacoin outputs, file contracts,
+// siafund outputs, siafund pool, and delayed siacoin outputs of the consensus
+// set by resetting them to the genesis state and applying the diffs of every
+// block in the current path.
+func (cs *ConsensusSet) repairConsensusState(tx *bolt.Tx) error {
+	// The diffs of pruned blocks have been discarded, and a broken current
+	// path cannot be followed.
+	if pruneHeight(tx) > 1 {
+		return errRepairPruned
+	}
+	if err := checkBlockConnectivity(tx); err != nil {
+		return errRepairConnectivity
+	}
+	height := blockHeight(tx)
+	path := make([]types.BlockID, height+1)
+	for i := range path {
+		id, err := getPath(tx, types.BlockHeight(i))
+		if err != nil {
+			return err
+		}
+		path[i] = id
+	}
+
+	// Delete and recreate every bucket that holds consensus state.
+	var prefixed [][]byte
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		if bytes.HasPrefix(name, prefixDSCO) || bytes.HasPrefix(name, prefixFCEX) {
+			prefixed = append(prefixed, append([]byte(nil), name...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range prefixed {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	for _, bucket := range [][]byte{BlockHeight, BlockPath, SiacoinOutputs, FileContracts, SiafundOutputs, SiafundPool} {
+		if err := tx.DeleteBucket(bucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(bucket); err != nil {
+			return err
+		}
+	}
+
+	// Re-apply the current path on top of the genesis state.
+	err = cs.initConsensusState(tx)
+	if err != nil {
+		return err
+	}
+	for _, id := range path[1:] {
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return err
+		}
+		if !pb.DiffsGenerated {
+			return errDiffsNotGenerated
+		}
+		commitDiffSet(tx, pb, modules.DiffApply)
+	}
+	return nil
+}
+
+// ConsistencyCheck walks the consensus database, verifying the connectivity
+// of the current path, the maturation of delayed siacoin outputs, and the
+// accounting of siacoins, siafunds, and the siafund pool. If inconsistencies
+// are found and 'repair' is true, the consensus state is re-derived from the
+// blocks in the current path and checked again.
+func (cs *ConsensusSet) ConsistencyCheck(repair bool) (modules.ConsistencyReport, error) {
+	err := cs.tg.Add()
+	if err != nil {
+		return modules.ConsistencyReport{}, err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var report modules.ConsistencyReport
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		report.Errors = consistencyErrors(tx)
+		return nil
+	})
+	if err != nil || len(report.Errors) == 0 || !repair {
+		return report, err
+	}
+
+	// Repair the consensus state, and only commit the repair if it results
+	// in a consistent database.
+	err = cs.db.Update(func(tx *bolt.Tx) error {
+		err := cs.repairConsensusState(tx)
+		if err != nil {
+			return err
+		}
+		if errs := consistencyErrors(tx); len(errs) != 0 {
+			return errors.New("consensus set is still inconsistent after repair: " + strings.Join(errs, "; "))
+		}
+		return tx.Bucket(Consistency).Put(Consistency, encoding.Marshal(false))
+	})
+	if err != nil {
+		return report, err
+	}
+	cs.log.Println("Repaired inconsistencies in the consensus database:", strings.Join(report.Errors, "; "))
+	report.Repaired = true
+	return report, nil
+}
+
 // TODO: Check that every file contract has an expiration too, and that the
 // number of file contracts + the number of expirations is equal.
@@ -0,0 +1,65 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestConsistencyCheckRepair corrupts the consensus database and checks that
+// ConsistencyCheck reports and repairs the corruption.
+func TestConsistencyCheckRepair(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester("TestConsistencyCheckRepair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	report, err := cst.cs.ConsistencyCheck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 0 {
+		t.Fatal("consistent database reported errors:", report.Errors)
+	}
+	checksum := cst.cs.dbConsensusChecksum()
+
+	// Corrupt the database by deleting a siacoin output.
+	err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(SiacoinOutputs).Cursor().First()
+		return tx.Bucket(SiacoinOutputs).Delete(k)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = cst.cs.ConsistencyCheck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) == 0 || report.Repaired {
+		t.Fatal("corruption was not reported")
+	}
+
+	// Repair the database.
+	report, err = cst.cs.ConsistencyCheck(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) == 0 || !report.Repaired {
+		t.Fatal("corruption was not repaired")
+	}
+	report, err = cst.cs.ConsistencyCheck(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 0 {
+		t.Fatal("repaired database reported errors:", report.Errors)
+	}
+	if cst.cs.dbConsensusChecksum() != checksum {
+		t.Error("repaired database does not match the original database")
+	}
+}
@@ -213,11 +213,19 @@ func generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
 	// applied.
 	createDSCOBucket(tx, pb.Height+types.MaturityDelay)
 
+	// Check the signatures and storage proofs of the transactions in
+	// parallel. These checks do not depend on the other transactions in the
+	// block.
+	proofs, err := preverifyTransactions(tx, pb.Block.Transactions)
+	if err != nil {
+		return err
+	}
+
 	// Validate and apply each transaction in the block. They cannot be
 	// validated all at once because some transactions may not be valid until
 	// previous transactions have been applied.
-	for _, txn := range pb.Block.Transactions {
-		err := validTransaction(tx, txn)
+	for i, txn := range pb.Block.Transactions {
+		err := validTransactionState(tx, txn, proofs[i])
 		if err != nil {
 			return err
 		}
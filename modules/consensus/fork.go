@@ -19,11 +19,14 @@ var (
 // the former.
 func backtrackToCurrentPath(tx *bolt.Tx, pb *processedBlock) []*processedBlock {
 	path := []*processedBlock{pb}
+	// The id is tracked separately from the block because the id of a pruned
+	// block cannot be computed from the block.
+	id := pb.Block.ID()
 	for {
 		// Error is not checked in production code - an error can only indicate
 		// that pb.Height > blockHeight(tx).
 		currentPathID, err := getPath(tx, pb.Height)
-		if currentPathID == pb.Block.ID() {
+		if currentPathID == id {
 			break
 		}
 		// Sanity check - an error should only indicate that pb.Height >
@@ -34,7 +37,8 @@ func backtrackToCurrentPath(tx *bolt.Tx, pb *processedBlock) []*processedBlock {
 
 		// Prepend the next block to the list of blocks leading from the
 		// current path to the input block.
-		pb, err = getBlockMap(tx, pb.Block.ParentID)
+		id = pb.Block.ParentID
+		pb, err = getBlockMap(tx, id)
 		if build.DEBUG && err != nil {
 			panic(err)
 		}
@@ -47,14 +51,15 @@ func backtrackToCurrentPath(tx *bolt.Tx, pb *processedBlock) []*processedBlock {
 // 'pb' is the current block. Blocks are returned in the order that they were
 // reverted.  'pb' is not reverted.
 func (cs *ConsensusSet) revertToBlock(tx *bolt.Tx, pb *processedBlock) (revertedBlocks []*processedBlock) {
-	// Sanity check - make sure that pb is in the current path.
+	// Sanity check - make sure that pb is in the current path. The id of a
+	// pruned block cannot be computed from the block.
 	currentPathID, err := getPath(tx, pb.Height)
-	if build.DEBUG && (err != nil || currentPathID != pb.Block.ID()) {
+	if build.DEBUG && (err != nil || (!isPruned(tx, currentPathID) && currentPathID != pb.Block.ID())) {
 		panic(errExternalRevert)
 	}
 
 	// Rewind blocks until 'pb' is the current block.
-	for currentBlockID(tx) != pb.Block.ID() {
+	for blockHeight(tx) > pb.Height {
 		block := currentProcessedBlock(tx)
 		commitDiffSet(tx, block, modules.DiffRevert)
 		revertedBlocks = append(revertedBlocks, block)
@@ -89,6 +94,7 @@ func (cs *ConsensusSet) applyUntilBlock(tx *bolt.Tx, pb *processedBlock) (applie
 			}
 		}
 		appliedBlocks = append(appliedBlocks, block)
+		cs.maybeSaveSnapshot(tx, block)
 
 		// Sanity check - after applying a block, check that the consensus set
 		// has maintained consistency.
@@ -107,6 +113,10 @@ func (cs *ConsensusSet) applyUntilBlock(tx *bolt.Tx, pb *processedBlock) (applie
 // updated if the function returns nil.
 func (cs *ConsensusSet) forkBlockchain(tx *bolt.Tx, newBlock *processedBlock) (revertedBlocks, appliedBlocks []*processedBlock, err error) {
 	commonParent := backtrackToCurrentPath(tx, newBlock)[0]
+	// Pruned blocks cannot be reverted, as their diffs have been discarded.
+	if commonParent.Height+1 < pruneHeight(tx) {
+		return nil, nil, errPrunedFork
+	}
 	revertedBlocks = cs.revertToBlock(tx, commonParent)
 	appliedBlocks, err = cs.applyUntilBlock(tx, newBlock)
 	if err != nil {
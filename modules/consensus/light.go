@@ -0,0 +1,654 @@
+package consensus
+
+// light.go implements the light consensus set, which follows the heaviest
+// chain using only block headers. A header is checked against the target of
+// its parent and the timestamps of its ancestors, which is enough to compute
+// the target of every block and the depth of every chain. The contents of the
+// blocks are never downloaded; the transactions relevant to a wallet are
+// fetched from full nodes through the SendTxnProofs RPC and verified against
+// the Merkle roots of the headers.
+//
+// The headers of the current path are stored in a flat file, at an offset of
+// 'height*BlockHeaderSize', so the light consensus set needs about 80 bytes of
+// disk per block.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	siasync "github.com/NebulousLabs/Sia/sync"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// lightHeadersFile is the file that stores the headers of the current
+	// path of a light consensus set.
+	lightHeadersFile = "headers.dat"
+
+	// lightLogFile is the log file of a light consensus set.
+	lightLogFile = "light.log"
+)
+
+var (
+	// lightSyncInterval is the amount of time that a light consensus set
+	// waits between synchronizations with its peers. New blocks are usually
+	// learned through RelayHeader in between.
+	lightSyncInterval = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 20 * time.Second
+		case "standard":
+			return 2 * time.Minute
+		case "testing":
+			return 250 * time.Millisecond
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	errBadTxnProof       = errors.New("peer sent a transaction proof that does not match the header chain")
+	errLightWrongGenesis = errors.New("headers file has wrong genesis block")
+	errNoTxnProofPeers   = errors.New("no peer was able to provide the transaction proofs")
+)
+
+// A headerNode is a header known to the light consensus set, together with
+// the values needed to validate its children and to compare its chain to
+// other chains.
+type headerNode struct {
+	header      types.BlockHeader
+	id          types.BlockID
+	height      types.BlockHeight
+	depth       types.Target
+	childTarget types.Target
+}
+
+// heavierThan returns true if the chain ending in 'hn' is heavier than the
+// chain ending in 'cmp' by the surpass threshold, like
+// processedBlock.heavierThan.
+func (hn *headerNode) heavierThan(cmp *headerNode) bool {
+	requirement := cmp.depth.AddDifficulties(cmp.childTarget.MulDifficulty(SurpassThreshold))
+	return requirement.Cmp(hn.depth) > 0 // Inversed, because the smaller target is actually heavier.
+}
+
+// proofsByPosition sorts transaction proofs by the position of their
+// transaction in the blockchain.
+type proofsByPosition []modules.TransactionProof
+
+func (p proofsByPosition) Len() int      { return len(p) }
+func (p proofsByPosition) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p proofsByPosition) Less(i, j int) bool {
+	if p[i].Height != p[j].Height {
+		return p[i].Height < p[j].Height
+	}
+	return p[i].ProofIndex < p[j].ProofIndex
+}
+
+// The LightConsensusSet follows the heaviest blockchain by validating block
+// headers only. It implements modules.LightConsensusSet.
+type LightConsensusSet struct {
+	gateway modules.Gateway
+
+	// nodes contains every valid header that has been seen, including the
+	// headers of chains that are not the current path. path contains the ids
+	// of the current path, indexed by height.
+	//
+	// Memory: nodes holds roughly 200 bytes per header, which is about 30 MB
+	// per 150,000 blocks.
+	nodes  map[types.BlockID]*headerNode
+	path   []types.BlockID
+	synced bool
+
+	headers    *os.File
+	log        *persist.Logger
+	mu         sync.RWMutex
+	persistDir string
+	tg         siasync.ThreadGroup
+}
+
+// NewLight returns a new LightConsensusSet. If there is an existing headers
+// file in the persist directory, it will be loaded. The light consensus set
+// synchronizes with the peers of the gateway in the background.
+func NewLight(gateway modules.Gateway, persistDir string) (*LightConsensusSet, error) {
+	// Check for nil dependencies.
+	if gateway == nil {
+		return nil, errNilGateway
+	}
+
+	genesis := &headerNode{
+		header:      types.GenesisBlock.Header(),
+		id:          types.GenesisID,
+		depth:       types.RootDepth,
+		childTarget: types.RootTarget,
+	}
+	lcs := &LightConsensusSet{
+		gateway: gateway,
+
+		nodes: map[types.BlockID]*headerNode{genesis.id: genesis},
+		path:  []types.BlockID{genesis.id},
+
+		persistDir: persistDir,
+	}
+	lcs.tg.SetName("light consensus")
+
+	err := lcs.initPersist()
+	if err != nil {
+		return nil, err
+	}
+
+	gateway.RegisterRPC("RelayHeader", lcs.threadedRPCRelayHeader)
+	gateway.RegisterRPC("SendBlocks", lcs.rpcUnsupported)
+	gateway.RegisterRPC("RelayTransactionSet", lcs.rpcUnsupported)
+	lcs.tg.OnStop(func() {
+		lcs.gateway.UnregisterRPC("RelayHeader")
+		lcs.gateway.UnregisterRPC("SendBlocks")
+		lcs.gateway.UnregisterRPC("RelayTransactionSet")
+	})
+	go lcs.threadedSyncLoop()
+
+	return lcs, nil
+}
+
+// initPersist creates the persist directory and logger, and loads the headers
+// file.
+func (lcs *LightConsensusSet) initPersist() error {
+	err := os.MkdirAll(lcs.persistDir, 0700)
+	if err != nil {
+		return err
+	}
+	lcs.log, err = persist.NewFileLogger(filepath.Join(lcs.persistDir, lightLogFile))
+	if err != nil {
+		return err
+	}
+	lcs.tg.AfterStop(func() {
+		err := lcs.log.Close()
+		if err != nil {
+			// State of the logger is unknown, a println will suffice.
+			fmt.Println("Error shutting down light consensus set logger:", err)
+		}
+	})
+
+	lcs.headers, err = os.OpenFile(filepath.Join(lcs.persistDir, lightHeadersFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	lcs.tg.AfterStop(func() {
+		err := lcs.headers.Close()
+		if err != nil {
+			lcs.log.Println("ERROR: Unable to close headers file at shutdown:", err)
+		}
+	})
+	return lcs.load()
+}
+
+// load replays the headers of the headers file. The headers are validated
+// again, and the file is truncated after the last valid header.
+func (lcs *LightConsensusSet) load() error {
+	data, err := ioutil.ReadAll(lcs.headers)
+	if err != nil {
+		return err
+	}
+	numHeaders := len(data) / types.BlockHeaderSize
+	for i := 0; i < numHeaders; i++ {
+		var h types.BlockHeader
+		err := encoding.Unmarshal(data[i*types.BlockHeaderSize:(i+1)*types.BlockHeaderSize], &h)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			if h.ID() != types.GenesisID {
+				return errLightWrongGenesis
+			}
+			continue
+		}
+		_, _, err = lcs.addHeader(h)
+		if err != nil {
+			lcs.log.Printf("WARN: discarding headers file after height %v: %v", i-1, err)
+			numHeaders = i
+			break
+		}
+	}
+	if numHeaders > len(lcs.path) {
+		numHeaders = len(lcs.path)
+	}
+	return lcs.saveHeaders(types.BlockHeight(numHeaders))
+}
+
+// saveHeaders writes the headers of the current path, starting at height
+// 'start', to the headers file, and removes any headers above the current
+// path.
+func (lcs *LightConsensusSet) saveHeaders(start types.BlockHeight) error {
+	var buf bytes.Buffer
+	for _, id := range lcs.path[start:] {
+		buf.Write(encoding.Marshal(lcs.nodes[id].header))
+	}
+	_, err := lcs.headers.WriteAt(buf.Bytes(), int64(start)*types.BlockHeaderSize)
+	if err != nil {
+		return err
+	}
+	err = lcs.headers.Truncate(int64(len(lcs.path)) * types.BlockHeaderSize)
+	if err != nil {
+		return err
+	}
+	return lcs.headers.Sync()
+}
+
+// minimumValidChildTimestamp returns the earliest timestamp that a child of
+// 'parent' can have. See stdBlockRuleHelper.minimumValidChildTimestamp.
+func (lcs *LightConsensusSet) minimumValidChildTimestamp(parent *headerNode) types.Timestamp {
+	windowTimes := make(types.TimestampSlice, types.MedianTimestampWindow)
+	windowTimes[0] = parent.header.Timestamp
+	current := parent
+	for i := uint64(1); i < types.MedianTimestampWindow; i++ {
+		// If the genesis block is reached, use its timestamp for all
+		// remaining times.
+		if current.height == 0 {
+			windowTimes[i] = windowTimes[i-1]
+			continue
+		}
+		current = lcs.nodes[current.header.ParentID]
+		windowTimes[i] = current.header.Timestamp
+	}
+	sort.Sort(windowTimes)
+	return windowTimes[len(windowTimes)/2]
+}
+
+// childTarget returns the target of the children of 'hn', whose parent is
+// 'parent'. See ConsensusSet.setChildTarget.
+func (lcs *LightConsensusSet) childTarget(parent, hn *headerNode) types.Target {
+	if hn.height%(types.TargetWindow/2) != 0 {
+		return parent.childTarget
+	}
+
+	// Grab the header that was generated 'TargetWindow' blocks prior to the
+	// parent, stopping at the genesis block.
+	var windowSize types.BlockHeight
+	current := hn
+	for windowSize = 0; windowSize < types.TargetWindow && current.height > 0; windowSize++ {
+		current = lcs.nodes[current.header.ParentID]
+	}
+	timePassed := hn.header.Timestamp - current.header.Timestamp
+	expectedTimePassed := types.BlockFrequency * windowSize
+	base := big.NewRat(int64(timePassed), int64(expectedTimePassed))
+
+	adjustedRatTarget := new(big.Rat).Mul(parent.childTarget.Rat(), clampTargetAdjustment(base))
+	return types.RatToTarget(adjustedRatTarget)
+}
+
+// addHeader validates a header and adds it to the set of known headers. If the
+// header creates a heavier chain, the current path is switched to that chain,
+// and the height of the last block that the old and new paths have in common
+// is returned.
+func (lcs *LightConsensusSet) addHeader(h types.BlockHeader) (forkHeight types.BlockHeight, pathChanged bool, err error) {
+	id := h.ID()
+	if _, exists := lcs.nodes[id]; exists {
+		return 0, false, modules.ErrBlockKnown
+	}
+	parent, exists := lcs.nodes[h.ParentID]
+	if !exists {
+		return 0, false, errOrphan
+	}
+	if !checkHeaderTarget(h, parent.childTarget) {
+		return 0, false, modules.ErrBlockUnsolved
+	}
+	if h.Timestamp < lcs.minimumValidChildTimestamp(parent) {
+		return 0, false, errEarlyTimestamp
+	}
+	if h.Timestamp > types.CurrentTimestamp()+types.FutureThreshold {
+		return 0, false, errFutureTimestamp
+	}
+
+	hn := &headerNode{
+		header: h,
+		id:     id,
+		height: parent.height + 1,
+		depth:  parent.depth.AddDifficulties(parent.childTarget),
+	}
+	hn.childTarget = lcs.childTarget(parent, hn)
+	lcs.nodes[id] = hn
+
+	current := lcs.nodes[lcs.path[len(lcs.path)-1]]
+	if !hn.heavierThan(current) {
+		return 0, false, nil
+	}
+
+	// Walk back from the new header to the current path, then replace the
+	// path above the fork.
+	var newIDs []types.BlockID
+	fork := hn
+	for fork.height >= types.BlockHeight(len(lcs.path)) || lcs.path[fork.height] != fork.id {
+		newIDs = append(newIDs, fork.id)
+		fork = lcs.nodes[fork.header.ParentID]
+	}
+	lcs.path = lcs.path[:fork.height+1]
+	for i := len(newIDs) - 1; i >= 0; i-- {
+		lcs.path = append(lcs.path, newIDs[i])
+	}
+	if fork.id != current.id {
+		lcs.log.Printf("Reorg at height %v: reverted %v headers, applied %v headers", fork.height, current.height-fork.height, len(newIDs))
+	}
+	return fork.height, true, nil
+}
+
+// managedAcceptHeaders adds a chain of headers to the light consensus set,
+// stopping at the first invalid header. Known headers are skipped. Returns
+// true if the current path changed.
+func (lcs *LightConsensusSet) managedAcceptHeaders(headers []types.BlockHeader) (pathChanged bool, err error) {
+	lcs.mu.Lock()
+	defer lcs.mu.Unlock()
+
+	saveStart := types.BlockHeight(len(lcs.path))
+	for _, h := range headers {
+		forkHeight, changed, addErr := lcs.addHeader(h)
+		if addErr == modules.ErrBlockKnown {
+			continue
+		} else if addErr != nil {
+			err = addErr
+			break
+		}
+		if changed && forkHeight+1 < saveStart {
+			saveStart = forkHeight + 1
+		}
+		pathChanged = pathChanged || changed
+	}
+	if pathChanged {
+		if saveErr := lcs.saveHeaders(saveStart); saveErr != nil {
+			lcs.log.Println("ERROR: unable to save headers:", saveErr)
+		}
+	}
+	return pathChanged, err
+}
+
+// blockHistory returns the ids of a sample of the blocks of the current path,
+// in the same layout as blockHistory.
+func (lcs *LightConsensusSet) blockHistory() (blockIDs [32]types.BlockID) {
+	height := types.BlockHeight(len(lcs.path) - 1)
+	step := types.BlockHeight(1)
+	for i := 0; i < 31; i++ {
+		blockIDs[i] = lcs.path[height]
+		if i >= 9 {
+			step *= 2
+		}
+		if height <= step {
+			break
+		}
+		height -= step
+	}
+	blockIDs[31] = lcs.path[0]
+	return blockIDs
+}
+
+// managedReceiveHeaders returns an RPCFunc that is the calling end of the
+// SendHeaders RPC. The received headers are stored in 'headers' without being
+// validated.
+func (lcs *LightConsensusSet) managedReceiveHeaders(headers *[]types.BlockHeader) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		if err := setSyncDeadline(conn); err != nil {
+			return err
+		}
+
+		lcs.mu.RLock()
+		history := lcs.blockHistory()
+		lcs.mu.RUnlock()
+		if err := encoding.WriteObject(conn, history); err != nil {
+			return err
+		}
+
+		var received []types.BlockHeader
+		moreAvailable := true
+		for moreAvailable {
+			var batch []types.BlockHeader
+			if err := encoding.ReadObject(conn, &batch, uint64(maxCatchUpHeaders)*types.BlockHeaderSize+8); err != nil {
+				return err
+			}
+			if err := encoding.ReadObject(conn, &moreAvailable, 1); err != nil {
+				return err
+			}
+			received = append(received, batch...)
+			if types.BlockHeight(len(received)) > maxSyncHeaders {
+				return errTooManyHeaders
+			}
+		}
+		*headers = received
+		return nil
+	}
+}
+
+// managedSyncPeer downloads headers from a peer until the peer has no more
+// headers that change the current path.
+func (lcs *LightConsensusSet) managedSyncPeer(addr modules.NetAddress) error {
+	for {
+		var headers []types.BlockHeader
+		err := lcs.gateway.RPC(addr, "SendHeaders", lcs.managedReceiveHeaders(&headers))
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return nil
+		}
+		pathChanged, err := lcs.managedAcceptHeaders(headers)
+		if err != nil {
+			return err
+		}
+		if !pathChanged {
+			return nil
+		}
+	}
+}
+
+// threadedSyncLoop periodically synchronizes the light consensus set with all
+// of its peers. The light consensus set is marked as synced after the first
+// round in which a peer was synchronized without error.
+func (lcs *LightConsensusSet) threadedSyncLoop() {
+	if err := lcs.tg.Add(); err != nil {
+		return
+	}
+	defer lcs.tg.Done()
+
+	for {
+		for _, p := range lcs.gateway.Peers() {
+			err := lcs.managedSyncPeer(p.NetAddress)
+			if err != nil {
+				lcs.log.Debugln("WARN: failed to sync headers with", p.NetAddress, err)
+				continue
+			}
+			lcs.mu.Lock()
+			lcs.synced = true
+			lcs.mu.Unlock()
+		}
+
+		select {
+		case <-lcs.tg.StopChan():
+			return
+		case <-time.After(lightSyncInterval):
+		}
+	}
+}
+
+// threadedRPCRelayHeader is an RPC that accepts a header relayed by a peer. If
+// the parent of the header is unknown, the headers are synchronized with the
+// peer.
+func (lcs *LightConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
+	if err := lcs.tg.Add(); err != nil {
+		return err
+	}
+	defer lcs.tg.Done()
+
+	var h types.BlockHeader
+	err := encoding.ReadObject(conn, &h, types.BlockHeaderSize)
+	if err != nil {
+		return err
+	}
+	_, err = lcs.managedAcceptHeaders([]types.BlockHeader{h})
+	if err == errOrphan {
+		// The gateway cannot be called from inside of an RPC, so the sync
+		// happens in a separate goroutine.
+		go func() {
+			if err := lcs.tg.Add(); err != nil {
+				return
+			}
+			defer lcs.tg.Done()
+			if err := lcs.managedSyncPeer(conn.RPCAddr()); err != nil {
+				lcs.log.Debugln("WARN: failed to get parents of orphan header:", err)
+			}
+		}()
+		return nil
+	} else if err == modules.ErrBlockKnown {
+		return nil
+	}
+	return err
+}
+
+// rpcUnsupported answers the RPCs that full nodes call on all of their peers,
+// but that a light consensus set cannot serve, by closing the connection.
+// Otherwise the gateway would treat them as unknown RPCs and penalize the full
+// node for calling them.
+func (lcs *LightConsensusSet) rpcUnsupported(conn modules.PeerConn) error {
+	return nil
+}
+
+// verifyTxnProofs checks that each proof of the response is a relevant
+// transaction in a block of the current path between 'start' and resp.Next.
+func (lcs *LightConsensusSet) verifyTxnProofs(resp txnProofResponse, start types.BlockHeight, addrs map[types.UnlockHash]struct{}) error {
+	lcs.mu.RLock()
+	defer lcs.mu.RUnlock()
+	for _, proof := range resp.Proofs {
+		if proof.Height < start || proof.Height >= resp.Next {
+			return errBadTxnProof
+		}
+		if proof.Height >= types.BlockHeight(len(lcs.path)) || lcs.path[proof.Height] != proof.BlockID {
+			return errBadTxnProof
+		}
+		header := lcs.nodes[proof.BlockID].header
+		if !header.VerifyTransactionProof(proof.Transaction, proof.ProofIndex, proof.NumLeaves, proof.HashSet) {
+			return errBadTxnProof
+		}
+		if !transactionRelevant(proof.Transaction, addrs) {
+			return errBadTxnProof
+		}
+	}
+	return nil
+}
+
+// Close safely closes the light consensus set.
+func (lcs *LightConsensusSet) Close() error {
+	return lcs.tg.Stop()
+}
+
+// CurrentHeader returns the header of the most recent block in the current
+// path, and its height.
+func (lcs *LightConsensusSet) CurrentHeader() (types.BlockHeader, types.BlockHeight) {
+	lcs.mu.RLock()
+	defer lcs.mu.RUnlock()
+	hn := lcs.nodes[lcs.path[len(lcs.path)-1]]
+	return hn.header, hn.height
+}
+
+// HeaderAtHeight returns the header of the block at the given height in the
+// current path.
+func (lcs *LightConsensusSet) HeaderAtHeight(height types.BlockHeight) (types.BlockHeader, bool) {
+	lcs.mu.RLock()
+	defer lcs.mu.RUnlock()
+	if height >= types.BlockHeight(len(lcs.path)) {
+		return types.BlockHeader{}, false
+	}
+	return lcs.nodes[lcs.path[height]].header, true
+}
+
+// RelevantTransactions fetches the transactions that spend from or pay to any
+// of the addresses, in blocks of the current path at or above height 'start'.
+// The proofs are returned in the order of the transactions in the blockchain.
+// Addresses are requested in batches of at most maxTxnProofAddresses, and a
+// transaction relevant to several batches is only returned once.
+func (lcs *LightConsensusSet) RelevantTransactions(addrs []types.UnlockHash, start types.BlockHeight) ([]modules.TransactionProof, error) {
+	if err := lcs.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer lcs.tg.Done()
+
+	type txnKey struct {
+		block types.BlockID
+		index uint64
+	}
+	var proofs []modules.TransactionProof
+	seen := make(map[txnKey]struct{})
+	for len(addrs) > 0 {
+		batch := addrs
+		if len(batch) > maxTxnProofAddresses {
+			batch = batch[:maxTxnProofAddresses]
+		}
+		addrs = addrs[len(batch):]
+		batchProofs, err := lcs.managedRelevantTransactions(batch, start)
+		if err != nil {
+			return nil, err
+		}
+		for _, proof := range batchProofs {
+			key := txnKey{proof.BlockID, proof.ProofIndex}
+			if _, exists := seen[key]; !exists {
+				seen[key] = struct{}{}
+				proofs = append(proofs, proof)
+			}
+		}
+	}
+	sort.Sort(proofsByPosition(proofs))
+	return proofs, nil
+}
+
+// managedRelevantTransactions fetches the transactions relevant to a batch of
+// addresses. The blocks are scanned by the peers in ranges; a peer that fails,
+// is behind, or sends an invalid proof is skipped for the remaining ranges.
+func (lcs *LightConsensusSet) managedRelevantTransactions(addrs []types.UnlockHash, start types.BlockHeight) ([]modules.TransactionProof, error) {
+	if len(addrs) > maxTxnProofAddresses {
+		return nil, errTooManyAddresses
+	}
+	addrSet := make(map[types.UnlockHash]struct{}, len(addrs))
+	for _, uh := range addrs {
+		addrSet[uh] = struct{}{}
+	}
+
+	var proofs []modules.TransactionProof
+	peers := lcs.gateway.Peers()
+	next := start
+	for {
+		_, height := lcs.CurrentHeader()
+		if next > height {
+			return proofs, nil
+		}
+		if len(peers) == 0 {
+			return nil, errNoTxnProofPeers
+		}
+
+		var resp txnProofResponse
+		req := txnProofRequest{Addresses: addrs, Start: next}
+		err := lcs.gateway.RPC(peers[0].NetAddress, "SendTxnProofs", receiveTxnProofs(req, &resp))
+		if err == nil {
+			err = lcs.verifyTxnProofs(resp, next, addrSet)
+		}
+		if err != nil || resp.Next <= next {
+			lcs.log.Debugln("WARN: failed to get transaction proofs from", peers[0].NetAddress, err)
+			peers = peers[1:]
+			continue
+		}
+		proofs = append(proofs, resp.Proofs...)
+		next = resp.Next
+	}
+}
+
+// Synced returns true if the light consensus set has synchronized its headers
+// with at least one peer.
+func (lcs *LightConsensusSet) Synced() bool {
+	lcs.mu.RLock()
+	defer lcs.mu.RUnlock()
+	return lcs.synced
+}
@@ -0,0 +1,123 @@
+package consensus
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestLightConsensusSet checks that a light consensus set follows the headers
+// of a full node, and that it can fetch and verify the transactions relevant
+// to an address.
+func TestLightConsensusSet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester("TestLightConsensusSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	testdir := build.TempDir(modules.ConsensusDir, "TestLightConsensusSetLight")
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	lcs, err := NewLight(g, filepath.Join(testdir, modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Connect(cst.gateway.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send siacoins to an address and sync the headers.
+	addr := randAddress()
+	_, err = cst.wallet.SendSiacoins(types.SiacoinPrecision, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lcs.managedSyncPeer(cst.gateway.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, height := lcs.CurrentHeader()
+	if height != cst.cs.Height() || header.ID() != cst.cs.CurrentBlock().ID() {
+		t.Fatalf("light consensus set is at height %v, full node is at height %v", height, cst.cs.Height())
+	}
+
+	// The transaction should be found in the last block.
+	proofs, err := lcs.RelevantTransactions([]types.UnlockHash{addr}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs) != 1 {
+		t.Fatalf("expected 1 relevant transaction, got %v", len(proofs))
+	}
+	if proofs[0].Height != height || proofs[0].BlockID != header.ID() {
+		t.Error("relevant transaction was reported in the wrong block")
+	}
+	proofs, err = lcs.RelevantTransactions([]types.UnlockHash{randAddress()}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs) != 0 {
+		t.Error("found transactions for an unused address")
+	}
+
+	// Tampered proofs should be rejected.
+	proofs, err = lcs.RelevantTransactions([]types.UnlockHash{addr}, height)
+	if err != nil || len(proofs) != 1 {
+		t.Fatal("expected 1 relevant transaction in the last block, got", len(proofs), err)
+	}
+	addrSet := map[types.UnlockHash]struct{}{addr: {}}
+	tampered := proofs[0]
+	tampered.Transaction.MinerFees = append(tampered.Transaction.MinerFees, types.NewCurrency64(1))
+	resp := txnProofResponse{Proofs: []modules.TransactionProof{tampered}, Next: height + 1}
+	if err := lcs.verifyTxnProofs(resp, height, addrSet); err != errBadTxnProof {
+		t.Error("expected errBadTxnProof for a tampered transaction, got", err)
+	}
+	tampered = proofs[0]
+	tampered.BlockID = types.BlockID{}
+	resp.Proofs = []modules.TransactionProof{tampered}
+	if err := lcs.verifyTxnProofs(resp, height, addrSet); err != errBadTxnProof {
+		t.Error("expected errBadTxnProof for an unknown block, got", err)
+	}
+	resp.Proofs = proofs
+	if err := lcs.verifyTxnProofs(resp, height, map[types.UnlockHash]struct{}{}); err != errBadTxnProof {
+		t.Error("expected errBadTxnProof for an irrelevant transaction, got", err)
+	}
+
+	// Orphan headers should be rejected.
+	orphan := header
+	orphan.ParentID = types.BlockID{1}
+	if _, err := lcs.managedAcceptHeaders([]types.BlockHeader{orphan}); err != errOrphan {
+		t.Error("expected errOrphan, got", err)
+	}
+
+	// The headers should be loaded again after a restart.
+	err = lcs.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lcs, err = NewLight(g, filepath.Join(testdir, modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lcs.Close()
+	if reloaded, h := lcs.CurrentHeader(); h != height || reloaded.ID() != header.ID() {
+		t.Fatalf("expected height %v after restart, got %v", height, h)
+	}
+}
@@ -0,0 +1,156 @@
+package consensus
+
+// parallel.go spreads the expensive parts of block validation, checking
+// transaction signatures and verifying storage proofs, across all CPUs. The
+// signatures of every transaction in the block are verified as a single
+// batch. The remaining validation happens serially in generateAndApplyDiff,
+// because transactions in a block may depend on each other.
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// A proofVerification is the result of verifying a storage proof in parallel.
+// A file contract can be revised earlier in the same block as its storage
+// proof, so the result also records the file contract and segment that the
+// proof was verified against, and is only reused if they have not changed.
+type proofVerification struct {
+	checked        bool
+	fileMerkleRoot crypto.Hash
+	fileSize       uint64
+	segmentIndex   uint64
+	verified       bool
+}
+
+// matches returns true if the proof was verified against the provided file
+// contract and segment.
+func (pv proofVerification) matches(fc types.FileContract, segmentIndex uint64) bool {
+	return pv.checked && pv.fileMerkleRoot == fc.FileMerkleRoot && pv.fileSize == fc.FileSize && pv.segmentIndex == segmentIndex
+}
+
+// parallelValidate calls fn for every index in [0, n) using one worker per
+// CPU. Once fn returns an error, the workers stop picking up new indices and
+// the error is returned.
+func parallelValidate(n int, fn func(int) error) error {
+	workers := runtime.NumCPU()
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var next int64 = -1
+	var failed int32
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				if err := fn(i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						atomic.StoreInt32(&failed, 1)
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// preverifyTransactions checks that each transaction of a block is
+// StandaloneValid, which includes checking the signatures, and verifies the
+// storage proofs against the consensus set as it was before the block. The
+// checks run in parallel and stop at the first invalid transaction, after
+// which the signatures of all transactions are verified in one batch. The
+// storage proof results are returned per transaction, to be used by
+// validTransactionState.
+//
+// An invalid storage proof does not stop the other checks, as the file
+// contract may still be revised by an earlier transaction in the block.
+func preverifyTransactions(tx *bolt.Tx, txns []types.Transaction) ([][]proofVerification, error) {
+	height := blockHeight(tx)
+	proofs := make([][]proofVerification, len(txns))
+
+	// Gather the file contracts and segments of the storage proofs up front,
+	// because the database transaction cannot be shared between goroutines.
+	// Proofs using the rules from before the 100e3 hardfork are left to
+	// validStorageProofs100e3.
+	type proofJob struct {
+		sp     types.StorageProof
+		fc     types.FileContract
+		result *proofVerification
+	}
+	var jobs []proofJob
+	pre100e3 := (build.Release == "standard" && height < 100e3) || (build.Release == "testing" && height < 10)
+	for i, t := range txns {
+		if pre100e3 || len(t.StorageProofs) == 0 {
+			continue
+		}
+		proofs[i] = make([]proofVerification, len(t.StorageProofs))
+		for j, sp := range t.StorageProofs {
+			segmentIndex, err := storageProofSegment(tx, sp.ParentID)
+			if err != nil {
+				continue
+			}
+			fc, err := getFileContract(tx, sp.ParentID)
+			if err != nil {
+				continue
+			}
+			proofs[i][j].segmentIndex = segmentIndex
+			jobs = append(jobs, proofJob{sp: sp, fc: fc, result: &proofs[i][j]})
+		}
+	}
+
+	// Indices below len(txns) check a transaction, the rest verify a storage
+	// proof.
+	batches := make([][]crypto.SignedHash, len(txns))
+	err := parallelValidate(len(txns)+len(jobs), func(i int) error {
+		if i < len(txns) {
+			var err error
+			batches[i], err = txns[i].StandaloneValidBatch(height)
+			return err
+		}
+		job := jobs[i-len(txns)]
+		job.result.fileMerkleRoot = job.fc.FileMerkleRoot
+		job.result.fileSize = job.fc.FileSize
+		job.result.verified = verifyStorageProof(job.sp, job.fc, job.result.segmentIndex)
+		job.result.checked = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var batch []crypto.SignedHash
+	for _, b := range batches {
+		batch = append(batch, b...)
+	}
+	err = crypto.VerifyBatch(batch)
+	if err != nil {
+		return nil, err
+	}
+	return proofs, nil
+}
@@ -0,0 +1,47 @@
+package consensus
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// TestParallelValidate checks that parallelValidate calls the validation
+// function for every index, and returns the first error.
+func TestParallelValidate(t *testing.T) {
+	var calls int64
+	seen := make([]int32, 100)
+	err := parallelValidate(len(seen), func(i int) error {
+		atomic.AddInt64(&calls, 1)
+		atomic.AddInt32(&seen[i], 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != int64(len(seen)) {
+		t.Fatalf("expected %v calls, got %v", len(seen), calls)
+	}
+	for i := range seen {
+		if seen[i] != 1 {
+			t.Fatalf("index %v was validated %v times", i, seen[i])
+		}
+	}
+
+	// An error from any index should be returned.
+	errBad := errors.New("bad index")
+	err = parallelValidate(1000, func(i int) error {
+		if i == 500 {
+			return errBad
+		}
+		return nil
+	})
+	if err != errBad {
+		t.Fatalf("expected %v, got %v", errBad, err)
+	}
+
+	// Validating nothing should succeed.
+	if err := parallelValidate(0, func(int) error { return errBad }); err != nil {
+		t.Fatal(err)
+	}
+}
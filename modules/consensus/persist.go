@@ -33,7 +33,11 @@ func (cs *ConsensusSet) loadDB() error {
 	return cs.db.Update(func(tx *bolt.Tx) error {
 		// Check if the database has been initialized.
 		if !dbInitialized(tx) {
-			return cs.initDB(tx)
+			err := cs.initDB(tx)
+			if err != nil {
+				return err
+			}
+			return initPruneDB(tx)
 		}
 
 		// Check that inconsistencies have not been detected in the database.
@@ -50,7 +54,9 @@ func (cs *ConsensusSet) loadDB() error {
 		if genesisID != cs.blockRoot.Block.ID() {
 			return errors.New("Blockchain has wrong genesis block, exiting.")
 		}
-		return nil
+		// Databases created before pruning was introduced do not have the
+		// pruning buckets.
+		return initPruneDB(tx)
 	})
 }
 
@@ -0,0 +1,146 @@
+package consensus
+
+// prune.go contains the logic for operating the consensus set in pruned mode.
+// In pruned mode, the transactions, miner payouts, and diffs of blocks in the
+// current path are discarded once the blocks are buried deeply enough. The
+// processed block is kept in the BlockMap so that the parent id, timestamp,
+// height, depth, and child target of each block remain available, and the
+// Merkle root of the discarded body is kept so that the header of each block
+// can still be reconstructed.
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// PrunedBlocks is a database bucket containing the Merkle roots of the
+	// blocks that have been pruned, keyed by their id.
+	PrunedBlocks = []byte("PrunedBlocks")
+
+	// PruneHeight is a database bucket that stores the height of the next
+	// block in the current path that will be pruned.
+	PruneHeight = []byte("PruneHeight")
+
+	// minPruneDepth is the minimum number of blocks that must be kept in full
+	// by a pruned consensus set. Reorgs that are deeper than the prune depth
+	// cannot be followed.
+	minPruneDepth = func() types.BlockHeight {
+		switch build.Release {
+		case "dev":
+			return 50
+		case "standard":
+			return 1000
+		case "testing":
+			return 5
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	errBlockPruned           = errors.New("block has been pruned")
+	errConsensusChangePruned = errors.New("consensus change refers to blocks that have been pruned")
+	errPruneDepthTooSmall    = errors.New("prune depth is too small")
+	errPrunedFork            = errors.New("block forks from a block that has been pruned")
+)
+
+// initPruneDB creates the pruning buckets if they do not exist yet. The
+// genesis block is never pruned, so pruning starts at height 1.
+func initPruneDB(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(PrunedBlocks)
+	if err != nil {
+		return err
+	}
+	ph, err := tx.CreateBucketIfNotExists(PruneHeight)
+	if err != nil {
+		return err
+	}
+	if ph.Get(PruneHeight) != nil {
+		return nil
+	}
+	return ph.Put(PruneHeight, encoding.Marshal(types.BlockHeight(1)))
+}
+
+// pruneHeight returns the height of the next block in the current path that
+// will be pruned.
+func pruneHeight(tx *bolt.Tx) types.BlockHeight {
+	var height types.BlockHeight
+	err := encoding.Unmarshal(tx.Bucket(PruneHeight).Get(PruneHeight), &height)
+	if build.DEBUG && err != nil {
+		panic(err)
+	}
+	return height
+}
+
+// isPruned returns true if the body of the block with the input id has been
+// pruned.
+func isPruned(tx *bolt.Tx, id types.BlockID) bool {
+	return tx.Bucket(PrunedBlocks).Get(id[:]) != nil
+}
+
+// getBlockHeader returns the header of the block with the input id, which is
+// available even if the block has been pruned.
+func getBlockHeader(tx *bolt.Tx, id types.BlockID) (types.BlockHeader, error) {
+	pb, err := getBlockMap(tx, id)
+	if err != nil {
+		return types.BlockHeader{}, err
+	}
+	rootBytes := tx.Bucket(PrunedBlocks).Get(id[:])
+	if rootBytes == nil {
+		return pb.Block.Header(), nil
+	}
+	h := types.BlockHeader{
+		ParentID:  pb.Block.ParentID,
+		Nonce:     pb.Block.Nonce,
+		Timestamp: pb.Block.Timestamp,
+	}
+	copy(h.MerkleRoot[:], rootBytes)
+	return h, nil
+}
+
+// pruneBlocks discards the bodies of all blocks in the current path that are
+// more than 'pruneDepth' blocks deep and have not been pruned yet.
+func (cs *ConsensusSet) pruneBlocks(tx *bolt.Tx) error {
+	if cs.pruneDepth == 0 {
+		return nil
+	}
+	height := blockHeight(tx)
+	next := pruneHeight(tx)
+	for ; next+cs.pruneDepth <= height; next++ {
+		id, err := getPath(tx, next)
+		if err != nil {
+			return err
+		}
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return err
+		}
+		root := pb.Block.MerkleRoot()
+		err = tx.Bucket(PrunedBlocks).Put(id[:], root[:])
+		if err != nil {
+			return err
+		}
+
+		// Discard the body and the diffs of the block. The block is stored
+		// under its original id, as the id can no longer be computed from
+		// the pruned block.
+		pb.Block.MinerPayouts = nil
+		pb.Block.Transactions = nil
+		pb.DiffsGenerated = false
+		pb.SiacoinOutputDiffs = nil
+		pb.FileContractDiffs = nil
+		pb.SiafundOutputDiffs = nil
+		pb.DelayedSiacoinOutputDiffs = nil
+		pb.SiafundPoolDiffs = nil
+		err = tx.Bucket(BlockMap).Put(id[:], encoding.Marshal(*pb))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(PruneHeight).Put(PruneHeight, encoding.Marshal(next))
+}
@@ -0,0 +1,88 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestNewPrunedDepth checks that NewPruned rejects prune depths that are
+// smaller than the minimum.
+func TestNewPrunedDepth(t *testing.T) {
+	_, err := NewPruned(nil, false, "", minPruneDepth-1)
+	if err != errPruneDepthTooSmall {
+		t.Fatalf("expected %v, got %v", errPruneDepthTooSmall, err)
+	}
+}
+
+// TestPruneBlocks checks that the bodies of deeply buried blocks are
+// discarded while their headers remain available.
+func TestPruneBlocks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester("TestPruneBlocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Record the id of the first block before pruning is enabled.
+	b1, exists := cst.cs.BlockAtHeight(1)
+	if !exists {
+		t.Fatal("block at height 1 does not exist")
+	}
+
+	// Enable pruning and mine enough blocks that block 1 is pruned.
+	cst.cs.pruneDepth = minPruneDepth
+	for i := types.BlockHeight(0); i <= minPruneDepth; i++ {
+		_, err := cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	height := cst.cs.Height()
+
+	// Pruned blocks should not be returned, but their headers should still
+	// be available.
+	if _, exists := cst.cs.BlockAtHeight(1); exists {
+		t.Error("pruned block was returned")
+	}
+	if _, exists := cst.cs.BlockAtHeight(height - minPruneDepth + 1); !exists {
+		t.Error("block within the prune depth was not returned")
+	}
+	if _, exists := cst.cs.BlockAtHeight(0); !exists {
+		t.Error("genesis block was pruned")
+	}
+	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+		if pruneHeight(tx) != height-minPruneDepth+1 {
+			t.Errorf("expected prune height %v, got %v", height-minPruneDepth+1, pruneHeight(tx))
+		}
+		h, err := getBlockHeader(tx, b1.ID())
+		if err != nil {
+			return err
+		}
+		if h.ID() != b1.ID() {
+			t.Error("header of pruned block does not match the block")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Subscribers that need the pruned blocks should be rejected.
+	ms := newMockSubscriber()
+	err = cst.cs.ConsensusSetSubscribe(&ms, modules.ConsensusChangeBeginning)
+	if err != errConsensusChangePruned {
+		t.Fatalf("expected %v, got %v", errConsensusChangePruned, err)
+	}
+	err = cst.cs.ConsensusSetSubscribe(&ms, modules.ConsensusChangeRecent)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
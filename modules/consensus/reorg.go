@@ -0,0 +1,84 @@
+package consensus
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// maxReorgHistory is the number of reorgs that the consensus set
+	// remembers. Older reorgs are discarded.
+	maxReorgHistory = func() int {
+		switch build.Release {
+		case "dev":
+			return 100
+		case "standard":
+			return 1000
+		case "testing":
+			return 5
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+)
+
+// recordReorg adds a reorg event to the reorg history if the change entry
+// reverts any blocks. The caller must hold the write lock.
+func (cs *ConsensusSet) recordReorg(ce changeEntry) {
+	if len(ce.RevertedBlocks) == 0 {
+		return
+	}
+	var height types.BlockHeight
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		height = blockHeight(tx)
+		return nil
+	})
+	cs.recordReorgAtHeight(ce, height)
+}
+
+// recordReorgAtHeight adds a reorg event to the reorg history, using 'height'
+// as the height of the consensus set after the change entry was applied. The
+// caller must hold the write lock.
+func (cs *ConsensusSet) recordReorgAtHeight(ce changeEntry, height types.BlockHeight) {
+	if len(ce.RevertedBlocks) == 0 {
+		return
+	}
+	cs.reorgIndex++
+	event := modules.ReorgEvent{
+		Index:          cs.reorgIndex,
+		Depth:          types.BlockHeight(len(ce.RevertedBlocks)),
+		ForkHeight:     height - types.BlockHeight(len(ce.AppliedBlocks)),
+		Height:         height,
+		RevertedBlocks: ce.RevertedBlocks,
+		AppliedBlocks:  ce.AppliedBlocks,
+		Timestamp:      types.CurrentTimestamp(),
+	}
+	cs.reorgs = append(cs.reorgs, event)
+	if len(cs.reorgs) > maxReorgHistory {
+		cs.reorgs = cs.reorgs[len(cs.reorgs)-maxReorgHistory:]
+	}
+	cs.log.Printf("INFO: reorg of depth %v at height %v, reverted %v blocks and applied %v blocks", event.Depth, event.ForkHeight, len(event.RevertedBlocks), len(event.AppliedBlocks))
+}
+
+// Reorgs returns the remembered reorgs that have an index greater than
+// 'since', oldest first.
+func (cs *ConsensusSet) Reorgs(since uint64) []modules.ReorgEvent {
+	err := cs.tg.Add()
+	if err != nil {
+		return nil
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	var events []modules.ReorgEvent
+	for _, event := range cs.reorgs {
+		if event.Index > since {
+			events = append(events, event)
+		}
+	}
+	return events
+}
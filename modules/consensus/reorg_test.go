@@ -0,0 +1,81 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestReorgs checks that reorgs are recorded by the consensus set.
+func TestReorgs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rs := createReorgSets("TestReorgs")
+	defer rs.Close()
+
+	// Blocks that extend the current path should not be recorded as reorgs.
+	_, err := rs.cstMain.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := rs.cstMain.cs.Reorgs(0); len(events) != 0 {
+		t.Fatal("expected no reorgs, got", len(events))
+	}
+
+	// Cause cstMain to reorg onto the path of cstAlt.
+	oldHeight := rs.cstMain.cs.dbBlockHeight()
+	oldID := rs.cstMain.cs.dbCurrentProcessedBlock().Block.ID()
+	rs.extend()
+	events := rs.cstMain.cs.Reorgs(0)
+	if len(events) != 1 {
+		t.Fatal("expected 1 reorg, got", len(events))
+	}
+	event := events[0]
+	if event.Index != 1 {
+		t.Error("wrong index:", event.Index)
+	}
+	if event.Depth != types.BlockHeight(len(event.RevertedBlocks)) || event.Depth != oldHeight-event.ForkHeight {
+		t.Error("wrong depth:", event.Depth, oldHeight, event.ForkHeight)
+	}
+	if event.RevertedBlocks[0] != oldID {
+		t.Error("first reverted block should be the old current block")
+	}
+	if event.Height != rs.cstMain.cs.dbBlockHeight() || event.AppliedBlocks[len(event.AppliedBlocks)-1] != rs.cstMain.cs.dbCurrentProcessedBlock().Block.ID() {
+		t.Error("last applied block should be the new current block")
+	}
+
+	// Reorgs that have already been seen should not be returned again.
+	if events := rs.cstMain.cs.Reorgs(event.Index); len(events) != 0 {
+		t.Fatal("expected no new reorgs, got", len(events))
+	}
+}
+
+// TestReorgHistoryLimit checks that only the most recent reorgs are
+// remembered.
+func TestReorgHistoryLimit(t *testing.T) {
+	cst, err := createConsensusSetTester("TestReorgHistoryLimit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cs := cst.cs
+
+	ce := changeEntry{
+		RevertedBlocks: []types.BlockID{{1}},
+		AppliedBlocks:  []types.BlockID{{2}},
+	}
+	cs.mu.Lock()
+	for i := 0; i < maxReorgHistory+2; i++ {
+		cs.recordReorg(ce)
+	}
+	cs.mu.Unlock()
+
+	events := cs.Reorgs(0)
+	if len(events) != maxReorgHistory {
+		t.Fatal("expected", maxReorgHistory, "reorgs, got", len(events))
+	}
+	if events[0].Index != 3 || events[len(events)-1].Index != uint64(maxReorgHistory+2) {
+		t.Error("wrong reorgs were discarded:", events[0].Index, events[len(events)-1].Index)
+	}
+}
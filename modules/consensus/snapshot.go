@@ -0,0 +1,419 @@
+package consensus
+
+// snapshot.go allows subscribers to join the consensus set without replaying
+// every consensus change since the genesis block. A snapshot subscriber is
+// sent a single consensus change containing the blocks that it has not seen
+// yet. A fresh subscriber receives diffs that add every object in the
+// consensus set that matches the subscriber's filter. A subscriber resuming
+// from an earlier consensus change instead receives the net diffs of the
+// blocks it missed, so that objects which were spent or revised in the
+// meantime are reverted.
+//
+// Snapshots are not accompanied by proofs. Block headers do not commit to the
+// state of the consensus set, so there is nothing a proof could be checked
+// against; the subscriber must trust the consensus set it is subscribing to.
+// Subscribers that cannot do so should fetch transaction proofs using the
+// light consensus set instead.
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	errSnapshotHeight = errors.New("snapshot cannot start above the current height")
+)
+
+// fileContractMatches returns true if any of the unlock hashes of the file
+// contract are accepted by the filter.
+func fileContractMatches(fc types.FileContract, filter modules.SnapshotFilter) bool {
+	if filter(fc.UnlockHash) {
+		return true
+	}
+	for _, sco := range fc.ValidProofOutputs {
+		if filter(sco.UnlockHash) {
+			return true
+		}
+	}
+	for _, sco := range fc.MissedProofOutputs {
+		if filter(sco.UnlockHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// computeSnapshot computes a snapshot consensus change containing the blocks
+// in the current path starting at height 'start'. If 'resume' is false, the
+// snapshot contains diffs that add every siacoin output, file contract,
+// siafund output, and delayed siacoin output in the consensus set that matches
+// the filter. If 'resume' is true, the subscriber already knows the consensus
+// set as of the block preceding 'start', and the snapshot contains the net
+// diffs of the blocks instead.
+func (cs *ConsensusSet) computeSnapshot(tx *bolt.Tx, start types.BlockHeight, resume bool, filter modules.SnapshotFilter) (modules.ConsensusChange, error) {
+	// A snapshot starting one block above the current height contains no
+	// blocks, only the objects in the consensus set.
+	height := blockHeight(tx)
+	if start > height+1 {
+		return modules.ConsensusChange{}, errSnapshotHeight
+	}
+	if filter == nil {
+		filter = func(types.UnlockHash) bool { return true }
+	}
+
+	// The snapshot shares the id of the most recent consensus change, so that
+	// the subscriber can resume from the snapshot using
+	// ConsensusSetSubscribe.
+	cc := modules.ConsensusChange{
+		Snapshot: true,
+	}
+	copy(cc.ID[:], tx.Bucket(ChangeLog).Get(ChangeLogTailID))
+
+	// Add the blocks that the subscriber has not seen yet.
+	var pbs []*processedBlock
+	for i := start; i <= height; i++ {
+		id, err := getPath(tx, i)
+		if err != nil {
+			return modules.ConsensusChange{}, err
+		}
+		if isPruned(tx, id) {
+			return modules.ConsensusChange{}, errConsensusChangePruned
+		}
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return modules.ConsensusChange{}, err
+		}
+		cc.AppliedBlocks = append(cc.AppliedBlocks, pb.Block)
+		pbs = append(pbs, pb)
+	}
+
+	var err error
+	if resume {
+		addResumeDiffs(&cc, pbs, getSiafundPool(tx), filter)
+	} else {
+		err = addSnapshotObjects(tx, &cc, height, filter)
+	}
+	if err != nil {
+		return modules.ConsensusChange{}, err
+	}
+
+	// Grab the child target and the minimum valid child timestamp.
+	pb, err := getBlockMap(tx, currentBlockID(tx))
+	if err != nil {
+		return modules.ConsensusChange{}, err
+	}
+	cc.ChildTarget = pb.ChildTarget
+	cc.MinimumValidChildTimestamp = cs.blockRuleHelper.minimumValidChildTimestamp(tx.Bucket(BlockMap), pb)
+	cc.Synced = cs.synced
+	return cc, nil
+}
+
+// addSnapshotObjects adds diffs to the consensus change that add every object
+// in the consensus set that matches the filter.
+func addSnapshotObjects(tx *bolt.Tx, cc *modules.ConsensusChange, height types.BlockHeight, filter modules.SnapshotFilter) error {
+	err := tx.Bucket(SiacoinOutputs).ForEach(func(k, v []byte) error {
+		var scod modules.SiacoinOutputDiff
+		err := encoding.Unmarshal(v, &scod.SiacoinOutput)
+		if err != nil {
+			return err
+		}
+		if !filter(scod.SiacoinOutput.UnlockHash) {
+			return nil
+		}
+		copy(scod.ID[:], k)
+		scod.Direction = modules.DiffApply
+		cc.SiacoinOutputDiffs = append(cc.SiacoinOutputDiffs, scod)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = tx.Bucket(FileContracts).ForEach(func(k, v []byte) error {
+		var fcd modules.FileContractDiff
+		err := encoding.Unmarshal(v, &fcd.FileContract)
+		if err != nil {
+			return err
+		}
+		if !fileContractMatches(fcd.FileContract, filter) {
+			return nil
+		}
+		copy(fcd.ID[:], k)
+		fcd.Direction = modules.DiffApply
+		cc.FileContractDiffs = append(cc.FileContractDiffs, fcd)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = tx.Bucket(SiafundOutputs).ForEach(func(k, v []byte) error {
+		var sfod modules.SiafundOutputDiff
+		err := encoding.Unmarshal(v, &sfod.SiafundOutput)
+		if err != nil {
+			return err
+		}
+		if !filter(sfod.SiafundOutput.UnlockHash) {
+			return nil
+		}
+		copy(sfod.ID[:], k)
+		sfod.Direction = modules.DiffApply
+		cc.SiafundOutputDiffs = append(cc.SiafundOutputDiffs, sfod)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := height + 1; i <= height+types.MaturityDelay; i++ {
+		dscoBucket := tx.Bucket(append(prefixDSCO, encoding.Marshal(i)...))
+		if dscoBucket == nil {
+			continue
+		}
+		err = dscoBucket.ForEach(func(k, v []byte) error {
+			dscod := modules.DelayedSiacoinOutputDiff{
+				Direction:      modules.DiffApply,
+				MaturityHeight: i,
+			}
+			err := encoding.Unmarshal(v, &dscod.SiacoinOutput)
+			if err != nil {
+				return err
+			}
+			if !filter(dscod.SiacoinOutput.UnlockHash) {
+				return nil
+			}
+			copy(dscod.ID[:], k)
+			cc.DelayedSiacoinOutputDiffs = append(cc.DelayedSiacoinOutputDiffs, dscod)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cc.SiafundPoolDiffs = []modules.SiafundPoolDiff{{
+		Direction: modules.DiffApply,
+		Adjusted:  getSiafundPool(tx),
+	}}
+	return nil
+}
+
+// addResumeDiffs adds the net diffs of the processed blocks to the consensus
+// change. An object that existed before the first block and was removed or
+// changed by the blocks is reverted, and an object that exists after the last
+// block and was added or changed by the blocks is applied. Objects that were
+// both added and removed by the blocks are left out. Reverts are ordered
+// before applies, so that a revised file contract is removed before its
+// revision is added.
+func addResumeDiffs(cc *modules.ConsensusChange, pbs []*processedBlock, pool types.Currency, filter modules.SnapshotFilter) {
+	// Siacoin outputs.
+	var scoIDs []types.SiacoinOutputID
+	firstSCOD := make(map[types.SiacoinOutputID]modules.SiacoinOutputDiff)
+	lastSCOD := make(map[types.SiacoinOutputID]modules.SiacoinOutputDiff)
+	for _, pb := range pbs {
+		for _, scod := range pb.SiacoinOutputDiffs {
+			if _, exists := firstSCOD[scod.ID]; !exists {
+				firstSCOD[scod.ID] = scod
+				scoIDs = append(scoIDs, scod.ID)
+			}
+			lastSCOD[scod.ID] = scod
+		}
+	}
+	for _, id := range scoIDs {
+		if scod := firstSCOD[id]; scod.Direction == modules.DiffRevert && filter(scod.SiacoinOutput.UnlockHash) {
+			cc.SiacoinOutputDiffs = append(cc.SiacoinOutputDiffs, scod)
+		}
+	}
+	for _, id := range scoIDs {
+		if scod := lastSCOD[id]; scod.Direction == modules.DiffApply && filter(scod.SiacoinOutput.UnlockHash) {
+			cc.SiacoinOutputDiffs = append(cc.SiacoinOutputDiffs, scod)
+		}
+	}
+
+	// File contracts.
+	var fcIDs []types.FileContractID
+	firstFCD := make(map[types.FileContractID]modules.FileContractDiff)
+	lastFCD := make(map[types.FileContractID]modules.FileContractDiff)
+	for _, pb := range pbs {
+		for _, fcd := range pb.FileContractDiffs {
+			if _, exists := firstFCD[fcd.ID]; !exists {
+				firstFCD[fcd.ID] = fcd
+				fcIDs = append(fcIDs, fcd.ID)
+			}
+			lastFCD[fcd.ID] = fcd
+		}
+	}
+	for _, id := range fcIDs {
+		if fcd := firstFCD[id]; fcd.Direction == modules.DiffRevert && fileContractMatches(fcd.FileContract, filter) {
+			cc.FileContractDiffs = append(cc.FileContractDiffs, fcd)
+		}
+	}
+	for _, id := range fcIDs {
+		if fcd := lastFCD[id]; fcd.Direction == modules.DiffApply && fileContractMatches(fcd.FileContract, filter) {
+			cc.FileContractDiffs = append(cc.FileContractDiffs, fcd)
+		}
+	}
+
+	// Siafund outputs.
+	var sfoIDs []types.SiafundOutputID
+	firstSFOD := make(map[types.SiafundOutputID]modules.SiafundOutputDiff)
+	lastSFOD := make(map[types.SiafundOutputID]modules.SiafundOutputDiff)
+	for _, pb := range pbs {
+		for _, sfod := range pb.SiafundOutputDiffs {
+			if _, exists := firstSFOD[sfod.ID]; !exists {
+				firstSFOD[sfod.ID] = sfod
+				sfoIDs = append(sfoIDs, sfod.ID)
+			}
+			lastSFOD[sfod.ID] = sfod
+		}
+	}
+	for _, id := range sfoIDs {
+		if sfod := firstSFOD[id]; sfod.Direction == modules.DiffRevert && filter(sfod.SiafundOutput.UnlockHash) {
+			cc.SiafundOutputDiffs = append(cc.SiafundOutputDiffs, sfod)
+		}
+	}
+	for _, id := range sfoIDs {
+		if sfod := lastSFOD[id]; sfod.Direction == modules.DiffApply && filter(sfod.SiafundOutput.UnlockHash) {
+			cc.SiafundOutputDiffs = append(cc.SiafundOutputDiffs, sfod)
+		}
+	}
+
+	// Delayed siacoin outputs.
+	var dscoIDs []types.SiacoinOutputID
+	firstDSCOD := make(map[types.SiacoinOutputID]modules.DelayedSiacoinOutputDiff)
+	lastDSCOD := make(map[types.SiacoinOutputID]modules.DelayedSiacoinOutputDiff)
+	for _, pb := range pbs {
+		for _, dscod := range pb.DelayedSiacoinOutputDiffs {
+			if _, exists := firstDSCOD[dscod.ID]; !exists {
+				firstDSCOD[dscod.ID] = dscod
+				dscoIDs = append(dscoIDs, dscod.ID)
+			}
+			lastDSCOD[dscod.ID] = dscod
+		}
+	}
+	for _, id := range dscoIDs {
+		if dscod := firstDSCOD[id]; dscod.Direction == modules.DiffRevert && filter(dscod.SiacoinOutput.UnlockHash) {
+			cc.DelayedSiacoinOutputDiffs = append(cc.DelayedSiacoinOutputDiffs, dscod)
+		}
+	}
+	for _, id := range dscoIDs {
+		if dscod := lastDSCOD[id]; dscod.Direction == modules.DiffApply && filter(dscod.SiacoinOutput.UnlockHash) {
+			cc.DelayedSiacoinOutputDiffs = append(cc.DelayedSiacoinOutputDiffs, dscod)
+		}
+	}
+
+	// The siafund pool only needs to be updated if one of the blocks changed
+	// it.
+	for _, pb := range pbs {
+		if len(pb.SiafundPoolDiffs) > 0 {
+			cc.SiafundPoolDiffs = []modules.SiafundPoolDiff{{
+				Direction: modules.DiffApply,
+				Previous:  pb.SiafundPoolDiffs[0].Previous,
+				Adjusted:  pool,
+			}}
+			break
+		}
+	}
+}
+
+// snapshotSubscribe sends a snapshot starting at height 'start' to the
+// subscriber, and then adds the subscriber to the list of subscribers. If
+// 'resume' is true, the snapshot contains the net diffs of the blocks instead
+// of every object in the consensus set.
+func (cs *ConsensusSet) snapshotSubscribe(subscriber modules.ConsensusSetSubscriber, start func(*bolt.Tx) (types.BlockHeight, bool, error), resume bool, filter modules.SnapshotFilter) error {
+	// Send any pending consensus changes to the existing subscribers, as the
+	// snapshot already contains them.
+	cs.flushPendingChanges()
+
+	err := cs.db.View(func(tx *bolt.Tx) error {
+		height, needed, err := start(tx)
+		if err != nil || !needed {
+			return err
+		}
+		cc, err := cs.computeSnapshot(tx, height, resume, filter)
+		if err != nil {
+			return err
+		}
+		subscriber.ProcessConsensusChange(cc)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cs.subscribers = append(cs.subscribers, subscriber)
+	return nil
+}
+
+// ConsensusSetSnapshotSubscribe adds a subscriber to the list of subscribers.
+// Instead of replaying every consensus change since the change with the
+// provided id, the subscriber is sent a single snapshot containing the blocks
+// in the current path that follow the change, and the net diffs of those
+// blocks for the objects that match the filter.
+//
+// Using modules.ConsensusChangeBeginning as the start will send a snapshot
+// containing every block in the current path, and diffs adding the objects in
+// the consensus set that match the filter. Using
+// modules.ConsensusChangeRecent will send a snapshot containing no blocks,
+// only the diffs adding the objects that match the filter. Using the id of the
+// most recent change will not send a snapshot at all.
+func (cs *ConsensusSet) ConsensusSetSnapshotSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID, filter modules.SnapshotFilter) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if start == modules.ConsensusChangeBeginning {
+		return cs.snapshotSubscribe(subscriber, func(*bolt.Tx) (types.BlockHeight, bool, error) {
+			return 0, true, nil
+		}, false, filter)
+	} else if start == modules.ConsensusChangeRecent {
+		return cs.snapshotSubscribe(subscriber, func(tx *bolt.Tx) (types.BlockHeight, bool, error) {
+			return blockHeight(tx) + 1, true, nil
+		}, false, filter)
+	}
+	return cs.snapshotSubscribe(subscriber, func(tx *bolt.Tx) (types.BlockHeight, bool, error) {
+
+		// The snapshot starts after the most recent block applied by the
+		// change. If that block is no longer in the current path, the
+		// subscriber has desynchronized and needs to perform a rescan.
+		entry, exists := getEntry(tx, start)
+		if !exists {
+			return 0, false, modules.ErrInvalidConsensusChangeID
+		}
+		tip := entry.AppliedBlocks[len(entry.AppliedBlocks)-1]
+		pb, err := getBlockMap(tx, tip)
+		if err != nil {
+			return 0, false, err
+		}
+		id, err := getPath(tx, pb.Height)
+		if err != nil || id != tip {
+			return 0, false, modules.ErrInvalidConsensusChangeID
+		}
+		return pb.Height + 1, pb.Height < blockHeight(tx), nil
+	}, true, filter)
+}
+
+// ConsensusSetSnapshotSubscribeHeight adds a subscriber to the list of
+// subscribers, sending it a snapshot containing the blocks in the current path
+// starting at the provided height, and diffs adding the objects in the
+// consensus set that match the filter.
+func (cs *ConsensusSet) ConsensusSetSnapshotSubscribeHeight(subscriber modules.ConsensusSetSubscriber, height types.BlockHeight, filter modules.SnapshotFilter) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.snapshotSubscribe(subscriber, func(tx *bolt.Tx) (types.BlockHeight, bool, error) {
+		if height > blockHeight(tx) {
+			return 0, false, errSnapshotHeight
+		}
+		return height, true, nil
+	}, false, filter)
+}
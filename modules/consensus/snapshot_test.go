@@ -0,0 +1,248 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSnapshotSubscribe checks that a snapshot subscriber receives the same
+// siacoin outputs as a subscriber that replays every consensus change, and
+// that it receives regular consensus changes afterwards.
+func TestSnapshotSubscribe(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester("TestSnapshotSubscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Replay every consensus change to determine the unspent siacoin
+	// outputs.
+	full := newMockSubscriber()
+	err = cst.cs.ConsensusSetSubscribe(&full, modules.ConsensusChangeBeginning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	for _, cc := range full.updates {
+		for _, scod := range cc.SiacoinOutputDiffs {
+			if scod.Direction == modules.DiffApply {
+				outputs[scod.ID] = scod.SiacoinOutput
+			} else {
+				delete(outputs, scod.ID)
+			}
+		}
+	}
+
+	// A snapshot from the beginning should contain every block and the same
+	// outputs.
+	snap := newMockSubscriber()
+	err = cst.cs.ConsensusSetSnapshotSubscribe(&snap, modules.ConsensusChangeBeginning, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.updates) != 1 {
+		t.Fatalf("expected 1 snapshot, got %v", len(snap.updates))
+	}
+	cc := snap.updates[0]
+	if !cc.Snapshot {
+		t.Error("snapshot is not marked as a snapshot")
+	}
+	if cc.ID != full.updates[len(full.updates)-1].ID {
+		t.Error("snapshot does not share the id of the most recent change")
+	}
+	if types.BlockHeight(len(cc.AppliedBlocks)) != cst.cs.Height()+1 {
+		t.Errorf("expected %v blocks, got %v", cst.cs.Height()+1, len(cc.AppliedBlocks))
+	}
+	if len(cc.SiacoinOutputDiffs) != len(outputs) {
+		t.Fatalf("expected %v outputs, got %v", len(outputs), len(cc.SiacoinOutputDiffs))
+	}
+	for _, scod := range cc.SiacoinOutputDiffs {
+		if _, exists := outputs[scod.ID]; !exists {
+			t.Error("snapshot contains an output that is not in the consensus set")
+		}
+	}
+
+	// A filtered snapshot should only contain matching outputs.
+	var uh types.UnlockHash
+	for _, sco := range outputs {
+		if sco.UnlockHash != (types.UnlockHash{}) {
+			uh = sco.UnlockHash
+			break
+		}
+	}
+	filtered := newMockSubscriber()
+	err = cst.cs.ConsensusSetSnapshotSubscribeHeight(&filtered, cst.cs.Height(), func(h types.UnlockHash) bool {
+		return h == uh
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc = filtered.updates[0]
+	if len(cc.AppliedBlocks) != 1 {
+		t.Errorf("expected 1 block, got %v", len(cc.AppliedBlocks))
+	}
+	if len(cc.SiacoinOutputDiffs) == 0 {
+		t.Error("filtered snapshot contains no outputs")
+	}
+	for _, scod := range cc.SiacoinOutputDiffs {
+		if scod.SiacoinOutput.UnlockHash != uh {
+			t.Error("filtered snapshot contains an output that does not match the filter")
+		}
+	}
+
+	// A snapshot from an earlier change should only contain the blocks that
+	// follow the change.
+	partial := newMockSubscriber()
+	err = cst.cs.ConsensusSetSnapshotSubscribe(&partial, full.updates[len(full.updates)-3].ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partial.updates[0].AppliedBlocks) != 2 {
+		t.Errorf("expected 2 blocks, got %v", len(partial.updates[0].AppliedBlocks))
+	}
+
+	// Subscribing from the most recent change should not send a snapshot.
+	recent := newMockSubscriber()
+	err = cst.cs.ConsensusSetSnapshotSubscribe(&recent, full.updates[len(full.updates)-1].ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent.updates) != 0 {
+		t.Errorf("expected no snapshot, got %v updates", len(recent.updates))
+	}
+
+	// Subscribing with ConsensusChangeRecent should send a snapshot of the
+	// consensus set without any blocks.
+	tip := newMockSubscriber()
+	err = cst.cs.ConsensusSetSnapshotSubscribe(&tip, modules.ConsensusChangeRecent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tip.updates) != 1 {
+		t.Fatalf("expected 1 snapshot, got %v", len(tip.updates))
+	}
+	if len(tip.updates[0].AppliedBlocks) != 0 {
+		t.Errorf("expected no blocks, got %v", len(tip.updates[0].AppliedBlocks))
+	}
+	if len(tip.updates[0].SiacoinOutputDiffs) != len(outputs) {
+		t.Errorf("expected %v outputs, got %v", len(outputs), len(tip.updates[0].SiacoinOutputDiffs))
+	}
+
+	// Snapshots cannot start above the current height.
+	err = cst.cs.ConsensusSetSnapshotSubscribeHeight(&recent, cst.cs.Height()+1, nil)
+	if err != errSnapshotHeight {
+		t.Errorf("expected %v, got %v", errSnapshotHeight, err)
+	}
+
+	// New blocks should be sent to snapshot subscribers as regular consensus
+	// changes.
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.updates) != 2 || snap.updates[1].Snapshot {
+		t.Error("snapshot subscriber did not receive a regular consensus change")
+	}
+	if len(recent.updates) != 1 {
+		t.Error("subscriber did not receive a regular consensus change")
+	}
+}
+
+// TestSnapshotSubscribeResume checks that a snapshot subscriber resuming from
+// an earlier consensus change is sent reverts for the outputs that were spent
+// since the change.
+func TestSnapshotSubscribeResume(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester("TestSnapshotSubscribeResume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// applyDiffs applies the siacoin output diffs of a consensus change to a
+	// set of outputs.
+	outputs := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	applyDiffs := func(cc modules.ConsensusChange) {
+		for _, scod := range cc.SiacoinOutputDiffs {
+			if scod.Direction == modules.DiffApply {
+				outputs[scod.ID] = scod.SiacoinOutput
+			} else {
+				delete(outputs, scod.ID)
+			}
+		}
+	}
+	full := newMockSubscriber()
+	err = cst.cs.ConsensusSetSubscribe(&full, modules.ConsensusChangeBeginning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cc := range full.updates {
+		applyDiffs(cc)
+	}
+	resumeID := full.updates[len(full.updates)-1].ID
+
+	// Spend some of the wallet's outputs in a new block.
+	_, err = cst.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	for id, sco := range outputs {
+		expected[id] = sco
+	}
+	for _, cc := range full.updates[len(full.updates)-1:] {
+		for _, scod := range cc.SiacoinOutputDiffs {
+			if scod.Direction == modules.DiffApply {
+				expected[scod.ID] = scod.SiacoinOutput
+			} else {
+				delete(expected, scod.ID)
+			}
+		}
+	}
+
+	// Resuming from the earlier change should revert the spent outputs and
+	// result in the same set of outputs.
+	resumed := newMockSubscriber()
+	err = cst.cs.ConsensusSetSnapshotSubscribe(&resumed, resumeID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.updates) != 1 {
+		t.Fatalf("expected 1 snapshot, got %v", len(resumed.updates))
+	}
+	cc := resumed.updates[0]
+	if len(cc.AppliedBlocks) != 1 {
+		t.Errorf("expected 1 block, got %v", len(cc.AppliedBlocks))
+	}
+	reverted := false
+	for _, scod := range cc.SiacoinOutputDiffs {
+		if scod.Direction == modules.DiffRevert {
+			reverted = true
+		}
+	}
+	if !reverted {
+		t.Error("snapshot does not revert the spent outputs")
+	}
+	applyDiffs(cc)
+	if len(outputs) != len(expected) {
+		t.Fatalf("expected %v outputs, got %v", len(expected), len(outputs))
+	}
+	for id := range expected {
+		if _, exists := outputs[id]; !exists {
+			t.Error("resumed snapshot is missing an output")
+		}
+	}
+}
@@ -1,17 +1,45 @@
 package consensus
 
 import (
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/NebulousLabs/bolt"
 )
 
+var (
+	// maxBatchedChanges is the maximum number of change entries that are
+	// merged into a single consensus change during IBD.
+	maxBatchedChanges = func() int {
+		switch build.Release {
+		case "dev":
+			return 20
+		case "standard":
+			return 100
+		case "testing":
+			return 3
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+)
+
 // computeConsensusChange computes the consensus change from the change entry
 // at index 'i' in the change log. If i is out of bounds, an error is returned.
 func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (modules.ConsensusChange, error) {
 	cc := modules.ConsensusChange{
 		ID: ce.ID(),
 	}
+	// The diffs of pruned blocks have been discarded, so a consensus change
+	// cannot be computed for them.
+	for _, ids := range [][]types.BlockID{ce.RevertedBlocks, ce.AppliedBlocks} {
+		for _, id := range ids {
+			if isPruned(tx, id) {
+				return modules.ConsensusChange{}, errConsensusChangePruned
+			}
+		}
+	}
 	for _, revertedBlockID := range ce.RevertedBlocks {
 		revertedBlock, err := getBlockMap(tx, revertedBlockID)
 		if err != nil {
@@ -89,17 +117,61 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 	return cc, nil
 }
 
+// computeBatchedConsensusChange merges the consensus changes of several
+// consecutive change entries into a single consensus change. Only the first
+// entry may revert blocks, otherwise the merged change would need to revert
+// blocks after applying others.
+func (cs *ConsensusSet) computeBatchedConsensusChange(tx *bolt.Tx, entries []changeEntry) (modules.ConsensusChange, error) {
+	var batch modules.ConsensusChange
+	for i, ce := range entries {
+		if build.DEBUG && i > 0 && len(ce.RevertedBlocks) > 0 {
+			panic("only the first entry of a batch may revert blocks")
+		}
+		cc, err := cs.computeConsensusChange(tx, ce)
+		if err != nil {
+			return modules.ConsensusChange{}, err
+		}
+		batch.RevertedBlocks = append(batch.RevertedBlocks, cc.RevertedBlocks...)
+		batch.AppliedBlocks = append(batch.AppliedBlocks, cc.AppliedBlocks...)
+		batch.SiacoinOutputDiffs = append(batch.SiacoinOutputDiffs, cc.SiacoinOutputDiffs...)
+		batch.FileContractDiffs = append(batch.FileContractDiffs, cc.FileContractDiffs...)
+		batch.SiafundOutputDiffs = append(batch.SiafundOutputDiffs, cc.SiafundOutputDiffs...)
+		batch.DelayedSiacoinOutputDiffs = append(batch.DelayedSiacoinOutputDiffs, cc.DelayedSiacoinOutputDiffs...)
+		batch.SiafundPoolDiffs = append(batch.SiafundPoolDiffs, cc.SiafundPoolDiffs...)
+
+		// The id, targets and synced status of the batch are those of the
+		// most recent change, so that subscribers can resume from it.
+		batch.ID = cc.ID
+		batch.ChildTarget = cc.ChildTarget
+		batch.MinimumValidChildTimestamp = cc.MinimumValidChildTimestamp
+		batch.Synced = cc.Synced
+	}
+	return batch, nil
+}
+
 // readLockUpdateSubscribers will inform all subscribers of a new update to the
-// consensus set. readlockUpdateSubscribers does not alter the changelog, the
-// changelog must be updated beforehand.
-func (cs *ConsensusSet) readlockUpdateSubscribers(ce changeEntry) {
+// consensus set. If multiple change entries are provided, they are sent as a
+// single consensus change. readlockUpdateSubscribers does not alter the
+// changelog, the changelog must be updated beforehand.
+func (cs *ConsensusSet) readlockUpdateSubscribers(entries []changeEntry) {
 	// Get the consensus change and send it to all subscribers.
 	var cc modules.ConsensusChange
+	var tip types.BlockHeight
 	err := cs.db.View(func(tx *bolt.Tx) error {
 		// Compute the consensus change so it can be sent to subscribers.
 		var err error
-		cc, err = cs.computeConsensusChange(tx, ce)
-		return err
+		cc, err = cs.computeBatchedConsensusChange(tx, entries)
+		if err != nil {
+			return err
+		}
+		// The batch may lag behind the current block, so the height of its
+		// most recent block is looked up directly.
+		pb, err := getBlockMap(tx, cc.AppliedBlocks[len(cc.AppliedBlocks)-1].ID())
+		if err != nil {
+			return err
+		}
+		tip = pb.Height
+		return nil
 	})
 	if err != nil {
 		cs.log.Critical("computeConsensusChange failed:", err)
@@ -108,6 +180,74 @@ func (cs *ConsensusSet) readlockUpdateSubscribers(ce changeEntry) {
 	for _, subscriber := range cs.subscribers {
 		subscriber.ProcessConsensusChange(cc)
 	}
+
+	for i, b := range cc.AppliedBlocks {
+		cs.events.Publish(modules.EventBlockConnected, modules.BlockConnectedEvent{
+			ID:        b.ID(),
+			Height:    tip - types.BlockHeight(len(cc.AppliedBlocks)-1-i),
+			Timestamp: b.Timestamp,
+		})
+	}
+}
+
+// SubscribeEvents returns a channel that receives an event for each block
+// that joins the current path, and a function that ends the subscription.
+func (cs *ConsensusSet) SubscribeEvents() (<-chan modules.Event, func()) {
+	return cs.events.Subscribe()
+}
+
+// queueChangeEntry adds a change entry to the batch of pending consensus
+// changes, and returns the change entries that should be sent to subscribers
+// now. Unless batchChanges is set, the entry is returned immediately.
+func (cs *ConsensusSet) queueChangeEntry(ce changeEntry) (ready []changeEntry) {
+	if !cs.batchChanges {
+		return []changeEntry{ce}
+	}
+
+	// Reverted blocks can only appear at the start of a batch, so an entry
+	// that reverts blocks starts a new batch.
+	if len(ce.RevertedBlocks) > 0 {
+		ready = cs.pendingChanges
+		cs.pendingChanges = nil
+	}
+	cs.pendingChanges = append(cs.pendingChanges, ce)
+
+	// Send full batches, holding back the most recent entry. This ensures
+	// that the batch sent at the end of IBD is never empty, so that
+	// subscribers always receive a change that is marked as synced.
+	if len(cs.pendingChanges) > maxBatchedChanges {
+		last := len(cs.pendingChanges) - 1
+		ready = cs.pendingChanges[:last]
+		cs.pendingChanges = []changeEntry{cs.pendingChanges[last]}
+	}
+	return ready
+}
+
+// flushPendingChanges sends the batch of pending consensus changes to all
+// subscribers. The caller must hold the write lock.
+func (cs *ConsensusSet) flushPendingChanges() {
+	if len(cs.pendingChanges) == 0 {
+		return
+	}
+	pending := cs.pendingChanges
+	cs.pendingChanges = nil
+	cs.readlockUpdateSubscribers(pending)
+}
+
+// managedMarkSynced marks the consensus set as synced with the network, stops
+// batching consensus changes, and sends any pending consensus changes to the
+// subscribers. The final change is marked as synced.
+func (cs *ConsensusSet) managedMarkSynced() {
+	cs.mu.Lock()
+	cs.synced = true
+	cs.batchChanges = false
+	pending := cs.pendingChanges
+	cs.pendingChanges = nil
+	cs.mu.Demote()
+	defer cs.mu.DemotedUnlock()
+	if len(pending) > 0 {
+		cs.readlockUpdateSubscribers(pending)
+	}
 }
 
 // initializeSubscribe will take a subscriber and feed them all of the
@@ -180,6 +320,10 @@ func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSub
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	// Send any pending consensus changes to the existing subscribers, as the
+	// new subscriber will receive them during initialization.
+	cs.flushPendingChanges()
+
 	// Get the input module caught up to the currenct consnesus set.
 	cs.subscribers = append(cs.subscribers, subscriber)
 	err = cs.initializeSubscribe(subscriber, start)
@@ -2,8 +2,10 @@ package consensus
 
 import (
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // mockSubscriber receives and holds changes to the consensus set, remembering
@@ -148,3 +150,93 @@ func TestUnsubscribe(t *testing.T) {
 		t.Error("mock subscriber was not correctly unsubscribed")
 	}
 }
+
+// TestBatchedConsensusChanges checks that consensus changes are sent to
+// subscribers in batches during IBD, and that the pending changes are sent
+// when the consensus set is marked as synced.
+func TestBatchedConsensusChanges(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := createConsensusSetTester("TestBatchedConsensusChanges1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester("TestBatchedConsensusChanges2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Wait for cst2 to finish its startup, then put it back into IBD.
+	for !cst2.cs.Synced() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ms := newMockSubscriber()
+	err = cst2.cs.ConsensusSetSubscribe(&ms, modules.ConsensusChangeRecent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cst2.cs.mu.Lock()
+	cst2.cs.synced = false
+	cst2.cs.batchChanges = true
+	cst2.cs.mu.Unlock()
+
+	// Give cst2 the blocks of cst1, plus two full batches.
+	var blocks []types.Block
+	for i := types.BlockHeight(1); i <= cst1.cs.Height(); i++ {
+		b, _ := cst1.cs.BlockAtHeight(i)
+		blocks = append(blocks, b)
+	}
+	for len(blocks) < 2*maxBatchedChanges+1 {
+		b, err := cst1.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b)
+	}
+	for _, b := range blocks {
+		err = cst2.cs.managedAcceptBlock(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	numBatches := (len(blocks) - 1) / maxBatchedChanges
+	if len(ms.updates) != numBatches {
+		t.Fatalf("expected %v batches, got %v", numBatches, len(ms.updates))
+	}
+	for _, cc := range ms.updates {
+		if len(cc.AppliedBlocks) != maxBatchedChanges {
+			t.Fatalf("expected %v blocks per batch, got %v", maxBatchedChanges, len(cc.AppliedBlocks))
+		}
+		if cc.Synced {
+			t.Fatal("batch was marked as synced during IBD")
+		}
+	}
+
+	// Marking the consensus set as synced should send the remaining blocks.
+	cst2.cs.managedMarkSynced()
+	var numBlocks int
+	for _, cc := range ms.updates {
+		numBlocks += len(cc.AppliedBlocks)
+	}
+	if numBlocks != len(blocks) {
+		t.Fatalf("expected %v blocks, got %v", len(blocks), numBlocks)
+	}
+	last := ms.updates[len(ms.updates)-1]
+	if !last.Synced {
+		t.Fatal("final change was not marked as synced")
+	}
+
+	// Subscribers should be able to resume from the id of a batch.
+	tail := newMockSubscriber()
+	err = cst2.cs.ConsensusSetSubscribe(&tail, last.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tail.updates) != 0 {
+		t.Fatal("subscriber resuming from the latest batch received changes")
+	}
+}
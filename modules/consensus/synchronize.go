@@ -2,7 +2,6 @@ package consensus
 
 import (
 	"errors"
-	"net"
 	"sync"
 	"time"
 
@@ -83,6 +82,7 @@ var (
 		}
 	}()
 
+	errNotSynced         = errors.New("consensus set is not synced with the network")
 	errEarlyStop         = errors.New("initial blockchain download did not complete by the time shutdown was issued")
 	errSendBlocksStalled = errors.New("SendBlocks RPC timed and never received any blocks")
 )
@@ -135,13 +135,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 	// Set a deadline after which SendBlocks will timeout. During IBD, esepcially,
 	// SendBlocks will timeout. This is by design so that IBD switches peers to
 	// prevent any one peer from stalling IBD.
-	err := conn.SetDeadline(time.Now().Add(sendBlocksTimeout))
-	// Ignore errors returned by SetDeadline if the conn is a pipe in testing.
-	// Pipes do not support Set{,Read,Write}Deadline and should only be used in
-	// testing.
-	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "set" && opErr.Net == "pipe" && build.Release == "testing" {
-		err = nil
-	}
+	err := setSyncDeadline(conn)
 	if err != nil {
 		return err
 	}
@@ -213,25 +207,18 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 			return err
 		}
 
-		// Integrate the blocks into the consensus set.
-		for _, block := range newBlocks {
+		// Integrate the blocks into the consensus set. managedAcceptBlocks is
+		// used instead of AcceptBlock so as not to broadcast every block.
+		// Blocks that do not extend the current path or are already in the
+		// database are skipped.
+		if len(newBlocks) > 0 {
 			stalled = false
-			// Call managedAcceptBlock instead of AcceptBlock so as not to broadcast
-			// every block.
-			acceptErr := cs.managedAcceptBlock(block)
-			// Set a flag to indicate that we should broadcast the last block received.
-			if acceptErr == nil {
-				chainExtended = true
-			}
-			// ErrNonExtendingBlock must be ignored until headers-first block
-			// sharing is implemented, block already in database should also be
-			// ignored.
-			if acceptErr == modules.ErrNonExtendingBlock || acceptErr == modules.ErrBlockKnown {
-				acceptErr = nil
-			}
-			if acceptErr != nil {
-				return acceptErr
-			}
+		}
+		extended, acceptErr := cs.managedAcceptBlocks(newBlocks)
+		// Set a flag to indicate that we should broadcast the last block received.
+		chainExtended = chainExtended || extended
+		if acceptErr != nil {
+			return acceptErr
 		}
 	}
 	return nil
@@ -239,11 +226,11 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 
 // threadedReceiveBlocks is the calling end of the SendBlocks RPC.
 func (cs *ConsensusSet) threadedReceiveBlocks(conn modules.PeerConn) error {
-	err := cs.tg.Add()
+	err := cs.tg.AddNamed("threadedReceiveBlocks")
 	if err != nil {
 		return err
 	}
-	defer cs.tg.Done()
+	defer cs.tg.DoneNamed("threadedReceiveBlocks")
 	return cs.managedReceiveBlocks(conn)
 }
 
@@ -268,31 +255,18 @@ func (cs *ConsensusSet) rpcSendBlocks(conn modules.PeerConn) error {
 	}
 
 	// Find the most recent block from knownBlocks in the current path.
-	found := false
 	var start types.BlockHeight
-	var csHeight types.BlockHeight
+	var found bool
 	cs.mu.RLock()
 	err = cs.db.View(func(tx *bolt.Tx) error {
-		csHeight = blockHeight(tx)
-		for _, id := range knownBlocks {
-			pb, err := getBlockMap(tx, id)
+		start, found = pathStart(tx, knownBlocks)
+		// Pruned blocks cannot be sent.
+		if found {
+			id, err := getPath(tx, start)
 			if err != nil {
-				continue
-			}
-			pathID, err := getPath(tx, pb.Height)
-			if err != nil {
-				continue
-			}
-			if pathID != pb.Block.ID() {
-				continue
-			}
-			if pb.Height == csHeight {
-				break
+				return err
 			}
-			found = true
-			// Start from the child of the common block.
-			start = pb.Height + 1
-			break
+			found = !isPruned(tx, id)
 		}
 		return nil
 	})
@@ -367,7 +341,7 @@ func (cs *ConsensusSet) rpcRelayBlock(conn modules.PeerConn) error {
 	var b types.Block
 	err = encoding.ReadObject(conn, &b, types.BlockSizeLimit)
 	if err != nil {
-		return err
+		return modules.DecodeViolation(err)
 	}
 
 	// Submit the block to the consensus set and broadcast it.
@@ -384,15 +358,34 @@ func (cs *ConsensusSet) rpcRelayBlock(conn modules.PeerConn) error {
 		}()
 	}
 	if err != nil {
-		return err
+		return cs.managedBlockViolation(b.ID(), err)
 	}
 	cs.managedBroadcastBlock(b)
 	return nil
 }
 
+// managedBlockViolation marks the error returned when accepting a block or
+// header relayed by a peer as a protocol violation if the block is invalid.
+// Blocks that are already known, orphaned, in the near future, or rejected
+// because of a problem with the consensus set itself are not the peer's
+// fault.
+func (cs *ConsensusSet) managedBlockViolation(id types.BlockID, err error) error {
+	switch err {
+	case errDoSBlock, errEarlyTimestamp, errExtremeFutureTimestamp, errLargeBlock, errBadMinerPayouts, modules.ErrBlockUnsolved:
+		return modules.NewProtocolViolation(err)
+	}
+	cs.mu.RLock()
+	_, invalid := cs.dosBlocks[id]
+	cs.mu.RUnlock()
+	if invalid {
+		return modules.NewProtocolViolation(err)
+	}
+	return err
+}
+
 // threadedRPCRelayHeader is an RPC that accepts a block header from a peer.
 func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
-	err := cs.tg.Add()
+	err := cs.tg.AddNamed("threadedRPCRelayHeader")
 	if err != nil {
 		return err
 	}
@@ -400,7 +393,7 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 	defer func() {
 		go func() {
 			wg.Wait()
-			cs.tg.Done()
+			cs.tg.DoneNamed("threadedRPCRelayHeader")
 		}()
 	}()
 
@@ -408,7 +401,7 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 	var h types.BlockHeader
 	err = encoding.ReadObject(conn, &h, types.BlockHeaderSize)
 	if err != nil {
-		return err
+		return modules.DecodeViolation(err)
 	}
 
 	// Start verification inside of a bolt View tx.
@@ -439,7 +432,7 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 		}()
 		return nil
 	} else if err != nil {
-		return err
+		return cs.managedBlockViolation(h.ID(), err)
 	}
 
 	// If the header is valid and extends the heaviest chain, fetch the
@@ -454,7 +447,7 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 	// adjusted.
 	wg.Add(1)
 	go func() {
-		err = cs.gateway.RPC(conn.RPCAddr(), "SendBlk", cs.managedReceiveBlock(h.ID()))
+		err := cs.managedFetchRelayedBlock(conn.RPCAddr(), h.ID())
 		if err != nil {
 			cs.log.Debugln("WARN: failed to get header's corresponding block:", err)
 		}
@@ -478,17 +471,7 @@ func (cs *ConsensusSet) rpcSendBlk(conn modules.PeerConn) error {
 		return err
 	}
 	// Lookup the corresponding block.
-	var b types.Block
-	cs.mu.RLock()
-	err = cs.db.View(func(tx *bolt.Tx) error {
-		pb, err := getBlockMap(tx, id)
-		if err != nil {
-			return err
-		}
-		b = pb.Block
-		return nil
-	})
-	cs.mu.RUnlock()
+	b, err := cs.managedRelayableBlock(id)
 	if err != nil {
 		return err
 	}
@@ -500,6 +483,26 @@ func (cs *ConsensusSet) rpcSendBlk(conn modules.PeerConn) error {
 	return nil
 }
 
+// managedRelayableBlock returns the block with the given id, unless the block
+// is unknown or has been pruned.
+func (cs *ConsensusSet) managedRelayableBlock(id types.BlockID) (types.Block, error) {
+	var b types.Block
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	err := cs.db.View(func(tx *bolt.Tx) error {
+		if isPruned(tx, id) {
+			return errBlockPruned
+		}
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return err
+		}
+		b = pb.Block
+		return nil
+	})
+	return b, err
+}
+
 // managedReceiveBlock takes a block id and returns an RPCFunc that requests that
 // block and then calls AcceptBlock on it. The returned function should be used
 // as the calling end of the SendBlk RPC. Note that although the function
@@ -522,9 +525,11 @@ func (cs *ConsensusSet) managedReceiveBlock(id types.BlockID) modules.RPCFunc {
 	}
 }
 
-// threadedInitialBlockchainDownload performs the IBD on outbound peers. Blocks
+// threadedInitialBlockchainDownload performs the IBD on outbound peers. Headers
 // are downloaded from one peer at a time in 5 minute intervals, so as to
-// prevent any one peer from significantly slowing down IBD.
+// prevent any one peer from significantly slowing down IBD. Once a chain of
+// headers has been validated, the corresponding blocks are downloaded in
+// parallel from all outbound peers.
 //
 // NOTE: IBD will succeed right now when each peer has a different blockchain.
 // The height and the block id of the remote peers' current blocks are not
@@ -544,13 +549,19 @@ func (cs *ConsensusSet) threadedInitialBlockchainDownload() error {
 	for {
 		numOutboundSynced = 0
 		numOutboundNotSynced = 0
+		// We only sync on outbound peers at first to make IBD less susceptible to
+		// fast-mining and other attacks, as outbound peers are more difficult to
+		// manipulate.
+		var outbound []modules.Peer
 		for _, p := range cs.gateway.Peers() {
-			// We only sync on outbound peers at first to make IBD less susceptible to
-			// fast-mining and other attacks, as outbound peers are more difficult to
-			// manipulate.
-			if p.Inbound {
-				continue
+			if !p.Inbound {
+				outbound = append(outbound, p)
 			}
+		}
+		for i, p := range outbound {
+			// Headers are requested from p, and the blocks are downloaded
+			// from all outbound peers, starting with p.
+			peers := append(append([]modules.Peer{p}, outbound[:i]...), outbound[i+1:]...)
 
 			// Put the rest of the iteration inside of a thread group.
 			err := func() error {
@@ -560,9 +571,16 @@ func (cs *ConsensusSet) threadedInitialBlockchainDownload() error {
 				}
 				defer cs.tg.Done()
 
-				// Request blocks from the peer. The error returned will only be
-				// 'nil' if there are no more blocks to receive.
-				err = cs.gateway.RPC(p.NetAddress, "SendBlocks", cs.managedReceiveBlocks)
+				// Request headers from the peer and download the corresponding
+				// blocks. The error returned will only be 'nil' if there are no
+				// more blocks to receive.
+				receivedHeaders, err := cs.managedHeadersFirstSync(p, peers)
+				// COMPATv1.0.3 - peers that do not support the SendHeaders RPC
+				// will close the stream without sending any headers, in which
+				// case the blocks are requested using SendBlocks.
+				if err != nil && !receivedHeaders {
+					err = cs.gateway.RPC(p.NetAddress, "SendBlocks", cs.managedReceiveBlocks)
+				}
 				if err == nil {
 					numOutboundSynced++
 					// In this case, 'return nil' is equivalent to skipping to
@@ -629,3 +647,12 @@ func (cs *ConsensusSet) Synced() bool {
 	defer cs.mu.RUnlock()
 	return cs.synced
 }
+
+// Healthy returns an error if the consensus set has not caught up to the
+// rest of the network.
+func (cs *ConsensusSet) Healthy() error {
+	if !cs.Synced() {
+		return errNotSynced
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+package consensus
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// maxParallelBlockDownloads is the maximum number of SendBlks RPCs that
+	// are in flight at once while downloading the blocks of a header chain.
+	maxParallelBlockDownloads = 8
+)
+
+var (
+	// maxCatchUpHeaders is the maximum number of headers that are sent in a
+	// single batch of the SendHeaders RPC.
+	maxCatchUpHeaders = func() types.BlockHeight {
+		switch build.Release {
+		case "dev":
+			return 500
+		case "standard":
+			return 2000
+		case "testing":
+			return 10
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+	// maxSyncHeaders is the maximum number of headers that are sent in a
+	// single call of the SendHeaders RPC. Once the blocks for these headers
+	// have been downloaded, the caller will ask for more headers.
+	maxSyncHeaders = func() types.BlockHeight {
+		switch build.Release {
+		case "dev":
+			return 5000
+		case "standard":
+			return 20000
+		case "testing":
+			return 50
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	errBadHeaderChain  = errors.New("peer sent headers that do not form a chain")
+	errBadHeaderTarget = errors.New("peer sent a header that does not meet the minimum possible target")
+	errTooManyHeaders  = errors.New("peer sent more headers than allowed by the SendHeaders RPC")
+	errWrongBlocks     = errors.New("peer sent blocks that were not requested")
+)
+
+// setSyncDeadline sets the deadline after which a synchronization RPC will
+// time out. Errors returned by SetDeadline are ignored if the conn is a pipe
+// in testing, as pipes do not support Set{,Read,Write}Deadline and should
+// only be used in testing.
+func setSyncDeadline(conn net.Conn) error {
+	err := conn.SetDeadline(time.Now().Add(sendBlocksTimeout))
+	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "set" && opErr.Net == "pipe" && build.Release == "testing" {
+		err = nil
+	}
+	return err
+}
+
+// pathStart returns the height of the first block in the current path that
+// follows the most recent of the known blocks. found is false if none of the
+// known blocks are in the current path, or if the most recent known block is
+// the current block.
+func pathStart(tx *bolt.Tx, knownBlocks [32]types.BlockID) (start types.BlockHeight, found bool) {
+	csHeight := blockHeight(tx)
+	for _, id := range knownBlocks {
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			continue
+		}
+		pathID, err := getPath(tx, pb.Height)
+		if err != nil {
+			continue
+		}
+		if pathID != id {
+			continue
+		}
+		if pb.Height == csHeight {
+			break
+		}
+		// Start from the child of the common block.
+		return pb.Height + 1, true
+	}
+	return 0, false
+}
+
+// rpcSendHeaders is the receiving end of the SendHeaders RPC. It reads the
+// block history of the caller and returns the headers of the current path
+// following the most recent common block, in batches of up to
+// 'maxCatchUpHeaders'. Each batch is followed by a boolean indicating
+// whether more headers are available. At most 'maxSyncHeaders' are sent per
+// call.
+func (cs *ConsensusSet) rpcSendHeaders(conn modules.PeerConn) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	var knownBlocks [32]types.BlockID
+	err = encoding.ReadObject(conn, &knownBlocks, 32*crypto.HashSize)
+	if err != nil {
+		return err
+	}
+	var start types.BlockHeight
+	var found bool
+	cs.mu.RLock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		start, found = pathStart(tx, knownBlocks)
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if !found {
+		if err = encoding.WriteObject(conn, []types.BlockHeader{}); err != nil {
+			return err
+		}
+		return encoding.WriteObject(conn, false)
+	}
+
+	// Send the caller the headers that they are missing.
+	end := start + maxSyncHeaders
+	moreAvailable := true
+	for moreAvailable {
+		var headers []types.BlockHeader
+		cs.mu.RLock()
+		err = cs.db.View(func(tx *bolt.Tx) error {
+			height := blockHeight(tx)
+			for i := start; i <= height && i < start+maxCatchUpHeaders && i < end; i++ {
+				id, err := getPath(tx, i)
+				if build.DEBUG && err != nil {
+					panic(err)
+				}
+				header, err := getBlockHeader(tx, id)
+				if build.DEBUG && err != nil {
+					panic(err)
+				}
+				headers = append(headers, header)
+			}
+			start += maxCatchUpHeaders
+			moreAvailable = start <= height && start < end
+			return nil
+		})
+		cs.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		if err = encoding.WriteObject(conn, headers); err != nil {
+			return err
+		}
+		if err = encoding.WriteObject(conn, moreAvailable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedReceiveHeaders returns an RPCFunc that is the calling end of the
+// SendHeaders RPC. The received headers are validated as a chain extending a
+// known block and then stored in 'headers'.
+func (cs *ConsensusSet) managedReceiveHeaders(headers *[]types.BlockHeader) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		if err := setSyncDeadline(conn); err != nil {
+			return err
+		}
+
+		var history [32]types.BlockID
+		cs.mu.RLock()
+		err := cs.db.View(func(tx *bolt.Tx) error {
+			history = blockHistory(tx)
+			return nil
+		})
+		cs.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if err := encoding.WriteObject(conn, history); err != nil {
+			return err
+		}
+
+		var received []types.BlockHeader
+		moreAvailable := true
+		for moreAvailable {
+			var batch []types.BlockHeader
+			if err := encoding.ReadObject(conn, &batch, uint64(maxCatchUpHeaders)*types.BlockHeaderSize+8); err != nil {
+				return err
+			}
+			if err := encoding.ReadObject(conn, &moreAvailable, 1); err != nil {
+				return err
+			}
+			received = append(received, batch...)
+			if types.BlockHeight(len(received)) > maxSyncHeaders {
+				return errTooManyHeaders
+			}
+		}
+		if len(received) == 0 {
+			*headers = nil
+			return nil
+		}
+
+		cs.mu.RLock()
+		err = cs.db.View(func(tx *bolt.Tx) error {
+			return cs.validateHeaderChain(tx, received)
+		})
+		cs.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		*headers = received
+		return nil
+	}
+}
+
+// validateHeaderChain checks that the headers form a chain that extends a
+// known block, and that each header meets the easiest target it could
+// possibly have. The exact targets depend on the timestamps of the full
+// blocks, so the true targets are checked when the blocks are accepted.
+func (cs *ConsensusSet) validateHeaderChain(tx *bolt.Tx, headers []types.BlockHeader) error {
+	parent, err := getBlockMap(tx, headers[0].ParentID)
+	if err != nil {
+		return errOrphan
+	}
+	height := parent.Height
+	target := parent.ChildTarget
+	prevID := headers[0].ParentID
+	for _, h := range headers {
+		if h.ParentID != prevID {
+			return errBadHeaderChain
+		}
+		id := h.ID()
+		if _, exists := cs.dosBlocks[id]; exists {
+			return errDoSBlock
+		}
+		// The target can only change when the parent's height is a multiple
+		// of half the target window, and can decrease in difficulty by at
+		// most MaxAdjustmentUp.
+		if height != parent.Height && height%(types.TargetWindow/2) == 0 {
+			target = types.RatToTarget(new(big.Rat).Mul(target.Rat(), types.MaxAdjustmentUp))
+		}
+		if !checkHeaderTarget(h, target) {
+			return errBadHeaderTarget
+		}
+		if h.Timestamp > types.CurrentTimestamp()+types.ExtremeFutureThreshold {
+			return errExtremeFutureTimestamp
+		}
+		prevID = id
+		height++
+	}
+	return nil
+}
+
+// rpcSendBlks is the receiving end of the SendBlks RPC. It reads a list of up
+// to 'MaxCatchUpBlocks' block ids and sends the corresponding blocks.
+func (cs *ConsensusSet) rpcSendBlks(conn modules.PeerConn) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	var ids []types.BlockID
+	err = encoding.ReadObject(conn, &ids, uint64(MaxCatchUpBlocks)*crypto.HashSize+8)
+	if err != nil {
+		return err
+	}
+	if types.BlockHeight(len(ids)) > MaxCatchUpBlocks {
+		return errWrongBlocks
+	}
+	blocks := make([]types.Block, 0, len(ids))
+	cs.mu.RLock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			if isPruned(tx, id) {
+				return errBlockPruned
+			}
+			pb, err := getBlockMap(tx, id)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, pb.Block)
+		}
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return encoding.WriteObject(conn, blocks)
+}
+
+// managedReceiveBlks returns an RPCFunc that is the calling end of the
+// SendBlks RPC. The requested blocks are stored in 'blocks' after checking
+// that they match the requested ids.
+func (cs *ConsensusSet) managedReceiveBlks(ids []types.BlockID, blocks *[]types.Block) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		if err := setSyncDeadline(conn); err != nil {
+			return err
+		}
+		if err := encoding.WriteObject(conn, ids); err != nil {
+			return err
+		}
+		var received []types.Block
+		if err := encoding.ReadObject(conn, &received, uint64(len(ids))*types.BlockSizeLimit+8); err != nil {
+			return err
+		}
+		if len(received) != len(ids) {
+			return errWrongBlocks
+		}
+		for i := range received {
+			if received[i].ID() != ids[i] {
+				return errWrongBlocks
+			}
+		}
+		*blocks = received
+		return nil
+	}
+}
+
+// managedDownloadBlocks downloads the blocks of a validated header chain,
+// spreading the requests across peers, and adds them to the consensus set in
+// order. It returns true if any of the blocks extended the current path.
+func (cs *ConsensusSet) managedDownloadBlocks(headers []types.BlockHeader, peers []modules.Peer) (chainExtended bool, err error) {
+	// Split the headers into batches of block ids.
+	var batches [][]types.BlockID
+	for i := 0; i < len(headers); i += int(MaxCatchUpBlocks) {
+		end := i + int(MaxCatchUpBlocks)
+		if end > len(headers) {
+			end = len(headers)
+		}
+		ids := make([]types.BlockID, 0, end-i)
+		for _, h := range headers[i:end] {
+			ids = append(ids, h.ID())
+		}
+		batches = append(batches, ids)
+	}
+
+	for w := 0; w < len(batches); w += maxParallelBlockDownloads {
+		end := w + maxParallelBlockDownloads
+		if end > len(batches) {
+			end = len(batches)
+		}
+		window := batches[w:end]
+
+		// Download each batch of the window in parallel. Each batch starts
+		// with a different peer, and moves on to the next peer on failure.
+		results := make([][]types.Block, len(window))
+		errs := make([]error, len(window))
+		var wg sync.WaitGroup
+		for i := range window {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := range peers {
+					p := peers[(w+i+j)%len(peers)]
+					errs[i] = cs.gateway.RPC(p.NetAddress, "SendBlks", cs.managedReceiveBlks(window[i], &results[i]))
+					if errs[i] == nil {
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		// Accept the blocks in order. Blocks that do not extend the current
+		// path are expected while the header chain is still lighter than the
+		// current path.
+		var blocks []types.Block
+		for i := range window {
+			if errs[i] != nil {
+				break
+			}
+			blocks = append(blocks, results[i]...)
+		}
+		extended, acceptErr := cs.managedAcceptBlocks(blocks)
+		chainExtended = chainExtended || extended
+		if acceptErr != nil {
+			return chainExtended, acceptErr
+		}
+		for i := range window {
+			if errs[i] != nil {
+				return chainExtended, errs[i]
+			}
+		}
+	}
+	return chainExtended, nil
+}
+
+// managedHeadersFirstSync synchronizes with 'p' by first downloading and
+// validating its header chain, and then downloading the corresponding blocks
+// from all of 'peers'. If no headers could be received from 'p', receivedAny
+// is false.
+func (cs *ConsensusSet) managedHeadersFirstSync(p modules.Peer, peers []modules.Peer) (receivedAny bool, err error) {
+	// Broadcast the current block if the chain was extended. This is in a
+	// defer to ensure that a block is broadcast even if a later download
+	// fails.
+	chainExtended := false
+	defer func() {
+		if chainExtended {
+			cs.mu.RLock()
+			synced := cs.synced
+			cs.mu.RUnlock()
+			if synced {
+				cs.managedBroadcastBlock(cs.managedCurrentBlock())
+			}
+		}
+	}()
+	for {
+		var headers []types.BlockHeader
+		err := cs.gateway.RPC(p.NetAddress, "SendHeaders", cs.managedReceiveHeaders(&headers))
+		if err != nil {
+			return receivedAny, err
+		}
+		if len(headers) == 0 {
+			return receivedAny, nil
+		}
+		receivedAny = true
+
+		prevBlock := cs.managedCurrentBlock().ID()
+		extended, err := cs.managedDownloadBlocks(headers, peers)
+		chainExtended = chainExtended || extended
+		if err != nil {
+			return receivedAny, err
+		}
+		// If the current block did not change, the peer's chain is not
+		// heavier than ours and there is nothing more to download.
+		if cs.managedCurrentBlock().ID() == prevBlock {
+			return receivedAny, nil
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package consensus
+
+import (
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestSendHeadersSendBlks checks that a consensus set can synchronize with
+// another consensus set by downloading its headers using the SendHeaders RPC
+// and then downloading the corresponding blocks using the SendBlks RPC.
+func TestSendHeadersSendBlks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := blankConsensusSetTester("TestSendHeadersSendBlks1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester("TestSendHeadersSendBlks2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Mine enough blocks on cst2 that the headers are sent in multiple
+	// batches.
+	numBlocks := int(maxCatchUpHeaders)*2 + 3
+	for i := 0; i < numBlocks; i++ {
+		_, err := cst2.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Download the headers from cst2.
+	p1, p2 := net.Pipe()
+	go cst2.cs.rpcSendHeaders(mockPeerConn{p2})
+	var headers []types.BlockHeader
+	err = cst1.cs.managedReceiveHeaders(&headers)(mockPeerConn{p1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != numBlocks {
+		t.Fatalf("expected %v headers, got %v", numBlocks, len(headers))
+	}
+	if headers[len(headers)-1].ID() != cst2.cs.CurrentBlock().ID() {
+		t.Fatal("last header does not match the current block of cst2")
+	}
+
+	// A tampered header chain should not validate.
+	tampered := append([]types.BlockHeader(nil), headers...)
+	tampered[1].ParentID = types.BlockID{}
+	err = cst1.cs.db.View(func(tx *bolt.Tx) error {
+		return cst1.cs.validateHeaderChain(tx, tampered)
+	})
+	if err != errBadHeaderChain {
+		t.Fatalf("expected %v, got %v", errBadHeaderChain, err)
+	}
+
+	// Download the blocks in batches and add them to cst1.
+	for i := 0; i < len(headers); i += int(MaxCatchUpBlocks) {
+		end := i + int(MaxCatchUpBlocks)
+		if end > len(headers) {
+			end = len(headers)
+		}
+		var ids []types.BlockID
+		for _, h := range headers[i:end] {
+			ids = append(ids, h.ID())
+		}
+		p1, p2 := net.Pipe()
+		go cst2.cs.rpcSendBlks(mockPeerConn{p2})
+		var blocks []types.Block
+		err = cst1.cs.managedReceiveBlks(ids, &blocks)(mockPeerConn{p1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range blocks {
+			err = cst1.cs.managedAcceptBlock(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if cst1.cs.CurrentBlock().ID() != cst2.cs.CurrentBlock().ID() {
+		t.Fatal("cst1 did not synchronize with cst2")
+	}
+
+	// cst1 is synchronized, so no more headers should be sent.
+	p1, p2 = net.Pipe()
+	go cst2.cs.rpcSendHeaders(mockPeerConn{p2})
+	err = cst1.cs.managedReceiveHeaders(&headers)(mockPeerConn{p1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 0 {
+		t.Fatalf("expected 0 headers, got %v", len(headers))
+	}
+}
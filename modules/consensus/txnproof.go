@@ -0,0 +1,191 @@
+package consensus
+
+// txnproof.go implements the SendTxnProofs RPC, through which full nodes
+// serve the transactions relevant to a set of addresses, along with Merkle
+// proofs that tie each transaction to the header of its block. The RPC is used
+// by light consensus sets, which know the headers but not the blocks.
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// maxTxnProofAddresses is the maximum number of addresses in a single
+	// call of the SendTxnProofs RPC.
+	maxTxnProofAddresses = 1000
+
+	// maxTxnProofResponseSize is the size of the proofs after which a full
+	// node stops scanning blocks and sends its response.
+	maxTxnProofResponseSize = 1 << 22
+)
+
+var (
+	// maxTxnProofBlocks is the maximum number of blocks that a full node
+	// scans in a single call of the SendTxnProofs RPC.
+	maxTxnProofBlocks = func() types.BlockHeight {
+		switch build.Release {
+		case "dev":
+			return 200
+		case "standard":
+			return 1000
+		case "testing":
+			return 5
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	errTooManyAddresses = errors.New("request contains more addresses than allowed by the SendTxnProofs RPC")
+)
+
+type (
+	// txnProofRequest is sent by the caller of the SendTxnProofs RPC. It
+	// asks for the transactions relevant to the addresses in the blocks of
+	// the current path, starting at height Start.
+	txnProofRequest struct {
+		Addresses []types.UnlockHash
+		Start     types.BlockHeight
+	}
+
+	// txnProofResponse is the response to a txnProofRequest. Next is the
+	// height of the first block that was not scanned, and Height is the
+	// height of the current block of the full node.
+	txnProofResponse struct {
+		Proofs []modules.TransactionProof
+		Next   types.BlockHeight
+		Height types.BlockHeight
+	}
+)
+
+// transactionRelevant returns true if the transaction spends from or pays to
+// any of the addresses.
+func transactionRelevant(txn types.Transaction, addrs map[types.UnlockHash]struct{}) bool {
+	relevant := func(uh types.UnlockHash) bool {
+		_, exists := addrs[uh]
+		return exists
+	}
+	for _, sci := range txn.SiacoinInputs {
+		if relevant(sci.UnlockConditions.UnlockHash()) {
+			return true
+		}
+	}
+	for _, sco := range txn.SiacoinOutputs {
+		if relevant(sco.UnlockHash) {
+			return true
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		if relevant(sfi.UnlockConditions.UnlockHash()) || relevant(sfi.ClaimUnlockHash) {
+			return true
+		}
+	}
+	for _, sfo := range txn.SiafundOutputs {
+		if relevant(sfo.UnlockHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// rpcSendTxnProofs is the receiving end of the SendTxnProofs RPC. It reads a
+// txnProofRequest and scans up to 'maxTxnProofBlocks' blocks of the current
+// path for relevant transactions, sending a proof for each one. Pruned blocks
+// cannot be scanned.
+func (cs *ConsensusSet) rpcSendTxnProofs(conn modules.PeerConn) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	var req txnProofRequest
+	err = encoding.ReadObject(conn, &req, maxTxnProofAddresses*crypto.HashSize+16)
+	if err != nil {
+		return err
+	}
+	if len(req.Addresses) > maxTxnProofAddresses {
+		return errTooManyAddresses
+	}
+	addrs := make(map[types.UnlockHash]struct{}, len(req.Addresses))
+	for _, uh := range req.Addresses {
+		addrs[uh] = struct{}{}
+	}
+
+	// Scan one block per database transaction, so that the consensus set is
+	// not locked for the whole scan.
+	resp := txnProofResponse{Next: req.Start}
+	var size int
+	for size < maxTxnProofResponseSize && resp.Next < req.Start+maxTxnProofBlocks {
+		done := false
+		cs.mu.RLock()
+		err = cs.db.View(func(tx *bolt.Tx) error {
+			resp.Height = blockHeight(tx)
+			if resp.Next > resp.Height {
+				done = true
+				return nil
+			}
+			id, err := getPath(tx, resp.Next)
+			if err != nil {
+				return err
+			}
+			if isPruned(tx, id) {
+				return errBlockPruned
+			}
+			pb, err := getBlockMap(tx, id)
+			if err != nil {
+				return err
+			}
+			for i, txn := range pb.Block.Transactions {
+				if !transactionRelevant(txn, addrs) {
+					continue
+				}
+				proofIndex, numLeaves, hashSet := pb.Block.TransactionProof(i)
+				proof := modules.TransactionProof{
+					BlockID:     id,
+					Height:      resp.Next,
+					Transaction: txn,
+					ProofIndex:  proofIndex,
+					NumLeaves:   numLeaves,
+					HashSet:     hashSet,
+				}
+				resp.Proofs = append(resp.Proofs, proof)
+				size += len(encoding.Marshal(proof))
+			}
+			resp.Next++
+			return nil
+		})
+		cs.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	return encoding.WriteObject(conn, resp)
+}
+
+// receiveTxnProofs returns an RPCFunc that is the calling end of the
+// SendTxnProofs RPC. The response is stored in 'resp' without being
+// verified.
+func receiveTxnProofs(req txnProofRequest, resp *txnProofResponse) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		if err := setSyncDeadline(conn); err != nil {
+			return err
+		}
+		if err := encoding.WriteObject(conn, req); err != nil {
+			return err
+		}
+		// The response can exceed maxTxnProofResponseSize by the proofs of a
+		// single block.
+		return encoding.ReadObject(conn, resp, maxTxnProofResponseSize+2*types.BlockSizeLimit)
+	}
+}
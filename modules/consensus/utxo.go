@@ -0,0 +1,342 @@
+package consensus
+
+// utxo.go implements exporting the set of unspent siacoin and siafund outputs
+// to a file, and importing such a file on another node to compare it against
+// the local set. The set is committed to by the Merkle root of the ids and
+// outputs in ascending order of id, which is the order in which they are
+// stored in the database, so two nodes at the same block can check that they
+// agree on the set by comparing a single hash.
+//
+// An exported file contains the header of the set, followed by the siacoin
+// outputs and then the siafund outputs. The outputs are written in batches,
+// each followed by a bool indicating whether more batches follow.
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// maxUTXODifferences is the maximum number of differing outputs of each
+	// type that are listed when comparing an imported UTXO set.
+	maxUTXODifferences = 1000
+
+	// maxUTXOBatchSize is the maximum size of a batch of outputs in an
+	// exported UTXO set.
+	maxUTXOBatchSize = 1 << 22
+
+	// utxoBatchSize is the number of outputs written in each batch of an
+	// exported UTXO set.
+	utxoBatchSize = 10e3
+)
+
+var (
+	errUTXOSetBlock    = errors.New("UTXO set was exported at a different block than the current block")
+	errUTXOSetCorrupt  = errors.New("UTXO set does not match its hash")
+	errUTXOSetExists   = errors.New("UTXO set destination already exists")
+	errUTXOSetUnsorted = errors.New("UTXO set outputs are not in ascending order of id")
+)
+
+type (
+	// A utxoEntry is an output along with its id, as stored in the database.
+	// Both are kept in their encoded form so that they can be committed to
+	// and compared without decoding them.
+	utxoEntry struct {
+		ID     []byte
+		Output []byte
+	}
+)
+
+// utxoSetHeader returns the header of the current UTXO set.
+func utxoSetHeader(tx *bolt.Tx) modules.UTXOSetHeader {
+	tree := crypto.NewTree()
+	header := modules.UTXOSetHeader{
+		Height:  blockHeight(tx),
+		BlockID: currentBlockID(tx),
+	}
+	_ = tx.Bucket(SiacoinOutputs).ForEach(func(k, v []byte) error {
+		tree.Push(k)
+		tree.Push(v)
+		header.SiacoinOutputs++
+		return nil
+	})
+	_ = tx.Bucket(SiafundOutputs).ForEach(func(k, v []byte) error {
+		tree.Push(k)
+		tree.Push(v)
+		header.SiafundOutputs++
+		return nil
+	})
+	header.Hash = tree.Root()
+	return header
+}
+
+// writeUTXOBucket writes the entries of a UTXO bucket to w in batches.
+func writeUTXOBucket(b *bolt.Bucket, w io.Writer) error {
+	var entries []utxoEntry
+	err := b.ForEach(func(k, v []byte) error {
+		entries = append(entries, utxoEntry{
+			ID:     append([]byte(nil), k...),
+			Output: append([]byte(nil), v...),
+		})
+		if len(entries) < utxoBatchSize {
+			return nil
+		}
+		if err := encoding.WriteObject(w, entries); err != nil {
+			return err
+		}
+		entries = entries[:0]
+		return encoding.WriteObject(w, true)
+	})
+	if err != nil {
+		return err
+	}
+	if err := encoding.WriteObject(w, entries); err != nil {
+		return err
+	}
+	return encoding.WriteObject(w, false)
+}
+
+// writeUTXOSet writes the header and outputs of the current UTXO set to w.
+func writeUTXOSet(tx *bolt.Tx, w io.Writer) (modules.UTXOSetHeader, error) {
+	header := utxoSetHeader(tx)
+	if err := encoding.WriteObject(w, header); err != nil {
+		return modules.UTXOSetHeader{}, err
+	}
+	for _, bucket := range [][]byte{SiacoinOutputs, SiafundOutputs} {
+		if err := writeUTXOBucket(tx.Bucket(bucket), w); err != nil {
+			return modules.UTXOSetHeader{}, err
+		}
+	}
+	return header, nil
+}
+
+// A utxoMerger compares a stream of outputs, sorted by id, against the
+// outputs of a bucket, recording the ids of the outputs that only appear on
+// one side or that differ between the two.
+type utxoMerger struct {
+	c     *bolt.Cursor
+	k, v  []byte
+	count uint64
+	diffs [][]byte
+}
+
+// newUTXOMerger returns a utxoMerger for the provided bucket.
+func newUTXOMerger(b *bolt.Bucket) *utxoMerger {
+	m := &utxoMerger{c: b.Cursor()}
+	m.k, m.v = m.c.First()
+	return m
+}
+
+// diff records that the output with the provided id differs.
+func (m *utxoMerger) diff(id []byte) {
+	m.count++
+	if len(m.diffs) < maxUTXODifferences {
+		m.diffs = append(m.diffs, append([]byte(nil), id...))
+	}
+}
+
+// next compares the next output of the stream.
+func (m *utxoMerger) next(e utxoEntry) {
+	for m.k != nil && bytes.Compare(m.k, e.ID) < 0 {
+		m.diff(m.k)
+		m.k, m.v = m.c.Next()
+	}
+	if m.k != nil && bytes.Equal(m.k, e.ID) {
+		if !bytes.Equal(m.v, e.Output) {
+			m.diff(e.ID)
+		}
+		m.k, m.v = m.c.Next()
+		return
+	}
+	m.diff(e.ID)
+}
+
+// finish records the outputs of the bucket that come after the last output
+// of the stream.
+func (m *utxoMerger) finish() {
+	for m.k != nil {
+		m.diff(m.k)
+		m.k, m.v = m.c.Next()
+	}
+}
+
+// readUTXOBucket reads the batches of outputs of one UTXO bucket from r,
+// adding them to the tree and comparing them using the merger. The number of
+// outputs read is returned.
+func readUTXOBucket(r io.Reader, tree *crypto.MerkleTree, m *utxoMerger) (n uint64, err error) {
+	var prev []byte
+	for more := true; more; {
+		var entries []utxoEntry
+		if err := encoding.ReadObject(r, &entries, maxUTXOBatchSize); err != nil {
+			return 0, err
+		}
+		if err := encoding.ReadObject(r, &more, 1); err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			if prev != nil && bytes.Compare(prev, e.ID) >= 0 {
+				return 0, errUTXOSetUnsorted
+			}
+			prev = e.ID
+			tree.Push(e.ID)
+			tree.Push(e.Output)
+			m.next(e)
+			n++
+		}
+	}
+	m.finish()
+	return n, nil
+}
+
+// compareUTXOSet reads a UTXO set from r and compares it against the current
+// UTXO set.
+func compareUTXOSet(tx *bolt.Tx, r io.Reader) (modules.UTXOSetComparison, error) {
+	var header modules.UTXOSetHeader
+	if err := encoding.ReadObject(r, &header, 1e3); err != nil {
+		return modules.UTXOSetComparison{}, err
+	}
+	if header.BlockID != currentBlockID(tx) {
+		return modules.UTXOSetComparison{}, errUTXOSetBlock
+	}
+
+	tree := crypto.NewTree()
+	scoMerger := newUTXOMerger(tx.Bucket(SiacoinOutputs))
+	numSiacoinOutputs, err := readUTXOBucket(r, tree, scoMerger)
+	if err != nil {
+		return modules.UTXOSetComparison{}, err
+	}
+	sfoMerger := newUTXOMerger(tx.Bucket(SiafundOutputs))
+	numSiafundOutputs, err := readUTXOBucket(r, tree, sfoMerger)
+	if err != nil {
+		return modules.UTXOSetComparison{}, err
+	}
+	if tree.Root() != header.Hash || numSiacoinOutputs != header.SiacoinOutputs || numSiafundOutputs != header.SiafundOutputs {
+		return modules.UTXOSetComparison{}, errUTXOSetCorrupt
+	}
+
+	comparison := modules.UTXOSetComparison{
+		Header:      header,
+		Differences: scoMerger.count + sfoMerger.count,
+	}
+	comparison.Matches = comparison.Differences == 0
+	for _, id := range scoMerger.diffs {
+		var scoid types.SiacoinOutputID
+		copy(scoid[:], id)
+		comparison.SiacoinOutputs = append(comparison.SiacoinOutputs, scoid)
+	}
+	for _, id := range sfoMerger.diffs {
+		var sfoid types.SiafundOutputID
+		copy(sfoid[:], id)
+		comparison.SiafundOutputs = append(comparison.SiafundOutputs, sfoid)
+	}
+	return comparison, nil
+}
+
+// UTXOSet returns the header of the current UTXO set, which includes the
+// hash committing to the set.
+func (cs *ConsensusSet) UTXOSet() (header modules.UTXOSetHeader, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return modules.UTXOSetHeader{}, err
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		header = utxoSetHeader(tx)
+		return nil
+	})
+	return header, err
+}
+
+// ExportUTXOSet writes the current UTXO set to the provided file, returning
+// the header of the exported set. Existing files are never overwritten.
+func (cs *ConsensusSet) ExportUTXOSet(filename string) (header modules.UTXOSetHeader, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return modules.UTXOSetHeader{}, err
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	// Write the set to a temporary file so that an interrupted export does
+	// not leave a partial set behind.
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		return modules.UTXOSetHeader{}, errUTXOSetExists
+	}
+	tmpFilename := filename + "_temp"
+	f, err := os.OpenFile(tmpFilename, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return modules.UTXOSetHeader{}, errUTXOSetExists
+	} else if err != nil {
+		return modules.UTXOSetHeader{}, err
+	}
+	w := bufio.NewWriter(f)
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		var err error
+		header, err = writeUTXOSet(tx, w)
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return modules.UTXOSetHeader{}, err
+	}
+
+	// Unlike a rename, a link fails if the destination was created while the
+	// set was being written.
+	err = os.Link(tmpFilename, filename)
+	os.Remove(tmpFilename)
+	if os.IsExist(err) {
+		return modules.UTXOSetHeader{}, errUTXOSetExists
+	} else if err != nil {
+		return modules.UTXOSetHeader{}, err
+	}
+	return header, nil
+}
+
+// ImportUTXOSet reads a UTXO set that was exported by ExportUTXOSet, possibly
+// on another node, and compares it against the current UTXO set. The set
+// must have been exported at the current block. The consensus set is not
+// modified.
+func (cs *ConsensusSet) ImportUTXOSet(filename string) (comparison modules.UTXOSetComparison, err error) {
+	err = cs.tg.Add()
+	if err != nil {
+		return modules.UTXOSetComparison{}, err
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return modules.UTXOSetComparison{}, err
+	}
+	defer f.Close()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		var err error
+		comparison, err = compareUTXOSet(tx, bufio.NewReader(f))
+		return err
+	})
+	return comparison, err
+}
@@ -0,0 +1,102 @@
+package consensus
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestExportImportUTXOSet checks that an exported UTXO set can be imported
+// and compared against the consensus set.
+func TestExportImportUTXOSet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	cst, err := createConsensusSetTester("TestExportImportUTXOSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Export the set and check that it matches the current set.
+	filename := filepath.Join(cst.persistDir, "utxos")
+	header, err := cst.cs.ExportUTXOSet(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := cst.cs.UTXOSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != current {
+		t.Fatal("exported header does not match the current UTXO set")
+	}
+	if header.SiacoinOutputs == 0 || header.SiafundOutputs == 0 {
+		t.Fatal("exported set is missing outputs")
+	}
+	if _, err := cst.cs.ExportUTXOSet(filename); err != errUTXOSetExists {
+		t.Fatal("expected errUTXOSetExists when exporting over an existing file, got", err)
+	}
+	comparison, err := cst.cs.ImportUTXOSet(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !comparison.Matches || comparison.Differences != 0 || comparison.Header != header {
+		t.Fatal("imported set does not match the current set:", comparison)
+	}
+
+	// Add an output to the consensus set, which should be reported as a
+	// difference.
+	scoid := types.SiacoinOutputID{1, 2, 3}
+	err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(SiacoinOutputs).Put(scoid[:], encoding.Marshal(types.SiacoinOutput{}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comparison, err = cst.cs.ImportUTXOSet(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comparison.Matches || comparison.Differences != 1 || len(comparison.SiacoinOutputs) != 1 || comparison.SiacoinOutputs[0] != scoid {
+		t.Fatal("added output was not reported:", comparison)
+	}
+	err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(SiacoinOutputs).Delete(scoid[:])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the hash in the header of the file, which follows the 8 byte
+	// length prefix, the height, the block id, and the output counts.
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[8+8+32+8+8] ^= 1
+	corruptFilename := filename + "_corrupt"
+	err = ioutil.WriteFile(corruptFilename, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.cs.ImportUTXOSet(corruptFilename)
+	if err != errUTXOSetCorrupt {
+		t.Fatal("expected errUTXOSetCorrupt, got", err)
+	}
+
+	// A set exported at a different block cannot be compared.
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.cs.ImportUTXOSet(filename)
+	if err != errUTXOSetBlock {
+		t.Fatal("expected errUTXOSetBlock, got", err)
+	}
+}
@@ -18,8 +18,8 @@ var (
 	errInvalidStorageProof        = errors.New("provided storage proof is invalid")
 	errLateRevision               = errors.New("file contract revision submitted after deadline")
 	errLowRevisionNumber          = errors.New("transaction has a file contract with an outdated revision number")
-	errMissingSiacoinOutput       = errors.New("transaction spends a nonexisting siacoin output")
-	errMissingSiafundOutput       = errors.New("transaction spends a nonexisting siafund output")
+	errMissingSiacoinOutput       = modules.ErrMissingSiacoinOutput
+	errMissingSiafundOutput       = modules.ErrMissingSiafundOutput
 	errSiacoinInputOutputMismatch = errors.New("siacoin inputs do not equal siacoin outputs for transaction")
 	errSiafundInputOutputMismatch = errors.New("siafund inputs do not equal siafund outputs for transaction")
 	errUnfinishedFileContract     = errors.New("file contract window has not yet openend")
@@ -160,14 +160,46 @@ func validStorageProofs100e3(tx *bolt.Tx, t types.Transaction) error {
 	return nil
 }
 
+// verifyStorageProof checks a storage proof against the file contract that it
+// proves, using the rules that have been in place since the 100e3 hardfork.
+func verifyStorageProof(sp types.StorageProof, fc types.FileContract, segmentIndex uint64) bool {
+	leaves := crypto.CalculateLeaves(fc.FileSize)
+	segmentLen := uint64(crypto.SegmentSize)
+
+	// If this segment chosen is the final segment, it should only be as
+	// long as necessary to complete the filesize.
+	if segmentIndex == leaves-1 {
+		segmentLen = fc.FileSize % crypto.SegmentSize
+	}
+	if segmentLen == 0 {
+		segmentLen = uint64(crypto.SegmentSize)
+	}
+
+	return crypto.VerifySegment(
+		sp.Segment[:segmentLen],
+		sp.HashSet,
+		leaves,
+		segmentIndex,
+		fc.FileMerkleRoot,
+	)
+}
+
 // validStorageProofs checks that the storage proofs are valid in the context
 // of the consensus set.
 func validStorageProofs(tx *bolt.Tx, t types.Transaction) error {
+	return validPreverifiedStorageProofs(tx, t, nil)
+}
+
+// validPreverifiedStorageProofs checks that the storage proofs are valid in
+// the context of the consensus set. 'proofs' contains the results of
+// verifying the storage proofs in parallel, and can be nil. A result is only
+// used if it was computed against the same file contract and segment.
+func validPreverifiedStorageProofs(tx *bolt.Tx, t types.Transaction, proofs []proofVerification) error {
 	if (build.Release == "standard" && blockHeight(tx) < 100e3) || (build.Release == "testing" && blockHeight(tx) < 10) {
 		return validStorageProofs100e3(tx, t)
 	}
 
-	for _, sp := range t.StorageProofs {
+	for i, sp := range t.StorageProofs {
 		// Check that the storage proof itself is valid.
 		segmentIndex, err := storageProofSegment(tx, sp.ParentID)
 		if err != nil {
@@ -178,25 +210,12 @@ func validStorageProofs(tx *bolt.Tx, t types.Transaction) error {
 		if err != nil {
 			return err
 		}
-		leaves := crypto.CalculateLeaves(fc.FileSize)
-		segmentLen := uint64(crypto.SegmentSize)
-
-		// If this segment chosen is the final segment, it should only be as
-		// long as necessary to complete the filesize.
-		if segmentIndex == leaves-1 {
-			segmentLen = fc.FileSize % crypto.SegmentSize
-		}
-		if segmentLen == 0 {
-			segmentLen = uint64(crypto.SegmentSize)
+		var verified bool
+		if i < len(proofs) && proofs[i].matches(fc, segmentIndex) {
+			verified = proofs[i].verified
+		} else {
+			verified = verifyStorageProof(sp, fc, segmentIndex)
 		}
-
-		verified := crypto.VerifySegment(
-			sp.Segment[:segmentLen],
-			sp.HashSet,
-			leaves,
-			segmentIndex,
-			fc.FileMerkleRoot,
-		)
 		if !verified && fc.FileSize > 0 {
 			return errInvalidStorageProof
 		}
@@ -291,14 +310,21 @@ func validTransaction(tx *bolt.Tx, t types.Transaction) error {
 	if err != nil {
 		return err
 	}
+	return validTransactionState(tx, t, nil)
+}
 
+// validTransactionState performs the checks of validTransaction that depend
+// on the current consensus set. The caller must have already checked that the
+// transaction is StandaloneValid. 'proofs' contains the results of verifying
+// the storage proofs of the transaction in parallel, and can be nil.
+func validTransactionState(tx *bolt.Tx, t types.Transaction, proofs []proofVerification) error {
 	// Check that each portion of the transaction is legal given the current
 	// consensus set.
-	err = validSiacoins(tx, t)
+	err := validSiacoins(tx, t)
 	if err != nil {
 		return err
 	}
-	err = validStorageProofs(tx, t)
+	err = validPreverifiedStorageProofs(tx, t, proofs)
 	if err != nil {
 		return err
 	}
@@ -341,8 +367,23 @@ func (cs *ConsensusSet) TryTransactionSet(txns []types.Transaction) (modules.Con
 	errSuccess := errors.New("success")
 	err = cs.db.Update(func(tx *bolt.Tx) error {
 		diffHolder.Height = blockHeight(tx)
+
+		// The signatures of the whole set are verified in one batch before
+		// checking the transactions against the consensus set.
+		var batch []crypto.SignedHash
+		for _, txn := range txns {
+			txnBatch, err := txn.StandaloneValidBatch(diffHolder.Height)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, txnBatch...)
+		}
+		err := crypto.VerifyBatch(batch)
+		if err != nil {
+			return err
+		}
 		for _, txn := range txns {
-			err := validTransaction(tx, txn)
+			err := validTransactionState(tx, txn, nil)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,138 @@
+package modules
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// EventBlockConnected is published by the consensus set when a block
+	// joins the current path.
+	EventBlockConnected = "blockconnected"
+
+	// EventContractFormed is published by the renter when a file contract is
+	// formed or renewed with a host.
+	EventContractFormed = "contractformed"
+
+	// EventStorageFolderError is published by the host when a storage folder
+	// fails to read or write a sector.
+	EventStorageFolderError = "storagefoldererror"
+
+	// EventTransactionReceived is published by the wallet when a transaction
+	// relevant to the wallet is confirmed.
+	EventTransactionReceived = "transactionreceived"
+
+	// EventUploadComplete is published by the renter when every chunk of a
+	// file has been uploaded at full redundancy.
+	EventUploadComplete = "uploadcomplete"
+
+	// eventBufferSize is the number of events that are queued for a
+	// subscriber before further events are dropped.
+	eventBufferSize = 64
+)
+
+type (
+	// An Event is a notification that something happened in a module. The
+	// Data depends on the Type of the event.
+	Event struct {
+		Type string      `json:"type"`
+		Time time.Time   `json:"time"`
+		Data interface{} `json:"data"`
+	}
+
+	// BlockConnectedEvent is the data of an EventBlockConnected event.
+	BlockConnectedEvent struct {
+		ID        types.BlockID     `json:"id"`
+		Height    types.BlockHeight `json:"height"`
+		Timestamp types.Timestamp   `json:"timestamp"`
+	}
+
+	// ContractFormedEvent is the data of an EventContractFormed event.
+	ContractFormedEvent struct {
+		ID          types.FileContractID `json:"id"`
+		NetAddress  NetAddress           `json:"netaddress"`
+		EndHeight   types.BlockHeight    `json:"endheight"`
+		RenterFunds types.Currency       `json:"renterfunds"`
+	}
+
+	// StorageFolderErrorEvent is the data of an EventStorageFolderError
+	// event. The operation is either "read" or "write".
+	StorageFolderErrorEvent struct {
+		Path      string `json:"path"`
+		Operation string `json:"operation"`
+		Error     string `json:"error"`
+	}
+
+	// TransactionReceivedEvent is the data of an EventTransactionReceived
+	// event.
+	TransactionReceivedEvent struct {
+		TransactionID      types.TransactionID `json:"transactionid"`
+		ConfirmationHeight types.BlockHeight   `json:"confirmationheight"`
+	}
+
+	// UploadCompleteEvent is the data of an EventUploadComplete event.
+	UploadCompleteEvent struct {
+		SiaPath string `json:"siapath"`
+	}
+
+	// An EventPublisher is a module that publishes events.
+	EventPublisher interface {
+		// SubscribeEvents returns a channel that receives the events
+		// published by the module, and a function that ends the
+		// subscription.
+		SubscribeEvents() (<-chan Event, func())
+	}
+
+	// An EventFeed delivers published events to its subscribers. Publishing
+	// never blocks: a subscriber that falls behind misses events instead of
+	// holding up the module. The zero value is an empty feed, and a nil feed
+	// discards published events.
+	EventFeed struct {
+		subscribers map[chan Event]struct{}
+		mu          sync.Mutex
+	}
+)
+
+// Publish sends an event to every subscriber of the feed.
+func (f *EventFeed) Publish(eventType string, data interface{}) {
+	if f == nil {
+		return
+	}
+	e := Event{
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.subscribers {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel that receives the events published to the
+// feed, and a function that ends the subscription and closes the channel.
+func (f *EventFeed) Subscribe() (<-chan Event, func()) {
+	c := make(chan Event, eventBufferSize)
+	f.mu.Lock()
+	if f.subscribers == nil {
+		f.subscribers = make(map[chan Event]struct{})
+	}
+	f.subscribers[c] = struct{}{}
+	f.mu.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, c)
+			f.mu.Unlock()
+			close(c)
+		})
+	}
+}
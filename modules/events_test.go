@@ -0,0 +1,45 @@
+package modules
+
+import (
+	"testing"
+)
+
+// TestEventFeed probes the publishing and subscribing of an EventFeed.
+func TestEventFeed(t *testing.T) {
+	// Publishing to a nil or empty feed is a no-op.
+	var nilFeed *EventFeed
+	nilFeed.Publish(EventBlockConnected, nil)
+	var f EventFeed
+	f.Publish(EventBlockConnected, nil)
+
+	c1, unsubscribe1 := f.Subscribe()
+	c2, unsubscribe2 := f.Subscribe()
+	f.Publish(EventUploadComplete, UploadCompleteEvent{SiaPath: "foo"})
+	for _, c := range []<-chan Event{c1, c2} {
+		e := <-c
+		if e.Type != EventUploadComplete || e.Data.(UploadCompleteEvent).SiaPath != "foo" {
+			t.Fatal("wrong event received:", e)
+		}
+	}
+
+	// An unsubscribed channel is closed and receives no further events.
+	unsubscribe1()
+	unsubscribe1()
+	if _, ok := <-c1; ok {
+		t.Fatal("channel was not closed")
+	}
+	f.Publish(EventBlockConnected, nil)
+	if e := <-c2; e.Type != EventBlockConnected {
+		t.Fatal("wrong event received:", e)
+	}
+
+	// Events for a subscriber that falls behind are dropped instead of
+	// blocking the publisher.
+	for i := 0; i < eventBufferSize*2; i++ {
+		f.Publish(EventBlockConnected, nil)
+	}
+	if len(c2) != eventBufferSize {
+		t.Fatal("expected a full buffer, got", len(c2))
+	}
+	unsubscribe2()
+}
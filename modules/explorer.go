@@ -8,6 +8,20 @@ const (
 	// ExplorerDir is the name of the directory that is typically used for the
 	// explorer.
 	ExplorerDir = "explorer"
+
+	// File contract statuses reported by the explorer. A contract is active
+	// until its proof window opens, and is resolved either by a storage proof
+	// during the window or by the window closing without a proof.
+	ContractStatusActive      = "active"
+	ContractStatusProofWindow = "proofwindow"
+	ContractStatusSucceeded   = "succeeded"
+	ContractStatusFailed      = "failed"
+
+	// Types of the events in the lifecycle of a file contract.
+	ContractEventFormation    = "formation"
+	ContractEventRevision     = "revision"
+	ContractEventStorageProof = "storageproof"
+	ContractEventMissedProof  = "missedproof"
 )
 
 type (
@@ -45,6 +59,100 @@ type (
 		TotalRevisionVolume types.Currency `json:"totalrevisionvolume"`
 	}
 
+	// StorageStats summarizes the file contracts on the network as they were
+	// at a specific block. The size of the active contracts is an estimate of
+	// the amount of data that hosts are storing under contract. The average
+	// storage price is measured in hastings per byte per block, and is
+	// estimated from the payouts of the file contracts formed and revised in
+	// the week before the block.
+	StorageStats struct {
+		Height    types.BlockHeight `json:"height"`
+		Timestamp types.Timestamp   `json:"timestamp"`
+
+		ActiveContractCount uint64         `json:"activecontractcount"`
+		ActiveContractValue types.Currency `json:"activecontractvalue"`
+		ActiveContractSize  types.Currency `json:"activecontractsize"`
+		TotalContractCount  uint64         `json:"totalcontractcount"`
+		TotalContractValue  types.Currency `json:"totalcontractvalue"`
+		AverageStoragePrice types.Currency `json:"averagestorageprice"`
+	}
+
+	// SiafundClaim describes the siacoins claimed from the siafund pool when
+	// a siafund output was spent.
+	SiafundClaim struct {
+		SiafundOutputID types.SiafundOutputID `json:"siafundoutputid"`
+		ClaimOutputID   types.SiacoinOutputID `json:"claimoutputid"`
+		TransactionID   types.TransactionID   `json:"transactionid"`
+		Height          types.BlockHeight     `json:"height"`
+		UnlockHash      types.UnlockHash      `json:"unlockhash"`      // owner of the spent siafunds
+		ClaimUnlockHash types.UnlockHash      `json:"claimunlockhash"` // recipient of the claim
+		Siafunds        types.Currency        `json:"siafunds"`
+		Value           types.Currency        `json:"value"`
+	}
+
+	// SiafundHolder describes the siafunds held by an unlock hash, and the
+	// siacoins that the holder could currently claim from the siafund pool.
+	SiafundHolder struct {
+		UnlockHash        types.UnlockHash `json:"unlockhash"`
+		Siafunds          types.Currency   `json:"siafunds"`
+		UnclaimedSiacoins types.Currency   `json:"unclaimedsiacoins"`
+	}
+
+	// SiafundDistribution describes the current distribution of siafunds.
+	SiafundDistribution struct {
+		SiafundPool types.Currency  `json:"siafundpool"`
+		Holders     []SiafundHolder `json:"holders"`
+	}
+
+	// SiafundTransfer describes a transaction that moved siafunds.
+	SiafundTransfer struct {
+		TransactionID types.TransactionID   `json:"transactionid"`
+		Height        types.BlockHeight     `json:"height"`
+		Inputs        []types.SiafundOutput `json:"inputs"` // the outputs being spent
+		Outputs       []types.SiafundOutput `json:"outputs"`
+	}
+
+	// UnlockHashTransaction is a transaction in the history of an unlock
+	// hash. If MinerPayout is true, the unlock hash received a miner payout,
+	// and ID is the ID of the block containing the payout.
+	UnlockHashTransaction struct {
+		ID          types.TransactionID `json:"id"`
+		Height      types.BlockHeight   `json:"height"`
+		MinerPayout bool                `json:"minerpayout"`
+	}
+
+	// ContractEvent is an event in the lifecycle of a file contract. Missed
+	// proofs do not happen in a transaction, so their TransactionID is empty.
+	ContractEvent struct {
+		Type           string              `json:"type"`
+		TransactionID  types.TransactionID `json:"transactionid"`
+		Height         types.BlockHeight   `json:"height"`
+		RevisionNumber uint64              `json:"revisionnumber"`
+	}
+
+	// ContractPayout is a siacoin output that a file contract pays out when
+	// it is resolved, along with the ID the output has once it is created.
+	ContractPayout struct {
+		ID types.SiacoinOutputID `json:"id"`
+		types.SiacoinOutput
+	}
+
+	// ContractLifecycle describes a file contract from its formation to its
+	// resolution. The payouts are those of the latest revision, and
+	// ResolutionHeight is the height at which the contract was resolved, or
+	// zero if it has not been resolved yet.
+	ContractLifecycle struct {
+		ID               types.FileContractID         `json:"id"`
+		Status           string                       `json:"status"`
+		Contract         types.FileContract           `json:"contract"`
+		Revisions        []types.FileContractRevision `json:"revisions"`
+		Events           []ContractEvent              `json:"events"`
+		ResolutionHeight types.BlockHeight            `json:"resolutionheight"`
+
+		ValidProofPayouts  []ContractPayout `json:"validproofpayouts"`
+		MissedProofPayouts []ContractPayout `json:"missedproofpayouts"`
+	}
+
 	// Explorer tracks the blockchain and provides tools for gathering
 	// statistics and finding objects or patterns within the blockchain.
 	Explorer interface {
@@ -60,6 +168,15 @@ type (
 		// in the explorer's database.
 		LatestBlockFacts() BlockFacts
 
+		// StorageStats returns statistics about the file contracts on the
+		// network as of the latest block.
+		StorageStats() StorageStats
+
+		// StorageStatsHistory returns the storage statistics at every 'step'
+		// blocks from 'start' to 'end', inclusive, so that trends can be
+		// charted. Heights beyond the current height are ignored.
+		StorageStatsHistory(start, end, step types.BlockHeight) ([]StorageStats, error)
+
 		// Transaction returns the block that contains the input transaction
 		// id. The transaction itself is either the block (indicating the miner
 		// payouts are somehow involved), or it is a transaction inside of the
@@ -71,6 +188,11 @@ type (
 		// provided unlock hash.
 		UnlockHash(types.UnlockHash) []types.TransactionID
 
+		// UnlockHashHistory returns a page of the transactions associated
+		// with the provided unlock hash, from the most recent to the oldest,
+		// along with the total number of transactions associated with it.
+		UnlockHashHistory(uh types.UnlockHash, offset, limit int) ([]UnlockHashTransaction, int)
+
 		// SiacoinOutput will return the siacoin output associated with the
 		// input id.
 		SiacoinOutput(types.SiacoinOutputID) (types.SiacoinOutput, bool)
@@ -91,6 +213,11 @@ type (
 		// the provided file contract id.
 		FileContractID(types.FileContractID) []types.TransactionID
 
+		// ContractLifecycle returns the lifecycle of a file contract, from
+		// its formation through its revisions to its resolution. The bool
+		// indicates whether the file contract appears in the blockchain.
+		ContractLifecycle(types.FileContractID) (ContractLifecycle, bool)
+
 		// SiafundOutput will return the siafund output associated with the
 		// input id.
 		SiafundOutput(types.SiafundOutputID) (types.SiafundOutput, bool)
@@ -99,6 +226,29 @@ type (
 		// the provided siafund output id.
 		SiafundOutputID(types.SiafundOutputID) []types.TransactionID
 
+		// SiafundDistribution returns the current value of the siafund pool
+		// and the siafunds held by each unlock hash, ordered from the largest
+		// holder to the smallest.
+		SiafundDistribution() SiafundDistribution
+
+		// SiafundHolder returns the siafunds held by the provided unlock hash.
+		SiafundHolder(types.UnlockHash) SiafundHolder
+
+		// SiafundTransfers returns all of the transactions that moved siafunds
+		// to or from the provided unlock hash, in the order they appear in the
+		// blockchain.
+		SiafundTransfers(types.UnlockHash) []SiafundTransfer
+
+		// SiafundClaims returns all of the siafund claims that were made by or
+		// paid to the provided unlock hash, in the order they appear in the
+		// blockchain.
+		SiafundClaims(types.UnlockHash) []SiafundClaim
+
+		// ConsistencyCheck compares the explorer's database against the
+		// consensus set, returning a description of each inconsistency
+		// found.
+		ConsistencyCheck() (ConsistencyReport, error)
+
 		Close() error
 	}
 )
@@ -0,0 +1,195 @@
+package explorer
+
+// consistency.go compares the explorer's database against the consensus set.
+// The explorer indexes every consensus change in a single database
+// transaction, so a change that cannot be indexed is rolled back entirely;
+// the consistency check confirms that the indexes and totals of the explorer
+// still agree with consensus.
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	errConsistencyChanged = errors.New("consensus changed during the consistency check; try again")
+)
+
+// snapshotRecorder is a consensus set subscriber that records the snapshot it
+// is sent when subscribing, ignoring any later changes.
+type snapshotRecorder struct {
+	snapshot modules.ConsensusChange
+	received bool
+}
+
+// ProcessConsensusChange records the first consensus change it receives.
+func (sr *snapshotRecorder) ProcessConsensusChange(cc modules.ConsensusChange) {
+	if !sr.received {
+		sr.snapshot = cc
+		sr.received = true
+	}
+}
+
+// checkBlockIndex checks that the explorer indexes exactly the blocks in the
+// current path of the consensus set, at the right heights, and that every
+// indexed block has block facts.
+func (e *Explorer) checkBlockIndex(tx *bolt.Tx, height types.BlockHeight) error {
+	var indexed types.BlockHeight
+	err := tx.Bucket(bucketBlockIDs).ForEach(func(k, v []byte) error {
+		var id types.BlockID
+		var h types.BlockHeight
+		if err := encoding.Unmarshal(k, &id); err != nil {
+			return err
+		}
+		if err := encoding.Unmarshal(v, &h); err != nil {
+			return err
+		}
+		block, exists := e.cs.BlockAtHeight(h)
+		if !exists || block.ID() != id {
+			return fmt.Errorf("block %v is indexed at height %v but is not in the current path", id, h)
+		}
+		if tx.Bucket(bucketBlockFacts).Get(k) == nil {
+			return fmt.Errorf("block %v has no block facts", id)
+		}
+		indexed++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if indexed != height+1 {
+		return fmt.Errorf("explorer indexes %v blocks but is at height %v", indexed, height)
+	}
+	return nil
+}
+
+// checkActiveContracts checks that the active contract totals of the
+// explorer match the file contracts in the consensus set, and that the
+// explorer knows the latest revision of each contract.
+func (e *Explorer) checkActiveContracts(tx *bolt.Tx, height types.BlockHeight, fcds []modules.FileContractDiff) error {
+	var cost, size types.Currency
+	for _, fcd := range fcds {
+		cost = cost.Add(fcd.FileContract.Payout)
+		size = size.Add(types.NewCurrency64(fcd.FileContract.FileSize))
+
+		var history fileContractHistory
+		err := dbGetAndDecode(bucketFileContractHistories, fcd.ID, &history)(tx)
+		if err != nil {
+			return fmt.Errorf("file contract %v is not indexed", fcd.ID)
+		}
+		revisionNumber := history.Contract.RevisionNumber
+		if n := len(history.Revisions); n > 0 {
+			revisionNumber = history.Revisions[n-1].NewRevisionNumber
+		}
+		if revisionNumber != fcd.FileContract.RevisionNumber {
+			return fmt.Errorf("file contract %v is at revision %v, but the explorer has revision %v", fcd.ID, fcd.FileContract.RevisionNumber, revisionNumber)
+		}
+	}
+
+	var bf blockFacts
+	err := e.dbGetBlockFacts(height, &bf)(tx)
+	if err != nil {
+		return err
+	}
+	if bf.ActiveContractCount != uint64(len(fcds)) || bf.ActiveContractCost.Cmp(cost) != 0 || bf.ActiveContractSize.Cmp(size) != 0 {
+		return fmt.Errorf("explorer has %v active contracts worth %v covering %v bytes, but consensus has %v worth %v covering %v bytes",
+			bf.ActiveContractCount, bf.ActiveContractCost, bf.ActiveContractSize, len(fcds), cost, size)
+	}
+	return nil
+}
+
+// checkSiafunds checks that the siafund holdings and the siafund pool of the
+// explorer match the consensus set.
+func checkSiafunds(tx *bolt.Tx, sfods []modules.SiafundOutputDiff, pool types.Currency) error {
+	var explorerPool types.Currency
+	err := dbGetInternal(internalSiafundPool, &explorerPool)(tx)
+	if err != nil {
+		return err
+	}
+	if explorerPool.Cmp(pool) != 0 {
+		return fmt.Errorf("explorer has a siafund pool of %v, but consensus has %v", explorerPool, pool)
+	}
+
+	holdings := tx.Bucket(bucketSiafundHoldings)
+	for _, sfod := range sfods {
+		b := holdings.Bucket(encoding.Marshal(sfod.SiafundOutput.UnlockHash))
+		if b == nil || b.Get(encoding.Marshal(sfod.ID)) == nil {
+			return fmt.Errorf("siafund output %v is missing from the holdings of %v", sfod.ID, sfod.SiafundOutput.UnlockHash)
+		}
+	}
+	held := 0
+	err = holdings.ForEach(func(k, _ []byte) error {
+		return holdings.Bucket(k).ForEach(func(_, _ []byte) error {
+			held++
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if held != len(sfods) {
+		return fmt.Errorf("explorer holds %v siafund outputs, but consensus has %v", held, len(sfods))
+	}
+	return nil
+}
+
+// ConsistencyCheck compares the block index, the active contracts, and the
+// siafund holdings of the explorer against the consensus set, returning a
+// description of each inconsistency found. The explorer cannot repair its
+// database; an inconsistent explorer must be rebuilt by deleting its database.
+func (e *Explorer) ConsistencyCheck() (modules.ConsistencyReport, error) {
+	var height types.BlockHeight
+	err := e.db.View(dbGetInternal(internalBlockHeight, &height))
+	if err != nil {
+		return modules.ConsistencyReport{}, err
+	}
+
+	// Grab a snapshot of the objects in the consensus set. Subscribing sends
+	// any pending consensus changes to the explorer first, so the snapshot and
+	// the explorer should describe the same block.
+	var sr snapshotRecorder
+	err = e.cs.ConsensusSetSnapshotSubscribeHeight(&sr, height, nil)
+	if err != nil {
+		return modules.ConsistencyReport{}, err
+	}
+	e.cs.Unsubscribe(&sr)
+	var pool types.Currency
+	for _, diff := range sr.snapshot.SiafundPoolDiffs {
+		pool = diff.Adjusted
+	}
+
+	var report modules.ConsistencyReport
+	err = e.db.View(func(tx *bolt.Tx) error {
+		var recentChange modules.ConsensusChangeID
+		err := dbGetInternal(internalRecentChange, &recentChange)(tx)
+		if err != nil {
+			return err
+		}
+		err = dbGetInternal(internalBlockHeight, &height)(tx)
+		if err != nil {
+			return err
+		}
+		if recentChange != sr.snapshot.ID {
+			return errConsistencyChanged
+		}
+
+		checks := []func() error{
+			func() error { return e.checkBlockIndex(tx, height) },
+			func() error { return e.checkActiveContracts(tx, height, sr.snapshot.FileContractDiffs) },
+			func() error { return checkSiafunds(tx, sr.snapshot.SiafundOutputDiffs, pool) },
+		}
+		for _, check := range checks {
+			if err := check(); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			}
+		}
+		return nil
+	})
+	return report, err
+}
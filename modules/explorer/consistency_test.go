@@ -0,0 +1,83 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationConsistencyCheck checks that the consistency check passes
+// before and after a reorg, and reports a corrupted database.
+func TestIntegrationConsistencyCheck(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester("TestIntegrationConsistencyCheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Put a file contract into the chain.
+	builder := et.wallet.StartTransaction()
+	err = builder.FundSiacoins(types.NewCurrency64(5e9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcOutputs := []types.SiacoinOutput{{Value: types.NewCurrency64(4805e6)}}
+	builder.AddFileContract(types.FileContract{
+		FileSize:           5e3,
+		WindowStart:        et.cs.Height() + 10,
+		WindowEnd:          et.cs.Height() + 20,
+		Payout:             types.NewCurrency64(5e9),
+		ValidProofOutputs:  fcOutputs,
+		MissedProofOutputs: fcOutputs,
+	})
+	txns, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = et.tpool.AcceptTransactionSet(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = et.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	report, err := et.explorer.ConsistencyCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 0 {
+		t.Fatal("explorer is inconsistent:", report.Errors)
+	}
+
+	// Reorg the contract out of the blockchain. Every index entry of the
+	// reverted blocks should be removed.
+	err = et.reorgToBlank()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = et.explorer.ConsistencyCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 0 {
+		t.Fatal("explorer is inconsistent after a reorg:", report.Errors)
+	}
+	if txids := et.explorer.FileContractID(txns[len(txns)-1].FileContractID(0)); len(txids) != 0 {
+		t.Fatal("reverted file contract is still indexed")
+	}
+
+	// Corrupt the siafund pool.
+	err = et.explorer.db.Update(dbSetInternal(internalSiafundPool, types.NewCurrency64(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = et.explorer.ConsistencyCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 1 {
+		t.Fatal("expected one inconsistency, got", report.Errors)
+	}
+}
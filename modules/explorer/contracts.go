@@ -0,0 +1,163 @@
+package explorer
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// contractEventOrder is the order of the events of a file contract that
+// happen at the same height.
+var contractEventOrder = map[string]int{
+	modules.ContractEventFormation:    0,
+	modules.ContractEventRevision:     1,
+	modules.ContractEventStorageProof: 2,
+	modules.ContractEventMissedProof:  3,
+}
+
+// contractEvents implements sort.Interface, ordering the events of a file
+// contract from the oldest to the most recent.
+type contractEvents []modules.ContractEvent
+
+func (ce contractEvents) Len() int      { return len(ce) }
+func (ce contractEvents) Swap(i, j int) { ce[i], ce[j] = ce[j], ce[i] }
+func (ce contractEvents) Less(i, j int) bool {
+	if ce[i].Height != ce[j].Height {
+		return ce[i].Height < ce[j].Height
+	}
+	if ce[i].Type != ce[j].Type {
+		return contractEventOrder[ce[i].Type] < contractEventOrder[ce[j].Type]
+	}
+	return ce[i].RevisionNumber < ce[j].RevisionNumber
+}
+
+// transactionContractEvents returns the events of a file contract that happen
+// in a transaction.
+func transactionContractEvents(id types.FileContractID, txn types.Transaction, height types.BlockHeight) (events []modules.ContractEvent) {
+	txid := txn.ID()
+	for i, fc := range txn.FileContracts {
+		if txn.FileContractID(uint64(i)) == id {
+			events = append(events, modules.ContractEvent{
+				Type:           modules.ContractEventFormation,
+				TransactionID:  txid,
+				Height:         height,
+				RevisionNumber: fc.RevisionNumber,
+			})
+		}
+	}
+	for _, fcr := range txn.FileContractRevisions {
+		if fcr.ParentID == id {
+			events = append(events, modules.ContractEvent{
+				Type:           modules.ContractEventRevision,
+				TransactionID:  txid,
+				Height:         height,
+				RevisionNumber: fcr.NewRevisionNumber,
+			})
+		}
+	}
+	for _, sp := range txn.StorageProofs {
+		if sp.ParentID == id {
+			events = append(events, modules.ContractEvent{
+				Type:          modules.ContractEventStorageProof,
+				TransactionID: txid,
+				Height:        height,
+			})
+		}
+	}
+	return events
+}
+
+// contractPayouts returns the payouts of a file contract for a proof status,
+// along with the IDs the payouts have once they are created.
+func contractPayouts(id types.FileContractID, status types.ProofStatus, outputs []types.SiacoinOutput) []modules.ContractPayout {
+	payouts := make([]modules.ContractPayout, len(outputs))
+	for i, sco := range outputs {
+		payouts[i] = modules.ContractPayout{
+			ID:            id.StorageProofOutputID(status, uint64(i)),
+			SiacoinOutput: sco,
+		}
+	}
+	return payouts
+}
+
+// ContractLifecycle returns the lifecycle of a file contract, from its
+// formation through its revisions to its resolution. A contract that has no
+// storage proof when its proof window closes is resolved as missed at the
+// end of the window.
+func (e *Explorer) ContractLifecycle(id types.FileContractID) (modules.ContractLifecycle, bool) {
+	var history fileContractHistory
+	var txids []types.TransactionID
+	var height types.BlockHeight
+	err := e.db.View(func(tx *bolt.Tx) error {
+		err := dbGetAndDecode(bucketFileContractHistories, id, &history)(tx)
+		if err != nil {
+			return err
+		}
+		err = dbGetTransactionIDSet(bucketFileContractIDs, id, &txids)(tx)
+		if err != nil {
+			return err
+		}
+		return dbGetInternal(internalBlockHeight, &height)(tx)
+	})
+	if err != nil {
+		return modules.ContractLifecycle{}, false
+	}
+
+	cl := modules.ContractLifecycle{
+		ID:        id,
+		Contract:  history.Contract,
+		Revisions: history.Revisions,
+		Events:    []modules.ContractEvent{},
+	}
+	if cl.Revisions == nil {
+		cl.Revisions = []types.FileContractRevision{}
+	}
+	for _, txid := range txids {
+		block, txnHeight, exists := e.Transaction(txid)
+		if !exists {
+			continue
+		}
+		for _, txn := range block.Transactions {
+			if txn.ID() == txid {
+				cl.Events = append(cl.Events, transactionContractEvents(id, txn, txnHeight)...)
+			}
+		}
+	}
+
+	// The window and payouts are those of the latest revision.
+	windowStart, windowEnd := history.Contract.WindowStart, history.Contract.WindowEnd
+	validOutputs, missedOutputs := history.Contract.ValidProofOutputs, history.Contract.MissedProofOutputs
+	if n := len(history.Revisions); n > 0 {
+		fcr := history.Revisions[n-1]
+		windowStart, windowEnd = fcr.NewWindowStart, fcr.NewWindowEnd
+		validOutputs, missedOutputs = fcr.NewValidProofOutputs, fcr.NewMissedProofOutputs
+	}
+	cl.ValidProofPayouts = contractPayouts(id, types.ProofValid, validOutputs)
+	cl.MissedProofPayouts = contractPayouts(id, types.ProofMissed, missedOutputs)
+
+	switch {
+	case history.StorageProof.ParentID == id:
+		cl.Status = modules.ContractStatusSucceeded
+		for _, event := range cl.Events {
+			if event.Type == modules.ContractEventStorageProof {
+				cl.ResolutionHeight = event.Height
+			}
+		}
+	case height >= windowEnd:
+		cl.Status = modules.ContractStatusFailed
+		cl.ResolutionHeight = windowEnd
+		cl.Events = append(cl.Events, modules.ContractEvent{
+			Type:   modules.ContractEventMissedProof,
+			Height: windowEnd,
+		})
+	case height >= windowStart:
+		cl.Status = modules.ContractStatusProofWindow
+	default:
+		cl.Status = modules.ContractStatusActive
+	}
+	sort.Sort(contractEvents(cl.Events))
+	return cl, true
+}
@@ -0,0 +1,107 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationContractLifecycle checks that the explorer reports the
+// formation, revisions, and missed proof of a file contract.
+func TestIntegrationContractLifecycle(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester("TestIntegrationContractLifecycle")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Form a file contract that can be revised without signatures.
+	var uc types.UnlockConditions
+	fcOutputs := []types.SiacoinOutput{{Value: types.NewCurrency64(4805e6)}}
+	fc := types.FileContract{
+		FileSize:           5e3,
+		WindowStart:        et.cs.Height() + 3,
+		WindowEnd:          et.cs.Height() + 4,
+		Payout:             types.NewCurrency64(5e9),
+		ValidProofOutputs:  fcOutputs,
+		MissedProofOutputs: fcOutputs,
+		UnlockHash:         uc.UnlockHash(),
+	}
+	builder := et.wallet.StartTransaction()
+	err = builder.FundSiacoins(fc.Payout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.AddFileContract(fc)
+	txns, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formation := txns[len(txns)-1]
+	fcid := formation.FileContractID(0)
+	err = et.tpool.AcceptTransactionSet(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = et.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revise the contract.
+	revision := types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:              fcid,
+			UnlockConditions:      uc,
+			NewRevisionNumber:     1,
+			NewFileSize:           10e3,
+			NewWindowStart:        fc.WindowStart,
+			NewWindowEnd:          fc.WindowEnd,
+			NewValidProofOutputs:  fcOutputs,
+			NewMissedProofOutputs: fcOutputs,
+			NewUnlockHash:         fc.UnlockHash,
+		}},
+	}
+	err = et.tpool.AcceptTransactionSet([]types.Transaction{revision})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = et.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	cl, exists := et.explorer.ContractLifecycle(fcid)
+	if !exists {
+		t.Fatal("contract not found")
+	}
+	if cl.Status != modules.ContractStatusActive || cl.ResolutionHeight != 0 {
+		t.Fatal("expected an active contract, got", cl.Status)
+	}
+	if len(cl.Events) != 2 || cl.Events[0].Type != modules.ContractEventFormation || cl.Events[0].TransactionID != formation.ID() ||
+		cl.Events[1].Type != modules.ContractEventRevision || cl.Events[1].RevisionNumber != 1 || cl.Events[1].TransactionID != revision.ID() {
+		t.Fatal("wrong contract events:", cl.Events)
+	}
+	if len(cl.Revisions) != 1 || len(cl.MissedProofPayouts) != 1 || cl.MissedProofPayouts[0].ID != fcid.StorageProofOutputID(types.ProofMissed, 0) {
+		t.Fatal("wrong revisions or payouts:", cl.Revisions, cl.MissedProofPayouts)
+	}
+
+	// Let the proof window pass without a proof.
+	for et.cs.Height() < fc.WindowEnd {
+		if _, err = et.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cl, _ = et.explorer.ContractLifecycle(fcid)
+	if cl.Status != modules.ContractStatusFailed || cl.ResolutionHeight != fc.WindowEnd {
+		t.Fatal("expected a failed contract, got", cl.Status, cl.ResolutionHeight)
+	}
+	if last := cl.Events[len(cl.Events)-1]; last.Type != modules.ContractEventMissedProof || last.Height != fc.WindowEnd {
+		t.Fatal("missed proof is not the last event:", cl.Events)
+	}
+
+	if _, exists := et.explorer.ContractLifecycle(types.FileContractID{1}); exists {
+		t.Fatal("unknown contract was found")
+	}
+}
@@ -1,6 +1,7 @@
 package explorer
 
 import (
+	"encoding/binary"
 	"errors"
 
 	"github.com/NebulousLabs/Sia/encoding"
@@ -21,17 +22,24 @@ var (
 	bucketFileContractIDs       = []byte("FileContractIDs")
 	bucketSiacoinOutputIDs      = []byte("SiacoinOutputIDs")
 	bucketSiacoinOutputs        = []byte("SiacoinOutputs")
+	bucketSiafundClaims         = []byte("SiafundClaims")
+	bucketSiafundHoldings       = []byte("SiafundHoldings")
 	bucketSiafundOutputIDs      = []byte("SiafundOutputIDs")
 	bucketSiafundOutputs        = []byte("SiafundOutputs")
+	bucketSiafundTransfers      = []byte("SiafundTransfers")
+	bucketStoragePriceTotals    = []byte("StoragePriceTotals")
 	bucketTransactionIDs        = []byte("TransactionIDs")
 	bucketUnlockHashes          = []byte("UnlockHashes")
+	bucketUnlockHashHistory     = []byte("UnlockHashHistory")
 
 	// bucketInternal is used to store values internal to the explorer
 	bucketInternal = []byte("Internal")
 
 	// keys for bucketInternal
-	internalBlockHeight  = []byte("BlockHeight")
-	internalRecentChange = []byte("RecentChange")
+	internalActiveContracts = []byte("ActiveContracts")
+	internalBlockHeight     = []byte("BlockHeight")
+	internalRecentChange    = []byte("RecentChange")
+	internalSiafundPool     = []byte("SiafundPool")
 )
 
 // These functions all return a 'func(*bolt.Tx) error', which, allows them to
@@ -115,3 +123,14 @@ func dbGetInternal(key []byte, val interface{}) func(*bolt.Tx) error {
 		return encoding.Unmarshal(tx.Bucket(bucketInternal).Get(key), val)
 	}
 }
+
+// unlockHashHistoryKey returns the key of a transaction in the history of an
+// unlock hash. Keys start with the big-endian height of the transaction, so
+// that iterating over the history visits the transactions in the order they
+// appeared in the blockchain.
+func unlockHashHistoryKey(height types.BlockHeight, txid types.TransactionID) []byte {
+	key := make([]byte, 8+len(txid))
+	binary.BigEndian.PutUint64(key, uint64(height))
+	copy(key[8:], txid[:])
+	return key
+}
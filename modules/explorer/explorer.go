@@ -14,6 +14,10 @@ const (
 	// hashrateEstimationBlocks is the number of blocks that are used to
 	// estimate the current hashrate.
 	hashrateEstimationBlocks = 200 // 33 hours
+
+	// storagePriceWindow is the number of blocks that are used to estimate
+	// the average storage price.
+	storagePriceWindow = 1008 // 1 week
 )
 
 var (
@@ -41,6 +45,24 @@ type (
 		Timestamp types.Timestamp
 	}
 
+	// activeContractTotals holds the running totals of the file contracts
+	// that are active in the consensus set.
+	activeContractTotals struct {
+		Count uint64
+		Cost  types.Currency
+		Size  types.Currency
+	}
+
+	// storagePriceTotals holds the running totals of the payouts of the file
+	// contracts formed and revised up to a certain block, and of the data
+	// they cover, measured in bytes multiplied by the number of blocks the
+	// bytes are stored for. The totals are stored separately from the block
+	// facts so that the encoding of the block facts does not change.
+	storagePriceTotals struct {
+		ContractValue types.Currency
+		ByteBlocks    types.Currency
+	}
+
 	// An Explorer contains a more comprehensive view of the blockchain,
 	// including various statistics and metrics.
 	Explorer struct {
@@ -1,7 +1,11 @@
 package explorer
 
 import (
+	"encoding/binary"
+	"sort"
+
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
@@ -82,6 +86,39 @@ func (e *Explorer) UnlockHash(uh types.UnlockHash) []types.TransactionID {
 	return ids
 }
 
+// UnlockHashHistory returns the transactions that contain the unlock hash,
+// from the most recent to the oldest, skipping the first offset transactions
+// and returning at most limit transactions. The total number of transactions
+// that contain the unlock hash is also returned. Miner payouts are reported
+// with the ID of the block containing them.
+func (e *Explorer) UnlockHashHistory(uh types.UnlockHash, offset, limit int) (history []modules.UnlockHashTransaction, total int) {
+	err := e.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketUnlockHashHistory).Bucket(encoding.Marshal(uh))
+		if b == nil {
+			return nil
+		}
+		total = b.Stats().KeyN
+		blockIDs := tx.Bucket(bucketBlockIDs)
+		c := b.Cursor()
+		k, _ := c.Last()
+		for i := 0; i < offset && k != nil; i++ {
+			k, _ = c.Prev()
+		}
+		for ; k != nil && len(history) < limit; k, _ = c.Prev() {
+			var ut modules.UnlockHashTransaction
+			ut.Height = types.BlockHeight(binary.BigEndian.Uint64(k[:8]))
+			copy(ut.ID[:], k[8:])
+			ut.MinerPayout = blockIDs.Get(encoding.Marshal(types.BlockID(ut.ID))) != nil
+			history = append(history, ut)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0
+	}
+	return history, total
+}
+
 // SiacoinOutput returns the siacoin output associated with the specified ID.
 func (e *Explorer) SiacoinOutput(id types.SiacoinOutputID) (types.SiacoinOutput, bool) {
 	var sco types.SiacoinOutput
@@ -152,3 +189,171 @@ func (e *Explorer) SiafundOutputID(id types.SiafundOutputID) []types.Transaction
 	}
 	return ids
 }
+
+type (
+	// siafundHoldersByValue sorts siafund holders from the largest holder to
+	// the smallest.
+	siafundHoldersByValue []modules.SiafundHolder
+
+	// siafundClaimsByHeight sorts siafund claims by height.
+	siafundClaimsByHeight []modules.SiafundClaim
+
+	// siafundTransfersByHeight sorts siafund transfers by height.
+	siafundTransfersByHeight []modules.SiafundTransfer
+)
+
+func (s siafundHoldersByValue) Len() int      { return len(s) }
+func (s siafundHoldersByValue) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s siafundHoldersByValue) Less(i, j int) bool {
+	return s[i].Siafunds.Cmp(s[j].Siafunds) > 0
+}
+
+func (s siafundClaimsByHeight) Len() int           { return len(s) }
+func (s siafundClaimsByHeight) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s siafundClaimsByHeight) Less(i, j int) bool { return s[i].Height < s[j].Height }
+
+func (s siafundTransfersByHeight) Len() int           { return len(s) }
+func (s siafundTransfersByHeight) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s siafundTransfersByHeight) Less(i, j int) bool { return s[i].Height < s[j].Height }
+
+// dbGetSiafundHolder returns a 'func(*bolt.Tx) error' that totals the
+// siafunds held by an unlock hash and the siacoins that could be claimed
+// from the siafund pool by spending them.
+func dbGetSiafundHolder(uh types.UnlockHash, pool types.Currency, holder *modules.SiafundHolder) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		holder.UnlockHash = uh
+		holder.Siafunds = types.ZeroCurrency
+		holder.UnclaimedSiacoins = types.ZeroCurrency
+		b := tx.Bucket(bucketSiafundHoldings).Bucket(encoding.Marshal(uh))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, sfoBytes []byte) error {
+			var sfo types.SiafundOutput
+			err := encoding.Unmarshal(sfoBytes, &sfo)
+			if err != nil {
+				return err
+			}
+			// Claims are computed the same way as in the consensus set.
+			holder.Siafunds = holder.Siafunds.Add(sfo.Value)
+			claim := pool.Sub(sfo.ClaimStart).Div(types.SiafundCount).Mul(sfo.Value)
+			holder.UnclaimedSiacoins = holder.UnclaimedSiacoins.Add(claim)
+			return nil
+		})
+	}
+}
+
+// SiafundDistribution returns the current value of the siafund pool and the
+// siafunds held by each unlock hash, ordered from the largest holder to the
+// smallest.
+func (e *Explorer) SiafundDistribution() modules.SiafundDistribution {
+	var sd modules.SiafundDistribution
+	err := e.db.View(func(tx *bolt.Tx) error {
+		err := dbGetInternal(internalSiafundPool, &sd.SiafundPool)(tx)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketSiafundHoldings).ForEach(func(uhBytes, _ []byte) error {
+			var uh types.UnlockHash
+			err := encoding.Unmarshal(uhBytes, &uh)
+			if err != nil {
+				return err
+			}
+			var holder modules.SiafundHolder
+			err = dbGetSiafundHolder(uh, sd.SiafundPool, &holder)(tx)
+			if err != nil {
+				return err
+			}
+			sd.Holders = append(sd.Holders, holder)
+			return nil
+		})
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	sort.Sort(siafundHoldersByValue(sd.Holders))
+	return sd
+}
+
+// SiafundHolder returns the siafunds held by the specified unlock hash.
+func (e *Explorer) SiafundHolder(uh types.UnlockHash) modules.SiafundHolder {
+	var holder modules.SiafundHolder
+	err := e.db.View(func(tx *bolt.Tx) error {
+		var pool types.Currency
+		err := dbGetInternal(internalSiafundPool, &pool)(tx)
+		if err != nil {
+			return err
+		}
+		return dbGetSiafundHolder(uh, pool, &holder)(tx)
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	return holder
+}
+
+// SiafundTransfers returns all of the transactions that moved siafunds to or
+// from the specified unlock hash, ordered by height.
+func (e *Explorer) SiafundTransfers(uh types.UnlockHash) []modules.SiafundTransfer {
+	var txids []types.TransactionID
+	err := e.db.View(dbGetTransactionIDSet(bucketSiafundTransfers, uh, &txids))
+	if err != nil {
+		return nil
+	}
+
+	var transfers []modules.SiafundTransfer
+	for _, txid := range txids {
+		block, height, exists := e.Transaction(txid)
+		if !exists {
+			build.Critical("explorer pointing to nonexistent txn")
+			continue
+		}
+		for _, txn := range block.Transactions {
+			if txn.ID() != txid {
+				continue
+			}
+			transfer := modules.SiafundTransfer{
+				TransactionID: txid,
+				Height:        height,
+				Outputs:       txn.SiafundOutputs,
+			}
+			for _, sfi := range txn.SiafundInputs {
+				sfo, exists := e.SiafundOutput(sfi.ParentID)
+				if build.DEBUG && !exists {
+					panic("could not find corresponding siafund output")
+				}
+				transfer.Inputs = append(transfer.Inputs, sfo)
+			}
+			transfers = append(transfers, transfer)
+			break
+		}
+	}
+	sort.Sort(siafundTransfersByHeight(transfers))
+	return transfers
+}
+
+// SiafundClaims returns all of the siafund claims that were made by or paid
+// to the specified unlock hash, ordered by height.
+func (e *Explorer) SiafundClaims(uh types.UnlockHash) []modules.SiafundClaim {
+	var claims []modules.SiafundClaim
+	err := e.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSiafundClaims).Bucket(encoding.Marshal(uh))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, claimBytes []byte) error {
+			var claim modules.SiafundClaim
+			err := encoding.Unmarshal(claimBytes, &claim)
+			if err != nil {
+				return err
+			}
+			claims = append(claims, claim)
+			return nil
+		})
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	sort.Sort(siafundClaimsByHeight(claims))
+	return claims
+}
@@ -72,3 +72,190 @@ func TestBlockFacts(t *testing.T) {
 		t.Error("call to 'BlockFacts' has failed")
 	}
 }
+
+// TestIntegrationExplorerSiafunds checks that the explorer tracks the
+// distribution of siafunds, siafund transfers, and siafund claims.
+func TestIntegrationExplorerSiafunds(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester("TestIntegrationExplorerSiafunds")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The initial distribution should match the genesis allocation.
+	sd := et.explorer.SiafundDistribution()
+	if len(sd.Holders) != len(types.GenesisSiafundAllocation) {
+		t.Fatalf("expected %v holders, got %v", len(types.GenesisSiafundAllocation), len(sd.Holders))
+	}
+	total := types.ZeroCurrency
+	for i, holder := range sd.Holders {
+		total = total.Add(holder.Siafunds)
+		if i > 0 && holder.Siafunds.Cmp(sd.Holders[i-1].Siafunds) > 0 {
+			t.Error("holders are not sorted by siafunds")
+		}
+	}
+	if total.Cmp(types.SiafundCount) != 0 {
+		t.Fatal("distribution does not contain all of the siafunds:", total)
+	}
+
+	// Put a file contract into the chain to fill the siafund pool.
+	builder := et.wallet.StartTransaction()
+	builder.FundSiacoins(types.NewCurrency64(5e9))
+	fcOutputs := []types.SiacoinOutput{{Value: types.NewCurrency64(4805e6)}}
+	builder.AddFileContract(types.FileContract{
+		FileSize:           5e3,
+		WindowStart:        et.cs.Height() + 2,
+		WindowEnd:          et.cs.Height() + 3,
+		Payout:             types.NewCurrency64(5e9),
+		ValidProofOutputs:  fcOutputs,
+		MissedProofOutputs: fcOutputs,
+	})
+	txns, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = et.tpool.AcceptTransactionSet(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd = et.explorer.SiafundDistribution()
+	if sd.SiafundPool.IsZero() {
+		t.Fatal("siafund pool was not updated")
+	}
+
+	// Send the anyone-can-spend siafund output to a wallet address.
+	anyone := types.UnlockConditions{}.UnlockHash()
+	unclaimed := et.explorer.SiafundHolder(anyone).UnclaimedSiacoins
+	if unclaimed.IsZero() {
+		t.Fatal("holder should have unclaimed siacoins")
+	}
+	uc, err := et.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfoid := types.GenesisBlock.Transactions[0].SiafundOutputID(2)
+	txn := types.Transaction{
+		SiafundInputs: []types.SiafundInput{{
+			ParentID:        sfoid,
+			ClaimUnlockHash: uc.UnlockHash(),
+		}},
+		SiafundOutputs: []types.SiafundOutput{{
+			Value:      types.NewCurrency64(1e3),
+			UnlockHash: uc.UnlockHash(),
+		}},
+	}
+	err = et.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the holders, the transfer, and the claim.
+	if holder := et.explorer.SiafundHolder(anyone); !holder.Siafunds.IsZero() {
+		t.Error("spent siafunds are still held:", holder.Siafunds)
+	}
+	if holder := et.explorer.SiafundHolder(uc.UnlockHash()); holder.Siafunds.Cmp(types.NewCurrency64(1e3)) != 0 {
+		t.Error("received siafunds are not held:", holder.Siafunds)
+	}
+	for _, uh := range []types.UnlockHash{anyone, uc.UnlockHash()} {
+		transfers := et.explorer.SiafundTransfers(uh)
+		if len(transfers) != 1 || transfers[0].TransactionID != txn.ID() {
+			t.Fatal("transfer was not recorded:", transfers)
+		}
+		if len(transfers[0].Inputs) != 1 || transfers[0].Inputs[0].Value.Cmp(types.NewCurrency64(1e3)) != 0 {
+			t.Error("transfer inputs were not recorded:", transfers[0].Inputs)
+		}
+		claims := et.explorer.SiafundClaims(uh)
+		if len(claims) != 1 || claims[0].SiafundOutputID != sfoid {
+			t.Fatal("claim was not recorded:", claims)
+		}
+		if claims[0].Value.Cmp(unclaimed) != 0 {
+			t.Errorf("expected claim of %v, got %v", unclaimed, claims[0].Value)
+		}
+	}
+
+	// Reorg the claim out of the blockchain.
+	err = et.reorgToBlank()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims := et.explorer.SiafundClaims(anyone); len(claims) != 0 {
+		t.Error("claim was not reverted:", claims)
+	}
+	if transfers := et.explorer.SiafundTransfers(anyone); len(transfers) != 0 {
+		t.Error("transfer was not reverted:", transfers)
+	}
+	if holder := et.explorer.SiafundHolder(anyone); holder.Siafunds.Cmp(types.NewCurrency64(1e3)) != 0 {
+		t.Error("reverted siafunds are not held:", holder.Siafunds)
+	}
+	if sd := et.explorer.SiafundDistribution(); !sd.SiafundPool.IsZero() {
+		t.Error("siafund pool was not reverted:", sd.SiafundPool)
+	}
+}
+
+// TestIntegrationUnlockHashHistory checks that the explorer returns the
+// transactions involving an unlock hash from the most recent to the oldest,
+// one page at a time.
+func TestIntegrationUnlockHashHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester("TestIntegrationUnlockHashHistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send siacoins to a new address in three separate blocks.
+	uc, err := et.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := uc.UnlockHash()
+	var txids []types.TransactionID
+	for i := 0; i < 3; i++ {
+		txns, err := et.wallet.SendSiacoins(types.SiacoinPrecision, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txids = append(txids, txns[len(txns)-1].ID())
+		if _, err := et.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, total := et.explorer.UnlockHashHistory(addr, 0, 2)
+	if total != 3 || len(history) != 2 {
+		t.Fatal("wrong history length:", total, len(history))
+	}
+	if history[0].ID != txids[2] || history[1].ID != txids[1] || history[0].Height <= history[1].Height {
+		t.Fatal("history is not ordered from the most recent transaction:", history)
+	}
+	history, _ = et.explorer.UnlockHashHistory(addr, 2, 2)
+	if len(history) != 1 || history[0].ID != txids[0] || history[0].MinerPayout {
+		t.Fatal("wrong second page of history:", history)
+	}
+
+	// Miner payouts are reported with the ID of their block.
+	b, err := et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, _ = et.explorer.UnlockHashHistory(b.MinerPayouts[0].UnlockHash, 0, 1)
+	if len(history) != 1 || !history[0].MinerPayout || history[0].ID != types.TransactionID(b.ID()) {
+		t.Fatal("miner payout is not the most recent entry in the history:", history)
+	}
+
+	// An unlock hash that does not appear in the blockchain has no history.
+	if history, total := et.explorer.UnlockHashHistory(types.UnlockHash{1}, 0, 10); total != 0 || len(history) != 0 {
+		t.Fatal("unknown unlock hash has history:", history)
+	}
+}
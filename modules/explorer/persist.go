@@ -44,10 +44,15 @@ func (e *Explorer) initPersist() error {
 			bucketInternal,
 			bucketSiacoinOutputIDs,
 			bucketSiacoinOutputs,
+			bucketSiafundClaims,
+			bucketSiafundHoldings,
 			bucketSiafundOutputIDs,
 			bucketSiafundOutputs,
+			bucketSiafundTransfers,
+			bucketStoragePriceTotals,
 			bucketTransactionIDs,
 			bucketUnlockHashes,
+			bucketUnlockHashHistory,
 		}
 		for _, b := range buckets {
 			_, err := tx.CreateBucketIfNotExists(b)
@@ -62,6 +67,7 @@ func (e *Explorer) initPersist() error {
 		}{
 			{internalBlockHeight, encoding.Marshal(types.BlockHeight(0))},
 			{internalRecentChange, encoding.Marshal(modules.ConsensusChangeID{})},
+			{internalSiafundPool, encoding.Marshal(types.ZeroCurrency)},
 		}
 		b := tx.Bucket(bucketInternal)
 		for _, d := range internalDefaults {
@@ -74,6 +80,29 @@ func (e *Explorer) initPersist() error {
 			}
 		}
 
+		// Databases created before the active contracts were tracked
+		// separately start from the totals in the latest block facts.
+		if b.Get(internalActiveContracts) == nil {
+			var active activeContractTotals
+			var height types.BlockHeight
+			var bf blockFacts
+			err := dbGetInternal(internalBlockHeight, &height)(tx)
+			if err != nil {
+				return err
+			}
+			if e.dbGetBlockFacts(height, &bf)(tx) == nil {
+				active = activeContractTotals{
+					Count: bf.ActiveContractCount,
+					Cost:  bf.ActiveContractCost,
+					Size:  bf.ActiveContractSize,
+				}
+			}
+			err = dbSetInternal(internalActiveContracts, active)(tx)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
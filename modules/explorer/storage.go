@@ -0,0 +1,155 @@
+package explorer
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// maxStatsPoints is the largest number of points that can be requested
+	// from a statistics history at once.
+	maxStatsPoints = 1000
+)
+
+var (
+	errStatsRange = errors.New("start height must not be greater than end height")
+	errStatsStep  = errors.New("step must be greater than zero")
+	errStatsLimit = errors.New("too many points requested; use a larger step or a smaller range")
+)
+
+// statsHeights returns the heights at every 'step' blocks from 'start' to
+// 'end', inclusive, for a statistics history. Heights beyond the latest block
+// in the explorer's database are ignored.
+func statsHeights(tx *bolt.Tx, start, end, step types.BlockHeight) ([]types.BlockHeight, error) {
+	if start > end {
+		return nil, errStatsRange
+	}
+	if step == 0 {
+		return nil, errStatsStep
+	}
+	var height types.BlockHeight
+	err := dbGetInternal(internalBlockHeight, &height)(tx)
+	if err != nil {
+		return nil, err
+	}
+	if end > height {
+		end = height
+	}
+	if start > end {
+		return nil, nil
+	}
+	if (end-start)/step+1 > maxStatsPoints {
+		return nil, errStatsLimit
+	}
+	var heights []types.BlockHeight
+	for h := start; h <= end; h += step {
+		heights = append(heights, h)
+	}
+	return heights, nil
+}
+
+// dbGetStoragePriceTotals returns the storage price totals at the provided
+// height. Blocks that were added before the totals were tracked have no
+// totals, and are treated as having no contracts.
+func (e *Explorer) dbGetStoragePriceTotals(tx *bolt.Tx, height types.BlockHeight) (storagePriceTotals, error) {
+	var totals storagePriceTotals
+	block, exists := e.cs.BlockAtHeight(height)
+	if !exists {
+		return totals, errors.New("requested storage price totals for a block that does not exist")
+	}
+	err := dbGetAndDecode(bucketStoragePriceTotals, block.ID(), &totals)(tx)
+	if err == errNotExist {
+		err = nil
+	}
+	return totals, err
+}
+
+// dbGetStorageStats returns a 'func(*bolt.Tx) error' that computes the storage
+// statistics at the provided height. The average storage price covers the
+// contracts formed and revised in the 'storagePriceWindow' blocks up to and
+// including the block at that height.
+func (e *Explorer) dbGetStorageStats(height types.BlockHeight, stats *modules.StorageStats) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		var bf blockFacts
+		err := e.dbGetBlockFacts(height, &bf)(tx)
+		if err != nil {
+			return err
+		}
+		totals, err := e.dbGetStoragePriceTotals(tx, height)
+		if err != nil {
+			return err
+		}
+		if height >= storagePriceWindow {
+			old, err := e.dbGetStoragePriceTotals(tx, height-storagePriceWindow)
+			if err != nil {
+				return err
+			}
+			totals.ContractValue = totals.ContractValue.Sub(old.ContractValue)
+			totals.ByteBlocks = totals.ByteBlocks.Sub(old.ByteBlocks)
+		}
+
+		*stats = modules.StorageStats{
+			Height:    bf.Height,
+			Timestamp: bf.Timestamp,
+
+			ActiveContractCount: bf.ActiveContractCount,
+			ActiveContractValue: bf.ActiveContractCost,
+			ActiveContractSize:  bf.ActiveContractSize,
+			TotalContractCount:  bf.FileContractCount,
+			TotalContractValue:  bf.TotalContractCost,
+		}
+		if !totals.ByteBlocks.IsZero() {
+			stats.AverageStoragePrice = totals.ContractValue.Div(totals.ByteBlocks)
+		}
+		return nil
+	}
+}
+
+// StorageStats returns statistics about the file contracts on the network as
+// of the latest block in the explorer's database.
+func (e *Explorer) StorageStats() modules.StorageStats {
+	var stats modules.StorageStats
+	err := e.db.View(func(tx *bolt.Tx) error {
+		var height types.BlockHeight
+		err := dbGetInternal(internalBlockHeight, &height)(tx)
+		if err != nil {
+			return err
+		}
+		return e.dbGetStorageStats(height, &stats)(tx)
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	return stats
+}
+
+// StorageStatsHistory returns the storage statistics at every 'step' blocks
+// from 'start' to 'end', inclusive. Heights beyond the latest block in the
+// explorer's database are ignored.
+func (e *Explorer) StorageStatsHistory(start, end, step types.BlockHeight) ([]modules.StorageStats, error) {
+	var stats []modules.StorageStats
+	err := e.db.View(func(tx *bolt.Tx) error {
+		heights, err := statsHeights(tx, start, end, step)
+		if err != nil {
+			return err
+		}
+		for _, h := range heights {
+			var ss modules.StorageStats
+			err := e.dbGetStorageStats(h, &ss)(tx)
+			if err != nil {
+				return err
+			}
+			stats = append(stats, ss)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
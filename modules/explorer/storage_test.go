@@ -0,0 +1,98 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationStorageStats checks that the storage statistics follow the
+// file contracts that are added to the blockchain.
+func TestIntegrationStorageStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester("TestIntegrationStorageStats")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := et.explorer.StorageStats()
+	if stats.Height != et.cs.Height() || stats.ActiveContractCount != 0 || !stats.ActiveContractSize.IsZero() || !stats.AverageStoragePrice.IsZero() {
+		t.Fatal("fresh explorer reports active contracts:", stats)
+	}
+	startHeight := et.cs.Height()
+
+	// Put a file contract into the chain.
+	fcOutputs := []types.SiacoinOutput{{Value: types.NewCurrency64(4805e6)}}
+	fc := types.FileContract{
+		FileSize:           5e3,
+		WindowStart:        et.cs.Height() + 10,
+		WindowEnd:          et.cs.Height() + 20,
+		Payout:             types.NewCurrency64(5e9),
+		ValidProofOutputs:  fcOutputs,
+		MissedProofOutputs: fcOutputs,
+	}
+	builder := et.wallet.StartTransaction()
+	err = builder.FundSiacoins(fc.Payout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.AddFileContract(fc)
+	txns, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = et.tpool.AcceptTransactionSet(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The contract covers its data from the block it was formed in until the
+	// end of its proof window.
+	byteBlocks := types.NewCurrency64(fc.FileSize).Mul64(uint64(fc.WindowEnd - et.cs.Height()))
+	expectedPrice := fc.Payout.Div(byteBlocks)
+	stats = et.explorer.StorageStats()
+	if stats.ActiveContractCount != 1 || stats.TotalContractCount != 1 {
+		t.Error("contract was not counted:", stats)
+	}
+	if stats.ActiveContractValue.Cmp(types.NewCurrency64(5e9)) != 0 || stats.TotalContractValue.Cmp(types.NewCurrency64(5e9)) != 0 {
+		t.Error("contract value was not tallied:", stats)
+	}
+	if stats.ActiveContractSize.Cmp(types.NewCurrency64(5e3)) != 0 {
+		t.Error("contract size was not tallied:", stats.ActiveContractSize)
+	}
+	if stats.AverageStoragePrice.Cmp(expectedPrice) != 0 {
+		t.Errorf("expected storage price %v, got %v", expectedPrice, stats.AverageStoragePrice)
+	}
+
+	// The history should show the contract appearing.
+	history, err := et.explorer.StorageStatsHistory(startHeight, et.cs.Height()+100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatal("expected 2 points, got", len(history))
+	}
+	if history[0].Height != startHeight || history[0].ActiveContractCount != 0 || !history[0].AverageStoragePrice.IsZero() {
+		t.Error("wrong first point:", history[0])
+	}
+	if history[1].Height != startHeight+1 || history[1].ActiveContractCount != 1 || history[1].AverageStoragePrice.Cmp(expectedPrice) != 0 {
+		t.Error("wrong second point:", history[1])
+	}
+
+	// Invalid requests are rejected.
+	if _, err := et.explorer.StorageStatsHistory(2, 1, 1); err != errStatsRange {
+		t.Error("expected errStatsRange, got", err)
+	}
+	if _, err := et.explorer.StorageStatsHistory(0, 1, 0); err != errStatsStep {
+		t.Error("expected errStatsStep, got", err)
+	}
+	if _, err := et.explorer.StorageStatsHistory(0, maxStatsPoints, 1); err != nil {
+		t.Error("heights beyond the current height were not ignored:", err)
+	}
+}
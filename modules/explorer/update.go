@@ -18,6 +18,15 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 		build.Critical("Explorer.ProcessConsensusChange called with a ConsensusChange that has no AppliedBlocks")
 	}
 
+	// Collect the values of the siafund claims created by the change so that
+	// they can be associated with the siafund inputs that created them.
+	claimValues := make(map[types.SiacoinOutputID]types.Currency)
+	for _, diff := range cc.DelayedSiacoinOutputDiffs {
+		if diff.Direction == modules.DiffApply {
+			claimValues[diff.ID] = diff.SiacoinOutput.Value
+		}
+	}
+
 	err := e.db.Update(func(tx *bolt.Tx) (err error) {
 		// use exception-style error handling to enable more concise update code
 		defer func() {
@@ -38,6 +47,11 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			bid := block.ID()
 			tbid := types.TransactionID(bid)
 
+			// A reverted block must be the tip of the explorer's chain. If it
+			// is not, the explorer has fallen out of sync with consensus, and
+			// the whole change is rolled back.
+			height := blockheight
+			dbCheckBlockHeight(tx, bid, height)
 			blockheight--
 			dbRemoveBlockID(tx, bid)
 			dbRemoveTransactionID(tx, tbid) // Miner payouts are a transaction
@@ -52,7 +66,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			for j, payout := range block.MinerPayouts {
 				scoid := block.MinerPayoutID(uint64(j))
 				dbRemoveSiacoinOutputID(tx, scoid, tbid)
-				dbRemoveUnlockHash(tx, payout.UnlockHash, tbid)
+				dbRemoveUnlockHash(tx, payout.UnlockHash, tbid, height)
 			}
 
 			// Remove transactions
@@ -62,43 +76,43 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 
 				for _, sci := range txn.SiacoinInputs {
 					dbRemoveSiacoinOutputID(tx, sci.ParentID, txid)
-					dbRemoveUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid)
+					dbRemoveUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid, height)
 				}
 				for k, sco := range txn.SiacoinOutputs {
 					scoid := txn.SiacoinOutputID(uint64(k))
 					dbRemoveSiacoinOutputID(tx, scoid, txid)
-					dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+					dbRemoveUnlockHash(tx, sco.UnlockHash, txid, height)
 					dbRemoveSiacoinOutput(tx, scoid)
 				}
 				for k, fc := range txn.FileContracts {
 					fcid := txn.FileContractID(uint64(k))
 					dbRemoveFileContractID(tx, fcid, txid)
-					dbRemoveUnlockHash(tx, fc.UnlockHash, txid)
+					dbRemoveUnlockHash(tx, fc.UnlockHash, txid, height)
 					for l, sco := range fc.ValidProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, height)
 					}
 					for l, sco := range fc.MissedProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, height)
 					}
 					dbRemoveFileContract(tx, fcid)
 				}
 				for _, fcr := range txn.FileContractRevisions {
 					dbRemoveFileContractID(tx, fcr.ParentID, txid)
-					dbRemoveUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid)
-					dbRemoveUnlockHash(tx, fcr.NewUnlockHash, txid)
+					dbRemoveUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid, height)
+					dbRemoveUnlockHash(tx, fcr.NewUnlockHash, txid, height)
 					for l, sco := range fcr.NewValidProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, height)
 					}
 					for l, sco := range fcr.NewMissedProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, height)
 					}
 					// Remove the file contract revision from the revision chain.
 					dbRemoveFileContractRevision(tx, fcr.ParentID)
@@ -108,18 +122,22 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 				}
 				for _, sfi := range txn.SiafundInputs {
 					dbRemoveSiafundOutputID(tx, sfi.ParentID, txid)
-					dbRemoveUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid)
-					dbRemoveUnlockHash(tx, sfi.ClaimUnlockHash, txid)
+					dbRemoveUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid, height)
+					dbRemoveUnlockHash(tx, sfi.ClaimUnlockHash, txid, height)
+					dbRemoveSiafundTransfer(tx, sfi.UnlockConditions.UnlockHash(), txid)
+					dbRemoveSiafundClaim(tx, sfi.UnlockConditions.UnlockHash(), sfi.ClaimUnlockHash, sfi.ParentID)
 				}
 				for k, sfo := range txn.SiafundOutputs {
 					sfoid := txn.SiafundOutputID(uint64(k))
 					dbRemoveSiafundOutputID(tx, sfoid, txid)
-					dbRemoveUnlockHash(tx, sfo.UnlockHash, txid)
+					dbRemoveUnlockHash(tx, sfo.UnlockHash, txid, height)
+					dbRemoveSiafundTransfer(tx, sfo.UnlockHash, txid)
 				}
 			}
 
 			// remove the associated block facts
 			dbRemoveBlockFacts(tx, bid)
+			dbRemoveStoragePriceTotals(tx, bid)
 		}
 
 		// Update cumulative stats for applied blocks.
@@ -133,6 +151,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 				continue
 			}
 
+			dbCheckBlockHeight(tx, block.ParentID, blockheight)
 			blockheight++
 			dbAddBlockID(tx, bid, blockheight)
 			dbAddTransactionID(tx, tbid, blockheight) // Miner payouts are a transaction
@@ -147,7 +166,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			for j, payout := range block.MinerPayouts {
 				scoid := block.MinerPayoutID(uint64(j))
 				dbAddSiacoinOutputID(tx, scoid, tbid)
-				dbAddUnlockHash(tx, payout.UnlockHash, tbid)
+				dbAddUnlockHash(tx, payout.UnlockHash, tbid, blockheight)
 			}
 
 			// Update cumulative stats for applied transactions.
@@ -158,43 +177,43 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 
 				for _, sci := range txn.SiacoinInputs {
 					dbAddSiacoinOutputID(tx, sci.ParentID, txid)
-					dbAddUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid)
+					dbAddUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid, blockheight)
 				}
 				for j, sco := range txn.SiacoinOutputs {
 					scoid := txn.SiacoinOutputID(uint64(j))
 					dbAddSiacoinOutputID(tx, scoid, txid)
-					dbAddUnlockHash(tx, sco.UnlockHash, txid)
+					dbAddUnlockHash(tx, sco.UnlockHash, txid, blockheight)
 					dbAddSiacoinOutput(tx, scoid, sco)
 				}
 				for k, fc := range txn.FileContracts {
 					fcid := txn.FileContractID(uint64(k))
 					dbAddFileContractID(tx, fcid, txid)
-					dbAddUnlockHash(tx, fc.UnlockHash, txid)
+					dbAddUnlockHash(tx, fc.UnlockHash, txid, blockheight)
 					dbAddFileContract(tx, fcid, fc)
 					for l, sco := range fc.ValidProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, blockheight)
 					}
 					for l, sco := range fc.MissedProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, blockheight)
 					}
 				}
 				for _, fcr := range txn.FileContractRevisions {
 					dbAddFileContractID(tx, fcr.ParentID, txid)
-					dbAddUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid)
-					dbAddUnlockHash(tx, fcr.NewUnlockHash, txid)
+					dbAddUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid, blockheight)
+					dbAddUnlockHash(tx, fcr.NewUnlockHash, txid, blockheight)
 					for l, sco := range fcr.NewValidProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, blockheight)
 					}
 					for l, sco := range fcr.NewMissedProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, blockheight)
 					}
 					dbAddFileContractRevision(tx, fcr.ParentID, fcr)
 				}
@@ -204,14 +223,30 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 				}
 				for _, sfi := range txn.SiafundInputs {
 					dbAddSiafundOutputID(tx, sfi.ParentID, txid)
-					dbAddUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid)
-					dbAddUnlockHash(tx, sfi.ClaimUnlockHash, txid)
+					dbAddUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid, blockheight)
+					dbAddUnlockHash(tx, sfi.ClaimUnlockHash, txid, blockheight)
+					dbAddSiafundTransfer(tx, sfi.UnlockConditions.UnlockHash(), txid)
+
+					var sfo types.SiafundOutput
+					assertNil(dbGetAndDecode(bucketSiafundOutputs, sfi.ParentID, &sfo)(tx))
+					claimID := sfi.ParentID.SiaClaimOutputID()
+					dbAddSiafundClaim(tx, modules.SiafundClaim{
+						SiafundOutputID: sfi.ParentID,
+						ClaimOutputID:   claimID,
+						TransactionID:   txid,
+						Height:          blockheight,
+						UnlockHash:      sfi.UnlockConditions.UnlockHash(),
+						ClaimUnlockHash: sfi.ClaimUnlockHash,
+						Siafunds:        sfo.Value,
+						Value:           claimValues[claimID],
+					})
 				}
 				for k, sfo := range txn.SiafundOutputs {
 					sfoid := txn.SiafundOutputID(uint64(k))
 					dbAddSiafundOutputID(tx, sfoid, txid)
-					dbAddUnlockHash(tx, sfo.UnlockHash, txid)
+					dbAddUnlockHash(tx, sfo.UnlockHash, txid, blockheight)
 					dbAddSiafundOutput(tx, sfoid, sfo)
+					dbAddSiafundTransfer(tx, sfo.UnlockHash, txid)
 				}
 			}
 
@@ -220,13 +255,35 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 				facts := dbCalculateBlockFacts(tx, e.cs, block)
 				dbAddBlockFacts(tx, facts)
 			}
+			dbAddStoragePriceTotals(tx, bid, dbCalculateStoragePriceTotals(tx, block, blockheight))
 		}
 
-		// Compute the changes in the active set. Note, because this is calculated
-		// at the end instead of in a loop, the historic facts may contain
-		// inaccuracies about the active set. This should not be a problem except
-		// for large reorgs.
-		// TODO: improve this
+		// Compute the changes in the active set. The running totals of the
+		// active set follow the file contract diffs exactly, including those
+		// of reverted blocks, and are copied into the facts of the current
+		// block. Note, because this is calculated at the end instead of in a
+		// loop, the facts of the intermediate blocks of a change may contain
+		// inaccuracies about the active set.
+		var active activeContractTotals
+		err = dbGetInternal(internalActiveContracts, &active)(tx)
+		if err != nil {
+			return err
+		}
+		for _, diff := range cc.FileContractDiffs {
+			if diff.Direction == modules.DiffApply {
+				active.Count++
+				active.Cost = active.Cost.Add(diff.FileContract.Payout)
+				active.Size = active.Size.Add(types.NewCurrency64(diff.FileContract.FileSize))
+			} else {
+				active.Count--
+				active.Cost = active.Cost.Sub(diff.FileContract.Payout)
+				active.Size = active.Size.Sub(types.NewCurrency64(diff.FileContract.FileSize))
+			}
+		}
+		err = dbSetInternal(internalActiveContracts, active)(tx)
+		if err != nil {
+			return err
+		}
 		currentBlock, exists := e.cs.BlockAtHeight(blockheight)
 		if !exists {
 			build.Critical("consensus is missing block", blockheight)
@@ -235,23 +292,36 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 		var facts blockFacts
 		err = dbGetAndDecode(bucketBlockFacts, currentID, &facts)(tx)
 		if err == nil {
-			for _, diff := range cc.FileContractDiffs {
-				if diff.Direction == modules.DiffApply {
-					facts.ActiveContractCount++
-					facts.ActiveContractCost = facts.ActiveContractCost.Add(diff.FileContract.Payout)
-					facts.ActiveContractSize = facts.ActiveContractSize.Add(types.NewCurrency64(diff.FileContract.FileSize))
-				} else {
-					facts.ActiveContractCount--
-					facts.ActiveContractCost = facts.ActiveContractCost.Sub(diff.FileContract.Payout)
-					facts.ActiveContractSize = facts.ActiveContractSize.Sub(types.NewCurrency64(diff.FileContract.FileSize))
-				}
-			}
+			facts.ActiveContractCount = active.Count
+			facts.ActiveContractCost = active.Cost
+			facts.ActiveContractSize = active.Size
 			err = tx.Bucket(bucketBlockFacts).Put(encoding.Marshal(currentID), encoding.Marshal(facts))
 			if err != nil {
 				return err
 			}
 		}
 
+		// Update the siafund holdings and the value of the siafund pool. The
+		// siafund output diffs contain the claim start of each output, which
+		// is needed to compute the unclaimed siacoins of each holder.
+		for _, diff := range cc.SiafundOutputDiffs {
+			if diff.Direction == modules.DiffApply {
+				dbAddSiafundHolding(tx, diff.ID, diff.SiafundOutput)
+			} else {
+				dbRemoveSiafundHolding(tx, diff.ID, diff.SiafundOutput)
+			}
+		}
+		for _, diff := range cc.SiafundPoolDiffs {
+			pool := diff.Adjusted
+			if diff.Direction == modules.DiffRevert {
+				pool = diff.Previous
+			}
+			err = dbSetInternal(internalSiafundPool, pool)(tx)
+			if err != nil {
+				return err
+			}
+		}
+
 		// set final blockheight
 		err = dbSetInternal(internalBlockHeight, blockheight)(tx)
 		if err != nil {
@@ -287,6 +357,20 @@ func mustDelete(bucket *bolt.Bucket, key interface{}) {
 	assertNil(bucket.Delete(encoding.Marshal(key)))
 }
 
+// mustDeleteFromSet removes a member from the set stored in the nested bucket
+// 'key' of 'parent'. The nested bucket is deleted once it is empty, so that
+// reverting a block leaves no trace of the objects it introduced.
+func mustDeleteFromSet(parent *bolt.Bucket, key, member interface{}) {
+	b := parent.Bucket(encoding.Marshal(key))
+	if b == nil {
+		return
+	}
+	mustDelete(b, member)
+	if k, _ := b.Cursor().First(); k == nil {
+		assertNil(parent.DeleteBucket(encoding.Marshal(key)))
+	}
+}
+
 // These functions panic on error. The panic will be caught by
 // ProcessConsensusChange.
 
@@ -298,6 +382,16 @@ func dbRemoveBlockID(tx *bolt.Tx, id types.BlockID) {
 	mustDelete(tx.Bucket(bucketBlockIDs), id)
 }
 
+// dbCheckBlockHeight panics if the block is not in the explorer's chain at the
+// provided height.
+func dbCheckBlockHeight(tx *bolt.Tx, id types.BlockID, height types.BlockHeight) {
+	var stored types.BlockHeight
+	err := dbGetAndDecode(bucketBlockIDs, id, &stored)(tx)
+	if err != nil || stored != height {
+		panic(fmt.Sprintf("block %v is not at height %v of the explorer's chain", id, height))
+	}
+}
+
 // Add/Remove block facts
 func dbAddBlockFacts(tx *bolt.Tx, facts blockFacts) {
 	mustPut(tx.Bucket(bucketBlockFacts), facts.BlockID, facts)
@@ -330,8 +424,7 @@ func dbAddFileContractID(tx *bolt.Tx, id types.FileContractID, txid types.Transa
 	mustPutSet(b, txid)
 }
 func dbRemoveFileContractID(tx *bolt.Tx, id types.FileContractID, txid types.TransactionID) {
-	// TODO: delete bucket when it becomes empty
-	mustDelete(tx.Bucket(bucketFileContractIDs).Bucket(encoding.Marshal(id)), txid)
+	mustDeleteFromSet(tx.Bucket(bucketFileContractIDs), id, txid)
 }
 
 func dbAddFileContractRevision(tx *bolt.Tx, fcid types.FileContractID, fcr types.FileContractRevision) {
@@ -363,8 +456,7 @@ func dbAddSiacoinOutputID(tx *bolt.Tx, id types.SiacoinOutputID, txid types.Tran
 	mustPutSet(b, txid)
 }
 func dbRemoveSiacoinOutputID(tx *bolt.Tx, id types.SiacoinOutputID, txid types.TransactionID) {
-	// TODO: delete bucket when it becomes empty
-	mustDelete(tx.Bucket(bucketSiacoinOutputIDs).Bucket(encoding.Marshal(id)), txid)
+	mustDeleteFromSet(tx.Bucket(bucketSiacoinOutputIDs), id, txid)
 }
 
 // Add/Remove siafund output
@@ -382,8 +474,48 @@ func dbAddSiafundOutputID(tx *bolt.Tx, id types.SiafundOutputID, txid types.Tran
 	mustPutSet(b, txid)
 }
 func dbRemoveSiafundOutputID(tx *bolt.Tx, id types.SiafundOutputID, txid types.TransactionID) {
-	// TODO: delete bucket when it becomes empty
-	mustDelete(tx.Bucket(bucketSiafundOutputIDs).Bucket(encoding.Marshal(id)), txid)
+	mustDeleteFromSet(tx.Bucket(bucketSiafundOutputIDs), id, txid)
+}
+
+// Add/Remove siafund output from the holdings of its unlock hash
+func dbAddSiafundHolding(tx *bolt.Tx, id types.SiafundOutputID, sfo types.SiafundOutput) {
+	b, err := tx.Bucket(bucketSiafundHoldings).CreateBucketIfNotExists(encoding.Marshal(sfo.UnlockHash))
+	assertNil(err)
+	mustPut(b, id, sfo)
+}
+func dbRemoveSiafundHolding(tx *bolt.Tx, id types.SiafundOutputID, sfo types.SiafundOutput) {
+	holdings := tx.Bucket(bucketSiafundHoldings)
+	b := holdings.Bucket(encoding.Marshal(sfo.UnlockHash))
+	mustDelete(b, id)
+	// Remove the holder entirely once it holds no siafunds.
+	if k, _ := b.Cursor().First(); k == nil {
+		assertNil(holdings.DeleteBucket(encoding.Marshal(sfo.UnlockHash)))
+	}
+}
+
+// Add/Remove txid from siafund transfer bucket
+func dbAddSiafundTransfer(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID) {
+	b, err := tx.Bucket(bucketSiafundTransfers).CreateBucketIfNotExists(encoding.Marshal(uh))
+	assertNil(err)
+	mustPutSet(b, txid)
+}
+func dbRemoveSiafundTransfer(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID) {
+	mustDeleteFromSet(tx.Bucket(bucketSiafundTransfers), uh, txid)
+}
+
+// Add/Remove siafund claim. Claims are indexed by both the owner of the
+// spent siafunds and the recipient of the claim.
+func dbAddSiafundClaim(tx *bolt.Tx, claim modules.SiafundClaim) {
+	for _, uh := range []types.UnlockHash{claim.UnlockHash, claim.ClaimUnlockHash} {
+		b, err := tx.Bucket(bucketSiafundClaims).CreateBucketIfNotExists(encoding.Marshal(uh))
+		assertNil(err)
+		mustPut(b, claim.SiafundOutputID, claim)
+	}
+}
+func dbRemoveSiafundClaim(tx *bolt.Tx, uh, claimUH types.UnlockHash, id types.SiafundOutputID) {
+	for _, uh := range []types.UnlockHash{uh, claimUH} {
+		mustDeleteFromSet(tx.Bucket(bucketSiafundClaims), uh, id)
+	}
 }
 
 // Add/Remove storage proof
@@ -397,6 +529,14 @@ func dbRemoveStorageProof(tx *bolt.Tx, fcid types.FileContractID) {
 	dbAddStorageProof(tx, fcid, types.StorageProof{})
 }
 
+// Add/Remove storage price totals
+func dbAddStoragePriceTotals(tx *bolt.Tx, id types.BlockID, totals storagePriceTotals) {
+	mustPut(tx.Bucket(bucketStoragePriceTotals), id, totals)
+}
+func dbRemoveStoragePriceTotals(tx *bolt.Tx, id types.BlockID) {
+	mustDelete(tx.Bucket(bucketStoragePriceTotals), id)
+}
+
 // Add/Remove transaction ID
 func dbAddTransactionID(tx *bolt.Tx, id types.TransactionID, height types.BlockHeight) {
 	mustPut(tx.Bucket(bucketTransactionIDs), id, height)
@@ -405,15 +545,24 @@ func dbRemoveTransactionID(tx *bolt.Tx, id types.TransactionID) {
 	mustDelete(tx.Bucket(bucketTransactionIDs), id)
 }
 
-// Add/Remove txid from unlock hash bucket
-func dbAddUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID) {
+// Add/Remove txid from unlock hash bucket, and from the unlock hash's history
+func dbAddUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID, height types.BlockHeight) {
 	b, err := tx.Bucket(bucketUnlockHashes).CreateBucketIfNotExists(encoding.Marshal(uh))
 	assertNil(err)
 	mustPutSet(b, txid)
-}
-func dbRemoveUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID) {
-	// TODO: delete bucket when it becomes empty
-	mustDelete(tx.Bucket(bucketUnlockHashes).Bucket(encoding.Marshal(uh)), txid)
+	b, err = tx.Bucket(bucketUnlockHashHistory).CreateBucketIfNotExists(encoding.Marshal(uh))
+	assertNil(err)
+	assertNil(b.Put(unlockHashHistoryKey(height, txid), nil))
+}
+func dbRemoveUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID, height types.BlockHeight) {
+	mustDeleteFromSet(tx.Bucket(bucketUnlockHashes), uh, txid)
+	history := tx.Bucket(bucketUnlockHashHistory)
+	if b := history.Bucket(encoding.Marshal(uh)); b != nil {
+		assertNil(b.Delete(unlockHashHistoryKey(height, txid)))
+		if k, _ := b.Cursor().First(); k == nil {
+			assertNil(history.DeleteBucket(encoding.Marshal(uh)))
+		}
+	}
 }
 
 func dbCalculateBlockFacts(tx *bolt.Tx, cs modules.ConsensusSet, block types.Block) blockFacts {
@@ -496,6 +645,37 @@ func dbCalculateBlockFacts(tx *bolt.Tx, cs modules.ConsensusSet, block types.Blo
 	return bf
 }
 
+// dbCalculateStoragePriceTotals adds the file contracts formed and revised in
+// a block to the storage price totals of its parent. Each contract adds its
+// payout and the data it covers until the end of its proof window. Blocks
+// added before the totals were tracked are treated as having no contracts.
+func dbCalculateStoragePriceTotals(tx *bolt.Tx, block types.Block, height types.BlockHeight) storagePriceTotals {
+	var totals storagePriceTotals
+	err := dbGetAndDecode(bucketStoragePriceTotals, block.ParentID, &totals)(tx)
+	if err != nil && err != errNotExist {
+		panic(err)
+	}
+
+	add := func(payout types.Currency, fileSize uint64, windowEnd types.BlockHeight) {
+		if fileSize == 0 || windowEnd <= height {
+			return
+		}
+		totals.ContractValue = totals.ContractValue.Add(payout)
+		totals.ByteBlocks = totals.ByteBlocks.Add(types.NewCurrency64(fileSize).Mul64(uint64(windowEnd - height)))
+	}
+	for _, txn := range block.Transactions {
+		for _, fc := range txn.FileContracts {
+			add(fc.Payout, fc.FileSize, fc.WindowEnd)
+		}
+		for _, fcr := range txn.FileContractRevisions {
+			var history fileContractHistory
+			assertNil(dbGetAndDecode(bucketFileContractHistories, fcr.ParentID, &history)(tx))
+			add(history.Contract.Payout, fcr.NewFileSize, fcr.NewWindowEnd)
+		}
+	}
+	return totals
+}
+
 // Special handling for the genesis block. No other functions are called on it.
 func dbAddGenesisBlock(tx *bolt.Tx) {
 	id := types.GenesisID
@@ -505,7 +685,7 @@ func dbAddGenesisBlock(tx *bolt.Tx) {
 	for i, sfo := range types.GenesisSiafundAllocation {
 		sfoid := types.GenesisBlock.Transactions[0].SiafundOutputID(uint64(i))
 		dbAddSiafundOutputID(tx, sfoid, txid)
-		dbAddUnlockHash(tx, sfo.UnlockHash, txid)
+		dbAddUnlockHash(tx, sfo.UnlockHash, txid, 0)
 		dbAddSiafundOutput(tx, sfoid, sfo)
 	}
 	dbAddBlockFacts(tx, blockFacts{
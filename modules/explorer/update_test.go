@@ -183,27 +183,24 @@ func TestIntegrationExplorerFileContractMetrics(t *testing.T) {
 
 	// Reorg the block explorer to a blank state, see that all of the file
 	// contract statistics got removed.
-
-	// TODO: broken by new block facts model
-
-	// err = et.reorgToBlank()
-	// if err != nil {
-	// 	t.Fatal(err)
-	// }
-	// facts, ok = et.currentFacts()
-	// if !ok {
-	// 	t.Fatal("couldn't get current facts")
-	// }
-	// if !facts.ActiveContractCost.IsZero() {
-	// 	t.Error("post reorg active contract cost should be zero, got", facts.ActiveContractCost)
-	// }
-	// if facts.ActiveContractCount != 0 {
-	// 	t.Error("post reorg active contract count should be zero, got", facts.ActiveContractCount)
-	// }
-	// if !facts.TotalContractCost.IsZero() {
-	// 	t.Error("post reorg total contract cost should be zero, got", facts.TotalContractCost)
-	// }
-	// if facts.FileContractCount != 0 {
-	// 	t.Error("post reorg file contract count should be zero, got", facts.FileContractCount)
-	// }
+	err = et.reorgToBlank()
+	if err != nil {
+		t.Fatal(err)
+	}
+	facts, ok = et.currentFacts()
+	if !ok {
+		t.Fatal("couldn't get current facts")
+	}
+	if !facts.ActiveContractCost.IsZero() {
+		t.Error("post reorg active contract cost should be zero, got", facts.ActiveContractCost)
+	}
+	if facts.ActiveContractCount != 0 {
+		t.Error("post reorg active contract count should be zero, got", facts.ActiveContractCount)
+	}
+	if !facts.TotalContractCost.IsZero() {
+		t.Error("post reorg total contract cost should be zero, got", facts.TotalContractCost)
+	}
+	if facts.FileContractCount != 0 {
+		t.Error("post reorg file contract count should be zero, got", facts.FileContractCount)
+	}
 }
@@ -0,0 +1,176 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// sealedFileKeysVersion is the version of the format of SealedFileKeys.
+	sealedFileKeysVersion = "1.1"
+
+	// fileKeysScryptR and fileKeysScryptP are the scrypt block size and
+	// parallelization parameters used to seal file keys.
+	fileKeysScryptR = 8
+	fileKeysScryptP = 1
+
+	// maxFileKeysScryptMemory is the most memory, in bytes, that opening
+	// sealed file keys may require. It prevents a crafted export from
+	// exhausting the memory of the machine opening it.
+	maxFileKeysScryptMemory = 1 << 30
+)
+
+var (
+	// fileKeysScryptN is the scrypt cost parameter used to seal file keys.
+	// Testing builds use a low cost to keep the tests fast.
+	fileKeysScryptN = func() int {
+		switch build.Release {
+		case "dev", "standard":
+			return 1 << 15
+		case "testing":
+			return 1 << 10
+		default:
+			panic("unrecognized build.Release constant in fileKeysScryptN")
+		}
+	}()
+
+	errBadFileKeysScryptParams = errors.New("sealed file keys have invalid scrypt parameters")
+
+	// ErrBadFileKeysPassword is returned when sealed file keys are opened
+	// with the wrong password.
+	ErrBadFileKeysPassword = errors.New("file keys could not be decrypted, the password is likely wrong")
+
+	// ErrNoFileKeysPassword is returned when file keys are sealed or opened
+	// without a password.
+	ErrNoFileKeysPassword = errors.New("a password is required to protect the file keys")
+)
+
+type (
+	// FileKeys is the material needed to recover a single file without the
+	// renter: the layout of the file and, for each piece, the host that
+	// stores it and the key that decrypts it. The piece keys are derived from
+	// the file's master key, which cannot be recovered from them, so the keys
+	// of one file can be handed to someone else without exposing any other
+	// file encrypted with the same master key.
+	FileKeys struct {
+		SiaPath      string `json:"siapath"`
+		Filesize     uint64 `json:"filesize"`     // bytes
+		PieceSize    uint64 `json:"piecesize"`    // bytes of the file in each piece
+		DataPieces   int    `json:"datapieces"`   // pieces needed to recover a chunk
+		ParityPieces int    `json:"paritypieces"` // extra pieces of each chunk
+		Encrypted    bool   `json:"encrypted"`    // false if the data was pre-encrypted
+
+		// Cipher is the cipher that the piece keys are used with. Keys
+		// exported before the cipher could be chosen are Twofish keys.
+		Cipher crypto.CipherType `json:"cipher,omitempty"`
+
+		// Checksum is the hex-encoded SHA-256 hash of the uploaded data,
+		// which the recovered file can be checked against. It is omitted
+		// if no checksum was recorded for the file.
+		Checksum string `json:"checksum,omitempty"`
+
+		Pieces []PieceKey `json:"pieces"`
+	}
+
+	// PieceKey locates a piece of a file and holds the key that decrypts it.
+	// Key is empty if the file is not encrypted by the renter.
+	PieceKey struct {
+		Chunk      uint64               `json:"chunk"`
+		Piece      uint64               `json:"piece"`
+		MerkleRoot crypto.Hash          `json:"merkleroot"`
+		ContractID types.FileContractID `json:"contractid"`
+		NetAddress NetAddress           `json:"netaddress"`
+		Key        string               `json:"key,omitempty"` // hex
+	}
+
+	// SealedFileKeys is a FileKeys encrypted with a key derived from a
+	// password and a random salt using scrypt. The scrypt parameters are
+	// recorded so that the cost can be raised without breaking old exports.
+	SealedFileKeys struct {
+		Version    string            `json:"version"`
+		Salt       crypto.Hash       `json:"salt"`
+		ScryptN    int               `json:"scryptn"`
+		ScryptR    int               `json:"scryptr"`
+		ScryptP    int               `json:"scryptp"`
+		Ciphertext crypto.Ciphertext `json:"ciphertext"`
+	}
+)
+
+// fileKeysEncryptionKey derives the key that seals file keys from a password
+// using scrypt with the provided parameters.
+func fileKeysEncryptionKey(password string, salt crypto.Hash, n, r, p int) (crypto.TwofishKey, error) {
+	// scrypt needs 128*n*r bytes of memory, and rejects parameters that are
+	// not sane.
+	if n <= 1 || r <= 0 || p <= 0 || uint64(n)*uint64(r) > maxFileKeysScryptMemory/128 {
+		return crypto.TwofishKey{}, errBadFileKeysScryptParams
+	}
+	var key crypto.TwofishKey
+	derived, err := scrypt.Key([]byte(password), salt[:], n, r, p, len(key))
+	if err != nil {
+		return crypto.TwofishKey{}, errBadFileKeysScryptParams
+	}
+	copy(key[:], derived)
+	return key, nil
+}
+
+// SealFileKeys encrypts file keys with a password.
+func SealFileKeys(fk FileKeys, password string) (SealedFileKeys, error) {
+	if password == "" {
+		return SealedFileKeys{}, ErrNoFileKeysPassword
+	}
+	plaintext, err := json.Marshal(fk)
+	if err != nil {
+		return SealedFileKeys{}, err
+	}
+	saltBytes, err := crypto.RandBytes(len(crypto.Hash{}))
+	if err != nil {
+		return SealedFileKeys{}, err
+	}
+	var salt crypto.Hash
+	copy(salt[:], saltBytes)
+	key, err := fileKeysEncryptionKey(password, salt, fileKeysScryptN, fileKeysScryptR, fileKeysScryptP)
+	if err != nil {
+		return SealedFileKeys{}, err
+	}
+	ciphertext, err := key.EncryptBytes(plaintext)
+	if err != nil {
+		return SealedFileKeys{}, err
+	}
+	return SealedFileKeys{
+		Version:    sealedFileKeysVersion,
+		Salt:       salt,
+		ScryptN:    fileKeysScryptN,
+		ScryptR:    fileKeysScryptR,
+		ScryptP:    fileKeysScryptP,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// OpenFileKeys decrypts file keys that were sealed with SealFileKeys.
+func OpenFileKeys(sealed SealedFileKeys, password string) (FileKeys, error) {
+	if password == "" {
+		return FileKeys{}, ErrNoFileKeysPassword
+	}
+	if sealed.Version != sealedFileKeysVersion {
+		return FileKeys{}, errors.New("unrecognized file keys version: " + sealed.Version)
+	}
+	key, err := fileKeysEncryptionKey(password, sealed.Salt, sealed.ScryptN, sealed.ScryptR, sealed.ScryptP)
+	if err != nil {
+		return FileKeys{}, err
+	}
+	plaintext, err := key.DecryptBytes(sealed.Ciphertext)
+	if err != nil {
+		return FileKeys{}, ErrBadFileKeysPassword
+	}
+	var fk FileKeys
+	if err := json.Unmarshal(plaintext, &fk); err != nil {
+		return FileKeys{}, err
+	}
+	return fk, nil
+}
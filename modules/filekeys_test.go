@@ -0,0 +1,57 @@
+package modules
+
+import (
+	"testing"
+)
+
+// TestSealFileKeys checks that sealed file keys can only be opened with the
+// password they were sealed with.
+func TestSealFileKeys(t *testing.T) {
+	fk := FileKeys{
+		SiaPath:    "foo",
+		Filesize:   100,
+		DataPieces: 1,
+		Encrypted:  true,
+		Pieces:     []PieceKey{{Chunk: 0, Piece: 0, NetAddress: "foo:1234", Key: "abcd"}},
+	}
+	if _, err := SealFileKeys(fk, ""); err != ErrNoFileKeysPassword {
+		t.Fatal("expected ErrNoFileKeysPassword, got", err)
+	}
+	sealed, err := SealFileKeys(fk, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileKeys(sealed, "wrong"); err != ErrBadFileKeysPassword {
+		t.Fatal("expected ErrBadFileKeysPassword, got", err)
+	}
+	opened, err := OpenFileKeys(sealed, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.SiaPath != fk.SiaPath || len(opened.Pieces) != 1 || opened.Pieces[0] != fk.Pieces[0] {
+		t.Fatal("opened file keys do not match:", opened)
+	}
+
+	// The same keys sealed twice use different salts.
+	sealed2, err := SealFileKeys(fk, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed2.Salt == sealed.Salt {
+		t.Error("file keys were sealed with the same salt twice")
+	}
+
+	// The scrypt parameters are recorded, and keys sealed with parameters
+	// that would need too much memory are rejected without deriving a key.
+	if sealed.ScryptN != fileKeysScryptN || sealed.ScryptR != fileKeysScryptR || sealed.ScryptP != fileKeysScryptP {
+		t.Error("scrypt parameters were not recorded:", sealed.ScryptN, sealed.ScryptR, sealed.ScryptP)
+	}
+	sealed.ScryptN = 1 << 30
+	if _, err := OpenFileKeys(sealed, "password"); err != errBadFileKeysScryptParams {
+		t.Error("expected errBadFileKeysScryptParams, got", err)
+	}
+	sealed.ScryptN = 3
+	if _, err := OpenFileKeys(sealed, "password"); err != errBadFileKeysScryptParams {
+		t.Error("expected errBadFileKeysScryptParams, got", err)
+	}
+}
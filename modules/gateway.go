@@ -1,7 +1,9 @@
 package modules
 
 import (
+	"io"
 	"net"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 )
@@ -115,6 +117,74 @@ type (
 		Local      bool       `json:"local"`
 		NetAddress NetAddress `json:"netaddress"`
 		Version    string     `json:"version"`
+
+		// Score reflects how well the peer has behaved so far. Useful relays
+		// raise the score, while protocol violations and high latency lower
+		// it.
+		Score int64 `json:"score"`
+	}
+
+	// A PeerBan is a host that the Gateway refuses to connect to or accept
+	// connections from because a peer on that host misbehaved.
+	PeerBan struct {
+		Host   string    `json:"host"`
+		Expiry time.Time `json:"expiry"`
+	}
+
+	// NATStatus describes how the Gateway's port was mapped on the router,
+	// and whether the Gateway can be reached by other nodes.
+	NATStatus struct {
+		// Method is "upnp" or "nat-pmp" if the port was mapped automatically,
+		// and empty if no mapping could be made.
+		Method string `json:"method"`
+
+		// ExternalIP is the IP address of the Gateway as seen by the rest of
+		// the network. It is empty if it could not be discovered.
+		ExternalIP string `json:"externalip"`
+
+		// Reachable is true if the last reachability test succeeded.
+		// LastChecked is the time of that test, and is zero if no test has
+		// been performed yet.
+		Reachable   bool      `json:"reachable"`
+		LastChecked time.Time `json:"lastchecked"`
+	}
+
+	// GatewaySettings control how many peers the Gateway connects to and how
+	// it treats misbehaving peers. Local peers do not count towards the
+	// limits.
+	GatewaySettings struct {
+		// MaxPeers is the maximum number of peers. Once it is reached, an
+		// inbound peer is kicked to make room for each new peer.
+		MaxPeers int `json:"maxpeers"`
+
+		// MaxInboundPeers is the maximum number of inbound peers. It cannot
+		// be larger than MaxPeers.
+		MaxInboundPeers int `json:"maxinboundpeers"`
+
+		// MaxPeersPerSubnet is the maximum number of peers that can share a
+		// subnet (a /24 for IPv4 and a /64 for IPv6).
+		MaxPeersPerSubnet int `json:"maxpeerspersubnet"`
+
+		// BanDuration is how long misbehaving hosts are banned for.
+		BanDuration time.Duration `json:"banduration"`
+
+		// BandwidthLimits maps RPC names to the number of bytes per second
+		// that may be transferred for that RPC, summed over all peers. RPCs
+		// without a limit are not throttled.
+		BandwidthLimits map[string]uint64 `json:"bandwidthlimits"`
+	}
+
+	// RPCBandwidth is the amount of data that the Gateway has transferred
+	// for an RPC since startup, counting both the RPCs that it called and
+	// the RPCs that it handled.
+	RPCBandwidth struct {
+		RPC      string `json:"rpc"`
+		Sent     uint64 `json:"sent"`     // bytes
+		Received uint64 `json:"received"` // bytes
+
+		// Limit is the number of bytes per second that may be transferred
+		// for the RPC, or 0 if the RPC is not limited.
+		Limit uint64 `json:"limit"`
 	}
 
 	// A PeerConn is the connection type used when communicating with peers during
@@ -133,6 +203,15 @@ type (
 	// keeping the connection open after all necessary I/O has been performed.
 	RPCFunc func(PeerConn) error
 
+	// A ProtocolViolation is returned by an RPCFunc when the calling peer
+	// violated the protocol, for example by sending an object that could not
+	// be decoded, or an invalid block or transaction. The gateway lowers the
+	// score of the peer for each violation; other errors returned by an
+	// RPCFunc do not affect the peer's score.
+	ProtocolViolation struct {
+		Err error
+	}
+
 	// A Gateway facilitates the interactions between the local node and remote
 	// nodes (peers). It relays incoming blocks and transactions to local modules,
 	// and broadcasts outgoing blocks and transactions to peers. In a broad sense,
@@ -145,12 +224,36 @@ type (
 		// Disconnect terminates a connection to a peer.
 		Disconnect(NetAddress) error
 
+		// UnpinNode clears the public key pinned for the node that the
+		// address led to, so that the next key presented by the node is
+		// pinned instead.
+		UnpinNode(NetAddress) error
+
 		// Address returns the Gateway's address.
 		Address() NetAddress
 
 		// Peers returns the addresses that the Gateway is currently connected to.
 		Peers() []Peer
 
+		// Bans returns the hosts that are currently banned.
+		Bans() []PeerBan
+
+		// NATStatus returns how the Gateway's port was mapped on the router,
+		// and whether the Gateway is reachable by other nodes.
+		NATStatus() NATStatus
+
+		// Bandwidth returns the amount of data that the Gateway has
+		// transferred for each RPC.
+		Bandwidth() []RPCBandwidth
+
+		// Settings returns the Gateway's settings.
+		Settings() GatewaySettings
+
+		// SetSettings changes the Gateway's settings. If the limits are
+		// lowered, the lowest scoring peers are disconnected until the limits
+		// are met. Bans that are already in place are not affected.
+		SetSettings(GatewaySettings) error
+
 		// RegisterRPC registers a function to handle incoming connections that
 		// supply the given RPC ID.
 		RegisterRPC(string, RPCFunc)
@@ -183,3 +286,30 @@ type (
 		Close() error
 	}
 )
+
+// NewProtocolViolation marks an error returned by an RPCFunc as a protocol
+// violation of the calling peer. A nil error is returned unchanged.
+func NewProtocolViolation(err error) error {
+	if err == nil {
+		return nil
+	}
+	return ProtocolViolation{Err: err}
+}
+
+// DecodeViolation marks an error returned while reading an object sent by a
+// peer as a protocol violation, unless the error was caused by the connection
+// failing rather than by the object being malformed.
+func DecodeViolation(err error) error {
+	if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return err
+	}
+	if _, ok := err.(net.Error); ok {
+		return err
+	}
+	return ProtocolViolation{Err: err}
+}
+
+// Error implements the error interface.
+func (pv ProtocolViolation) Error() string {
+	return pv.Err.Error()
+}
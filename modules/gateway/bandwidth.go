@@ -0,0 +1,167 @@
+package gateway
+
+// bandwidth.go keeps track of how much data the gateway sends and receives
+// for each RPC, so that operators can see where their bandwidth goes. Both
+// the RPCs that the gateway calls and the RPCs that it serves are counted,
+// including the RPC header. Each RPC can also be given a limit, in bytes per
+// second, which is shared by all connections that use that RPC. Connections
+// that exceed the limit are slowed down rather than dropped.
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var errEmptyRPCName = errors.New("bandwidth limits must specify an RPC name")
+
+// rpcBandwidth tracks the data transferred for a single RPC and throttles
+// transfers that exceed the RPC's limit.
+type rpcBandwidth struct {
+	name string
+
+	mu       sync.Mutex
+	sent     uint64
+	received uint64
+
+	// limit is the number of bytes per second that may be transferred for
+	// the RPC, or 0 if the RPC is not limited. next is the time at which all
+	// of the data transferred so far is within the limit.
+	limit uint64
+	next  time.Time
+}
+
+// record adds n bytes to the counters of the RPC, and returns how long the
+// caller needs to wait for the transfer to be within the RPC's limit.
+func (b *rpcBandwidth) record(n int, sent bool) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sent {
+		b.sent += uint64(n)
+	} else {
+		b.received += uint64(n)
+	}
+	if b.limit == 0 || n <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	b.next = b.next.Add(time.Duration(uint64(n) * uint64(time.Second) / b.limit))
+	return b.next.Sub(now)
+}
+
+// setLimit changes the limit of the RPC.
+func (b *rpcBandwidth) setLimit(limit uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+}
+
+// info returns the counters and the limit of the RPC.
+func (b *rpcBandwidth) info() modules.RPCBandwidth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return modules.RPCBandwidth{
+		RPC:      b.name,
+		Sent:     b.sent,
+		Received: b.received,
+		Limit:    b.limit,
+	}
+}
+
+// byRPCName sorts bandwidth counters by the name of their RPC.
+type byRPCName []modules.RPCBandwidth
+
+func (bn byRPCName) Len() int           { return len(bn) }
+func (bn byRPCName) Less(i, j int) bool { return bn[i].RPC < bn[j].RPC }
+func (bn byRPCName) Swap(i, j int)      { bn[i], bn[j] = bn[j], bn[i] }
+
+// meteredConn wraps a connection that is being used for an RPC, counting the
+// data that is transferred and enforcing the RPC's limit.
+type meteredConn struct {
+	modules.PeerConn
+	bw   *rpcBandwidth
+	stop <-chan struct{}
+}
+
+// wait blocks for the provided duration, or until the gateway shuts down.
+func (mc *meteredConn) wait(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	select {
+	case <-time.After(d):
+	case <-mc.stop:
+	}
+}
+
+// Read reads from the underlying connection and records the data received.
+func (mc *meteredConn) Read(p []byte) (int, error) {
+	n, err := mc.PeerConn.Read(p)
+	mc.wait(mc.bw.record(n, false))
+	return n, err
+}
+
+// Write writes to the underlying connection and records the data sent.
+func (mc *meteredConn) Write(p []byte) (int, error) {
+	n, err := mc.PeerConn.Write(p)
+	mc.wait(mc.bw.record(n, true))
+	return n, err
+}
+
+// rpcBandwidthFor returns the bandwidth tracker of the RPC with the provided
+// name, creating it if necessary.
+func (g *Gateway) rpcBandwidthFor(name string) *rpcBandwidth {
+	id := handlerName(name)
+	b, exists := g.bandwidth[id]
+	if !exists {
+		b = &rpcBandwidth{
+			name:  name,
+			limit: g.settings.BandwidthLimits[name],
+		}
+		g.bandwidth[id] = b
+	}
+	return b
+}
+
+// managedMeterConn wraps the connection of an RPC so that the data it
+// transfers is counted towards the RPC.
+func (g *Gateway) managedMeterConn(conn modules.PeerConn, name string) modules.PeerConn {
+	g.mu.Lock()
+	b := g.rpcBandwidthFor(name)
+	g.mu.Unlock()
+	return &meteredConn{
+		PeerConn: conn,
+		bw:       b,
+		stop:     g.threads.StopChan(),
+	}
+}
+
+// applyBandwidthLimits updates the limits of all tracked RPCs to match the
+// gateway's settings.
+func (g *Gateway) applyBandwidthLimits() {
+	for _, b := range g.bandwidth {
+		b.setLimit(g.settings.BandwidthLimits[b.name])
+	}
+	for name := range g.settings.BandwidthLimits {
+		g.rpcBandwidthFor(name)
+	}
+}
+
+// Bandwidth returns the amount of data that the gateway has sent and received
+// for each RPC since startup, along with the limit of each RPC.
+func (g *Gateway) Bandwidth() []modules.RPCBandwidth {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	bandwidth := make([]modules.RPCBandwidth, 0, len(g.bandwidth))
+	for _, b := range g.bandwidth {
+		bandwidth = append(bandwidth, b.info())
+	}
+	sort.Sort(byRPCName(bandwidth))
+	return bandwidth
+}
@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// rpcBandwidthInfo returns the bandwidth of the named RPC on the gateway.
+func rpcBandwidthInfo(g *Gateway, name string) (modules.RPCBandwidth, bool) {
+	for _, rb := range g.Bandwidth() {
+		if rb.RPC == name {
+			return rb, true
+		}
+	}
+	return modules.RPCBandwidth{}, false
+}
+
+// TestRPCBandwidth checks that the data transferred by an RPC is counted on
+// both the calling and the handling side.
+func TestRPCBandwidth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g1 := newTestingGateway("TestRPCBandwidth1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestRPCBandwidth2", t)
+	defer g2.Close()
+
+	err := g2.Connect(g1.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler reads 100 bytes (plus an 8 byte prefix) and writes nothing.
+	done := make(chan struct{})
+	g1.RegisterRPC("Foo", func(conn modules.PeerConn) error {
+		defer close(done)
+		var data []byte
+		return encoding.ReadObject(conn, &data, 200)
+	})
+	err = g2.RPC(g1.Address(), "Foo", func(conn modules.PeerConn) error {
+		return encoding.WriteObject(conn, make([]byte, 100))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	expected := uint64(rpcHeaderLen + 8 + 8 + 100)
+	if rb, ok := rpcBandwidthInfo(g2, "Foo"); !ok || rb.Sent != expected || rb.Received != 0 {
+		t.Errorf("caller recorded the wrong bandwidth: expected %v sent, got %+v", expected, rb)
+	}
+	if rb, ok := rpcBandwidthInfo(g1, "Foo"); !ok || rb.Received != expected || rb.Sent != 0 {
+		t.Errorf("handler recorded the wrong bandwidth: expected %v received, got %+v", expected, rb)
+	}
+}
+
+// TestRPCBandwidthLimit checks that transfers exceeding the limit of an RPC
+// are delayed.
+func TestRPCBandwidthLimit(t *testing.T) {
+	b := &rpcBandwidth{name: "Foo", limit: 1000}
+	if d := b.record(500, true); d < 400*time.Millisecond || d > 500*time.Millisecond {
+		t.Error("expected a delay of about 500ms, got", d)
+	}
+	if d := b.record(500, false); d < 900*time.Millisecond || d > time.Second {
+		t.Error("expected a delay of about 1s, got", d)
+	}
+	if info := b.info(); info.Sent != 500 || info.Received != 500 {
+		t.Error("wrong counters:", info)
+	}
+
+	// Unlimited RPCs are never delayed.
+	b.setLimit(0)
+	if d := b.record(1e6, true); d != 0 {
+		t.Error("unlimited RPC was delayed by", d)
+	}
+}
+
+// TestSetBandwidthLimits checks that the bandwidth limits in the settings are
+// applied to the RPCs.
+func TestSetBandwidthLimits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestSetBandwidthLimits", t)
+	defer g.Close()
+
+	settings := g.Settings()
+	settings.BandwidthLimits = map[string]uint64{"ShareNodes": 5000, "Bar": 0}
+	if err := g.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if rb, ok := rpcBandwidthInfo(g, "ShareNodes"); !ok || rb.Limit != 5000 {
+		t.Fatal("ShareNodes limit was not applied:", rb)
+	}
+	if _, ok := g.Settings().BandwidthLimits["Bar"]; ok {
+		t.Error("zero limit was not dropped from the settings")
+	}
+
+	// Changing the returned settings should not change the gateway.
+	g.Settings().BandwidthLimits["ShareNodes"] = 1
+	if rb, _ := rpcBandwidthInfo(g, "ShareNodes"); rb.Limit != 5000 {
+		t.Error("limit changed without calling SetSettings:", rb.Limit)
+	}
+
+	settings.BandwidthLimits = map[string]uint64{"": 100}
+	if err := g.SetSettings(settings); err != errEmptyRPCName {
+		t.Fatal("expected errEmptyRPCName, got", err)
+	}
+}
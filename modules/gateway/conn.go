@@ -21,13 +21,14 @@ func (pc peerConn) RPCAddr() modules.NetAddress {
 
 // dial will dial the input address and return a connection. dial appropriately
 // handles things like clean shutdown, fast shutdown, and chooses the correct
-// communication protocol.
+// communication protocol. If a proxy is set, the connection is made through
+// the proxy.
 func (g *Gateway) dial(addr modules.NetAddress) (net.Conn, error) {
 	dialer := &net.Dialer{
 		Cancel:  g.threads.StopChan(),
 		Timeout: dialTimeout,
 	}
-	conn, err := dialer.Dial("tcp", string(addr))
+	conn, err := modules.Dial(dialer, addr)
 	if err != nil {
 		return nil, err
 	}
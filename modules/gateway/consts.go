@@ -11,6 +11,16 @@ const (
 	// was altered to include adiitional information transfer.
 	handshakeUpgradeVersion = "1.0.0"
 
+	// nodeIDUpgradeVersion is the version where the gateway handshake was
+	// extended to exchange node IDs, allowing gateways to recognize
+	// connections to themselves and duplicate connections to the same node.
+	nodeIDUpgradeVersion = "1.0.4"
+
+	// sessionUpgradeVersion is the version where the gateway handshake was
+	// extended to encrypt the connection and authenticate the public key of
+	// the remote node.
+	sessionUpgradeVersion = "1.0.4"
+
 	// maxLocalOutbound is currently set to 3, meaning the gateway will not
 	// consider a local node to be an outbound peer if the gateway already has
 	// 3 outbound peers. Three is currently needed to handle situations where
@@ -28,6 +38,42 @@ const (
 	minAcceptableVersion = "0.4.0"
 )
 
+const (
+	// relayReward is the amount that a peer's score is raised by each time
+	// the peer relays something useful to the gateway, such as a block or
+	// transaction set that the gateway did not yet know about.
+	relayReward = 1
+
+	// violationPenalty is the amount that a peer's score is lowered by each
+	// time the peer violates the protocol, for example by calling an unknown
+	// RPC or by sending an invalid object.
+	violationPenalty = 10
+
+	// latencyPenaltyUnit is the amount of connection latency that lowers a
+	// peer's score by one.
+	latencyPenaltyUnit = 100 * time.Millisecond
+
+	// evictPeerScore is the score below which an outbound peer is evicted
+	// once the gateway is well connected, freeing up the slot for a peer
+	// that is hopefully better behaved.
+	evictPeerScore = -20
+
+	// banPeerScore is the score at or below which a peer is disconnected and
+	// its host is banned.
+	banPeerScore = -50
+)
+
+var (
+	// relayRPCs are the RPCs that relay new objects to the gateway. Their
+	// handlers only succeed if the relayed object was new, so a peer's score
+	// is raised each time it calls one of them successfully. Serving a
+	// request, such as SendBlocks or ShareNodes, does not earn a reward.
+	relayRPCs = map[rpcID]struct{}{
+		handlerName("RelayBlock"):          {},
+		handlerName("RelayTransactionSet"): {},
+	}
+)
+
 var (
 	// healthyNodeListLen defines the number of nodes that the gateway must
 	// have in the node list before it will stop asking peers for more nodes.
@@ -59,6 +105,22 @@ var (
 		}
 	}()
 
+	// maxNodeListLen defines the maximum number of nodes that the gateway
+	// will keep in the node list. Once the node list is full, new nodes are
+	// only added by evicting the lowest quality nodes.
+	maxNodeListLen = func() int {
+		switch build.Release {
+		case "dev":
+			return 500
+		case "standard":
+			return 5000
+		case "testing":
+			return 50
+		default:
+			panic("unrecognized build.Release in maxNodeListLen")
+		}
+	}()
+
 	// nodePurgeDelay defines the amount of time that is waited between each
 	// iteration of the node purge loop.
 	nodePurgeDelay = func() time.Duration {
@@ -89,6 +151,21 @@ var (
 		}
 	}()
 
+	// nodeUnreachableTimeout defines how long a node must have been
+	// unreachable before it is removed from the node list.
+	nodeUnreachableTimeout = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 1 * time.Hour
+		case "standard":
+			return 7 * 24 * time.Hour
+		case "testing":
+			return 1 * time.Second
+		default:
+			panic("unrecognized build.Release in nodeUnreachableTimeout")
+		}
+	}()
+
 	// pruneNodeListLen defines the number of nodes that the gateway must have
 	// to be pruning nodes from the node list.
 	pruneNodeListLen = func() int {
@@ -106,6 +183,22 @@ var (
 )
 
 var (
+	// anchorPeerTimeout is how recently a node must have been an outbound
+	// peer for the gateway to reconnect to it at startup, before connecting
+	// to any other nodes.
+	anchorPeerTimeout = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 1 * time.Hour
+		case "standard":
+			return 3 * 24 * time.Hour
+		case "testing":
+			return 1 * time.Minute
+		default:
+			panic("unrecognized build.Release in anchorPeerTimeout")
+		}
+	}()
+
 	// The gateway will sleep this long between incoming connections. For
 	// attack reasons, the acceptInterval should be longer than the
 	// nodeListDelay. Right at startup, a node is vulnerable to being flooded
@@ -143,8 +236,9 @@ var (
 		}
 	}()
 
-	// fullyConnectedThreshold defines the number of peers that the gateway can
-	// have before it stops accepting inbound connections.
+	// fullyConnectedThreshold defines the default number of peers that the
+	// gateway can have before it starts kicking inbound peers to make room
+	// for new ones. It is also the default limit on inbound peers.
 	fullyConnectedThreshold = func() int {
 		switch build.Release {
 		case "dev":
@@ -224,6 +318,23 @@ var (
 		}
 	}()
 
+	// natRenewInterval is how often the gateway renews its port mapping and
+	// tests whether it is reachable. NAT-PMP mappings are requested with a
+	// lifetime of twice this interval, so that they do not expire between
+	// renewals.
+	natRenewInterval = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 5 * time.Minute
+		case "standard":
+			return 30 * time.Minute
+		case "testing":
+			return 5 * time.Second
+		default:
+			panic("unrecognized build.Release in natRenewInterval")
+		}
+	}()
+
 	// wellConnectedThreshold is the number of outbound connections at which
 	// the gateway will not attempt to make new outbound connections.
 	wellConnectedThreshold = func() int {
@@ -240,6 +351,41 @@ var (
 	}()
 )
 
+var (
+	// defaultBanDuration is the amount of time that a misbehaving host is
+	// banned for, unless a different duration has been set using
+	// SetSettings.
+	defaultBanDuration = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 1 * time.Hour
+		case "standard":
+			return 24 * time.Hour
+		case "testing":
+			return 5 * time.Second
+		default:
+			panic("unrecognized build.Release in defaultBanDuration")
+		}
+	}()
+
+	// defaultMaxPeersPerSubnet is the number of non-local peers that may
+	// share a subnet, unless a different limit has been set using
+	// SetSettings. Limiting the peers per subnet makes it harder for a
+	// single operator to fill up the gateway's peer slots.
+	defaultMaxPeersPerSubnet = func() int {
+		switch build.Release {
+		case "dev":
+			return 10
+		case "standard":
+			return 8
+		case "testing":
+			return 3
+		default:
+			panic("unrecognized build.Release in defaultMaxPeersPerSubnet")
+		}
+	}()
+)
+
 var (
 	// connStdDeadline defines the standard deadline that should be used for
 	// all temporary connections to the gateway.
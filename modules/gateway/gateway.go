@@ -45,7 +45,10 @@ import (
 // Furthermore, to increase the difficulty of attack, if a new inbound
 // connection shares the same IP address as an existing connection, the shared
 // connection is the connection that gets dropped (unless that connection is a
-// local or outbound connection).
+// local or outbound connection). Otherwise, the inbound peer with the lowest
+// score is dropped. Peers are scored on their latency, on how many useful
+// objects they relay, and on how often they violate the protocol, and peers
+// that misbehave persistently are banned (see scoring.go).
 //
 // Nodes are added to a peerlist in two methods. The first method is that a
 // gateway will ask its outbound peers for a list of nodes. If the node list is
@@ -126,16 +129,11 @@ import (
 // nodes need to be able to successfully ping eachother until a sufficient
 // portion of the network has upgraded to the new code.
 //
-// TODO: The gateway, when connecting to a peer, currently has no way of
-// recognizing whether that peer is itself or not. Because of this, the gateway
-// does occasionally try to connect to itself, especially if it has a really
-// small node list. This can cause problems, especially during testing, if the
-// gateway is intentionally limiting the number of local outbount peers that it
-// has. For that reason, the 'permanentPeerManager' will allow the gateway to
-// add local peers as outbound peers all the way up to 3 total connections. The
-// gateway needs a proper way to recognize if it is trying to connect itself
-// (sending a nonce when performing the version handshake, for example). Once
-// that has been implemented, maxLocalOutobundPeers can be reduced to 2 or 1
+// TODO: Peers older than v1.0.4 do not send a node ID during the handshake,
+// so the gateway cannot recognize a connection to itself through such a
+// handshake. Because a gateway running this code always sends its node ID,
+// this only matters for duplicate connections to old peers. Once old peers
+// have left the network, maxLocalOutboundPeers can be reduced to 2 or 1
 // (probably 2).
 
 import (
@@ -149,10 +147,12 @@ import (
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	siasync "github.com/NebulousLabs/Sia/sync"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
 	errNoPeers     = errors.New("no peers")
+	errStopped     = errors.New("gateway is stopped")
 	errUnreachable = errors.New("peer did not respond to ping")
 )
 
@@ -162,12 +162,37 @@ type Gateway struct {
 	myAddr   modules.NetAddress
 	port     string
 
+	// id is the gateway's persistent node ID, which is sent to peers during
+	// the handshake. It is set during startup and never changes, so it can
+	// be read without holding the lock.
+	//
+	// selfAddrs are the addresses that turned out to lead back to the
+	// gateway itself. They are never added to the node list.
+	//
+	// keys is the key pair that the gateway uses to authenticate itself to
+	// its peers. Like id, it never changes after startup.
+	//
+	// pinnedKeys are the public keys that the nodes dialed by the gateway
+	// presented the first time, indexed by node ID.
+	id         nodeID
+	keys       sessionKeys
+	pinnedKeys map[nodeID]types.SiaPublicKey
+	selfAddrs  map[modules.NetAddress]struct{}
+
 	// handlers are the RPCs that the Gateway can handle.
 	//
 	// initRPCs are the RPCs that the Gateway calls upon connecting to a peer.
 	handlers map[rpcID]modules.RPCFunc
 	initRPCs map[string]modules.RPCFunc
 
+	// bandwidth tracks the data transferred for each RPC, both for the RPCs
+	// that the Gateway calls and for those that it handles.
+	bandwidth map[rpcID]*rpcBandwidth
+
+	// rpcLimits are the rate limits that each peer is subject to when
+	// calling the Gateway's RPCs.
+	rpcLimits map[rpcID]rpcRateLimit
+
 	// nodes is the set of all known nodes (i.e. potential peers).
 	//
 	// peers are the nodes that the gateway is currently connected to.
@@ -183,10 +208,23 @@ type Gateway struct {
 	// and would block any threads.Flush() calls. So a second threadgroup is
 	// added which handles clean-shutdown for the peers, without blocking
 	// threads.Flush() calls.
-	nodes  map[modules.NetAddress]struct{}
+	nodes  map[modules.NetAddress]*node
 	peers  map[modules.NetAddress]*peer
 	peerTG siasync.ThreadGroup
 
+	// bans maps the hosts that are banned to the time at which their ban
+	// expires. Hosts are banned for settings.BanDuration when one of their
+	// peers misbehaves.
+	bans map[string]time.Time
+
+	// natStatus describes how the gateway's port was mapped on the router,
+	// and the result of the latest reachability test.
+	natStatus modules.NATStatus
+
+	// settings contains the connection limits and the ban duration of the
+	// gateway. They can be changed at runtime using SetSettings.
+	settings modules.GatewaySettings
+
 	// Utilities.
 	log        *persist.Logger
 	mu         sync.RWMutex
@@ -213,6 +251,21 @@ func (g *Gateway) Address() modules.NetAddress {
 	return g.myAddr
 }
 
+// Healthy returns an error if the Gateway is stopped or is not connected to
+// any peers.
+func (g *Gateway) Healthy() error {
+	if err := g.threads.Add(); err != nil {
+		return errStopped
+	}
+	defer g.threads.Done()
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if len(g.peers) == 0 {
+		return errNoPeers
+	}
+	return nil
+}
+
 // Close saves the state of the Gateway and stops its listener process.
 func (g *Gateway) Close() error {
 	if err := g.threads.Stop(); err != nil {
@@ -232,14 +285,28 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	}
 
 	g := &Gateway{
-		handlers: make(map[rpcID]modules.RPCFunc),
-		initRPCs: make(map[string]modules.RPCFunc),
+		handlers:  make(map[rpcID]modules.RPCFunc),
+		initRPCs:  make(map[string]modules.RPCFunc),
+		bandwidth: make(map[rpcID]*rpcBandwidth),
+		rpcLimits: make(map[rpcID]rpcRateLimit),
 
-		peers: make(map[modules.NetAddress]*peer),
-		nodes: make(map[modules.NetAddress]struct{}),
+		peers:      make(map[modules.NetAddress]*peer),
+		nodes:      make(map[modules.NetAddress]*node),
+		pinnedKeys: make(map[nodeID]types.SiaPublicKey),
+		selfAddrs:  make(map[modules.NetAddress]struct{}),
+
+		bans: make(map[string]time.Time),
+		settings: modules.GatewaySettings{
+			MaxPeers:          fullyConnectedThreshold,
+			MaxInboundPeers:   fullyConnectedThreshold,
+			MaxPeersPerSubnet: defaultMaxPeersPerSubnet,
+			BanDuration:       defaultBanDuration,
+		},
 
 		persistDir: persistDir,
 	}
+	g.threads.SetName("gateway")
+	g.peerTG.SetName("gateway peers")
 
 	// Create the logger.
 	g.log, err = persist.NewFileLogger(filepath.Join(g.persistDir, logFile))
@@ -264,6 +331,10 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 		}
 	})
 
+	for name, limit := range defaultRPCRateLimits {
+		g.rpcLimits[handlerName(name)] = limit
+	}
+
 	// Register RPCs.
 	g.RegisterRPC("ShareNodes", g.shareNodes)
 	g.RegisterConnectCall("ShareNodes", g.requestNodes)
@@ -273,11 +344,30 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 		g.UnregisterConnectCall("ShareNodes")
 	})
 
+	// Load the node ID, generating a new one if this is the first time the
+	// gateway is started.
+	if err := g.loadNodeID(); err != nil {
+		return nil, err
+	}
+	if err := g.loadSessionKeys(); err != nil {
+		return nil, err
+	}
+
+	// Load the pinned keys. If they don't exist, no key has been pinned yet.
+	if loadErr := g.loadPinnedKeys(); loadErr != nil && !os.IsNotExist(loadErr) {
+		return nil, loadErr
+	}
+
 	// Load the old node list. If it doesn't exist, no problem, but if it does,
 	// we want to know about any errors preventing us from loading it.
 	if loadErr := g.load(); loadErr != nil && !os.IsNotExist(loadErr) {
 		return nil, loadErr
 	}
+	// Load the settings. If they don't exist, the defaults are used.
+	if loadErr := g.loadSettings(); loadErr != nil && !os.IsNotExist(loadErr) {
+		return nil, loadErr
+	}
+	g.applyBandwidthLimits()
 
 	// Add the bootstrap peers to the node list.
 	if bootstrap {
@@ -309,7 +399,7 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 		return nil, err
 	}
 	// Set myAddr equal to the address returned by the listener. It will be
-	// overwritten by threadedLearnHostname later on.
+	// overwritten by permanentNATManager later on.
 	g.myAddr = modules.NetAddress(g.listener.Addr().String())
 
 	// Spawn the peer connection listener.
@@ -336,9 +426,15 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	})
 	go g.permanentNodePurger(nodePurgerClosedChan)
 
-	// Spawn threads to take care of port forwarding and hostname discovery.
-	go g.threadedForwardPort(g.port)
-	go g.threadedLearnHostname()
+	// Spawn the NAT manager, which takes care of port forwarding, hostname
+	// discovery and reachability testing. The port mapping is removed once
+	// the NAT manager has shut down.
+	natManagerClosedChan := make(chan struct{})
+	g.threads.AfterStop(g.managedClearPort)
+	g.threads.OnStop(func() {
+		<-natManagerClosedChan
+	})
+	go g.permanentNATManager(natManagerClosedChan)
 
 	g.log.Println("INFO: gateway created, started logging")
 	return g, nil
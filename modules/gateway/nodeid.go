@@ -0,0 +1,151 @@
+package gateway
+
+// nodeid.go gives every gateway a random, persistent node ID which is
+// exchanged during the connection handshake. Because a node can be reachable
+// through several addresses (a local address, an external address, an IPv6
+// address, ...), comparing addresses is not enough to recognize that the
+// gateway has connected to itself or to a node that it is already connected
+// to. Comparing node IDs is.
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+var (
+	errDuplicateNodeID = errors.New("already connected to this node via a different address")
+	errOurNodeID       = errors.New("can't connect to our own node")
+)
+
+// nodeID uniquely identifies a gateway. The zero ID is used for peers that
+// are too old to send an ID during the handshake.
+type nodeID [16]byte
+
+// String returns the hex representation of the node ID.
+func (id nodeID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MarshalJSON marshals the node ID as a hex string.
+func (id nodeID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+
+// UnmarshalJSON unmarshals a node ID from a hex string.
+func (id *nodeID) UnmarshalJSON(b []byte) error {
+	var idStr string
+	if err := json.Unmarshal(b, &idStr); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(idStr)
+	if err != nil || len(decoded) != len(id) {
+		return fmt.Errorf("invalid node ID %q", idStr)
+	}
+	copy(id[:], decoded)
+	return nil
+}
+
+// newNodeID returns a random node ID.
+func newNodeID() (id nodeID, err error) {
+	b, err := crypto.RandBytes(len(id))
+	if err != nil {
+		return nodeID{}, err
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// loadNodeID loads the gateway's node ID from disk. If no node ID has been
+// saved yet, a new one is generated and saved.
+func (g *Gateway) loadNodeID() error {
+	var idStr string
+	err := persist.LoadFile(nodeIDMetadata, &idStr, filepath.Join(g.persistDir, nodeIDFile))
+	if err == nil {
+		b, err := hex.DecodeString(idStr)
+		if err != nil || len(b) != len(g.id) {
+			return fmt.Errorf("invalid node ID %q", idStr)
+		}
+		copy(g.id[:], b)
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	g.id, err = newNodeID()
+	if err != nil {
+		return err
+	}
+	return persist.SaveFileSync(nodeIDMetadata, g.id.String(), filepath.Join(g.persistDir, nodeIDFile))
+}
+
+// connectNodeIDHandshake performs the node ID handshake and should be called
+// on the side initiating the connection request. The remote node ID is only
+// returned if err == nil.
+func connectNodeIDHandshake(conn net.Conn, id nodeID) (remoteID nodeID, err error) {
+	if err := encoding.WriteObject(conn, id); err != nil {
+		return nodeID{}, fmt.Errorf("failed to write node ID: %v", err)
+	}
+	if err := encoding.ReadObject(conn, &remoteID, uint64(len(remoteID))); err != nil {
+		return nodeID{}, fmt.Errorf("failed to read remote node ID: %v", err)
+	}
+	return remoteID, nil
+}
+
+// acceptConnNodeIDHandshake performs the node ID handshake and should be
+// called on the side accepting a connection request. The remote node ID is
+// only returned if err == nil.
+func acceptConnNodeIDHandshake(conn net.Conn, id nodeID) (remoteID nodeID, err error) {
+	if err := encoding.ReadObject(conn, &remoteID, uint64(len(remoteID))); err != nil {
+		return nodeID{}, fmt.Errorf("failed to read remote node ID: %v", err)
+	}
+	if err := encoding.WriteObject(conn, id); err != nil {
+		return nodeID{}, fmt.Errorf("failed to write node ID: %v", err)
+	}
+	return remoteID, nil
+}
+
+// managedCheckNodeID returns an error if the remote node ID belongs to the
+// gateway itself. The address that led the gateway back to itself is removed
+// from the node list and will not be added again, so that no more connections
+// are wasted on it.
+func (g *Gateway) managedCheckNodeID(remoteAddr modules.NetAddress, remoteID nodeID) error {
+	if remoteID != g.id {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.selfAddrs[remoteAddr] = struct{}{}
+	if _, exists := g.nodes[remoteAddr]; exists {
+		delete(g.nodes, remoteAddr)
+		if err := g.save(); err != nil {
+			g.log.Println("WARN: failed to save the node list:", err)
+		}
+	}
+	g.log.Debugln("INFO: pruned our own address from the node list:", remoteAddr)
+	return errOurNodeID
+}
+
+// peerWithNodeID returns the connected peer that has the provided node ID,
+// or nil if there is no such peer. The zero ID never matches.
+func (g *Gateway) peerWithNodeID(id nodeID) *peer {
+	if id == (nodeID{}) {
+		return nil
+	}
+	for _, p := range g.peers {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/muxado"
+)
+
+// TestNodeIDPersist checks that a gateway keeps its node ID across restarts.
+func TestNodeIDPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	g := newTestingGateway("TestNodeIDPersist", t)
+	id := g.id
+	if id == (nodeID{}) {
+		t.Fatal("gateway was not given a node ID")
+	}
+	g.Close()
+
+	g2, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	if g2.id != id {
+		t.Fatalf("node ID changed after restart: %v -> %v", id, g2.id)
+	}
+
+	g3 := newTestingGateway("TestNodeIDPersist2", t)
+	defer g3.Close()
+	if g3.id == id {
+		t.Fatal("two gateways were given the same node ID")
+	}
+}
+
+// TestSelfConnect checks that the gateway recognizes when an address other
+// than its own leads back to itself, and prunes that address.
+func TestSelfConnect(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	g := newTestingGateway("TestSelfConnect", t)
+	defer g.Close()
+
+	// Pretend that the gateway has learned a different external address, so
+	// that the listener address is no longer recognized as our own.
+	g.mu.Lock()
+	listenAddr := g.myAddr
+	g.myAddr = modules.NetAddress("1.2.3.4:" + g.port)
+	g.nodes[listenAddr] = &node{NetAddress: listenAddr, FirstSeen: time.Now()}
+	g.mu.Unlock()
+
+	if err := g.Connect(listenAddr); err != errOurNodeID {
+		t.Fatal("expected errOurNodeID, got", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, exists := g.nodes[listenAddr]; exists {
+		t.Error("our own address was not pruned from the node list")
+	}
+	if err := g.addNode(listenAddr); err != errOurAddress {
+		t.Error("expected errOurAddress when re-adding our own address, got", err)
+	}
+	if len(g.peers) != 0 {
+		t.Error("gateway added itself as a peer:", g.peers)
+	}
+}
+
+// TestDuplicateNodeID checks that the gateway rejects a second connection to
+// a node that it is already connected to via a different address.
+func TestDuplicateNodeID(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	g := newTestingGateway("TestDuplicateNodeID", t)
+	defer g.Close()
+
+	id, err := newNodeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	err = g.acceptPeer(&peer{
+		Peer: modules.Peer{NetAddress: "1.2.3.4:1234"},
+		id:   id,
+		sess: muxado.Client(new(dummyConn)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.acceptPeer(&peer{
+		Peer: modules.Peer{NetAddress: "5.6.7.8:1234"},
+		id:   id,
+		sess: muxado.Client(new(dummyConn)),
+	})
+	if err != errDuplicateNodeID {
+		t.Fatal("expected errDuplicateNodeID, got", err)
+	}
+
+	// Peers without a node ID are never considered duplicates.
+	for _, addr := range []modules.NetAddress{"9.9.9.9:1234", "9.9.8.8:1234"} {
+		err = g.acceptPeer(&peer{
+			Peer: modules.Peer{NetAddress: addr},
+			sess: muxado.Client(new(dummyConn)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(g.peers) != 3 {
+		t.Fatal("expected 3 peers, got", len(g.peers))
+	}
+}
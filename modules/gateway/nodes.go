@@ -3,6 +3,7 @@ package gateway
 import (
 	"errors"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -12,15 +13,118 @@ import (
 )
 
 var (
-	errNodeExists = errors.New("node already added")
-	errNoNodes    = errors.New("no nodes in the node list")
-	errOurAddress = errors.New("can't add our own address")
+	errNodeExists   = errors.New("node already added")
+	errNodeListFull = errors.New("node list is full")
+	errNoNodes      = errors.New("no nodes in the node list")
+	errNodeNotFound = errors.New("no record of that node")
+	errOurAddress   = errors.New("can't add our own address")
 )
 
-// addNode adds an address to the set of nodes on the network.
+// node is an entry in the gateway's node list. Along with the address of the
+// node, it tracks the results of contacting the node so that nodes which have
+// been unreachable for a long time can be removed from the node list.
+type node struct {
+	NetAddress modules.NetAddress `json:"netaddress"`
+
+	// FirstSeen is the time at which the node was added to the node list.
+	// LastChecked is the last time that the gateway tried to contact the
+	// node, and LastSuccess is the last time that contacting the node
+	// succeeded. Both are the zero time if the node has never been contacted.
+	FirstSeen   time.Time `json:"firstseen"`
+	LastChecked time.Time `json:"lastchecked"`
+	LastSuccess time.Time `json:"lastsuccess"`
+
+	// ConsecutiveFailures is the number of times in a row that contacting
+	// the node has failed.
+	ConsecutiveFailures uint64 `json:"consecutivefailures"`
+
+	// LastConnected is the last time that the node was one of the gateway's
+	// outbound peers. It is updated when the connection is formed and when
+	// it closes. Nodes that were connected recently are reconnected to first
+	// when the gateway starts.
+	LastConnected time.Time `json:"lastconnected"`
+
+	// NodeID is the ID of the node that the address led to the last time
+	// the gateway dialed it. If a key is pinned for that node, later
+	// connections to the address must lead to the same node. It is the zero
+	// ID if the node has never been dialed, or is too old to send an ID.
+	NodeID nodeID `json:"nodeid"`
+}
+
+// lastReachable returns the last time that the node was known to be
+// reachable. Nodes that have never been contacted are given the benefit of
+// the doubt from the moment they were added to the node list.
+func (n *node) lastReachable() time.Time {
+	if n.LastSuccess.IsZero() {
+		return n.FirstSeen
+	}
+	return n.LastSuccess
+}
+
+// worseNode returns true if node 'a' is a worse candidate for keeping in the
+// node list than node 'b'. Nodes that are failing are worse than nodes that
+// are not, nodes that have never been reached are worse than nodes that have
+// been reached, and otherwise nodes that were reachable less recently are
+// worse.
+func worseNode(a, b *node) bool {
+	if a.ConsecutiveFailures != b.ConsecutiveFailures {
+		return a.ConsecutiveFailures > b.ConsecutiveFailures
+	}
+	if a.LastSuccess.IsZero() != b.LastSuccess.IsZero() {
+		return a.LastSuccess.IsZero()
+	}
+	return a.lastReachable().Before(b.lastReachable())
+}
+
+// byLastConnected sorts nodes so that the most recently connected nodes come
+// first.
+type byLastConnected []*node
+
+func (nodes byLastConnected) Len() int      { return len(nodes) }
+func (nodes byLastConnected) Swap(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] }
+func (nodes byLastConnected) Less(i, j int) bool {
+	return nodes[i].LastConnected.After(nodes[j].LastConnected)
+}
+
+// anchorNodes returns the addresses of the nodes that were outbound peers of
+// the gateway within the last anchorPeerTimeout, most recent first, up to a
+// maximum of wellConnectedThreshold. Only outbound peers become anchors, so
+// that nodes that connect to the gateway cannot make themselves anchors.
+func (g *Gateway) anchorNodes() []modules.NetAddress {
+	var recent []*node
+	for _, n := range g.nodes {
+		if !n.LastConnected.IsZero() && time.Since(n.LastConnected) < anchorPeerTimeout {
+			recent = append(recent, n)
+		}
+	}
+	sort.Sort(byLastConnected(recent))
+	if len(recent) > wellConnectedThreshold {
+		recent = recent[:wellConnectedThreshold]
+	}
+	addrs := make([]modules.NetAddress, len(recent))
+	for i, n := range recent {
+		addrs[i] = n.NetAddress
+	}
+	return addrs
+}
+
+// markNodeConnected records that the node at the input address is, or was
+// until now, an outbound peer. Nothing happens if the node is not in the node
+// list.
+func (g *Gateway) markNodeConnected(addr modules.NetAddress) {
+	if n, exists := g.nodes[addr]; exists {
+		n.LastConnected = time.Now()
+	}
+}
+
+// addNode adds an address to the set of nodes on the network. If the node
+// list is full, the lowest quality node is evicted to make room for the new
+// node, unless every node in the list is of higher quality than the new node.
 func (g *Gateway) addNode(addr modules.NetAddress) error {
 	if addr == g.myAddr {
 		return errOurAddress
+	} else if _, self := g.selfAddrs[addr]; self {
+		return errOurAddress
 	} else if _, exists := g.nodes[addr]; exists {
 		return errNodeExists
 	} else if addr.IsStdValid() != nil {
@@ -28,10 +132,85 @@ func (g *Gateway) addNode(addr modules.NetAddress) error {
 	} else if net.ParseIP(addr.Host()) == nil {
 		return errors.New("address must be an IP address: " + string(addr))
 	}
-	g.nodes[addr] = struct{}{}
+	n := &node{
+		NetAddress: addr,
+		FirstSeen:  time.Now(),
+	}
+	if len(g.nodes) >= maxNodeListLen {
+		worst := g.worstNode()
+		if worst == nil || !worseNode(worst, n) {
+			return errNodeListFull
+		}
+		delete(g.nodes, worst.NetAddress)
+	}
+	g.nodes[addr] = n
 	return nil
 }
 
+// worstNode returns the lowest quality node in the node list that is not
+// currently a peer. nil is returned if there is no such node.
+func (g *Gateway) worstNode() *node {
+	var worst *node
+	for addr, n := range g.nodes {
+		if _, isPeer := g.peers[addr]; isPeer {
+			continue
+		}
+		if worst == nil || worseNode(n, worst) {
+			worst = n
+		}
+	}
+	return worst
+}
+
+// staleNode returns the node that has gone the longest without being
+// contacted. An error is returned if there are no nodes in the node list.
+func (g *Gateway) staleNode() (modules.NetAddress, error) {
+	var stale *node
+	for _, n := range g.nodes {
+		if stale == nil || n.LastChecked.Before(stale.LastChecked) {
+			stale = n
+		}
+	}
+	if stale == nil {
+		return "", errNoNodes
+	}
+	return stale.NetAddress, nil
+}
+
+// markNodeReachable records that the node at the input address was
+// successfully contacted. Nothing happens if the node is not in the node list.
+func (g *Gateway) markNodeReachable(addr modules.NetAddress) {
+	n, exists := g.nodes[addr]
+	if !exists {
+		return
+	}
+	n.LastChecked = time.Now()
+	n.LastSuccess = n.LastChecked
+	n.ConsecutiveFailures = 0
+}
+
+// markNodeUnreachable records that contacting the node at the input address
+// failed. Nothing happens if the node is not in the node list.
+func (g *Gateway) markNodeUnreachable(addr modules.NetAddress) {
+	n, exists := g.nodes[addr]
+	if !exists {
+		return
+	}
+	n.LastChecked = time.Now()
+	n.ConsecutiveFailures++
+}
+
+// nodeIsDead returns true if the node at the input address has failed its
+// most recent check and has not been reachable for longer than
+// nodeUnreachableTimeout.
+func (g *Gateway) nodeIsDead(addr modules.NetAddress) bool {
+	n, exists := g.nodes[addr]
+	if !exists {
+		return false
+	}
+	return n.ConsecutiveFailures > 0 && time.Since(n.lastReachable()) > nodeUnreachableTimeout
+}
+
 // managedAddUntrustedNode adds an address to the set of nodes on the network, but
 // first verifies that there is a reachable node at the provided address.
 func (g *Gateway) managedAddUntrustedNode(addr modules.NetAddress) error {
@@ -67,19 +246,22 @@ func (g *Gateway) managedAddUntrustedNode(addr modules.NetAddress) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	err = g.addNode(addr)
+	g.markNodeReachable(addr)
 	if err != nil {
 		return err
 	}
 	return g.save()
 }
 
-// removeNode will remove a node from the gateway.
+// removeNode will remove a node from the gateway. The key pinned for the node
+// that the address led to is dropped if no other address leads to it.
 func (g *Gateway) removeNode(addr modules.NetAddress) error {
-	if _, exists := g.nodes[addr]; !exists {
-		return errors.New("no record of that node")
+	n, exists := g.nodes[addr]
+	if !exists {
+		return errNodeNotFound
 	}
 	delete(g.nodes, addr)
-	return nil
+	return g.unpinNodeKey(n.NodeID)
 }
 
 // randomNode returns a random node from the gateway. An error can be returned
@@ -98,15 +280,57 @@ func (g *Gateway) randomNode() (modules.NetAddress, error) {
 	if err != nil {
 		return "", err
 	}
-	for node := range g.nodes {
+	for addr := range g.nodes {
 		if r <= 0 {
-			return node, nil
+			return addr, nil
 		}
 		r--
 	}
 	return "", errNoPeers
 }
 
+// randomDiverseNode returns a random node, preferring nodes of an address
+// family that none of the gateway's outbound peers belong to, so that a
+// dual-stack gateway stays connected to both the IPv4 and the IPv6 parts of
+// the network. Hostnames are counted as IPv4. Half of the time an ordinary
+// random node is returned, so that a gateway without working IPv6
+// connectivity is not stuck dialing IPv6 nodes.
+func (g *Gateway) randomDiverseNode() (modules.NetAddress, error) {
+	var haveIPv4, haveIPv6 bool
+	for addr, p := range g.peers {
+		if p.Inbound {
+			continue
+		}
+		if addr.IsIPv6() {
+			haveIPv6 = true
+		} else {
+			haveIPv4 = true
+		}
+	}
+	// Prefer a family only if exactly one of them is represented.
+	if haveIPv4 == haveIPv6 {
+		return g.randomNode()
+	}
+	if n, err := crypto.RandIntn(2); err != nil || n == 0 {
+		return g.randomNode()
+	}
+
+	var candidates []modules.NetAddress
+	for addr := range g.nodes {
+		if addr.IsIPv6() != haveIPv6 {
+			candidates = append(candidates, addr)
+		}
+	}
+	if len(candidates) == 0 {
+		return g.randomNode()
+	}
+	r, err := crypto.RandIntn(len(candidates))
+	if err != nil {
+		return "", err
+	}
+	return candidates[r], nil
+}
+
 // shareNodes is the receiving end of the ShareNodes RPC. It writes up to 10
 // randomly selected nodes to the caller.
 func (g *Gateway) shareNodes(conn modules.PeerConn) error {
@@ -121,8 +345,8 @@ func (g *Gateway) shareNodes(conn modules.PeerConn) error {
 		// Create a random permutation of nodes from the gateway to iterate
 		// through.
 		gnodes := make([]modules.NetAddress, 0, len(g.nodes))
-		for node := range g.nodes {
-			gnodes = append(gnodes, node)
+		for addr := range g.nodes {
+			gnodes = append(gnodes, addr)
 		}
 		perm, err := crypto.Perm(len(g.nodes))
 		if err != nil {
@@ -166,7 +390,9 @@ func (g *Gateway) requestNodes(conn modules.PeerConn) error {
 	g.mu.Lock()
 	for _, node := range nodes {
 		err := g.addNode(node)
-		if err != nil && err != errNodeExists && err != errOurAddress {
+		if err == errNodeListFull {
+			break
+		} else if err != nil && err != errNodeExists && err != errOurAddress {
 			g.log.Printf("WARN: peer '%v' sent the invalid addr '%v'", conn.RPCAddr(), node)
 		}
 	}
@@ -179,8 +405,8 @@ func (g *Gateway) requestNodes(conn modules.PeerConn) error {
 }
 
 // permanentNodePurger is a thread that runs throughout the lifetime of the
-// gateway, purging unconnectable nodes from the node list in a sustainable
-// way.
+// gateway, testing the nodes in the node list in a sustainable way and purging
+// nodes that have been unreachable for a long time.
 func (g *Gateway) permanentNodePurger(closeChan chan struct{}) {
 	defer close(closeChan)
 
@@ -198,10 +424,9 @@ func (g *Gateway) permanentNodePurger(closeChan chan struct{}) {
 			return
 		}
 
-		// Get a random node for scanning.
+		// Get the node that has gone the longest without being checked.
 		g.mu.RLock()
-		numNodes := len(g.nodes)
-		node, err := g.randomNode()
+		node, err := g.staleNode()
 		g.mu.RUnlock()
 		if err == errNoNodes {
 			// errNoNodes is a common error that will be resovled by the
@@ -209,37 +434,43 @@ func (g *Gateway) permanentNodePurger(closeChan chan struct{}) {
 			continue
 		} else if err != nil {
 			// Unusual error, create a logging statement.
-			g.log.Println("ERROR: could not pick a random node for uptime check:", err)
-			continue
-		}
-		if numNodes <= pruneNodeListLen {
-			// There are not enough nodes in the gateway - pruning more is
-			// probably a bad idea, and may affect the user's ability to
-			// connect to the network in the future.
+			g.log.Println("ERROR: could not pick a stale node for uptime check:", err)
 			continue
 		}
 		// Check whether this node is already a peer. If so, no need to dial
 		// them.
-		g.mu.RLock()
+		g.mu.Lock()
 		_, exists := g.peers[node]
-		g.mu.RUnlock()
+		if exists {
+			g.markNodeReachable(node)
+		}
+		g.mu.Unlock()
 		if exists {
 			continue
 		}
 
-		// Try connecting to the random node. If the node is not reachable,
-		// remove them from the node list.
+		// Try connecting to the node. If the node is not reachable, record
+		// the failure, and remove the node from the node list if it has not
+		// been reachable for a long time.
 		conn, err := g.dial(node)
 		if err != nil {
 			// NOTE: an error may be returned if the dial is cancelled
-			// partway through, which would cause the node to be pruned
-			// even though it may be a good node. Because nodes are
-			// plentiful, that's not a huge problem.
+			// partway through. A single failure does not cause the node to
+			// be removed, so this is not a problem.
 			g.mu.Lock()
-			g.removeNode(node)
-			g.save()
+			g.markNodeUnreachable(node)
+			// There need to be enough nodes left in the gateway - pruning
+			// more is probably a bad idea, and may affect the user's ability
+			// to connect to the network in the future.
+			if g.nodeIsDead(node) && len(g.nodes) > pruneNodeListLen {
+				lastReachable := g.nodes[node].lastReachable()
+				g.removeNode(node)
+				g.log.Debugf("INFO: removing node %q because it has been unreachable since %v: %v", node, lastReachable, err)
+				if err := g.save(); err != nil {
+					g.log.Println("WARN: failed to save nodelist after purging a node:", err)
+				}
+			}
 			g.mu.Unlock()
-			g.log.Debugf("INFO: removing node %q because dialing it failed: %v", node, err)
 			continue
 		}
 
@@ -258,6 +489,12 @@ func (g *Gateway) permanentNodePurger(closeChan chan struct{}) {
 			g.log.Debugln("WARN: peer does not seem to have correctly rejected our ping:", reject)
 		}
 		conn.Close()
+
+		// The results of uptime checks are saved along with the rest of the
+		// node list, they are not worth a write to disk on their own.
+		g.mu.Lock()
+		g.markNodeReachable(node)
+		g.mu.Unlock()
 	}
 }
 
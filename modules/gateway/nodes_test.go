@@ -9,6 +9,7 @@ import (
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/muxado"
 )
 
 const dummyNode = "111.111.111.111:1111"
@@ -65,6 +66,147 @@ func TestRemoveNode(t *testing.T) {
 	}
 }
 
+// TestNodeListEviction checks that the node list is capped at maxNodeListLen,
+// and that the lowest quality nodes are evicted to make room for new nodes.
+func TestNodeListEviction(t *testing.T) {
+	g := &Gateway{
+		nodes: make(map[modules.NetAddress]*node),
+		peers: make(map[modules.NetAddress]*peer),
+	}
+
+	// Fill the node list with nodes that have all been reached.
+	for i := 0; i < maxNodeListLen; i++ {
+		addr := modules.NetAddress("111.111.111.111:" + strconv.Itoa(i+1))
+		if err := g.addNode(addr); err != nil {
+			t.Fatal(err)
+		}
+		g.markNodeReachable(addr)
+	}
+
+	// A node that has never been reached should not displace nodes that
+	// have been reached.
+	newNode := modules.NetAddress("222.222.222.222:1111")
+	if err := g.addNode(newNode); err != errNodeListFull {
+		t.Fatalf("expected %v, got %v", errNodeListFull, err)
+	}
+
+	// Once a node starts failing, it should be evicted in favor of the new
+	// node.
+	failing := modules.NetAddress("111.111.111.111:5")
+	g.markNodeUnreachable(failing)
+	if err := g.addNode(newNode); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := g.nodes[failing]; exists {
+		t.Error("failing node was not evicted")
+	}
+	if len(g.nodes) != maxNodeListLen {
+		t.Errorf("expected %v nodes, got %v", maxNodeListLen, len(g.nodes))
+	}
+
+	// Nodes that have never been reached are evicted oldest first.
+	g.nodes[newNode].FirstSeen = time.Now().Add(-time.Hour)
+	newNode2 := modules.NetAddress("222.222.222.222:2222")
+	if err := g.addNode(newNode2); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := g.nodes[newNode]; exists {
+		t.Error("oldest unreached node was not evicted")
+	}
+
+	// Peers are never evicted.
+	g.nodes[newNode2].ConsecutiveFailures = 1
+	g.peers[newNode2] = &peer{}
+	newNode3 := modules.NetAddress("222.222.222.222:3333")
+	if err := g.addNode(newNode3); err != errNodeListFull {
+		t.Fatalf("expected %v, got %v", errNodeListFull, err)
+	}
+}
+
+// TestNodeIsDead checks that nodes are only considered dead after they have
+// been unreachable for longer than nodeUnreachableTimeout.
+func TestNodeIsDead(t *testing.T) {
+	g := &Gateway{
+		nodes: make(map[modules.NetAddress]*node),
+		peers: make(map[modules.NetAddress]*peer),
+	}
+	if err := g.addNode(dummyNode); err != nil {
+		t.Fatal(err)
+	}
+	n := g.nodes[dummyNode]
+
+	// A recently added node is not dead, even if it cannot be reached.
+	g.markNodeUnreachable(dummyNode)
+	if g.nodeIsDead(dummyNode) {
+		t.Error("recently added node is considered dead")
+	}
+
+	// A node that has been unreachable for a long time is dead.
+	n.FirstSeen = time.Now().Add(-2 * nodeUnreachableTimeout)
+	if !g.nodeIsDead(dummyNode) {
+		t.Error("long unreachable node is not considered dead")
+	}
+
+	// A node that was reached recently is not dead, even if the most recent
+	// check failed.
+	g.markNodeReachable(dummyNode)
+	g.markNodeUnreachable(dummyNode)
+	if n.ConsecutiveFailures != 1 {
+		t.Errorf("expected 1 consecutive failure, got %v", n.ConsecutiveFailures)
+	}
+	if g.nodeIsDead(dummyNode) {
+		t.Error("recently reached node is considered dead")
+	}
+
+	// A node that passed its most recent check is not dead.
+	n.LastSuccess = time.Now().Add(-2 * nodeUnreachableTimeout)
+	n.ConsecutiveFailures = 0
+	if g.nodeIsDead(dummyNode) {
+		t.Error("node that passed its last check is considered dead")
+	}
+}
+
+// TestAnchorNodes checks that the nodes which were recently outbound peers are
+// selected as anchors, most recent first.
+func TestAnchorNodes(t *testing.T) {
+	g := &Gateway{
+		nodes: make(map[modules.NetAddress]*node),
+		peers: make(map[modules.NetAddress]*peer),
+	}
+	for i := 0; i < wellConnectedThreshold+3; i++ {
+		addr := modules.NetAddress("111.111.111." + strconv.Itoa(i) + ":1111")
+		if err := g.addNode(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if anchors := g.anchorNodes(); len(anchors) != 0 {
+		t.Fatal("nodes that were never connected were returned as anchors:", anchors)
+	}
+
+	// Mark every node as connected at a different time; the first node was
+	// connected too long ago to be an anchor.
+	now := time.Now()
+	for i := 0; i < wellConnectedThreshold+3; i++ {
+		addr := modules.NetAddress("111.111.111." + strconv.Itoa(i) + ":1111")
+		g.nodes[addr].LastConnected = now.Add(-time.Duration(i) * time.Second)
+	}
+	oldest := modules.NetAddress("111.111.111.0:1111")
+	g.nodes[oldest].LastConnected = now.Add(-2 * anchorPeerTimeout)
+
+	anchors := g.anchorNodes()
+	if len(anchors) != wellConnectedThreshold {
+		t.Fatalf("expected %v anchors, got %v", wellConnectedThreshold, len(anchors))
+	}
+	for i, addr := range anchors {
+		if addr == oldest {
+			t.Fatal("node connected too long ago was returned as an anchor")
+		}
+		if i > 0 && g.nodes[addr].LastConnected.After(g.nodes[anchors[i-1]].LastConnected) {
+			t.Fatal("anchors are not sorted by most recent connection")
+		}
+	}
+}
+
 // TestRandomNode tries pulling random nodes from the gateway using
 // g.randomNode() under a variety of conditions.
 func TestRandomNode(t *testing.T) {
@@ -143,6 +285,70 @@ func TestRandomNode(t *testing.T) {
 	}
 }
 
+// TestRandomDiverseNode checks that randomDiverseNode prefers nodes of the
+// address family that the outbound peers are missing.
+func TestRandomDiverseNode(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	g := newTestingGateway("TestRandomDiverseNode", t)
+	defer g.Close()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ipv6Node := modules.NetAddress("[2001:db8::1]:1111")
+	for i := 0; i < 20; i++ {
+		if err := g.addNode(modules.NetAddress("111.111.111." + strconv.Itoa(i) + ":1111")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.addNode(ipv6Node); err != nil {
+		t.Fatal(err)
+	}
+	g.peers[dummyNode] = &peer{
+		Peer: modules.Peer{NetAddress: dummyNode},
+		sess: muxado.Client(new(dummyConn)),
+	}
+
+	// With only IPv4 outbound peers, the IPv6 node should be picked about
+	// half of the time, instead of 1 in 21 times.
+	picked := 0
+	for i := 0; i < 100; i++ {
+		addr, err := g.randomDiverseNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr == ipv6Node {
+			picked++
+		}
+	}
+	if picked < 25 {
+		t.Error("IPv6 node was only picked", picked, "times out of 100")
+	}
+
+	// Inbound peers don't count towards the diversity of the outbound
+	// peers.
+	g.peers[dummyNode].Inbound = true
+	g.peers["[2001:db8::2]:1111"] = &peer{
+		Peer: modules.Peer{NetAddress: "[2001:db8::2]:1111"},
+		sess: muxado.Client(new(dummyConn)),
+	}
+	picked = 0
+	for i := 0; i < 100; i++ {
+		addr, err := g.randomDiverseNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr == ipv6Node {
+			picked++
+		}
+	}
+	if picked > 10 {
+		t.Error("IPv6 node was preferred when the outbound peers are IPv6:", picked)
+	}
+}
+
 // TestShareNodes checks that two gateways will share nodes with eachother
 // following the desired sharing strategy.
 func TestShareNodes(t *testing.T) {
@@ -179,10 +385,10 @@ func TestShareNodes(t *testing.T) {
 
 	// remove all nodes from both peers
 	g1.mu.Lock()
-	g1.nodes = map[modules.NetAddress]struct{}{}
+	g1.nodes = map[modules.NetAddress]*node{}
 	g1.mu.Unlock()
 	g2.mu.Lock()
-	g2.nodes = map[modules.NetAddress]struct{}{}
+	g2.nodes = map[modules.NetAddress]*node{}
 	g2.mu.Unlock()
 
 	// SharePeers should now return no peers
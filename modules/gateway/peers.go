@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -36,7 +37,13 @@ func (s invalidVersionError) Error() string {
 
 type peer struct {
 	modules.Peer
-	sess muxado.Session
+	id    nodeID
+	sess  muxado.Session
+	score peerScore
+
+	// buckets limit how often the peer may call each rate limited RPC. They
+	// are created the first time the peer calls the RPC.
+	buckets map[rpcID]*tokenBucket
 }
 
 func (p *peer) open() (modules.PeerConn, error) {
@@ -62,16 +69,24 @@ func (g *Gateway) addPeer(p *peer) {
 	go g.threadedListenPeer(p)
 }
 
-// randomOutboundPeer returns a random outbound peer.
+// randomOutboundPeer returns a random outbound peer. Peers with a negative
+// score are only returned if there are no other outbound peers.
 func (g *Gateway) randomOutboundPeer() (modules.NetAddress, error) {
-	// Get the list of outbound peers.
-	var addrs []modules.NetAddress
+	// Get the list of outbound peers, preferring those in good standing.
+	var addrs, poorAddrs []modules.NetAddress
 	for addr, peer := range g.peers {
 		if peer.Inbound {
 			continue
 		}
+		if peer.score.score() < 0 {
+			poorAddrs = append(poorAddrs, addr)
+			continue
+		}
 		addrs = append(addrs, addr)
 	}
+	if len(addrs) == 0 {
+		addrs = poorAddrs
+	}
 	if len(addrs) == 0 {
 		return "", errNoPeers
 	}
@@ -113,16 +128,31 @@ func (g *Gateway) permanentListen(closeChan chan struct{}) {
 
 // threadedAcceptConn adds a connecting node as a peer.
 func (g *Gateway) threadedAcceptConn(conn net.Conn) {
-	if g.threads.Add() != nil {
+	if g.threads.AddNamed("threadedAcceptConn") != nil {
 		conn.Close()
 		return
 	}
-	defer g.threads.Done()
+	defer g.threads.DoneNamed("threadedAcceptConn")
 	conn.SetDeadline(time.Now().Add(connStdDeadline))
 
 	addr := modules.NetAddress(conn.RemoteAddr().String())
 	g.log.Debugf("INFO: %v wants to connect", addr)
 
+	g.mu.RLock()
+	banned := g.banned(addr)
+	limitErr := g.checkPeerLimits(addr, true)
+	g.mu.RUnlock()
+	if banned {
+		g.log.Debugf("INFO: %v wanted to connect, but its host is banned", addr)
+		conn.Close()
+		return
+	}
+	if limitErr != nil {
+		g.log.Debugf("INFO: %v wanted to connect, but was rejected: %v", addr, limitErr)
+		conn.Close()
+		return
+	}
+
 	remoteVersion, err := acceptConnVersionHandshake(conn, build.Version)
 	if err != nil {
 		g.log.Debugf("INFO: %v wanted to connect but version handshake failed: %v", addr, err)
@@ -158,7 +188,7 @@ func (g *Gateway) managedAcceptConnOldPeer(conn net.Conn, remoteVersion string)
 
 	// Old peers are unable to give us a dialback port, so we can't verify
 	// whether or not they are local peers.
-	g.acceptPeer(&peer{
+	return g.acceptPeer(&peer{
 		Peer: modules.Peer{
 			Inbound:    true,
 			Local:      false,
@@ -167,8 +197,6 @@ func (g *Gateway) managedAcceptConnOldPeer(conn net.Conn, remoteVersion string)
 		},
 		sess: muxado.Server(conn),
 	})
-
-	return nil
 }
 
 // managedAcceptConnNewPeer accepts connection requests from peers >= v1.0.0.
@@ -181,6 +209,26 @@ func (g *Gateway) managedAcceptConnNewPeer(conn net.Conn, remoteVersion string)
 	if err != nil {
 		return err
 	}
+	// Learn the peer's node ID, and make sure that the peer is not the
+	// gateway itself.
+	var remoteID nodeID
+	if build.VersionCmp(remoteVersion, nodeIDUpgradeVersion) >= 0 {
+		remoteID, err = acceptConnNodeIDHandshake(conn, g.id)
+		if err != nil {
+			return err
+		}
+		if err := g.managedCheckNodeID(remoteAddr, remoteID); err != nil {
+			return err
+		}
+	}
+	// Encrypt the rest of the connection. Inbound peers are not checked
+	// against pinned keys, as they may connect from any address.
+	if build.VersionCmp(remoteVersion, sessionUpgradeVersion) >= 0 {
+		conn, _, err = sessionHandshake(conn, g.keys, false, g.id, remoteID)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Attempt to add the peer to the node list. If the add is successful and
 	// the address is a local address, mark the peer as a local peer.
@@ -198,59 +246,82 @@ func (g *Gateway) managedAcceptConnNewPeer(conn net.Conn, remoteVersion string)
 		return fmt.Errorf("already connected to a peer on that address: %v", remoteAddr)
 	}
 	// Accept the peer.
-	g.acceptPeer(&peer{
+	return g.acceptPeer(&peer{
 		Peer: modules.Peer{
 			Inbound:    true,
 			Local:      local,
 			NetAddress: remoteAddr,
 			Version:    remoteVersion,
 		},
+		id:   remoteID,
 		sess: muxado.Server(conn),
 	})
-	return nil
 }
 
-// acceptPeer makes room for the peer if necessary by kicking out existing
-// peers, then adds the peer to the peer list.
-func (g *Gateway) acceptPeer(p *peer) {
-	// If we are not fully connected, add the peer without kicking any out.
-	if len(g.peers) < fullyConnectedThreshold {
+// acceptPeer adds the peer to the peer list, making room for it if necessary
+// by kicking out an inbound peer. An error is returned if the peer cannot be
+// added without exceeding the gateway's limits, or if the gateway is already
+// connected to the same node via a different address. Local peers are always
+// added.
+func (g *Gateway) acceptPeer(p *peer) error {
+	if existing := g.peerWithNodeID(p.id); existing != nil {
+		g.log.Debugf("INFO: %v is the same node as existing peer %v", p.NetAddress, existing.NetAddress)
+		return errDuplicateNodeID
+	}
+	if err := g.checkPeerLimits(p.NetAddress, p.Inbound); err != nil {
+		return err
+	}
+	if p.NetAddress.IsLocal() {
 		g.addPeer(p)
-		return
+		return nil
+	}
+
+	// If we have room for the peer, add it without kicking any out.
+	total, inbound, _ := g.peerCounts(p.NetAddress)
+	if total < g.settings.MaxPeers && (!p.Inbound || inbound < g.settings.MaxInboundPeers) {
+		g.addPeer(p)
+		return nil
 	}
 
 	// Select a peer to kick. Outbound peers and local peers are not
 	// available to be kicked.
-	var addrs []modules.NetAddress
-	for addr := range g.peers {
+	var candidates []*peer
+	for addr, existing := range g.peers {
 		// Do not kick outbound peers or local peers.
-		if !p.Inbound || p.Local {
+		if !existing.Inbound || addr.IsLocal() {
 			continue
 		}
 
 		// Prefer kicking a peer with the same hostname.
 		if addr.Host() == p.NetAddress.Host() {
-			addrs = []modules.NetAddress{addr}
+			candidates = []*peer{existing}
 			break
 		}
-		addrs = append(addrs, addr)
+		candidates = append(candidates, existing)
 	}
-	if len(addrs) == 0 {
-		// There is nobody suitable to kick, therefore do not kick anyone.
-		g.addPeer(p)
-		return
+	if len(candidates) == 0 {
+		// There is nobody suitable to kick, therefore the peer cannot be
+		// added.
+		return errPeerLimit
 	}
 
-	// Of the remaining options, select one at random.
-	r, err := crypto.RandIntn(len(addrs))
+	// Of the remaining options, kick the peer with the lowest score, breaking
+	// ties at random.
+	sort.Sort(byScore(candidates))
+	lowest := 0
+	for lowest < len(candidates) && candidates[lowest].score.score() == candidates[0].score.score() {
+		lowest++
+	}
+	r, err := crypto.RandIntn(lowest)
 	if err != nil {
 		g.log.Severe("random number generation failure:", err)
 	}
-	kick := addrs[r]
+	kick := candidates[r].NetAddress
 	g.peers[kick].sess.Close()
 	delete(g.peers, kick)
 	g.log.Printf("INFO: disconnected from %v to make room for %v\n", kick, p.NetAddress)
 	g.addPeer(p)
+	return nil
 }
 
 // acceptConnPortHandshake performs the port handshake and should be called on
@@ -360,7 +431,7 @@ func (g *Gateway) managedConnectOldPeer(conn net.Conn, remoteVersion string, rem
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.addPeer(&peer{
+	return g.acceptPeer(&peer{
 		Peer: modules.Peer{
 			Inbound:    false,
 			Local:      local,
@@ -369,7 +440,6 @@ func (g *Gateway) managedConnectOldPeer(conn net.Conn, remoteVersion string, rem
 		},
 		sess: muxado.Client(conn),
 	})
-	return nil
 }
 
 // managedConnectNewPeer connects to peers >= v1.0.0. The peer is added as a
@@ -384,6 +454,27 @@ func (g *Gateway) managedConnectNewPeer(conn net.Conn, remoteVersion string, rem
 	if err != nil {
 		return err
 	}
+	// Exchange node IDs, and make sure that the address does not lead back to
+	// the gateway itself.
+	var remoteID nodeID
+	if build.VersionCmp(remoteVersion, nodeIDUpgradeVersion) >= 0 {
+		remoteID, err = connectNodeIDHandshake(conn, g.id)
+		if err != nil {
+			return err
+		}
+		if err := g.managedCheckNodeID(remoteAddr, remoteID); err != nil {
+			return err
+		}
+	}
+	// Encrypt the rest of the connection, and authenticate the node that
+	// was dialed.
+	var remoteKey crypto.PublicKey
+	if build.VersionCmp(remoteVersion, sessionUpgradeVersion) >= 0 {
+		conn, remoteKey, err = sessionHandshake(conn, g.keys, true, g.id, remoteID)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Attempt to add the peer to the node list. If the add is successful and
 	// the address is a local address, mark the peer as a local peer.
@@ -395,16 +486,20 @@ func (g *Gateway) managedConnectNewPeer(conn net.Conn, remoteVersion string, rem
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.addPeer(&peer{
+	if err := g.pinNodeKey(remoteAddr, remoteID, remoteKey); err != nil {
+		return err
+	}
+	g.markNodeConnected(remoteAddr)
+	return g.acceptPeer(&peer{
 		Peer: modules.Peer{
 			Inbound:    false,
 			Local:      local,
 			NetAddress: remoteAddr,
 			Version:    remoteVersion,
 		},
+		id:   remoteID,
 		sess: muxado.Client(conn),
 	})
-	return nil
 }
 
 // managedConnect establishes a persistent connection to a peer, and adds it to
@@ -425,12 +520,23 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 	}
 	g.mu.RLock()
 	_, exists := g.peers[addr]
+	banned := g.banned(addr)
+	limitErr := g.checkPeerLimits(addr, false)
 	g.mu.RUnlock()
 	if exists {
 		return errPeerExists
 	}
+	if banned {
+		return errPeerBanned
+	}
+	if limitErr != nil {
+		return limitErr
+	}
 
-	// Dial the peer and perform peer initialization.
+	// Dial the peer and perform peer initialization. The time taken to dial
+	// the peer and complete the version handshake is recorded as the peer's
+	// latency.
+	start := time.Now()
 	conn, err := g.dial(addr)
 	if err != nil {
 		return err
@@ -442,6 +548,7 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 		conn.Close()
 		return err
 	}
+	latency := time.Since(start)
 	if build.VersionCmp(remoteVersion, handshakeUpgradeVersion) < 0 {
 		err = g.managedConnectOldPeer(conn, remoteVersion, addr)
 	} else {
@@ -451,6 +558,11 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 		conn.Close()
 		return err
 	}
+	g.mu.Lock()
+	if p, exists := g.peers[addr]; exists {
+		p.score.latency = latency
+	}
+	g.mu.Unlock()
 	g.log.Debugln("INFO: connected to new peer", addr)
 
 	// Connection successful, clear the timeout as to maintain a persistent
@@ -518,7 +630,9 @@ func (g *Gateway) Peers() []modules.Peer {
 	defer g.mu.RUnlock()
 	var peers []modules.Peer
 	for _, p := range g.peers {
-		peers = append(peers, p.Peer)
+		info := p.Peer
+		info.Score = p.score.score()
+		peers = append(peers, info)
 	}
 	return peers
 }
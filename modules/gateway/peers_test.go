@@ -347,6 +347,40 @@ func TestUnitAcceptableVersion(t *testing.T) {
 	}
 }
 
+// TestConnectIPv6 checks that gateways can listen on and connect to IPv6
+// addresses.
+func TestConnectIPv6(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+	l.Close()
+
+	g1, err := New("[::1]:0", false, build.TempDir("gateway", "TestConnectIPv6_1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	g2, err := New("[::1]:0", false, build.TempDir("gateway", "TestConnectIPv6_2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+
+	if !g1.Address().IsIPv6() {
+		t.Fatal("gateway is not listening on an IPv6 address:", g1.Address())
+	}
+	if err := g2.Connect(g1.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if len(g1.Peers()) != 1 || !g1.Peers()[0].NetAddress.IsIPv6() {
+		t.Fatal("IPv6 peer was not added:", g1.Peers())
+	}
+}
+
 // TestConnectRejectsInvalidAddrs tests that Connect only connects to valid IP
 // addresses.
 func TestConnectRejectsInvalidAddrs(t *testing.T) {
@@ -644,6 +678,26 @@ func TestAcceptConnRejectsVersions(t *testing.T) {
 	}
 }
 
+// TestHandshakeUpgradeVersions checks that the extensions of the handshake are
+// only used with peers whose version supports them. Released v1.0.3 nodes do
+// not read or send any of the extensions.
+func TestHandshakeUpgradeVersions(t *testing.T) {
+	for _, version := range []string{"1.0.0", "1.0.3"} {
+		if build.VersionCmp(version, nodeIDUpgradeVersion) >= 0 {
+			t.Errorf("node ID handshake would be used with a v%v peer", version)
+		}
+		if build.VersionCmp(version, sessionUpgradeVersion) >= 0 {
+			t.Errorf("session handshake would be used with a v%v peer", version)
+		}
+	}
+	if build.VersionCmp(build.Version, nodeIDUpgradeVersion) < 0 {
+		t.Error("node ID handshake would not be used with peers running this version")
+	}
+	if build.VersionCmp(build.Version, sessionUpgradeVersion) < 0 {
+		t.Error("session handshake would not be used with peers running this version")
+	}
+}
+
 // TestDisconnect checks that calls to gateway.Disconnect correctly disconnect
 // and remove peers from the gateway.
 func TestDisconnect(t *testing.T) {
@@ -704,8 +758,8 @@ func TestPeerManager(t *testing.T) {
 
 	// g1's node list should only contain g2
 	g1.mu.Lock()
-	g1.nodes = map[modules.NetAddress]struct{}{}
-	g1.nodes[g2.Address()] = struct{}{}
+	g1.nodes = map[modules.NetAddress]*node{}
+	g1.nodes[g2.Address()] = &node{NetAddress: g2.Address()}
 	g1.mu.Unlock()
 
 	// when peerManager wakes up, it should connect to g2.
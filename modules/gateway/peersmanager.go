@@ -1,6 +1,8 @@
 package gateway
 
 import (
+	"sync"
+
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 )
@@ -55,31 +57,70 @@ func (g *Gateway) numOutboundPeers() (numOutboundPeers int) {
 	return numOutboundPeers
 }
 
+// managedConnectAnchors reconnects to the nodes that were outbound peers of
+// the gateway shortly before it was last shut down. The connections are
+// formed concurrently, and managedConnectAnchors returns once every attempt
+// has finished.
+func (g *Gateway) managedConnectAnchors() {
+	g.mu.RLock()
+	anchors := g.anchorNodes()
+	g.mu.RUnlock()
+	if len(anchors) == 0 {
+		return
+	}
+	g.log.Debugln("[PPM] Reconnecting to", len(anchors), "anchor peers")
+
+	var wg sync.WaitGroup
+	for _, addr := range anchors {
+		wg.Add(1)
+		go func(addr modules.NetAddress) {
+			defer wg.Done()
+			if err := g.threads.Add(); err != nil {
+				return
+			}
+			defer g.threads.Done()
+			g.managedPeerManagerConnect(addr)
+		}(addr)
+	}
+	wg.Wait()
+}
+
 // permanentPeerManager tries to keep the Gateway well-connected. As long as
 // the Gateway is not well-connected, it tries to connect to random nodes.
 func (g *Gateway) permanentPeerManager(closedChan chan struct{}) {
 	// Send a signal upon shutdown.
 	defer close(closedChan)
 
+	// Reconnect to the recent outbound peers before selecting random nodes,
+	// which include the bootstrap nodes.
+	g.managedConnectAnchors()
+
 	// permanentPeerManager will attempt to connect to peers asynchronously,
 	// such that multiple connection attempts can be open at once, but a
 	// limited number.
 	connectionLimiterChan := make(chan struct{}, maxConcurrentOutboundPeerRequests)
 
 	for {
-		// If the gateway is well connected, sleep for a while and then try
-		// again.
+		// If the gateway is well connected, or has as many outbound peers as
+		// its peer limit allows, sleep for a while and then try again.
+		// Before sleeping, evict the worst outbound peer if it has been
+		// behaving poorly, so that its slot can be filled by a better peer.
 		numOutboundPeers := g.numOutboundPeers()
-		if numOutboundPeers >= wellConnectedThreshold {
+		g.mu.RLock()
+		maxPeers := g.settings.MaxPeers
+		g.mu.RUnlock()
+		if numOutboundPeers >= wellConnectedThreshold || numOutboundPeers >= maxPeers {
+			g.managedEvictPeer()
 			if !g.managedSleep(wellConnectedDelay) {
 				return
 			}
 			continue
 		}
 
-		// Fetch a random node.
+		// Fetch a random node, preferring the address family that the
+		// outbound peers are missing.
 		g.mu.RLock()
-		addr, err := g.randomNode()
+		addr, err := g.randomDiverseNode()
 		g.mu.RUnlock()
 		// If there was an error, log the error and then wait a while before
 		// trying again.
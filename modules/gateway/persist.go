@@ -11,6 +11,22 @@ const (
 	// nodesFile is the name of the file that contains all seen nodes.
 	nodesFile = "nodes.json"
 
+	// nodeIDFile is the name of the file that contains the gateway's node
+	// ID.
+	nodeIDFile = "nodeid.json"
+
+	// sessionKeysFile is the name of the file that contains the key pair
+	// that the gateway uses to authenticate itself to peers.
+	sessionKeysFile = "sessionkeys.json"
+
+	// pinnedKeysFile is the name of the file that contains the public keys
+	// pinned to the IDs of the nodes that the gateway has dialed.
+	pinnedKeysFile = "pinnedkeys.json"
+
+	// settingsFile is the name of the file that contains the gateway's
+	// settings.
+	settingsFile = "settings.json"
+
 	// logFile is the name of the log file.
 	logFile = modules.GatewayDir + ".log"
 )
@@ -18,34 +34,109 @@ const (
 // persistMetadata contains the header and version strings that identify the
 // gateway persist file.
 var persistMetadata = persist.Metadata{
+	Header:  "Sia Node List",
+	Version: "1.0.4",
+}
+
+// compatV103PersistMetadata contains the header and version strings of the
+// gateway persist file prior to v1.0.4, when only the addresses of the nodes
+// were saved.
+var compatV103PersistMetadata = persist.Metadata{
 	Header:  "Sia Node List",
 	Version: "0.3.3",
 }
 
+// nodeIDMetadata contains the header and version strings that identify the
+// gateway node ID file.
+var nodeIDMetadata = persist.Metadata{
+	Header:  "Gateway Node ID",
+	Version: "1.0.3",
+}
+
+// sessionKeysMetadata contains the header and version strings that identify
+// the gateway session keys file.
+var sessionKeysMetadata = persist.Metadata{
+	Header:  "Gateway Session Keys",
+	Version: "1.0.3",
+}
+
+// pinnedKeysMetadata contains the header and version strings that identify
+// the gateway pinned keys file.
+var pinnedKeysMetadata = persist.Metadata{
+	Header:  "Gateway Pinned Keys",
+	Version: "1.0.4",
+}
+
+// settingsMetadata contains the header and version strings that identify the
+// gateway settings file.
+var settingsMetadata = persist.Metadata{
+	Header:  "Gateway Settings",
+	Version: "1.0.0",
+}
+
 // persistData returns the data in the Gateway that will be saved to disk.
-func (g *Gateway) persistData() (nodes []modules.NetAddress) {
-	for node := range g.nodes {
-		nodes = append(nodes, node)
+func (g *Gateway) persistData() (nodes []*node) {
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
 	}
 	return
 }
 
 // load loads the Gateway's persistent data from disk.
 func (g *Gateway) load() error {
-	var nodes []modules.NetAddress
+	var nodes []*node
 	err := persist.LoadFile(persistMetadata, &nodes, filepath.Join(g.persistDir, nodesFile))
+	if err == persist.ErrBadVersion {
+		// COMPATv1.0.3 - node lists saved by v1.0.3 and earlier are a list
+		// of addresses.
+		nodes, err = g.compatLoadV103()
+	}
 	if err != nil {
 		return err
 	}
-	for _, node := range nodes {
-		err := g.addNode(node)
+	for _, n := range nodes {
+		err := g.addNode(n.NetAddress)
 		if err != nil {
-			g.log.Printf("WARN: error loading node '%v' from persist: %v", node, err)
+			g.log.Printf("WARN: error loading node '%v' from persist: %v", n.NetAddress, err)
+			continue
 		}
+		if !n.FirstSeen.IsZero() {
+			*g.nodes[n.NetAddress] = *n
+		}
+	}
+	return nil
+}
+
+// loadSettings loads the Gateway's settings from disk. The settings on disk
+// are only used if they are valid.
+func (g *Gateway) loadSettings() error {
+	var settings modules.GatewaySettings
+	err := persist.LoadFile(settingsMetadata, &settings, filepath.Join(g.persistDir, settingsFile))
+	if err != nil {
+		return err
+	}
+	if err := validateSettings(settings); err != nil {
+		return err
 	}
+	g.settings = settings
 	return nil
 }
 
+// compatLoadV103 loads a node list that was saved prior to v1.0.4. The nodes
+// are treated as if they had just been added to the node list.
+func (g *Gateway) compatLoadV103() ([]*node, error) {
+	var addrs []modules.NetAddress
+	err := persist.LoadFile(compatV103PersistMetadata, &addrs, filepath.Join(g.persistDir, nodesFile))
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*node, 0, len(addrs))
+	for _, addr := range addrs {
+		nodes = append(nodes, &node{NetAddress: addr})
+	}
+	return nodes, nil
+}
+
 // save stores the Gateway's persistent data on disk.
 func (g *Gateway) save() error {
 	return persist.SaveFile(persistMetadata, g.persistData(), filepath.Join(g.persistDir, nodesFile))
@@ -56,3 +147,9 @@ func (g *Gateway) save() error {
 func (g *Gateway) saveSync() error {
 	return persist.SaveFileSync(persistMetadata, g.persistData(), filepath.Join(g.persistDir, nodesFile))
 }
+
+// saveSettingsSync stores the Gateway's settings on disk, and then syncs to
+// disk.
+func (g *Gateway) saveSettingsSync() error {
+	return persist.SaveFileSync(settingsMetadata, g.settings, filepath.Join(g.persistDir, settingsFile))
+}
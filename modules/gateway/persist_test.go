@@ -1,7 +1,12 @@
 package gateway
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
 )
 
 func TestLoad(t *testing.T) {
@@ -24,3 +29,75 @@ func TestLoad(t *testing.T) {
 		t.Fatal("gateway did not load old peer list:", g2.nodes)
 	}
 }
+
+// TestLoadCompatV103 checks that node lists saved prior to v1.0.4, which only
+// contain the addresses of the nodes, can still be loaded.
+func TestLoadCompatV103(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	g := newTestingGateway("TestLoadCompatV103", t)
+	g.Close()
+	err := persist.SaveFile(compatV103PersistMetadata, []modules.NetAddress{dummyNode}, filepath.Join(g.persistDir, nodesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g2, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	n, ok := g2.nodes[dummyNode]
+	if !ok {
+		t.Fatal("gateway did not load old node list:", g2.nodes)
+	}
+	if n.FirstSeen.IsZero() {
+		t.Error("node loaded from old node list has no FirstSeen time")
+	}
+}
+
+// TestAnchorPeersReconnect checks that a restarted gateway reconnects to the
+// outbound peers it had before it was shut down.
+func TestAnchorPeersReconnect(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	g1 := newTestingGateway("TestAnchorPeersReconnect1", t)
+	g2 := newTestingGateway("TestAnchorPeersReconnect2", t)
+	defer g2.Close()
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g3, err := New("localhost:0", false, g1.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g3.Close()
+	g3.mu.RLock()
+	n, exists := g3.nodes[g2.Address()]
+	recorded := exists && !n.LastConnected.IsZero()
+	g3.mu.RUnlock()
+	if !recorded {
+		t.Fatal("connection to the outbound peer was not recorded")
+	}
+
+	// The anchor should be reconnected to well before the peer manager
+	// would normally get around to it.
+	for i := 0; i < 50; i++ {
+		g3.mu.RLock()
+		_, connected := g3.peers[g2.Address()]
+		g3.mu.RUnlock()
+		if connected {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("gateway did not reconnect to its anchor peer")
+}
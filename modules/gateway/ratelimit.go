@@ -0,0 +1,101 @@
+package gateway
+
+// ratelimit.go limits how often each peer may call the RPCs that are
+// expensive to serve, such as block requests and transaction relays. Every
+// peer has a token bucket per limited RPC. Each call takes a token, and the
+// tokens are refilled at a steady rate up to a maximum burst. Calls made
+// without a token are refused, and count as a protocol violation, so a peer
+// that keeps exceeding its limits is eventually banned.
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var errRPCRateLimited = errors.New("peer exceeded the rate limit of the RPC")
+
+// rpcRateLimit is the number of calls to an RPC that a single peer may make
+// per second, along with the number of calls that may be made in a burst.
+type rpcRateLimit struct {
+	rate  float64
+	burst float64
+}
+
+// defaultRPCRateLimits are the rate limits that are applied to each peer.
+// RPCs that are not listed are not limited. Syncing peers call SendBlocks
+// back to back, so its limit is set well above the rate at which a single
+// call can be served.
+var defaultRPCRateLimits = func() map[string]rpcRateLimit {
+	limits := map[string]rpcRateLimit{
+		"SendBlocks":          {rate: 2, burst: 20},
+		"SendBlk":             {rate: 10, burst: 50},
+		"SendBlks":            {rate: 2, burst: 20},
+		"SendHeaders":         {rate: 2, burst: 20},
+		"SendCheckpoint":      {rate: 0.1, burst: 5},
+		"SendTxnProofs":       {rate: 2, burst: 20},
+		"RelayBlock":          {rate: 5, burst: 20},
+		"RelayHeader":         {rate: 5, burst: 20},
+		"RelayTransactionSet": {rate: 20, burst: 100},
+		"ShareNodes":          {rate: 0.1, burst: 10},
+	}
+	switch build.Release {
+	case "dev", "standard":
+	case "testing":
+		// Tests call RPCs far more often than real peers do.
+		for name, limit := range limits {
+			limits[name] = rpcRateLimit{rate: limit.rate * 100, burst: limit.burst * 100}
+		}
+	default:
+		panic("unrecognized build.Release in defaultRPCRateLimits")
+	}
+	return limits
+}()
+
+// tokenBucket tracks the calls that a peer may make to a single RPC.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket according to the time that has passed since it
+// was last used, and then takes a token from it. false is returned if the
+// bucket is empty.
+func (tb *tokenBucket) take(limit rpcRateLimit, now time.Time) bool {
+	tb.tokens += now.Sub(tb.last).Seconds() * limit.rate
+	if tb.tokens > limit.burst {
+		tb.tokens = limit.burst
+	}
+	tb.last = now
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// managedTakeRPCToken reports whether the peer at addr may call the RPC
+// with the provided ID, taking a token from the peer's bucket for the RPC.
+// Calls from addresses that are not peers, and calls to RPCs without a
+// limit, are always allowed.
+func (g *Gateway) managedTakeRPCToken(addr modules.NetAddress, id rpcID) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	limit, limited := g.rpcLimits[id]
+	p, exists := g.peers[addr]
+	if !limited || !exists {
+		return true
+	}
+	if p.buckets == nil {
+		p.buckets = make(map[rpcID]*tokenBucket)
+	}
+	tb, exists := p.buckets[id]
+	if !exists {
+		// New peers start with a full bucket.
+		tb = &tokenBucket{tokens: limit.burst, last: time.Now()}
+		p.buckets[id] = tb
+	}
+	return tb.take(limit, time.Now())
+}
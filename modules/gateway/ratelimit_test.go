@@ -0,0 +1,137 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/muxado"
+)
+
+// TestTokenBucket checks that a token bucket allows bursts up to its limit
+// and refills at the configured rate.
+func TestTokenBucket(t *testing.T) {
+	limit := rpcRateLimit{rate: 2, burst: 3}
+	now := time.Now()
+	tb := &tokenBucket{tokens: limit.burst, last: now}
+	for i := 0; i < 3; i++ {
+		if !tb.take(limit, now) {
+			t.Fatal("call within the burst was refused:", i)
+		}
+	}
+	if tb.take(limit, now) {
+		t.Fatal("call beyond the burst was allowed")
+	}
+
+	// After half a second, one more call is allowed.
+	now = now.Add(500 * time.Millisecond)
+	if !tb.take(limit, now) {
+		t.Fatal("bucket was not refilled")
+	}
+	if tb.take(limit, now) {
+		t.Fatal("bucket was refilled too quickly")
+	}
+
+	// The bucket never holds more than the burst.
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !tb.take(limit, now) {
+			t.Fatal("call within the burst was refused:", i)
+		}
+	}
+	if tb.take(limit, now) {
+		t.Fatal("bucket was refilled beyond the burst")
+	}
+}
+
+// TestTakeRPCToken checks that each peer has its own buckets, and that RPCs
+// without a limit are not limited.
+func TestTakeRPCToken(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestTakeRPCToken", t)
+	defer g.Close()
+
+	limited, unlimited := handlerName("Limited"), handlerName("Unlimited")
+	addr1 := modules.NetAddress("1.2.3.4:1234")
+	addr2 := modules.NetAddress("5.6.7.8:5678")
+	g.mu.Lock()
+	g.rpcLimits[limited] = rpcRateLimit{rate: 0, burst: 2}
+	for _, addr := range []modules.NetAddress{addr1, addr2} {
+		g.addPeer(&peer{
+			Peer: modules.Peer{
+				NetAddress: addr,
+				Inbound:    true,
+			},
+			sess: muxado.Client(new(dummyConn)),
+		})
+	}
+	g.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if !g.managedTakeRPCToken(addr1, limited) {
+			t.Fatal("call within the limit was refused")
+		}
+	}
+	if g.managedTakeRPCToken(addr1, limited) {
+		t.Fatal("call beyond the limit was allowed")
+	}
+	if !g.managedTakeRPCToken(addr2, limited) {
+		t.Fatal("limit was shared between peers")
+	}
+	for i := 0; i < 10; i++ {
+		if !g.managedTakeRPCToken(addr1, unlimited) {
+			t.Fatal("RPC without a limit was limited")
+		}
+	}
+}
+
+// TestRPCRateLimitDisconnects checks that a peer which keeps calling an RPC
+// beyond its limit is refused service and eventually disconnected.
+func TestRPCRateLimitDisconnects(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g1 := newTestingGateway("TestRPCRateLimitDisconnects1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestRPCRateLimitDisconnects2", t)
+	defer g2.Close()
+
+	g1.RegisterRPC("Foo", func(conn modules.PeerConn) error {
+		_, err := conn.Write([]byte{1})
+		return err
+	})
+	g1.mu.Lock()
+	g1.rpcLimits[handlerName("Foo")] = rpcRateLimit{rate: 0, burst: 1}
+	g1.mu.Unlock()
+	if err := g2.Connect(g1.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	callFoo := func() error {
+		return g2.RPC(g1.Address(), "Foo", func(conn modules.PeerConn) error {
+			_, err := conn.Read(make([]byte, 1))
+			return err
+		})
+	}
+	if err := callFoo(); err != nil {
+		t.Fatal("call within the limit failed:", err)
+	}
+	if err := callFoo(); err == nil {
+		t.Fatal("call beyond the limit was served")
+	}
+
+	// Local peers are disconnected rather than banned once their score is
+	// low enough.
+	for i := 0; i < -banPeerScore/violationPenalty+1; i++ {
+		callFoo()
+	}
+	time.Sleep(100 * time.Millisecond)
+	g1.mu.RLock()
+	_, connected := g1.peers[g2.Address()]
+	g1.mu.RUnlock()
+	if connected {
+		t.Fatal("peer that exceeded its rate limit was not disconnected")
+	}
+}
@@ -10,6 +10,10 @@ import (
 	"github.com/NebulousLabs/Sia/modules"
 )
 
+// rpcHeaderLen is the length of the header that precedes each RPC: an 8-byte
+// length prefix followed by the 8-byte rpcID.
+const rpcHeaderLen = 16
+
 // rpcID is an 8-byte signature that is added to all RPCs to tell the gatway
 // what to do with the RPC.
 type rpcID [8]byte
@@ -48,6 +52,7 @@ func (g *Gateway) managedRPC(addr modules.NetAddress, name string, fn modules.RP
 		return err
 	}
 	defer conn.Close()
+	conn = g.managedMeterConn(conn, name)
 
 	// write header
 	if err := encoding.WriteObject(conn, handlerName(name)); err != nil {
@@ -79,6 +84,7 @@ func (g *Gateway) RegisterRPC(name string, fn modules.RPCFunc) {
 		build.Critical("RPC already registered: " + name)
 	}
 	g.handlers[handlerName(name)] = fn
+	g.rpcBandwidthFor(name)
 }
 
 // UnregisterRPC unregisters an RPC and removes the corresponding RPCFunc from
@@ -126,10 +132,10 @@ func (g *Gateway) threadedListenPeer(p *peer) {
 	// tool for closing out the threads. Instead, they register to peerTG,
 	// which is cleanly closed upon gateway shutdown but will not block any
 	// calls to threads.Flush()
-	if g.peerTG.Add() != nil {
+	if g.peerTG.AddNamed("threadedListenPeer") != nil {
 		return
 	}
-	defer g.peerTG.Done()
+	defer g.peerTG.DoneNamed("threadedListenPeer")
 
 	// Spin up a goroutine to listen for a shutdown signal from both the peer
 	// and from the gateway. In the event of either, close the muxado session.
@@ -149,6 +155,9 @@ func (g *Gateway) threadedListenPeer(p *peer) {
 		// Can't call Disconnect because it could return sync.ErrStopped.
 		g.mu.Lock()
 		delete(g.peers, p.NetAddress)
+		if !p.Inbound {
+			g.markNodeConnected(p.NetAddress)
+		}
 		g.mu.Unlock()
 		if err := p.sess.Close(); err != nil {
 			g.log.Debugf("WARN: error disconnecting from peer %q: %v", p.NetAddress, err)
@@ -176,10 +185,10 @@ func (g *Gateway) threadedListenPeer(p *peer) {
 // appropriate handler for further processing.
 func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
 	defer conn.Close()
-	if g.threads.Add() != nil {
+	if g.threads.AddNamed("threadedHandleConn") != nil {
 		return
 	}
-	defer g.threads.Done()
+	defer g.threads.DoneNamed("threadedHandleConn")
 
 	var id rpcID
 	if err := encoding.ReadObject(conn, &id, 8); err != nil {
@@ -188,21 +197,45 @@ func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
 	// call registered handler for this ID
 	g.mu.RLock()
 	fn, ok := g.handlers[id]
+	bw := g.bandwidth[id]
 	g.mu.RUnlock()
 	if !ok {
 		g.log.Debugf("WARN: incoming conn %v requested unknown RPC \"%v\"", conn.RPCAddr(), id)
+		g.managedRecordViolation(conn.RPCAddr(), errors.New("unknown RPC "+id.String()))
+		return
+	}
+	if !g.managedTakeRPCToken(conn.RPCAddr(), id) {
+		g.log.Debugf("WARN: incoming conn %v exceeded the rate limit of RPC \"%v\"", conn.RPCAddr(), id)
+		g.managedRecordViolation(conn.RPCAddr(), errRPCRateLimited)
 		return
 	}
 	g.log.Debugf("INFO: incoming conn %v requested RPC \"%v\"", conn.RPCAddr(), id)
 
+	// Count the header towards the RPC, and count the rest of the data
+	// transferred by the handler.
+	if bw != nil {
+		bw.record(rpcHeaderLen, false)
+		conn = &meteredConn{
+			PeerConn: conn,
+			bw:       bw,
+			stop:     g.threads.StopChan(),
+		}
+	}
+
 	// call fn
 	err := fn(conn)
-	// don't log benign errors
-	if err == modules.ErrDuplicateTransactionSet || err == modules.ErrBlockKnown {
-		err = nil
-	}
-	if err != nil {
+	// Only explicit protocol violations lower the peer's score. Other errors,
+	// such as the peer relaying something we already had or the connection
+	// failing, are not necessarily the peer's fault.
+	if pv, ok := err.(modules.ProtocolViolation); ok {
+		g.managedRecordViolation(conn.RPCAddr(), pv)
+		return
+	} else if err != nil {
 		g.log.Debugf("WARN: incoming RPC \"%v\" from conn %v failed: %v", id, conn.RPCAddr(), err)
+		return
+	}
+	if _, ok := relayRPCs[id]; ok {
+		g.managedRecordRelay(conn.RPCAddr())
 	}
 }
 
@@ -0,0 +1,166 @@
+package gateway
+
+// scoring.go keeps track of how well each peer behaves. Peers earn points for
+// relaying objects that the gateway did not know about yet, and lose points
+// for violating the protocol and for being slow to respond to the connection
+// handshake. The scores are used to decide which peers to keep: when the
+// gateway is full, the lowest scoring inbound peer is kicked to make room for
+// new peers, and once the gateway is well connected, outbound peers with a
+// poor score are evicted so that better peers can take their place. A peer
+// whose score drops too far is disconnected and its host is banned for a
+// while, so that it cannot simply reconnect.
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var errPeerBanned = errors.New("peer's host is banned")
+
+// peerScore tracks the behavior of a peer over the lifetime of its
+// connection.
+type peerScore struct {
+	// latency is the time it took to dial the peer and complete the
+	// handshake. It is only known for outbound peers.
+	latency time.Duration
+
+	// relays is the number of RPCs called by the peer that relayed something
+	// useful, and violations is the number of RPCs called by the peer that
+	// violated the protocol.
+	relays     uint64
+	violations uint64
+}
+
+// score returns the score of the peer. New peers start with a score of zero.
+func (ps peerScore) score() int64 {
+	score := int64(ps.relays)*relayReward - int64(ps.violations)*violationPenalty
+	return score - int64(ps.latency/latencyPenaltyUnit)
+}
+
+// banned returns whether the host of the provided address is currently
+// banned.
+func (g *Gateway) banned(addr modules.NetAddress) bool {
+	expiry, exists := g.bans[addr.Host()]
+	return exists && time.Now().Before(expiry)
+}
+
+// ban disconnects from all peers on the host of the provided address, and
+// refuses connections to and from the host until the ban duration has passed.
+// Local hosts are never banned, as that would cut the gateway off from every
+// node on the local network; only the peer itself is disconnected.
+func (g *Gateway) ban(addr modules.NetAddress) {
+	host := addr.Host()
+	if addr.IsLocal() {
+		if p, exists := g.peers[addr]; exists {
+			delete(g.peers, addr)
+			if err := p.sess.Close(); err != nil {
+				g.log.Debugf("WARN: error disconnecting from misbehaving peer %q: %v", addr, err)
+			}
+		}
+		g.log.Printf("INFO: disconnected from local peer %v after it misbehaved\n", addr)
+		return
+	}
+	g.pruneBans()
+	g.bans[host] = time.Now().Add(g.settings.BanDuration)
+	for peerAddr, p := range g.peers {
+		if peerAddr.Host() != host {
+			continue
+		}
+		delete(g.peers, peerAddr)
+		if err := p.sess.Close(); err != nil {
+			g.log.Debugf("WARN: error disconnecting from banned peer %q: %v", peerAddr, err)
+		}
+	}
+	g.log.Printf("INFO: banned host %v for %v after peer %v misbehaved\n", host, g.settings.BanDuration, addr)
+}
+
+// pruneBans removes the bans that have expired.
+func (g *Gateway) pruneBans() {
+	now := time.Now()
+	for host, expiry := range g.bans {
+		if !now.Before(expiry) {
+			delete(g.bans, host)
+		}
+	}
+}
+
+// managedRecordRelay records that a peer relayed something useful.
+func (g *Gateway) managedRecordRelay(addr modules.NetAddress) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if p, exists := g.peers[addr]; exists {
+		p.score.relays++
+	}
+}
+
+// managedRecordViolation records that a peer violated the protocol, banning
+// the peer's host if its score has dropped too far.
+func (g *Gateway) managedRecordViolation(addr modules.NetAddress, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p, exists := g.peers[addr]
+	if !exists {
+		return
+	}
+	p.score.violations++
+	g.log.Debugf("WARN: peer %v violated the protocol (score %v): %v", addr, p.score.score(), err)
+	if p.score.score() <= banPeerScore {
+		g.ban(addr)
+	}
+}
+
+// byScore sorts peers by ascending score.
+type byScore []*peer
+
+func (ps byScore) Len() int           { return len(ps) }
+func (ps byScore) Less(i, j int) bool { return ps[i].score.score() < ps[j].score.score() }
+func (ps byScore) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+
+// outboundPeersByScore returns the outbound peers of the gateway, sorted by
+// ascending score.
+func (g *Gateway) outboundPeersByScore() []*peer {
+	var peers []*peer
+	for _, p := range g.peers {
+		if !p.Inbound {
+			peers = append(peers, p)
+		}
+	}
+	sort.Sort(byScore(peers))
+	return peers
+}
+
+// managedEvictPeer disconnects from the lowest scoring outbound peer if its
+// score is below evictPeerScore. Evicted peers are not banned, and remain in
+// the node list.
+func (g *Gateway) managedEvictPeer() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	peers := g.outboundPeersByScore()
+	if len(peers) == 0 || peers[0].score.score() >= evictPeerScore {
+		return
+	}
+	p := peers[0]
+	delete(g.peers, p.NetAddress)
+	if err := p.sess.Close(); err != nil {
+		g.log.Debugf("WARN: error disconnecting from evicted peer %q: %v", p.NetAddress, err)
+	}
+	g.log.Printf("INFO: evicted outbound peer %v with score %v\n", p.NetAddress, p.score.score())
+}
+
+// Bans returns the hosts that are currently banned.
+func (g *Gateway) Bans() []modules.PeerBan {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pruneBans()
+	bans := make([]modules.PeerBan, 0, len(g.bans))
+	for host, expiry := range g.bans {
+		bans = append(bans, modules.PeerBan{
+			Host:   host,
+			Expiry: expiry,
+		})
+	}
+	return bans
+}
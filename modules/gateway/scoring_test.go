@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/muxado"
+)
+
+// TestPeerScore checks that relays, violations and latency affect a peer's
+// score as expected.
+func TestPeerScore(t *testing.T) {
+	var ps peerScore
+	if ps.score() != 0 {
+		t.Fatal("new peers should have a score of zero, got", ps.score())
+	}
+	ps.relays = 5
+	if ps.score() != 5*relayReward {
+		t.Fatal("relays did not raise the score:", ps.score())
+	}
+	ps.violations = 1
+	if ps.score() != 5*relayReward-violationPenalty {
+		t.Fatal("violations did not lower the score:", ps.score())
+	}
+	ps.latency = 3 * latencyPenaltyUnit
+	if ps.score() != 5*relayReward-violationPenalty-3 {
+		t.Fatal("latency did not lower the score:", ps.score())
+	}
+}
+
+// TestRecordViolationBans checks that a peer which keeps violating the
+// protocol is disconnected and has its host banned, and that the ban expires.
+func TestRecordViolationBans(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestRecordViolationBans", t)
+	defer g.Close()
+
+	addr := modules.NetAddress("1.2.3.4:1234")
+	g.mu.Lock()
+	g.settings.BanDuration = time.Second
+	g.addPeer(&peer{
+		Peer: modules.Peer{
+			NetAddress: addr,
+			Inbound:    true,
+		},
+		sess: muxado.Client(new(dummyConn)),
+	})
+	g.mu.Unlock()
+
+	g.managedRecordRelay(addr)
+	for i := 0; i < -banPeerScore/violationPenalty; i++ {
+		g.mu.RLock()
+		_, exists := g.peers[addr]
+		g.mu.RUnlock()
+		if !exists {
+			t.Fatal("peer was disconnected after", i, "violations")
+		}
+		g.managedRecordViolation(addr, errors.New("violation"))
+	}
+	g.mu.RLock()
+	_, exists := g.peers[addr]
+	g.mu.RUnlock()
+	if !exists {
+		t.Fatal("peer was disconnected before its score reached banPeerScore")
+	}
+
+	g.managedRecordViolation(addr, errors.New("violation"))
+	g.mu.RLock()
+	_, exists = g.peers[addr]
+	banned := g.banned("1.2.3.4:5678")
+	g.mu.RUnlock()
+	if exists {
+		t.Fatal("misbehaving peer was not disconnected")
+	}
+	if !banned {
+		t.Fatal("misbehaving peer's host was not banned")
+	}
+	if bans := g.Bans(); len(bans) != 1 || bans[0].Host != "1.2.3.4" {
+		t.Fatal("expected the peer's host to be banned, got", bans)
+	}
+	if err := g.Connect(addr); err != errPeerBanned {
+		t.Fatal("expected errPeerBanned, got", err)
+	}
+
+	// The ban should expire after the ban duration.
+	time.Sleep(time.Second)
+	if bans := g.Bans(); len(bans) != 0 {
+		t.Fatal("ban did not expire:", bans)
+	}
+}
+
+// TestBanLocalPeer checks that misbehaving local peers are disconnected, but
+// that their host is not banned.
+func TestBanLocalPeer(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestBanLocalPeer", t)
+	defer g.Close()
+
+	addr := modules.NetAddress("127.0.0.1:1234")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.addPeer(&peer{
+		Peer: modules.Peer{
+			NetAddress: addr,
+			Inbound:    true,
+			Local:      true,
+		},
+		sess: muxado.Client(new(dummyConn)),
+	})
+	g.ban(addr)
+	if _, exists := g.peers[addr]; exists {
+		t.Fatal("misbehaving local peer was not disconnected")
+	}
+	if g.banned(addr) {
+		t.Fatal("local host should not be banned")
+	}
+}
+
+// TestAcceptPeerKicksLowestScore checks that a full gateway kicks the inbound
+// peer with the lowest score to make room for a new peer.
+func TestAcceptPeerKicksLowestScore(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestAcceptPeerKicksLowestScore", t)
+	defer g.Close()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Fill the gateway with inbound peers, all of which have relayed
+	// something useful except for one.
+	worst := modules.NetAddress("1.1.1.1:1")
+	for i := 0; i < fullyConnectedThreshold; i++ {
+		addr := modules.NetAddress(net.JoinHostPort(fmt.Sprintf("1.1.1.%v", i+1), "1"))
+		p := &peer{
+			Peer: modules.Peer{
+				NetAddress: addr,
+				Inbound:    true,
+			},
+			sess: muxado.Client(new(dummyConn)),
+		}
+		if addr != worst {
+			p.score.relays = 1
+		}
+		g.addPeer(p)
+	}
+
+	err := g.acceptPeer(&peer{
+		Peer: modules.Peer{
+			NetAddress: "2.2.2.2:2",
+			Inbound:    true,
+		},
+		sess: muxado.Client(new(dummyConn)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := g.peers["2.2.2.2:2"]; !exists {
+		t.Fatal("new peer was not accepted")
+	}
+	if _, exists := g.peers[worst]; exists {
+		t.Fatal("the lowest scoring peer was not kicked")
+	}
+	if len(g.peers) != fullyConnectedThreshold {
+		t.Fatal("expected one peer to be kicked, have", len(g.peers), "peers")
+	}
+}
+
+// TestEvictPeer checks that the lowest scoring outbound peer is evicted only
+// if its score is below evictPeerScore, and that randomOutboundPeer prefers
+// peers in good standing.
+func TestEvictPeer(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestEvictPeer", t)
+	defer g.Close()
+
+	good := &peer{
+		Peer: modules.Peer{NetAddress: "1.1.1.1:1"},
+		sess: muxado.Client(new(dummyConn)),
+	}
+	bad := &peer{
+		Peer: modules.Peer{NetAddress: "2.2.2.2:2"},
+		sess: muxado.Client(new(dummyConn)),
+	}
+	bad.score.violations = 1
+	g.mu.Lock()
+	g.addPeer(good)
+	g.addPeer(bad)
+	for i := 0; i < 10; i++ {
+		addr, err := g.randomOutboundPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != good.NetAddress {
+			t.Fatal("randomOutboundPeer returned a peer with a negative score")
+		}
+	}
+	g.mu.Unlock()
+
+	// A single violation is not enough to be evicted.
+	g.managedEvictPeer()
+	if g.numOutboundPeers() != 2 {
+		t.Fatal("peer was evicted before its score dropped below evictPeerScore")
+	}
+
+	g.mu.Lock()
+	bad.score.violations = -evictPeerScore/violationPenalty + 1
+	g.mu.Unlock()
+	g.managedEvictPeer()
+	g.mu.RLock()
+	_, goodExists := g.peers[good.NetAddress]
+	_, badExists := g.peers[bad.NetAddress]
+	g.mu.RUnlock()
+	if !goodExists || badExists {
+		t.Fatal("expected only the bad peer to be evicted")
+	}
+}
+
+// TestHandleConnScoring checks that only protocol violations lower the score
+// of the calling peer, and that only successful relays raise it.
+func TestHandleConnScoring(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g1 := newTestingGateway("TestHandleConnScoring1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestHandleConnScoring2", t)
+	defer g2.Close()
+	err := g1.Connect(g2.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g2.RegisterRPC("Fail", func(modules.PeerConn) error {
+		return errors.New("failed")
+	})
+	g2.RegisterRPC("Violate", func(modules.PeerConn) error {
+		return modules.NewProtocolViolation(errors.New("invalid object"))
+	})
+	g2.RegisterRPC("Pull", func(modules.PeerConn) error {
+		return nil
+	})
+	g2.RegisterRPC("RelayBlock", func(modules.PeerConn) error {
+		return nil
+	})
+
+	// waitScore waits for the score of g1 as seen by g2 to match.
+	waitScore := func(relays, violations uint64) {
+		var ps peerScore
+		for i := 0; i < 100; i++ {
+			g2.mu.RLock()
+			ps = g2.peers[g1.Address()].score
+			g2.mu.RUnlock()
+			if ps.relays == relays && ps.violations == violations {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %v relays and %v violations, got %v and %v", relays, violations, ps.relays, ps.violations)
+	}
+	for _, name := range []string{"Fail", "Pull"} {
+		g1.RPC(g2.Address(), name, func(modules.PeerConn) error { return nil })
+	}
+	time.Sleep(100 * time.Millisecond)
+	waitScore(0, 0)
+	g1.RPC(g2.Address(), "RelayBlock", func(modules.PeerConn) error { return nil })
+	waitScore(1, 0)
+	g1.RPC(g2.Address(), "Violate", func(modules.PeerConn) error { return nil })
+	waitScore(1, 1)
+}
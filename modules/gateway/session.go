@@ -0,0 +1,389 @@
+package gateway
+
+// session.go encrypts and authenticates the connections between gateways.
+// Every gateway has a persistent ed25519 key pair. During the connection
+// handshake, both sides exchange their public keys along with ephemeral ECDH
+// keys, and sign the transcript of the handshake with their long-term keys.
+// The shared secret of the ephemeral keys is used to derive one Twofish-GCM
+// key per direction, so that relayed blocks and transactions can be neither
+// read nor altered by anyone on the path between the gateways.
+//
+// The first key that a node presents when the gateway dials it is pinned to
+// the node's ID, so that a node reachable through several addresses has a
+// single pin. The node list remembers which node each address led to. Later
+// connections to that address must lead to the same node, present its pinned
+// key, and not skip the session handshake, which would otherwise be possible
+// by tampering with the unencrypted version handshake. The pin is dropped when
+// the last address leading to the node is purged from the node list, or when
+// it is cleared through UnpinNode.
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// maxSessionFrameSize is the largest amount of plaintext that is sent in
+	// a single encrypted frame.
+	maxSessionFrameSize = 1 << 16
+
+	// maxSessionHelloSize is the largest encoded sessionHello that will be
+	// read from a peer.
+	maxSessionHelloSize = 256
+)
+
+var (
+	errBadEphemeralKey   = errors.New("peer sent an invalid ephemeral key")
+	errBadSessionFrame   = errors.New("peer sent an encrypted frame that is too large")
+	errNodeNotPinned     = errors.New("no public key is pinned for that node")
+	errPeerIDMismatch    = errors.New("peer presented a different node ID than the pinned node last reached at its address")
+	errPeerKeyMismatch   = errors.New("peer presented a different public key than the one pinned for its node ID")
+	errSessionDowngrade  = errors.New("peer skipped the session handshake, but a public key is pinned for the node at its address")
+	errSessionTampered   = errors.New("encrypted frame failed authentication")
+	errSessionSignature  = errors.New("peer did not prove ownership of its public key")
+	sessionSpecifier     = types.Specifier{'g', 'a', 't', 'e', 'w', 'a', 'y', ' ', 's', 'e', 's', 's', 'i', 'o', 'n'}
+	sessionInitiatorRole = "initiator"
+	sessionResponderRole = "responder"
+)
+
+// sessionKeys is the long-term key pair of the gateway, which peers use to
+// authenticate it.
+type sessionKeys struct {
+	PublicKey crypto.PublicKey
+	SecretKey crypto.SecretKey
+}
+
+// pinnedKey is the public key pinned for a node ID, as it is saved on disk.
+type pinnedKey struct {
+	NodeID    nodeID             `json:"nodeid"`
+	PublicKey types.SiaPublicKey `json:"publickey"`
+}
+
+// sessionHello is sent by both sides at the start of the session handshake.
+type sessionHello struct {
+	PublicKey crypto.PublicKey
+	Ephemeral []byte
+}
+
+// loadSessionKeys loads the gateway's key pair from disk. If no key pair has
+// been saved yet, a new one is generated and saved.
+func (g *Gateway) loadSessionKeys() error {
+	err := persist.LoadFile(sessionKeysMetadata, &g.keys, filepath.Join(g.persistDir, sessionKeysFile))
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	g.keys.SecretKey, g.keys.PublicKey, err = crypto.GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+	return persist.SaveFileSync(sessionKeysMetadata, g.keys, filepath.Join(g.persistDir, sessionKeysFile))
+}
+
+// sessionHandshake performs the session handshake on conn, returning a
+// connection that encrypts all further traffic along with the public key of
+// the remote node. 'initiator' is true on the side that dialed the
+// connection. The node IDs that were exchanged earlier in the handshake are
+// part of the signed transcript, so that they cannot be altered either.
+func sessionHandshake(conn net.Conn, keys sessionKeys, initiator bool, localID, remoteID nodeID) (net.Conn, crypto.PublicKey, error) {
+	curve := elliptic.P256()
+	ephemeral, x, y, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, crypto.PublicKey{}, err
+	}
+	local := sessionHello{
+		PublicKey: keys.PublicKey,
+		Ephemeral: elliptic.Marshal(curve, x, y),
+	}
+
+	// Exchange hellos.
+	var remote sessionHello
+	writeHello := func() error {
+		if err := encoding.WriteObject(conn, local); err != nil {
+			return fmt.Errorf("failed to write session hello: %v", err)
+		}
+		return nil
+	}
+	readHello := func() error {
+		if err := encoding.ReadObject(conn, &remote, maxSessionHelloSize); err != nil {
+			return fmt.Errorf("failed to read session hello: %v", err)
+		}
+		return nil
+	}
+	if err := sessionExchange(initiator, writeHello, readHello); err != nil {
+		return nil, crypto.PublicKey{}, err
+	}
+	rx, ry := elliptic.Unmarshal(curve, remote.Ephemeral)
+	if rx == nil {
+		return nil, crypto.PublicKey{}, errBadEphemeralKey
+	}
+
+	// Both sides sign the transcript along with their role, so that a
+	// signature cannot be reflected back to the node that made it.
+	initHello, respHello := local, remote
+	initID, respID := localID, remoteID
+	localRole, remoteRole := sessionInitiatorRole, sessionResponderRole
+	if !initiator {
+		initHello, respHello = remote, local
+		initID, respID = remoteID, localID
+		localRole, remoteRole = remoteRole, localRole
+	}
+	transcript := crypto.HashAll(sessionSpecifier, initHello, respHello, initID, respID)
+	sig, err := crypto.SignHash(crypto.HashAll(transcript, localRole), keys.SecretKey)
+	if err != nil {
+		return nil, crypto.PublicKey{}, err
+	}
+	var remoteSig crypto.Signature
+	writeSig := func() error {
+		if err := encoding.WriteObject(conn, sig); err != nil {
+			return fmt.Errorf("failed to write session signature: %v", err)
+		}
+		return nil
+	}
+	readSig := func() error {
+		if err := encoding.ReadObject(conn, &remoteSig, crypto.SignatureSize); err != nil {
+			return fmt.Errorf("failed to read session signature: %v", err)
+		}
+		return nil
+	}
+	if err := sessionExchange(initiator, writeSig, readSig); err != nil {
+		return nil, crypto.PublicKey{}, err
+	}
+	if crypto.VerifyHash(crypto.HashAll(transcript, remoteRole), remote.PublicKey, remoteSig) != nil {
+		return nil, crypto.PublicKey{}, errSessionSignature
+	}
+
+	// Derive a key for each direction from the shared secret.
+	sx, _ := curve.ScalarMult(rx, ry, ephemeral)
+	secret := sx.Bytes()
+	sendKey := crypto.TwofishKey(crypto.HashAll(secret, transcript, localRole))
+	recvKey := crypto.TwofishKey(crypto.HashAll(secret, transcript, remoteRole))
+	return newSessionConn(conn, sendKey, recvKey), remote.PublicKey, nil
+}
+
+// sessionExchange performs one step of the session handshake. The initiator
+// writes its message first and then reads the remote message, while the
+// responder does the opposite.
+func sessionExchange(initiator bool, write, read func() error) error {
+	first, second := write, read
+	if !initiator {
+		first, second = read, write
+	}
+	if err := first(); err != nil {
+		return err
+	}
+	return second()
+}
+
+// sessionConn is a connection that encrypts and authenticates all data sent
+// over it. Data is sent in frames consisting of a 4 byte length prefix
+// followed by the sealed plaintext. Each direction has its own key, and the
+// nonce of each frame is the number of frames sent before it, so frames
+// cannot be replayed, reordered, or dropped without detection.
+type sessionConn struct {
+	net.Conn
+
+	sendMu    sync.Mutex
+	send      cipher.AEAD
+	sendCount uint64
+
+	recvMu    sync.Mutex
+	recv      cipher.AEAD
+	recvCount uint64
+	recvBuf   []byte
+}
+
+// newSessionConn wraps conn in a sessionConn using the provided keys.
+func newSessionConn(conn net.Conn, sendKey, recvKey crypto.TwofishKey) *sessionConn {
+	// NOTE: NewGCM only returns an error if the block size is not 16, which
+	// is never the case for Twofish.
+	send, _ := cipher.NewGCM(sendKey.NewCipher())
+	recv, _ := cipher.NewGCM(recvKey.NewCipher())
+	return &sessionConn{
+		Conn: conn,
+		send: send,
+		recv: recv,
+	}
+}
+
+// sessionNonce returns the nonce of the frame with the provided index.
+func sessionNonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+// Write encrypts p and writes it to the underlying connection.
+func (sc *sessionConn) Write(p []byte) (int, error) {
+	sc.sendMu.Lock()
+	defer sc.sendMu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > maxSessionFrameSize {
+			n = maxSessionFrameSize
+		}
+		frame := make([]byte, 4, 4+n+sc.send.Overhead())
+		frame = sc.send.Seal(frame, sessionNonce(sc.send, sc.sendCount), p[:n], nil)
+		binary.BigEndian.PutUint32(frame[:4], uint32(len(frame)-4))
+		sc.sendCount++
+		if _, err := sc.Conn.Write(frame); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Read reads and decrypts data from the underlying connection.
+func (sc *sessionConn) Read(p []byte) (int, error) {
+	sc.recvMu.Lock()
+	defer sc.recvMu.Unlock()
+
+	if len(sc.recvBuf) == 0 {
+		var prefix [4]byte
+		if _, err := io.ReadFull(sc.Conn, prefix[:]); err != nil {
+			return 0, err
+		}
+		frameLen := binary.BigEndian.Uint32(prefix[:])
+		if frameLen > uint32(maxSessionFrameSize+sc.recv.Overhead()) {
+			return 0, errBadSessionFrame
+		}
+		frame := make([]byte, frameLen)
+		if _, err := io.ReadFull(sc.Conn, frame); err != nil {
+			return 0, err
+		}
+		plaintext, err := sc.recv.Open(frame[:0], sessionNonce(sc.recv, sc.recvCount), frame, nil)
+		if err != nil {
+			return 0, errSessionTampered
+		}
+		sc.recvCount++
+		sc.recvBuf = plaintext
+	}
+	n := copy(p, sc.recvBuf)
+	sc.recvBuf = sc.recvBuf[n:]
+	return n, nil
+}
+
+// loadPinnedKeys loads the public keys pinned to node IDs from disk.
+func (g *Gateway) loadPinnedKeys() error {
+	var pins []pinnedKey
+	err := persist.LoadFile(pinnedKeysMetadata, &pins, filepath.Join(g.persistDir, pinnedKeysFile))
+	if err != nil {
+		return err
+	}
+	for _, pin := range pins {
+		g.pinnedKeys[pin.NodeID] = pin.PublicKey
+	}
+	return nil
+}
+
+// savePinnedKeys stores the public keys pinned to node IDs on disk.
+func (g *Gateway) savePinnedKeys() error {
+	pins := make([]pinnedKey, 0, len(g.pinnedKeys))
+	for id, pk := range g.pinnedKeys {
+		pins = append(pins, pinnedKey{NodeID: id, PublicKey: pk})
+	}
+	return persist.SaveFileSync(pinnedKeysMetadata, pins, filepath.Join(g.persistDir, pinnedKeysFile))
+}
+
+// pinNodeKey checks the node ID and public key presented by a node that the
+// gateway dialed. If the address previously led to a node with a pinned key,
+// the same node must be reached again. The key must match the key pinned for
+// the node ID, and is pinned if none has been pinned yet. A zero key means
+// that the session handshake was skipped.
+func (g *Gateway) pinNodeKey(addr modules.NetAddress, id nodeID, key crypto.PublicKey) error {
+	n, exists := g.nodes[addr]
+	if !exists {
+		return nil
+	}
+	if _, pinned := g.pinnedKeys[n.NodeID]; pinned {
+		if key == (crypto.PublicKey{}) {
+			return errSessionDowngrade
+		}
+		if id != n.NodeID {
+			return errPeerIDMismatch
+		}
+	}
+	if key == (crypto.PublicKey{}) || id == (nodeID{}) {
+		return nil
+	}
+
+	pk, pinned := g.pinnedKeys[id]
+	if pinned && !bytes.Equal(pk.Key, key[:]) {
+		return errPeerKeyMismatch
+	}
+	if !pinned {
+		g.pinnedKeys[id] = types.SiaPublicKey{
+			Algorithm: types.SignatureEd25519,
+			Key:       append([]byte(nil), key[:]...),
+		}
+		if err := g.savePinnedKeys(); err != nil {
+			return err
+		}
+	}
+	if n.NodeID != id {
+		n.NodeID = id
+		return g.save()
+	}
+	return nil
+}
+
+// unpinNodeKey drops the key pinned for the node ID if no address in the
+// node list leads to the node anymore.
+func (g *Gateway) unpinNodeKey(id nodeID) error {
+	if _, pinned := g.pinnedKeys[id]; !pinned {
+		return nil
+	}
+	for _, n := range g.nodes {
+		if n.NodeID == id {
+			return nil
+		}
+	}
+	delete(g.pinnedKeys, id)
+	return g.savePinnedKeys()
+}
+
+// UnpinNode clears the public key pinned for the node that the address led
+// to, so that the next key presented by the node is pinned instead. This is
+// needed when a node has legitimately changed its key pair, or when the
+// address now belongs to a different node.
+func (g *Gateway) UnpinNode(addr modules.NetAddress) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n, exists := g.nodes[addr]
+	if !exists {
+		return errNodeNotFound
+	}
+	if _, pinned := g.pinnedKeys[n.NodeID]; !pinned {
+		return errNodeNotPinned
+	}
+	delete(g.pinnedKeys, n.NodeID)
+	return g.savePinnedKeys()
+}
@@ -0,0 +1,252 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// newTestSessionKeys returns a random key pair for use in a session
+// handshake.
+func newTestSessionKeys(t *testing.T) sessionKeys {
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sessionKeys{PublicKey: pk, SecretKey: sk}
+}
+
+// sessionPipe performs the session handshake over an in-memory connection,
+// returning both ends of the encrypted connection.
+func sessionPipe(t *testing.T, keys1, keys2 sessionKeys) (net.Conn, net.Conn) {
+	c1, c2 := net.Pipe()
+	type result struct {
+		conn net.Conn
+		key  crypto.PublicKey
+		err  error
+	}
+	results := make(chan result)
+	go func() {
+		conn, key, err := sessionHandshake(c2, keys2, false, nodeID{2}, nodeID{1})
+		results <- result{conn, key, err}
+	}()
+	conn1, key1, err := sessionHandshake(c1, keys1, true, nodeID{1}, nodeID{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-results
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if key1 != keys2.PublicKey || r.key != keys1.PublicKey {
+		t.Fatal("handshake did not return the remote public keys")
+	}
+	return conn1, r.conn
+}
+
+// TestSessionHandshake checks that data sent over a session is delivered
+// intact, including writes that span several frames.
+func TestSessionHandshake(t *testing.T) {
+	conn1, conn2 := sessionPipe(t, newTestSessionKeys(t), newTestSessionKeys(t))
+	defer conn1.Close()
+	defer conn2.Close()
+
+	data, err := crypto.RandBytes(maxSessionFrameSize*2 + 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go conn1.Write(data)
+	received := make([]byte, len(data))
+	if _, err := io.ReadFull(conn2, received); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Fatal("data was corrupted by the session")
+	}
+
+	// Data should also flow in the other direction.
+	go conn2.Write([]byte("reply"))
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(conn1, reply); err != nil || string(reply) != "reply" {
+		t.Fatal("reply was not received:", string(reply), err)
+	}
+}
+
+// tamperConn flips a bit in the last byte of every write.
+type tamperConn struct {
+	net.Conn
+}
+
+func (tc tamperConn) Write(p []byte) (int, error) {
+	b := append([]byte(nil), p...)
+	b[len(b)-1] ^= 1
+	return tc.Conn.Write(b)
+}
+
+// TestSessionTampering checks that modified frames are rejected.
+func TestSessionTampering(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	key, err := crypto.GenerateTwofishKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := newSessionConn(tamperConn{c1}, key, key)
+	receiver := newSessionConn(c2, key, key)
+
+	go sender.Write([]byte("hello"))
+	if _, err := receiver.Read(make([]byte, 5)); err != errSessionTampered {
+		t.Fatal("expected errSessionTampered, got", err)
+	}
+}
+
+// TestSessionKeyPinning checks that the gateway pins the key of the nodes it
+// dials to their node ID, and refuses to connect to a node that presents a
+// different key.
+func TestSessionKeyPinning(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g1 := newTestingGateway("TestSessionKeyPinning1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestSessionKeyPinning2", t)
+	defer g2.Close()
+
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	g1.mu.RLock()
+	id := g1.nodes[g2.Address()].NodeID
+	pinned := g1.pinnedKeys[g2.id]
+	g1.mu.RUnlock()
+	if id != g2.id {
+		t.Fatal("node ID of the dialed node was not recorded:", id)
+	}
+	if !bytes.Equal(pinned.Key, g2.keys.PublicKey[:]) {
+		t.Fatal("key of the dialed node was not pinned:", pinned)
+	}
+
+	// Pretend that a different key was pinned for g2's node ID.
+	if err := g1.Disconnect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	other := newTestSessionKeys(t)
+	g1.mu.Lock()
+	g1.pinnedKeys[g2.id] = types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       other.PublicKey[:],
+	}
+	g1.mu.Unlock()
+	if err := g1.Connect(g2.Address()); err != errPeerKeyMismatch {
+		t.Fatal("expected errPeerKeyMismatch, got", err)
+	}
+
+	// Skipping the session handshake is not allowed once a key is pinned,
+	// and neither is reaching a different node through the address.
+	g1.mu.Lock()
+	err := g1.pinNodeKey(g2.Address(), nodeID{}, crypto.PublicKey{})
+	g1.mu.Unlock()
+	if err != errSessionDowngrade {
+		t.Fatal("expected errSessionDowngrade, got", err)
+	}
+	otherID, err := newNodeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g1.mu.Lock()
+	err = g1.pinNodeKey(g2.Address(), otherID, other.PublicKey)
+	g1.mu.Unlock()
+	if err != errPeerIDMismatch {
+		t.Fatal("expected errPeerIDMismatch, got", err)
+	}
+
+	// Once the pin is cleared, the key that the node presents is pinned
+	// again.
+	if err := g1.UnpinNode(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.UnpinNode(g2.Address()); err != errNodeNotPinned {
+		t.Fatal("expected errNodeNotPinned, got", err)
+	}
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	g1.mu.RLock()
+	pinned = g1.pinnedKeys[g2.id]
+	g1.mu.RUnlock()
+	if !bytes.Equal(pinned.Key, g2.keys.PublicKey[:]) {
+		t.Fatal("key of the dialed node was not pinned again:", pinned)
+	}
+}
+
+// TestSessionKeyPinsPurged checks that the key pinned for a node is dropped
+// once the last address that leads to the node is removed from the node list.
+func TestSessionKeyPinsPurged(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestSessionKeyPinsPurged", t)
+	defer g.Close()
+
+	keys := newTestSessionKeys(t)
+	id, err := newNodeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs := []modules.NetAddress{"111.111.111.111:1111", "[1111::1111]:1111"}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, addr := range addrs {
+		if err := g.addNode(addr); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.pinNodeKey(addr, id, keys.PublicKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(g.pinnedKeys) != 1 {
+		t.Fatal("expected a single pin for both addresses, got", len(g.pinnedKeys))
+	}
+
+	if err := g.removeNode(addrs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned := g.pinnedKeys[id]; !pinned {
+		t.Fatal("pin was dropped while another address still leads to the node")
+	}
+	if err := g.removeNode(addrs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned := g.pinnedKeys[id]; pinned {
+		t.Fatal("pin was not dropped after the node was purged")
+	}
+}
+
+// TestSessionKeysPersist checks that the gateway keeps its key pair across
+// restarts.
+func TestSessionKeysPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestSessionKeysPersist", t)
+	keys := g.keys
+	if keys.PublicKey == (crypto.PublicKey{}) {
+		t.Fatal("gateway was not given a key pair")
+	}
+	g.Close()
+
+	g2, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	if g2.keys != keys {
+		t.Fatal("key pair changed after restart")
+	}
+}
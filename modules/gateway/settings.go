@@ -0,0 +1,191 @@
+package gateway
+
+// settings.go enforces the connection limits of the gateway. The limits allow
+// low-resource nodes to cap the number of peers they maintain, and the
+// per-subnet limit makes it harder for a single operator to take up all of
+// the gateway's peer slots. Local peers are exempt from all limits, so that a
+// node can always be reached from its own network.
+
+import (
+	"errors"
+	"net"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	errInboundDisabled          = errors.New("gateway is not accepting inbound peers")
+	errInvalidMaxInboundPeers   = errors.New("max inbound peers must be between zero and max peers")
+	errInvalidMaxPeers          = errors.New("max peers must be greater than zero")
+	errInvalidMaxPeersPerSubnet = errors.New("max peers per subnet must be greater than zero")
+	errNegativeBanDuration      = errors.New("ban duration cannot be negative")
+	errPeerLimit                = errors.New("gateway has reached its peer limit")
+	errSubnetFull               = errors.New("gateway has reached its peer limit for that subnet")
+)
+
+// subnet returns the subnet of the provided address, which is the /24 for
+// IPv4 addresses and the /64 for IPv6 addresses. If the host is not an IP
+// address, the host itself is returned.
+func subnet(addr modules.NetAddress) string {
+	ip := net.ParseIP(addr.Host())
+	if ip == nil {
+		return addr.Host()
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
+
+// validateSettings returns an error if the provided settings are not usable.
+func validateSettings(settings modules.GatewaySettings) error {
+	if settings.MaxPeers <= 0 {
+		return errInvalidMaxPeers
+	}
+	if settings.MaxInboundPeers < 0 || settings.MaxInboundPeers > settings.MaxPeers {
+		return errInvalidMaxInboundPeers
+	}
+	if settings.MaxPeersPerSubnet <= 0 {
+		return errInvalidMaxPeersPerSubnet
+	}
+	if settings.BanDuration < 0 {
+		return errNegativeBanDuration
+	}
+	for name := range settings.BandwidthLimits {
+		if name == "" {
+			return errEmptyRPCName
+		}
+	}
+	return nil
+}
+
+// copyBandwidthLimits returns a copy of the provided bandwidth limits, so that
+// the limits of the gateway cannot be modified by callers. Limits of zero are
+// dropped, as they mean that the RPC is not limited.
+func copyBandwidthLimits(limits map[string]uint64) map[string]uint64 {
+	if len(limits) == 0 {
+		return nil
+	}
+	c := make(map[string]uint64, len(limits))
+	for name, limit := range limits {
+		if limit != 0 {
+			c[name] = limit
+		}
+	}
+	return c
+}
+
+// peerCounts returns the number of non-local peers, the number of non-local
+// inbound peers, and the number of non-local peers that share a subnet with
+// the provided address.
+func (g *Gateway) peerCounts(addr modules.NetAddress) (total, inbound, sameSubnet int) {
+	addrSubnet := subnet(addr)
+	for peerAddr, p := range g.peers {
+		if peerAddr.IsLocal() {
+			continue
+		}
+		total++
+		if p.Inbound {
+			inbound++
+		}
+		if subnet(peerAddr) == addrSubnet {
+			sameSubnet++
+		}
+	}
+	return total, inbound, sameSubnet
+}
+
+// checkPeerLimits returns an error if a new peer with the provided address
+// cannot be added without exceeding the gateway's limits, even after kicking
+// an inbound peer to make room.
+func (g *Gateway) checkPeerLimits(addr modules.NetAddress, inbound bool) error {
+	if addr.IsLocal() {
+		return nil
+	}
+	if inbound && g.settings.MaxInboundPeers == 0 {
+		return errInboundDisabled
+	}
+	total, numInbound, sameSubnet := g.peerCounts(addr)
+	if sameSubnet >= g.settings.MaxPeersPerSubnet {
+		return errSubnetFull
+	}
+	if total >= g.settings.MaxPeers && numInbound == 0 {
+		return errPeerLimit
+	}
+	return nil
+}
+
+// trimPeers disconnects from non-local peers until the gateway is within its
+// limits. The lowest scoring inbound peers are disconnected first; outbound
+// peers are only disconnected if there are no inbound peers left.
+func (g *Gateway) trimPeers() {
+	var inbound, outbound []*peer
+	for addr, p := range g.peers {
+		if addr.IsLocal() {
+			continue
+		}
+		if p.Inbound {
+			inbound = append(inbound, p)
+		} else {
+			outbound = append(outbound, p)
+		}
+	}
+	sort.Sort(byScore(inbound))
+	sort.Sort(byScore(outbound))
+
+	var kick []*peer
+	for len(inbound) > g.settings.MaxInboundPeers {
+		kick = append(kick, inbound[0])
+		inbound = inbound[1:]
+	}
+	for len(inbound)+len(outbound) > g.settings.MaxPeers {
+		if len(inbound) > 0 {
+			kick = append(kick, inbound[0])
+			inbound = inbound[1:]
+		} else {
+			kick = append(kick, outbound[0])
+			outbound = outbound[1:]
+		}
+	}
+	for _, p := range kick {
+		delete(g.peers, p.NetAddress)
+		if err := p.sess.Close(); err != nil {
+			g.log.Debugf("WARN: error disconnecting from peer %q: %v", p.NetAddress, err)
+		}
+		g.log.Printf("INFO: disconnected from %v to meet the peer limits\n", p.NetAddress)
+	}
+}
+
+// Settings returns the Gateway's settings.
+func (g *Gateway) Settings() modules.GatewaySettings {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	settings := g.settings
+	settings.BandwidthLimits = copyBandwidthLimits(g.settings.BandwidthLimits)
+	return settings
+}
+
+// SetSettings changes the Gateway's settings. If the limits are lowered, the
+// lowest scoring peers are disconnected until the limits are met. Bans that
+// are already in place are not affected.
+func (g *Gateway) SetSettings(settings modules.GatewaySettings) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+	if err := validateSettings(settings); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.settings = settings
+	g.settings.BandwidthLimits = copyBandwidthLimits(settings.BandwidthLimits)
+	g.trimPeers()
+	g.applyBandwidthLimits()
+	if err := g.saveSettingsSync(); err != nil {
+		return errors.New("settings updated, but failed saving to disk: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/muxado"
+)
+
+// TestSubnet checks that addresses are grouped into /24 subnets for IPv4 and
+// /64 subnets for IPv6.
+func TestSubnet(t *testing.T) {
+	tests := []struct {
+		a, b   modules.NetAddress
+		shared bool
+	}{
+		{"1.2.3.4:1", "1.2.3.5:2", true},
+		{"1.2.3.4:1", "1.2.4.4:1", false},
+		{"[2001:db8::1]:1", "[2001:db8::2]:1", true},
+		{"[2001:db8::1]:1", "[2001:db8:0:1::1]:1", false},
+		{"1.2.3.4:1", "[::ffff:1.2.3.5]:1", true},
+	}
+	for _, test := range tests {
+		if (subnet(test.a) == subnet(test.b)) != test.shared {
+			t.Errorf("expected subnet(%v) == subnet(%v) to be %v", test.a, test.b, test.shared)
+		}
+	}
+}
+
+// TestPeerLimits checks that acceptPeer enforces the inbound and per-subnet
+// limits, and that local peers are exempt from them.
+func TestPeerLimits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestPeerLimits", t)
+	defer g.Close()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.settings.MaxPeers = 4
+	g.settings.MaxInboundPeers = 2
+	g.settings.MaxPeersPerSubnet = 2
+
+	newPeer := func(addr modules.NetAddress, inbound bool) *peer {
+		return &peer{
+			Peer: modules.Peer{
+				NetAddress: addr,
+				Inbound:    inbound,
+			},
+			sess: muxado.Client(new(dummyConn)),
+		}
+	}
+
+	// Fill up the subnet 1.1.1.0/24.
+	for _, addr := range []modules.NetAddress{"1.1.1.1:1", "1.1.1.2:1"} {
+		if err := g.acceptPeer(newPeer(addr, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.acceptPeer(newPeer("1.1.1.3:1", false)); err != errSubnetFull {
+		t.Fatal("expected errSubnetFull, got", err)
+	}
+	if err := g.checkPeerLimits("1.1.1.3:1", true); err != errSubnetFull {
+		t.Fatal("expected errSubnetFull, got", err)
+	}
+
+	// Local peers are not limited.
+	for _, addr := range []modules.NetAddress{"127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:3"} {
+		if err := g.acceptPeer(newPeer(addr, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The inbound limit should be enforced by kicking an existing inbound
+	// peer.
+	for _, addr := range []modules.NetAddress{"2.2.2.2:1", "3.3.3.3:1", "4.4.4.4:1"} {
+		if err := g.acceptPeer(newPeer(addr, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	total, inbound, _ := g.peerCounts("5.5.5.5:1")
+	if total != 4 || inbound != 2 {
+		t.Fatalf("expected 4 non-local peers of which 2 are inbound, got %v and %v", total, inbound)
+	}
+	if len(g.peers) != 7 {
+		t.Fatal("expected the local peers to be kept, have", len(g.peers), "peers")
+	}
+
+	// Once there are no inbound peers left to kick, new peers are rejected.
+	g.settings.MaxInboundPeers = 0
+	g.trimPeers()
+	if err := g.checkPeerLimits("5.5.5.5:1", true); err != errInboundDisabled {
+		t.Fatal("expected errInboundDisabled, got", err)
+	}
+	g.settings.MaxPeers = 2
+	if err := g.acceptPeer(newPeer("5.5.5.5:1", false)); err != errPeerLimit {
+		t.Fatal("expected errPeerLimit, got", err)
+	}
+}
+
+// TestSetSettings checks that SetSettings validates the settings, disconnects
+// peers to meet lowered limits, and persists the settings.
+func TestSetSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestSetSettings", t)
+
+	settings := g.Settings()
+	invalid := []struct {
+		modify func(*modules.GatewaySettings)
+		err    error
+	}{
+		{func(s *modules.GatewaySettings) { s.MaxPeers = 0 }, errInvalidMaxPeers},
+		{func(s *modules.GatewaySettings) { s.MaxInboundPeers = -1 }, errInvalidMaxInboundPeers},
+		{func(s *modules.GatewaySettings) { s.MaxInboundPeers = s.MaxPeers + 1 }, errInvalidMaxInboundPeers},
+		{func(s *modules.GatewaySettings) { s.MaxPeersPerSubnet = 0 }, errInvalidMaxPeersPerSubnet},
+		{func(s *modules.GatewaySettings) { s.BanDuration = -time.Second }, errNegativeBanDuration},
+	}
+	for _, test := range invalid {
+		s := settings
+		test.modify(&s)
+		if err := g.SetSettings(s); err != test.err {
+			t.Errorf("expected %v, got %v", test.err, err)
+		}
+	}
+
+	// Add a few peers, then lower the limits. The lowest scoring inbound
+	// peer should be disconnected first.
+	g.mu.Lock()
+	for _, addr := range []modules.NetAddress{"1.1.1.1:1", "2.2.2.2:1", "3.3.3.3:1"} {
+		p := &peer{
+			Peer: modules.Peer{
+				NetAddress: addr,
+				Inbound:    addr != "1.1.1.1:1",
+			},
+			sess: muxado.Client(new(dummyConn)),
+		}
+		if addr == "3.3.3.3:1" {
+			p.score.relays = 1
+		}
+		g.addPeer(p)
+	}
+	g.mu.Unlock()
+	settings = modules.GatewaySettings{
+		MaxPeers:          2,
+		MaxInboundPeers:   1,
+		MaxPeersPerSubnet: 1,
+		BanDuration:       time.Hour,
+		BandwidthLimits:   map[string]uint64{"ShareNodes": 1000},
+	}
+	if err := g.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.Settings(), settings) {
+		t.Fatal("settings were not updated:", g.Settings())
+	}
+	g.mu.Lock()
+	_, exists := g.peers["2.2.2.2:1"]
+	if exists || len(g.peers) != 2 {
+		t.Fatal("expected the lowest scoring inbound peer to be disconnected, have", g.peers)
+	}
+	g.ban("4.4.4.4:1")
+	expiry := g.bans["4.4.4.4"]
+	g.mu.Unlock()
+	if expiry.Before(time.Now().Add(59 * time.Minute)) {
+		t.Fatal("new ban did not use the updated ban duration:", expiry)
+	}
+
+	// The settings should persist across restarts.
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	g2, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	if !reflect.DeepEqual(g2.Settings(), settings) {
+		t.Fatal("settings were not loaded:", g2.Settings())
+	}
+}
@@ -1,7 +1,15 @@
 package gateway
 
+// upnp.go takes care of making the gateway reachable from the internet. The
+// gateway asks the router to forward its port, first using UPnP and then
+// using NAT-PMP, and renews the mapping periodically in case the router
+// forgets it. After each renewal, the gateway tests whether it can actually
+// be reached on its external address, so that users can tell whether their
+// node is connectable.
+
 import (
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
@@ -11,11 +19,20 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/go-upnp"
+	natgateway "github.com/jackpal/gateway"
+	natpmp "github.com/jackpal/go-nat-pmp"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
+const (
+	// natMethodUPnP and natMethodNATPMP are the values of NATStatus.Method
+	// for ports that were mapped using UPnP and NAT-PMP respectively.
+	natMethodUPnP   = "upnp"
+	natMethodNATPMP = "nat-pmp"
+)
+
 // myExternalIP discovers the gateway's external IP by querying a centralized
 // service, http://myexternalip.com.
 func myExternalIP() (string, error) {
@@ -41,97 +58,207 @@ func myExternalIP() (string, error) {
 	return strings.TrimSpace(string(buf)), nil
 }
 
-// threadedLearnHostname discovers the external IP of the Gateway. Once the IP
-// has been discovered, it registers the ShareNodes RPC to be called on new
-// connections, advertising the IP to other nodes.
-func (g *Gateway) threadedLearnHostname() {
-	if err := g.threads.Add(); err != nil {
-		return
+// forwardUPnP maps the port on the router using UPnP, returning the external
+// IP reported by the router.
+func forwardUPnP(port uint16) (string, error) {
+	d, err := upnp.Discover()
+	if err != nil {
+		return "", fmt.Errorf("no UPnP-enabled devices found: %v", err)
 	}
-	defer g.threads.Done()
-
-	if build.Release == "testing" {
-		return
+	if err := d.Forward(port, "Sia RPC"); err != nil {
+		return "", err
 	}
+	return d.ExternalIP()
+}
 
-	// try UPnP first, then fallback to myexternalip.com
-	var host string
-	d, err := upnp.Discover()
-	if err == nil {
-		host, err = d.ExternalIP()
+// forwardNATPMP maps the port on the router using NAT-PMP for the provided
+// lifetime, returning the external IP reported by the router.
+func forwardNATPMP(port uint16, lifetime time.Duration) (string, error) {
+	gatewayIP, err := natgateway.DiscoverGateway()
+	if err != nil {
+		return "", fmt.Errorf("could not find the router: %v", err)
 	}
+	client := natpmp.NewClient(gatewayIP)
+	res, err := client.AddPortMapping("tcp", int(port), int(port), int(lifetime/time.Second))
 	if err != nil {
-		host, err = myExternalIP()
+		return "", err
+	}
+	// Peers are told which port to dial back on, so the router has to use
+	// the same port externally.
+	if res.MappedExternalPort != port {
+		client.AddPortMapping("tcp", int(port), 0, 0)
+		return "", fmt.Errorf("router mapped the port to %v instead", res.MappedExternalPort)
 	}
+	ext, err := client.GetExternalAddress()
 	if err != nil {
-		g.log.Println("WARN: failed to discover external IP:", err)
-		return
+		return "", err
 	}
+	return net.IP(ext.ExternalIPAddress[:]).String(), nil
+}
 
+// managedForwardPort maps the gateway's port on the router, trying UPnP first
+// and NAT-PMP second. The method that worked and the external IP reported by
+// the router are returned. If neither method works, the method is empty.
+func (g *Gateway) managedForwardPort() (method, host string) {
 	g.mu.RLock()
-	addr := modules.NetAddress(net.JoinHostPort(host, g.port))
+	portStr := g.port
 	g.mu.RUnlock()
-	if err := addr.IsValid(); err != nil {
-		g.log.Printf("WARN: discovered hostname %q is invalid: %v", addr, err)
-		return
-	}
+	portInt, _ := strconv.Atoi(portStr)
+	port := uint16(portInt)
 
-	g.mu.Lock()
-	g.myAddr = addr
-	g.mu.Unlock()
-
-	g.log.Println("INFO: our address is", addr)
+	host, upnpErr := forwardUPnP(port)
+	if upnpErr == nil {
+		return natMethodUPnP, host
+	}
+	host, pmpErr := forwardNATPMP(port, 2*natRenewInterval)
+	if pmpErr == nil {
+		return natMethodNATPMP, host
+	}
+	g.log.Printf("WARN: could not automatically forward port %s: UPnP: %v; NAT-PMP: %v", portStr, upnpErr, pmpErr)
+	return "", ""
 }
 
-// threadedForwardPort adds a port mapping to the router.
-func (g *Gateway) threadedForwardPort(port string) {
-	if err := g.threads.Add(); err != nil {
+// managedClearPort removes the port mapping from the router, if one was made.
+func (g *Gateway) managedClearPort() {
+	if build.Release == "testing" {
 		return
 	}
-	defer g.threads.Done()
 
-	if build.Release == "testing" {
+	g.mu.RLock()
+	method := g.natStatus.Method
+	portStr := g.port
+	g.mu.RUnlock()
+	portInt, _ := strconv.Atoi(portStr)
+
+	var err error
+	switch method {
+	case natMethodUPnP:
+		var d *upnp.IGD
+		d, err = upnp.Discover()
+		if err == nil {
+			err = d.Clear(uint16(portInt))
+		}
+	case natMethodNATPMP:
+		var gatewayIP net.IP
+		gatewayIP, err = natgateway.DiscoverGateway()
+		if err == nil {
+			_, err = natpmp.NewClient(gatewayIP).AddPortMapping("tcp", portInt, 0, 0)
+		}
+	default:
 		return
 	}
-
-	d, err := upnp.Discover()
 	if err != nil {
-		g.log.Printf("WARN: could not automatically forward port %s: no UPnP-enabled devices found: %v", port, err)
+		g.log.Printf("WARN: could not automatically unforward port %s: %v", portStr, err)
 		return
 	}
 
-	portInt, _ := strconv.Atoi(port)
-	err = d.Forward(uint16(portInt), "Sia RPC")
-	if err != nil {
-		g.log.Printf("WARN: could not automatically forward port %s: %v", port, err)
-		return
+	g.log.Println("INFO: successfully unforwarded port", portStr)
+}
+
+// managedLearnHostname sets the gateway's address using the provided external
+// IP. If the IP is empty, it is looked up using myexternalip.com instead. The
+// IP that was used is returned.
+func (g *Gateway) managedLearnHostname(host string) string {
+	if host == "" {
+		var err error
+		host, err = myExternalIP()
+		if err != nil {
+			g.log.Println("WARN: failed to discover external IP:", err)
+			return ""
+		}
 	}
 
-	g.log.Println("INFO: successfully forwarded port", port)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	addr := modules.NetAddress(net.JoinHostPort(host, g.port))
+	if err := addr.IsValid(); err != nil {
+		g.log.Printf("WARN: discovered hostname %q is invalid: %v", addr, err)
+		return ""
+	}
+	if addr != g.myAddr {
+		g.myAddr = addr
+		g.log.Println("INFO: our address is", addr)
+	}
+	return host
+}
+
+// managedCheckReachability tests whether the gateway can be reached on its
+// external address. An inbound connection from a non-local peer proves that
+// the gateway is reachable. Otherwise, the gateway tries to dial its own
+// external address, which succeeds if the port is open and the router
+// supports connecting to its own external IP.
+func (g *Gateway) managedCheckReachability() bool {
+	g.mu.RLock()
+	addr := g.myAddr
+	for peerAddr, p := range g.peers {
+		if p.Inbound && !peerAddr.IsLocal() {
+			g.mu.RUnlock()
+			return true
+		}
+	}
+	g.mu.RUnlock()
 
-	// Establish port-clearing at shutdown.
-	g.threads.AfterStop(func() {
-		g.managedClearPort(port)
-	})
+	// A local address means that the external address is not known yet.
+	if addr.IsLocal() {
+		return false
+	}
+	conn, err := g.dial(addr)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
 }
 
-// managedClearPort removes a port mapping from the router.
-func (g *Gateway) managedClearPort(port string) {
+// permanentNATManager keeps the gateway's port mapped on the router, renewing
+// the mapping every natRenewInterval, and keeps the gateway's address and
+// reachability status up to date.
+func (g *Gateway) permanentNATManager(closeChan chan struct{}) {
+	defer close(closeChan)
+
 	if build.Release == "testing" {
 		return
 	}
-
-	d, err := upnp.Discover()
-	if err != nil {
+	// Discovering the external IP would bypass the proxy, and sharing it
+	// with peers would defeat the purpose of using one.
+	if proxy := modules.Proxy(); proxy != "" {
+		g.log.Println("INFO: outbound connections go through proxy", proxy, "- not discovering the external IP")
 		return
 	}
 
-	portInt, _ := strconv.Atoi(port)
-	err = d.Clear(uint16(portInt))
-	if err != nil {
-		g.log.Printf("WARN: could not automatically unforward port %s: %v", port, err)
-		return
+	for {
+		method, host := g.managedForwardPort()
+		g.mu.RLock()
+		previousMethod := g.natStatus.Method
+		g.mu.RUnlock()
+		if method != "" && method != previousMethod {
+			g.log.Println("INFO: successfully forwarded port using", method)
+		}
+		host = g.managedLearnHostname(host)
+		reachable := g.managedCheckReachability()
+
+		g.mu.Lock()
+		if !reachable && g.natStatus.Reachable {
+			g.log.Println("WARN: gateway is no longer reachable on", g.myAddr)
+		}
+		g.natStatus = modules.NATStatus{
+			Method:      method,
+			ExternalIP:  host,
+			Reachable:   reachable,
+			LastChecked: time.Now(),
+		}
+		g.mu.Unlock()
+
+		if !g.managedSleep(natRenewInterval) {
+			return
+		}
 	}
+}
 
-	g.log.Println("INFO: successfully unforwarded port", port)
+// NATStatus returns how the gateway's port was mapped on the router, and
+// whether the gateway is reachable by other nodes.
+func (g *Gateway) NATStatus() modules.NATStatus {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.natStatus
 }
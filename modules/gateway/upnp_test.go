@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/muxado"
+)
+
+// TestCheckReachability checks that the gateway is only considered reachable
+// once its external address is known and it can be dialed, or once a
+// non-local peer has connected to it.
+func TestCheckReachability(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway("TestCheckReachability", t)
+	defer g.Close()
+
+	if status := g.NATStatus(); !status.LastChecked.IsZero() || status.Method != "" {
+		t.Fatal("NAT status should be empty before the first check:", status)
+	}
+
+	// The testing gateway listens on a local address, which means that its
+	// external address is unknown.
+	if g.managedCheckReachability() {
+		t.Fatal("gateway with a local address should not be reachable")
+	}
+
+	// Inbound local peers are not proof of reachability.
+	g.mu.Lock()
+	g.addPeer(&peer{
+		Peer: modules.Peer{
+			NetAddress: "127.0.0.1:1234",
+			Inbound:    true,
+		},
+		sess: muxado.Client(new(dummyConn)),
+	})
+	g.mu.Unlock()
+	if g.managedCheckReachability() {
+		t.Fatal("local inbound peer should not make the gateway reachable")
+	}
+
+	// Inbound non-local peers are.
+	g.mu.Lock()
+	g.addPeer(&peer{
+		Peer: modules.Peer{
+			NetAddress: "1.2.3.4:1234",
+			Inbound:    true,
+		},
+		sess: muxado.Client(new(dummyConn)),
+	})
+	g.mu.Unlock()
+	if !g.managedCheckReachability() {
+		t.Fatal("non-local inbound peer should make the gateway reachable")
+	}
+}
@@ -0,0 +1,66 @@
+package modules
+
+import (
+	"errors"
+	"strings"
+)
+
+// GeoIPFilename is the name of the file, inside the hostdb directory, that
+// maps IP address ranges to countries. Each line holds the first and last
+// address of a range and the ISO 3166-1 alpha-2 code of its country,
+// separated by commas, such as "1.0.0.0,1.0.0.255,AU". Lines starting with
+// '#' are ignored. Without the file, the countries of hosts are unknown.
+const GeoIPFilename = "geoip.csv"
+
+var (
+	// ErrUnknownRegion is returned when a region constraint is neither a
+	// known region nor a country code.
+	ErrUnknownRegion = errors.New("region must be a known region or a two-letter country code")
+
+	// Regions are the groups of countries that an upload can be restricted
+	// to by name. Uploads can also be restricted to individual countries by
+	// their ISO 3166-1 alpha-2 code.
+	Regions = map[string][]string{
+		// The member states of the European Union.
+		"EU": euCountries,
+
+		// The European Economic Area: the European Union, Iceland,
+		// Liechtenstein, and Norway.
+		"EEA": append(append([]string(nil), euCountries...), "IS", "LI", "NO"),
+	}
+
+	euCountries = []string{
+		"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+		"IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO", "SE", "SI", "SK",
+	}
+)
+
+// IsCountryCode returns true if s has the form of an ISO 3166-1 alpha-2
+// country code: two uppercase letters.
+func IsCountryCode(s string) bool {
+	return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
+}
+
+// ExpandRegions returns the set of countries that are permitted by a list of
+// region names and country codes. Region names and country codes are not case
+// sensitive. A nil set is returned if the list is empty, meaning that every
+// host is permitted.
+func ExpandRegions(regions []string) (map[string]bool, error) {
+	if len(regions) == 0 {
+		return nil, nil
+	}
+	countries := make(map[string]bool)
+	for _, region := range regions {
+		region = strings.ToUpper(strings.TrimSpace(region))
+		if members, ok := Regions[region]; ok {
+			for _, c := range members {
+				countries[c] = true
+			}
+		} else if IsCountryCode(region) {
+			countries[region] = true
+		} else {
+			return nil, ErrUnknownRegion
+		}
+	}
+	return countries, nil
+}
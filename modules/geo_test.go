@@ -0,0 +1,39 @@
+package modules
+
+import (
+	"testing"
+)
+
+// TestExpandRegions checks that region names and country codes are expanded
+// into sets of countries.
+func TestExpandRegions(t *testing.T) {
+	countries, err := ExpandRegions(nil)
+	if err != nil || countries != nil {
+		t.Fatal("expected a nil set for no regions, got", countries, err)
+	}
+
+	countries, err = ExpandRegions([]string{"eu", " ch "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(countries) != len(Regions["EU"])+1 {
+		t.Fatal("wrong number of countries:", len(countries))
+	}
+	for _, c := range []string{"DE", "FR", "CH"} {
+		if !countries[c] {
+			t.Error("expected", c, "to be permitted")
+		}
+	}
+	if countries["US"] || countries["NO"] {
+		t.Error("countries outside of the regions are permitted")
+	}
+	if eea, _ := ExpandRegions([]string{"EEA"}); !eea["NO"] || !eea["DE"] {
+		t.Error("EEA does not contain Norway and Germany")
+	}
+
+	for _, bad := range []string{"Europe", "C", "C1", ""} {
+		if _, err := ExpandRegions([]string{bad}); err != ErrUnknownRegion {
+			t.Errorf("expected ErrUnknownRegion for %q, got %v", bad, err)
+		}
+	}
+}
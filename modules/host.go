@@ -1,12 +1,23 @@
 package modules
 
 import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
 
 const (
 	// HostDir names the directory that contains the host persistence.
 	HostDir = "host"
+
+	// HostAlertWarning is the severity of an alert about a problem that may
+	// cost the host money if it is not dealt with.
+	HostAlertWarning = "warning"
+
+	// HostAlertCritical is the severity of an alert about a problem that is
+	// costing the host money, or that stops the host from working.
+	HostAlertCritical = "critical"
 )
 
 var (
@@ -79,16 +90,102 @@ type (
 		UnrecognizedCalls uint64 `json:"unrecognizedcalls"`
 	}
 
+	// HostAudit is the result of a self-audit of the host's storage. Each
+	// checked sector is read from disk, and a storage proof of a random
+	// segment of the sector is built and verified against the Merkle root of
+	// its contract, as the network would verify it. Only the unresolved
+	// storage obligations that still need a storage proof are audited.
+	HostAudit struct {
+		Obligations    uint64             `json:"obligations"`
+		Sectors        uint64             `json:"sectors"`
+		SectorsChecked uint64             `json:"sectorschecked"`
+		Failures       []HostAuditFailure `json:"failures"`
+	}
+
+	// HostAuditFailure is a sector that would fail a storage proof, along
+	// with the deadline of the storage proof that is at risk.
+	HostAuditFailure struct {
+		ObligationID  types.FileContractID `json:"obligationid"`
+		SectorIndex   uint64               `json:"sectorindex"`
+		SectorRoot    crypto.Hash          `json:"sectorroot"`
+		ProofDeadline types.BlockHeight    `json:"proofdeadline"`
+		Error         string               `json:"error"`
+	}
+
+	// HostError is an error that the host encountered while handling an RPC,
+	// along with the time that it occurred.
+	HostError struct {
+		Message string    `json:"message"`
+		Time    time.Time `json:"time"`
+	}
+
+	// HostObligationSummary reports the number of storage obligations of the
+	// host with each status, along with the storage proof deadlines of the
+	// unresolved obligations that still need a storage proof.
+	HostObligationSummary struct {
+		Unresolved uint64 `json:"unresolved"`
+		Rejected   uint64 `json:"rejected"`
+		Succeeded  uint64 `json:"succeeded"`
+		Failed     uint64 `json:"failed"`
+
+		UpcomingProofs []HostProofDeadline `json:"upcomingproofs"`
+	}
+
+	// HostProofDeadline is the storage proof window of a storage obligation.
+	// The host must submit a storage proof between WindowStart and WindowEnd.
+	HostProofDeadline struct {
+		ObligationID types.FileContractID `json:"obligationid"`
+		WindowStart  types.BlockHeight    `json:"windowstart"`
+		WindowEnd    types.BlockHeight    `json:"windowend"`
+	}
+
+	// HostReachability reports whether renters appear to be able to reach
+	// the host. The host is considered reachable if it has been announced and
+	// has recently received an incoming connection.
+	HostReachability struct {
+		Announced             bool       `json:"announced"`
+		LastInboundConnection time.Time  `json:"lastinboundconnection"`
+		NetAddress            NetAddress `json:"netaddress"`
+		Reachable             bool       `json:"reachable"`
+	}
+
+	// HostAlert is an operational problem of the host that needs the
+	// attention of its operator. Since is the time at which the problem was
+	// first noticed.
+	HostAlert struct {
+		ID       string    `json:"id"`
+		Severity string    `json:"severity"`
+		Message  string    `json:"message"`
+		Since    time.Time `json:"since"`
+	}
+
 	// A Host can take storage from disk and offer it to the network, managing
 	// things such as announcements, settings, and implementing all of the RPCs
 	// of the host protocol.
 	Host interface {
+		// Alerts returns the operational problems of the host, most severe
+		// first.
+		Alerts() []HostAlert
+
 		// Announce submits a host announcement to the blockchain.
 		Announce() error
 
+		// Audit checks that the host could build valid storage proofs for
+		// a random selection of up to the given number of sectors.
+		Audit(sectors uint64) (HostAudit, error)
+
 		// AnnounceAddress submits an announcement using the given address.
 		AnnounceAddress(NetAddress) error
 
+		// Backup writes a consistent snapshot of the host's settings,
+		// storage obligations, and storage manager metadata to a directory,
+		// without stopping the host. Sector data is not included.
+		Backup(dir string) error
+
+		// DismissAlert removes an alert about a past problem. Alerts about
+		// problems that are still present cannot be dismissed.
+		DismissAlert(id string) error
+
 		// ExternalSettings returns the settings of the host as seen by an
 		// untrusted node querying the host for settings.
 		ExternalSettings() HostExternalSettings
@@ -104,6 +201,18 @@ type (
 		// have been made to the host.
 		NetworkMetrics() HostNetworkMetrics
 
+		// ObligationSummary returns the number of storage obligations with
+		// each status, and the upcoming storage proof deadlines.
+		ObligationSummary() (HostObligationSummary, error)
+
+		// Reachability returns information about whether the host can be
+		// reached by renters.
+		Reachability() HostReachability
+
+		// RecentErrors returns the most recent errors encountered by the host
+		// while handling RPCs, oldest first.
+		RecentErrors() []HostError
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
@@ -0,0 +1,205 @@
+package host
+
+// alerts.go reports the operational problems of the host that may cost it
+// money. Some problems are conditions that are checked every time the alerts
+// are requested, and disappear once they are fixed. Others are events, such
+// as a missed storage proof, that are remembered until the operator dismisses
+// them. Like the recent errors, the alerts are not persisted.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// The IDs of the alerts about conditions of the host.
+	alertCollateralBudget = "collateralbudget"
+	alertUnreachable      = "unreachable"
+	alertWalletLocked     = "walletlocked"
+
+	// The prefixes of the IDs of the alerts that are specific to a storage
+	// folder or to a storage obligation. The rest of the ID is the path of
+	// the storage folder or the ID of the obligation.
+	alertFolderFailingPrefix = "folderfailing:"
+	alertFolderMissingPrefix = "foldermissing:"
+	alertProofMissedPrefix   = "proofmissed:"
+)
+
+var (
+	// errAlertActive is returned when the operator tries to dismiss an alert
+	// about a problem that is still present.
+	errAlertActive = errors.New("alert cannot be dismissed while its problem is present")
+
+	// errUnknownAlert is returned when the operator tries to dismiss an alert
+	// that does not exist.
+	errUnknownAlert = errors.New("no alert with that id")
+)
+
+// alertsBySeverity sorts alerts with critical alerts first, and then by the
+// time at which their problem was noticed, oldest first.
+type alertsBySeverity []modules.HostAlert
+
+func (as alertsBySeverity) Len() int      { return len(as) }
+func (as alertsBySeverity) Swap(i, j int) { as[i], as[j] = as[j], as[i] }
+func (as alertsBySeverity) Less(i, j int) bool {
+	ci, cj := as[i].Severity == modules.HostAlertCritical, as[j].Severity == modules.HostAlertCritical
+	if ci != cj {
+		return ci
+	}
+	return as[i].Since.Before(as[j].Since)
+}
+
+// raiseAlert records an alert about an event. If an alert with the same ID
+// exists, its message and severity are replaced, but the time at which the
+// problem was first noticed is kept. The caller must hold the lock.
+func (h *Host) raiseAlert(id, severity, message string) {
+	alert, exists := h.alerts[id]
+	if !exists {
+		alert.Since = time.Now()
+	}
+	alert.ID = id
+	alert.Severity = severity
+	alert.Message = message
+	h.alerts[id] = alert
+}
+
+// managedAlertConditions returns an alert for each problematic condition of
+// the host. The Since field of the alerts is not set.
+func (h *Host) managedAlertConditions() []modules.HostAlert {
+	var conditions []modules.HostAlert
+	h.mu.RLock()
+	settings := h.settings
+	locked := h.financialMetrics.LockedStorageCollateral
+	h.mu.RUnlock()
+
+	if settings.AcceptingContracts {
+		// The collateral budget limits the contracts that the host can
+		// accept, so a host that has used up its budget loses business.
+		budget := settings.CollateralBudget
+		if locked.Cmp(budget) >= 0 {
+			conditions = append(conditions, modules.HostAlert{
+				ID:       alertCollateralBudget,
+				Severity: modules.HostAlertCritical,
+				Message:  fmt.Sprintf("collateral budget of %v H is used up; new contracts are rejected", budget),
+			})
+		} else if locked.Mul64(100).Cmp(budget.Mul64(collateralAlertPercent)) >= 0 {
+			conditions = append(conditions, modules.HostAlert{
+				ID:       alertCollateralBudget,
+				Severity: modules.HostAlertWarning,
+				Message:  fmt.Sprintf("%v H of the collateral budget of %v H is locked in contracts", locked, budget),
+			})
+		}
+
+		// The wallet funds the collateral of new contracts.
+		if !h.wallet.Unlocked() {
+			conditions = append(conditions, modules.HostAlert{
+				ID:       alertWalletLocked,
+				Severity: modules.HostAlertCritical,
+				Message:  "wallet is locked; new contracts cannot be funded",
+			})
+		}
+
+		reachability := h.Reachability()
+		if !reachability.Announced {
+			conditions = append(conditions, modules.HostAlert{
+				ID:       alertUnreachable,
+				Severity: modules.HostAlertWarning,
+				Message:  "host is accepting contracts but has not been announced at its current address",
+			})
+		} else if !reachability.Reachable {
+			conditions = append(conditions, modules.HostAlert{
+				ID:       alertUnreachable,
+				Severity: modules.HostAlertWarning,
+				Message:  fmt.Sprintf("no renter has connected to %v since %v; check that the host is reachable", reachability.NetAddress, reachability.LastInboundConnection.Format(time.RFC3339)),
+			})
+		}
+	}
+
+	// Sectors in a missing storage folder cannot be read, so the storage
+	// proofs for them will fail.
+	for _, sf := range h.StorageFolders() {
+		if sf.Missing {
+			conditions = append(conditions, modules.HostAlert{
+				ID:       alertFolderMissingPrefix + sf.Path,
+				Severity: modules.HostAlertCritical,
+				Message:  fmt.Sprintf("storage folder %v is missing; relink it to its new path", sf.Path),
+			})
+		}
+	}
+	return conditions
+}
+
+// Alerts returns the operational problems of the host, most severe first.
+func (h *Host) Alerts() []modules.HostAlert {
+	conditions := h.managedAlertConditions()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Keep the time at which each condition was first noticed, and forget
+	// the conditions that have been fixed.
+	since := make(map[string]time.Time)
+	for i := range conditions {
+		t, exists := h.conditionsSince[conditions[i].ID]
+		if !exists {
+			t = time.Now()
+		}
+		conditions[i].Since = t
+		since[conditions[i].ID] = t
+	}
+	h.conditionsSince = since
+
+	alerts := conditions
+	for _, alert := range h.alerts {
+		alerts = append(alerts, alert)
+	}
+	sort.Stable(alertsBySeverity(alerts))
+	return alerts
+}
+
+// DismissAlert removes an alert about a past problem.
+func (h *Host) DismissAlert(id string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, exists := h.conditionsSince[id]; exists {
+		return errAlertActive
+	}
+	if _, exists := h.alerts[id]; !exists {
+		return errUnknownAlert
+	}
+	delete(h.alerts, id)
+	return nil
+}
+
+// threadedWatchStorageFolders raises an alert for each storage folder that
+// fails to read or write a sector.
+func (h *Host) threadedWatchStorageFolders() {
+	events, unsubscribe := h.StorageManager.SubscribeEvents()
+	defer unsubscribe()
+	for {
+		select {
+		case e := <-events:
+			data, ok := e.Data.(modules.StorageFolderErrorEvent)
+			if !ok {
+				continue
+			}
+			h.mu.Lock()
+			h.raiseAlert(alertFolderFailingPrefix+data.Path, modules.HostAlertCritical,
+				fmt.Sprintf("storage folder %v failed to %v a sector: %v", data.Path, data.Operation, data.Error))
+			h.mu.Unlock()
+		case <-h.tg.StopChan():
+			return
+		}
+	}
+}
+
+// raiseProofMissedAlert raises an alert for a storage obligation whose
+// storage proof was not confirmed in time. The caller must hold the lock.
+func (h *Host) raiseProofMissedAlert(id types.FileContractID, lost types.Currency) {
+	h.raiseAlert(alertProofMissedPrefix+id.String(), modules.HostAlertCritical,
+		fmt.Sprintf("storage proof for contract %v was missed, losing %v H of collateral", id, lost))
+}
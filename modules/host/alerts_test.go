@@ -0,0 +1,106 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// findAlert returns the alert with the given ID.
+func findAlert(alerts []modules.HostAlert, id string) (modules.HostAlert, bool) {
+	for _, alert := range alerts {
+		if alert.ID == id {
+			return alert, true
+		}
+	}
+	return modules.HostAlert{}, false
+}
+
+// TestHostAlerts checks that the host raises alerts for its problematic
+// conditions and for missed storage proofs, and that only alerts about past
+// events can be dismissed.
+func TestHostAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestHostAlerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	if alerts := ht.host.Alerts(); len(alerts) != 0 {
+		t.Fatal("host should start without alerts:", alerts)
+	}
+
+	// Accept contracts with the whole collateral budget locked in contracts.
+	budget := types.SiacoinPrecision.Mul64(100)
+	ht.host.mu.Lock()
+	ht.host.settings.AcceptingContracts = true
+	ht.host.settings.CollateralBudget = budget
+	ht.host.financialMetrics.LockedStorageCollateral = budget
+	ht.host.mu.Unlock()
+
+	alerts := ht.host.Alerts()
+	collateral, ok := findAlert(alerts, alertCollateralBudget)
+	if !ok || collateral.Severity != modules.HostAlertCritical {
+		t.Fatal("expected a critical collateral budget alert:", alerts)
+	}
+	if _, ok := findAlert(alerts, alertUnreachable); !ok {
+		t.Fatal("expected an alert for a host that has not been announced:", alerts)
+	}
+	if _, ok := findAlert(alerts, alertWalletLocked); ok {
+		t.Fatal("unexpected wallet alert while the wallet is unlocked")
+	}
+	if err := ht.host.DismissAlert(alertCollateralBudget); err != errAlertActive {
+		t.Fatal("expected errAlertActive, got", err)
+	}
+
+	// The time at which a condition was noticed is kept while it persists.
+	ht.host.mu.Lock()
+	ht.host.financialMetrics.LockedStorageCollateral = budget.Mul64(95).Div64(100)
+	ht.host.mu.Unlock()
+	warning, ok := findAlert(ht.host.Alerts(), alertCollateralBudget)
+	if !ok || warning.Severity != modules.HostAlertWarning {
+		t.Fatal("expected a collateral budget warning:", warning)
+	}
+	if !warning.Since.Equal(collateral.Since) {
+		t.Error("time at which the condition was noticed changed")
+	}
+
+	if err := ht.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := findAlert(ht.host.Alerts(), alertWalletLocked); !ok {
+		t.Fatal("expected an alert while the wallet is locked")
+	}
+
+	// Fixing a condition removes its alert.
+	ht.host.mu.Lock()
+	ht.host.settings.AcceptingContracts = false
+	ht.host.mu.Unlock()
+	if alerts := ht.host.Alerts(); len(alerts) != 0 {
+		t.Fatal("alerts remain after the host stopped accepting contracts:", alerts)
+	}
+
+	// Alerts about missed storage proofs stay until they are dismissed.
+	id := types.FileContractID{1}
+	ht.host.mu.Lock()
+	ht.host.raiseProofMissedAlert(id, types.SiacoinPrecision)
+	ht.host.mu.Unlock()
+	alerts = ht.host.Alerts()
+	if len(alerts) != 1 || alerts[0].ID != alertProofMissedPrefix+id.String() || alerts[0].Severity != modules.HostAlertCritical {
+		t.Fatal("expected a missed proof alert:", alerts)
+	}
+	if err := ht.host.DismissAlert(alerts[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if alerts := ht.host.Alerts(); len(alerts) != 0 {
+		t.Fatal("dismissed alert was not removed:", alerts)
+	}
+	if err := ht.host.DismissAlert(alertProofMissedPrefix + id.String()); err != errUnknownAlert {
+		t.Fatal("expected errUnknownAlert, got", err)
+	}
+}
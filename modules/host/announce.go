@@ -11,6 +11,11 @@ var (
 	// is locked.
 	errAnnWalletLocked = errors.New("cannot announce the host while the wallet is locked")
 
+	// errProxyAutoAddress is returned if the host is asked to announce its
+	// automatically discovered address while a proxy is in use, which would
+	// reveal the IP address that the proxy is hiding.
+	errProxyAutoAddress = errors.New("host will not announce its discovered address while a proxy is in use; set the address to announce explicitly")
+
 	// errUnknownAddress is returned if the host is unable to determine a
 	// public address for itself to use in the announcement.
 	errUnknownAddress = errors.New("host cannot announce, does not seem to have a valid address.")
@@ -68,6 +73,11 @@ func (h *Host) announce(addr modules.NetAddress) error {
 // arbitrary data, signing the transaction, and submitting it to the
 // transaction pool.
 func (h *Host) Announce() error {
+	// Announcements are signed with the host key, which is derived from the
+	// wallet seed.
+	if err := h.managedEstablishSigningKey(); err != nil {
+		return err
+	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	err := h.tg.Add()
@@ -80,6 +90,9 @@ func (h *Host) Announce() error {
 	if h.settings.NetAddress != "" {
 		return h.announce(h.settings.NetAddress)
 	}
+	if modules.Proxy() != "" {
+		return errProxyAutoAddress
+	}
 	if h.autoAddress == "" {
 		return errUnknownAddress
 	}
@@ -89,6 +102,11 @@ func (h *Host) Announce() error {
 // AnnounceAddress submits a host announcement to the blockchain to announce a
 // specific address. No checks for validity are performed on the address.
 func (h *Host) AnnounceAddress(addr modules.NetAddress) error {
+	// Announcements are signed with the host key, which is derived from the
+	// wallet seed.
+	if err := h.managedEstablishSigningKey(); err != nil {
+		return err
+	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	err := h.tg.Add()
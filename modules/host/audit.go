@@ -0,0 +1,160 @@
+package host
+
+// audit.go implements the self-audit of the host's storage, which checks that
+// the host could submit valid storage proofs for a random selection of the
+// sectors of its storage obligations, before the network finds out otherwise.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errAuditSectorCorrupt is returned when the data of an audited sector
+	// does not match the Merkle root of the sector.
+	errAuditSectorCorrupt = errors.New("sector data does not match the sector's Merkle root")
+
+	// errAuditProofInvalid is returned when the storage proof of an audited
+	// sector does not match the Merkle root of its contract.
+	errAuditProofInvalid = errors.New("storage proof does not match the contract's Merkle root")
+)
+
+// auditSector checks that a valid storage proof can be built for a random
+// segment of a sector of a storage obligation, given a tree of the
+// obligation's sector roots.
+func (h *Host) auditSector(so storageObligation, tree *crypto.SectorTree, sectorIndex uint64) error {
+	sectorRoot := so.SectorRoots[sectorIndex]
+	sectorBytes, err := h.ReadSector(sectorRoot)
+	if err != nil {
+		return fmt.Errorf("could not read sector: %v", err)
+	}
+	if crypto.MerkleRoot(sectorBytes) != sectorRoot {
+		return errAuditSectorCorrupt
+	}
+
+	segmentsPerSector := modules.SectorSize / crypto.SegmentSize
+	sectorSegment, err := crypto.RandIntn(int(segmentsPerSector))
+	if err != nil {
+		return err
+	}
+	segmentIndex := sectorIndex*segmentsPerSector + uint64(sectorSegment)
+	sp := so.storageProof(tree, sectorBytes, segmentIndex)
+	numSegments := crypto.CalculateLeaves(so.fileSize())
+	if !crypto.VerifySegment(sp.Segment[:], sp.HashSet, numSegments, segmentIndex, so.merkleRoot()) {
+		return errAuditProofInvalid
+	}
+	return nil
+}
+
+// Audit checks that the host could build valid storage proofs for a random
+// selection of up to the given number of sectors of the unresolved storage
+// obligations that still need a storage proof. Each storage obligation is
+// locked while its sectors are being checked.
+func (h *Host) Audit(sectors uint64) (audit modules.HostAudit, err error) {
+	if err = h.tg.Add(); err != nil {
+		return modules.HostAudit{}, err
+	}
+	defer h.tg.Done()
+
+	// Gather the number of sectors of each obligation that needs a proof.
+	var ids []types.FileContractID
+	var sizes []uint64
+	h.mu.RLock()
+	err = h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketStorageObligations).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var so storageObligation
+			err := json.Unmarshal(v, &so)
+			if err != nil {
+				return err
+			}
+			if so.ObligationStatus != obligationUnresolved || so.ProofConfirmed || len(so.SectorRoots) == 0 {
+				continue
+			}
+			ids = append(ids, so.id())
+			sizes = append(sizes, uint64(len(so.SectorRoots)))
+		}
+		return nil
+	})
+	h.mu.RUnlock()
+	if err != nil {
+		return modules.HostAudit{}, err
+	}
+	audit.Obligations = uint64(len(ids))
+	for _, size := range sizes {
+		audit.Sectors += size
+	}
+	audit.Failures = []modules.HostAuditFailure{}
+
+	// Select the sectors to check, numbering the sectors of all of the
+	// obligations consecutively.
+	selected := make(map[uint64]bool)
+	if sectors >= audit.Sectors {
+		for i := uint64(0); i < audit.Sectors; i++ {
+			selected[i] = true
+		}
+	}
+	for uint64(len(selected)) < sectors && uint64(len(selected)) < audit.Sectors {
+		n, err := crypto.RandIntn(int(audit.Sectors))
+		if err != nil {
+			return modules.HostAudit{}, err
+		}
+		selected[uint64(n)] = true
+	}
+
+	// Check the selected sectors of each obligation. The obligation is read
+	// again under its lock, in case it was revised since it was counted.
+	var offset uint64
+	for i, id := range ids {
+		var indices []uint64
+		for j := uint64(0); j < sizes[i]; j++ {
+			if selected[offset+j] {
+				indices = append(indices, j)
+			}
+		}
+		offset += sizes[i]
+		if len(indices) == 0 {
+			continue
+		}
+
+		h.managedLockStorageObligation(id)
+		var so storageObligation
+		h.mu.RLock()
+		err = h.db.View(func(tx *bolt.Tx) error {
+			so, err = getStorageObligation(tx, id)
+			return err
+		})
+		h.mu.RUnlock()
+		if err == nil && so.ObligationStatus == obligationUnresolved {
+			tree := crypto.NewSectorTree(so.SectorRoots)
+			for _, index := range indices {
+				if index >= uint64(len(so.SectorRoots)) {
+					continue
+				}
+				audit.SectorsChecked++
+				if err := h.auditSector(so, tree, index); err != nil {
+					h.log.Printf("Audit of sector %v of storage obligation %v failed: %v", index, id, err)
+					audit.Failures = append(audit.Failures, modules.HostAuditFailure{
+						ObligationID:  id,
+						SectorIndex:   index,
+						SectorRoot:    so.SectorRoots[index],
+						ProofDeadline: so.proofDeadline(),
+						Error:         err.Error(),
+					})
+				}
+			}
+		}
+		h.managedUnlockStorageObligation(id)
+		if err != nil && err != errNoStorageObligation {
+			return modules.HostAudit{}, err
+		}
+	}
+	return audit, nil
+}
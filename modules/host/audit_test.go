@@ -0,0 +1,121 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestAudit checks that the audit passes for a storage obligation whose
+// sectors are intact, and reports sectors that would fail a storage proof.
+func TestAudit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestAudit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// An empty host has nothing to audit.
+	audit, err := ht.host.Audit(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.Obligations != 0 || audit.SectorsChecked != 0 || len(audit.Failures) != 0 {
+		t.Fatal("unexpected audit of an empty host:", audit)
+	}
+
+	// Add a storage obligation holding two sectors.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.addStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+	sectorRoot1, sectorData1, err := randSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot2, sectorData2, err := randSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.SectorRoots = []crypto.Hash{sectorRoot1, sectorRoot2}
+	validPayouts, missedPayouts := so.payouts()
+	so.RevisionTransactionSet = []types.Transaction{{
+		FileContractRevisions: []types.FileContractRevision{{
+			ParentID:          so.id(),
+			UnlockConditions:  types.UnlockConditions{},
+			NewRevisionNumber: 1,
+
+			NewFileSize:           uint64(len(sectorData1) + len(sectorData2)),
+			NewFileMerkleRoot:     crypto.MerkleRoot(append(sectorData1, sectorData2...)),
+			NewWindowStart:        so.expiration(),
+			NewWindowEnd:          so.proofDeadline(),
+			NewValidProofOutputs:  validPayouts,
+			NewMissedProofOutputs: missedPayouts,
+			NewUnlockHash:         types.UnlockConditions{}.UnlockHash(),
+		}},
+	}}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.modifyStorageObligation(so, nil, so.SectorRoots, [][]byte{sectorData1, sectorData2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+
+	audit, err = ht.host.Audit(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.Obligations != 1 || audit.Sectors != 2 || audit.SectorsChecked != 2 || len(audit.Failures) != 0 {
+		t.Fatal("unexpected audit of intact sectors:", audit)
+	}
+	audit, err = ht.host.Audit(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.SectorsChecked != 1 {
+		t.Fatal("expected 1 sector to be checked, got", audit.SectorsChecked)
+	}
+
+	// A missing sector fails the audit.
+	err = ht.host.DeleteSector(sectorRoot2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err = ht.host.Audit(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.Failures) != 1 || audit.Failures[0].SectorRoot != sectorRoot2 || audit.Failures[0].SectorIndex != 1 {
+		t.Fatal("missing sector was not reported:", audit.Failures)
+	}
+
+	// A contract whose Merkle root does not match the sectors fails the
+	// audit for every sector.
+	so.RevisionTransactionSet[0].FileContractRevisions[0].NewFileMerkleRoot = sectorRoot1
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err = ht.host.Audit(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.Failures) != 2 || audit.Failures[0].Error != errAuditProofInvalid.Error() {
+		t.Fatal("mismatched contract root was not reported:", audit.Failures)
+	}
+}
@@ -0,0 +1,102 @@
+package host
+
+// backup.go takes backups of the host's metadata while the host is running.
+// A backup has the same layout as the host's persist directory, so restoring
+// it means stopping the host and copying the files of the backup over the
+// files of the persist directory. The storage folders are not part of the
+// backup; they must be backed up separately, or the sectors that they hold
+// will be lost.
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errBackupNotEmpty is returned if the backup destination already
+	// contains files, which the backup would otherwise overwrite.
+	errBackupNotEmpty = errors.New("backup destination is not empty")
+
+	// errBackupUnverified is returned if the database of the backup does not
+	// hold the same storage obligations as the host.
+	errBackupUnverified = errors.New("backup database does not match the host database")
+)
+
+// backupDatabase copies the host database to filename, and then checks that
+// the copy can be opened and holds the same number of storage obligations as
+// the host.
+func (h *Host) backupDatabase(filename string) error {
+	var obligations int
+	err := h.db.View(func(tx *bolt.Tx) error {
+		obligations = tx.Bucket(bucketStorageObligations).Stats().KeyN
+		return tx.CopyFile(filename, 0600)
+	})
+	if err != nil {
+		return err
+	}
+
+	db, err := persist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		bso := tx.Bucket(bucketStorageObligations)
+		if bso == nil || bso.Stats().KeyN != obligations {
+			return errBackupUnverified
+		}
+		return nil
+	})
+}
+
+// Backup writes a consistent snapshot of the host's metadata to dir: the
+// host's settings and identity, the database of storage obligations, and the
+// settings and database of the storage manager. Changes to storage
+// obligations wait until the snapshot has been taken, so that the storage
+// obligations and the sectors known to the storage manager agree. The
+// directory is created if it does not exist, and must be empty if it does.
+func (h *Host) Backup(dir string) error {
+	if err := h.tg.Add(); err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	} else if len(entries) > 0 {
+		return errBackupNotEmpty
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	// The host lock is acquired before the obligation lock, matching the
+	// order used when storage obligations are removed.
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.obligationChangeMu.Lock()
+	defer h.obligationChangeMu.Unlock()
+
+	err = h.StorageManager.BackupMetadata(filepath.Join(dir, "storagemanager"))
+	if err != nil {
+		return err
+	}
+	err = h.backupDatabase(filepath.Join(dir, dbFilename))
+	if err != nil {
+		return err
+	}
+	err = persist.SaveFileSync(persistMetadata, h.persistData(), filepath.Join(dir, settingsFile))
+	if err != nil {
+		return err
+	}
+	h.log.Println("Backed up the host metadata to", dir)
+	return nil
+}
@@ -0,0 +1,78 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestHostBackup checks that a backup holds the host's identity, its storage
+// obligations, and the storage manager metadata.
+func TestHostBackup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestHostBackup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.addStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+
+	dir := filepath.Join(ht.persistDir, "backup")
+	err = ht.host.Backup(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backup should not overwrite an earlier backup.
+	if err = ht.host.Backup(dir); err != errBackupNotEmpty {
+		t.Fatal("expected errBackupNotEmpty, got", err)
+	}
+
+	// The settings of the backup should hold the identity of the host.
+	var p persistence
+	err = persist.LoadFile(persistMetadata, &p, filepath.Join(dir, settingsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SecretKey != ht.host.secretKey || p.Settings.NetAddress != ht.host.settings.NetAddress {
+		t.Fatal("backup does not hold the host's identity and settings")
+	}
+
+	// The database of the backup should hold the storage obligation.
+	db, err := persist.OpenDatabase(dbMetadata, filepath.Join(dir, dbFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		_, err := getStorageObligation(tx, so.id())
+		return err
+	})
+	if err != nil {
+		t.Fatal("backup does not hold the storage obligation:", err)
+	}
+
+	// The storage manager metadata should be backed up alongside.
+	for _, name := range []string{"storagemanager.json", "storagemanager.db"} {
+		if _, err := os.Stat(filepath.Join(dir, "storagemanager", name)); err != nil {
+			t.Fatal("storage manager metadata was not backed up:", err)
+		}
+	}
+}
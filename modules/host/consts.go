@@ -9,6 +9,10 @@ import (
 )
 
 const (
+	// collateralAlertPercent is the percentage of the collateral budget that
+	// can be locked in storage obligations before the host raises an alert.
+	collateralAlertPercent = 90
+
 	// defaultMaxDuration defines the maximum number of blocks into the future
 	// that the host will accept for the duration of an incoming file contract
 	// obligation. 6 months is chosen because hosts are expected to be
@@ -158,6 +162,31 @@ var (
 		panic("unrecognized release constant in host - logAllLimit")
 	}()
 
+	// maxRecentErrors is the number of recent errors that the host keeps
+	// around to report to the user.
+	maxRecentErrors = 20
+
+	// maxUpcomingProofs is the maximum number of upcoming storage proof
+	// deadlines that are reported in the obligation summary.
+	maxUpcomingProofs = 10
+
+	// reachabilityTimeout is the amount of time after the last incoming
+	// connection that the host is still considered to be reachable. Renters
+	// scan hosts periodically, so a reachable host should receive connections
+	// regularly.
+	reachabilityTimeout = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Hour
+		}
+		if build.Release == "standard" {
+			return 24 * time.Hour
+		}
+		if build.Release == "testing" {
+			return time.Minute
+		}
+		panic("unrecognized release constant in host - reachabilityTimeout")
+	}()
+
 	// maximumLockedStorageObligations sets the maximum number of storage
 	// obligations that are allowed to be locked at a time. The map uses an
 	// in-memory lock, but also a locked storage obligation could be reading a
@@ -175,6 +204,23 @@ var (
 		panic("unrecognized release constant in host - maximumLockedStorageObligations")
 	}()
 
+	// maxPartialSectors is the maximum number of partially received sectors
+	// that the host keeps in memory so that interrupted uploads can be
+	// resumed. When the limit is reached, the oldest partial sector is
+	// discarded.
+	maxPartialSectors = func() int {
+		if build.Release == "dev" {
+			return 10
+		}
+		if build.Release == "standard" {
+			return 20
+		}
+		if build.Release == "testing" {
+			return 3
+		}
+		panic("unrecognized release constant in host - maxPartialSectors")
+	}()
+
 	// obligationLockTimeout defines how long a thread will wait to get a lock
 	// on a storage obligation before timing out and reporting an error to the
 	// renter.
@@ -191,6 +237,24 @@ var (
 		panic("unrecognized release constant in host - obligationLockTimeout")
 	}()
 
+	// proofPriorityWindow is the number of blocks before a storage proof
+	// deadline at which the host starts reading the sectors needed for the
+	// proof ahead of the sectors requested by renters. Missing a storage proof
+	// costs the host its collateral, whereas a slow download only costs the
+	// renter some time.
+	proofPriorityWindow = func() types.BlockHeight {
+		if build.Release == "dev" {
+			return 10 // About 2 minutes
+		}
+		if build.Release == "standard" {
+			return 36 // 6 hours.
+		}
+		if build.Release == "testing" {
+			return 3
+		}
+		panic("unrecognized release constant in host - proof priority window")
+	}()
+
 	// revisionSubmissionBuffer describes the number of blocks ahead of time
 	// that the host will submit a file contract revision. The host will not
 	// accept any more revisions once inside the submission buffer.
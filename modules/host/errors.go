@@ -113,8 +113,11 @@ func (ec ErrorInternal) Error() string {
 }
 
 // mangedLogError will take an error and log it to the host, depending on the
-// type of error and whether or not the DEBUG flag has been set.
+// type of error and whether or not the DEBUG flag has been set. The error is
+// also added to the list of recent errors.
 func (h *Host) managedLogError(err error) {
+	h.managedRecordError(err)
+
 	// Determine the type of error and the number of times that this error has
 	// been logged.
 	var num uint64
@@ -69,6 +69,7 @@ import (
 	"net"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -105,6 +106,10 @@ var (
 	// having been closed.
 	errHostClosed = errors.New("call is disabled because the host is closed")
 
+	// errNoStorage is returned by Healthy when the host is accepting
+	// contracts but has no storage folders to put the data in.
+	errNoStorage = errors.New("host is accepting contracts but has no storage folders")
+
 	// Nil dependency errors.
 	errNilCS     = errors.New("host cannot use a nil state")
 	errNilTpool  = errors.New("host cannot use a nil transaction pool")
@@ -134,6 +139,17 @@ type Host struct {
 	atomicInternalErrors      uint64
 	atomicNormalErrors        uint64
 
+	// recentErrors contains the most recent errors encountered while
+	// handling RPCs, so that they can be reported to the user. Like the error
+	// counters, they are not persisted.
+	recentErrors []modules.HostError
+
+	// alerts contains the alerts about past events that have not been
+	// dismissed, and conditionsSince records when each of the problematic
+	// conditions of the host was first noticed. See alerts.go.
+	alerts          map[string]modules.HostAlert
+	conditionsSince map[string]time.Time
+
 	// Dependencies.
 	cs     modules.ConsensusSet
 	tpool  modules.TransactionPool
@@ -158,20 +174,36 @@ type Host struct {
 	//
 	// The announced bool indicates whether the host remembers having a
 	// successful announcement with the current address.
-	announced        bool
-	autoAddress      modules.NetAddress
-	financialMetrics modules.HostFinancialMetrics
-	publicKey        types.SiaPublicKey
-	revisionNumber   uint64
-	secretKey        crypto.SecretKey
-	settings         modules.HostInternalSettings
-	unlockHash       types.UnlockHash // A wallet address that can receive coins.
+	//
+	// lastInboundConnection is the time at which the host last received an
+	// incoming connection, and is used to report whether the host is
+	// reachable.
+	announced             bool
+	autoAddress           modules.NetAddress
+	financialMetrics      modules.HostFinancialMetrics
+	lastInboundConnection time.Time
+	publicKey             types.SiaPublicKey
+	revisionNumber        uint64
+	secretKey             crypto.SecretKey
+	settings              modules.HostInternalSettings
+	unlockHash            types.UnlockHash // A wallet address that can receive coins.
 
 	// A map of storage obligations that are currently being modified. Locks on
 	// storage obligations can be long-running, and each storage obligation can
 	// be locked separately.
 	lockedStorageObligations map[types.FileContractID]*siasync.TryMutex
 
+	// obligationChangeMu is held as a read lock while storage obligations
+	// and their sectors are being changed, and as a write lock while a backup
+	// is taken, so that the backup does not see a change half-way through.
+	obligationChangeMu sync.RWMutex
+
+	// Sectors that were partially received before an upload was interrupted,
+	// kept so that the renter can resume the upload. The order records the
+	// age of the partial sectors, oldest first.
+	partialSectors     map[partialSectorID][]byte
+	partialSectorOrder []partialSectorID
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	listener   net.Listener
@@ -210,7 +242,7 @@ func (h *Host) checkUnlockHash() error {
 // mocked such that the dependencies can return unexpected errors or unique
 // behaviors during testing, enabling easier testing of the failure modes of
 // the Host.
-func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, listenerAddress string, persistDir string) (*Host, error) {
+func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, listenerAddress string, persistDir string, metadataDir string) (*Host, error) {
 	// Check that all the dependencies were provided.
 	if cs == nil {
 		return nil, errNilCS
@@ -229,10 +261,14 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		wallet:       wallet,
 		dependencies: dependencies,
 
+		alerts:                   make(map[string]modules.HostAlert),
+		conditionsSince:          make(map[string]time.Time),
 		lockedStorageObligations: make(map[types.FileContractID]*siasync.TryMutex),
+		partialSectors:           make(map[partialSectorID][]byte),
 
 		persistDir: persistDir,
 	}
+	h.tg.SetName("host")
 
 	// Call stop in the event of a partial startup.
 	var err error
@@ -264,12 +300,18 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 	})
 
 	// Add the storage manager to the host, and set up the stop call that will
-	// close the storage manager.
-	h.StorageManager, err = storagemanager.New(filepath.Join(persistDir, "storagemanager"))
+	// close the storage manager. The storage manager metadata is kept in the
+	// persist dir unless another directory was provided.
+	smDir := filepath.Join(persistDir, "storagemanager")
+	if metadataDir == "" {
+		metadataDir = smDir
+	}
+	sm, err := storagemanager.NewWithMetadataDir(smDir, metadataDir)
 	if err != nil {
 		h.log.Println("Could not open the storage manager:", err)
 		return nil, err
 	}
+	h.StorageManager = sm
 	h.tg.AfterStop(func() {
 		err = h.StorageManager.Close()
 		if err != nil {
@@ -305,12 +347,38 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		h.log.Println("Could not initialize host networking:", err)
 		return nil, err
 	}
+	go h.threadedShutdownOnStorageFailure(sm.Failed())
+	go h.threadedWatchStorageFolders()
 	return h, nil
 }
 
 // New returns an initialized Host.
 func New(cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, address string, persistDir string) (*Host, error) {
-	return newHost(productionDependencies{}, cs, tpool, wallet, address, persistDir)
+	return newHost(productionDependencies{}, cs, tpool, wallet, address, persistDir, "")
+}
+
+// NewWithMetadataDir returns an initialized Host that keeps the storage
+// manager's database and settings in metadataDir. metadataDir can be on a
+// different device from the storage folders and the rest of the host.
+func NewWithMetadataDir(cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, address string, persistDir string, metadataDir string) (*Host, error) {
+	return newHost(productionDependencies{}, cs, tpool, wallet, address, persistDir, metadataDir)
+}
+
+// threadedShutdownOnStorageFailure shuts down the host if the storage manager
+// fails to write its metadata. The host cannot keep its promises to renters
+// without the metadata, so it stops accepting new contracts and data instead
+// of carrying on in an inconsistent state.
+func (h *Host) threadedShutdownOnStorageFailure(failure <-chan struct{}) {
+	select {
+	case <-failure:
+	case <-h.tg.StopChan():
+		return
+	}
+	h.log.Println("Shutting down the host because the storage manager could not write its metadata")
+
+	// Errors are logged by the stop functions, and the logger is closed once
+	// the host has stopped.
+	_ = h.Close()
 }
 
 // Close shuts down the host.
@@ -318,6 +386,22 @@ func (h *Host) Close() error {
 	return h.tg.Stop()
 }
 
+// Healthy returns an error if the host is stopped, or if it is accepting
+// contracts without having any storage to offer.
+func (h *Host) Healthy() error {
+	if err := h.tg.Add(); err != nil {
+		return errHostClosed
+	}
+	defer h.tg.Done()
+	h.mu.RLock()
+	accepting := h.settings.AcceptingContracts
+	h.mu.RUnlock()
+	if accepting && len(h.StorageFolders()) == 0 {
+		return errNoStorage
+	}
+	return nil
+}
+
 // ExternalSettings returns the hosts external settings. These values cannot be
 // set by the user (host is configured through InternalSettings), and are the
 // values that get displayed to other hosts on the network.
@@ -134,7 +134,7 @@ func blankMockHostTester(d dependencies, name string) (*hostTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	h, err := newHost(d, cs, tp, w, "localhost:0", filepath.Join(testdir, modules.HostDir))
+	h, err := newHost(d, cs, tp, w, "localhost:0", filepath.Join(testdir, modules.HostDir), "")
 	if err != nil {
 		return nil, err
 	}
@@ -196,7 +196,7 @@ func newMockHostTester(d dependencies, name string) (*hostTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = ht.host.AddStorageFolder(storageFolderOne, modules.SectorSize*8)
+	err = ht.host.AddStorageFolder(storageFolderOne, modules.SectorSize*8, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -205,7 +205,7 @@ func newMockHostTester(d dependencies, name string) (*hostTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = ht.host.AddStorageFolder(storageFolderTwo, modules.SectorSize*8*2)
+	err = ht.host.AddStorageFolder(storageFolderTwo, modules.SectorSize*8*2, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -260,6 +260,55 @@ func TestHostInitialization(t *testing.T) {
 	}
 }
 
+// TestHostSigningKeyFromSeed checks that a host created while the wallet is
+// locked derives its signing key from the seed once the wallet is unlocked.
+func TestHostSigningKeyFromSeed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	bht, err := blankHostTester("TestHostSigningKeyFromSeed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bht.Close()
+	if len(bht.host.publicKey.Key) != 0 {
+		t.Fatal("host has a signing key before the wallet was unlocked")
+	}
+	if err := bht.host.managedEstablishSigningKey(); err != modules.ErrLockedWallet {
+		t.Fatal("expected ErrLockedWallet, got", err)
+	}
+
+	err = bht.initWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bht.host.managedEstablishSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, pk, err := bht.wallet.KeyAtIndex(modules.KeyPurposeHostSigning, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bht.host.secretKey != sk || string(bht.host.publicKey.Key) != string(pk[:]) {
+		t.Fatal("host signing key was not derived from the wallet seed")
+	}
+
+	// The derived key should be kept across restarts.
+	err = bht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bht.host, err = New(bht.cs, bht.tpool, bht.wallet, "localhost:0", filepath.Join(bht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bht.host.secretKey != sk {
+		t.Fatal("derived signing key was not persisted")
+	}
+}
+
 // TestHostMultiClose checks that the host returns an error if Close is called
 // multiple times on the host.
 func TestHostMultiClose(t *testing.T) {
@@ -288,7 +337,7 @@ func TestHostMultiClose(t *testing.T) {
 	// Set ht.host to something non-nil - nil was returned because startup was
 	// incomplete. If ht.host is nil at the end of the function, the ht.Close()
 	// operation will fail.
-	ht.host, err = newHost(productionDependencies{}, ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	ht.host, err = newHost(productionDependencies{}, ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir), "")
 	if err != nil {
 		t.Fatal(err)
 	}
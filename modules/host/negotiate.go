@@ -129,6 +129,10 @@ var (
 	// that is too small.
 	errSmallWindow = ErrorCommunication("rejected for small window size")
 
+	// errUnknownSectorRoot is returned if the renter asks the host to insert
+	// a sector by its Merkle root, but the host is not storing that sector.
+	errUnknownSectorRoot = ErrorCommunication("renter is attempting to insert a sector root that the host is not storing")
+
 	// errUnknownModification is returned if the host receives a modification
 	// action from the renter that it does not understand.
 	errUnknownModification = ErrorCommunication("renter is attempting an action that the host does not understand")
@@ -261,7 +265,7 @@ func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, rente
 			if err == nil {
 				return nil
 			}
-			if err != nil && i > 4 {
+			if err == errCollateralBudgetExceeded || i > 4 {
 				h.log.Println(err)
 				builder.Drop()
 				return err
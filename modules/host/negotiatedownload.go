@@ -113,15 +113,18 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	if err != nil {
 		return extendErr("failed to read renter signature: ", ErrorConnection(err.Error()))
 	}
+	// Verify that the signature is valid and get the host's signature. No
+	// data is sent unless the payment can be submitted to the blockchain.
 	txn, err := createRevisionSignature(paymentRevision, renterSignature, secretKey, blockHeight)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
+		return extendErr("could not create revision signature: ", ErrorCommunication(err.Error()))
+	}
 
 	// Update the storage obligation.
 	paymentTransfer := existingRevision.NewValidProofOutputs[0].Value.Sub(paymentRevision.NewValidProofOutputs[0].Value)
 	so.PotentialDownloadRevenue = so.PotentialDownloadRevenue.Add(paymentTransfer)
-	so.RevisionTransactionSet = []types.Transaction{{
-		FileContractRevisions: []types.FileContractRevision{paymentRevision},
-		TransactionSignatures: []types.TransactionSignature{renterSignature, txn.TransactionSignatures[1]},
-	}}
+	so.RevisionTransactionSet = []types.Transaction{txn}
 	err = h.modifyStorageObligation(*so, nil, nil, nil)
 	if err != nil {
 		return extendErr("failed to modify storage obligation: ", ErrorInternal(modules.WriteNegotiationRejection(conn, err).Error()))
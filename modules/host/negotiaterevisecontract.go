@@ -41,16 +41,32 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	settings := h.settings
 	secretKey := h.secretKey
 	blockHeight := h.blockHeight
+	riskedCollateral := h.financialMetrics.RiskedStorageCollateral
 	h.mu.RUnlock()
 
 	// The renter is going to send its intended modifications, followed by the
 	// file contract revision that pays for them.
 	var modifications []modules.RevisionAction
 	var revision types.FileContractRevision
-	err = encoding.ReadObject(conn, &modifications, settings.MaxReviseBatchSize)
+	err = encoding.ReadObjectStream(conn, &modifications, settings.MaxReviseBatchSize, modules.SectorSize)
 	if err != nil {
 		return extendErr("unable to read revision modifications: ", ErrorConnection(err.Error()))
 	}
+	// The data of resumable inserts is sent after the modifications. Once it
+	// has been received, they are handled like any other insert. If the
+	// received data does not match the root that the renter sent, the Merkle
+	// root of the revision will not match, and the revision is rejected.
+	for i, modification := range modifications {
+		if modification.Type != modules.ActionInsertResumable {
+			continue
+		}
+		sector, err := h.managedReceiveResumableSector(conn, so.id(), modification.Data)
+		if err != nil {
+			return extendErr("unable to receive sector data: ", err)
+		}
+		modifications[i].Type = modules.ActionInsert
+		modifications[i].Data = sector
+	}
 	err = encoding.ReadObject(conn, &revision, modules.NegotiateMaxFileContractRevisionSize)
 	if err != nil {
 		return extendErr("unable to read proposed revision: ", ErrorConnection(err.Error()))
@@ -105,6 +121,30 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				sectorsGained = append(sectorsGained, newRoot)
 				gainedSectorData = append(gainedSectorData, modification.Data)
 				so.SectorRoots = append(so.SectorRoots[:modification.SectorIndex], append([]crypto.Hash{newRoot}, so.SectorRoots[modification.SectorIndex:]...)...)
+			case modules.ActionInsertRoot:
+				// The data is the Merkle root of a sector that the host is
+				// already storing. The host only needs to add a virtual
+				// sector, so no upload bandwidth is charged.
+				if uint64(len(modification.Data)) != crypto.HashSize {
+					return errBadSectorSize
+				}
+				var newRoot crypto.Hash
+				copy(newRoot[:], modification.Data)
+				if !h.HasSector(newRoot) {
+					return errUnknownSectorRoot
+				}
+
+				// Update finances.
+				blocksRemaining := so.proofDeadline() - blockHeight
+				blockBytesCurrency := types.NewCurrency64(uint64(blocksRemaining)).Mul64(modules.SectorSize)
+				storageRevenue = storageRevenue.Add(settings.MinStoragePrice.Mul(blockBytesCurrency))
+				newCollateral = newCollateral.Add(settings.Collateral.Mul(blockBytesCurrency))
+
+				// Insert the sector into the root list. There is no data for
+				// the sector, as the host already has it.
+				sectorsGained = append(sectorsGained, newRoot)
+				gainedSectorData = append(gainedSectorData, nil)
+				so.SectorRoots = append(so.SectorRoots[:modification.SectorIndex], append([]crypto.Hash{newRoot}, so.SectorRoots[modification.SectorIndex:]...)...)
 			case modules.ActionModify:
 				// Check that the offset and length are okay. Length is already
 				// known to be appropriately small, but the offset needs to be
@@ -135,6 +175,11 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				return errUnknownModification
 			}
 		}
+		// The collateral put at risk by the revision counts towards the
+		// collateral budget, like the collateral of a new file contract.
+		if riskedCollateral.Add(newCollateral).Cmp(settings.CollateralBudget) > 0 {
+			return errCollateralBudgetExceeded
+		}
 		newRevenue := storageRevenue.Add(bandwidthRevenue)
 		return extendErr("unable to verify updated contract: ", verifyRevision(*so, revision, blockHeight, newRevenue, newCollateral))
 	}()
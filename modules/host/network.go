@@ -108,11 +108,11 @@ func (h *Host) initNetworking(address string) (err error) {
 // threadedHandleConn handles an incoming connection to the host, typically an
 // RPC.
 func (h *Host) threadedHandleConn(conn net.Conn) {
-	err := h.tg.Add()
+	err := h.tg.AddNamed("threadedHandleConn")
 	if err != nil {
 		return
 	}
-	defer h.tg.Done()
+	defer h.tg.DoneNamed("threadedHandleConn")
 
 	// Close the conn on host.Close or when the method terminates, whichever comes
 	// first.
@@ -142,6 +142,18 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 		return
 	}
 
+	// Any well-formed incoming call shows that the host is reachable.
+	h.mu.Lock()
+	h.lastInboundConnection = time.Now()
+	h.mu.Unlock()
+
+	// Every RPC is signed with the host key, which cannot be derived from the
+	// wallet seed until the wallet has been unlocked.
+	if err := h.managedEstablishSigningKey(); err != nil {
+		h.log.Debugln("WARN: refusing incoming RPC, the host signing key is unavailable:", err)
+		return
+	}
+
 	switch id {
 	case modules.RPCDownload:
 		atomic.AddUint64(&h.atomicDownloadCalls, 1)
@@ -0,0 +1,98 @@
+package host
+
+// partialsector.go allows renters to resume interrupted uploads. When a
+// renter inserts a sector with ActionInsertResumable, the sector data is sent
+// after the revision actions instead of inside them. If the connection fails
+// while the data is being received, the host keeps the part of the sector
+// that arrived. When the renter reconnects and inserts the same sector into
+// the same contract, the host tells the renter how much of the sector it
+// already has, and the renter only sends the rest.
+//
+// Partial sectors are kept in memory and are not persisted. Only a few are
+// kept at a time, as each can be as large as a full sector.
+
+import (
+	"io"
+	"net"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A partialSectorID identifies a partially received sector by the contract
+// that it is being inserted into and the Merkle root that the renter claims
+// the sector has. Including the contract prevents renters from interfering
+// with each other's uploads.
+type partialSectorID struct {
+	contract types.FileContractID
+	root     crypto.Hash
+}
+
+// managedPartialSector returns the part of a sector that was received before
+// an upload was interrupted, removing it from the set of partial sectors.
+func (h *Host) managedPartialSector(id partialSectorID) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	data := h.partialSectors[id]
+	h.removePartialSector(id)
+	return data
+}
+
+// managedStorePartialSector keeps the part of a sector that was received
+// before an upload was interrupted. If too many partial sectors are kept, the
+// oldest is discarded.
+func (h *Host) managedStorePartialSector(id partialSectorID, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removePartialSector(id)
+	h.partialSectors[id] = data
+	h.partialSectorOrder = append(h.partialSectorOrder, id)
+	for len(h.partialSectorOrder) > maxPartialSectors {
+		h.removePartialSector(h.partialSectorOrder[0])
+	}
+}
+
+// removePartialSector discards a partial sector.
+func (h *Host) removePartialSector(id partialSectorID) {
+	delete(h.partialSectors, id)
+	for i, other := range h.partialSectorOrder {
+		if other == id {
+			h.partialSectorOrder = append(h.partialSectorOrder[:i], h.partialSectorOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// managedReceiveResumableSector receives the data of a sector inserted with
+// ActionInsertResumable. The host writes the number of bytes of the sector
+// that it already has, and then reads the rest of the sector from the
+// renter. If the transfer is interrupted, the data received so far is kept so
+// that the renter can resume the transfer on a new connection.
+func (h *Host) managedReceiveResumableSector(conn net.Conn, contract types.FileContractID, rootData []byte) ([]byte, error) {
+	if uint64(len(rootData)) != crypto.HashSize {
+		return nil, errBadSectorSize
+	}
+	var id partialSectorID
+	id.contract = contract
+	copy(id.root[:], rootData)
+
+	// The sector is assembled in a buffer of the full size, so that the
+	// partial data does not need to be copied.
+	sector := make([]byte, modules.SectorSize)
+	offset := copy(sector, h.managedPartialSector(id))
+	if err := encoding.WriteObject(conn, uint64(offset)); err != nil {
+		h.managedStorePartialSector(id, sector[:offset])
+		return nil, ErrorConnection("could not send resume offset: " + err.Error())
+	}
+	n, err := io.ReadFull(conn, sector[offset:])
+	if err != nil {
+		h.managedStorePartialSector(id, sector[:offset+n])
+		return nil, ErrorConnection("sector transfer was interrupted: " + err.Error())
+	}
+	return sector, nil
+}
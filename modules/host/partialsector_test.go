@@ -0,0 +1,87 @@
+package host
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestResumableSectorTransfer checks that the host keeps the part of a sector
+// received before a transfer was interrupted, and that the renter only needs
+// to send the rest of the sector to resume the transfer.
+func TestResumableSectorTransfer(t *testing.T) {
+	h := &Host{partialSectors: make(map[partialSectorID][]byte)}
+	sector, err := crypto.RandBytes(int(modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := crypto.MerkleRoot(sector)
+	contract := types.FileContractID{1}
+
+	// transfer sends the part of the sector that the host asks for, up to
+	// limit bytes, and returns the offset that the host reported.
+	transfer := func(limit int) (uint64, []byte, error) {
+		hostConn, renterConn := net.Pipe()
+		defer hostConn.Close()
+		offsetChan := make(chan uint64)
+		go func() {
+			defer renterConn.Close()
+			var offset uint64
+			if err := encoding.ReadObject(renterConn, &offset, 16); err != nil {
+				close(offsetChan)
+				return
+			}
+			offsetChan <- offset
+			end := int(offset) + limit
+			if end > len(sector) {
+				end = len(sector)
+			}
+			renterConn.Write(sector[offset:end])
+		}()
+		received, err := h.managedReceiveResumableSector(hostConn, contract, root[:])
+		return <-offsetChan, received, err
+	}
+
+	// Interrupt the transfer after half of the sector has been sent.
+	offset, _, err := transfer(len(sector) / 2)
+	if err == nil {
+		t.Fatal("interrupted transfer should fail")
+	} else if offset != 0 {
+		t.Fatal("expected the first transfer to start at 0, got", offset)
+	}
+
+	// The second transfer should continue where the first one stopped.
+	offset, received, err := transfer(len(sector))
+	if err != nil {
+		t.Fatal(err)
+	} else if offset != uint64(len(sector)/2) {
+		t.Fatal("expected the transfer to resume at", len(sector)/2, "got", offset)
+	} else if !bytes.Equal(received, sector) {
+		t.Fatal("resumed transfer produced the wrong sector")
+	}
+	if len(h.partialSectors) != 0 || len(h.partialSectorOrder) != 0 {
+		t.Fatal("partial sector was not discarded after the transfer completed")
+	}
+
+	// Partial sectors of other contracts are not used.
+	h.managedStorePartialSector(partialSectorID{types.FileContractID{2}, root}, sector[:10])
+	if offset, _, err = transfer(len(sector)); err != nil || offset != 0 {
+		t.Fatal("transfer used the partial sector of another contract:", offset, err)
+	}
+
+	// Only the most recent partial sectors are kept.
+	for i := 0; i <= maxPartialSectors; i++ {
+		h.managedStorePartialSector(partialSectorID{types.FileContractID{byte(i + 3)}, root}, sector[:10])
+	}
+	if len(h.partialSectors) != maxPartialSectors || len(h.partialSectorOrder) != maxPartialSectors {
+		t.Fatal("expected", maxPartialSectors, "partial sectors, got", len(h.partialSectors))
+	}
+	if _, exists := h.partialSectors[partialSectorID{types.FileContractID{3}, root}]; exists {
+		t.Fatal("oldest partial sector was not discarded")
+	}
+}
@@ -90,23 +90,56 @@ func (h *Host) establishDefaults() error {
 		MinUploadBandwidthPrice:   defaultUploadBandwidthPrice,
 	}
 
-	// Generate signing key, for revising contracts.
-	sk, pk, err := crypto.GenerateKeyPair()
+	// Derive the signing key, for revising contracts, from the wallet seed so
+	// that the host identity can be recovered from the seed. The wallet is
+	// usually locked when the host is first started, in which case the key
+	// is derived by managedEstablishSigningKey once the wallet is unlocked.
+	sk, pk, err := h.wallet.KeyAtIndex(modules.KeyPurposeHostSigning, 0)
+	if err == nil {
+		h.setSigningKey(sk, pk)
+	} else if err != modules.ErrLockedWallet {
+		return err
+	}
+
+	// Subscribe to the consensus set.
+	err = h.initConsensusSubscription()
 	if err != nil {
 		return err
 	}
+	return nil
+}
+
+// setSigningKey sets the key pair that the host signs with.
+func (h *Host) setSigningKey(sk crypto.SecretKey, pk crypto.PublicKey) {
 	h.secretKey = sk
 	h.publicKey = types.SiaPublicKey{
 		Algorithm: types.SignatureEd25519,
 		Key:       pk[:],
 	}
+}
 
-	// Subscribe to the consensus set.
-	err = h.initConsensusSubscription()
+// managedEstablishSigningKey derives the signing key of the host from the
+// wallet seed if it has not been derived yet. modules.ErrLockedWallet is
+// returned if the key is missing and the wallet is locked.
+func (h *Host) managedEstablishSigningKey() error {
+	h.mu.RLock()
+	established := len(h.publicKey.Key) != 0
+	h.mu.RUnlock()
+	if established {
+		return nil
+	}
+
+	sk, pk, err := h.wallet.KeyAtIndex(modules.KeyPurposeHostSigning, 0)
 	if err != nil {
 		return err
 	}
-	return nil
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.publicKey.Key) != 0 {
+		return nil
+	}
+	h.setSigningKey(sk, pk)
+	return h.saveSync()
 }
 
 // initDB will check that the database has been initialized and if not, will
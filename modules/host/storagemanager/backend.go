@@ -0,0 +1,107 @@
+package storagemanager
+
+// backend.go defines the interface between the storage manager and the
+// storage that holds the sectors of a storage folder. Most storage folders are
+// directories on a local disk, reached through a symlink in the storage
+// manager's directory. Storage folders can also be held by an S3-compatible
+// object store, see objectstore.go.
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A sectorBackend stores the sectors of a single storage folder. Sectors are
+// identified by the sector ID that the storage manager derives from their
+// Merkle root. A backend is not told about the sector usage database; keeping
+// the database consistent with the backend is the job of the storage manager.
+type sectorBackend interface {
+	// readSector returns the data of a sector. If parts is greater than one,
+	// the backend may fetch the sector in that many pieces at once, which
+	// lowers the latency of reads from backends that are reached over a
+	// network.
+	readSector(id []byte, parts int) ([]byte, error)
+
+	// removeSector removes a sector from the backend.
+	removeSector(id []byte) error
+
+	// writeSector stores the data of a sector. A failed write may leave
+	// partial data behind, which should be cleaned up with removeSector.
+	writeSector(id []byte, data []byte) error
+}
+
+// fileBackend stores sectors as files in a directory, using the storage
+// manager's dependencies so that disk failures can be simulated.
+type fileBackend struct {
+	deps dependencies
+	dir  string
+}
+
+// readSector reads a sector file from disk. Local disks gain nothing from
+// parallel reads, so parts is ignored.
+func (fb fileBackend) readSector(id []byte, parts int) ([]byte, error) {
+	return fb.deps.readFile(filepath.Join(fb.dir, string(id)))
+}
+
+// removeSector removes a sector file from disk.
+func (fb fileBackend) removeSector(id []byte) error {
+	return fb.deps.removeFile(filepath.Join(fb.dir, string(id)))
+}
+
+// writeSector writes a sector file to disk.
+func (fb fileBackend) writeSector(id []byte, data []byte) error {
+	return fb.deps.writeFile(filepath.Join(fb.dir, string(id)), data, 0400)
+}
+
+// isObjectStorePath returns true if a storage folder path refers to an object
+// store rather than a directory.
+func isObjectStorePath(path string) bool {
+	return strings.HasPrefix(path, objectStoreScheme+"://") || strings.HasPrefix(path, objectStoreInsecureScheme+"://")
+}
+
+// folderBackend returns the backend holding the sectors of the storage folder
+// with the given UID. Sectors of local folders are reached through the
+// folder's symlink, even if the folder is no longer known to the storage
+// manager.
+func (sm *StorageManager) folderBackend(uid []byte) (sectorBackend, error) {
+	sf := sm.storageFolder(uid)
+	if sf != nil && isObjectStorePath(sf.Path) {
+		return newObjectBackend(sf.Path, sf.uidString())
+	}
+	dir := filepath.Join(sm.persistDir, hex.EncodeToString(uid))
+	return fileBackend{deps: sm.dependencies, dir: dir}, nil
+}
+
+// linkStorageFolder checks that a new storage folder can hold sectors, and
+// links the folder into the storage manager's directory if it is local.
+func (sm *StorageManager) linkStorageFolder(sf *storageFolder) error {
+	if isObjectStorePath(sf.Path) {
+		ob, err := newObjectBackend(sf.Path, sf.uidString())
+		if err != nil {
+			return err
+		}
+		return ob.checkBucket()
+	}
+
+	// Check that the folder being linked to both exists and is a folder.
+	pathInfo, err := os.Stat(sf.Path)
+	if err != nil {
+		return err
+	}
+	if !pathInfo.Mode().IsDir() {
+		return errStorageFolderNotFolder
+	}
+	// Symlink the path for the data to the UID location of the host.
+	return sm.dependencies.symlink(sf.Path, filepath.Join(sm.persistDir, sf.uidString()))
+}
+
+// unlinkStorageFolder removes the link between a removed storage folder and
+// the storage manager's directory. Object store folders are not linked.
+func (sm *StorageManager) unlinkStorageFolder(sf *storageFolder) error {
+	if isObjectStorePath(sf.Path) {
+		return nil
+	}
+	return sm.dependencies.removeFile(filepath.Join(sm.persistDir, sf.uidString()))
+}
@@ -0,0 +1,35 @@
+package storagemanager
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// BackupMetadata writes a consistent copy of the storage manager's settings
+// and database to dir. The sector data in the storage folders is not copied.
+// Sectors cannot be added or removed while the copy is being made.
+func (sm *StorageManager) BackupMetadata(dir string) error {
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return errStorageManagerClosed
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	err = persist.SaveFileSync(persistMetadata, sm.persistData(), filepath.Join(dir, settingsFile))
+	if err != nil {
+		return err
+	}
+	return sm.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(filepath.Join(dir, dbFilename), 0600)
+	})
+}
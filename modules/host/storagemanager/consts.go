@@ -34,6 +34,24 @@ var (
 		panic("unrecognized release constant in host - maximum storage folder size")
 	}()
 
+	// folderReadLimit is the number of sector reads that each storage folder
+	// performs at once. Reads from different storage folders are performed
+	// in parallel, so that a host with many disks can read the sectors for
+	// many storage proofs within the proof window. A few reads at a time keep
+	// a disk busy without making every read slower.
+	folderReadLimit = func() int {
+		if build.Release == "dev" {
+			return 2
+		}
+		if build.Release == "standard" {
+			return 4
+		}
+		if build.Release == "testing" {
+			return 2
+		}
+		panic("unrecognized release constant in host - folder read limit")
+	}()
+
 	// maximumVirtualSectors defines the maximum number of virtual sectors that
 	// can be tied to each physical sector.
 	maximumVirtualSectors = func() int {
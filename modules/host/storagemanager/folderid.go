@@ -0,0 +1,180 @@
+package storagemanager
+
+// folderid.go identifies local storage folders by the UID of the storage
+// folder rather than by their path. A small file holding the UID is written
+// into every local storage folder. When the storage manager starts, it checks
+// that each storage folder still holds the file with its UID. A folder that
+// does not, for example because its drive has been mounted somewhere else, is
+// marked as missing instead of having its sectors treated as lost. The
+// operator can then relink the storage folder to its new path, and the
+// storage manager checks that the new path holds the same storage folder
+// before using it.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// folderIDFile is the name of the file in each local storage folder that
+	// holds the UID of the storage folder.
+	folderIDFile = "siastoragefolder.json"
+)
+
+var (
+	// folderIDMetadata is the header of the file that identifies a storage
+	// folder.
+	folderIDMetadata = persist.Metadata{
+		Header:  "Sia Storage Folder",
+		Version: "1.0.3",
+	}
+
+	// ErrFolderInUse is returned if a storage folder is added at a path that
+	// already holds one of the host's storage folders, for example after the
+	// storage folder was moved to a new mount point.
+	ErrFolderInUse = errors.New("selected path already holds a storage folder of this host, please use 'relink'")
+
+	// ErrFolderMismatch is returned if a storage folder is relinked to a path
+	// that does not hold that storage folder.
+	ErrFolderMismatch = errors.New("selected path does not hold the storage folder being relinked")
+
+	// errRelinkObjectStore is returned if a storage folder in an object store
+	// is relinked, or a storage folder is relinked to an object store.
+	errRelinkObjectStore = errors.New("storage folders in an object store cannot be relinked")
+)
+
+// folderID is the contents of the file that identifies a storage folder.
+type folderID struct {
+	UID string
+}
+
+// folderIDPath returns the location of the file that identifies the storage
+// folder at path.
+func folderIDPath(path string) string {
+	return filepath.Join(path, folderIDFile)
+}
+
+// readFolderID returns the UID of the storage folder at path.
+func (sm *StorageManager) readFolderID(path string) ([]byte, error) {
+	var id folderID
+	err := sm.dependencies.loadFile(folderIDMetadata, &id, folderIDPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(id.UID)
+}
+
+// writeFolderID writes the file that identifies a local storage folder into
+// the folder.
+func (sm *StorageManager) writeFolderID(sf *storageFolder) error {
+	if isObjectStorePath(sf.Path) {
+		return nil
+	}
+	return persist.SaveFileSync(folderIDMetadata, folderID{UID: sf.uidString()}, folderIDPath(sf.Path))
+}
+
+// checkStorageFolders checks that every local storage folder still holds the
+// file with its UID, marking the folders that do not as missing. Storage
+// folders created by older versions have no such file; the file is written
+// for them as long as the folder does not appear to have been moved.
+func (sm *StorageManager) checkStorageFolders() {
+	for _, sf := range sm.storageFolders {
+		if isObjectStorePath(sf.Path) {
+			continue
+		}
+		uid, err := sm.readFolderID(sf.Path)
+		if os.IsNotExist(err) && sm.canAdoptFolder(sf) {
+			err = sm.writeFolderID(sf)
+			if err != nil {
+				sm.log.Println("Unable to write the ID of storage folder", sf.Path, err)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(uid, sf.UID) {
+			sf.missing = true
+			sm.log.Println("WARN: storage folder", sf.Path, "is missing or holds a different storage folder; relink it if it has been moved:", err)
+		}
+	}
+}
+
+// canAdoptFolder returns whether a storage folder without an ID file is
+// trusted to be the storage folder at its path. An empty directory is only
+// trusted if the storage folder holds no sectors, as an empty directory is
+// what remains at the path of a drive that is no longer mounted.
+func (sm *StorageManager) canAdoptFolder(sf *storageFolder) bool {
+	if sf.SizeRemaining == sf.Size {
+		return true
+	}
+	dir, err := os.Open(sf.Path)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+	names, _ := dir.Readdirnames(1)
+	return len(names) > 0
+}
+
+// RelinkStorageFolder points a storage folder that has been moved, for
+// example to a new mount point, at its new path. The new path must hold the
+// storage folder, as identified by the UID written into the folder when it
+// was added.
+func (sm *StorageManager) RelinkStorageFolder(index int, newPath string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return errStorageManagerClosed
+	}
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
+
+	// Check that the inputs are valid.
+	if index >= len(sm.storageFolders) || index < 0 {
+		return errBadStorageFolderIndex
+	}
+	sf := sm.storageFolders[index]
+	if isObjectStorePath(sf.Path) || isObjectStorePath(newPath) {
+		return errRelinkObjectStore
+	}
+	if !filepath.IsAbs(newPath) {
+		if newPath == "" {
+			return ErrEmptyPath
+		}
+		return ErrRelativePath
+	}
+	for i, other := range sm.storageFolders {
+		if i != index && other.Path == newPath {
+			return ErrRepeatFolder
+		}
+	}
+	uid, err := sm.readFolderID(newPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !bytes.Equal(uid, sf.UID) {
+		return ErrFolderMismatch
+	}
+
+	// Replace the symlink to the old path with a symlink to the new path.
+	err = sm.unlinkStorageFolder(sf)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	oldPath := sf.Path
+	sf.Path = newPath
+	err = sm.linkStorageFolder(sf)
+	if err != nil {
+		sf.Path = oldPath
+		return composeErrors(err, sm.dependencies.symlink(oldPath, filepath.Join(sm.persistDir, sf.uidString())))
+	}
+	sf.missing = false
+	sm.log.Println("Relinked storage folder", oldPath, "to", newPath)
+	return sm.saveSync()
+}
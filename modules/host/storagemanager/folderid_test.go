@@ -0,0 +1,91 @@
+package storagemanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRelinkStorageFolder checks that a storage folder which is moved while
+// the storage manager is not running is marked as missing, and that it can be
+// relinked to its new path without losing its sectors.
+func TestRelinkStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	testdir := build.TempDir(modules.StorageManagerDir, "TestRelinkStorageFolder")
+	persistDir := filepath.Join(testdir, modules.StorageManagerDir)
+	sm, err := New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPath := filepath.Join(testdir, "oldmount")
+	if err := os.Mkdir(oldPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddStorageFolder(oldPath, minimumStorageFolderSize, nil); err != nil {
+		t.Fatal(err)
+	}
+	root, data, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddSector(root, types.BlockHeight(10), data); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the storage folder, leaving an empty directory at the old path as
+	// an unmounted drive would.
+	newPath := filepath.Join(testdir, "newmount")
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(oldPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	sm, err = New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+	if !sm.StorageFolders()[0].Missing {
+		t.Fatal("moved storage folder is not marked as missing")
+	}
+
+	// The moved folder cannot be added as a new storage folder, and the
+	// storage folder cannot be relinked to a folder that does not hold it.
+	if err := sm.AddStorageFolder(newPath, minimumStorageFolderSize, nil); err != ErrFolderInUse {
+		t.Fatal("expected ErrFolderInUse, got", err)
+	}
+	otherPath := filepath.Join(testdir, "other")
+	if err := os.Mkdir(otherPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.RelinkStorageFolder(0, otherPath); err != ErrFolderMismatch {
+		t.Fatal("expected ErrFolderMismatch, got", err)
+	}
+
+	// Relink the storage folder and check that its sector can be read.
+	if err := sm.RelinkStorageFolder(0, newPath); err != nil {
+		t.Fatal(err)
+	}
+	sfm := sm.StorageFolders()[0]
+	if sfm.Missing || sfm.Path != newPath {
+		t.Fatal("storage folder was not relinked:", sfm)
+	}
+	readData, err := sm.ReadSector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readData, data) {
+		t.Fatal("sector data changed after relinking the storage folder")
+	}
+}
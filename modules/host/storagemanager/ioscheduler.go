@@ -0,0 +1,114 @@
+package storagemanager
+
+import (
+	"errors"
+	"sync"
+)
+
+// An ioClass is the kind of work that a disk operation is performed for.
+// Classes with a lower value have a higher priority.
+type ioClass int
+
+const (
+	// ioProof is a sector read needed to build a storage proof. Missing a
+	// storage proof costs the host its collateral, so proofs come first.
+	ioProof ioClass = iota
+
+	// ioDownload is a sector read that serves a download.
+	ioDownload
+
+	// ioUpload is a sector write that stores an upload.
+	ioUpload
+
+	numIOClasses
+)
+
+var (
+	// ErrQueueFull is returned when too many operations of the same class
+	// are already waiting for the disk.
+	ErrQueueFull = errors.New("host is too busy to perform the disk operation; try again later")
+
+	// ioQueueDepth is the maximum number of operations of each class that
+	// may wait for the disk at once. Further operations fail with
+	// ErrQueueFull instead of adding to the backlog.
+	ioQueueDepth = [numIOClasses]int{
+		ioProof:    256,
+		ioDownload: 128,
+		ioUpload:   64,
+	}
+)
+
+// ioScheduler decides the order in which disk operations are performed. At
+// most 'limit' operations are performed at a time, or one if 'limit' is
+// zero. When an operation finishes, the waiting operation of the highest
+// class is started: proofs before downloads, and downloads before uploads, so
+// that storage proofs are never starved by bulk transfers. Operations of the
+// same class are started in the order that they arrived.
+//
+// The zero value is ready for use.
+type ioScheduler struct {
+	active int
+	limit  int
+	queues [numIOClasses][]chan struct{}
+	mu     sync.Mutex
+}
+
+// newIOScheduler returns an ioScheduler that performs up to limit operations
+// at a time.
+func newIOScheduler(limit int) *ioScheduler {
+	return &ioScheduler{limit: limit}
+}
+
+// acquire blocks until the caller is allowed to perform an operation of the
+// given class. If the class already has the maximum number of waiting
+// operations, ErrQueueFull is returned immediately. Every successful call to
+// acquire must be followed by a call to release.
+func (s *ioScheduler) acquire(class ioClass) error {
+	s.mu.Lock()
+	if s.active < s.limit || s.active == 0 {
+		s.active++
+		s.mu.Unlock()
+		return nil
+	}
+	if len(s.queues[class]) >= ioQueueDepth[class] {
+		s.mu.Unlock()
+		return ErrQueueFull
+	}
+	c := make(chan struct{})
+	s.queues[class] = append(s.queues[class], c)
+	s.mu.Unlock()
+	<-c
+	return nil
+}
+
+// release signals that the caller has finished its operation, handing its
+// place to the next waiting operation.
+func (s *ioScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for class := range s.queues {
+		if len(s.queues[class]) > 0 {
+			next := s.queues[class][0]
+			s.queues[class] = s.queues[class][1:]
+			close(next)
+			return
+		}
+	}
+	s.active--
+}
+
+// folderIO returns the scheduler for the sector reads of the storage folder
+// with the given UID. Each storage folder has its own scheduler, so reads
+// from different storage folders are performed in parallel, while no single
+// disk is given more than folderReadLimit reads at a time.
+func (sm *StorageManager) folderIO(uid []byte) *ioScheduler {
+	sm.folderIOMu.Lock()
+	defer sm.folderIOMu.Unlock()
+	key := string(uid)
+	s, exists := sm.folderIOs[key]
+	if !exists {
+		s = newIOScheduler(folderReadLimit)
+		sm.folderIOs[key] = s
+	}
+	return s
+}
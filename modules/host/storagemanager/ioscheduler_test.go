@@ -0,0 +1,145 @@
+package storagemanager
+
+import (
+	"testing"
+	"time"
+)
+
+// waitForQueue blocks until the I/O scheduler has the expected number of
+// waiting operations in each class.
+func waitForQueue(t *testing.T, s *ioScheduler, depths [numIOClasses]int) {
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		done := true
+		for class := range s.queues {
+			done = done && len(s.queues[class]) == depths[class]
+		}
+		s.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("operations were not queued")
+}
+
+// TestIOSchedulerPriority checks that waiting proofs are started before
+// waiting downloads, waiting downloads before waiting uploads, and that
+// operations of the same class are started in order.
+func TestIOSchedulerPriority(t *testing.T) {
+	var s ioScheduler
+	if err := s.acquire(ioUpload); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 6)
+	var depths [numIOClasses]int
+	queue := func(name string, class ioClass) {
+		go func() {
+			if err := s.acquire(class); err != nil {
+				order <- err.Error()
+				return
+			}
+			order <- name
+			s.release()
+		}()
+		depths[class]++
+		waitForQueue(t, &s, depths)
+	}
+	queue("upload1", ioUpload)
+	queue("download1", ioDownload)
+	queue("upload2", ioUpload)
+	queue("proof1", ioProof)
+	queue("download2", ioDownload)
+	queue("proof2", ioProof)
+	s.release()
+
+	for _, expected := range []string{"proof1", "proof2", "download1", "download2", "upload1", "upload2"} {
+		if name := <-order; name != expected {
+			t.Fatalf("expected %v to be performed next, got %v", expected, name)
+		}
+	}
+
+	// Once all operations have finished, the scheduler should be idle again.
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		active := s.active
+		s.mu.Unlock()
+		if active == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("scheduler is still active after all operations finished")
+}
+
+// TestIOSchedulerQueueDepth checks that operations are refused once their
+// class has the maximum number of waiting operations, without affecting the
+// other classes.
+func TestIOSchedulerQueueDepth(t *testing.T) {
+	oldDepth := ioQueueDepth
+	ioQueueDepth = [numIOClasses]int{ioProof: 1, ioDownload: 1, ioUpload: 1}
+	defer func() {
+		ioQueueDepth = oldDepth
+	}()
+
+	var s ioScheduler
+	if err := s.acquire(ioDownload); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 2)
+	go func() {
+		err := s.acquire(ioUpload)
+		if err == nil {
+			s.release()
+		}
+		done <- err
+	}()
+	waitForQueue(t, &s, [numIOClasses]int{ioUpload: 1})
+	if err := s.acquire(ioUpload); err != ErrQueueFull {
+		t.Fatal("expected ErrQueueFull, got", err)
+	}
+	go func() {
+		err := s.acquire(ioProof)
+		if err == nil {
+			s.release()
+		}
+		done <- err
+	}()
+	waitForQueue(t, &s, [numIOClasses]int{ioProof: 1, ioUpload: 1})
+
+	s.release()
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestIOSchedulerLimit checks that a scheduler performs up to its limit of
+// operations at once, and queues any further operations.
+func TestIOSchedulerLimit(t *testing.T) {
+	s := newIOScheduler(2)
+	for i := 0; i < 2; i++ {
+		if err := s.acquire(ioProof); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		if err := s.acquire(ioProof); err == nil {
+			s.release()
+		}
+		close(done)
+	}()
+	waitForQueue(t, s, [numIOClasses]int{ioProof: 1})
+
+	s.release()
+	<-done
+	s.release()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != 0 {
+		t.Fatal("scheduler still has active operations:", s.active)
+	}
+}
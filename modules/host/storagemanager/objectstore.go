@@ -0,0 +1,319 @@
+package storagemanager
+
+// objectstore.go implements a sector backend that keeps sectors in an
+// S3-compatible object store. A storage folder is held by an object store if
+// its path has the form
+//
+//	s3://endpoint/bucket/prefix?region=us-east-1
+//
+// where the endpoint is the host (and optional port) of the store, requests
+// are made over HTTPS, and buckets are addressed by path, which every
+// S3-compatible store supports. The prefix and region are optional; the region
+// defaults to us-east-1. The s3+http scheme makes requests over plain HTTP,
+// which should only be used for stores on the local machine or network.
+//
+// The credentials are read from the AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY environment variables when the storage folder is
+// used, so that they are never written to the storage manager's metadata.
+// Each sector is stored as the object prefix/folder/sector, where folder is
+// the UID of the storage folder, so several storage folders can share a
+// bucket.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// objectStoreScheme is the scheme of storage folder paths that refer to
+	// an object store reached over HTTPS.
+	objectStoreScheme = "s3"
+
+	// objectStoreInsecureScheme is the scheme of storage folder paths that
+	// refer to an object store reached over plain HTTP.
+	objectStoreInsecureScheme = "s3+http"
+
+	// objectStoreDefaultRegion is the region that requests are signed for if
+	// the storage folder path does not name one.
+	objectStoreDefaultRegion = "us-east-1"
+
+	// objectStoreProofReadParts is the number of ranged requests that a
+	// sector is fetched with when it is needed for a storage proof. Fetching
+	// the parts at once hides most of the latency of the store.
+	objectStoreProofReadParts = 4
+
+	// objectStoreRequestTimeout is the maximum amount of time that a single
+	// request to an object store may take.
+	objectStoreRequestTimeout = 2 * time.Minute
+)
+
+var (
+	// errObjectStoreCredentials is returned if an object store is used
+	// without credentials in the environment.
+	errObjectStoreCredentials = errors.New("object store credentials must be set in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+
+	// errObjectStoreSectorSize is returned if an object holding a sector
+	// does not have the size of a sector.
+	errObjectStoreSectorSize = errors.New("object store returned a sector of the wrong size")
+
+	// errObjectStorePath is returned if an object store path cannot be
+	// parsed.
+	errObjectStorePath = errors.New("object store path must have the form s3://endpoint/bucket[/prefix][?region=region]")
+
+	// objectStoreClient is the HTTP client that is shared by all object
+	// store backends.
+	objectStoreClient = &http.Client{Timeout: objectStoreRequestTimeout}
+)
+
+// objectBackend stores the sectors of a storage folder in an S3-compatible
+// object store.
+type objectBackend struct {
+	baseURL   string // scheme and endpoint
+	bucket    string
+	prefix    string // object key prefix, including the folder UID
+	region    string
+	accessKey string
+	secretKey string
+
+	// now returns the time that requests are signed at. It can be replaced
+	// when testing.
+	now func() time.Time
+}
+
+// newObjectBackend returns the backend for the storage folder with the given
+// path and UID.
+func newObjectBackend(path, uid string) (*objectBackend, error) {
+	u, err := url.Parse(path)
+	if err != nil || u.Host == "" {
+		return nil, errObjectStorePath
+	}
+	scheme := "https"
+	if u.Scheme == objectStoreInsecureScheme {
+		scheme = "http"
+	}
+	segments := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if segments[0] == "" {
+		return nil, errObjectStorePath
+	}
+	prefix := uid
+	if len(segments) == 2 && segments[1] != "" {
+		prefix = strings.Trim(segments[1], "/") + "/" + uid
+	}
+	region := u.Query().Get("region")
+	if region == "" {
+		region = objectStoreDefaultRegion
+	}
+
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, errObjectStoreCredentials
+	}
+	return &objectBackend{
+		baseURL:   scheme + "://" + u.Host,
+		bucket:    segments[0],
+		prefix:    prefix,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		now:       time.Now,
+	}, nil
+}
+
+// objectPath returns the escaped path of the object holding a sector, or of
+// the bucket if id is nil.
+func (ob *objectBackend) objectPath(id []byte) string {
+	path := "/" + s3Escape(ob.bucket)
+	if id == nil {
+		return path
+	}
+	for _, segment := range strings.Split(ob.prefix+"/"+string(id), "/") {
+		path += "/" + s3Escape(segment)
+	}
+	return path
+}
+
+// do performs a signed request against the store and returns the response if
+// it has one of the expected status codes.
+func (ob *objectBackend) do(method string, id []byte, body []byte, header http.Header, expected ...int) (*http.Response, error) {
+	path := ob.objectPath(id)
+	req, err := http.NewRequest(method, ob.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	ob.sign(req, path, body)
+
+	resp, err := objectStoreClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+	return nil, fmt.Errorf("object store returned %v: %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+// sign adds an AWS Signature Version 4 authorization header to a request.
+// Only the host, content hash, and date headers are signed.
+func (ob *objectBackend) sign(req *http.Request, path string, body []byte) {
+	now := ob.now().UTC()
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("X-Amz-Date", timestamp)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		"", // no query string
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + hex.EncodeToString(payloadHash[:]),
+		"x-amz-date:" + timestamp,
+		"",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + ob.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+ob.secretKey), date)
+	key = hmacSHA256(key, ob.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+ob.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// checkBucket checks that the bucket of the storage folder exists and that
+// the credentials grant access to it.
+func (ob *objectBackend) checkBucket() error {
+	resp, err := ob.do("HEAD", nil, nil, nil, http.StatusOK)
+	if err != nil {
+		return errors.New("could not reach object store bucket: " + err.Error())
+	}
+	return resp.Body.Close()
+}
+
+// readSector fetches a sector from the store. If parts is greater than one,
+// the sector is fetched with that many ranged requests at once.
+func (ob *objectBackend) readSector(id []byte, parts int) ([]byte, error) {
+	if parts <= 1 {
+		resp, err := ob.do("GET", id, nil, nil, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(modules.SectorSize)+1))
+		if err != nil {
+			return nil, err
+		} else if uint64(len(data)) != modules.SectorSize {
+			return nil, errObjectStoreSectorSize
+		}
+		return data, nil
+	}
+
+	data := make([]byte, modules.SectorSize)
+	partSize := (modules.SectorSize + uint64(parts) - 1) / uint64(parts)
+	errs := make([]error, parts)
+	var wg sync.WaitGroup
+	for i := 0; i < parts; i++ {
+		start := uint64(i) * partSize
+		end := start + partSize
+		if end > modules.SectorSize {
+			end = modules.SectorSize
+		}
+		if start >= end {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, part []byte, start, end uint64) {
+			defer wg.Done()
+			errs[i] = ob.readRange(id, part, start, end)
+		}(i, data[start:end], start, end)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// readRange fetches the bytes [start, end) of a sector into part.
+func (ob *objectBackend) readRange(id []byte, part []byte, start, end uint64) error {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", start, end-1)}}
+	resp, err := ob.do("GET", id, nil, header, http.StatusPartialContent)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadFull(resp.Body, part)
+	return err
+}
+
+// removeSector deletes a sector from the store.
+func (ob *objectBackend) removeSector(id []byte) error {
+	resp, err := ob.do("DELETE", id, nil, nil, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// writeSector uploads a sector to the store.
+func (ob *objectBackend) writeSector(id []byte, data []byte) error {
+	resp, err := ob.do("PUT", id, data, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes a segment of an object path the way that AWS Signature
+// Version 4 requires: every byte other than letters, digits, '-', '.', '_',
+// and '~' is percent-encoded.
+func s3Escape(segment string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
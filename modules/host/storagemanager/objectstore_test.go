@@ -0,0 +1,177 @@
+package storagemanager
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// fakeObjectStore is a minimal S3-compatible object store that holds objects
+// in memory.
+type fakeObjectStore struct {
+	bucket  string
+	objects map[string][]byte
+	ranged  int // number of ranged reads served
+	mu      sync.Mutex
+}
+
+// ServeHTTP implements http.Handler.
+func (fos *fakeObjectStore) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	fos.mu.Lock()
+	defer fos.mu.Unlock()
+
+	if req.URL.Path == "/"+fos.bucket {
+		if req.Method != "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if !strings.HasPrefix(req.URL.Path, "/"+fos.bucket+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(req.URL.Path, "/"+fos.bucket+"/")
+	switch req.Method {
+	case "PUT":
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fos.objects[key] = data
+	case "DELETE":
+		delete(fos.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case "GET":
+		data, ok := fos.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r := req.Header.Get("Range"); r != "" {
+			var start, end int
+			if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil || end >= len(data) || start > end {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			fos.ranged++
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start : end+1])
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// TestObjectBackend checks that sectors can be written to, read from, and
+// removed from an object store.
+func TestObjectBackend(t *testing.T) {
+	store := &fakeObjectStore{bucket: "sia", objects: make(map[string][]byte)}
+	server := httptest.NewServer(store)
+	defer server.Close()
+	os.Setenv("AWS_ACCESS_KEY_ID", "access")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	path := "s3+http://" + strings.TrimPrefix(server.URL, "http://") + "/sia/host?region=eu-west-1"
+	if !isObjectStorePath(path) {
+		t.Fatal("object store path was not recognized")
+	}
+	ob, err := newObjectBackend(path, "0102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ob.region != "eu-west-1" || ob.prefix != "host/0102" {
+		t.Fatal("path was parsed incorrectly:", ob.region, ob.prefix)
+	}
+	if err := ob.checkBucket(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write a sector and read it back, both whole and in parts.
+	id := []byte("sector")
+	data, err := crypto.RandBytes(int(modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.writeSector(id, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.objects["host/0102/sector"]; !ok {
+		t.Fatal("sector was not stored under the folder's prefix")
+	}
+	read, err := ob.readSector(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Fatal("sector was read incorrectly")
+	}
+	read, err = ob.readSector(id, objectStoreProofReadParts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Fatal("sector was read incorrectly in parts")
+	}
+	if store.ranged != objectStoreProofReadParts {
+		t.Fatal("expected", objectStoreProofReadParts, "ranged reads, got", store.ranged)
+	}
+
+	// Remove the sector.
+	if err := ob.removeSector(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ob.readSector(id, 1); err == nil {
+		t.Fatal("removed sector could still be read")
+	}
+
+	// A missing bucket is reported when the folder is added.
+	ob, err = newObjectBackend("s3+http://"+strings.TrimPrefix(server.URL, "http://")+"/other", "0102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.checkBucket(); err == nil {
+		t.Fatal("missing bucket was not reported")
+	}
+
+	// Credentials are required.
+	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	if _, err := newObjectBackend(path, "0102"); err != errObjectStoreCredentials {
+		t.Fatal("expected errObjectStoreCredentials, got", err)
+	}
+}
+
+// TestS3Escape checks that object paths are escaped as required by request
+// signing.
+func TestS3Escape(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"abc-XYZ_0.9~", "abc-XYZ_0.9~"},
+		{"a b", "a%20b"},
+		{"a+b=c", "a%2Bb%3Dc"},
+		{"\xff", "%FF"},
+	}
+	for _, test := range tests {
+		if out := s3Escape(test.in); out != test.out {
+			t.Errorf("s3Escape(%q): expected %q, got %q", test.in, test.out, out)
+		}
+	}
+}
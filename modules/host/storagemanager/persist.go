@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/persist"
@@ -61,7 +62,7 @@ func (sm *StorageManager) initDB() error {
 // manager.
 func (sm *StorageManager) load() error {
 	p := new(persistence)
-	err := sm.dependencies.loadFile(persistMetadata, p, filepath.Join(sm.persistDir, settingsFile))
+	err := sm.dependencies.loadFile(persistMetadata, p, filepath.Join(sm.metadataDir, settingsFile))
 	if os.IsNotExist(err) {
 		// There is no host.json file, set up sane defaults.
 		return sm.establishDefaults()
@@ -76,10 +77,70 @@ func (sm *StorageManager) load() error {
 
 // save stores all of the persistent data of the storage manager to disk.
 func (sm *StorageManager) save() error {
-	return persist.SaveFile(persistMetadata, sm.persistData(), filepath.Join(sm.persistDir, settingsFile))
+	err := persist.SaveFile(persistMetadata, sm.persistData(), filepath.Join(sm.metadataDir, settingsFile))
+	if err != nil {
+		sm.markFailed(err)
+	}
+	return err
 }
 
-// save stores all of the persistent data of the storage manager to disk.
+// saveSync stores all of the persistent data of the storage manager to disk,
+// and then syncs the file.
 func (sm *StorageManager) saveSync() error {
-	return persist.SaveFileSync(persistMetadata, sm.persistData(), filepath.Join(sm.persistDir, settingsFile))
+	err := persist.SaveFileSync(persistMetadata, sm.persistData(), filepath.Join(sm.metadataDir, settingsFile))
+	if err != nil {
+		sm.markFailed(err)
+	}
+	return err
+}
+
+// failureMarkerDirs returns the directories that the failure marker is
+// written to. The marker is written to both the metadata directory and the
+// persist directory, because the metadata directory may be on the device
+// that failed.
+func (sm *StorageManager) failureMarkerDirs() []string {
+	if sm.metadataDir == sm.persistDir {
+		return []string{sm.persistDir}
+	}
+	return []string{sm.metadataDir, sm.persistDir}
+}
+
+// checkFailureMarker returns errPriorFailure if a failure marker was left
+// behind by a previous run.
+func (sm *StorageManager) checkFailureMarker() error {
+	for _, dir := range sm.failureMarkerDirs() {
+		markerPath := filepath.Join(dir, failureMarkerFile)
+		marker, err := sm.dependencies.readFile(markerPath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		sm.log.Printf("Found failure marker %v: %s", markerPath, marker)
+		return errPriorFailure
+	}
+	return nil
+}
+
+// markFailed is called when the metadata of the storage manager could not be
+// written to disk. The in-memory state can no longer be trusted to match the
+// state on disk, so all further changes are refused and a failure marker is
+// written so that the storage manager does not start again until the disk
+// has been checked.
+func (sm *StorageManager) markFailed(err error) {
+	if sm.failed != nil {
+		return
+	}
+	sm.failed = err
+	close(sm.failure)
+	sm.log.Println("CRITICAL: unable to write storage manager metadata, refusing further changes:", err)
+
+	marker := []byte(time.Now().Format(time.RFC3339) + ": " + err.Error() + "\n")
+	for _, dir := range sm.failureMarkerDirs() {
+		markerPath := filepath.Join(dir, failureMarkerFile)
+		writeErr := sm.dependencies.writeFile(markerPath, marker, 0600)
+		if writeErr != nil {
+			sm.log.Println("Unable to write failure marker", markerPath, writeErr)
+		}
+	}
 }
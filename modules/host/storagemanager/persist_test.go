@@ -0,0 +1,84 @@
+package storagemanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestMetadataDirFailure checks that the storage manager keeps its metadata
+// in a separate directory when asked to, and that losing that directory
+// leaves the storage manager disabled rather than crashing it.
+func TestMetadataDirFailure(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	testdir := build.TempDir(modules.StorageManagerDir, "TestMetadataDirFailure")
+	persistDir := filepath.Join(testdir, modules.StorageManagerDir)
+	metadataDir := filepath.Join(testdir, "metadata")
+	sm, err := NewWithMetadataDir(persistDir, metadataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range []string{dbFilename, settingsFile} {
+		if _, err := os.Stat(filepath.Join(metadataDir, filename)); err != nil {
+			t.Fatal("metadata file is not in the metadata dir:", err)
+		}
+		if _, err := os.Stat(filepath.Join(persistDir, filename)); !os.IsNotExist(err) {
+			t.Fatal("metadata file was written to the persist dir:", filename)
+		}
+	}
+	folder := filepath.Join(testdir, "folder")
+	if err := os.Mkdir(folder, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddStorageFolder(folder, minimumStorageFolderSize, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove the metadata dir, simulating the loss of the device it is on.
+	if err := os.RemoveAll(metadataDir); err != nil {
+		t.Fatal(err)
+	}
+	root, data, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddSector(root, types.BlockHeight(10), data); err == nil {
+		t.Fatal("sector was added without saving the metadata")
+	}
+	select {
+	case <-sm.Failed():
+	default:
+		t.Fatal("failure was not signalled")
+	}
+	if err := sm.AddSector(root, types.BlockHeight(10), data); err != errStorageManagerFailed {
+		t.Fatal("expected errStorageManagerFailed, got", err)
+	}
+	if err := sm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The storage manager should refuse to start until the failure marker is
+	// removed.
+	if err := os.MkdirAll(metadataDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWithMetadataDir(persistDir, metadataDir); err != errPriorFailure {
+		t.Fatal("expected errPriorFailure, got", err)
+	}
+	if err := os.Remove(filepath.Join(persistDir, failureMarkerFile)); err != nil {
+		t.Fatal(err)
+	}
+	sm, err = NewWithMetadataDir(persistDir, metadataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
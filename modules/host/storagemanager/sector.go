@@ -3,11 +3,8 @@ package storagemanager
 import (
 	"bytes"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -128,8 +125,15 @@ func (sm *StorageManager) sectorID(sectorRootBytes []byte) []byte {
 // AddSector will add a data sector to the host, correctly selecting the
 // storage folder in which the sector belongs.
 func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.BlockHeight, sectorData []byte) error {
+	if err := sm.io.acquire(ioUpload); err != nil {
+		return err
+	}
+	defer sm.io.release()
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	// Check that there is enough room for the sector in at least one storage
 	// folder - check will also guarantee that there is at least one storage folder.
@@ -193,20 +197,24 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 		potentialFolders := sm.storageFolders
 		emptiestFolder, emptiestIndex := emptiestStorageFolder(potentialFolders)
 		for emptiestFolder != nil {
-			sectorPath := filepath.Join(sm.persistDir, emptiestFolder.uidString(), string(sectorKey))
-			err := sm.dependencies.writeFile(sectorPath, sectorData, 0400)
+			backend, err := sm.folderBackend(emptiestFolder.UID)
+			if err == nil {
+				err = backend.writeSector(sectorKey, sectorData)
+			}
 			if err != nil {
 				// Log the error.
 				sm.log.Println("Unable to accept sector", err, "into folder", emptiestFolder.uidString())
 
 				// Indicate to the user that the storage folder is having write
 				// trouble.
-				emptiestFolder.FailedWrites++
+				sm.failedWrite(emptiestFolder, err)
 
 				// Remove the attempted write - an an incomplete write can
 				// leave a partial file on disk. Error is not checked, we
 				// already know the disk is having trouble.
-				_ = sm.dependencies.removeFile(sectorPath)
+				if backend != nil {
+					_ = backend.removeSector(sectorKey)
+				}
 
 				// Remove the failed folder from the list of folders that can
 				// be tried.
@@ -248,6 +256,9 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 func (sm *StorageManager) AddSectorBatch(sectorRoots []crypto.Hash, expiryHeight types.BlockHeight) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	err := sm.db.Update(func(tx *bolt.Tx) error {
 		for _, root := range sectorRoots {
@@ -295,44 +306,123 @@ func (sm *StorageManager) AddSectorBatch(sectorRoots []crypto.Hash, expiryHeight
 	return sm.save()
 }
 
+// HasSector indicates whether the sector is being stored by the storage
+// manager. Only the sector usage database is consulted, the sector itself is
+// not read from disk.
+func (sm *StorageManager) HasSector(sectorRoot crypto.Hash) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var exists bool
+	_ = sm.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(bucketSectorUsage).Get(sm.sectorID(sectorRoot[:])) != nil
+		return nil
+	})
+	return exists
+}
+
 // ReadSector will pull a sector from disk into memory.
-func (sm *StorageManager) ReadSector(sectorRoot crypto.Hash) (sectorBytes []byte, err error) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+func (sm *StorageManager) ReadSector(sectorRoot crypto.Hash) ([]byte, error) {
+	return sm.managedReadSector(sectorRoot, ioDownload)
+}
+
+// ReadSectorPriority will pull a sector from disk into memory, ahead of any
+// downloads that are waiting for the same storage folder. It is used to build
+// storage proofs.
+func (sm *StorageManager) ReadSectorPriority(sectorRoot crypto.Hash) ([]byte, error) {
+	return sm.managedReadSector(sectorRoot, ioProof)
+}
 
+// managedSectorFolder returns the UID of the storage folder holding a sector.
+func (sm *StorageManager) managedSectorFolder(sectorKey []byte) (uid []byte, err error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 	err = sm.db.View(func(tx *bolt.Tx) error {
-		bsu := tx.Bucket(bucketSectorUsage)
-		sectorKey := sm.sectorID(sectorRoot[:])
-		sectorUsageBytes := bsu.Get(sectorKey)
+		sectorUsageBytes := tx.Bucket(bucketSectorUsage).Get(sectorKey)
 		if sectorUsageBytes == nil {
 			return ErrSectorNotFound
 		}
 		var su sectorUsage
-		err = json.Unmarshal(sectorUsageBytes, &su)
-		if err != nil {
-			return err
-		}
-
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(su.StorageFolder), string(sectorKey))
-		sectorBytes, err = ioutil.ReadFile(sectorPath)
-		sf := sm.storageFolder(su.StorageFolder)
+		err := json.Unmarshal(sectorUsageBytes, &su)
 		if err != nil {
-			// Mark the read failure in the sector.
-			sf.FailedReads++
 			return err
 		}
-		sf.SuccessfulReads++
+		uid = su.StorageFolder
 		return nil
 	})
 	return
 }
 
+// managedReadSector pulls a sector from disk into memory. If the sector is
+// moved to another storage folder while it is being read, the read is tried
+// again in the new storage folder.
+func (sm *StorageManager) managedReadSector(sectorRoot crypto.Hash, class ioClass) ([]byte, error) {
+	sectorKey := sm.sectorID(sectorRoot[:])
+	uid, err := sm.managedSectorFolder(sectorKey)
+	if err != nil {
+		return nil, err
+	}
+	sectorBytes, err := sm.managedReadFolderSector(uid, sectorKey, class)
+	if err != nil {
+		newUID, lookupErr := sm.managedSectorFolder(sectorKey)
+		if lookupErr == nil && !bytes.Equal(newUID, uid) {
+			sectorBytes, err = sm.managedReadFolderSector(newUID, sectorKey, class)
+		}
+	}
+	return sectorBytes, err
+}
+
+// managedReadFolderSector waits for its turn in the I/O schedule of a storage
+// folder, and then reads a sector from the folder. The storage manager is not
+// locked during the read, so that reads from other storage folders can
+// proceed.
+func (sm *StorageManager) managedReadFolderSector(uid []byte, sectorKey []byte, class ioClass) ([]byte, error) {
+	scheduler := sm.folderIO(uid)
+	if err := scheduler.acquire(class); err != nil {
+		return nil, err
+	}
+	sm.mu.RLock()
+	backend, err := sm.folderBackend(uid)
+	sm.mu.RUnlock()
+
+	// Sectors that are needed for storage proofs are fetched in parts at
+	// once from backends that support it.
+	parts := 1
+	if class == ioProof {
+		parts = objectStoreProofReadParts
+	}
+	var sectorBytes []byte
+	if err == nil {
+		sectorBytes, err = backend.readSector(sectorKey, parts)
+	}
+	scheduler.release()
+
+	// Record the outcome of the read, unless the storage folder has been
+	// removed in the meantime.
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sf := sm.storageFolder(uid)
+	if err != nil {
+		if sf != nil {
+			sm.failedRead(sf, err)
+		}
+		return nil, err
+	}
+	if sf != nil {
+		sf.SuccessfulReads++
+	}
+	return sectorBytes, nil
+}
+
 // RemoveSector will remove a sector from the host at the given expiry height.
 // If the provided sector does not have an expiration at the given height, an
 // error will be thrown.
 func (sm *StorageManager) RemoveSector(sectorRoot crypto.Hash, expiryHeight types.BlockHeight) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	return sm.db.Update(func(tx *bolt.Tx) error {
 		// Grab the existing sector usage information from the database.
@@ -393,11 +483,13 @@ func (sm *StorageManager) RemoveSector(sectorRoot crypto.Hash, expiryHeight type
 
 		// Remove the sector from the physical disk and update the storage
 		// folder metadata.
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(usage.StorageFolder), string(sectorKey))
-		err = sm.dependencies.removeFile(sectorPath)
+		backend, err := sm.folderBackend(usage.StorageFolder)
+		if err == nil {
+			err = backend.removeSector(sectorKey)
+		}
 		if err != nil {
 			// Indicate that the storage folder is having write troubles.
-			folder.FailedWrites++
+			sm.failedWrite(folder, err)
 			return err
 		}
 		folder.SizeRemaining += modules.SectorSize
@@ -426,6 +518,9 @@ func (sm *StorageManager) DeleteSector(sectorRoot crypto.Hash) error {
 	if sm.closed {
 		return errStorageManagerClosed
 	}
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	return sm.db.Update(func(tx *bolt.Tx) error {
 		// Check that the sector exists in the database.
@@ -452,11 +547,13 @@ func (sm *StorageManager) DeleteSector(sectorRoot crypto.Hash) error {
 		// Remove the sector from the physical disk and update the storage
 		// folder metadata. The file is removed from disk as early as possible
 		// to prevent potential errors from stopping the delete.
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(usage.StorageFolder), string(sectorKey))
-		err = sm.dependencies.removeFile(sectorPath)
+		backend, err := sm.folderBackend(usage.StorageFolder)
+		if err == nil {
+			err = backend.removeSector(sectorKey)
+		}
 		if err != nil {
 			// Indicate that the storage folder is having write troubles.
-			folder.FailedWrites++
+			sm.failedWrite(folder, err)
 			return err
 		}
 		folder.SizeRemaining += modules.SectorSize
@@ -20,7 +20,7 @@ func TestMaxVirtualSectors(t *testing.T) {
 	defer smt.Close()
 
 	// Add a storage folder to receive a sector.
-	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -63,7 +63,7 @@ func TestBadSectorAdd(t *testing.T) {
 	}
 	defer smt.Close()
 	// Add a storage folder to receive a sector.
-	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -82,3 +82,48 @@ func TestBadSectorAdd(t *testing.T) {
 	_ = smt.sm.AddSector(sectorRoot, 1, sectorData[:1])
 	t.Fatal("panic not thrown")
 }
+
+// TestHasSector checks that HasSector reports the sectors that have been added
+// to the storage manager.
+func TestHasSector(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestHasSector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+
+	// Add a storage folder to receive a sector.
+	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sectorRoot, sectorData, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smt.sm.HasSector(sectorRoot) {
+		t.Fatal("HasSector reports a sector that has not been added")
+	}
+	err = smt.sm.AddSector(sectorRoot, 1, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !smt.sm.HasSector(sectorRoot) {
+		t.Fatal("HasSector does not report an added sector")
+	}
+
+	// Removing the only instance of the sector should cause HasSector to
+	// return false again.
+	err = smt.sm.RemoveSector(sectorRoot, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smt.sm.HasSector(sectorRoot) {
+		t.Fatal("HasSector reports a sector that has been removed")
+	}
+}
@@ -48,10 +48,9 @@ package storagemanager
 // buckets relies on fancier, less used features in the boltdb dependency,
 // which carries a higher error risk.
 
-// TODO: Need to add some command to 'siad' that will correctly repoint a
-// storage folder to a new mountpoint. As best I can tell, this needs to happen
-// while siad is not running. Either that, or 'siac' needs to do the whole
-// shutdown thing itself? Still unclear.
+// Local storage folders hold a file with the UID of the storage folder, so
+// that a storage folder which has been moved to a new mountpoint can be
+// recognized and relinked. See folderid.go.
 
 import (
 	"bytes"
@@ -59,7 +58,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -158,10 +156,16 @@ var (
 // Statistics are kept on the integrity of reads and writes. Ideally, the
 // filesystem is never returning errors, but if errors are being returned they
 // will be tracked and can be reported to the user.
+//
+// 'missing' is set if the folder at 'Path' did not hold the storage folder
+// when the storage manager started. No sectors are placed into a missing
+// storage folder until it has been relinked.
 type storageFolder struct {
 	Path string
 	UID  []byte
 
+	missing bool
+
 	Size          uint64 // bytes
 	SizeRemaining uint64 // bytes
 
@@ -188,6 +192,11 @@ func emptiestStorageFolder(sfs []*storageFolder) (*storageFolder, int) {
 		if sf.SizeRemaining < modules.SectorSize || sf.Size < sf.SizeRemaining {
 			continue
 		}
+		// Sectors written to a missing storage folder would not be found
+		// after the storage folder is relinked.
+		if sf.missing {
+			continue
+		}
 		winner = true // at least one storage folder has enough space for a new sector.
 
 		// Check this storage folder against the current winning storage folder's utilization.
@@ -206,8 +215,9 @@ func emptiestStorageFolder(sfs []*storageFolder) (*storageFolder, int) {
 }
 
 // offloadStorageFolder takes sectors in a storage folder and moves them to
-// another storage folder.
-func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dataToOffload uint64) error {
+// another storage folder. The fraction of 'dataToOffload' that has been moved
+// is reported to 'progress'.
+func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dataToOffload uint64, progress modules.ProgressFunc) error {
 	// The host is going to check every sector, using a different database tx
 	// for each sector. To be able to track progress, a starting point needs to
 	// be grabbed. This read grabs the starting point.
@@ -273,13 +283,16 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 			success := false
 			emptiestFolder, emptiestIndex := emptiestStorageFolder(availableFolders)
 			for emptiestFolder != nil {
-				oldSectorPath := filepath.Join(sm.persistDir, offloadFolder.uidString(), string(currentSectorID))
 				// Try reading the sector from disk.
-				sectorData, err := sm.dependencies.readFile(oldSectorPath)
+				oldBackend, err := sm.folderBackend(offloadFolder.UID)
+				var sectorData []byte
+				if err == nil {
+					sectorData, err = oldBackend.readSector(currentSectorID, 1)
+				}
 				if err != nil {
 					// Inidicate that the storage folder is having read
 					// troubles.
-					offloadFolder.FailedReads++
+					sm.failedRead(offloadFolder, err)
 
 					// Returning nil will move to the next sector. Though the
 					// current sector has failed to read, the host will keep
@@ -290,17 +303,21 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 				offloadFolder.SuccessfulReads++
 
 				// Try writing the sector to the emptiest storage folder.
-				newSectorPath := filepath.Join(sm.persistDir, emptiestFolder.uidString(), string(currentSectorID))
-				err = sm.dependencies.writeFile(newSectorPath, sectorData, 0700)
+				newBackend, err := sm.folderBackend(emptiestFolder.UID)
+				if err == nil {
+					err = newBackend.writeSector(currentSectorID, sectorData)
+				}
 				if err != nil {
 					// Indicate that the storage folder is having write
 					// troubles.
-					emptiestFolder.FailedWrites++
+					sm.failedWrite(emptiestFolder, err)
 
 					// After the failed write, try removing any garbage that
 					// may have gotten left behind. The error is not checked,
 					// as it is known that the disk is having write troubles.
-					_ = sm.dependencies.removeFile(newSectorPath)
+					if newBackend != nil {
+						_ = newBackend.removeSector(currentSectorID)
+					}
 
 					// Because the write failed, we should move on to the next
 					// storage folder, and remove the current storage folder
@@ -313,11 +330,11 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 				}
 				// Indicate that the storage folder is doing successful writes.
 				emptiestFolder.SuccessfulWrites++
-				err = sm.dependencies.removeFile(oldSectorPath)
+				err = oldBackend.removeSector(currentSectorID)
 				if err != nil {
 					// Indicate that the storage folder is having write
 					// troubles.
-					offloadFolder.FailedWrites++
+					sm.failedWrite(offloadFolder, err)
 				} else {
 					offloadFolder.SuccessfulWrites++
 				}
@@ -334,6 +351,7 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 			offloadFolder.SizeRemaining += modules.SectorSize
 			emptiestFolder.SizeRemaining -= modules.SectorSize
 			dataOffloaded += modules.SectorSize
+			progress.Report(float64(dataOffloaded) / float64(dataToOffload))
 
 			// Update the sector usage database to reflect the file movement.
 			// Because this cannot be done atomically, recovery tools are
@@ -383,8 +401,37 @@ func (sf *storageFolder) uidString() string {
 	return hex.EncodeToString(sf.UID)
 }
 
-// AddStorageFolder adds a storage folder to the host.
-func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
+// failedRead records a failed sector read in a storage folder, and announces
+// the failure.
+func (sm *StorageManager) failedRead(sf *storageFolder, err error) {
+	sf.FailedReads++
+	sm.events.Publish(modules.EventStorageFolderError, modules.StorageFolderErrorEvent{
+		Path:      sf.Path,
+		Operation: "read",
+		Error:     err.Error(),
+	})
+}
+
+// failedWrite records a failed sector write in a storage folder, and announces
+// the failure.
+func (sm *StorageManager) failedWrite(sf *storageFolder, err error) {
+	sf.FailedWrites++
+	sm.events.Publish(modules.EventStorageFolderError, modules.StorageFolderErrorEvent{
+		Path:      sf.Path,
+		Operation: "write",
+		Error:     err.Error(),
+	})
+}
+
+// SubscribeEvents returns a channel that receives an event for each sector
+// read or write that fails, and a function that ends the subscription.
+func (sm *StorageManager) SubscribeEvents() (<-chan modules.Event, func()) {
+	return sm.events.Subscribe()
+}
+
+// AddStorageFolder adds a storage folder to the host. Progress is reported
+// once the folder has been linked, and once it has been saved.
+func (sm *StorageManager) AddStorageFolder(path string, size uint64, progress modules.ProgressFunc) error {
 	// Lock the host for the duration of the add operation - it is important
 	// that the host not be manipulated while sectors are being moved around.
 	sm.mu.Lock()
@@ -396,6 +443,9 @@ func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
 	if sm.closed {
 		return errStorageManagerClosed
 	}
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	// Check that the maximum number of allowed storage folders has not been
 	// exceeded.
@@ -409,8 +459,8 @@ func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
 	if size < minimumStorageFolderSize {
 		return ErrSmallStorageFolder
 	}
-	// Check that the path is an absolute path.
-	if !filepath.IsAbs(path) {
+	// Check that the path is an absolute path or refers to an object store.
+	if !filepath.IsAbs(path) && !isObjectStorePath(path) {
 		if path == "" {
 			return ErrEmptyPath
 		}
@@ -422,14 +472,13 @@ func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
 			return ErrRepeatFolder
 		}
 	}
-
-	// Check that the folder being linked to both exists and is a folder.
-	pathInfo, err := os.Stat(path)
-	if err != nil {
-		return err
-	}
-	if !pathInfo.Mode().IsDir() {
-		return errStorageFolderNotFolder
+	// Check that the folder does not hold a storage folder that has been
+	// moved here from another path.
+	if !isObjectStorePath(path) {
+		uid, err := sm.readFolderID(path)
+		if err == nil && sm.storageFolder(uid) != nil {
+			return ErrFolderInUse
+		}
 	}
 
 	// Create a storage folder object.
@@ -444,7 +493,7 @@ func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
 	newSF.UID = make([]byte, storageFolderUIDSize)
 	for {
 		// Generate an attempt UID for the storage folder.
-		_, err = sm.dependencies.randRead(newSF.UID)
+		_, err := sm.dependencies.randRead(newSF.UID)
 		if err != nil {
 			return err
 		}
@@ -464,16 +513,26 @@ func (sm *StorageManager) AddStorageFolder(path string, size uint64) error {
 		}
 	}
 
-	// Symlink the path for the data to the UID location of the host.
-	symPath := filepath.Join(sm.persistDir, newSF.uidString())
-	err = sm.dependencies.symlink(path, symPath)
+	// Link the storage folder into the host, checking that it can hold
+	// sectors.
+	err := sm.linkStorageFolder(newSF)
 	if err != nil {
 		return err
 	}
+	err = sm.writeFolderID(newSF)
+	if err != nil {
+		return composeErrors(err, sm.unlinkStorageFolder(newSF))
+	}
+	progress.Report(0.5)
 
 	// Add the storage folder to the list of folders for the host.
 	sm.storageFolders = append(sm.storageFolders, newSF)
-	return sm.saveSync()
+	err = sm.saveSync()
+	if err != nil {
+		return err
+	}
+	progress.Report(1)
+	return nil
 }
 
 // ResetStorageFolderHealth will reset the read and write statistics for the
@@ -486,6 +545,9 @@ func (sm *StorageManager) ResetStorageFolderHealth(index int) error {
 	if sm.closed {
 		return errStorageManagerClosed
 	}
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	// Check that the input is valid.
 	if index >= len(sm.storageFolders) {
@@ -509,6 +571,9 @@ func (sm *StorageManager) RemoveStorageFolder(removalIndex int, force bool) erro
 	if sm.closed {
 		return errStorageManagerClosed
 	}
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	// Check that the removal folder exists, and create a shortcut to it.
 	if removalIndex >= len(sm.storageFolders) || removalIndex < 0 {
@@ -518,7 +583,7 @@ func (sm *StorageManager) RemoveStorageFolder(removalIndex int, force bool) erro
 
 	// Move all of the sectors in the storage folder to other storage folders.
 	usedSize := removalFolder.Size - removalFolder.SizeRemaining
-	offloadErr := sm.offloadStorageFolder(removalFolder, usedSize)
+	offloadErr := sm.offloadStorageFolder(removalFolder, usedSize, nil)
 	// If 'force' is set, we want to ignore 'ErrIncompleteOffload' and try to
 	// remove the storage folder anyway. For any other error, we want to halt
 	// and return the error.
@@ -531,14 +596,23 @@ func (sm *StorageManager) RemoveStorageFolder(removalIndex int, force bool) erro
 
 	// Remove the storage folder from the host and then save the host.
 	sm.storageFolders = append(sm.storageFolders[0:removalIndex], sm.storageFolders[removalIndex+1:]...)
-	removeErr := sm.dependencies.removeFile(filepath.Join(sm.persistDir, removalFolder.uidString()))
+	removeErr := sm.unlinkStorageFolder(removalFolder)
+	sm.folderIOMu.Lock()
+	delete(sm.folderIOs, string(removalFolder.UID))
+	sm.folderIOMu.Unlock()
+	if !isObjectStorePath(removalFolder.Path) {
+		// The folder no longer holds a storage folder, so the file
+		// identifying it is removed. Failing to remove it is not an error.
+		_ = sm.dependencies.removeFile(folderIDPath(removalFolder.Path))
+	}
 	saveErr := sm.saveSync()
 	return composeErrors(saveErr, removeErr)
 }
 
 // ResizeStorageFolder changes the amount of disk space that is going to be
-// allocated to a storage folder.
-func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize uint64) error {
+// allocated to a storage folder. When the folder shrinks, the progress of
+// moving its sectors to other folders is reported to 'progress'.
+func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize uint64, progress modules.ProgressFunc) error {
 	// Lock the host for the duration of the resize operation - it is important
 	// that the host not be manipulated while sectors are being moved around.
 	sm.mu.Lock()
@@ -550,6 +624,9 @@ func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize ui
 	if sm.closed {
 		return errStorageManagerClosed
 	}
+	if sm.failed != nil {
+		return errStorageManagerFailed
+	}
 
 	// Check that the inputs are valid.
 	if storageFolderIndex >= len(sm.storageFolders) || storageFolderIndex < 0 {
@@ -573,13 +650,17 @@ func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize ui
 	if resizeFolderSizeConsumed <= newSize {
 		resizeFolder.SizeRemaining = newSize - resizeFolderSizeConsumed
 		resizeFolder.Size = newSize
-		return sm.saveSync()
+		err := sm.saveSync()
+		if err == nil {
+			progress.Report(1)
+		}
+		return err
 	}
 
 	// Calculate the number of sectors that need to be offloaded from the
 	// storage folder.
 	offloadSize := resizeFolderSizeConsumed - newSize
-	offloadErr := sm.offloadStorageFolder(resizeFolder, offloadSize)
+	offloadErr := sm.offloadStorageFolder(resizeFolder, offloadSize, progress)
 	if offloadErr == ErrIncompleteOffload {
 		// Offloading has not fully succeeded, but may have partially
 		// succeeded. To prevent new sectors from being added to the storage
@@ -607,6 +688,7 @@ func (sm *StorageManager) StorageFolders() (sfms []modules.StorageFolderMetadata
 			Capacity:          sf.Size,
 			CapacityRemaining: sf.SizeRemaining,
 			Path:              sf.Path,
+			Missing:           sf.missing,
 
 			FailedReads:      sf.FailedReads,
 			FailedWrites:     sf.FailedWrites,
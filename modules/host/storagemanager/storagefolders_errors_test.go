@@ -26,7 +26,7 @@ func TestClosedStorageManagerOperations(t *testing.T) {
 	}
 	defer smt.Close()
 
-	err = smt.sm.AddStorageFolder(filepath.Join(smt.persistDir, modules.StorageManagerDir), minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(filepath.Join(smt.persistDir, modules.StorageManagerDir), minimumStorageFolderSize, nil)
 	if err != errStorageManagerClosed {
 		t.Fatal("expected errStorageManagerClosed:", err)
 	}
@@ -34,7 +34,7 @@ func TestClosedStorageManagerOperations(t *testing.T) {
 	if err != errStorageManagerClosed {
 		t.Fatal("expected errStorageManagerClosed:", err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize, nil)
 	if err != errStorageManagerClosed {
 		t.Fatal("expected errStorageManagerClosed:", err)
 	}
@@ -79,11 +79,11 @@ func TestAddFolderNoRand(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	smt.sm, err = newStorageManager(faultyRand{}, filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	smt.sm, err = newStorageManager(faultyRand{}, filepath.Join(smt.persistDir, modules.StorageManagerDir), filepath.Join(smt.persistDir, modules.StorageManagerDir))
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(filepath.Join(smt.persistDir, modules.StorageManagerDir), minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(filepath.Join(smt.persistDir, modules.StorageManagerDir), minimumStorageFolderSize, nil)
 	if err != errMockBadRand {
 		t.Fatal(err)
 	}
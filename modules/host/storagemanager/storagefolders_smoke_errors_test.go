@@ -104,7 +104,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 		t.Fatal(err)
 	}
 	ffs := new(faultyFS)
-	smt.sm, err = newStorageManager(ffs, filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	smt.sm, err = newStorageManager(ffs, filepath.Join(smt.persistDir, modules.StorageManagerDir), filepath.Join(smt.persistDir, modules.StorageManagerDir))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -116,7 +116,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize, nil)
 	if err != mockErrSymlink {
 		t.Fatal(err)
 	}
@@ -124,7 +124,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 	// Add storage folder one without errors, and then add a sector to the
 	// storage folder.
 	ffs.brokenSubstrings = nil
-	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -143,7 +143,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem - there should be one sector in the storage folder.
-	infos, err := ioutil.ReadDir(storageFolderOne)
+	infos, err := readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -164,7 +164,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 		t.Fatal("failed writes counter is not incrementing properly")
 	}
 	// Check the filesystem - sector should still be in the storage folder.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -181,7 +181,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderTwo, minimumStorageFolderSize*3)
+	err = smt.sm.AddStorageFolder(storageFolderTwo, minimumStorageFolderSize*3, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,14 +209,14 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - there should be one sector in the storage folder,
 	// and none in storage folder two.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 1 {
 		t.Fatal("expecting at least one sector in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -247,14 +247,14 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - there should be one sector in the storage folder,
 	// and none in storage folder two.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 1 {
 		t.Fatal("expecting at least one sector in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -284,7 +284,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 		t.Fatal("storage folder was not removed correctly")
 	}
 	// Check the filesystem - there should be no sectors in storage folder two.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -301,7 +301,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderThree, minimumStorageFolderSize+modules.SectorSize)
+	err = smt.sm.AddStorageFolder(storageFolderThree, minimumStorageFolderSize+modules.SectorSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -335,14 +335,14 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - storage folder one is having disk issues and
 	// should have no sectors. Storage folder two should be full.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	infos, err = readSectorDir(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 0 {
 		t.Fatal("expecting zero sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -367,14 +367,14 @@ func TestStorageFolderTolerance(t *testing.T) {
 	}
 	// Check the filesystem - storage folder one is having disk issues and
 	// should have no sectors. Storage folder two should be full.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	infos, err = readSectorDir(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 0 {
 		t.Fatal("expecting zero sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -390,7 +390,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderFour, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(storageFolderFour, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -400,7 +400,7 @@ func TestStorageFolderTolerance(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*2)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*2, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -413,21 +413,21 @@ func TestStorageFolderTolerance(t *testing.T) {
 	// Check the filesystem - storageFolderTwo should have
 	// minimumStorageFolderSize*2 worth of sectors, and storageFolderFour
 	// should have minimumStorageFolderSize worth of sectors.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	infos, err = readSectorDir(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 0 {
 		t.Fatal("expecting zero sectors in storage folder three")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != int(numSectors)-int(minimumStorageFolderSize/modules.SectorSize) {
 		t.Fatal("expecting", numSectors, "sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderFour)
+	infos, err = readSectorDir(storageFolderFour)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -439,11 +439,11 @@ func TestStorageFolderTolerance(t *testing.T) {
 	// to storageFolderFour, but then trying to remove a bunch of sectors from
 	// storageFolderTwo. There is enough room on storage folder 3 to make the
 	// operation successful, but it is having disk troubles.
-	err = smt.sm.ResizeStorageFolder(2, minimumStorageFolderSize+modules.SectorSize)
+	err = smt.sm.ResizeStorageFolder(2, minimumStorageFolderSize+modules.SectorSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize, nil)
 	if err != ErrIncompleteOffload {
 		t.Fatal(err)
 	}
@@ -463,21 +463,21 @@ func TestStorageFolderTolerance(t *testing.T) {
 	// Check the filesystem - there should be one less sector in
 	// storageFolderTwo from the previous check, and one more sector in
 	// storageFolderFour.
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	infos, err = readSectorDir(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 0 {
 		t.Fatal("expecting zero sectors in storage folder three")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != int(numSectors)-int(minimumStorageFolderSize/modules.SectorSize)-1 {
 		t.Fatal("expecting", numSectors, "sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderFour)
+	infos, err = readSectorDir(storageFolderFour)
 	if err != nil {
 		t.Fatal(err)
 	}
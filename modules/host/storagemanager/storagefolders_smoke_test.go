@@ -94,17 +94,17 @@ func TestStorageFolderUsage(t *testing.T) {
 	storageFolderOne := filepath.Join(smt.persistDir, "manager drive 1")
 	// Try using a file size that is too small. Because a filesize check is
 	// quicker than a disk check, the filesize check should come first.
-	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize-1)
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize-1, nil)
 	if err != ErrSmallStorageFolder {
 		t.Fatal("expecting ErrSmallStorageFolder:", err)
 	}
 	// Try a file size that is too large.
-	err = smt.sm.AddStorageFolder(storageFolderOne, maximumStorageFolderSize+1)
+	err = smt.sm.AddStorageFolder(storageFolderOne, maximumStorageFolderSize+1, nil)
 	if err != ErrLargeStorageFolder {
 		t.Fatal("expecting ErrLargeStorageFolder:", err)
 	}
 	// Try linking to a storage folder that does not exist.
-	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize, nil)
 	if err == nil {
 		t.Fatal("should not be able to link to a storage folder which does not exist")
 	}
@@ -113,7 +113,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize, nil)
 	if err != errStorageFolderNotFolder {
 		t.Fatal(err)
 	}
@@ -126,7 +126,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -149,7 +149,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderTwo, minimumStorageFolderSize*2)
+	err = smt.sm.AddStorageFolder(storageFolderTwo, minimumStorageFolderSize*2, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -276,27 +276,27 @@ func TestStorageFolderUsage(t *testing.T) {
 
 	// Try to resize the storage folder. While resizing the storage folder, try
 	// a bunch of invalid resize calls.
-	err = smt.sm.ResizeStorageFolder(1, minimumStorageFolderSize-1)
+	err = smt.sm.ResizeStorageFolder(1, minimumStorageFolderSize-1, nil)
 	if err != errBadStorageFolderIndex {
 		t.Error(err)
 	}
-	err = smt.sm.ResizeStorageFolder(-1, minimumStorageFolderSize-1)
+	err = smt.sm.ResizeStorageFolder(-1, minimumStorageFolderSize-1, nil)
 	if err != errBadStorageFolderIndex {
 		t.Error(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize-1)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize-1, nil)
 	if err != ErrSmallStorageFolder {
 		t.Error(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, maximumStorageFolderSize+1)
+	err = smt.sm.ResizeStorageFolder(0, maximumStorageFolderSize+1, nil)
 	if err != ErrLargeStorageFolder {
 		t.Error(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*10)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*10, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*10)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*10, nil)
 	if err != ErrNoResize {
 		t.Fatal(err)
 	}
@@ -308,7 +308,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	}
 	// Manager should be able to support having uneven storage sizes.
 	oddStorageSize := (minimumStorageFolderSize) + modules.SectorSize*3 + 3
-	err = smt.sm.ResizeStorageFolder(0, oddStorageSize)
+	err = smt.sm.ResizeStorageFolder(0, oddStorageSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -351,7 +351,7 @@ func TestStorageFolderUsage(t *testing.T) {
 		sectorUsageMap[sectorRoot] = []types.BlockHeight{86 + types.BlockHeight(i)}
 	}
 	oldSize := smt.sm.storageFolders[0].Size
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize, nil)
 	if err != ErrIncompleteOffload {
 		t.Fatal(err)
 	}
@@ -374,7 +374,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	// Add a second folder, and add a sector to that folder. There should be
 	// enough space remaining in the first folder for the removal to be
 	// successful.
-	err = smt.sm.AddStorageFolder(storageFolderTwo, minimumStorageFolderSize*2)
+	err = smt.sm.AddStorageFolder(storageFolderTwo, minimumStorageFolderSize*2, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -401,14 +401,14 @@ func TestStorageFolderUsage(t *testing.T) {
 	}
 	// Check the filesystem. The folder for storage folder 1 should have 10
 	// files, and the folder for storage folder 2 should have 1 file.
-	infos, err := ioutil.ReadDir(storageFolderOne)
+	infos, err := readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 10 {
 		t.Fatal("storage folder one should have 10 sectors in it")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -423,7 +423,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	totalStorage, remainingStorage = smt.sm.capacity()
 	prevStorage := totalStorage
 	usedStorage := totalStorage - remainingStorage
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -435,14 +435,14 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Error("total storage was not adjusted correctly after removing a storage folder")
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 8 {
 		t.Fatal("wrong number of sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if len(infos) != 3 {
 		t.Fatal("wrong number of sectors in storage folder two")
 	}
@@ -466,7 +466,7 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Error("total storage was not adjusted after removing a storage folder")
 	}
 	// Check that the filesystem seems correct.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -481,11 +481,11 @@ func TestStorageFolderUsage(t *testing.T) {
 	// Add the first storage folder, resize the second storage folder back down
 	// to minimum. Note that storageFolderOne now has an index of '1', and
 	// storageFolderTwo now has an index of '0'.
-	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -496,14 +496,14 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 8 {
 		t.Fatal("wrong number of sectors")
 	}
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -514,7 +514,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	// Add a bunch of sectors and repeat sectors at multiple colliding heights.
 	// Start by resizing the first storage folder so that there is enough room
 	// for the new sectors.
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*3)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*3, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -570,14 +570,14 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 16 {
 		t.Fatal("there should be 16 sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -631,11 +631,11 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check that the total number of sectors seen on disk is 20.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
-	infos2, err := ioutil.ReadDir(storageFolderTwo)
+	infos2, err := readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -687,11 +687,11 @@ func TestStorageFolderUsage(t *testing.T) {
 
 			// Check that the filesystem is housing the correct number of
 			// sectors.
-			infos, err = ioutil.ReadDir(storageFolderOne)
+			infos, err = readSectorDir(storageFolderOne)
 			if err != nil {
 				t.Fatal(err)
 			}
-			infos2, err = ioutil.ReadDir(storageFolderTwo)
+			infos2, err = readSectorDir(storageFolderTwo)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -739,7 +739,7 @@ func TestStorageFolderUsage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(storageFolderThree, minimumStorageFolderSize*2)
+	err = smt.sm.AddStorageFolder(storageFolderThree, minimumStorageFolderSize*2, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -787,21 +787,21 @@ func TestStorageFolderUsage(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Check the filesystem.
-	infos, err = ioutil.ReadDir(storageFolderOne)
+	infos, err = readSectorDir(storageFolderOne)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 8 {
 		t.Fatal("expecting 8 sectors in storage folder one")
 	}
-	infos, err = ioutil.ReadDir(storageFolderTwo)
+	infos, err = readSectorDir(storageFolderTwo)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(infos) != 24 {
 		t.Fatal("expecting 24 sectors in storage folder two")
 	}
-	infos, err = ioutil.ReadDir(storageFolderThree)
+	infos, err = readSectorDir(storageFolderThree)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -811,23 +811,23 @@ func TestStorageFolderUsage(t *testing.T) {
 
 	// Do some resizing, to cause sectors to be moved around. Every storage
 	// folder should have sectors that get moved off of it.
-	err = smt.sm.ResizeStorageFolder(1, minimumStorageFolderSize*6)
+	err = smt.sm.ResizeStorageFolder(1, minimumStorageFolderSize*6, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(2, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(2, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*6)
+	err = smt.sm.ResizeStorageFolder(0, minimumStorageFolderSize*6, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.ResizeStorageFolder(1, minimumStorageFolderSize)
+	err = smt.sm.ResizeStorageFolder(1, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -862,15 +862,15 @@ func TestStorageFolderUsage(t *testing.T) {
 
 			// Check that the filesystem is housing the correct number of
 			// sectors.
-			infos, err := ioutil.ReadDir(storageFolderOne)
+			infos, err := readSectorDir(storageFolderOne)
 			if err != nil {
 				t.Fatal(err)
 			}
-			infos2, err := ioutil.ReadDir(storageFolderTwo)
+			infos2, err := readSectorDir(storageFolderTwo)
 			if err != nil {
 				t.Fatal(err)
 			}
-			infos3, err := ioutil.ReadDir(storageFolderThree)
+			infos3, err := readSectorDir(storageFolderThree)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -352,11 +352,11 @@ func TestRepeatStorageFolderPath(t *testing.T) {
 	}
 	defer smt.Close()
 
-	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize)
+	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize, nil)
 	if err != ErrRepeatFolder {
 		t.Fatal("expected ErrRepeatFolder, got", err)
 	}
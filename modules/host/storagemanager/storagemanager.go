@@ -11,14 +11,16 @@ import (
 	"sync"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 )
 
 const (
 	// Names of the various persist files in the storage manager.
-	dbFilename   = "storagemanager.db"
-	logFile      = "storagemanager.log"
-	settingsFile = "storagemanager.json"
+	dbFilename        = "storagemanager.db"
+	failureMarkerFile = "storagemanager.failed"
+	logFile           = "storagemanager.log"
+	settingsFile      = "storagemanager.json"
 )
 
 var (
@@ -37,6 +39,11 @@ var (
 	}
 
 	errStorageManagerClosed = errors.New("call is disabled because storage manager is closed")
+	errStorageManagerFailed = errors.New("call is disabled because storage manager could not write its metadata to disk")
+
+	// errPriorFailure is returned when the storage manager is started after
+	// failing to write its metadata in a previous run.
+	errPriorFailure = errors.New("storage manager previously failed to write its metadata; check the disk holding the metadata directory, then delete " + failureMarkerFile + " to start the host again")
 )
 
 // StorageManager tracks multiple storage folders, and is responsible for
@@ -50,12 +57,32 @@ type StorageManager struct {
 	sectorSalt     crypto.Hash
 	storageFolders []*storageFolder
 
+	// io orders the sector writes. Sector reads are ordered by the scheduler
+	// of the storage folder holding the sector, in folderIOs, so that the
+	// reads needed to build storage proofs skip ahead of downloads on each
+	// disk while reads from different disks proceed in parallel.
+	io         ioScheduler
+	folderIOs  map[string]*ioScheduler
+	folderIOMu sync.Mutex
+
+	// events announces the sector reads and writes that fail.
+	events modules.EventFeed
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	log        *persist.Logger
 	mu         sync.RWMutex
 	persistDir string
 
+	// metadataDir holds the database and settings file. It may be on a
+	// different device from the persist directory.
+	metadataDir string
+
+	// failed is set if the metadata could not be written to disk, after which
+	// all changes are refused. The failure channel is closed at the same time.
+	failed  error
+	failure chan struct{}
+
 	// The resource lock is held by threaded functions for the duration of
 	// their operation. Functions should grab the resource lock as a read lock
 	// unless they are planning on manipulating the 'closed' variable.
@@ -86,9 +113,12 @@ func (sm *StorageManager) Close() (composedError error) {
 		composedError = composeErrors(composedError, err)
 	}
 
-	// Save the latest host state.
+	// Save the latest host state, unless the metadata could not be written
+	// earlier, in which case the disk is left alone.
 	sm.mu.Lock()
-	err = sm.saveSync()
+	if sm.failed == nil {
+		err = sm.saveSync()
+	}
 	sm.mu.Unlock()
 	if err != nil {
 		composedError = composeErrors(composedError, err)
@@ -103,19 +133,33 @@ func (sm *StorageManager) Close() (composedError error) {
 	return composedError
 }
 
+// Failed returns a channel that is closed if the storage manager fails to
+// write its metadata to disk. The storage manager refuses all changes after
+// such a failure, and should be shut down.
+func (sm *StorageManager) Failed() <-chan struct{} {
+	return sm.failure
+}
+
 // newStorageManager creates a new storage manager.
-func newStorageManager(dependencies dependencies, persistDir string) (*StorageManager, error) {
+func newStorageManager(dependencies dependencies, persistDir, metadataDir string) (*StorageManager, error) {
 	sm := &StorageManager{
 		dependencies: dependencies,
 
-		persistDir: persistDir,
+		failure:     make(chan struct{}),
+		folderIOs:   make(map[string]*ioScheduler),
+		metadataDir: metadataDir,
+		persistDir:  persistDir,
 	}
 
-	// Create the perist directory if it does not yet exist.
+	// Create the perist and metadata directories if they do not yet exist.
 	err := dependencies.mkdirAll(sm.persistDir, 0700)
 	if err != nil {
 		return nil, err
 	}
+	err = dependencies.mkdirAll(sm.metadataDir, 0700)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize the logger. Logging should be initialized ASAP, because the
 	// rest of the initialization makes use of the logger.
@@ -124,8 +168,15 @@ func newStorageManager(dependencies dependencies, persistDir string) (*StorageMa
 		return nil, err
 	}
 
+	// Refuse to start if the metadata could not be written in a previous run.
+	err = sm.checkFailureMarker()
+	if err != nil {
+		_ = sm.log.Close()
+		return nil, err
+	}
+
 	// Open the database containing the host's storage obligation metadata.
-	sm.db, err = dependencies.openDatabase(dbMetadata, filepath.Join(sm.persistDir, dbFilename))
+	sm.db, err = dependencies.openDatabase(dbMetadata, filepath.Join(sm.metadataDir, dbFilename))
 	if err != nil {
 		// An error will be returned if the database has the wrong version, but
 		// as of writing there was only one version of the database and all
@@ -150,10 +201,20 @@ func newStorageManager(dependencies dependencies, persistDir string) (*StorageMa
 		_ = sm.db.Close()
 		return nil, err
 	}
+	// Check that the storage folders have not been moved while the storage
+	// manager was not running.
+	sm.checkStorageFolders()
 	return sm, nil
 }
 
 // New returns an initialized StorageManager.
 func New(persistDir string) (*StorageManager, error) {
-	return newStorageManager(productionDependencies{}, persistDir)
+	return newStorageManager(productionDependencies{}, persistDir, persistDir)
+}
+
+// NewWithMetadataDir returns an initialized StorageManager that keeps its
+// database and settings in metadataDir, which may be on a different device
+// from persistDir.
+func NewWithMetadataDir(persistDir, metadataDir string) (*StorageManager, error) {
+	return newStorageManager(productionDependencies{}, persistDir, metadataDir)
 }
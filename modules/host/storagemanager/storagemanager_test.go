@@ -1,6 +1,7 @@
 package storagemanager
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -26,7 +27,7 @@ func (smt *storageManagerTester) addRandFolder(size uint64) error {
 	if err != nil {
 		return err
 	}
-	return smt.sm.AddStorageFolder(dir, size)
+	return smt.sm.AddStorageFolder(dir, size, nil)
 }
 
 // capacity returns the amount of storage still available on the machine. The
@@ -53,6 +54,22 @@ func createSector() (sectorRoot crypto.Hash, sectorData []byte, err error) {
 	return sectorRoot, sectorData, nil
 }
 
+// readSectorDir returns the files in a storage folder, leaving out the file
+// that identifies the storage folder so that only sectors are returned.
+func readSectorDir(dir string) ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var sectors []os.FileInfo
+	for _, info := range infos {
+		if info.Name() != folderIDFile {
+			sectors = append(sectors, info)
+		}
+	}
+	return sectors, nil
+}
+
 // newStorageManagerTester creates a storage tester ready for use.
 func newStorageManagerTester(name string) (*storageManagerTester, error) {
 	testdir := build.TempDir(modules.StorageManagerDir, name)
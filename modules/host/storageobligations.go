@@ -243,6 +243,23 @@ func (so storageObligation) merkleRoot() crypto.Hash {
 	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts[0].FileMerkleRoot
 }
 
+// storageProof builds the storage proof of a segment of the storage
+// obligation, given the data of the sector that contains the segment and a
+// tree of the obligation's sector roots.
+func (so storageObligation) storageProof(tree *crypto.SectorTree, sectorBytes []byte, segmentIndex uint64) types.StorageProof {
+	// Build the storage proof for just the sector, and extend it to the root
+	// of the obligation using the tree of sector roots.
+	segmentsPerSector := modules.SectorSize / crypto.SegmentSize
+	base, cachedHashSet := crypto.MerkleProof(sectorBytes, segmentIndex%segmentsPerSector)
+	hashSet := append(cachedHashSet, tree.Prove(segmentIndex/segmentsPerSector)...)
+	sp := types.StorageProof{
+		ParentID: so.id(),
+		HashSet:  hashSet,
+	}
+	copy(sp.Segment[:], base)
+	return sp
+}
+
 // payous returns the set of valid payouts and missed payouts that represent
 // the latest revision for the storage obligation.
 func (so storageObligation) payouts() (valid []types.SiacoinOutput, missed []types.SiacoinOutput) {
@@ -323,7 +340,16 @@ func (h *Host) addStorageObligation(so storageObligation) error {
 		return errors.New("fill me in")
 	}
 
+	// The collateral budget is checked during negotiation, but other file
+	// contracts may have been added since then. Check again now that the
+	// host is locked, so that concurrent negotiations cannot together exceed
+	// the budget.
+	if h.financialMetrics.LockedStorageCollateral.Add(so.LockedCollateral).Cmp(h.settings.CollateralBudget) > 0 {
+		return errCollateralBudgetExceeded
+	}
+
 	// Add the storage obligation information to the database.
+	h.obligationChangeMu.RLock()
 	err := h.db.Update(func(tx *bolt.Tx) error {
 		// Sanity check - a storage obligation using the same file contract id
 		// should not already exist. This situation can happen if the
@@ -352,6 +378,7 @@ func (h *Host) addStorageObligation(so storageObligation) error {
 		}
 		return bso.Put(soid[:], soBytes)
 	})
+	h.obligationChangeMu.RUnlock()
 	if err != nil {
 		return err
 	}
@@ -401,6 +428,9 @@ func (h *Host) addStorageObligation(so storageObligation) error {
 // multiple instances of the same virtual sector, the virtural sector will need
 // to appear in 'sectorsRemoved' multiple times. Same with 'sectorsGained'.
 func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []crypto.Hash, sectorsGained []crypto.Hash, gainedSectorData [][]byte) error {
+	h.obligationChangeMu.RLock()
+	defer h.obligationChangeMu.RUnlock()
+
 	// Sanity check - obligation should be under lock while being modified.
 	soid := so.id()
 	_, exists := h.lockedStorageObligations[soid]
@@ -418,9 +448,10 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 		h.log.Critical("modifying a revision with garbage sector data", len(sectorsGained), len(gainedSectorData))
 		return errInsaneStorageObligationRevision
 	}
-	// Sanity check - all of the sector data should be modules.SectorSize
+	// Sanity check - all of the sector data should be modules.SectorSize. The
+	// data is nil for sectors that the renter inserted by their Merkle root.
 	for _, data := range gainedSectorData {
-		if uint64(len(data)) != modules.SectorSize {
+		if data != nil && uint64(len(data)) != modules.SectorSize {
 			h.log.Critical("modifying a revision with garbase sector sizes", len(data))
 			return errInsaneStorageObligationRevision
 		}
@@ -433,21 +464,42 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 	// and left to consistency checks and user actions to fix (will reduce host
 	// capacity, but will not inhibit the host's ability to submit storage
 	// proofs)
-	var i int
+	//
+	// Sectors that the host is already storing only need another virtual
+	// sector, which is added for all of them at once without touching the
+	// sector data.
+	var addedSectors, virtualSectors []crypto.Hash
 	var err error
-	for i = range sectorsGained {
+	for i := range sectorsGained {
+		if h.HasSector(sectorsGained[i]) {
+			virtualSectors = append(virtualSectors, sectorsGained[i])
+			continue
+		}
+		if gainedSectorData[i] == nil {
+			// The sector was removed after the renter's revision was
+			// verified.
+			err = errUnknownSectorRoot
+			break
+		}
 		err = h.AddSector(sectorsGained[i], so.expiration(), gainedSectorData[i])
 		if err != nil {
 			break
 		}
+		addedSectors = append(addedSectors, sectorsGained[i])
+	}
+	if err == nil && len(virtualSectors) > 0 {
+		err = h.AddSectorBatch(virtualSectors, so.expiration())
+		if err == nil {
+			addedSectors = append(addedSectors, virtualSectors...)
+		}
 	}
 	if err != nil {
 		// Because there was an error, all of the sectors that got added need
 		// to be reverted.
-		for j := 0; j < i; j++ {
+		for _, root := range addedSectors {
 			// Error is not checked because there's nothing useful that can be
 			// done about an error.
-			_ = h.RemoveSector(sectorsGained[j], so.expiration())
+			_ = h.RemoveSector(root, so.expiration())
 		}
 		return err
 	}
@@ -461,6 +513,22 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 			return err
 		}
 
+		// Revisions that add collateral must stay within the collateral
+		// budget, which may have been lowered since the file contract was
+		// formed.
+		if so.LockedCollateral.Cmp(oldSO.LockedCollateral) > 0 {
+			locked := h.financialMetrics.LockedStorageCollateral.Sub(oldSO.LockedCollateral).Add(so.LockedCollateral)
+			if locked.Cmp(h.settings.CollateralBudget) > 0 {
+				return errCollateralBudgetExceeded
+			}
+		}
+		if so.RiskedCollateral.Cmp(oldSO.RiskedCollateral) > 0 {
+			risked := h.financialMetrics.RiskedStorageCollateral.Sub(oldSO.RiskedCollateral).Add(so.RiskedCollateral)
+			if risked.Cmp(h.settings.CollateralBudget) > 0 {
+				return errCollateralBudgetExceeded
+			}
+		}
+
 		// Store the new storage obligation to replace the old one.
 		return putStorageObligation(tx, so)
 	})
@@ -507,6 +575,8 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 // removeStorageObligation will remove a storage obligation from the host,
 // either due to failure or success.
 func (h *Host) removeStorageObligation(so storageObligation, sos storageObligationStatus) error {
+	h.obligationChangeMu.RLock()
+	defer h.obligationChangeMu.RUnlock()
 
 	// Call removeSector for every sector in the storage obligation.
 	for _, root := range so.SectorRoots {
@@ -562,6 +632,7 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 		// Add the obligation statistics as loss.
 		h.financialMetrics.LostStorageCollateral = h.financialMetrics.LostStorageCollateral.Add(so.RiskedCollateral)
 		h.financialMetrics.LostRevenue = h.financialMetrics.LostRevenue.Add(so.ContractCost).Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue)
+		h.raiseProofMissedAlert(so.id(), so.RiskedCollateral)
 	}
 
 	// Update the storage obligation to be finalized but still in-database. The
@@ -742,34 +813,22 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 			return
 		}
 		sectorIndex := segmentIndex / (modules.SectorSize / crypto.SegmentSize)
-		// Pull the corresponding sector into memory.
+		// Pull the corresponding sector into memory. If the deadline is near,
+		// the read skips ahead of any downloads that are waiting, so that the
+		// proof is not missed because the host is busy serving renters.
 		sectorRoot := so.SectorRoots[sectorIndex]
-		sectorBytes, err := h.ReadSector(sectorRoot)
+		var sectorBytes []byte
+		if blockHeight+proofPriorityWindow >= so.proofDeadline() {
+			sectorBytes, err = h.ReadSectorPriority(sectorRoot)
+		} else {
+			sectorBytes, err = h.ReadSector(sectorRoot)
+		}
 		if err != nil {
 			h.log.Debugln(err)
 			return
 		}
 
-		// Build the storage proof for just the sector.
-		sectorSegment := segmentIndex % (modules.SectorSize / crypto.SegmentSize)
-		base, cachedHashSet := crypto.MerkleProof(sectorBytes, sectorSegment)
-
-		// Using the sector, build a cached root.
-		log2SectorSize := uint64(0)
-		for 1<<log2SectorSize < (modules.SectorSize / crypto.SegmentSize) {
-			log2SectorSize++
-		}
-		ct := crypto.NewCachedTree(log2SectorSize)
-		ct.SetIndex(segmentIndex)
-		for _, root := range so.SectorRoots {
-			ct.Push(root)
-		}
-		hashSet := ct.Prove(base, cachedHashSet)
-		sp := types.StorageProof{
-			ParentID: so.id(),
-			HashSet:  hashSet,
-		}
-		copy(sp.Segment[:], base)
+		sp := so.storageProof(crypto.NewSectorTree(so.SectorRoots), sectorBytes, segmentIndex)
 
 		// Create and build the transaction with the storage proof.
 		builder := h.wallet.StartTransaction()
@@ -745,3 +745,83 @@ func TestAutoRevisionSubmission(t *testing.T) {
 		t.Fatal("the host should be reporting revenue after a successful storage proof")
 	}
 }
+
+// TestStorageObligationCollateralBudget checks that the host refuses to add a
+// storage obligation that would push the locked collateral past the
+// collateral budget.
+func TestStorageObligationCollateralBudget(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestStorageObligationCollateralBudget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Set a collateral budget that fits only one storage obligation.
+	settings := ht.host.InternalSettings()
+	settings.CollateralBudget = types.SiacoinPrecision.Mul64(15)
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a storage obligation that uses most of the budget.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.LockedCollateral = types.SiacoinPrecision.Mul64(10)
+	ht.host.managedLockStorageObligation(so.id())
+	ht.host.mu.Lock()
+	err = ht.host.addStorageObligation(so)
+	ht.host.mu.Unlock()
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second storage obligation should exceed the budget.
+	so2, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so2.LockedCollateral = types.SiacoinPrecision.Mul64(10)
+	ht.host.managedLockStorageObligation(so2.id())
+	ht.host.mu.Lock()
+	err = ht.host.addStorageObligation(so2)
+	ht.host.mu.Unlock()
+	ht.host.managedUnlockStorageObligation(so2.id())
+	if err != errCollateralBudgetExceeded {
+		t.Fatal("expected errCollateralBudgetExceeded, got", err)
+	}
+	fm := ht.host.FinancialMetrics()
+	if fm.ContractCount != 1 {
+		t.Error("host should have 1 contract:", fm.ContractCount)
+	}
+	if fm.LockedStorageCollateral.Cmp(types.SiacoinPrecision.Mul64(10)) != 0 {
+		t.Error("locked collateral was not tracked correctly:", fm.LockedStorageCollateral)
+	}
+
+	// Revisions that put collateral at risk are limited by the budget too.
+	so.RiskedCollateral = types.SiacoinPrecision.Mul64(5)
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.modifyStorageObligation(so, nil, nil, nil)
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	so.RiskedCollateral = types.SiacoinPrecision.Mul64(20)
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.modifyStorageObligation(so, nil, nil, nil)
+	ht.host.managedUnlockStorageObligation(so.id())
+	if err != errCollateralBudgetExceeded {
+		t.Fatal("expected errCollateralBudgetExceeded for a revision, got", err)
+	}
+	fm = ht.host.FinancialMetrics()
+	if fm.RiskedStorageCollateral.Cmp(types.SiacoinPrecision.Mul64(5)) != 0 {
+		t.Error("rejected revision changed the risked collateral:", fm.RiskedStorageCollateral)
+	}
+}
@@ -0,0 +1,112 @@
+package host
+
+// summary.go reports operational state of the host that is not covered by the
+// settings and metrics, such as the status of the storage obligations, recent
+// errors, and whether the host is reachable.
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// proofDeadlines is a list of storage proof deadlines that can be sorted by
+// the start of the proof window.
+type proofDeadlines []modules.HostProofDeadline
+
+func (pd proofDeadlines) Len() int           { return len(pd) }
+func (pd proofDeadlines) Less(i, j int) bool { return pd[i].WindowStart < pd[j].WindowStart }
+func (pd proofDeadlines) Swap(i, j int)      { pd[i], pd[j] = pd[j], pd[i] }
+
+// managedRecordError adds an error to the list of recent errors, discarding
+// the oldest error if the list is full.
+func (h *Host) managedRecordError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recentErrors = append(h.recentErrors, modules.HostError{
+		Message: err.Error(),
+		Time:    time.Now(),
+	})
+	if len(h.recentErrors) > maxRecentErrors {
+		h.recentErrors = h.recentErrors[len(h.recentErrors)-maxRecentErrors:]
+	}
+}
+
+// ObligationSummary returns the number of storage obligations with each
+// status, and the storage proof deadlines of the unresolved obligations that
+// have not had a storage proof confirmed, soonest first.
+func (h *Host) ObligationSummary() (summary modules.HostObligationSummary, err error) {
+	if err = h.tg.Add(); err != nil {
+		return modules.HostObligationSummary{}, err
+	}
+	defer h.tg.Done()
+
+	err = h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketStorageObligations).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var so storageObligation
+			err := json.Unmarshal(v, &so)
+			if err != nil {
+				return err
+			}
+			switch so.ObligationStatus {
+			case obligationUnresolved:
+				summary.Unresolved++
+				if !so.ProofConfirmed {
+					summary.UpcomingProofs = append(summary.UpcomingProofs, modules.HostProofDeadline{
+						ObligationID: so.id(),
+						WindowStart:  so.expiration(),
+						WindowEnd:    so.proofDeadline(),
+					})
+				}
+			case obligationRejected:
+				summary.Rejected++
+			case obligationSucceeded:
+				summary.Succeeded++
+			case obligationFailed:
+				summary.Failed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return modules.HostObligationSummary{}, err
+	}
+
+	sort.Sort(proofDeadlines(summary.UpcomingProofs))
+	if len(summary.UpcomingProofs) > maxUpcomingProofs {
+		summary.UpcomingProofs = summary.UpcomingProofs[:maxUpcomingProofs]
+	}
+	return summary, nil
+}
+
+// Reachability returns information about whether the host can be reached by
+// renters.
+func (h *Host) Reachability() modules.HostReachability {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	netAddress := h.settings.NetAddress
+	if netAddress == "" {
+		netAddress = h.autoAddress
+	}
+	return modules.HostReachability{
+		Announced:             h.announced,
+		LastInboundConnection: h.lastInboundConnection,
+		NetAddress:            netAddress,
+		Reachable:             h.announced && time.Since(h.lastInboundConnection) < reachabilityTimeout,
+	}
+}
+
+// RecentErrors returns the most recent errors encountered by the host while
+// handling RPCs, oldest first.
+func (h *Host) RecentErrors() []modules.HostError {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	errs := make([]modules.HostError, len(h.recentErrors))
+	copy(errs, h.recentErrors)
+	return errs
+}
@@ -0,0 +1,87 @@
+package host
+
+import (
+	"testing"
+)
+
+// TestObligationSummary checks that the obligation summary reports unresolved
+// storage obligations and their storage proof deadlines.
+func TestObligationSummary(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestObligationSummary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	summary, err := ht.host.ObligationSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unresolved != 0 || len(summary.UpcomingProofs) != 0 {
+		t.Fatal("host should start without storage obligations:", summary)
+	}
+
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedLockStorageObligation(so.id())
+	err = ht.host.addStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.managedUnlockStorageObligation(so.id())
+
+	summary, err = ht.host.ObligationSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unresolved != 1 {
+		t.Fatal("expected 1 unresolved obligation, got", summary.Unresolved)
+	}
+	if len(summary.UpcomingProofs) != 1 {
+		t.Fatal("expected 1 upcoming proof, got", len(summary.UpcomingProofs))
+	}
+	proof := summary.UpcomingProofs[0]
+	if proof.ObligationID != so.id() || proof.WindowStart != so.expiration() || proof.WindowEnd != so.proofDeadline() {
+		t.Error("upcoming proof does not match the storage obligation:", proof)
+	}
+}
+
+// TestRecentErrors checks that the host keeps track of the most recent errors
+// that it logs.
+func TestRecentErrors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := blankHostTester("TestRecentErrors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	if len(ht.host.RecentErrors()) != 0 {
+		t.Fatal("host should start without recent errors")
+	}
+	for i := 0; i < maxRecentErrors+5; i++ {
+		ht.host.managedLogError(ErrorCommunication("test error"))
+	}
+	ht.host.managedLogError(ErrorInternal("last error"))
+	errs := ht.host.RecentErrors()
+	if len(errs) != maxRecentErrors {
+		t.Fatalf("expected %v recent errors, got %v", maxRecentErrors, len(errs))
+	}
+	if errs[len(errs)-1].Message != ErrorInternal("last error").Error() {
+		t.Error("most recent error is not last:", errs[len(errs)-1].Message)
+	}
+
+	// The host has not been announced, so it should not be reachable.
+	if ht.host.Reachability().Reachable {
+		t.Error("unannounced host should not be reachable")
+	}
+}
@@ -27,8 +27,9 @@ func (h *Host) managedLearnHostname() {
 	netAddr := h.settings.NetAddress
 	h.mu.RUnlock()
 	// If the settings indicate that an address has been manually set, there is
-	// no reason to learn the hostname.
-	if netAddr != "" {
+	// no reason to learn the hostname. While a proxy is in use, the hostname
+	// is not learned either, as it must not be announced.
+	if netAddr != "" || modules.Proxy() != "" {
 		return
 	}
 
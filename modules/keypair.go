@@ -0,0 +1,62 @@
+package modules
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// ErrBadSecretKey is returned when the secret key of a key pair cannot
+	// be decoded.
+	ErrBadSecretKey = errors.New("secret key must be 128 hex characters")
+)
+
+// A KeyPair is a standalone address together with the secret key that spends
+// the coins sent to it. Unlike the addresses of a wallet, a key pair is not
+// derived from a seed, so it can be generated offline, printed as a paper
+// wallet, and later loaded into a wallet as a loose key.
+type KeyPair struct {
+	Address   types.UnlockHash `json:"address"`
+	SecretKey string           `json:"secretkey"` // hex
+}
+
+// StandardUnlockConditions returns the unlock conditions of an address that
+// is spent with a single signature from the given public key. These are the
+// unlock conditions of every address generated by a wallet.
+func StandardUnlockConditions(pk crypto.PublicKey) types.UnlockConditions {
+	return types.UnlockConditions{
+		PublicKeys: []types.SiaPublicKey{{
+			Algorithm: types.SignatureEd25519,
+			Key:       pk[:],
+		}},
+		SignaturesRequired: 1,
+	}
+}
+
+// NewKeyPair generates a key pair from fresh entropy. It does not need a
+// wallet or a network connection.
+func NewKeyPair() (KeyPair, error) {
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		return KeyPair{}, err
+	}
+	defer crypto.SecureWipe(sk[:])
+	return KeyPair{
+		Address:   StandardUnlockConditions(pk).UnlockHash(),
+		SecretKey: hex.EncodeToString(sk[:]),
+	}, nil
+}
+
+// ParseSecretKey decodes the secret key of a key pair.
+func ParseSecretKey(s string) (sk crypto.SecretKey, err error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(sk) {
+		return crypto.SecretKey{}, ErrBadSecretKey
+	}
+	copy(sk[:], b)
+	crypto.SecureWipe(b)
+	return sk, nil
+}
@@ -0,0 +1,35 @@
+package modules
+
+import (
+	"testing"
+)
+
+// TestNewKeyPair checks that the secret key of a generated key pair spends
+// from its address.
+func TestNewKeyPair(t *testing.T) {
+	kp, err := NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := ParseSecretKey(kp.SecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if StandardUnlockConditions(sk.PublicKey()).UnlockHash() != kp.Address {
+		t.Fatal("secret key does not belong to the address of the key pair")
+	}
+
+	kp2, err := NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kp2.Address == kp.Address {
+		t.Fatal("generated the same key pair twice")
+	}
+
+	for _, s := range []string{"", "abcd", kp.SecretKey[2:], kp.SecretKey + "00", "zz" + kp.SecretKey[2:]} {
+		if _, err := ParseSecretKey(s); err != ErrBadSecretKey {
+			t.Errorf("expected ErrBadSecretKey for %q, got %v", s, err)
+		}
+	}
+}
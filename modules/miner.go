@@ -2,6 +2,7 @@ package modules
 
 import (
 	"io"
+	"time"
 
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -10,8 +11,71 @@ const (
 	// MinerDir is the name of the directory that is used to store the miner's
 	// persistent data.
 	MinerDir = "miner"
+
+	// MinerPayoutSplitTotal is the sum of the shares of a set of payout
+	// splits. Shares are expressed in basis points, so that a share of 250 is
+	// 2.5% of the block payout.
+	MinerPayoutSplitTotal = 10000
 )
 
+// BlockTransactionCapacity is the number of bytes of transactions that the
+// miner will put into a block, leaving room for the header, the miner payouts,
+// and the arbitrary data transaction. The transaction pool bases its fee
+// estimates on it.
+var BlockTransactionCapacity = types.BlockSizeLimit - 5e3
+
+// A MinerPayoutSplit directs a share of each block payout to an address. The
+// shares of the splits used by a miner must sum to MinerPayoutSplitTotal.
+type MinerPayoutSplit struct {
+	UnlockHash  types.UnlockHash `json:"unlockhash"`
+	BasisPoints uint64           `json:"basispoints"`
+}
+
+// MinerCPUSettings controls how much of the machine the cpu miner uses.
+type MinerCPUSettings struct {
+	// Threads is the number of threads that mine at once. Zero means one
+	// thread.
+	Threads int `json:"threads"`
+
+	// DutyCycle is the percentage of time that each thread spends mining,
+	// resting for the remainder. Zero means 100%.
+	DutyCycle int `json:"dutycycle"`
+
+	// Windows are the times of day during which the cpu miner mines. If
+	// there are no windows, the cpu miner mines at all times.
+	Windows []MinerCPUWindow `json:"windows"`
+}
+
+// A MinerCPUWindow is a daily period during which the cpu miner mines. Start
+// and End are minutes after midnight in the local time of the miner. A window
+// whose End is before its Start runs past midnight.
+type MinerCPUWindow struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// A BlockTemplate describes the block that the miner is handing out for
+// work. The miner fills blocks with the unconfirmed transactions that pay the
+// highest fee rate, and the template's ID changes whenever a new block is
+// found or transactions paying higher fees arrive.
+type BlockTemplate struct {
+	ID       uint64            `json:"id"`
+	ParentID types.BlockID     `json:"parentid"`
+	Height   types.BlockHeight `json:"height"`
+	Target   types.Target      `json:"target"`
+
+	// Transactions is the number of transactions in the block, and Size is
+	// their total encoded size in bytes.
+	Transactions int    `json:"transactions"`
+	Size         uint64 `json:"size"`
+
+	// Fees is the total miner fees paid by the transactions, and Payout is
+	// the block subsidy plus the fees, which is the revenue the block earns if
+	// it is solved.
+	Fees   types.Currency `json:"fees"`
+	Payout types.Currency `json:"payout"`
+}
+
 // BlockManager contains functions that can interface with external miners,
 // providing and receiving blocks that have experienced nonce grinding.
 type BlockManager interface {
@@ -24,12 +88,41 @@ type BlockManager interface {
 	// valid target.
 	SubmitHeader(types.BlockHeader) error
 
+	// ServeGetwork starts serving headers to external miners using the
+	// getwork protocol on the provided address.
+	ServeGetwork(addr string) error
+
 	// BlocksMined returns the number of blocks and stale blocks that have been
 	// mined using this miner.
 	BlocksMined() (goodBlocks, staleBlocks int)
+
+	// BlockTemplate returns the template of the blocks that the miner is
+	// handing out for work.
+	BlockTemplate() BlockTemplate
+
+	// WaitForTemplate blocks until the miner's block template is no longer
+	// the template with the provided id, or until the timeout elapses, and
+	// then returns the current template.
+	WaitForTemplate(id uint64, timeout time.Duration) (BlockTemplate, error)
+
+	// PayoutSplits returns the addresses that block payouts are split
+	// between. If empty, the whole payout goes to an address from the
+	// wallet.
+	PayoutSplits() []MinerPayoutSplit
+
+	// SetPayoutSplits sets the addresses that block payouts are split
+	// between. The shares must sum to MinerPayoutSplitTotal. Setting no
+	// splits sends the whole payout to an address from the wallet.
+	SetPayoutSplits([]MinerPayoutSplit) error
+
+	// BlocksOrphaned returns the number of blocks mined using this miner
+	// that were part of the longest chain, but were later orphaned by a
+	// reorg. Orphaned blocks are included in the stale blocks reported by
+	// BlocksMined.
+	BlocksOrphaned() int
 }
 
-// CPUMiner provides access to a single-threaded cpu miner.
+// CPUMiner provides access to a cpu miner.
 type CPUMiner interface {
 	// CPUHashrate returns the hashrate of the cpu miner in hashes per second.
 	CPUHashrate() int
@@ -43,6 +136,14 @@ type CPUMiner interface {
 
 	// StopMining turns off the miner, but keeps the same number of threads.
 	StopCPUMining()
+
+	// CPUSettings returns the thread count, duty cycle, and schedule of the
+	// cpu miner.
+	CPUSettings() MinerCPUSettings
+
+	// SetCPUSettings sets the thread count, duty cycle, and schedule of the
+	// cpu miner. The settings take effect while the miner is running.
+	SetCPUSettings(MinerCPUSettings) error
 }
 
 // TestMiner provides direct access to block fetching, solving, and
@@ -31,7 +31,7 @@ func (m *Miner) blockForWork() types.Block {
 	if err != nil {
 		m.log.Println(err)
 	}
-	b.MinerPayouts = []types.SiacoinOutput{{Value: b.CalculateSubsidy(m.persist.Height + 1), UnlockHash: m.persist.Address}}
+	b.MinerPayouts = m.minerPayouts(b.CalculateSubsidy(m.persist.Height + 1))
 
 	// Add an arb-data txn to the block to create a unique merkle root.
 	randBytes, _ := crypto.RandBytes(types.SpecifierLen)
@@ -6,22 +6,18 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 )
 
-// threadedMine starts a gothread that does CPU mining. threadedMine is the
-// only function that should be setting the mining flag to true.
-func (m *Miner) threadedMine() {
-	if err := m.tg.Add(); err != nil {
-		return
-	}
-	defer m.tg.Done()
-
-	// There should not be another thread mining, and mining should be enabled.
-	m.mu.Lock()
-	if m.mining || !m.miningOn {
+// threadedMine is a gothread that does CPU mining. Mining threads are only
+// started by startMiningThreads, which counts them in 'mining' before they
+// start. A thread stops when mining is turned off, when the miner shuts down,
+// or when more threads are running than the cpu settings allow.
+func (m *Miner) threadedMine(id int) {
+	if err := m.tg.AddNamed("threadedMine"); err != nil {
+		m.mu.Lock()
+		m.mining--
 		m.mu.Unlock()
 		return
 	}
-	m.mining = true
-	m.mu.Unlock()
+	defer m.tg.DoneNamed("threadedMine")
 
 	// Solve blocks repeatedly, keeping track of how fast hashing is
 	// occurring.
@@ -33,25 +29,43 @@ func (m *Miner) threadedMine() {
 		select {
 		case <-m.tg.StopChan():
 			m.miningOn = false
-			m.mining = false
+			m.mining--
+			delete(m.hashRates, id)
 			m.mu.Unlock()
 			return
 		default:
 		}
 
-		// Kill the thread if mining has been turned off.
-		if !m.miningOn {
-			m.mining = false
+		// Kill the thread if mining has been turned off, or if the number of
+		// threads has been reduced. The count is updated before the lock is
+		// released so that no more threads stop than necessary.
+		if !m.miningOn || m.mining > m.cpuThreads() {
+			m.mining--
+			delete(m.hashRates, id)
 			m.mu.Unlock()
 			return
 		}
 
+		// Wait outside of the mining windows.
+		if !cpuWindowOpen(m.persist.CPUSettings.Windows, time.Now()) {
+			delete(m.hashRates, id)
+			m.mu.Unlock()
+			select {
+			case <-m.tg.StopChan():
+			case <-time.After(cpuScheduleInterval):
+			}
+			cycleStart = time.Now()
+			continue
+		}
+
 		// Prepare the work and release the miner lock.
 		bfw := m.blockForWork()
 		target := m.persist.Target
+		dutyCycle := m.cpuDutyCycle()
 		m.mu.Unlock()
 
 		// Solve the block.
+		solveStart := time.Now()
 		b, solved := solveBlock(bfw, target)
 		if solved {
 			err := m.managedSubmitBlock(b)
@@ -60,18 +74,37 @@ func (m *Miner) threadedMine() {
 			}
 		}
 
+		// Rest for the remainder of the duty cycle.
+		if dutyCycle < 100 {
+			rest := time.Since(solveStart) * time.Duration(100-dutyCycle) / time.Duration(dutyCycle)
+			select {
+			case <-m.tg.StopChan():
+			case <-time.After(rest):
+			}
+		}
+
 		// Update the hashrate. If the block was solved, the full set of
 		// iterations was not completed, so the hashrate should not be updated.
 		m.mu.Lock()
 		if !solved {
 			nanosecondsElapsed := 1 + time.Since(cycleStart).Nanoseconds() // Add 1 to prevent divide by zero errors.
 			cycleStart = time.Now()                                        // Reset the cycle counter as soon as the previous value is measured.
-			m.hashRate = 1e9 * solveAttempts / nanosecondsElapsed
+			m.hashRates[id] = 1e9 * solveAttempts / nanosecondsElapsed
 		}
 		m.mu.Unlock()
 	}
 }
 
+// startMiningThreads starts mining threads until the number of threads set
+// by the cpu settings are running.
+func (m *Miner) startMiningThreads() {
+	for m.mining < m.cpuThreads() {
+		m.mining++
+		m.threadID++
+		go m.threadedMine(m.threadID)
+	}
+}
+
 // CPUHashrate returns an estimated cpu hashrate.
 func (m *Miner) CPUHashrate() int {
 	if err := m.tg.Add(); err != nil {
@@ -81,7 +114,11 @@ func (m *Miner) CPUHashrate() int {
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	return int(m.hashRate)
+	var hashRate int64
+	for _, rate := range m.hashRates {
+		hashRate += rate
+	}
+	return int(hashRate)
 }
 
 // CPUMining indicates whether the cpu miner is running.
@@ -96,8 +133,8 @@ func (m *Miner) CPUMining() bool {
 	return m.miningOn
 }
 
-// StartCPUMining will start a single threaded cpu miner. If the miner is
-// already running, nothing will happen.
+// StartCPUMining will start the cpu miner, using the number of threads set by
+// the cpu settings. If the miner is already running, nothing will happen.
 func (m *Miner) StartCPUMining() {
 	if err := m.tg.Add(); err != nil {
 		build.Critical(err)
@@ -107,7 +144,7 @@ func (m *Miner) StartCPUMining() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.miningOn = true
-	go m.threadedMine()
+	m.startMiningThreads()
 }
 
 // StopCPUMining will stop the cpu miner. If the cpu miner is already stopped,
@@ -120,6 +157,6 @@ func (m *Miner) StopCPUMining() {
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.hashRate = 0
+	m.hashRates = make(map[int]int64)
 	m.miningOn = false
 }
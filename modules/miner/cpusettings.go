@@ -0,0 +1,127 @@
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// minutesPerDay is the number of minutes in a day, and bounds the start
+	// and end of cpu mining windows.
+	minutesPerDay = 24 * 60
+)
+
+var (
+	errCPUDutyCycle = errors.New("cpu mining duty cycle must be between 1 and 100 percent")
+	errCPUThreads   = fmt.Errorf("cpu miner can use between 1 and %v threads", runtime.NumCPU())
+	errCPUWindow    = errors.New("cpu mining window must start and end at different times of day")
+
+	// cpuScheduleInterval is how often an idle mining thread checks whether
+	// a mining window has opened.
+	cpuScheduleInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return 10 * time.Second
+		}
+		if build.Release == "standard" {
+			return time.Minute
+		}
+		if build.Release == "testing" {
+			return 100 * time.Millisecond
+		}
+		panic("unrecognized build.Release")
+	}()
+)
+
+// validateCPUSettings checks that the cpu settings can be used by the miner.
+func validateCPUSettings(cs modules.MinerCPUSettings) error {
+	if cs.Threads < 0 || cs.Threads > runtime.NumCPU() {
+		return errCPUThreads
+	}
+	if cs.DutyCycle < 0 || cs.DutyCycle > 100 {
+		return errCPUDutyCycle
+	}
+	for _, w := range cs.Windows {
+		if w.Start < 0 || w.Start >= minutesPerDay || w.End < 0 || w.End >= minutesPerDay || w.Start == w.End {
+			return errCPUWindow
+		}
+	}
+	return nil
+}
+
+// cpuThreads returns the number of threads that the cpu miner should run.
+func (m *Miner) cpuThreads() int {
+	if m.persist.CPUSettings.Threads == 0 {
+		return 1
+	}
+	return m.persist.CPUSettings.Threads
+}
+
+// cpuDutyCycle returns the percentage of time that each cpu mining thread
+// should spend mining.
+func (m *Miner) cpuDutyCycle() int {
+	if m.persist.CPUSettings.DutyCycle == 0 {
+		return 100
+	}
+	return m.persist.CPUSettings.DutyCycle
+}
+
+// cpuWindowOpen returns whether the cpu miner is scheduled to mine at time
+// t. If no windows are set, the miner mines at all times.
+func cpuWindowOpen(windows []modules.MinerCPUWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range windows {
+		if w.Start < w.End && w.Start <= minute && minute < w.End {
+			return true
+		}
+		// The window runs past midnight.
+		if w.Start > w.End && (minute >= w.Start || minute < w.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// CPUSettings returns the thread count, duty cycle, and schedule of the cpu
+// miner.
+func (m *Miner) CPUSettings() modules.MinerCPUSettings {
+	if err := m.tg.Add(); err != nil {
+		return modules.MinerCPUSettings{}
+	}
+	defer m.tg.Done()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cs := m.persist.CPUSettings
+	cs.Windows = append([]modules.MinerCPUWindow(nil), cs.Windows...)
+	return cs
+}
+
+// SetCPUSettings sets the thread count, duty cycle, and schedule of the cpu
+// miner. If the miner is running, threads are started or stopped to match the
+// new thread count.
+func (m *Miner) SetCPUSettings(cs modules.MinerCPUSettings) error {
+	if err := m.tg.Add(); err != nil {
+		return err
+	}
+	defer m.tg.Done()
+	if err := validateCPUSettings(cs); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cs.Windows = append([]modules.MinerCPUWindow(nil), cs.Windows...)
+	m.persist.CPUSettings = cs
+	if m.miningOn {
+		m.startMiningThreads()
+	}
+	m.log.Printf("CPU miner now uses %v threads at a %v%% duty cycle with %v mining windows", m.cpuThreads(), m.cpuDutyCycle(), len(cs.Windows))
+	return m.saveSync()
+}
@@ -0,0 +1,120 @@
+package miner
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestCPUWindowOpen probes the cpuWindowOpen function.
+func TestCPUWindowOpen(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2017, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+	day := []modules.MinerCPUWindow{{Start: 9 * 60, End: 17 * 60}}
+	night := []modules.MinerCPUWindow{{Start: 22 * 60, End: 6 * 60}}
+	tests := []struct {
+		windows []modules.MinerCPUWindow
+		t       time.Time
+		open    bool
+	}{
+		{nil, at(3, 0), true},
+		{day, at(9, 0), true},
+		{day, at(16, 59), true},
+		{day, at(17, 0), false},
+		{day, at(3, 0), false},
+		{night, at(23, 30), true},
+		{night, at(5, 59), true},
+		{night, at(6, 0), false},
+		{night, at(12, 0), false},
+		{append(day, night...), at(12, 0), true},
+	}
+	for i, test := range tests {
+		if open := cpuWindowOpen(test.windows, test.t); open != test.open {
+			t.Errorf("%v: expected %v, got %v", i, test.open, open)
+		}
+	}
+}
+
+// TestValidateCPUSettings probes the validateCPUSettings function.
+func TestValidateCPUSettings(t *testing.T) {
+	tests := []struct {
+		cs  modules.MinerCPUSettings
+		err error
+	}{
+		{modules.MinerCPUSettings{}, nil},
+		{modules.MinerCPUSettings{Threads: 1, DutyCycle: 50, Windows: []modules.MinerCPUWindow{{Start: 1320, End: 360}}}, nil},
+		{modules.MinerCPUSettings{Threads: -1}, errCPUThreads},
+		{modules.MinerCPUSettings{Threads: runtime.NumCPU() + 1}, errCPUThreads},
+		{modules.MinerCPUSettings{DutyCycle: 101}, errCPUDutyCycle},
+		{modules.MinerCPUSettings{Windows: []modules.MinerCPUWindow{{Start: 60, End: 60}}}, errCPUWindow},
+		{modules.MinerCPUSettings{Windows: []modules.MinerCPUWindow{{Start: 0, End: minutesPerDay}}}, errCPUWindow},
+	}
+	for i, test := range tests {
+		if err := validateCPUSettings(test.cs); err != test.err {
+			t.Errorf("%v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestIntegrationCPUSettings checks that the cpu miner runs the number of
+// threads set by its settings, and that the settings are kept across
+// restarts.
+func TestIntegrationCPUSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationCPUSettings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForThreads := func(n int) {
+		for i := 0; i < 100; i++ {
+			mt.miner.mu.RLock()
+			mining := mt.miner.mining
+			mt.miner.mu.RUnlock()
+			if mining == n {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal("expected", n, "mining threads")
+	}
+
+	threads := 2
+	if runtime.NumCPU() < threads {
+		threads = runtime.NumCPU()
+	}
+	cs := modules.MinerCPUSettings{Threads: threads, DutyCycle: 50}
+	if err := mt.miner.SetCPUSettings(cs); err != nil {
+		t.Fatal(err)
+	}
+	mt.miner.StartCPUMining()
+	waitForThreads(threads)
+
+	// Reducing the thread count should stop the extra threads, and closing
+	// every mining window should leave the threads idle.
+	cs = modules.MinerCPUSettings{Threads: 1, Windows: []modules.MinerCPUWindow{{Start: 0, End: 1}}}
+	if err := mt.miner.SetCPUSettings(cs); err != nil {
+		t.Fatal(err)
+	}
+	waitForThreads(1)
+	mt.miner.StopCPUMining()
+	waitForThreads(0)
+
+	// The settings should be kept across restarts.
+	if err := mt.miner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(mt.cs, mt.tpool, mt.wallet, filepath.Join(mt.persistDir, modules.MinerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if got := m.CPUSettings(); got.Threads != 1 || len(got.Windows) != 1 || got.Windows[0] != cs.Windows[0] {
+		t.Fatal("cpu settings were not kept across restarts:", got)
+	}
+}
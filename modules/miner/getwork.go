@@ -0,0 +1,141 @@
+package miner
+
+// getwork.go serves headers to external mining hardware using the getwork
+// protocol. getwork is JSON-RPC over HTTP: a call to the "getwork" method
+// without parameters returns a header to grind on along with the target, and
+// a call with a single parameter submits a solved header. Headers and targets
+// are hex encoded using the same binary encoding as the /miner/header API
+// call.
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errGetworkMethod  = errors.New("unknown method, only getwork is supported")
+	errGetworkParams  = errors.New("getwork takes at most one parameter")
+	errGetworkServing = errors.New("miner is already serving getwork")
+)
+
+type (
+	// getworkRequest is a JSON-RPC request made by an external miner.
+	getworkRequest struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params []string        `json:"params"`
+	}
+
+	// getworkResponse is the JSON-RPC response to a getworkRequest. Result is
+	// a getworkWork when work is requested, and a bool indicating whether the
+	// header was accepted when a header is submitted.
+	getworkResponse struct {
+		ID     json.RawMessage `json:"id"`
+		Result interface{}     `json:"result"`
+		Error  *getworkError   `json:"error"`
+	}
+
+	// getworkError is the error object of a JSON-RPC response.
+	getworkError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	// getworkWork is a header to grind on, along with the target that the
+	// header's ID must meet.
+	getworkWork struct {
+		Data   string `json:"data"`
+		Target string `json:"target"`
+	}
+)
+
+// getworkHandler handles a single getwork call.
+func (m *Miner) getworkHandler(w http.ResponseWriter, req *http.Request) {
+	var gr getworkRequest
+	resp := getworkResponse{}
+	err := json.NewDecoder(req.Body).Decode(&gr)
+	if err == nil {
+		resp.ID = gr.ID
+		resp.Result, err = m.managedGetwork(gr)
+	}
+	if err != nil {
+		resp.Result = nil
+		resp.Error = &getworkError{Code: -1, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// managedGetwork returns the result of a getwork call.
+func (m *Miner) managedGetwork(gr getworkRequest) (interface{}, error) {
+	if gr.Method != "getwork" {
+		return nil, errGetworkMethod
+	}
+	switch len(gr.Params) {
+	case 0:
+		header, target, err := m.HeaderForWork()
+		if err != nil {
+			return nil, err
+		}
+		return getworkWork{
+			Data:   hex.EncodeToString(encoding.Marshal(header)),
+			Target: hex.EncodeToString(target[:]),
+		}, nil
+	case 1:
+		headerBytes, err := hex.DecodeString(gr.Params[0])
+		if err != nil {
+			return nil, err
+		}
+		var header types.BlockHeader
+		err = encoding.Unmarshal(headerBytes, &header)
+		if err != nil {
+			return nil, err
+		}
+		// Rejected headers are reported with a false result rather than an
+		// error, as getwork miners expect.
+		err = m.SubmitHeader(header)
+		if err != nil {
+			m.log.Println("getwork header was rejected:", err)
+		}
+		return err == nil, nil
+	default:
+		return nil, errGetworkParams
+	}
+}
+
+// ServeGetwork starts serving the getwork protocol on the provided address.
+// The getwork server does not authenticate miners, so it should not be
+// exposed to untrusted networks.
+func (m *Miner) ServeGetwork(addr string) error {
+	if err := m.tg.Add(); err != nil {
+		return err
+	}
+	defer m.tg.Done()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.getworkListener != nil {
+		return errGetworkServing
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	m.getworkListener = l
+	m.tg.OnStop(func() {
+		l.Close()
+	})
+	m.log.Println("Serving getwork on", l.Addr())
+
+	go func() {
+		// Serve returns an error once the listener is closed during shutdown.
+		_ = http.Serve(l, http.HandlerFunc(m.getworkHandler))
+	}()
+	return nil
+}
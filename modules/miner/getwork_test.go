@@ -0,0 +1,92 @@
+package miner
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// callGetwork makes a getwork call to the miner's getwork server, decoding
+// the result into 'result'.
+func callGetwork(mt *minerTester, params []string, result interface{}) error {
+	reqBytes, err := json.Marshal(getworkRequest{
+		ID:     json.RawMessage("1"),
+		Method: "getwork",
+		Params: params,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post("http://"+mt.miner.getworkListener.Addr().String(), "application/json", bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	gr := getworkResponse{Result: result}
+	return json.NewDecoder(resp.Body).Decode(&gr)
+}
+
+// TestGetwork checks that an external miner can fetch work and submit solved
+// headers through the getwork server.
+func TestGetwork(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestGetwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.miner.ServeGetwork("localhost:0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.miner.ServeGetwork("localhost:0"); err != errGetworkServing {
+		t.Fatal("expected errGetworkServing, got", err)
+	}
+
+	// Fetch and decode some work.
+	var work getworkWork
+	if err := callGetwork(mt, nil, &work); err != nil {
+		t.Fatal(err)
+	}
+	headerBytes, err := hex.DecodeString(work.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header types.BlockHeader
+	if err := encoding.Unmarshal(headerBytes, &header); err != nil {
+		t.Fatal(err)
+	}
+	targetBytes, err := hex.DecodeString(work.Target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var target types.Target
+	copy(target[:], targetBytes)
+
+	// An unsolved header should be rejected.
+	var accepted bool
+	if err := callGetwork(mt, []string{hex.EncodeToString(encoding.Marshal(types.BlockHeader{}))}, &accepted); err != nil {
+		t.Fatal(err)
+	}
+	if accepted {
+		t.Fatal("unknown header was accepted")
+	}
+
+	// A solved header should extend the blockchain.
+	height := mt.cs.Height()
+	solved := solveHeader(header, target)
+	if err := callGetwork(mt, []string{hex.EncodeToString(encoding.Marshal(solved))}, &accepted); err != nil {
+		t.Fatal(err)
+	}
+	if !accepted {
+		t.Fatal("solved header was rejected")
+	}
+	if mt.cs.Height() != height+1 {
+		t.Fatal("solved header did not extend the blockchain")
+	}
+}
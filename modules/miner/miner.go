@@ -3,6 +3,7 @@ package miner
 import (
 	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -96,9 +97,20 @@ type Miner struct {
 	memProgress     int                                            // The index of the most recent header used in headerMem.
 
 	// CPUMiner variables.
-	miningOn bool  // indicates if the miner is supposed to be running
-	mining   bool  // indicates if the miner is actually running
-	hashRate int64 // indicates hashes per second
+	miningOn  bool          // indicates if the miner is supposed to be running
+	mining    int           // the number of mining threads that are running
+	threadID  int           // the id of the most recently started mining thread
+	hashRates map[int]int64 // hashes per second of each mining thread
+
+	// getworkListener accepts connections from external miners using the
+	// getwork protocol. It is nil unless ServeGetwork has been called.
+	getworkListener net.Listener
+
+	// templateID identifies the current block template, and is incremented
+	// whenever the template changes in a way that external miners should
+	// know about. templateChanged is closed and replaced at the same time.
+	templateID      uint64
+	templateChanged chan struct{}
 
 	// Utils
 	log        *persist.Logger
@@ -170,8 +182,13 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Walle
 		arbDataMem: make(map[types.BlockHeader][crypto.EntropySize]byte),
 		headerMem:  make([]types.BlockHeader, HeaderMemory),
 
+		hashRates: make(map[int]int64),
+
+		templateChanged: make(chan struct{}),
+
 		persistDir: persistDir,
 	}
+	m.tg.SetName("miner")
 
 	err := m.initPersist()
 	if err != nil {
@@ -264,3 +281,23 @@ func (m *Miner) BlocksMined() (goodBlocks, staleBlocks int) {
 	}
 	return
 }
+
+// BlocksOrphaned returns the number of blocks mined by the miner that were
+// part of the longest chain, but were later orphaned by a reorg.
+func (m *Miner) BlocksOrphaned() (orphanedBlocks int) {
+	if err := m.tg.Add(); err != nil {
+		build.Critical(err)
+	}
+	defer m.tg.Done()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A block that was orphaned may have been reapplied by a later reorg.
+	for _, blockID := range m.persist.BlocksOrphaned {
+		if !m.cs.InCurrentPath(blockID) {
+			orphanedBlocks++
+		}
+	}
+	return
+}
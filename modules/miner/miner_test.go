@@ -232,6 +232,74 @@ func TestIntegrationBlocksMined(t *testing.T) {
 	}
 }
 
+// TestIntegrationBlocksOrphaned checks that blocks mined by the miner which
+// are later reverted by a reorg are reported as orphaned.
+func TestIntegrationBlocksOrphaned(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt1, err := createMinerTester("TestIntegrationBlocksOrphaned - 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt2, err := createMinerTester("TestIntegrationBlocksOrphaned - 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a block on mt1 using the block manager so that it is recorded.
+	header, target, err := mt1.miner.HeaderForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mt1.miner.SubmitHeader(solveHeader(header, target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt1.miner.BlocksOrphaned() != 0 {
+		t.Fatal("block was orphaned before any reorg happened")
+	}
+
+	// Extend mt2 beyond mt1 and give mt1 the blocks, causing a reorg that
+	// orphans the block mined by mt1.
+	for mt2.cs.Height() <= mt1.cs.Height() {
+		_, err := mt2.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := types.BlockHeight(1); i <= mt2.cs.Height(); i++ {
+		b, _ := mt2.cs.BlockAtHeight(i)
+		err = mt1.cs.AcceptBlock(b)
+		if err != nil && err != modules.ErrNonExtendingBlock && err != modules.ErrBlockKnown {
+			t.Fatal(err)
+		}
+	}
+	if mt1.cs.CurrentBlock().ID() != mt2.cs.CurrentBlock().ID() {
+		t.Fatal("mt1 did not reorg to the chain of mt2")
+	}
+	goodBlocks, staleBlocks := mt1.miner.BlocksMined()
+	if goodBlocks != 0 || staleBlocks != 1 {
+		t.Errorf("expecting 0 good blocks and 1 stale block, got %v and %v", goodBlocks, staleBlocks)
+	}
+	if orphaned := mt1.miner.BlocksOrphaned(); orphaned != 1 {
+		t.Error("expecting 1 orphaned block, got", orphaned)
+	}
+
+	// Reboot the miner and verify that the orphaned block has persisted.
+	err = mt1.miner.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebootMiner, err := New(mt1.cs, mt1.tpool, mt1.wallet, filepath.Join(mt1.persistDir, modules.MinerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orphaned := rebootMiner.BlocksOrphaned(); orphaned != 1 {
+		t.Error("expecting 1 orphaned block after reboot, got", orphaned)
+	}
+}
+
 // TestIntegrationAutoRescan triggers a rescan during a call to New and
 // verifies that the rescanning happens correctly. The rescan is triggered by
 // a call to New, instead of getting called directly.
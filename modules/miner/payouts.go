@@ -0,0 +1,118 @@
+package miner
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errDuplicatePayoutAddress = errors.New("payout split lists the same address more than once")
+	errEmptyPayoutAddress     = errors.New("payout split has an empty address")
+	errPayoutSplitSum         = errors.New("payout split shares must sum to 100%")
+	errZeroPayoutShare        = errors.New("payout split has an address with no share")
+)
+
+// validatePayoutSplits checks that a set of payout splits sends the whole
+// block payout to distinct addresses. An empty set is valid, and sends the
+// payout to the wallet.
+func validatePayoutSplits(splits []modules.MinerPayoutSplit) error {
+	if len(splits) == 0 {
+		return nil
+	}
+	seen := make(map[types.UnlockHash]struct{})
+	var total uint64
+	for _, split := range splits {
+		if split.UnlockHash == (types.UnlockHash{}) {
+			return errEmptyPayoutAddress
+		}
+		if split.BasisPoints == 0 {
+			return errZeroPayoutShare
+		}
+		if _, exists := seen[split.UnlockHash]; exists {
+			return errDuplicatePayoutAddress
+		}
+		seen[split.UnlockHash] = struct{}{}
+		total += split.BasisPoints
+		if total > modules.MinerPayoutSplitTotal {
+			return errPayoutSplitSum
+		}
+	}
+	if total != modules.MinerPayoutSplitTotal {
+		return errPayoutSplitSum
+	}
+	return nil
+}
+
+// splitPayout divides a block payout between the split addresses. Payouts are
+// rounded down, and the remainder goes to the address with the largest share
+// so that the payouts sum to exactly the full payout. Payouts that round down
+// to zero are dropped, because blocks may not contain zero-value payouts.
+func splitPayout(payout types.Currency, splits []modules.MinerPayoutSplit) []types.SiacoinOutput {
+	outputs := make([]types.SiacoinOutput, len(splits))
+	remainder := payout
+	largest := 0
+	for i, split := range splits {
+		outputs[i] = types.SiacoinOutput{
+			Value:      payout.Mul64(split.BasisPoints).Div64(modules.MinerPayoutSplitTotal),
+			UnlockHash: split.UnlockHash,
+		}
+		remainder = remainder.Sub(outputs[i].Value)
+		if split.BasisPoints > splits[largest].BasisPoints {
+			largest = i
+		}
+	}
+	outputs[largest].Value = outputs[largest].Value.Add(remainder)
+
+	nonzero := outputs[:0]
+	for _, output := range outputs {
+		if !output.Value.IsZero() {
+			nonzero = append(nonzero, output)
+		}
+	}
+	return nonzero
+}
+
+// minerPayouts returns the miner payouts for a block with the provided
+// payout.
+func (m *Miner) minerPayouts(payout types.Currency) []types.SiacoinOutput {
+	if len(m.persist.PayoutSplits) == 0 {
+		return []types.SiacoinOutput{{Value: payout, UnlockHash: m.persist.Address}}
+	}
+	return splitPayout(payout, m.persist.PayoutSplits)
+}
+
+// PayoutSplits returns the addresses that block payouts are split between.
+// If no splits are set, the whole payout goes to an address from the wallet.
+func (m *Miner) PayoutSplits() []modules.MinerPayoutSplit {
+	if err := m.tg.Add(); err != nil {
+		return nil
+	}
+	defer m.tg.Done()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]modules.MinerPayoutSplit(nil), m.persist.PayoutSplits...)
+}
+
+// SetPayoutSplits sets the addresses that block payouts are split between.
+// The shares of the splits must sum to the full payout. Setting no splits
+// sends the whole payout to an address from the wallet.
+func (m *Miner) SetPayoutSplits(splits []modules.MinerPayoutSplit) error {
+	if err := m.tg.Add(); err != nil {
+		return err
+	}
+	defer m.tg.Done()
+	if err := validatePayoutSplits(splits); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.persist.PayoutSplits = append([]modules.MinerPayoutSplit(nil), splits...)
+	// Expire the source block so that new headers pay out to the new splits.
+	m.sourceBlockTime = time.Time{}
+	m.log.Printf("Block payouts are now split between %v addresses", len(splits))
+	return m.saveSync()
+}
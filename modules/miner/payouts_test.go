@@ -0,0 +1,88 @@
+package miner
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestValidatePayoutSplits probes the validatePayoutSplits function.
+func TestValidatePayoutSplits(t *testing.T) {
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	tests := []struct {
+		splits []modules.MinerPayoutSplit
+		err    error
+	}{
+		{nil, nil},
+		{[]modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 10000}}, nil},
+		{[]modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 9750}, {UnlockHash: b, BasisPoints: 250}}, nil},
+		{[]modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 9750}, {UnlockHash: b, BasisPoints: 200}}, errPayoutSplitSum},
+		{[]modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 9750}, {UnlockHash: b, BasisPoints: 300}}, errPayoutSplitSum},
+		{[]modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 10000}, {UnlockHash: b, BasisPoints: 0}}, errZeroPayoutShare},
+		{[]modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 5000}, {UnlockHash: a, BasisPoints: 5000}}, errDuplicatePayoutAddress},
+		{[]modules.MinerPayoutSplit{{UnlockHash: types.UnlockHash{}, BasisPoints: 10000}}, errEmptyPayoutAddress},
+	}
+	for i, test := range tests {
+		if err := validatePayoutSplits(test.splits); err != test.err {
+			t.Errorf("%v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestSplitPayout checks that split payouts sum to the full payout, with the
+// remainder going to the largest share, and that zero payouts are dropped.
+func TestSplitPayout(t *testing.T) {
+	a, b, c := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	outputs := splitPayout(types.NewCurrency64(1001), []modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 250}, {UnlockHash: b, BasisPoints: 9750}})
+	if len(outputs) != 2 || outputs[0].Value.Cmp(types.NewCurrency64(25)) != 0 || outputs[1].Value.Cmp(types.NewCurrency64(976)) != 0 {
+		t.Fatal("payout was split incorrectly:", outputs)
+	}
+
+	outputs = splitPayout(types.NewCurrency64(10), []modules.MinerPayoutSplit{{UnlockHash: a, BasisPoints: 1}, {UnlockHash: b, BasisPoints: 9998}, {UnlockHash: c, BasisPoints: 1}})
+	if len(outputs) != 1 || outputs[0].UnlockHash != b || outputs[0].Value.Cmp(types.NewCurrency64(10)) != 0 {
+		t.Fatal("zero payouts were not dropped:", outputs)
+	}
+}
+
+// TestIntegrationPayoutSplits checks that mined blocks split their payouts
+// between the configured addresses.
+func TestIntegrationPayoutSplits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationPayoutSplits")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	splits := []modules.MinerPayoutSplit{{UnlockHash: types.UnlockHash{1}, BasisPoints: 9750}, {UnlockHash: types.UnlockHash{2}, BasisPoints: 250}}
+	if err := mt.miner.SetPayoutSplits(splits[:1]); err != errPayoutSplitSum {
+		t.Fatal("expected errPayoutSplitSum, got", err)
+	}
+	if err := mt.miner.SetPayoutSplits(splits); err != nil {
+		t.Fatal(err)
+	}
+	if len(mt.miner.PayoutSplits()) != 2 {
+		t.Fatal("payout splits were not set")
+	}
+	b, err := mt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.MinerPayouts) != 2 || b.MinerPayouts[0].UnlockHash != splits[0].UnlockHash || b.MinerPayouts[1].UnlockHash != splits[1].UnlockHash {
+		t.Fatal("block payouts were not split:", b.MinerPayouts)
+	}
+
+	// Resetting the splits sends the payout to the wallet.
+	if err := mt.miner.SetPayoutSplits(nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err = mt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.MinerPayouts) != 1 || b.MinerPayouts[0].UnlockHash == splits[0].UnlockHash {
+		t.Fatal("block payout was not sent to the wallet:", b.MinerPayouts)
+	}
+}
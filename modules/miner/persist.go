@@ -24,12 +24,21 @@ var (
 type (
 	// persist contains all of the persistent miner data.
 	persistence struct {
-		RecentChange  modules.ConsensusChangeID
-		Height        types.BlockHeight
-		Target        types.Target
-		Address       types.UnlockHash
-		BlocksFound   []types.BlockID
-		UnsolvedBlock types.Block
+		RecentChange   modules.ConsensusChangeID
+		Height         types.BlockHeight
+		Target         types.Target
+		Address        types.UnlockHash
+		BlocksFound    []types.BlockID
+		BlocksOrphaned []types.BlockID
+		UnsolvedBlock  types.Block
+
+		// PayoutSplits are the addresses that block payouts are split
+		// between. If empty, the whole payout goes to Address.
+		PayoutSplits []modules.MinerPayoutSplit
+
+		// CPUSettings control the thread count, duty cycle, and schedule of
+		// the cpu miner.
+		CPUSettings modules.MinerCPUSettings
 	}
 )
 
@@ -0,0 +1,205 @@
+package miner
+
+// template.go selects the transactions that go into the blocks handed out for
+// work, and tracks changes to the block template so that external miners can
+// wait for a better block to work on.
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errTemplateWaitStopped = errors.New("miner was closed while waiting for a new template")
+)
+
+type (
+	// A txnSet is a group of unconfirmed transactions that depend on each
+	// other, and must therefore be included in a block together. The
+	// transactions are kept in the order that the transaction pool provided
+	// them, which places parents before their children.
+	txnSet struct {
+		txns    []types.Transaction
+		feeRate types.Currency
+		size    int
+	}
+
+	// txnSetsByFeeRate implements sort.Interface, ordering sets from highest
+	// to lowest fee rate.
+	txnSetsByFeeRate []txnSet
+)
+
+func (ts txnSetsByFeeRate) Len() int           { return len(ts) }
+func (ts txnSetsByFeeRate) Swap(i, j int)      { ts[i], ts[j] = ts[j], ts[i] }
+func (ts txnSetsByFeeRate) Less(i, j int) bool { return ts[i].feeRate.Cmp(ts[j].feeRate) > 0 }
+
+// groupTransactions splits a list of unconfirmed transactions into sets of
+// dependent transactions. Two transactions are dependent if one spends or
+// revises an object created by the other.
+func groupTransactions(txns []types.Transaction) []txnSet {
+	// Union the index of each transaction with the indices of the
+	// transactions that created the objects it uses.
+	parent := make([]int, len(txns))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	creators := make(map[crypto.Hash]int)
+	for i, txn := range txns {
+		var used []crypto.Hash
+		for _, sci := range txn.SiacoinInputs {
+			used = append(used, crypto.Hash(sci.ParentID))
+		}
+		for _, sfi := range txn.SiafundInputs {
+			used = append(used, crypto.Hash(sfi.ParentID))
+		}
+		for _, fcr := range txn.FileContractRevisions {
+			used = append(used, crypto.Hash(fcr.ParentID))
+		}
+		for _, sp := range txn.StorageProofs {
+			used = append(used, crypto.Hash(sp.ParentID))
+		}
+		for _, id := range used {
+			if j, exists := creators[id]; exists {
+				parent[find(i)] = find(j)
+			}
+		}
+
+		for j := range txn.SiacoinOutputs {
+			creators[crypto.Hash(txn.SiacoinOutputID(uint64(j)))] = i
+		}
+		for j := range txn.FileContracts {
+			creators[crypto.Hash(txn.FileContractID(uint64(j)))] = i
+		}
+		for j := range txn.SiafundOutputs {
+			creators[crypto.Hash(txn.SiafundOutputID(uint64(j)))] = i
+		}
+	}
+
+	// Assemble the sets, ordered by the position of their first transaction.
+	setIndex := make(map[int]int)
+	var sets []txnSet
+	for i, txn := range txns {
+		root := find(i)
+		si, exists := setIndex[root]
+		if !exists {
+			si = len(sets)
+			setIndex[root] = si
+			sets = append(sets, txnSet{})
+		}
+		sets[si].txns = append(sets[si].txns, txn)
+	}
+	for i := range sets {
+		sets[i].feeRate = modules.CalculateFee(sets[i].txns)
+		sets[i].size = len(encoding.Marshal(sets[i].txns))
+	}
+	return sets
+}
+
+// selectTransactions picks the unconfirmed transactions that pay the highest
+// fee rate and fit in a block. Dependent transactions are selected together,
+// and sets that do not fit in the remaining space are skipped in favor of
+// smaller sets that do.
+func selectTransactions(txns []types.Transaction) []types.Transaction {
+	sets := groupTransactions(txns)
+	// A stable sort keeps the transaction pool's order for sets that pay the
+	// same fee rate.
+	sort.Stable(txnSetsByFeeRate(sets))
+
+	var selected []types.Transaction
+	remaining := int(modules.BlockTransactionCapacity)
+	for _, set := range sets {
+		if set.size > remaining {
+			continue
+		}
+		selected = append(selected, set.txns...)
+		remaining -= set.size
+	}
+	return selected
+}
+
+// totalFees returns the sum of the miner fees paid by a list of transactions.
+func totalFees(txns []types.Transaction) types.Currency {
+	var fees types.Currency
+	for _, txn := range txns {
+		for _, fee := range txn.MinerFees {
+			fees = fees.Add(fee)
+		}
+	}
+	return fees
+}
+
+// updateTemplate marks that the block template has changed, waking any
+// callers waiting for a new template.
+func (m *Miner) updateTemplate() {
+	m.templateID++
+	close(m.templateChanged)
+	m.templateChanged = make(chan struct{})
+}
+
+// blockTemplate returns the current block template.
+func (m *Miner) blockTemplate() modules.BlockTemplate {
+	b := m.persist.UnsolvedBlock
+	fees := totalFees(b.Transactions)
+	return modules.BlockTemplate{
+		ID:           m.templateID,
+		ParentID:     b.ParentID,
+		Height:       m.persist.Height + 1,
+		Target:       m.persist.Target,
+		Transactions: len(b.Transactions),
+		Size:         uint64(len(encoding.Marshal(b.Transactions))),
+		Fees:         fees,
+		Payout:       types.CalculateCoinbase(m.persist.Height + 1).Add(fees),
+	}
+}
+
+// BlockTemplate returns the template of the blocks that the miner is handing
+// out for work.
+func (m *Miner) BlockTemplate() modules.BlockTemplate {
+	if err := m.tg.Add(); err != nil {
+		return modules.BlockTemplate{}
+	}
+	defer m.tg.Done()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.blockTemplate()
+}
+
+// WaitForTemplate blocks until the miner's block template is no longer the
+// template with the provided id, or until the timeout elapses, and then
+// returns the current template. It returns immediately if the current
+// template has a different id.
+func (m *Miner) WaitForTemplate(id uint64, timeout time.Duration) (modules.BlockTemplate, error) {
+	if err := m.tg.Add(); err != nil {
+		return modules.BlockTemplate{}, err
+	}
+	defer m.tg.Done()
+
+	m.mu.RLock()
+	current := m.templateID
+	changed := m.templateChanged
+	m.mu.RUnlock()
+	if current == id {
+		select {
+		case <-changed:
+		case <-time.After(timeout):
+		case <-m.tg.StopChan():
+			return modules.BlockTemplate{}, errTemplateWaitStopped
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.blockTemplate(), nil
+}
@@ -0,0 +1,93 @@
+package miner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSelectTransactions checks that transactions are selected by the fee
+// rate of their dependency sets, and that sets that do not fit are skipped.
+func TestSelectTransactions(t *testing.T) {
+	parent := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(1)}},
+	}
+	child := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: parent.SiacoinOutputID(0)}},
+		MinerFees:     []types.Currency{types.NewCurrency64(100e3)},
+	}
+	cheap := types.Transaction{
+		MinerFees:     []types.Currency{types.NewCurrency64(1e3)},
+		ArbitraryData: [][]byte{{1}},
+	}
+	huge := types.Transaction{
+		MinerFees:     []types.Currency{types.NewCurrency64(100e9)},
+		ArbitraryData: [][]byte{make([]byte, modules.BlockTransactionCapacity)},
+	}
+
+	sets := groupTransactions([]types.Transaction{cheap, parent, huge, child})
+	if len(sets) != 3 || len(sets[1].txns) != 2 {
+		t.Fatal("parent and child were not grouped together:", len(sets))
+	}
+
+	// The parent and child pay a higher fee rate than the cheap transaction,
+	// so they should come first, in their original order. The huge
+	// transaction pays the highest fee rate but does not fit.
+	selected := selectTransactions([]types.Transaction{cheap, parent, huge, child})
+	expected := []types.TransactionID{parent.ID(), child.ID(), cheap.ID()}
+	if len(selected) != len(expected) {
+		t.Fatal("wrong number of transactions selected:", len(selected))
+	}
+	for i, txn := range selected {
+		if txn.ID() != expected[i] {
+			t.Error("wrong transaction at index", i)
+		}
+	}
+}
+
+// TestWaitForTemplate checks that waiting for a new template returns when a
+// block is found, and times out otherwise.
+func TestWaitForTemplate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestWaitForTemplate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bt := mt.miner.BlockTemplate()
+	if bt.ParentID != mt.cs.CurrentBlock().ID() || bt.Height != mt.cs.Height()+1 {
+		t.Fatal("template does not build on the current block")
+	}
+	if bt.Payout.Cmp(types.CalculateCoinbase(bt.Height).Add(bt.Fees)) != 0 {
+		t.Fatal("template payout does not include the fees")
+	}
+
+	// A different id returns immediately.
+	if current, err := mt.miner.WaitForTemplate(bt.ID+1, time.Minute); err != nil || current.ID != bt.ID {
+		t.Fatal("expected the current template, got", current, err)
+	}
+	// The current id times out without a change.
+	if current, err := mt.miner.WaitForTemplate(bt.ID, 10*time.Millisecond); err != nil || current.ID != bt.ID {
+		t.Fatal("expected the same template after timing out, got", current, err)
+	}
+
+	// Finding a block changes the template.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		next, err := mt.miner.WaitForTemplate(bt.ID, time.Minute)
+		if err != nil {
+			t.Error(err)
+		} else if next.ID == bt.ID || next.Height != bt.Height+1 {
+			t.Error("template did not change after a block was found:", next)
+		}
+	}()
+	if _, err := mt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
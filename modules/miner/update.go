@@ -1,7 +1,8 @@
 package miner
 
 import (
-	"github.com/NebulousLabs/Sia/encoding"
+	"time"
+
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -37,6 +38,27 @@ func (m *Miner) ProcessConsensusChange(cc modules.ConsensusChange) {
 		}
 	}
 
+	// Record any blocks found by the miner that were orphaned by the change.
+	// Only reverted blocks need to be checked, which keeps hashing to a
+	// minimum during IBD.
+	if len(cc.RevertedBlocks) > 0 && len(m.persist.BlocksFound) > 0 {
+		found := make(map[types.BlockID]struct{}, len(m.persist.BlocksFound))
+		for _, id := range m.persist.BlocksFound {
+			found[id] = struct{}{}
+		}
+		for _, id := range m.persist.BlocksOrphaned {
+			delete(found, id)
+		}
+		for _, block := range cc.RevertedBlocks {
+			id := block.ID()
+			if _, exists := found[id]; exists {
+				m.persist.BlocksOrphaned = append(m.persist.BlocksOrphaned, id)
+				delete(found, id)
+				m.log.Printf("WARN: block %v found by the miner was orphaned by a reorg", id)
+			}
+		}
+	}
+
 	// Update the unsolved block.
 	m.persist.UnsolvedBlock.ParentID = cc.AppliedBlocks[len(cc.AppliedBlocks)-1].ID()
 	m.persist.Target = cc.ChildTarget
@@ -45,6 +67,7 @@ func (m *Miner) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// There is a new parent block, the source block should be updated to keep
 	// the stale rate as low as possible.
 	m.newSourceBlock()
+	m.updateTemplate()
 	m.persist.RecentChange = cc.ID
 	err := m.save()
 	if err != nil {
@@ -58,22 +81,17 @@ func (m *Miner) ReceiveUpdatedUnconfirmedTransactions(unconfirmedTransactions []
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Edge case - if there are no transactions, set the block's transactions
-	// to nil and return.
-	if len(unconfirmedTransactions) == 0 {
-		m.persist.UnsolvedBlock.Transactions = nil
-		return
-	}
-
-	// Add transactions to the block until the block size limit is reached.
-	// Transactions are assumed to be in a sensible order.
-	var i int
-	remainingSize := int(types.BlockSizeLimit - 5e3)
-	for i = range unconfirmedTransactions {
-		remainingSize -= len(encoding.Marshal(unconfirmedTransactions[i]))
-		if remainingSize < 0 {
-			break
-		}
+	// Fill the block with the transactions that pay the highest fee rate. If
+	// the new transactions pay more than the current ones, expire the source
+	// block so that the next header uses them, and let external miners know
+	// that a better template is available. Otherwise the transactions are
+	// picked up by the next source block. The source block is not replaced
+	// here because that may call out to the wallet while the transaction pool
+	// is locked.
+	oldFees := totalFees(m.persist.UnsolvedBlock.Transactions)
+	m.persist.UnsolvedBlock.Transactions = selectTransactions(unconfirmedTransactions)
+	if totalFees(m.persist.UnsolvedBlock.Transactions).Cmp(oldFees) > 0 {
+		m.sourceBlockTime = time.Time{}
+		m.updateTemplate()
 	}
-	m.persist.UnsolvedBlock.Transactions = unconfirmedTransactions[:i+1]
 }
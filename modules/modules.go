@@ -9,6 +9,27 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 )
 
+// A HealthChecker is a module that can report whether it is able to do its
+// job. The daemon aggregates the reports of its modules so that supervisors
+// can tell when the daemon is ready for use.
+type HealthChecker interface {
+	// Healthy returns nil if the module is working normally, and an error
+	// describing the problem otherwise.
+	Healthy() error
+}
+
+// A ProgressFunc is called by long operations with the fraction of the
+// operation that has completed, between 0 and 1. A nil ProgressFunc ignores
+// the progress.
+type ProgressFunc func(float64)
+
+// Report reports the fraction of an operation that has completed.
+func (p ProgressFunc) Report(fraction float64) {
+	if p != nil {
+		p(fraction)
+	}
+}
+
 var (
 	// SafeMutexDelay is the recommended timeout for the deadlock detecting
 	// mutex. This value is DEPRECATED, as safe mutexes are no longer
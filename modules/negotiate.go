@@ -111,6 +111,15 @@ var (
 	// sector.
 	ActionInsert = types.Specifier{'I', 'n', 's', 'e', 'r', 't'}
 
+	// ActionInsertRoot is the specifier for a RevisionAction that inserts a
+	// sector that the host is already storing, identified by its Merkle root.
+	ActionInsertRoot = types.Specifier{'I', 'n', 's', 'e', 'r', 't', 'R', 'o', 'o', 't'}
+
+	// ActionInsertResumable is the specifier for a RevisionAction that
+	// inserts a sector whose data is sent separately from the actions, so
+	// that an interrupted transfer can be resumed.
+	ActionInsertResumable = types.Specifier{'I', 'n', 's', 'e', 'r', 't', 'R', 'e', 's', 'u', 'm', 'e'}
+
 	// ActionModify is the specifier for a RevisionAction that modifies sector
 	// data.
 	ActionModify = types.Specifier{'M', 'o', 'd', 'i', 'f', 'y'}
@@ -182,6 +191,73 @@ var (
 	}()
 )
 
+// A HostFeature is an optional capability of the renter-host protocol. Hosts
+// do not advertise features explicitly; support for a feature is inferred
+// from the Version field of the host's external settings. This allows the
+// renter to select the correct code path for each contract while the network
+// is running a mix of host versions.
+type HostFeature string
+
+const (
+	// HostFeatureCollateralCap indicates that the host will accept upload
+	// revisions in which the renter has capped the collateral per sector.
+	HostFeatureCollateralCap HostFeature = "collateralcap"
+
+	// HostFeaturePriceTolerance indicates that the host tolerates small
+	// discrepancies (such as those caused by differing block heights) in the
+	// price and collateral of an upload revision.
+	HostFeaturePriceTolerance HostFeature = "pricetolerance"
+
+	// HostFeatureSectorDedup indicates that the host accepts ActionInsertRoot,
+	// allowing the renter to add a sector that the host already stores to a
+	// contract without uploading the data again.
+	HostFeatureSectorDedup HostFeature = "sectordedup"
+
+	// HostFeatureResumableUpload indicates that the host accepts
+	// ActionInsertResumable, and keeps the part of a sector that it received
+	// before an upload was interrupted.
+	HostFeatureResumableUpload HostFeature = "resumableupload"
+)
+
+// hostFeatureVersions lists each HostFeature alongside the earliest host
+// version that supports it. The list is kept in alphabetical order.
+var hostFeatureVersions = []struct {
+	feature HostFeature
+	version string
+}{
+	{HostFeatureCollateralCap, "0.6.1"},
+	{HostFeaturePriceTolerance, "1.0.2"},
+	{HostFeatureResumableUpload, "1.0.4"},
+	{HostFeatureSectorDedup, "1.0.4"},
+}
+
+// HostSupportsFeature returns true if a host running the provided version
+// supports the given feature. Unknown features and invalid versions are never
+// supported.
+func HostSupportsFeature(version string, f HostFeature) bool {
+	if !build.IsVersion(version) {
+		return false
+	}
+	for _, fv := range hostFeatureVersions {
+		if fv.feature == f {
+			return build.VersionCmp(version, fv.version) >= 0
+		}
+	}
+	return false
+}
+
+// HostFeatures returns the set of features supported by a host running the
+// provided version.
+func HostFeatures(version string) []HostFeature {
+	var features []HostFeature
+	for _, fv := range hostFeatureVersions {
+		if HostSupportsFeature(version, fv.feature) {
+			features = append(features, fv.feature)
+		}
+	}
+	return features
+}
+
 type (
 	// A DownloadAction is a description of a download that the renter would
 	// like to make. The MerkleRoot indicates the root of the sector, the
@@ -266,13 +342,20 @@ type (
 	}
 
 	// A RevisionAction is a description of an edit to be performed on a file
-	// contract. Three types are allowed, 'ActionDelete', 'ActionInsert', and
-	// 'ActionModify'. ActionDelete just takes a sector index, indicating which
-	// sector is going to be deleted. ActionInsert takes a sector index, and a
-	// full sector of data, indicating that a sector at the index should be
-	// inserted with the provided data. 'Modify' revises the sector at the
-	// given index, rewriting it with the provided data starting from the
-	// 'offset' within the sector.
+	// contract. Five types are allowed, 'ActionDelete', 'ActionInsert',
+	// 'ActionInsertRoot', 'ActionInsertResumable', and 'ActionModify'. ActionDelete just takes a sector
+	// index, indicating which sector is going to be deleted. ActionInsert
+	// takes a sector index, and a full sector of data, indicating that a
+	// sector at the index should be inserted with the provided data.
+	// ActionInsertRoot takes a sector index, and the Merkle root of a sector
+	// that the host is already storing in place of the data, indicating that
+	// the sector should be inserted without being uploaded again.
+	// ActionInsertResumable takes a sector index, and the Merkle root of the
+	// sector in place of the data. After reading the actions, the host writes
+	// the number of bytes of the sector that it already received during an
+	// interrupted upload, and the renter sends the rest of the sector. 'Modify'
+	// revises the sector at the given index, rewriting it with the provided
+	// data starting from the 'offset' within the sector.
 	//
 	// Modify could be simulated with an insert and a delete, however an insert
 	// requires a full sector to be uploaded, and a modify can be just a few
@@ -128,3 +128,43 @@ func TestNegotiationResponses(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestHostSupportsFeature checks that host features are gated on the correct
+// host versions.
+func TestHostSupportsFeature(t *testing.T) {
+	tests := []struct {
+		version  string
+		feature  HostFeature
+		expected bool
+	}{
+		{"0.6.0", HostFeatureCollateralCap, false},
+		{"0.6.1", HostFeatureCollateralCap, true},
+		{"1.0.1", HostFeaturePriceTolerance, false},
+		{"1.0.2", HostFeaturePriceTolerance, true},
+		{"1.0.3", HostFeaturePriceTolerance, true},
+		{"1.0.3", HostFeatureSectorDedup, false},
+		{"1.0.4", HostFeatureSectorDedup, true},
+		{"1.0.3", HostFeatureResumableUpload, false},
+		{"1.0.4", HostFeatureResumableUpload, true},
+		{"", HostFeatureCollateralCap, false},
+		{"foo", HostFeatureCollateralCap, false},
+	}
+	for _, test := range tests {
+		if HostSupportsFeature(test.version, test.feature) != test.expected {
+			t.Errorf("HostSupportsFeature(%q, %v): expected %v", test.version, test.feature, test.expected)
+		}
+	}
+
+	if len(HostFeatures("0.5.0")) != 0 {
+		t.Error("old host should not support any features")
+	}
+	if len(HostFeatures("1.0.2")) != 2 {
+		t.Error("v1.0.2 host should support all features except sector dedup")
+	}
+	if len(HostFeatures("1.0.3")) != 2 {
+		t.Error("v1.0.3 host should only support the features of v1.0.2")
+	}
+	if len(HostFeatures("1.0.4")) != len(hostFeatureVersions) {
+		t.Error("new host should support all features")
+	}
+}
@@ -60,6 +60,13 @@ func (na NetAddress) IsLoopback() bool {
 	return false
 }
 
+// IsIPv6 returns true if the host of the NetAddress is an IPv6 address.
+// Hostnames and IPv4 addresses return false.
+func (na NetAddress) IsIPv6() bool {
+	ip := net.ParseIP(na.Host())
+	return ip != nil && ip.To4() == nil
+}
+
 // IsLocal returns true if the input IP address belongs to a local address
 // range such as 192.168.x.x or 127.x.x.x
 func (na NetAddress) IsLocal() bool {
@@ -108,14 +115,27 @@ func (na NetAddress) IsValid() error {
 // IsStdValid returns an error if the NetAddress is invalid. A valid NetAddress
 // is of the form "host:port", such that "host" is either a valid IPv4/IPv6
 // address or a valid hostname, and "port" is an integer in the range
-// [1,65535]. Valid IPv4 addresses, IPv6 addresses, and hostnames are detailed
-// in RFCs 791, 2460, and 952, respectively.
+// [1,65535]. IPv6 addresses must be enclosed in brackets, as in "[::1]:9981",
+// and only IPv6 addresses may be enclosed in brackets. Valid IPv4 addresses,
+// IPv6 addresses, and hostnames are detailed in RFCs 791, 2460, and 952,
+// respectively.
 func (na NetAddress) IsStdValid() error {
 	// Verify the port number.
 	host, port, err := net.SplitHostPort(string(na))
 	if err != nil {
 		return err
 	}
+	// Brackets are only used to separate the colons of an IPv6 address from
+	// the port. Allowing them around other hosts, or around IPv4-mapped IPv6
+	// addresses, would give the same node several addresses.
+	if strings.HasPrefix(string(na), "[") {
+		if strings.Contains(host, "%") {
+			return errors.New("IPv6 zones are not supported")
+		}
+		if ip := net.ParseIP(host); ip == nil || ip.To4() != nil {
+			return errors.New("only IPv6 addresses may be enclosed in brackets")
+		}
+	}
 	portInt, err := strconv.Atoi(port)
 	if err != nil {
 		return errors.New("port is not an integer")
@@ -74,6 +74,13 @@ var (
 		"foo:1000000",
 		"localhost:0",
 		"[::1]:0",
+		// Brackets around hosts that aren't IPv6 addresses
+		"[12.34.45.64]:123",
+		"[::ffff:12.34.45.64]:123",
+		"[foo.com]:123",
+		"[localhost]:123",
+		// IPv6 zones
+		"[fe80::1%eth0]:123",
 	}
 	validAddrs = []string{
 		// Loopback address (valid in testing only, can't really test this well)
@@ -95,6 +102,8 @@ var (
 		strings.Repeat(strings.Repeat("a", 63)+".", 3) + "a:123", // 3x63 char length labels + 1x1 char length label without trailing dot
 		strings.Repeat(strings.Repeat("a", 63)+".", 3) + ":123",  // 3x63 char length labels with trailing dot
 		"[::2]:65535",
+		"[2001:db8::1]:9981",
+		"[2001:0db8:0000:0000:0000:0000:0000:0001]:9981",
 		"111.111.111.111:111",
 		"12.34.45.64:7777",
 	}
@@ -234,6 +243,27 @@ func TestIsValid(t *testing.T) {
 	}
 }
 
+// TestIsIPv6 checks that only IPv6 addresses are reported as such.
+func TestIsIPv6(t *testing.T) {
+	t.Parallel()
+
+	testSet := []struct {
+		query           NetAddress
+		desiredResponse bool
+	}{
+		{"[::1]:1234", true},
+		{"[2001:db8::1]:9981", true},
+		{"12.34.45.64:7777", false},
+		{"hn.com:8811", false},
+		{"2001:db8::1", false},
+	}
+	for _, test := range testSet {
+		if test.query.IsIPv6() != test.desiredResponse {
+			t.Errorf("IsIPv6(%v) should return %v", test.query, test.desiredResponse)
+		}
+	}
+}
+
 // TestIsLocal checks that the correct values are returned for all local IP
 // addresses.
 func TestIsLocal(t *testing.T) {
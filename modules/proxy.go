@@ -0,0 +1,178 @@
+package modules
+
+// proxy.go allows all outbound connections to peers and hosts to be routed
+// through a SOCKS5 proxy, such as the one provided by Tor. Hostnames are sent
+// to the proxy unresolved, so that DNS lookups do not bypass the proxy. Only
+// the CONNECT command without authentication is supported, which is all that
+// Tor requires.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	socks5Version        = 5
+	socks5NoAuth         = 0
+	socks5CmdConnect     = 1
+	socks5AddrIPv4       = 1
+	socks5AddrDomain     = 3
+	socks5AddrIPv6       = 4
+	socks5ReplySucceeded = 0
+)
+
+var (
+	errProxyAuthRequired = errors.New("proxy requires authentication, which is not supported")
+	errProxyBadVersion   = errors.New("proxy is not a SOCKS5 proxy")
+	errProxyHostTooLong  = errors.New("hostname is too long to be sent to the proxy")
+
+	// proxyAddr is the address of the SOCKS5 proxy that outbound connections
+	// are routed through. It is empty if no proxy is used.
+	proxyAddr   NetAddress
+	proxyAddrMu sync.RWMutex
+
+	// socks5Replies describes the errors that a SOCKS5 proxy can reply with.
+	socks5Replies = []string{
+		1: "general SOCKS server failure",
+		2: "connection not allowed by ruleset",
+		3: "network unreachable",
+		4: "host unreachable",
+		5: "connection refused",
+		6: "TTL expired",
+		7: "command not supported",
+		8: "address type not supported",
+	}
+)
+
+// SetProxy routes all outbound connections made with Dial through the SOCKS5
+// proxy at the provided address. An empty address disables the proxy.
+func SetProxy(addr NetAddress) error {
+	if addr != "" {
+		if addr.Host() == "" || addr.Port() == "" {
+			return fmt.Errorf("invalid proxy address %q", addr)
+		}
+	}
+	proxyAddrMu.Lock()
+	defer proxyAddrMu.Unlock()
+	proxyAddr = addr
+	return nil
+}
+
+// Proxy returns the address of the SOCKS5 proxy that outbound connections are
+// routed through, or the empty string if no proxy is used. Modules should not
+// reveal the IP address of the node, for example by discovering it or by
+// announcing it, while a proxy is in use.
+func Proxy() NetAddress {
+	proxyAddrMu.RLock()
+	defer proxyAddrMu.RUnlock()
+	return proxyAddr
+}
+
+// Dial connects to the provided address using the provided dialer. If a proxy
+// has been set, the connection is made through the proxy, and the dialer's
+// timeout also applies to the proxy handshake.
+func Dial(dialer *net.Dialer, addr NetAddress) (net.Conn, error) {
+	proxy := Proxy()
+	if proxy == "" {
+		return dialer.Dial("tcp", string(addr))
+	}
+
+	conn, err := dialer.Dial("tcp", string(proxy))
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to proxy: %v", err)
+	}
+	if dialer.Timeout != 0 {
+		conn.SetDeadline(time.Now().Add(dialer.Timeout))
+	}
+	if err := socks5Connect(conn, addr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy could not connect to %v: %v", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// socks5Connect performs the SOCKS5 handshake on a connection to a proxy,
+// asking the proxy to connect to the provided address.
+func socks5Connect(conn net.Conn, addr NetAddress) error {
+	host := addr.Host()
+	port, err := strconv.Atoi(addr.Port())
+	if err != nil || port < 0 || port > 65535 {
+		return fmt.Errorf("invalid port in address %q", addr)
+	}
+
+	// Negotiate the authentication method; only 'no authentication' is
+	// offered.
+	if _, err := conn.Write([]byte{socks5Version, 1, socks5NoAuth}); err != nil {
+		return err
+	}
+	resp := make([]byte, 2)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[0] != socks5Version {
+		return errProxyBadVersion
+	}
+	if resp[1] != socks5NoAuth {
+		return errProxyAuthRequired
+	}
+
+	// Send the connect request. Hostnames are sent as is, so that they are
+	// resolved by the proxy.
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errProxyHostTooLong
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AddrIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AddrIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Read the reply. The bound address that follows the header is not
+	// needed, but has to be read so that it does not end up in the stream.
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return errProxyBadVersion
+	}
+	if header[1] != socks5ReplySucceeded {
+		if int(header[1]) < len(socks5Replies) {
+			return errors.New(socks5Replies[header[1]])
+		}
+		return fmt.Errorf("unknown SOCKS5 error %v", header[1])
+	}
+	var boundLen int
+	switch header[3] {
+	case socks5AddrIPv4:
+		boundLen = net.IPv4len
+	case socks5AddrIPv6:
+		boundLen = net.IPv6len
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		boundLen = int(l[0])
+	default:
+		return fmt.Errorf("unknown address type %v in proxy reply", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, boundLen+2))
+	return err
+}
@@ -0,0 +1,141 @@
+package modules
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serveSOCKS5 accepts a single connection on the listener, performs the
+// server side of the SOCKS5 handshake, and connects the client to the
+// requested address. The requested host is sent on the returned channel.
+func serveSOCKS5(t *testing.T, l net.Listener) <-chan string {
+	hosts := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Method negotiation.
+		buf := make([]byte, 3)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Write([]byte{socks5Version, socks5NoAuth})
+
+		// Connect request.
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			t.Error(err)
+			return
+		}
+		var host string
+		switch header[3] {
+		case socks5AddrIPv4:
+			ip := make([]byte, net.IPv4len)
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case socks5AddrDomain:
+			l := make([]byte, 1)
+			io.ReadFull(conn, l)
+			name := make([]byte, l[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(conn, port)
+		hosts <- host
+
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))))
+		if err != nil {
+			conn.Write([]byte{socks5Version, 5, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		conn.Write([]byte{socks5Version, socks5ReplySucceeded, 0, socks5AddrIPv4, 127, 0, 0, 1, 0, 0})
+		go io.Copy(target, conn)
+		io.Copy(conn, target)
+	}()
+	return hosts
+}
+
+// TestDialProxy checks that Dial connects through the proxy when one is set,
+// and that hostnames are resolved by the proxy.
+func TestDialProxy(t *testing.T) {
+	// Create a target that echoes a single byte.
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			b := make([]byte, 1)
+			io.ReadFull(conn, b)
+			conn.Write(b)
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(target.Addr().String())
+
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	if err := SetProxy(NetAddress(proxy.Addr().String())); err != nil {
+		t.Fatal(err)
+	}
+	defer SetProxy("")
+
+	for _, host := range []string{"127.0.0.1", "localhost"} {
+		hosts := serveSOCKS5(t, proxy)
+		conn, err := Dial(&net.Dialer{Timeout: 5 * time.Second}, NetAddress(net.JoinHostPort(host, port)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requested := <-hosts; requested != host {
+			t.Errorf("proxy was asked for %q, expected %q", requested, host)
+		}
+		conn.Write([]byte{42})
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(conn, b); err != nil || b[0] != 42 {
+			t.Error("data was not relayed through the proxy:", b, err)
+		}
+		conn.Close()
+	}
+
+	// Refused connections should be reported.
+	target.Close()
+	serveSOCKS5(t, proxy)
+	_, err = Dial(&net.Dialer{Timeout: 5 * time.Second}, NetAddress(net.JoinHostPort("127.0.0.1", port)))
+	if err == nil {
+		t.Fatal("expected an error when the proxy cannot connect")
+	}
+}
+
+// TestSetProxy checks that invalid proxy addresses are rejected.
+func TestSetProxy(t *testing.T) {
+	defer SetProxy("")
+	if err := SetProxy("localhost"); err == nil {
+		t.Error("expected an error for an address without a port")
+	}
+	if err := SetProxy("localhost:9050"); err != nil {
+		t.Fatal(err)
+	}
+	if Proxy() != "localhost:9050" {
+		t.Error("proxy was not set:", Proxy())
+	}
+	if err := SetProxy(""); err != nil || Proxy() != "" {
+		t.Error("proxy was not cleared:", Proxy(), err)
+	}
+}
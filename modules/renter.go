@@ -12,6 +12,18 @@ const (
 	// RenterDir is the name of the directory that is used to store the
 	// renter's persistent data.
 	RenterDir = "renter"
+
+	// KeySourceRenter indicates that the encryption key of a file was
+	// generated by the renter.
+	KeySourceRenter = "renter"
+
+	// KeySourceCaller indicates that the encryption key of a file was
+	// supplied by the caller that uploaded the file.
+	KeySourceCaller = "caller"
+
+	// KeySourcePreEncrypted indicates that the data of a file was encrypted
+	// before it was uploaded, and that the renter did not encrypt it.
+	KeySourcePreEncrypted = "preencrypted"
 )
 
 // An ErasureCoder is an error-correcting encoder and decoder.
@@ -42,6 +54,49 @@ type FileUploadParams struct {
 	Source      string
 	SiaPath     string
 	ErasureCode ErasureCoder
+
+	// MinHostVersion, if set, restricts the upload to hosts that are running
+	// at least the specified version.
+	MinHostVersion string
+
+	// Regions, if set, restricts the upload to hosts that are located in
+	// the specified regions. Each element is either the name of a region in
+	// Regions or a country code. Hosts whose country is not known are not
+	// used.
+	Regions []string
+
+	// Tags are arbitrary key/value pairs that are attached to the file. They
+	// are stored by the renter and returned in file listings.
+	Tags map[string]string
+
+	// RetainUntil, if set, is the time after which the file no longer needs
+	// to be kept. Once it has passed, the file is no longer repaired, and
+	// contracts holding only data of such files are not renewed. If unset,
+	// the file is kept forever.
+	RetainUntil types.Timestamp
+
+	// EncryptionKey, if set, is used as the master key of the file instead
+	// of a key generated by the renter. The renter does not keep the key
+	// secret from anyone with access to the renter's files.
+	EncryptionKey crypto.TwofishKey
+
+	// PreEncrypted indicates that the data has already been encrypted by the
+	// caller. The renter will not encrypt the data when uploading it, or
+	// decrypt it when downloading it.
+	PreEncrypted bool
+
+	// ChunkSize, if set, is the number of bytes of the file that are erasure
+	// coded together. It must be a multiple of the number of data pieces,
+	// and each piece must fit in a sector. Every piece still occupies a full
+	// sector on its host, so small chunk sizes do not reduce storage costs,
+	// but reduce the memory and processing needed for small files. If unset,
+	// the largest possible chunk size is used.
+	ChunkSize uint64
+
+	// Cipher, if set, is the cipher that encrypts the pieces of the file.
+	// It is recorded with the file, so that the file can be downloaded no
+	// matter which cipher is the default. If unset, Twofish is used.
+	Cipher crypto.CipherType
 }
 
 // FileInfo provides information about a file.
@@ -53,6 +108,24 @@ type FileInfo struct {
 	Redundancy     float64           `json:"redundancy"`
 	UploadProgress float64           `json:"uploadprogress"`
 	Expiration     types.BlockHeight `json:"expiration"`
+	Tags           map[string]string `json:"tags"`
+	KeySource      string            `json:"keysource"`
+	ChunkSize      uint64            `json:"chunksize"`
+	Cipher         crypto.CipherType `json:"cipher"` // empty if the file is pre-encrypted
+
+	// Checksum is the hex-encoded SHA-256 hash of the data that was
+	// uploaded. It is empty for files that were loaded from a .sia file or
+	// uploaded before checksums were recorded.
+	Checksum string `json:"checksum"`
+
+	// Regions are the regions that pieces of the file may be placed in. It
+	// is empty if pieces may be placed on any host.
+	Regions []string `json:"regions"`
+
+	// RetainUntil is the time after which the file no longer needs to be
+	// kept, or zero if the file is kept forever. Renewing is false once the
+	// time has passed.
+	RetainUntil types.Timestamp `json:"retainuntil"`
 }
 
 // DownloadInfo provides information about a file that has been requested for
@@ -72,6 +145,31 @@ type Allowance struct {
 	Hosts       uint64            `json:"hosts"`
 	Period      types.BlockHeight `json:"period"`
 	RenewWindow types.BlockHeight `json:"renewwindow"`
+
+	// ContractsPerHost is the number of independent contracts formed with
+	// each host. The renter can revise each contract over its own
+	// connection, so uploads to the same host can proceed in parallel. Zero
+	// is treated as one.
+	ContractsPerHost uint64 `json:"contractsperhost"`
+
+	// Formation controls how new contracts are negotiated with hosts.
+	Formation ContractFormationPolicy `json:"formation"`
+}
+
+// ContractFormationPolicy controls how the renter negotiates new contracts.
+// Zero values use the renter's defaults.
+type ContractFormationPolicy struct {
+	// Concurrency is the number of hosts that contracts are negotiated with
+	// at once. Once contracts have been formed with enough hosts, the
+	// negotiations that are still in progress are abandoned.
+	Concurrency uint64 `json:"concurrency"`
+
+	// DialTimeout, SettingsTimeout, and NegotiateTimeout limit the phases of
+	// forming a contract: connecting to the host, verifying its settings,
+	// and negotiating the contract.
+	DialTimeout      time.Duration `json:"dialtimeout"`
+	SettingsTimeout  time.Duration `json:"settingstimeout"`
+	NegotiateTimeout time.Duration `json:"negotiatetimeout"`
 }
 
 // RenterSettings control the behavior of the Renter.
@@ -79,6 +177,16 @@ type RenterSettings struct {
 	Allowance Allowance `json:"allowance"`
 }
 
+// ContractRenewStatusActive, ContractRenewStatusDue, and
+// ContractRenewStatusRenewing are the renewal statuses of a renter contract.
+// A contract is due once it enters the renew window of the allowance, and is
+// renewing while its renewal is being negotiated.
+const (
+	ContractRenewStatusActive   = "active"
+	ContractRenewStatusDue      = "due"
+	ContractRenewStatusRenewing = "renewing"
+)
+
 // RenterFinancialMetrics contains metrics about how much the Renter has
 // spent on storage, uploads, and downloads.
 type RenterFinancialMetrics struct {
@@ -94,6 +202,97 @@ type RenterFinancialMetrics struct {
 	UploadSpending   types.Currency `json:"uploadspending"`
 }
 
+// RenterContractMetrics contains metrics about how much the Renter has spent
+// on a single contract, and about the renewal of the contract.
+type RenterContractMetrics struct {
+	ID types.FileContractID `json:"id"`
+
+	DownloadSpending types.Currency `json:"downloadspending"`
+	StorageSpending  types.Currency `json:"storagespending"`
+	UploadSpending   types.Currency `json:"uploadspending"`
+
+	// RenewedFrom is the ID of the contract that the contract renewed. It is
+	// the zero ID if the contract was not formed by a renewal.
+	RenewedFrom types.FileContractID `json:"renewedfrom"`
+
+	// RenewStatus is ContractRenewStatusActive, ContractRenewStatusDue, or
+	// ContractRenewStatusRenewing.
+	RenewStatus string `json:"renewstatus"`
+}
+
+// RenterTransferMetrics contains the number of bytes that the Renter has
+// transferred to and from hosts since startup.
+type RenterTransferMetrics struct {
+	Downloaded uint64 `json:"downloaded"` // bytes
+	Uploaded   uint64 `json:"uploaded"`   // bytes
+}
+
+// These are the directions of the transfers recorded in the renter's transfer
+// history.
+const (
+	// TransferUpload indicates that a sector was uploaded to a host.
+	TransferUpload = "upload"
+
+	// TransferDownload indicates that a sector was downloaded from a host.
+	TransferDownload = "download"
+)
+
+// A RenterTransfer records the transfer of a single sector between the Renter
+// and a host.
+type RenterTransfer struct {
+	SiaPath   string         `json:"siapath"`
+	Host      NetAddress     `json:"host"`
+	Direction string         `json:"direction"`
+	Bytes     uint64         `json:"bytes"`
+	Price     types.Currency `json:"price"`
+	Time      time.Time      `json:"time"`
+	Duration  time.Duration  `json:"duration"`
+
+	// Error is empty if the transfer succeeded. Failed transfers transfer
+	// no bytes.
+	Error string `json:"error"`
+}
+
+// These are the types of issue that can be found by the renter's metadata
+// check.
+const (
+	// RenterIssueExpiredContract indicates that a file references a contract
+	// that has expired or is unknown to the renter, and that was not renewed.
+	RenterIssueExpiredContract = "expiredcontract"
+
+	// RenterIssueMissingPieces indicates that a file references pieces that
+	// are not in the latest revision of the contract with the host.
+	RenterIssueMissingPieces = "missingpieces"
+
+	// RenterIssueOrphanedSectors indicates that a contract holds sectors
+	// that are not referenced by any file.
+	RenterIssueOrphanedSectors = "orphanedsectors"
+)
+
+// A RenterCheckIssue is an inconsistency between the renter's file metadata
+// and its contracts.
+type RenterCheckIssue struct {
+	Type       string               `json:"type"`
+	SiaPath    string               `json:"siapath"`
+	ContractID types.FileContractID `json:"contractid"`
+	NetAddress NetAddress           `json:"netaddress"`
+
+	// Count is the number of pieces or sectors affected.
+	Count int `json:"count"`
+
+	// Repaired is true if the file metadata was updated to resolve the
+	// issue.
+	Repaired bool `json:"repaired"`
+}
+
+// A RenterCheckReport is the result of checking the renter's file metadata
+// against its contracts.
+type RenterCheckReport struct {
+	FilesChecked     int                `json:"fileschecked"`
+	ContractsChecked int                `json:"contractschecked"`
+	Issues           []RenterCheckIssue `json:"issues"`
+}
+
 // A HostDBEntry represents one host entry in the Renter's host DB. It
 // aggregates the host's external settings with its public key.
 type HostDBEntry struct {
@@ -101,6 +300,21 @@ type HostDBEntry struct {
 	PublicKey types.SiaPublicKey `json:"publickey"`
 }
 
+// A HostSettingsChange records the prices, collateral, and capacity that a
+// host advertised when a change to them was first observed.
+type HostSettingsChange struct {
+	Timestamp   types.Timestamp   `json:"timestamp"`
+	BlockHeight types.BlockHeight `json:"blockheight"`
+
+	ContractPrice          types.Currency `json:"contractprice"`
+	DownloadBandwidthPrice types.Currency `json:"downloadbandwidthprice"`
+	StoragePrice           types.Currency `json:"storageprice"`
+	UploadBandwidthPrice   types.Currency `json:"uploadbandwidthprice"`
+	Collateral             types.Currency `json:"collateral"`
+	MaxCollateral          types.Currency `json:"maxcollateral"`
+	TotalStorage           uint64         `json:"totalstorage"`
+}
+
 // A RenterContract contains all the metadata necessary to revise or renew a
 // file contract.
 type RenterContract struct {
@@ -111,6 +325,11 @@ type RenterContract struct {
 	MerkleRoots     []crypto.Hash              `json:"merkleroots"`
 	NetAddress      NetAddress                 `json:"netaddress"`
 	SecretKey       crypto.SecretKey           `json:"secretkey"`
+
+	// HostVersion is the most recent version advertised by the host. It is
+	// refreshed every time the renter negotiates with the host, and is used
+	// to determine which protocol features can be used with the contract.
+	HostVersion string `json:"hostversion"`
 }
 
 // EndHeight returns the height at which the host is no longer obligated to
@@ -119,6 +338,12 @@ func (rc *RenterContract) EndHeight() types.BlockHeight {
 	return rc.LastRevision.NewWindowStart
 }
 
+// SupportsFeature returns true if the contract's host is known to support the
+// given protocol feature.
+func (rc *RenterContract) SupportsFeature(f HostFeature) bool {
+	return HostSupportsFeature(rc.HostVersion, f)
+}
+
 // RenterFunds returns the funds remaining in the contract's Renter payout as
 // of the most recent revision.
 func (rc *RenterContract) RenterFunds() types.Currency {
@@ -135,12 +360,29 @@ type Renter interface {
 	// AllHosts returns the full list of hosts known to the renter.
 	AllHosts() []HostDBEntry
 
+	// HostSettingsHistory returns the observed changes to a host's settings,
+	// oldest first. If the host is not known, false is returned.
+	HostSettingsHistory(NetAddress) ([]HostSettingsChange, bool)
+
+	// CheckMetadata cross-checks the renter's file metadata against its
+	// contracts. If repair is true, file metadata that refers to data the
+	// hosts no longer store is removed, so that the data can be repaired.
+	CheckMetadata(repair bool) RenterCheckReport
+
 	// Close closes the Renter.
 	Close() error
 
 	// Contracts returns the contracts formed by the renter.
 	Contracts() []RenterContract
 
+	// ContractMetrics returns the spending and renewal status of each
+	// contract formed by the renter.
+	ContractMetrics() []RenterContractMetrics
+
+	// CopyFile creates a new file at newPath that shares the pieces of the
+	// file at path. No data is uploaded.
+	CopyFile(path, newPath string) error
+
 	// DeleteFile deletes a file entry from the renter.
 	DeleteFile(path string) error
 
@@ -150,6 +392,10 @@ type Renter interface {
 	// DownloadQueue lists all the files that have been scheduled for download.
 	DownloadQueue() []DownloadInfo
 
+	// ExportFileKeys returns the material needed to recover a file without
+	// the renter, including the keys of its pieces.
+	ExportFileKeys(path string) (FileKeys, error)
+
 	// FileList returns information on all of the files stored by the renter.
 	FileList() []FileInfo
 
@@ -173,12 +419,27 @@ type Renter interface {
 	// SetSettings sets the Renter's settings.
 	SetSettings(RenterSettings) error
 
+	// SetFileRetention sets the time after which a file no longer needs to
+	// be kept. Zero keeps the file forever.
+	SetFileRetention(path string, until types.Timestamp) error
+
+	// SetFileTags replaces the tags attached to a file.
+	SetFileTags(path string, tags map[string]string) error
+
 	// ShareFiles creates a '.sia' file that can be shared with others.
 	ShareFiles(paths []string, shareDest string) error
 
 	// ShareFilesAscii creates an ASCII-encoded '.sia' file.
 	ShareFilesAscii(paths []string) (asciiSia string, err error)
 
+	// TransferHistory returns the most recent transfers between the Renter
+	// and its hosts, oldest first.
+	TransferHistory() []RenterTransfer
+
+	// TransferMetrics returns the number of bytes that the Renter has
+	// transferred to and from hosts since startup.
+	TransferMetrics() RenterTransferMetrics
+
 	// Upload uploads a file using the input parameters.
 	Upload(FileUploadParams) error
 }
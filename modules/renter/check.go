@@ -0,0 +1,189 @@
+package renter
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A metadataCheck holds the renter's contracts, indexed for checking files
+// against them.
+type metadataCheck struct {
+	byID   map[types.FileContractID]modules.RenterContract
+	byHost map[modules.NetAddress][]modules.RenterContract
+	height types.BlockHeight
+	repair bool
+
+	// roots holds the sectors of each contract, and referenced holds the
+	// sectors of each contract that are referenced by a file.
+	roots      map[types.FileContractID]map[crypto.Hash]struct{}
+	referenced map[types.FileContractID]map[crypto.Hash]struct{}
+}
+
+// newMetadataCheck indexes the provided contracts.
+func newMetadataCheck(contracts []modules.RenterContract, height types.BlockHeight, repair bool) *metadataCheck {
+	mc := &metadataCheck{
+		byID:   make(map[types.FileContractID]modules.RenterContract),
+		byHost: make(map[modules.NetAddress][]modules.RenterContract),
+		height: height,
+		repair: repair,
+
+		roots:      make(map[types.FileContractID]map[crypto.Hash]struct{}),
+		referenced: make(map[types.FileContractID]map[crypto.Hash]struct{}),
+	}
+	for _, c := range contracts {
+		mc.byID[c.ID] = c
+		mc.byHost[c.NetAddress] = append(mc.byHost[c.NetAddress], c)
+		mc.roots[c.ID] = make(map[crypto.Hash]struct{})
+		for _, root := range c.MerkleRoots {
+			mc.roots[c.ID][root] = struct{}{}
+		}
+		mc.referenced[c.ID] = make(map[crypto.Hash]struct{})
+	}
+	return mc
+}
+
+// liveContracts returns the unexpired contracts that hold the data of a file
+// contract. Files keep the ID of the contract that their pieces were uploaded
+// under, so if that contract has been renewed, the contracts formed with the
+// same host are used instead.
+func (mc *metadataCheck) liveContracts(fc fileContract) (live []modules.RenterContract) {
+	matched := mc.byHost[fc.IP]
+	if c, exists := mc.byID[fc.ID]; exists {
+		matched = []modules.RenterContract{c}
+	}
+	for _, c := range matched {
+		if c.EndHeight() > mc.height {
+			live = append(live, c)
+		}
+	}
+	return live
+}
+
+// checkFile checks the pieces of a file against the contracts that hold
+// them. If the check is repairing, references to pieces that are no longer
+// held by a live contract are removed from the file. checkFile returns true
+// if the file was modified.
+func (mc *metadataCheck) checkFile(f *file) (issues []modules.RenterCheckIssue, modified bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, fc := range f.contracts {
+		live := mc.liveContracts(fc)
+		if len(live) == 0 {
+			issues = append(issues, modules.RenterCheckIssue{
+				Type:       modules.RenterIssueExpiredContract,
+				SiaPath:    f.name,
+				ContractID: id,
+				NetAddress: fc.IP,
+				Count:      len(fc.Pieces),
+				Repaired:   mc.repair,
+			})
+			if mc.repair {
+				delete(f.contracts, id)
+				modified = true
+			}
+			continue
+		}
+
+		// Sort the pieces into those held by one of the live contracts and
+		// those that are missing from all of them.
+		var kept []pieceData
+		for _, p := range fc.Pieces {
+			held := false
+			for _, c := range live {
+				if _, exists := mc.roots[c.ID][p.MerkleRoot]; exists {
+					held = true
+					mc.referenced[c.ID][p.MerkleRoot] = struct{}{}
+				}
+			}
+			if held {
+				kept = append(kept, p)
+			}
+		}
+		if missing := len(fc.Pieces) - len(kept); missing > 0 {
+			issues = append(issues, modules.RenterCheckIssue{
+				Type:       modules.RenterIssueMissingPieces,
+				SiaPath:    f.name,
+				ContractID: id,
+				NetAddress: fc.IP,
+				Count:      missing,
+				Repaired:   mc.repair,
+			})
+			if mc.repair {
+				fc.Pieces = kept
+				f.contracts[id] = fc
+				modified = true
+			}
+		}
+	}
+	return issues, modified
+}
+
+// orphanedSectors reports the contracts holding sectors that were not
+// referenced by any of the checked files.
+func (mc *metadataCheck) orphanedSectors() (issues []modules.RenterCheckIssue) {
+	for id, roots := range mc.roots {
+		orphaned := len(roots) - len(mc.referenced[id])
+		if orphaned > 0 {
+			issues = append(issues, modules.RenterCheckIssue{
+				Type:       modules.RenterIssueOrphanedSectors,
+				ContractID: id,
+				NetAddress: mc.byID[id].NetAddress,
+				Count:      orphaned,
+			})
+		}
+	}
+	return issues
+}
+
+// CheckMetadata cross-checks the renter's file metadata against its
+// contracts. Files that reference expired contracts, and files that reference
+// pieces missing from the latest revision of their contracts, are reported.
+// If repair is true, the references are removed from the files, so that the
+// repair loop can upload the missing pieces again. Sectors that are held by a
+// contract but not referenced by any file are reported, but never deleted,
+// because deleting data from a host cannot be undone.
+func (r *Renter) CheckMetadata(repair bool) modules.RenterCheckReport {
+	contracts := r.hostContractor.Contracts()
+	mc := newMetadataCheck(contracts, r.cs.Height(), repair)
+
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+
+	// Check the files in a consistent order.
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := modules.RenterCheckReport{
+		FilesChecked:     len(names),
+		ContractsChecked: len(contracts),
+		Issues:           []modules.RenterCheckIssue{},
+	}
+	repaired := 0
+	for _, name := range names {
+		f := r.files[name]
+		issues, modified := mc.checkFile(f)
+		if modified {
+			if err := r.saveFile(f); err != nil {
+				r.log.Println("Could not save file", name, "after repairing its metadata:", err)
+				for i := range issues {
+					issues[i].Repaired = false
+				}
+			} else {
+				repaired += len(issues)
+			}
+		}
+		report.Issues = append(report.Issues, issues...)
+	}
+	report.Issues = append(report.Issues, mc.orphanedSectors()...)
+	if repaired > 0 {
+		r.log.Printf("Repaired %v issues with the renter's file metadata", repaired)
+	}
+	return report
+}
@@ -0,0 +1,112 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// checkContractor is a hostContractor with a fixed set of contracts.
+type checkContractor struct {
+	stubContractor
+	contracts []modules.RenterContract
+}
+
+func (cc *checkContractor) Contracts() []modules.RenterContract { return cc.contracts }
+
+// TestCheckMetadata checks that the metadata check finds files referencing
+// expired contracts and missing pieces, and contracts holding unreferenced
+// sectors, and that repairing removes the file references.
+func TestCheckMetadata(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	var roots [6]crypto.Hash
+	for i := range roots {
+		roots[i] = crypto.HashObject(i)
+	}
+	contract := func(id byte, addr modules.NetAddress, end types.BlockHeight, roots ...crypto.Hash) modules.RenterContract {
+		return modules.RenterContract{
+			ID:           types.FileContractID{id},
+			NetAddress:   addr,
+			LastRevision: types.FileContractRevision{NewWindowStart: end},
+			MerkleRoots:  roots,
+		}
+	}
+	cc := &checkContractor{contracts: []modules.RenterContract{
+		contract(1, "foo", 1000, roots[0], roots[1], roots[2]),
+		contract(2, "bar", 1000, roots[3]), // renewal of contract 9
+		contract(3, "baz", 0),
+	}}
+	rt, err := newContractorTester("TestCheckMetadata", stubHostDB{}, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rsc, _ := NewRSCode(1, 2)
+	f := newFile("foo", rsc, 10, 10)
+	f.contracts[types.FileContractID{1}] = fileContract{
+		ID:     types.FileContractID{1},
+		IP:     "foo",
+		Pieces: []pieceData{{Piece: 0, MerkleRoot: roots[0]}, {Piece: 1, MerkleRoot: roots[4]}},
+	}
+	f.contracts[types.FileContractID{9}] = fileContract{
+		ID:     types.FileContractID{9},
+		IP:     "bar",
+		Pieces: []pieceData{{Piece: 2, MerkleRoot: roots[3]}},
+	}
+	f.contracts[types.FileContractID{3}] = fileContract{
+		ID:     types.FileContractID{3},
+		IP:     "baz",
+		Pieces: []pieceData{{Piece: 1, MerkleRoot: roots[5]}},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.files["foo"] = f
+	rt.renter.mu.Unlock(id)
+
+	expected := map[string]modules.RenterCheckIssue{
+		modules.RenterIssueMissingPieces:   {ContractID: types.FileContractID{1}, Count: 1},
+		modules.RenterIssueExpiredContract: {ContractID: types.FileContractID{3}, Count: 1},
+		modules.RenterIssueOrphanedSectors: {ContractID: types.FileContractID{1}, Count: 2},
+	}
+	report := rt.renter.CheckMetadata(false)
+	if report.FilesChecked != 1 || report.ContractsChecked != 3 {
+		t.Fatal("wrong number of files or contracts checked:", report.FilesChecked, report.ContractsChecked)
+	}
+	if len(report.Issues) != len(expected) {
+		t.Fatal("expected 3 issues, got", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		exp, exists := expected[issue.Type]
+		if !exists || issue.ContractID != exp.ContractID || issue.Count != exp.Count {
+			t.Error("unexpected issue:", issue)
+		}
+		if issue.Repaired {
+			t.Error("issue was repaired by a check without repair:", issue)
+		}
+	}
+	if len(f.contracts) != 3 {
+		t.Fatal("check without repair modified the file")
+	}
+
+	// Repair the file. Only the orphaned sectors should remain afterwards.
+	report = rt.renter.CheckMetadata(true)
+	for _, issue := range report.Issues {
+		if issue.Repaired == (issue.Type == modules.RenterIssueOrphanedSectors) {
+			t.Error("issue has the wrong repaired status:", issue)
+		}
+	}
+	if _, exists := f.contracts[types.FileContractID{3}]; exists {
+		t.Error("expired contract was not removed from the file")
+	}
+	if pieces := f.contracts[types.FileContractID{1}].Pieces; len(pieces) != 1 || pieces[0].MerkleRoot != roots[0] {
+		t.Error("missing piece was not removed from the file:", pieces)
+	}
+	report = rt.renter.CheckMetadata(false)
+	if len(report.Issues) != 1 || report.Issues[0].Type != modules.RenterIssueOrphanedSectors {
+		t.Error("repaired file still has issues:", report.Issues)
+	}
+}
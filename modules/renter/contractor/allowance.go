@@ -9,10 +9,18 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
+const (
+	// allowanceReservation is the name under which the contractor reserves
+	// allowance funds in the wallet.
+	allowanceReservation = "renter allowance"
+)
+
 var (
 	errAllowanceNoHosts    = errors.New("hosts must be non-zero")
 	errAllowanceZeroPeriod = errors.New("period must be non-zero")
 	errAllowanceWindowSize = errors.New("renew window must be less than period")
+	errContractsPerHost    = errors.New("too many contracts per host")
+	errFormConcurrency     = errors.New("too many concurrent contract negotiations")
 
 	// ErrAllowanceZeroWindow is returned when the caller requests a
 	// zero-length renewal window. This will happen if the caller sets the
@@ -31,6 +39,48 @@ func (c *Contractor) contractEndHeight() types.BlockHeight {
 	return endHeight
 }
 
+// contractsPerHost returns the number of contracts that should be formed with
+// each host under the allowance.
+func contractsPerHost(a modules.Allowance) uint64 {
+	if a.ContractsPerHost == 0 {
+		return 1
+	}
+	return a.ContractsPerHost
+}
+
+// numHosts returns the number of distinct hosts that the Contractor has
+// contracts with.
+func (c *Contractor) numHosts() int {
+	hosts := make(map[modules.NetAddress]struct{})
+	for _, contract := range c.contracts {
+		hosts[contract.NetAddress] = struct{}{}
+	}
+	return len(hosts)
+}
+
+// reservedFunds returns the number of siacoins that the contractor expects to
+// spend soon: the unspent part of the allowance, or the entire allowance if
+// the contracts are about to be renewed.
+func (c *Contractor) reservedFunds() types.Currency {
+	if len(c.contracts) > 0 && c.blockHeight+c.allowance.RenewWindow >= c.contractEndHeight() {
+		return c.allowance.Funds
+	}
+	spending := c.financialMetrics.ContractSpending
+	if spending.Cmp(c.allowance.Funds) >= 0 {
+		return types.ZeroCurrency
+	}
+	return c.allowance.Funds.Sub(spending)
+}
+
+// managedUpdateReservation reserves the funds that the contractor expects to
+// spend soon in the wallet, so that they are not accidentally sent elsewhere.
+func (c *Contractor) managedUpdateReservation() {
+	c.mu.RLock()
+	reserved := c.reservedFunds()
+	c.mu.RUnlock()
+	c.wallet.ReserveSiacoins(allowanceReservation, reserved)
+}
+
 // SetAllowance sets the amount of money the Contractor is allowed to spend on
 // contracts over a given time period, divided among the number of hosts
 // specified. Note that Contractor can start forming contracts as soon as
@@ -57,22 +107,29 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 		return ErrAllowanceZeroWindow
 	} else if a.RenewWindow >= a.Period {
 		return errAllowanceWindowSize
+	} else if a.ContractsPerHost > maxContractsPerHost {
+		return errContractsPerHost
+	} else if a.Formation.Concurrency > maxFormConcurrency {
+		return errFormConcurrency
 	}
 
 	// check that allowance is sufficient to store at least one sector
 	numSectors, err := maxSectors(a, c.hdb, c.tpool)
 	if err != nil {
 		return err
-	} else if numSectors == 0 {
+	} else if numSectors < contractsPerHost(a) {
 		return ErrInsufficientAllowance
 	}
 
 	c.mu.RLock()
 	shouldRenew := a.Period != c.allowance.Period || a.Funds.Cmp(c.allowance.Funds) != 0
 	shouldWait := c.blockHeight+a.Period < c.contractEndHeight()
-	remaining := int(a.Hosts) - len(c.contracts)
+	remaining := int(a.Hosts) - c.numHosts()
 	c.mu.RUnlock()
 
+	// Reserve the funds needed by the new allowance once it has been set.
+	defer c.managedUpdateReservation()
+
 	if !shouldRenew {
 		// If no contracts need renewing, just form new contracts.
 		return c.managedFormAllowanceContracts(remaining, numSectors, a)
@@ -104,16 +161,19 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	c.mu.RUnlock()
 
 	// renew existing contracts with new allowance parameters
+	perHost := contractsPerHost(a)
 	newContracts := make(map[types.FileContractID]modules.RenterContract)
+	renewedFrom := make(map[types.FileContractID]types.FileContractID)
 	for _, contract := range renewSet {
-		newContract, err := c.managedRenew(contract, numSectors, endHeight)
+		newContract, err := c.managedRenew(contract, numSectors/perHost, endHeight)
 		if err != nil {
 			c.log.Printf("WARN: failed to renew contract with %v; a new contract will be formed in its place", contract.NetAddress)
 			remaining++
 			continue
 		}
 		newContracts[newContract.ID] = newContract
-		if len(newContracts) >= int(a.Hosts) {
+		renewedFrom[newContract.ID] = contract.ID
+		if len(newContracts) >= int(a.Hosts*perHost) {
 			break
 		}
 		if build.Release != "testing" {
@@ -124,7 +184,7 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 
 	// if we did not renew enough contracts, form new ones
 	if remaining > 0 {
-		formed, err := c.managedFormContracts(remaining, numSectors, endHeight)
+		formed, err := c.managedFormContracts(remaining, perHost, numSectors, endHeight, a.Formation)
 		if err != nil {
 			return err
 		}
@@ -142,6 +202,11 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	c.mu.Lock()
 	c.allowance = a
 	c.contracts = newContracts
+	c.contractMetrics = make(map[types.FileContractID]modules.RenterContractMetrics)
+	for id, oldID := range renewedFrom {
+		c.contractMetrics[id] = modules.RenterContractMetrics{RenewedFrom: oldID}
+	}
+	c.sectors = newSectorRegistry(c.contracts)
 	// update metrics
 	var spending types.Currency
 	for _, contract := range c.contracts {
@@ -172,7 +237,7 @@ func (c *Contractor) managedFormAllowanceContracts(n int, numSectors uint64, a m
 	c.mu.RUnlock()
 
 	// form the contracts
-	formed, err := c.managedFormContracts(n, numSectors, endHeight)
+	formed, err := c.managedFormContracts(n, contractsPerHost(a), numSectors, endHeight, a.Formation)
 	if err != nil {
 		return err
 	}
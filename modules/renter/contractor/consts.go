@@ -8,6 +8,18 @@ const (
 	// estimatedFileContractTransactionSize provides the estimated size of
 	// the average file contract in bytes.
 	estimatedFileContractTransactionSize = 1200
+
+	// maxContractsPerHost is the maximum number of contracts that the
+	// contractor will form with a single host.
+	maxContractsPerHost = 8
+
+	// defaultFormConcurrency is the number of hosts that contracts are
+	// negotiated with at once if the allowance does not specify it.
+	defaultFormConcurrency = 4
+
+	// maxFormConcurrency is the maximum number of hosts that contracts can
+	// be negotiated with at once.
+	maxFormConcurrency = 32
 )
 
 var (
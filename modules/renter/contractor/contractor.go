@@ -33,6 +33,7 @@ type cachedRevision struct {
 type Contractor struct {
 	// dependencies
 	cs      consensusSet
+	events  *modules.EventFeed
 	hdb     hostDB
 	log     *persist.Logger
 	persist persister
@@ -43,12 +44,16 @@ type Contractor struct {
 	blockHeight     types.BlockHeight
 	cachedRevisions map[types.FileContractID]cachedRevision
 	contracts       map[types.FileContractID]modules.RenterContract
+	contractMetrics map[types.FileContractID]modules.RenterContractMetrics // spending and renewal of each contract
 	downloaders     map[types.FileContractID]*hostDownloader
 	editors         map[types.FileContractID]*hostEditor
+	keyIndex        uint64 // next index used to derive a contract key
 	lastChange      modules.ConsensusChangeID
 	renewedIDs      map[types.FileContractID]types.FileContractID
-	renewing        map[types.FileContractID]bool // prevent revising during renewal
-	revising        map[types.FileContractID]bool // prevent overlapping revisions
+	renewFilter     func(modules.RenterContract) bool // reports whether a contract should be renewed
+	renewing        map[types.FileContractID]bool     // prevent revising during renewal
+	revising        map[types.FileContractID]bool     // prevent overlapping revisions
+	sectors         sectorRegistry                    // sector roots stored on each host
 
 	financialMetrics modules.RenterFinancialMetrics
 
@@ -66,6 +71,26 @@ func (c *Contractor) Allowance() modules.Allowance {
 	return c.allowance
 }
 
+// publishContractFormed announces that a contract has been formed or
+// renewed.
+func (c *Contractor) publishContractFormed(contract modules.RenterContract) {
+	c.events.Publish(modules.EventContractFormed, modules.ContractFormedEvent{
+		ID:          contract.ID,
+		NetAddress:  contract.NetAddress,
+		EndHeight:   contract.EndHeight(),
+		RenterFunds: contract.RenterFunds(),
+	})
+}
+
+// SetRenewFilter sets a function that is called for each contract that is up
+// for renewal. Contracts for which it returns false are left to expire. The
+// function is called without the contractor's lock held.
+func (c *Contractor) SetRenewFilter(fn func(modules.RenterContract) bool) {
+	c.mu.Lock()
+	c.renewFilter = fn
+	c.mu.Unlock()
+}
+
 // FinancialMetrics returns the financial metrics of the Contractor.
 func (c *Contractor) FinancialMetrics() modules.RenterFinancialMetrics {
 	c.mu.RLock()
@@ -95,6 +120,40 @@ func (c *Contractor) Contracts() (cs []modules.RenterContract) {
 	return
 }
 
+// ContractMetrics returns the spending and renewal status of each contract
+// formed by the contractor.
+func (c *Contractor) ContractMetrics() []modules.RenterContractMetrics {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var metrics []modules.RenterContractMetrics
+	for id, contract := range c.contracts {
+		m := c.contractMetrics[id]
+		m.ID = id
+		m.RenewStatus = modules.ContractRenewStatusActive
+		if c.renewing[id] {
+			m.RenewStatus = modules.ContractRenewStatusRenewing
+		} else if c.blockHeight+c.allowance.RenewWindow >= contract.EndHeight() {
+			m.RenewStatus = modules.ContractRenewStatusDue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// recordSpending adds the amounts spent on a contract to the metrics of the
+// contract and to the financial metrics of the contractor. The caller must
+// hold the lock.
+func (c *Contractor) recordSpending(id types.FileContractID, download, storage, upload types.Currency) {
+	c.financialMetrics.DownloadSpending = c.financialMetrics.DownloadSpending.Add(download)
+	c.financialMetrics.StorageSpending = c.financialMetrics.StorageSpending.Add(storage)
+	c.financialMetrics.UploadSpending = c.financialMetrics.UploadSpending.Add(upload)
+	m := c.contractMetrics[id]
+	m.DownloadSpending = m.DownloadSpending.Add(download)
+	m.StorageSpending = m.StorageSpending.Add(storage)
+	m.UploadSpending = m.UploadSpending.Add(upload)
+	c.contractMetrics[id] = m
+}
+
 // resolveID returns the ID of the most recent renewal of id.
 func (c *Contractor) resolveID(id types.FileContractID) types.FileContractID {
 	if newID, ok := c.renewedIDs[id]; ok && newID != id {
@@ -103,8 +162,9 @@ func (c *Contractor) resolveID(id types.FileContractID) types.FileContractID {
 	return id
 }
 
-// New returns a new Contractor.
-func New(cs consensusSet, wallet walletShim, tpool transactionPool, hdb hostDB, persistDir string) (*Contractor, error) {
+// New returns a new Contractor. The formation of contracts is announced on
+// the events feed, which may be nil.
+func New(cs consensusSet, wallet walletShim, tpool transactionPool, hdb hostDB, events *modules.EventFeed, persistDir string) (*Contractor, error) {
 	// Check for nil inputs.
 	if cs == nil {
 		return nil, errNilCS
@@ -128,14 +188,15 @@ func New(cs consensusSet, wallet walletShim, tpool transactionPool, hdb hostDB,
 	}
 
 	// Create Contractor using production dependencies.
-	return newContractor(cs, &walletBridge{w: wallet}, tpool, hdb, newPersist(persistDir), logger)
+	return newContractor(cs, &walletBridge{w: wallet}, tpool, hdb, events, newPersist(persistDir), logger)
 }
 
 // newContractor creates a Contractor using the provided dependencies.
-func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p persister, l *persist.Logger) (*Contractor, error) {
+func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, events *modules.EventFeed, p persister, l *persist.Logger) (*Contractor, error) {
 	// Create the Contractor object.
 	c := &Contractor{
 		cs:      cs,
+		events:  events,
 		hdb:     hdb,
 		log:     l,
 		persist: p,
@@ -144,11 +205,13 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 
 		cachedRevisions: make(map[types.FileContractID]cachedRevision),
 		contracts:       make(map[types.FileContractID]modules.RenterContract),
+		contractMetrics: make(map[types.FileContractID]modules.RenterContractMetrics),
 		downloaders:     make(map[types.FileContractID]*hostDownloader),
 		editors:         make(map[types.FileContractID]*hostEditor),
 		renewedIDs:      make(map[types.FileContractID]types.FileContractID),
 		renewing:        make(map[types.FileContractID]bool),
 		revising:        make(map[types.FileContractID]bool),
+		sectors:         make(sectorRegistry),
 	}
 
 	// Load the prior persistence structures.
@@ -168,5 +231,9 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 		return nil, errors.New("contractor subscription failed: " + err.Error())
 	}
 
+	// Reserve the funds of the loaded allowance, as the wallet does not
+	// persist reservations.
+	c.managedUpdateReservation()
+
 	return c, nil
 }
@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -24,7 +26,11 @@ func (newStub) ConsensusSetSubscribe(modules.ConsensusSetSubscriber, modules.Con
 func (newStub) Synced() bool { return true }
 
 // wallet stubs
+func (newStub) KeyAtIndex(types.Specifier, uint64) (sk crypto.SecretKey, pk crypto.PublicKey, err error) {
+	return
+}
 func (newStub) NextAddress() (uc types.UnlockConditions, err error) { return }
+func (newStub) ReserveSiacoins(string, types.Currency)              {}
 func (newStub) StartTransaction() modules.TransactionBuilder        { return nil }
 
 // transaction pool stubs
@@ -43,38 +49,38 @@ func TestNew(t *testing.T) {
 	dir := build.TempDir("contractor", "TestNew")
 
 	// Sane values.
-	_, err := New(stub, stub, stub, stub, dir)
+	_, err := New(stub, stub, stub, stub, nil, dir)
 	if err != nil {
 		t.Fatalf("expected nil, got %v", err)
 	}
 
 	// Nil consensus set.
-	_, err = New(nil, stub, stub, stub, dir)
+	_, err = New(nil, stub, stub, stub, nil, dir)
 	if err != errNilCS {
 		t.Fatalf("expected %v, got %v", errNilCS, err)
 	}
 
 	// Nil wallet.
-	_, err = New(stub, nil, stub, stub, dir)
+	_, err = New(stub, nil, stub, stub, nil, dir)
 	if err != errNilWallet {
 		t.Fatalf("expected %v, got %v", errNilWallet, err)
 	}
 
 	// Nil transaction pool.
-	_, err = New(stub, stub, nil, stub, dir)
+	_, err = New(stub, stub, nil, stub, nil, dir)
 	if err != errNilTpool {
 		t.Fatalf("expected %v, got %v", errNilTpool, err)
 	}
 
 	// Bad persistDir.
-	_, err = New(stub, stub, stub, stub, "")
+	_, err = New(stub, stub, stub, stub, nil, "")
 	if !os.IsNotExist(err) {
 		t.Fatalf("expected invalid directory, got %v", err)
 	}
 
 	// Corrupted persist file.
 	ioutil.WriteFile(filepath.Join(dir, "contractor.json"), []byte{1, 2, 3}, 0666)
-	_, err = New(stub, stub, stub, stub, dir)
+	_, err = New(stub, stub, stub, stub, nil, dir)
 	if _, ok := err.(*json.SyntaxError); !ok {
 		t.Fatalf("expected invalid json, got %v", err)
 	}
@@ -135,6 +141,54 @@ func TestContracts(t *testing.T) {
 	}
 }
 
+// TestContractMetrics tests the ContractMetrics and recordSpending methods.
+func TestContractMetrics(t *testing.T) {
+	c := &Contractor{
+		allowance:   modules.Allowance{RenewWindow: 10},
+		blockHeight: 100,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {ID: types.FileContractID{1}, LastRevision: types.FileContractRevision{NewWindowStart: 200}},
+			{2}: {ID: types.FileContractID{2}, LastRevision: types.FileContractRevision{NewWindowStart: 105}},
+			{3}: {ID: types.FileContractID{3}, LastRevision: types.FileContractRevision{NewWindowStart: 105}},
+		},
+		contractMetrics: map[types.FileContractID]modules.RenterContractMetrics{
+			{1}: {RenewedFrom: types.FileContractID{4}},
+		},
+		renewing: map[types.FileContractID]bool{
+			{3}: true,
+		},
+	}
+	c.recordSpending(types.FileContractID{1}, types.NewCurrency64(1), types.NewCurrency64(2), types.NewCurrency64(3))
+	c.recordSpending(types.FileContractID{1}, types.NewCurrency64(1), types.ZeroCurrency, types.ZeroCurrency)
+	if c.financialMetrics.DownloadSpending.Cmp(types.NewCurrency64(2)) != 0 || c.financialMetrics.UploadSpending.Cmp(types.NewCurrency64(3)) != 0 {
+		t.Fatal("financial metrics were not updated:", c.financialMetrics)
+	}
+
+	expStatus := map[types.FileContractID]string{
+		{1}: modules.ContractRenewStatusActive,
+		{2}: modules.ContractRenewStatusDue,
+		{3}: modules.ContractRenewStatusRenewing,
+	}
+	metrics := c.ContractMetrics()
+	if len(metrics) != 3 {
+		t.Fatal("expected 3 contract metrics, got", len(metrics))
+	}
+	for _, m := range metrics {
+		if m.RenewStatus != expStatus[m.ID] {
+			t.Errorf("contract %v: expected status %v, got %v", m.ID, expStatus[m.ID], m.RenewStatus)
+		}
+		if m.ID != (types.FileContractID{1}) {
+			continue
+		}
+		if m.RenewedFrom != (types.FileContractID{4}) {
+			t.Error("renewed contract was not reported:", m.RenewedFrom)
+		}
+		if m.DownloadSpending.Cmp(types.NewCurrency64(2)) != 0 || m.StorageSpending.Cmp(types.NewCurrency64(2)) != 0 || m.UploadSpending.Cmp(types.NewCurrency64(3)) != 0 {
+			t.Error("spending was not recorded:", m)
+		}
+	}
+}
+
 // TestResolveID tests the resolveID method.
 func TestResolveID(t *testing.T) {
 	c := &Contractor{
@@ -187,6 +241,24 @@ type stubHostDB struct{}
 func (stubHostDB) Host(modules.NetAddress) (h modules.HostDBEntry, ok bool)         { return }
 func (stubHostDB) RandomHosts(int, []modules.NetAddress) (hs []modules.HostDBEntry) { return }
 
+// TestNumHosts tests that the numHosts method counts hosts with multiple
+// contracts once.
+func TestNumHosts(t *testing.T) {
+	c := &Contractor{
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {NetAddress: "foo"},
+			{2}: {NetAddress: "foo"},
+			{3}: {NetAddress: "bar"},
+		},
+	}
+	if n := c.numHosts(); n != 2 {
+		t.Fatal("expected 2 hosts, got", n)
+	}
+	if n := contractsPerHost(modules.Allowance{}); n != 1 {
+		t.Fatal("expected a default of 1 contract per host, got", n)
+	}
+}
+
 // TestIntegrationSetAllowance tests the SetAllowance method.
 func TestIntegrationSetAllowance(t *testing.T) {
 	if testing.Short() {
@@ -219,6 +291,19 @@ func TestIntegrationSetAllowance(t *testing.T) {
 	if err != errAllowanceWindowSize {
 		t.Errorf("expected %q, got %q", errAllowanceWindowSize, err)
 	}
+	a.RenewWindow = 10
+	a.ContractsPerHost = maxContractsPerHost + 1
+	err = c.SetAllowance(a)
+	if err != errContractsPerHost {
+		t.Errorf("expected %q, got %q", errContractsPerHost, err)
+	}
+	a.ContractsPerHost = 0
+	a.Formation.Concurrency = maxFormConcurrency + 1
+	err = c.SetAllowance(a)
+	if err != errFormConcurrency {
+		t.Errorf("expected %q, got %q", errFormConcurrency, err)
+	}
+	a.Formation.Concurrency = 0
 
 	// reasonable values; should succeed
 	a.Funds = types.SiacoinPrecision.Mul64(100)
@@ -313,16 +398,25 @@ func TestIntegrationSetAllowance(t *testing.T) {
 
 // testWalletShim is used to test the walletBridge type.
 type testWalletShim struct {
+	keyAtIndexCalled  bool
 	nextAddressCalled bool
+	reserveCalled     bool
 	startTxnCalled    bool
 }
 
 // These stub implementations for the walletShim interface set their respective
 // booleans to true, allowing tests to verify that they have been called.
+func (ws *testWalletShim) KeyAtIndex(types.Specifier, uint64) (crypto.SecretKey, crypto.PublicKey, error) {
+	ws.keyAtIndexCalled = true
+	return crypto.SecretKey{}, crypto.PublicKey{}, nil
+}
 func (ws *testWalletShim) NextAddress() (types.UnlockConditions, error) {
 	ws.nextAddressCalled = true
 	return types.UnlockConditions{}, nil
 }
+func (ws *testWalletShim) ReserveSiacoins(string, types.Currency) {
+	ws.reserveCalled = true
+}
 func (ws *testWalletShim) StartTransaction() modules.TransactionBuilder {
 	ws.startTxnCalled = true
 	return nil
@@ -332,12 +426,45 @@ func (ws *testWalletShim) StartTransaction() modules.TransactionBuilder {
 func TestWalletBridge(t *testing.T) {
 	shim := new(testWalletShim)
 	bridge := walletBridge{shim}
+	bridge.KeyAtIndex(modules.KeyPurposeRenterContract, 0)
+	if !shim.keyAtIndexCalled {
+		t.Error("KeyAtIndex was not called on the shim")
+	}
 	bridge.NextAddress()
 	if !shim.nextAddressCalled {
 		t.Error("NextAddress was not called on the shim")
 	}
+	bridge.ReserveSiacoins(allowanceReservation, types.ZeroCurrency)
+	if !shim.reserveCalled {
+		t.Error("ReserveSiacoins was not called on the shim")
+	}
 	bridge.StartTransaction()
 	if !shim.startTxnCalled {
 		t.Error("StartTransaction was not called on the shim")
 	}
 }
+
+// reservationWalletShim is a walletShim that records the siacoins reserved
+// under each name.
+type reservationWalletShim struct {
+	testWalletShim
+	reserved map[string]types.Currency
+}
+
+func (ws *reservationWalletShim) ReserveSiacoins(name string, amount types.Currency) {
+	ws.reserved[name] = amount
+}
+
+// TestNewReservesAllowance checks that a contractor reserves the funds of its
+// persisted allowance when it is loaded.
+func TestNewReservesAllowance(t *testing.T) {
+	shim := &reservationWalletShim{reserved: make(map[string]types.Currency)}
+	p := &memPersist{Allowance: modules.Allowance{Funds: types.NewCurrency64(1000)}}
+	_, err := newContractor(newStub{}, &walletBridge{w: shim}, newStub{}, newStub{}, nil, p, persist.NewLogger(ioutil.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shim.reserved[allowanceReservation].Cmp(types.NewCurrency64(1000)) != 0 {
+		t.Fatal("allowance was not reserved:", shim.reserved)
+	}
+}
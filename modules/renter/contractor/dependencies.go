@@ -3,6 +3,7 @@ package contractor
 import (
 	"path/filepath"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
@@ -19,11 +20,15 @@ type (
 	// provide a shim to bridge the gap between modules.Wallet and
 	// transactionBuilder.
 	walletShim interface {
+		KeyAtIndex(types.Specifier, uint64) (crypto.SecretKey, crypto.PublicKey, error)
 		NextAddress() (types.UnlockConditions, error)
+		ReserveSiacoins(string, types.Currency)
 		StartTransaction() modules.TransactionBuilder
 	}
 	wallet interface {
+		KeyAtIndex(types.Specifier, uint64) (crypto.SecretKey, crypto.PublicKey, error)
 		NextAddress() (types.UnlockConditions, error)
+		ReserveSiacoins(string, types.Currency)
 		StartTransaction() transactionBuilder
 	}
 	transactionBuilder interface {
@@ -63,8 +68,14 @@ type walletBridge struct {
 	w walletShim
 }
 
+func (ws *walletBridge) KeyAtIndex(purpose types.Specifier, index uint64) (crypto.SecretKey, crypto.PublicKey, error) {
+	return ws.w.KeyAtIndex(purpose, index)
+}
 func (ws *walletBridge) NextAddress() (types.UnlockConditions, error) { return ws.w.NextAddress() }
-func (ws *walletBridge) StartTransaction() transactionBuilder         { return ws.w.StartTransaction() }
+func (ws *walletBridge) ReserveSiacoins(name string, amount types.Currency) {
+	ws.w.ReserveSiacoins(name, amount)
+}
+func (ws *walletBridge) StartTransaction() transactionBuilder { return ws.w.StartTransaction() }
 
 // stdPersist implements the persister interface via persist.SaveFile and
 // persist.LoadFile. The metadata and filename required by these functions is
@@ -22,6 +22,10 @@ type Downloader interface {
 	// retrieve.
 	Sector(root crypto.Hash) ([]byte, error)
 
+	// Spending returns the amount spent on downloads through the
+	// Downloader.
+	Spending() types.Currency
+
 	// Close terminates the connection to the host.
 	Close() error
 }
@@ -84,7 +88,7 @@ func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
 	hd.speed = uint64(duration.Seconds()) / modules.SectorSize
 
 	hd.contractor.mu.Lock()
-	hd.contractor.financialMetrics.DownloadSpending = hd.contractor.financialMetrics.DownloadSpending.Add(delta)
+	hd.contractor.recordSpending(contract.ID, delta, types.ZeroCurrency, types.ZeroCurrency)
 	hd.contractor.contracts[contract.ID] = contract
 	hd.contractor.saveSync()
 	hd.contractor.mu.Unlock()
@@ -92,6 +96,13 @@ func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
 	return sector, nil
 }
 
+// Spending returns the amount spent on downloads through the Downloader.
+func (hd *hostDownloader) Spending() types.Currency {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+	return hd.downloader.DownloadSpending
+}
+
 // Speed returns the most recent download speed of this host, in bytes per
 // second.
 func (hd *hostDownloader) Speed() uint64 {
@@ -151,6 +162,11 @@ func (c *Contractor) Downloader(id types.FileContractID) (_ Downloader, err erro
 	} else if host.DownloadBandwidthPrice.Cmp(maxDownloadPrice) > 0 {
 		return nil, errTooExpensive
 	}
+	if contract.HostVersion == "" {
+		// COMPATv1.0.3: contracts formed by older renters did not record
+		// the host version.
+		contract.HostVersion = host.Version
+	}
 
 	// acquire revising lock
 	c.mu.Lock()
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"sync"
 
-	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/proto"
@@ -41,6 +40,10 @@ type Editor interface {
 	// EndHeight returns the height at which the contract ends.
 	EndHeight() types.BlockHeight
 
+	// Spending returns the amount spent on uploads and storage through the
+	// Editor.
+	Spending() types.Currency
+
 	// Close terminates the connection to the host.
 	Close() error
 }
@@ -82,6 +85,13 @@ func (he *hostEditor) ContractID() types.FileContractID { return he.contract.ID
 // store the file.
 func (he *hostEditor) EndHeight() types.BlockHeight { return he.contract.EndHeight() }
 
+// Spending returns the amount spent on uploads and storage through the Editor.
+func (he *hostEditor) Spending() types.Currency {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	return he.editor.UploadSpending.Add(he.editor.StorageSpending)
+}
+
 // Close cleanly terminates the revision loop with the host and closes the
 // connection.
 func (he *hostEditor) Close() error {
@@ -108,9 +118,29 @@ func (he *hostEditor) Upload(data []byte) (crypto.Hash, error) {
 		return crypto.Hash{}, errInvalidEditor
 	}
 
+	// If the host is already storing the sector for one of our contracts,
+	// ask the host to reuse it instead of paying to upload it again.
+	sectorRoot := crypto.MerkleRoot(data)
+	he.contractor.mu.RLock()
+	stored := he.contractor.sectors.has(he.contract.NetAddress, sectorRoot)
+	he.contractor.mu.RUnlock()
+
 	oldUploadSpending := he.editor.UploadSpending
 	oldStorageSpending := he.editor.StorageSpending
-	contract, sectorRoot, err := he.editor.Upload(data)
+	var contract modules.RenterContract
+	var err error
+	if stored && he.contract.SupportsFeature(modules.HostFeatureSectorDedup) {
+		contract, err = he.editor.UploadRoot(sectorRoot)
+	} else {
+		contract, _, err = he.editor.Upload(data)
+		if proto.IsInterruptedTransfer(err) {
+			// The host kept the part of the sector that it received, so
+			// reconnect and send the rest.
+			if err = he.editor.Reconnect(); err == nil {
+				contract, _, err = he.editor.Upload(data)
+			}
+		}
+	}
 	if err != nil {
 		return crypto.Hash{}, err
 	}
@@ -118,9 +148,9 @@ func (he *hostEditor) Upload(data []byte) (crypto.Hash, error) {
 	storageDelta := he.editor.StorageSpending.Sub(oldStorageSpending)
 
 	he.contractor.mu.Lock()
-	he.contractor.financialMetrics.UploadSpending = he.contractor.financialMetrics.UploadSpending.Add(uploadDelta)
-	he.contractor.financialMetrics.StorageSpending = he.contractor.financialMetrics.StorageSpending.Add(storageDelta)
+	he.contractor.recordSpending(contract.ID, types.ZeroCurrency, storageDelta, uploadDelta)
 	he.contractor.contracts[contract.ID] = contract
+	he.contractor.sectors.add(contract.NetAddress, sectorRoot)
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
 	he.contract = contract
@@ -143,6 +173,7 @@ func (he *hostEditor) Delete(root crypto.Hash) error {
 
 	he.contractor.mu.Lock()
 	he.contractor.contracts[contract.ID] = contract
+	he.contractor.sectors.remove(contract.NetAddress, root)
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
 	he.contract = contract
@@ -166,8 +197,10 @@ func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newDat
 	uploadDelta := he.editor.UploadSpending.Sub(oldUploadSpending)
 
 	he.contractor.mu.Lock()
-	he.contractor.financialMetrics.UploadSpending = he.contractor.financialMetrics.UploadSpending.Add(uploadDelta)
+	he.contractor.recordSpending(contract.ID, types.ZeroCurrency, types.ZeroCurrency, uploadDelta)
 	he.contractor.contracts[contract.ID] = contract
+	he.contractor.sectors.remove(contract.NetAddress, oldRoot)
+	he.contractor.sectors.add(contract.NetAddress, newRoot)
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
 	he.contract = contract
@@ -207,11 +240,15 @@ func (c *Contractor) Editor(id types.FileContractID) (_ Editor, err error) {
 		return nil, errors.New("no record of that host")
 	} else if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
 		return nil, errTooExpensive
-	} else if build.VersionCmp(host.Version, "0.6.0") > 0 {
-		// COMPATv0.6.0: don't cap host.Collateral on old hosts
-		if host.Collateral.Cmp(maxUploadCollateral) > 0 {
-			host.Collateral = maxUploadCollateral
-		}
+	}
+	if contract.HostVersion == "" {
+		// COMPATv1.0.3: contracts formed by older renters did not record
+		// the host version.
+		contract.HostVersion = host.Version
+	}
+	// COMPATv0.6.0: don't cap host.Collateral on old hosts
+	if contract.SupportsFeature(modules.HostFeatureCollateralCap) && host.Collateral.Cmp(maxUploadCollateral) > 0 {
+		host.Collateral = maxUploadCollateral
 	}
 
 	// acquire revising lock
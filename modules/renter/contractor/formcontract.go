@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -27,7 +28,7 @@ var (
 )
 
 // maxSectors is the estimated maximum number of sectors that the allowance
-// can support.
+// can support on each host.
 func maxSectors(a modules.Allowance, hdb hostDB, tp transactionPool) (uint64, error) {
 	if a.Hosts <= 0 || a.Period <= 0 {
 		return 0, errors.New("invalid allowance")
@@ -54,11 +55,11 @@ func maxSectors(a modules.Allowance, hdb hostDB, tp transactionPool) (uint64, er
 	averageSectorPrice := sectorSum.Div64(uint64(len(hosts)))
 	averageContractPrice := contractCostSum.Div64(uint64(len(hosts)))
 	costPerSector := averageSectorPrice.Mul64(a.Hosts).Mul64(modules.SectorSize).Mul64(uint64(a.Period))
-	costForContracts := averageContractPrice.Mul64(a.Hosts)
+	costForContracts := averageContractPrice.Mul64(a.Hosts).Mul64(contractsPerHost(a))
 
 	// Subtract fees for creating the file contracts from the allowance.
 	_, feeEstimation := tp.FeeEstimation()
-	costForTxnFees := types.NewCurrency64(estimatedFileContractTransactionSize).Mul(feeEstimation).Mul64(a.Hosts)
+	costForTxnFees := types.NewCurrency64(estimatedFileContractTransactionSize).Mul(feeEstimation).Mul64(a.Hosts).Mul64(contractsPerHost(a))
 	// Check for potential divide by zero
 	if a.Funds.Cmp(costForTxnFees.Add(costForContracts)) <= 0 {
 		return 0, ErrInsufficientAllowance
@@ -76,6 +77,14 @@ func maxSectors(a modules.Allowance, hdb hostDB, tp transactionPool) (uint64, er
 // managedNewContract negotiates an initial file contract with the specified
 // host, saves it, and returns it.
 func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uint64, endHeight types.BlockHeight) (modules.RenterContract, error) {
+	return c.managedNegotiateContract(host, numSectors, endHeight, proto.FormTimeouts{}, nil)
+}
+
+// managedNegotiateContract negotiates an initial file contract with the
+// specified host using the provided timeouts, saves it, and returns it. The
+// negotiation is abandoned if cancel is closed before the contract has been
+// signed.
+func (c *Contractor) managedNegotiateContract(host modules.HostDBEntry, numSectors uint64, endHeight types.BlockHeight, timeouts proto.FormTimeouts, cancel <-chan struct{}) (modules.RenterContract, error) {
 	// reject hosts that are too expensive
 	if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
 		return modules.RenterContract{}, errTooExpensive
@@ -91,6 +100,22 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uin
 		return modules.RenterContract{}, err
 	}
 
+	// derive the contract key from the wallet seed, so that the contract can
+	// be recovered if the renter loses its persist data. The key index is
+	// saved before it is used, guaranteeing that it is never reused.
+	c.mu.Lock()
+	keyIndex := c.keyIndex
+	c.keyIndex++
+	err = c.saveSync()
+	c.mu.Unlock()
+	if err != nil {
+		return modules.RenterContract{}, err
+	}
+	sk, _, err := c.wallet.KeyAtIndex(modules.KeyPurposeRenterContract, keyIndex)
+	if err != nil {
+		return modules.RenterContract{}, err
+	}
+
 	// create contract params
 	c.mu.RLock()
 	params := proto.ContractParams{
@@ -99,6 +124,9 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uin
 		StartHeight:   c.blockHeight,
 		EndHeight:     endHeight,
 		RefundAddress: uc.UnlockHash(),
+		SecretKey:     sk,
+		Timeouts:      timeouts,
+		Cancel:        cancel,
 	}
 	c.mu.RUnlock()
 
@@ -113,13 +141,41 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uin
 
 	contractValue := contract.RenterFunds()
 	c.log.Printf("Formed contract with %v for %v SC", host.NetAddress, contractValue.Div(types.SiacoinPrecision))
+	c.publishContractFormed(contract)
 
 	return contract, nil
 }
 
-// managedFormContracts forms contracts with n hosts using the allowance
-// parameters.
-func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight types.BlockHeight) ([]modules.RenterContract, error) {
+// managedFormHostContracts forms up to perHost contracts with a host, each
+// storing numSectors sectors. It stops at the first contract that cannot be
+// formed, and returns the contracts that were formed along with the error.
+func (c *Contractor) managedFormHostContracts(host modules.HostDBEntry, perHost uint64, numSectors uint64, endHeight types.BlockHeight, timeouts proto.FormTimeouts, cancel <-chan struct{}) ([]modules.RenterContract, error) {
+	var contracts []modules.RenterContract
+	for i := uint64(0); i < perHost; i++ {
+		if i > 0 && build.Release != "testing" {
+			// sleep for 1 minute to alleviate potential block propagation issues
+			select {
+			case <-time.After(60 * time.Second):
+			case <-cancel:
+				return contracts, proto.ErrFormCancelled
+			}
+		}
+		contract, err := c.managedNegotiateContract(host, numSectors, endHeight, timeouts, cancel)
+		if err != nil {
+			return contracts, err
+		}
+		contracts = append(contracts, contract)
+	}
+	return contracts, nil
+}
+
+// managedFormContracts forms 'perHost' contracts with each of n hosts using
+// the allowance parameters. The 'numSectors' sectors that each host should
+// store are split evenly between its contracts. Contracts are negotiated with
+// several hosts at once, as set by the formation policy; once contracts have
+// been formed with n hosts, the remaining candidates are skipped and the
+// negotiations that are still in progress are abandoned.
+func (c *Contractor) managedFormContracts(n int, perHost uint64, numSectors uint64, endHeight types.BlockHeight, policy modules.ContractFormationPolicy) ([]modules.RenterContract, error) {
 	if n <= 0 {
 		return nil, nil
 	}
@@ -141,23 +197,62 @@ func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight ty
 		return nil, fmt.Errorf("not enough hosts in hostdb for contract formation, got %v but needed %v", len(hosts), n)
 	}
 
+	concurrency := int(policy.Concurrency)
+	if concurrency == 0 {
+		concurrency = defaultFormConcurrency
+	}
+	if concurrency > len(hosts) {
+		concurrency = len(hosts)
+	}
+	timeouts := proto.FormTimeouts{
+		Dial:        policy.DialTimeout,
+		Settings:    policy.SettingsTimeout,
+		Negotiation: policy.NegotiateTimeout,
+	}
+
+	// Hand the candidates to a pool of negotiators. enough is closed once
+	// contracts have been formed with n hosts.
+	candidates := make(chan modules.HostDBEntry, len(hosts))
+	for _, h := range hosts {
+		candidates <- h
+	}
+	close(candidates)
+	enough := make(chan struct{})
+
 	var contracts []modules.RenterContract
 	var errs []string
-	for _, h := range hosts {
-		contract, err := c.managedNewContract(h, numSectors, endHeight)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("\t%v: %v", h.NetAddress, err))
-			continue
-		}
-		contracts = append(contracts, contract)
-		if len(contracts) >= n {
-			break
-		}
-		if build.Release != "testing" {
-			// sleep for 1 minute to alleviate potential block propagation issues
-			time.Sleep(60 * time.Second)
-		}
+	var formedHosts int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range candidates {
+				select {
+				case <-enough:
+					return
+				default:
+				}
+				formed, err := c.managedFormHostContracts(h, perHost, numSectors/perHost, endHeight, timeouts, enough)
+
+				mu.Lock()
+				contracts = append(contracts, formed...)
+				if err != nil && err != proto.ErrFormCancelled {
+					errs = append(errs, fmt.Sprintf("\t%v: %v", h.NetAddress, err))
+				}
+				if len(formed) > 0 {
+					formedHosts++
+					if formedHosts == n {
+						close(enough)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
+
 	// If we couldn't form any contracts, return an error. Otherwise, just log
 	// the failures.
 	// TODO: is there a better way to handle failure here? Should we prefer an
@@ -165,8 +260,8 @@ func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight ty
 	// because they'll probably be more expensive than we can afford.
 	if len(contracts) == 0 {
 		return nil, errors.New("could not form any contracts:\n" + strings.Join(errs, "\n"))
-	} else if len(contracts) < n {
-		c.log.Printf("WARN: failed to form desired number of contracts (wanted %v, got %v):\n%v", n, len(contracts), strings.Join(errs, "\n"))
+	} else if len(contracts) < n*int(perHost) {
+		c.log.Printf("WARN: failed to form desired number of contracts (wanted %v, got %v):\n%v", n*int(perHost), len(contracts), strings.Join(errs, "\n"))
 	}
 
 	return contracts, nil
@@ -82,7 +82,7 @@ func newTestingHost(testdir string, cs modules.ConsensusSet, tp modules.Transact
 	if err != nil {
 		return nil, err
 	}
-	err = h.AddStorageFolder(storageFolder, 1e6)
+	err = h.AddStorageFolder(storageFolder, 1e6, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +101,7 @@ func newTestingContractor(testdir string, cs modules.ConsensusSet, tp modules.Tr
 	if err != nil {
 		return nil, err
 	}
-	return New(cs, w, tp, hdb, filepath.Join(testdir, "contractor"))
+	return New(cs, w, tp, hdb, nil, filepath.Join(testdir, "contractor"))
 }
 
 // newTestingTrio creates a Host, Contractor, and TestMiner that can be used
@@ -957,3 +957,94 @@ func TestIntegrationEditorCaching(t *testing.T) {
 	}
 	d4.Close()
 }
+
+// TestIntegrationSectorDedup tests that uploading a sector which the host is
+// already storing for another contract only sends the sector root, and that
+// the renter does not pay for upload bandwidth.
+func TestIntegrationSectorDedup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio("TestIntegrationSectorDedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// get the host's entry from the db
+	hostEntry, ok := c.hdb.Host(h.ExternalSettings().NetAddress)
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// form two contracts with the host
+	var contracts []modules.RenterContract
+	for i := 0; i < 2; i++ {
+		contract, err := c.managedNewContract(hostEntry, 10, c.blockHeight+100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.mu.Lock()
+		c.contracts[contract.ID] = contract
+		c.mu.Unlock()
+		contracts = append(contracts, contract)
+	}
+
+	// upload the same data to both contracts
+	data, err := crypto.RandBytes(int(modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	var uploadSpending []types.Currency
+	for _, contract := range contracts {
+		editor, err := c.Editor(contract.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := editor.Upload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = editor.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		uploadSpending = append(uploadSpending, c.FinancialMetrics().UploadSpending)
+	}
+	if roots[0] != roots[1] {
+		t.Fatal("uploads of the same data returned different roots")
+	}
+	if uploadSpending[0].IsZero() {
+		t.Fatal("first upload should have paid for upload bandwidth")
+	}
+	if uploadSpending[1].Cmp(uploadSpending[0]) != 0 {
+		t.Fatal("deduplicated upload should not have paid for upload bandwidth")
+	}
+	c.mu.RLock()
+	count := c.sectors[contracts[0].NetAddress][roots[0]]
+	c.mu.RUnlock()
+	if count != 2 {
+		t.Fatal("expected the sector to be registered twice, got", count)
+	}
+
+	// the sector should be downloadable using the second contract
+	downloader, err := c.Downloader(contracts[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved, err := downloader.Sector(roots[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Fatal("downloaded data does not match original")
+	}
+	err = downloader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
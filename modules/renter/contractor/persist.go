@@ -11,8 +11,10 @@ type contractorPersist struct {
 	Allowance        modules.Allowance
 	BlockHeight      types.BlockHeight
 	CachedRevisions  []cachedRevision
+	ContractMetrics  []modules.RenterContractMetrics
 	Contracts        []modules.RenterContract
 	FinancialMetrics modules.RenterFinancialMetrics
+	KeyIndex         uint64
 	LastChange       modules.ConsensusChangeID
 	RenewedIDs       map[string]string
 }
@@ -23,6 +25,7 @@ func (c *Contractor) persistData() contractorPersist {
 		Allowance:        c.allowance,
 		BlockHeight:      c.blockHeight,
 		FinancialMetrics: c.financialMetrics,
+		KeyIndex:         c.keyIndex,
 		LastChange:       c.lastChange,
 		RenewedIDs:       make(map[string]string),
 	}
@@ -32,6 +35,12 @@ func (c *Contractor) persistData() contractorPersist {
 	for _, contract := range c.contracts {
 		data.Contracts = append(data.Contracts, contract)
 	}
+	for id, m := range c.contractMetrics {
+		if _, ok := c.contracts[id]; ok {
+			m.ID = id
+			data.ContractMetrics = append(data.ContractMetrics, m)
+		}
+	}
 	for oldID, newID := range c.renewedIDs {
 		data.RenewedIDs[oldID.String()] = newID.String()
 	}
@@ -53,7 +62,12 @@ func (c *Contractor) load() error {
 	for _, contract := range data.Contracts {
 		c.contracts[contract.ID] = contract
 	}
+	for _, m := range data.ContractMetrics {
+		c.contractMetrics[m.ID] = m
+	}
+	c.sectors = newSectorRegistry(c.contracts)
 	c.financialMetrics = data.FinancialMetrics
+	c.keyIndex = data.KeyIndex
 	c.lastChange = data.LastChange
 	for oldString, newString := range data.RenewedIDs {
 		var oldHash, newHash crypto.Hash
@@ -20,8 +20,9 @@ func (m memPersist) load(data *contractorPersist) error     { *data = contractor
 func TestSaveLoad(t *testing.T) {
 	// create contractor with mocked persist dependency
 	c := &Contractor{
-		contracts:  make(map[types.FileContractID]modules.RenterContract),
-		renewedIDs: make(map[types.FileContractID]types.FileContractID),
+		contracts:       make(map[types.FileContractID]modules.RenterContract),
+		contractMetrics: make(map[types.FileContractID]modules.RenterContractMetrics),
+		renewedIDs:      make(map[types.FileContractID]types.FileContractID),
 	}
 	c.persist = new(memPersist)
 
@@ -36,6 +37,9 @@ func TestSaveLoad(t *testing.T) {
 		{1}: {2},
 		{2}: {3},
 	}
+	c.contractMetrics = map[types.FileContractID]modules.RenterContractMetrics{
+		{0}: {UploadSpending: types.NewCurrency64(7)},
+	}
 
 	// save and reload
 	err := c.save()
@@ -86,4 +90,7 @@ func TestSaveLoad(t *testing.T) {
 	if !ok0 || !ok1 || !ok2 {
 		t.Fatal("renewed IDs were not restored properly:", c.renewedIDs)
 	}
+	if m := c.contractMetrics[types.FileContractID{0}]; m.UploadSpending.Cmp(types.NewCurrency64(7)) != 0 {
+		t.Fatal("contract metrics were not restored properly:", c.contractMetrics)
+	}
 }
@@ -50,6 +50,7 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, numSectors ui
 		txnBuilder.Drop() // return unused outputs to wallet
 		return modules.RenterContract{}, err
 	}
+	c.publishContractFormed(newContract)
 
 	return newContract, nil
 }
@@ -59,13 +60,24 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, numSectors ui
 func (c *Contractor) managedRenewContracts() error {
 	c.mu.RLock()
 	// Renew contracts when they enter the renew window.
-	var renewSet []types.FileContractID
+	var due []modules.RenterContract
 	for _, contract := range c.contracts {
 		if c.blockHeight+c.allowance.RenewWindow >= contract.EndHeight() {
-			renewSet = append(renewSet, contract.ID)
+			due = append(due, contract)
 		}
 	}
+	renewFilter := c.renewFilter
 	c.mu.RUnlock()
+
+	// Leave the contracts that are no longer needed to expire.
+	var renewSet []types.FileContractID
+	for _, contract := range due {
+		if renewFilter != nil && !renewFilter(contract) {
+			c.log.Printf("not renewing contract with %v: it only holds files past their retention", contract.NetAddress)
+			continue
+		}
+		renewSet = append(renewSet, contract.ID)
+	}
 	if len(renewSet) == 0 {
 		// nothing to do
 		return nil
@@ -76,6 +88,7 @@ func (c *Contractor) managedRenewContracts() error {
 	c.mu.RLock()
 	endHeight := c.blockHeight + c.allowance.Period
 	numSectors, err := maxSectors(c.allowance, c.hdb, c.tpool)
+	numSectors /= contractsPerHost(c.allowance)
 	c.mu.RUnlock()
 	if err != nil {
 		return err
@@ -144,9 +157,12 @@ func (c *Contractor) managedRenewContracts() error {
 	c.mu.Lock()
 	for id, contract := range newContracts {
 		delete(c.contracts, id)
+		delete(c.contractMetrics, id)
 		c.contracts[contract.ID] = contract
+		c.contractMetrics[contract.ID] = modules.RenterContractMetrics{RenewedFrom: id}
 		c.renewedIDs[id] = contract.ID
 	}
+	c.sectors = newSectorRegistry(c.contracts)
 	err = c.saveSync()
 	c.mu.Unlock()
 	return err
@@ -0,0 +1,58 @@
+package contractor
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A sectorRegistry records which sector roots each host is storing for the
+// renter, across all of the renter's contracts with that host. The count of
+// each root is the number of times it appears in those contracts. Before
+// uploading a sector, the renter consults the registry; if the host already
+// stores the sector, the renter asks the host to insert it by its root
+// instead of paying to upload the data again.
+type sectorRegistry map[modules.NetAddress]map[crypto.Hash]int
+
+// newSectorRegistry returns a sectorRegistry containing the sector roots of
+// the provided contracts.
+func newSectorRegistry(contracts map[types.FileContractID]modules.RenterContract) sectorRegistry {
+	sr := make(sectorRegistry)
+	for _, contract := range contracts {
+		for _, root := range contract.MerkleRoots {
+			sr.add(contract.NetAddress, root)
+		}
+	}
+	return sr
+}
+
+// add records that the host is storing an instance of the sector root.
+func (sr sectorRegistry) add(host modules.NetAddress, root crypto.Hash) {
+	roots, ok := sr[host]
+	if !ok {
+		roots = make(map[crypto.Hash]int)
+		sr[host] = roots
+	}
+	roots[root]++
+}
+
+// remove records that the host has stopped storing an instance of the sector
+// root.
+func (sr sectorRegistry) remove(host modules.NetAddress, root crypto.Hash) {
+	roots, ok := sr[host]
+	if !ok {
+		return
+	}
+	roots[root]--
+	if roots[root] <= 0 {
+		delete(roots, root)
+	}
+	if len(roots) == 0 {
+		delete(sr, host)
+	}
+}
+
+// has returns whether the host is storing the sector root.
+func (sr sectorRegistry) has(host modules.NetAddress, root crypto.Hash) bool {
+	return sr[host][root] > 0
+}
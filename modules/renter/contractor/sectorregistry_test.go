@@ -0,0 +1,50 @@
+package contractor
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSectorRegistry tests that the sectorRegistry counts the instances of
+// each sector root per host.
+func TestSectorRegistry(t *testing.T) {
+	root1, root2 := crypto.Hash{1}, crypto.Hash{2}
+	contracts := map[types.FileContractID]modules.RenterContract{
+		{1}: {NetAddress: "foo", MerkleRoots: []crypto.Hash{root1, root2}},
+		{2}: {NetAddress: "foo", MerkleRoots: []crypto.Hash{root1}},
+		{3}: {NetAddress: "bar", MerkleRoots: []crypto.Hash{root2}},
+	}
+	sr := newSectorRegistry(contracts)
+	if sr["foo"][root1] != 2 || sr["foo"][root2] != 1 || sr["bar"][root2] != 1 {
+		t.Fatal("registry has wrong counts:", sr)
+	}
+	if sr.has("bar", root1) {
+		t.Fatal("registry should not have root1 on bar")
+	}
+
+	// removing one instance of a root should not remove the others
+	sr.remove("foo", root1)
+	if !sr.has("foo", root1) {
+		t.Fatal("root1 should still be on foo")
+	}
+	sr.remove("foo", root1)
+	if sr.has("foo", root1) {
+		t.Fatal("root1 should no longer be on foo")
+	}
+
+	// hosts without roots should be removed entirely
+	sr.remove("bar", root2)
+	if _, ok := sr["bar"]; ok {
+		t.Fatal("bar should have been removed from the registry")
+	}
+	// removing an unknown root is a no-op
+	sr.remove("baz", root1)
+
+	sr.add("baz", root1)
+	if !sr.has("baz", root1) {
+		t.Fatal("root1 should be on baz")
+	}
+}
@@ -32,8 +32,12 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 	}
 	for _, id := range expired {
 		delete(c.contracts, id)
+		delete(c.contractMetrics, id)
 		c.log.Debugln("INFO: deleted expired contract", id)
 	}
+	if len(expired) > 0 {
+		c.sectors = newSectorRegistry(c.contracts)
+	}
 
 	c.lastChange = cc.ID
 	err := c.save()
@@ -52,6 +56,7 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 				return
 			}
 			defer c.editLock.Unlock()
+			defer c.managedUpdateReservation()
 
 			// renew any contracts that have entered the renew window
 			err := c.managedRenewContracts()
@@ -62,7 +67,7 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 			// if we don't have enough contracts, form new ones
 			c.mu.RLock()
 			a := c.allowance
-			remaining := int(a.Hosts) - len(c.contracts)
+			remaining := int(a.Hosts) - c.numHosts()
 			c.mu.RUnlock()
 			if remaining <= 0 {
 				return
@@ -0,0 +1,226 @@
+package renter
+
+// dedup.go deduplicates chunks across uploads. Before any piece of a chunk is
+// uploaded, the renter hashes the chunk's data together with everything that
+// determines how its pieces are encoded and encrypted: the master key of the
+// file, the index of the chunk, the cipher, the piece size, and the erasure
+// code. If a chunk of another file has the same hash, that file's pieces of
+// the chunk can be decrypted and decoded exactly like the new file's pieces
+// would be, so they are referenced in the new file's metadata instead of being
+// uploaded and paid for again.
+//
+// Piece keys are derived from the master key and the chunk index, so only
+// chunks at the same index of files that share a master key can be
+// deduplicated. This is the case for copies of a file, and for files that are
+// uploaded with the same caller-supplied encryption key, such as successive
+// versions of a backup. Because the master key is part of the hash, the
+// hashes do not reveal whether files with different keys contain the same
+// data.
+//
+// Files uploaded with renter-generated keys each get a random master key, so
+// identical data in such files is not deduplicated. Doing so would require
+// deriving piece keys from the data itself (convergent encryption), which
+// changes the encryption of every file and reveals which files are identical
+// to anyone who can guess their contents. This is out of scope.
+//
+// Pieces are only shared from hosts that the new file may use: a file that is
+// restricted to certain regions or host versions does not reference pieces
+// stored on other hosts.
+
+import (
+	"io"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// A chunkRef identifies a chunk of a file by the file's nickname and the
+// chunk's index.
+type chunkRef struct {
+	name  string
+	chunk uint64
+}
+
+// chunkHash returns the deduplication hash of a chunk of f.
+func (f *file) chunkHash(chunkIndex uint64, chunk []byte) crypto.Hash {
+	return crypto.HashAll(
+		f.masterKey,
+		chunkIndex,
+		f.cipher(),
+		f.pieceSize,
+		uint64(f.erasureCode.MinPieces()),
+		uint64(f.erasureCode.NumPieces()),
+		chunk,
+	)
+}
+
+// sharePieces adds the pieces of a chunk of src to the same chunk of f,
+// returning the number of pieces that were added. Pieces stored on the hosts
+// in 'exclude' are not added. The pieces remain on their hosts until every
+// file that references them has been deleted.
+func (f *file) sharePieces(src *file, chunkIndex uint64, exclude []modules.NetAddress) int {
+	src.mu.RLock()
+	defer src.mu.RUnlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	excluded := make(map[modules.NetAddress]bool)
+	for _, addr := range exclude {
+		excluded[addr] = true
+	}
+	present := make(map[uint64]bool)
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			if p.Chunk == chunkIndex {
+				present[p.Piece] = true
+			}
+		}
+	}
+	added := 0
+	for id, srcContract := range src.contracts {
+		if excluded[srcContract.IP] {
+			continue
+		}
+		for _, p := range srcContract.Pieces {
+			if p.Chunk != chunkIndex || present[p.Piece] {
+				continue
+			}
+			contract, ok := f.contracts[id]
+			if !ok {
+				contract = fileContract{
+					ID:          srcContract.ID,
+					IP:          srcContract.IP,
+					WindowStart: srcContract.WindowStart,
+				}
+			}
+			contract.Pieces = append(contract.Pieces, p)
+			f.contracts[id] = contract
+			present[p.Piece] = true
+			added++
+		}
+	}
+	return added
+}
+
+// recordChunkHash records the deduplication hash of a chunk of the file with
+// the given nickname. r.mu must be held.
+func (r *Renter) recordChunkHash(name string, numChunks, chunkIndex uint64, h crypto.Hash) {
+	hashes := r.chunkHashes[name]
+	if uint64(len(hashes)) < numChunks {
+		hashes = append(hashes, make([]crypto.Hash, numChunks-uint64(len(hashes)))...)
+	}
+	if old := hashes[chunkIndex]; old != (crypto.Hash{}) {
+		r.unindexChunk(old, chunkRef{name, chunkIndex})
+	}
+	hashes[chunkIndex] = h
+	r.chunkHashes[name] = hashes
+	r.indexChunk(h, chunkRef{name, chunkIndex})
+}
+
+// indexChunk adds a chunk to the deduplication index. r.mu must be held.
+func (r *Renter) indexChunk(h crypto.Hash, ref chunkRef) {
+	if h == (crypto.Hash{}) {
+		return
+	}
+	refs, exists := r.dedupIndex[h]
+	if !exists {
+		refs = make(map[chunkRef]struct{})
+		r.dedupIndex[h] = refs
+	}
+	refs[ref] = struct{}{}
+}
+
+// unindexChunk removes a chunk from the deduplication index. r.mu must be
+// held.
+func (r *Renter) unindexChunk(h crypto.Hash, ref chunkRef) {
+	refs := r.dedupIndex[h]
+	delete(refs, ref)
+	if len(refs) == 0 {
+		delete(r.dedupIndex, h)
+	}
+}
+
+// indexFileChunks adds the recorded chunk hashes of the file with the given
+// nickname to the deduplication index. r.mu must be held.
+func (r *Renter) indexFileChunks(name string) {
+	for i, h := range r.chunkHashes[name] {
+		r.indexChunk(h, chunkRef{name, uint64(i)})
+	}
+}
+
+// unindexFileChunks removes the recorded chunk hashes of the file with the
+// given nickname from the deduplication index. r.mu must be held.
+func (r *Renter) unindexFileChunks(name string) {
+	for i, h := range r.chunkHashes[name] {
+		r.unindexChunk(h, chunkRef{name, uint64(i)})
+	}
+}
+
+// dedupSource returns a file other than f that has a chunk with the given
+// deduplication hash. r.mu must be held.
+func (r *Renter) dedupSource(f *file, h crypto.Hash) (*file, bool) {
+	for ref := range r.dedupIndex[h] {
+		if src, ok := r.files[ref.name]; ok && src != f {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+// managedDedupChunks looks for identical, already uploaded copies of the
+// chunks of f that have no pieces yet, and references their pieces in f. The
+// hashes of the chunks are recorded so that later uploads can reference them
+// in turn. Pieces stored on the hosts in 'exclude' are not referenced. The
+// chunks that still have missing pieces are returned.
+func (r *Renter) managedDedupChunks(f *file, handle io.ReaderAt, chunks map[uint64][]uint64, exclude []modules.NetAddress) map[uint64][]uint64 {
+	// Data that was encrypted before it was uploaded has no master key.
+	if !f.encrypted() {
+		return chunks
+	}
+
+	// Hash the chunks that have not been uploaded at all. Chunks that are
+	// partially uploaded already had their hash recorded.
+	f.mu.RLock()
+	name, numChunks, numPieces := f.name, f.numChunks(), f.erasureCode.NumPieces()
+	f.mu.RUnlock()
+	hashes := make(map[uint64]crypto.Hash)
+	for chunkIndex, pieces := range chunks {
+		if len(pieces) != numPieces {
+			continue
+		}
+		data, err := f.readChunk(chunkIndex, handle)
+		if err != nil {
+			r.log.Printf("could not read chunk %v of %v for deduplication: %v", chunkIndex, name, err)
+			return chunks
+		}
+		hashes[chunkIndex] = f.chunkHash(chunkIndex, data)
+	}
+	if len(hashes) == 0 {
+		return chunks
+	}
+
+	id := r.mu.Lock()
+	deduped := 0
+	for chunkIndex, h := range hashes {
+		if src, ok := r.dedupSource(f, h); ok {
+			if f.sharePieces(src, chunkIndex, exclude) > 0 {
+				deduped++
+			}
+		}
+		r.recordChunkHash(name, numChunks, chunkIndex, h)
+	}
+	r.save()
+	r.mu.Unlock(id)
+	if deduped == 0 {
+		return chunks
+	}
+
+	r.log.Printf("referenced the existing pieces of %v identical chunks of %v", deduped, name)
+	f.mu.RLock()
+	err := r.saveFile(f)
+	f.mu.RUnlock()
+	if err != nil {
+		r.log.Printf("failed to save deduplicated file %v: %v", name, err)
+	}
+	return f.incompleteChunks()
+}
@@ -0,0 +1,105 @@
+package renter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterDedupChunks checks that chunks that were already uploaded under
+// the same master key reference the existing pieces instead of being
+// uploaded again.
+func TestRenterDedupChunks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestRenterDedupChunks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rsc, _ := NewRSCode(1, 1)
+	data, _ := crypto.RandBytes(128)
+
+	// Upload the first file, which has nothing to deduplicate against.
+	f := newFile("1", rsc, 64, uint64(len(data)))
+	rt.renter.files[f.name] = f
+	chunks := rt.renter.managedDedupChunks(f, bytes.NewReader(data), f.incompleteChunks(), nil)
+	if len(chunks) != 2 {
+		t.Fatal("expected 2 chunks to upload, got", len(chunks))
+	}
+	if len(rt.renter.chunkHashes[f.name]) != 2 {
+		t.Fatal("chunk hashes were not recorded")
+	}
+	f.contracts[types.FileContractID{1}] = fileContract{
+		ID: types.FileContractID{1},
+		IP: "foo:1234",
+		Pieces: []pieceData{
+			{Chunk: 0, Piece: 0, MerkleRoot: crypto.Hash{1}},
+			{Chunk: 0, Piece: 1, MerkleRoot: crypto.Hash{2}},
+			{Chunk: 1, Piece: 0, MerkleRoot: crypto.Hash{3}},
+			{Chunk: 1, Piece: 1, MerkleRoot: crypto.Hash{4}},
+		},
+	}
+
+	// A file with a different master key shares no chunks.
+	f2 := newFile("2", rsc, 64, uint64(len(data)))
+	rt.renter.files[f2.name] = f2
+	chunks = rt.renter.managedDedupChunks(f2, bytes.NewReader(data), f2.incompleteChunks(), nil)
+	if len(chunks) != 2 || len(f2.contracts) != 0 {
+		t.Fatal("chunks were shared between files with different keys")
+	}
+
+	// A file with the same master key shares the chunks that are identical.
+	f3 := newFile("3", rsc, 64, uint64(len(data)))
+	f3.masterKey = f.masterKey
+	rt.renter.files[f3.name] = f3
+	data3 := append(append([]byte(nil), data[:64]...), make([]byte, 64)...)
+	chunks = rt.renter.managedDedupChunks(f3, bytes.NewReader(data3), f3.incompleteChunks(), nil)
+	if len(chunks) != 1 || len(chunks[1]) != 2 {
+		t.Fatal("expected only the second chunk to be uploaded, got", chunks)
+	}
+	pieces := f3.contracts[types.FileContractID{1}].Pieces
+	if len(pieces) != 2 || pieces[0].MerkleRoot != (crypto.Hash{1}) || pieces[1].MerkleRoot != (crypto.Hash{2}) {
+		t.Fatal("the pieces of the first chunk were not shared:", pieces)
+	}
+
+	// Pieces on hosts that the file may not use are not shared.
+	f5 := newFile("5", rsc, 64, uint64(len(data)))
+	f5.masterKey = f.masterKey
+	rt.renter.files[f5.name] = f5
+	chunks = rt.renter.managedDedupChunks(f5, bytes.NewReader(data), f5.incompleteChunks(), []modules.NetAddress{"foo:1234"})
+	if len(chunks) != 2 || len(f5.contracts) != 0 {
+		t.Fatal("pieces on an excluded host were shared")
+	}
+
+	// The shared pieces are still referenced after the first file is deleted.
+	delete(rt.renter.files, f.name)
+	if !rt.renter.referencedRoots()[crypto.Hash{1}] {
+		t.Fatal("shared pieces are not referenced")
+	}
+
+	// The index is updated when files are deleted.
+	h := rt.renter.chunkHashes[f.name][0]
+	if len(rt.renter.dedupIndex[h]) != 3 {
+		t.Fatal("expected 3 chunks in the index, got", len(rt.renter.dedupIndex[h]))
+	}
+	rt.renter.unindexFileChunks(f.name)
+	delete(rt.renter.chunkHashes, f.name)
+	if _, exists := rt.renter.dedupIndex[h][chunkRef{f.name, 0}]; exists || len(rt.renter.dedupIndex[h]) != 2 {
+		t.Fatal("deleted file was not removed from the index")
+	}
+
+	// Files whose data was encrypted before uploading are not deduplicated.
+	f4 := newFile("4", rsc, 64, uint64(len(data)))
+	f4.preEncrypted = true
+	f4.masterKey = f.masterKey
+	rt.renter.managedDedupChunks(f4, bytes.NewReader(data), f4.incompleteChunks(), nil)
+	if len(f4.contracts) != 0 || len(rt.renter.chunkHashes[f4.name]) != 0 {
+		t.Fatal("pre-encrypted file was deduplicated")
+	}
+}
@@ -15,8 +15,10 @@ import (
 )
 
 var (
-	errInsufficientHosts  = errors.New("insufficient hosts to recover file")
-	errInsufficientPieces = errors.New("couldn't fetch enough pieces to recover data")
+	errDownloadInterrupted = errors.New("download was interrupted by shutdown; it will resume from the last completed chunk")
+	errInsufficientHosts   = errors.New("insufficient hosts to recover file")
+	errInsufficientPieces  = errors.New("couldn't fetch enough pieces to recover data")
+	errChecksumMismatch    = errors.New("downloaded data does not match the checksum recorded when the file was uploaded")
 )
 
 // A fetcher fetches pieces from a host. This interface exists to facilitate
@@ -27,14 +29,35 @@ type fetcher interface {
 
 	// fetch returns the data specified by piece metadata.
 	fetch(pieceData) ([]byte, error)
+
+	// host returns the address of the host, which identifies it when
+	// measuring its latency.
+	host() modules.NetAddress
+}
+
+// A fetchResult is the outcome of fetching a piece.
+type fetchResult struct {
+	data []byte
+	err  error
+}
+
+// A stalledFetch is a fetch that exceeded its stall timeout. The piece can
+// still be received from result if the host eventually returns it.
+type stalledFetch struct {
+	piece  uint64
+	result <-chan fetchResult
 }
 
 // A hostFetcher fetches pieces from a host. It implements the fetcher
 // interface.
 type hostFetcher struct {
+	addr       modules.NetAddress
 	downloader contractor.Downloader
 	pieceMap   map[uint64][]pieceData
 	masterKey  crypto.TwofishKey
+	cipherType crypto.CipherType
+	encrypted  bool
+	pieceLen   uint64
 }
 
 // pieces returns the pieces stored on this host that are part of a given
@@ -43,6 +66,11 @@ func (hf *hostFetcher) pieces(chunk uint64) []pieceData {
 	return hf.pieceMap[chunk]
 }
 
+// host returns the address of the host.
+func (hf *hostFetcher) host() modules.NetAddress {
+	return hf.addr
+}
+
 // fetch downloads the piece specified by p.
 func (hf *hostFetcher) fetch(p pieceData) ([]byte, error) {
 	// request piece
@@ -51,24 +79,44 @@ func (hf *hostFetcher) fetch(p pieceData) ([]byte, error) {
 		return nil, err
 	}
 
+	// pieces that are smaller than a sector were padded when uploaded
+	if uint64(len(data)) > hf.pieceLen {
+		data = data[:hf.pieceLen]
+	}
+
+	// data that was encrypted before being uploaded is returned as-is
+	if !hf.encrypted {
+		return data, nil
+	}
+
 	// generate decryption key
-	key := deriveKey(hf.masterKey, p.Chunk, p.Piece)
+	key, err := crypto.NewCipherKey(hf.cipherType, deriveKey(hf.masterKey, p.Chunk, p.Piece))
+	if err != nil {
+		return nil, err
+	}
 
 	// decrypt and return
 	return key.DecryptBytes(data)
 }
 
-// newHostFetcher creates a new hostFetcher.
-func newHostFetcher(d contractor.Downloader, pieces []pieceData, masterKey crypto.TwofishKey) *hostFetcher {
+// newHostFetcher creates a new hostFetcher. If encrypted is false, pieces are
+// not decrypted after being fetched, otherwise they are decrypted with
+// cipherType. pieceLen is the number of bytes of each sector that belong to
+// the piece.
+func newHostFetcher(addr modules.NetAddress, d contractor.Downloader, pieces []pieceData, masterKey crypto.TwofishKey, cipherType crypto.CipherType, encrypted bool, pieceLen uint64) *hostFetcher {
 	// make piece map
 	pieceMap := make(map[uint64][]pieceData)
 	for _, p := range pieces {
 		pieceMap[p.Chunk] = append(pieceMap[p.Chunk], p)
 	}
 	return &hostFetcher{
+		addr:       addr,
 		downloader: d,
 		pieceMap:   pieceMap,
 		masterKey:  masterKey,
+		cipherType: cipherType,
+		encrypted:  encrypted,
+		pieceLen:   pieceLen,
 	}
 }
 
@@ -101,21 +149,127 @@ type download struct {
 	chunkSize   uint64
 	fileSize    uint64
 	hosts       []fetcher
+
+	// chunkPieces holds the pieces of the current chunk that have already
+	// been fetched, so that a failed attempt at recovering the chunk does not
+	// need to fetch them again.
+	chunkPieces [][]byte
+
+	// chunkComplete, if non-nil, is called after each chunk is written,
+	// allowing the renter to persist the progress of the download.
+	chunkComplete func(chunksCompleted uint64)
+
+	// stop, if non-nil, interrupts the download between chunks when it is
+	// closed. The progress recorded by chunkComplete allows the download to
+	// be resumed later.
+	stop <-chan struct{}
+
+	// latencies records the performance of each host, and determines the
+	// order in which hosts are asked for pieces.
+	latencies *latencyTracker
+
+	// inFlight holds a channel for each host that is closed when the host's
+	// most recent fetch returns. Fetches from a host that stalled must finish
+	// before the host is asked for another piece.
+	inFlight map[fetcher]chan struct{}
 }
 
-// getPiece locates and downloads a specific piece.
-func (d *download) getPiece(chunkIndex, pieceIndex uint64) []byte {
-	for _, h := range d.hosts {
-		for _, p := range h.pieces(chunkIndex) {
-			if p.Piece == pieceIndex {
-				data, err := h.fetch(p)
-				if err != nil {
-					break // try next host
-				}
-				return data
+// fetchPiece fetches p from h. If h does not return the piece within its
+// stall timeout, h is demoted and a stalledFetch is returned instead, so that
+// the piece can be requested from another host without abandoning h.
+func (d *download) fetchPiece(h fetcher, p pieceData) ([]byte, *stalledFetch, error) {
+	if done, exists := d.inFlight[h]; exists {
+		select {
+		case <-done:
+		case <-d.stop:
+			return nil, nil, errDownloadInterrupted
+		}
+	}
+	result := make(chan fetchResult, 1)
+	done := make(chan struct{})
+	d.inFlight[h] = done
+	start := time.Now()
+	go func() {
+		data, err := h.fetch(p)
+		d.latencies.record(h.host(), time.Since(start), err)
+		result <- fetchResult{data, err}
+		close(done)
+	}()
+
+	select {
+	case res := <-result:
+		return res.data, nil, res.err
+	case <-time.After(d.latencies.stallTimeout(h.host())):
+		d.latencies.stall(h.host(), start)
+		return nil, &stalledFetch{piece: p.Piece, result: result}, nil
+	case <-d.stop:
+		return nil, nil, errDownloadInterrupted
+	}
+}
+
+// fetchChunk fills in the missing pieces of chunk until at least 'left' more
+// pieces have been fetched. Hosts are asked for pieces in order of their
+// recent performance; a host that fails or stalls is skipped for the rest of
+// the chunk. Stalled fetches are only waited on if the other hosts cannot
+// supply enough pieces.
+func (d *download) fetchChunk(chunk [][]byte, left int) error {
+	// Shuffle the hosts first, so that hosts with equal performance share
+	// the load.
+	perm, err := crypto.Perm(len(d.hosts))
+	if err != nil {
+		return err
+	}
+	shuffled := make([]fetcher, len(d.hosts))
+	for i, j := range perm {
+		shuffled[i] = d.hosts[j]
+	}
+
+	var stalled []*stalledFetch
+	for _, h := range d.latencies.order(shuffled) {
+		if left <= 0 {
+			break
+		}
+		for _, p := range h.pieces(d.chunkIndex) {
+			if left <= 0 {
+				break
 			}
+			if p.Piece >= uint64(len(chunk)) || chunk[p.Piece] != nil {
+				continue
+			}
+			data, sf, err := d.fetchPiece(h, p)
+			if err == errDownloadInterrupted {
+				return err
+			}
+			if sf != nil {
+				stalled = append(stalled, sf)
+				break // try next host
+			}
+			if err != nil {
+				break // try next host
+			}
+			chunk[p.Piece] = data
+			left--
+		}
+	}
+
+	for _, sf := range stalled {
+		if left <= 0 {
+			break
+		}
+		var res fetchResult
+		select {
+		case res = <-sf.result:
+		case <-d.stop:
+			return errDownloadInterrupted
+		}
+		if res.err == nil && chunk[sf.piece] == nil {
+			chunk[sf.piece] = res.data
+			left--
 		}
 	}
+	if left > 0 {
+		return errInsufficientPieces
+	}
 	return nil
 }
 
@@ -125,25 +279,26 @@ func (d *download) getPiece(chunkIndex, pieceIndex uint64) []byte {
 // another iteration should be used.
 func (d *download) run(w io.Writer) error {
 	for ; d.received < d.fileSize; d.chunkIndex++ {
-		// load pieces into chunk
-		chunk := make([][]byte, d.erasureCode.NumPieces())
-		left := d.erasureCode.MinPieces()
-		// pick hosts at random
-		chunkOrder, err := crypto.Perm(len(chunk))
-		if err != nil {
-			return err
+		select {
+		case <-d.stop:
+			return errDownloadInterrupted
+		default:
+		}
+
+		// load pieces into chunk, reusing any pieces fetched by a previous
+		// attempt
+		if d.chunkPieces == nil {
+			d.chunkPieces = make([][]byte, d.erasureCode.NumPieces())
 		}
-		for _, j := range chunkOrder {
-			chunk[j] = d.getPiece(d.chunkIndex, uint64(j))
-			if chunk[j] != nil {
+		chunk := d.chunkPieces
+		left := d.erasureCode.MinPieces()
+		for _, piece := range chunk {
+			if piece != nil {
 				left--
 			}
-			if left == 0 {
-				break
-			}
 		}
-		if left != 0 {
-			return errInsufficientPieces
+		if err := d.fetchChunk(chunk, left); err != nil {
+			return err
 		}
 
 		// Write pieces to w. We always write chunkSize bytes unless this is
@@ -152,16 +307,48 @@ func (d *download) run(w io.Writer) error {
 		if n > d.fileSize-d.received {
 			n = d.fileSize - d.received
 		}
-		err = d.erasureCode.Recover(chunk, uint64(n), w)
+		err := d.erasureCode.Recover(chunk, uint64(n), w)
 		if err != nil {
 			return err
 		}
 		atomic.AddUint64(&d.received, n)
+		d.chunkPieces = nil
+		if d.chunkComplete != nil {
+			d.chunkComplete(d.chunkIndex + 1)
+		}
 	}
 
 	return nil
 }
 
+// resume prepares the download to continue writing to f after the first
+// chunksCompleted chunks, discarding any partially written chunk. If f is
+// shorter than expected, the download starts over.
+func (d *download) resume(f *os.File, chunksCompleted uint64) error {
+	offset := chunksCompleted * d.chunkSize
+	if offset > d.fileSize {
+		offset = d.fileSize
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if uint64(stat.Size()) < offset {
+		chunksCompleted, offset = 0, 0
+	}
+	err = f.Truncate(int64(offset))
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(int64(offset), os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+	d.chunkIndex = chunksCompleted
+	d.received = offset
+	return nil
+}
+
 // newDownload initializes and returns a download object.
 func (f *file) newDownload(hosts []fetcher, destination string) *download {
 	d := &download{
@@ -169,6 +356,8 @@ func (f *file) newDownload(hosts []fetcher, destination string) *download {
 		chunkSize:   f.chunkSize(),
 		fileSize:    f.size,
 		hosts:       hosts,
+		latencies:   newLatencyTracker(),
+		inFlight:    make(map[fetcher]chan struct{}),
 
 		startTime:   time.Now(),
 		chunkIndex:  0,
@@ -182,6 +371,11 @@ func (f *file) newDownload(hosts []fetcher, destination string) *download {
 // Download downloads a file, identified by its path, to the destination
 // specified.
 func (r *Renter) Download(path, destination string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
 	// Lookup the file associated with the nickname.
 	lockID := r.mu.RLock()
 	file, exists := r.files[path]
@@ -190,9 +384,14 @@ func (r *Renter) Download(path, destination string) error {
 		return errors.New("no file with that path")
 	}
 
-	// Create the download object and add it to the queue.
+	// Create the download object and add it to the queue. If a previous
+	// download of this file to the same destination did not finish, it is
+	// resumed from the last completed chunk.
 	d := file.newDownload([]fetcher{}, destination)
+	d.latencies = r.latencies
 	lockID = r.mu.Lock()
+	progress, resume := r.downloads[destination]
+	resume = resume && progress.SiaPath == path && progress.FileSize == file.size
 	r.downloadQueue = append(r.downloadQueue, d)
 	r.mu.Unlock(lockID)
 
@@ -202,11 +401,45 @@ func (r *Renter) Download(path, destination string) error {
 		// sane default
 		perm = 0666
 	}
-	f, err := os.OpenFile(destination, os.O_CREATE|os.O_RDWR|os.O_TRUNC, perm)
+	flags := os.O_CREATE | os.O_RDWR | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_RDWR
+	}
+	f, err := os.OpenFile(destination, flags, perm)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	if resume {
+		err = d.resume(f, progress.ChunksCompleted)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Persist the progress of the download after each chunk, so that the
+	// download can be resumed if it fails. The file is synced first, so that
+	// the recorded progress never exceeds the data on disk.
+	d.chunkComplete = func(chunksCompleted uint64) {
+		err := f.Sync()
+		if err != nil {
+			r.log.Println("WARN: could not sync download:", err)
+			return
+		}
+		lockID := r.mu.Lock()
+		r.downloads[destination] = downloadProgress{
+			SiaPath:         path,
+			FileSize:        file.size,
+			ChunksCompleted: chunksCompleted,
+		}
+		err = r.save()
+		r.mu.Unlock(lockID)
+		if err != nil {
+			r.log.Println("WARN: could not save download progress:", err)
+		}
+	}
+
+	d.stop = r.tg.StopChan()
 
 	// A loop that will iterate until the download is complete.
 	// Downloads are canceled if they make no progress for 120 minutes.
@@ -236,7 +469,12 @@ func (r *Renter) Download(path, destination string) error {
 		// wait up to 60 minutes for upload loop to exit
 		timeout := time.Now().Add(60 * time.Minute)
 		for uploading && time.Now().Before(timeout) {
-			time.Sleep(time.Second)
+			select {
+			case <-r.tg.StopChan():
+				resumeUploads()
+				return errDownloadInterrupted
+			case <-time.After(time.Second):
+			}
 			lockID = r.mu.RLock()
 			uploading = r.uploading
 			r.mu.RUnlock(lockID)
@@ -258,7 +496,8 @@ func (r *Renter) Download(path, destination string) error {
 					continue
 				}
 				defer d.Close()
-				hosts = append(hosts, newHostFetcher(d, c.Pieces, file.masterKey))
+				d = countingDownloader{Downloader: d, counters: r.transfers, history: r.history, host: c.IP, siapath: file.name}
+				hosts = append(hosts, newHostFetcher(c.IP, d, c.Pieces, file.masterKey, file.cipher(), file.encrypted(), file.storedPieceSize()))
 			}
 			if len(hosts) < file.erasureCode.MinPieces() {
 				return false, errors.New("could not connect to enough hosts:\n" + strings.Join(errs, "\n"))
@@ -273,18 +512,37 @@ func (r *Renter) Download(path, destination string) error {
 
 			// Perform download.
 			err = d.run(f)
+			if err == errDownloadInterrupted {
+				return false, err
+			}
 			done := err == nil
 			return done, nil
 		}()
+		if err == errDownloadInterrupted {
+			resumeUploads()
+			return err
+		}
 		if done {
-			// Download is complete!
+			// Download is complete! The progress no longer needs to be
+			// tracked.
 			resumeUploads()
-			break
+			lockID = r.mu.Lock()
+			delete(r.downloads, destination)
+			err = r.save()
+			r.mu.Unlock(lockID)
+			if err != nil {
+				r.log.Println("WARN: could not save download progress:", err)
+			}
+			return r.verifyDownload(path, f)
 		} else if err != nil {
 			// One of the more severe errors occurred, wait a bit before trying
 			// the download again.
 			resumeUploads()
-			time.Sleep(time.Second * 90)
+			select {
+			case <-r.tg.StopChan():
+				return errDownloadInterrupted
+			case <-time.After(time.Second * 90):
+			}
 		} else {
 			// We made progress, but haven't finished yet. Reset the progress
 			// deadline.
@@ -295,6 +553,30 @@ func (r *Renter) Download(path, destination string) error {
 			return errors.New("no progress in 30 minutes; giving up")
 		}
 	}
+}
+
+// verifyDownload checks the downloaded file f against the checksum recorded
+// when the file at path was uploaded. Files without a recorded checksum are
+// not checked. The whole file is read back from disk, because a resumed
+// download only writes the chunks that were missing.
+func (r *Renter) verifyDownload(path string, f *os.File) error {
+	lockID := r.mu.RLock()
+	checksum, exists := r.fileChecksums[path]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return nil
+	}
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	sum, err := dataChecksum(f)
+	if err != nil {
+		return err
+	}
+	if sum != checksum {
+		r.log.Printf("WARN: download of %v to %v does not match its checksum: expected %v, got %v\n", path, f.Name(), checksum, sum)
+		return errChecksumMismatch
+	}
 	return nil
 }
 
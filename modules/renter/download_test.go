@@ -2,10 +2,15 @@ package renter
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
@@ -30,6 +35,10 @@ func (f *testFetcher) pieces(chunkIndex uint64) []pieceData {
 	return f.pieceMap[chunkIndex]
 }
 
+func (f *testFetcher) host() modules.NetAddress {
+	return modules.NetAddress(fmt.Sprintf("%p", f))
+}
+
 func (f *testFetcher) fetch(p pieceData) ([]byte, error) {
 	f.nAttempt++
 	time.Sleep(f.delay)
@@ -140,6 +149,162 @@ func TestErasureDownload(t *testing.T) {
 	*/
 }
 
+// TestDownloadResume tests that a download can be resumed from the last
+// completed chunk of a partially written destination file.
+func TestDownloadResume(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	// generate data
+	const dataSize = 777
+	data, err := crypto.RandBytes(dataSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, err := NewRSCode(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create hosts and upload data to them
+	const pieceSize = 10
+	hosts := make([]fetcher, rsc.NumPieces())
+	for i := range hosts {
+		hosts[i] = &testFetcher{
+			sectors:   make(map[crypto.Hash][]byte),
+			pieceMap:  make(map[uint64][]pieceData),
+			pieceSize: pieceSize,
+			failRate:  1e9, // effectively never fail
+		}
+	}
+	r := bytes.NewReader(data)
+	chunk := make([]byte, pieceSize*rsc.MinPieces())
+	for i := uint64(0); ; i++ {
+		_, err := io.ReadFull(r, chunk)
+		if err == io.EOF {
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatal(err)
+		}
+		pieces, err := rsc.Encode(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, p := range pieces {
+			root := crypto.MerkleRoot(p)
+			host := hosts[j].(*testFetcher)
+			host.pieceMap[i] = append(host.pieceMap[i], pieceData{
+				Chunk:      i,
+				Piece:      uint64(j),
+				MerkleRoot: root,
+			})
+			host.sectors[root] = p
+		}
+	}
+
+	// Write the first 5 chunks of the file, followed by part of a chunk of
+	// garbage, to emulate a download that failed partway through.
+	dir := build.TempDir("renter", "TestDownloadResume")
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	chunkSize := uint64(pieceSize * rsc.MinPieces())
+	partial := append(append([]byte{}, data[:5*chunkSize]...), make([]byte, chunkSize/2)...)
+	_, err = f.Write(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Resume the download; only the remaining chunks should be fetched.
+	d := newFile("foo", rsc, pieceSize, dataSize).newDownload(hosts, f.Name())
+	var completed []uint64
+	d.chunkComplete = func(n uint64) { completed = append(completed, n) }
+	err = d.resume(f, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.run(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts int
+	for _, h := range hosts {
+		attempts += h.(*testFetcher).nAttempt
+	}
+	numChunks := (dataSize + chunkSize - 1) / chunkSize
+	if expected := int(numChunks-5) * rsc.MinPieces(); attempts != expected {
+		t.Errorf("expected %v fetches, got %v", expected, attempts)
+	}
+	if len(completed) == 0 || completed[len(completed)-1] != numChunks {
+		t.Error("chunk completion was not reported:", completed)
+	}
+
+	recovered, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered, data) {
+		t.Fatal("recovered data does not match original")
+	}
+}
+
+// TestVerifyDownload checks that a downloaded file is only accepted if it
+// matches the checksum recorded when the file was uploaded.
+func TestVerifyDownload(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestVerifyDownload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	data, err := crypto.RandBytes(777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(build.TempDir("renter", "TestVerifyDownload"), "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files without a checksum are not checked.
+	if err := rt.renter.verifyDownload("foo", f); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := dataChecksum(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.fileChecksums["foo"] = checksum
+	rt.renter.mu.Unlock(id)
+	if err := rt.renter.verifyDownload("foo", f); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the downloaded data.
+	if _, err := f.WriteAt([]byte{data[100] + 1}, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.verifyDownload("foo", f); err != errChecksumMismatch {
+		t.Fatal("expected errChecksumMismatch, got", err)
+	}
+}
+
 type downloadContractor struct {
 	stubContractor
 	downloaders int
@@ -1,9 +1,12 @@
 package renter
 
 import (
+	"encoding/hex"
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -12,10 +15,25 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
+const (
+	// maxFileTags is the maximum number of tags that can be attached to a
+	// file.
+	maxFileTags = 64
+
+	// maxFileTagLen is the maximum length of a tag key or value.
+	maxFileTagLen = 256
+)
+
 var (
 	ErrEmptyFilename = errors.New("filename must be a nonempty string")
 	ErrUnknownPath   = errors.New("no file known with that path")
 	ErrPathOverload  = errors.New("a file already exists at that location")
+
+	errLeadingSlash = errors.New("nicknames cannot begin with /")
+
+	errEmptyTagKey = errors.New("file tags cannot have an empty key")
+	errTooManyTags = errors.New("too many tags attached to file")
+	errTagTooLong  = errors.New("file tag key or value is too long")
 )
 
 // A file is a single file that has been uploaded to the network. Files are
@@ -31,7 +49,11 @@ type file struct {
 	erasureCode modules.ErasureCoder
 	pieceSize   uint64
 	mode        uint32 // actually an os.FileMode
-	mu          sync.RWMutex
+	cipherType  crypto.CipherType
+	// preEncrypted is set if the data was encrypted before it was uploaded,
+	// in which case the renter does not encrypt the pieces of the file.
+	preEncrypted bool
+	mu           sync.RWMutex
 }
 
 // A fileContract is a contract covering an arbitrary number of file pieces.
@@ -155,6 +177,57 @@ func (f *file) expiration() types.BlockHeight {
 	return lowest
 }
 
+// storedPieceSize returns the number of bytes of each sector that are used
+// by a piece of the file. Pieces that are smaller than a sector are padded to
+// a full sector when they are uploaded.
+func (f *file) storedPieceSize() uint64 {
+	if f.encrypted() {
+		return f.pieceSize + crypto.TwofishOverhead
+	}
+	return f.pieceSize
+}
+
+// encrypted returns true if the pieces of the file are encrypted by the
+// renter.
+func (f *file) encrypted() bool {
+	return !f.preEncrypted
+}
+
+// cipher returns the cipher that encrypts the pieces of the file.
+func (f *file) cipher() crypto.CipherType {
+	if f.cipherType == "" {
+		return crypto.CipherTwofish
+	}
+	return f.cipherType
+}
+
+// fileInfoCipher returns the cipher reported for the file, which is empty if
+// the file is not encrypted by the renter.
+func (f *file) fileInfoCipher() crypto.CipherType {
+	if !f.encrypted() {
+		return ""
+	}
+	return f.cipher()
+}
+
+// pieceKey returns the key that encrypts a piece of the file.
+func (f *file) pieceKey(chunk, piece uint64) (crypto.CipherKey, error) {
+	return crypto.NewCipherKey(f.cipher(), deriveKey(f.masterKey, chunk, piece))
+}
+
+// fileKeySource returns where the encryption key of a file came from. Files
+// that are not encrypted by the renter are recognized by their piece size, so
+// that files loaded from .sia files are also reported correctly.
+func (r *Renter) fileKeySource(f *file) string {
+	if !f.encrypted() {
+		return modules.KeySourcePreEncrypted
+	}
+	if source, exists := r.fileKeys[f.name]; exists {
+		return source
+	}
+	return modules.KeySourceRenter
+}
+
 // newFile creates a new file object.
 func newFile(name string, code modules.ErasureCoder, pieceSize, fileSize uint64) *file {
 	key, _ := crypto.GenerateTwofishKey()
@@ -165,6 +238,7 @@ func newFile(name string, code modules.ErasureCoder, pieceSize, fileSize uint64)
 		masterKey:   key,
 		erasureCode: code,
 		pieceSize:   pieceSize,
+		cipherType:  crypto.CipherTwofish,
 	}
 }
 
@@ -178,8 +252,16 @@ func (r *Renter) DeleteFile(nickname string) error {
 		return ErrUnknownPath
 	}
 	delete(r.files, nickname)
+	delete(r.fileTags, nickname)
+	delete(r.fileRetention, nickname)
+	delete(r.fileKeys, nickname)
+	delete(r.fileChecksums, nickname)
+	r.unindexFileChunks(nickname)
+	delete(r.chunkHashes, nickname)
 	os.RemoveAll(filepath.Join(r.persistDir, f.name+ShareExtension))
 	r.saveSync()
+	// Pieces that are shared with copies of the file must be kept.
+	shared := r.referencedRoots()
 	r.mu.Unlock(lockID)
 
 	// delete the file's associated contract data.
@@ -200,8 +282,10 @@ func (r *Renter) DeleteFile(nickname string) error {
 			// TODO: what if the host isn't online?
 			continue
 		}
-		for _, root := range c.MerkleRoots {
-			editor.Delete(root)
+		for _, p := range f.contracts[c.ID].Pieces {
+			if !shared[p.MerkleRoot] {
+				editor.Delete(p.MerkleRoot)
+			}
 		}
 		delete(f.contracts, c.ID)
 	}
@@ -216,22 +300,83 @@ func (r *Renter) FileList() []modules.FileInfo {
 
 	files := make([]modules.FileInfo, 0, len(r.files))
 	for _, f := range r.files {
-		// _, renewing := r.tracking[f.name]
-		// TODO: bring back per-file renewing
-		renewing := true
 		files = append(files, modules.FileInfo{
 			SiaPath:        f.name,
 			Filesize:       f.size,
 			Available:      f.available(),
 			Redundancy:     f.redundancy(),
-			Renewing:       renewing,
+			Renewing:       r.retained(f.name),
 			UploadProgress: f.uploadProgress(),
 			Expiration:     f.expiration(),
+			Tags:           copyFileTags(r.fileTags[f.name]),
+			KeySource:      r.fileKeySource(f),
+			ChunkSize:      f.chunkSize(),
+			Cipher:         f.fileInfoCipher(),
+			Checksum:       r.fileChecksums[f.name],
+			Regions:        append([]string(nil), r.tracking[f.name].Regions...),
+			RetainUntil:    r.fileRetention[f.name],
 		})
 	}
 	return files
 }
 
+// pieceKeys is a list of piece keys that can be sorted by chunk and piece.
+type pieceKeys []modules.PieceKey
+
+func (pk pieceKeys) Len() int      { return len(pk) }
+func (pk pieceKeys) Swap(i, j int) { pk[i], pk[j] = pk[j], pk[i] }
+func (pk pieceKeys) Less(i, j int) bool {
+	if pk[i].Chunk != pk[j].Chunk {
+		return pk[i].Chunk < pk[j].Chunk
+	}
+	return pk[i].Piece < pk[j].Piece
+}
+
+// ExportFileKeys returns the layout of a file, along with the location and
+// key of each of its pieces. Only the derived piece keys are returned, never
+// the master key of the file.
+func (r *Renter) ExportFileKeys(nickname string) (modules.FileKeys, error) {
+	lockID := r.mu.RLock()
+	f, exists := r.files[nickname]
+	checksum := r.fileChecksums[nickname]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return modules.FileKeys{}, ErrUnknownPath
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	fk := modules.FileKeys{
+		SiaPath:      f.name,
+		Filesize:     f.size,
+		PieceSize:    f.pieceSize,
+		DataPieces:   f.erasureCode.MinPieces(),
+		ParityPieces: f.erasureCode.NumPieces() - f.erasureCode.MinPieces(),
+		Encrypted:    f.encrypted(),
+		Cipher:       f.fileInfoCipher(),
+		Checksum:     checksum,
+		Pieces:       []modules.PieceKey{},
+	}
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			pk := modules.PieceKey{
+				Chunk:      p.Chunk,
+				Piece:      p.Piece,
+				MerkleRoot: p.MerkleRoot,
+				ContractID: fc.ID,
+				NetAddress: fc.IP,
+			}
+			if fk.Encrypted {
+				key := deriveKey(f.masterKey, p.Chunk, p.Piece)
+				pk.Key = hex.EncodeToString(key[:])
+			}
+			fk.Pieces = append(fk.Pieces, pk)
+		}
+	}
+	sort.Sort(pieceKeys(fk.Pieces))
+	return fk, nil
+}
+
 // RenameFile takes an existing file and changes the nickname. The original
 // file must exist, and there must not be any file that already has the
 // replacement nickname.
@@ -239,9 +384,9 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 	lockID := r.mu.Lock()
 	defer r.mu.Unlock(lockID)
 
-	// Check that newName is nonempty.
-	if newName == "" {
-		return ErrEmptyFilename
+	// Check that newName is valid.
+	if err := validateSiaPath(newName); err != nil {
+		return err
 	}
 
 	// Check that currentName exists and newName doesn't.
@@ -266,6 +411,32 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 	// Update the entries in the renter.
 	delete(r.files, currentName)
 	r.files[newName] = file
+	if tags, exists := r.fileTags[currentName]; exists {
+		delete(r.fileTags, currentName)
+		r.fileTags[newName] = tags
+	}
+	if until, exists := r.fileRetention[currentName]; exists {
+		delete(r.fileRetention, currentName)
+		r.fileRetention[newName] = until
+	}
+	if source, exists := r.fileKeys[currentName]; exists {
+		delete(r.fileKeys, currentName)
+		r.fileKeys[newName] = source
+	}
+	if checksum, exists := r.fileChecksums[currentName]; exists {
+		delete(r.fileChecksums, currentName)
+		r.fileChecksums[newName] = checksum
+	}
+	if hashes, exists := r.chunkHashes[currentName]; exists {
+		r.unindexFileChunks(currentName)
+		delete(r.chunkHashes, currentName)
+		r.chunkHashes[newName] = hashes
+		r.indexFileChunks(newName)
+	}
+	if meta, exists := r.tracking[currentName]; exists {
+		delete(r.tracking, currentName)
+		r.tracking[newName] = meta
+	}
 	err = r.saveSync()
 	if err != nil {
 		return err
@@ -279,3 +450,147 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 	oldPath := filepath.Join(r.persistDir, currentName+ShareExtension)
 	return os.RemoveAll(oldPath)
 }
+
+// CopyFile creates a new file, newName, that shares the pieces of an existing
+// file. No data is uploaded or downloaded; the copy keeps the tags, key, and
+// checksum of the original, and is repaired independently of it from the same
+// source. The pieces remain on their hosts until every file that shares them
+// has been deleted.
+func (r *Renter) CopyFile(currentName, newName string) error {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+
+	if err := validateSiaPath(newName); err != nil {
+		return err
+	}
+	f, exists := r.files[currentName]
+	if !exists {
+		return ErrUnknownPath
+	}
+	if _, exists := r.files[newName]; exists {
+		return ErrPathOverload
+	}
+
+	f.mu.RLock()
+	copied := &file{
+		name:        newName,
+		size:        f.size,
+		contracts:   make(map[types.FileContractID]fileContract, len(f.contracts)),
+		masterKey:   f.masterKey,
+		erasureCode: f.erasureCode,
+		pieceSize:   f.pieceSize,
+		mode:        f.mode,
+		cipherType:  f.cipherType,
+
+		preEncrypted: f.preEncrypted,
+	}
+	for id, fc := range f.contracts {
+		fc.Pieces = append([]pieceData(nil), fc.Pieces...)
+		copied.contracts[id] = fc
+	}
+	f.mu.RUnlock()
+	if err := r.saveFile(copied); err != nil {
+		return err
+	}
+
+	r.files[newName] = copied
+	if tags, exists := r.fileTags[currentName]; exists {
+		r.fileTags[newName] = copyFileTags(tags)
+	}
+	if until, exists := r.fileRetention[currentName]; exists {
+		r.fileRetention[newName] = until
+	}
+	if source, exists := r.fileKeys[currentName]; exists {
+		r.fileKeys[newName] = source
+	}
+	if checksum, exists := r.fileChecksums[currentName]; exists {
+		r.fileChecksums[newName] = checksum
+	}
+	if hashes, exists := r.chunkHashes[currentName]; exists {
+		r.chunkHashes[newName] = append([]crypto.Hash(nil), hashes...)
+		r.indexFileChunks(newName)
+	}
+	if meta, exists := r.tracking[currentName]; exists {
+		meta.Regions = append([]string(nil), meta.Regions...)
+		r.tracking[newName] = meta
+	}
+	return r.saveSync()
+}
+
+// referencedRoots returns the Merkle roots of every piece of every file in
+// the renter.
+func (r *Renter) referencedRoots() map[crypto.Hash]bool {
+	roots := make(map[crypto.Hash]bool)
+	for _, f := range r.files {
+		f.mu.RLock()
+		for _, fc := range f.contracts {
+			for _, p := range fc.Pieces {
+				roots[p.MerkleRoot] = true
+			}
+		}
+		f.mu.RUnlock()
+	}
+	return roots
+}
+
+// validateSiaPath checks that a path can be used as the nickname of a file.
+func validateSiaPath(path string) error {
+	if strings.HasPrefix(path, "/") {
+		return errLeadingSlash
+	}
+	if path == "" {
+		return ErrEmptyFilename
+	}
+	return nil
+}
+
+// SetFileTags replaces the tags attached to a file. Passing no tags removes
+// all of the tags from the file.
+func (r *Renter) SetFileTags(nickname string, tags map[string]string) error {
+	if err := validateFileTags(tags); err != nil {
+		return err
+	}
+
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if _, exists := r.files[nickname]; !exists {
+		return ErrUnknownPath
+	}
+	if len(tags) == 0 {
+		delete(r.fileTags, nickname)
+	} else {
+		r.fileTags[nickname] = copyFileTags(tags)
+	}
+	return r.saveSync()
+}
+
+// validateFileTags checks that a set of file tags is within the limits of the
+// renter.
+func validateFileTags(tags map[string]string) error {
+	if len(tags) > maxFileTags {
+		return errTooManyTags
+	}
+	for k, v := range tags {
+		if k == "" {
+			return errEmptyTagKey
+		}
+		if len(k) > maxFileTagLen || len(v) > maxFileTagLen {
+			return errTagTooLong
+		}
+	}
+	return nil
+}
+
+// copyFileTags returns a copy of a set of file tags, so that callers cannot
+// modify the tags held by the renter. A nil map is returned if there are no
+// tags.
+func copyFileTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
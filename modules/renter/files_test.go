@@ -1,10 +1,13 @@
 package renter
 
 import (
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -339,3 +342,320 @@ func TestRenterRenameFile(t *testing.T) {
 		t.Error("Expecting ErrPathOverload, got", err)
 	}
 }
+
+// TestRenterCopyFile probes the copy method of the renter.
+func TestRenterCopyFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestRenterCopyFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Copy a file that doesn't exist.
+	err = rt.renter.CopyFile("1", "1a")
+	if err != ErrUnknownPath {
+		t.Error("Expecting ErrUnknownPath:", err)
+	}
+
+	// Copy a file that does exist.
+	f := newTestingFile()
+	f.name = "1"
+	f.contracts = map[types.FileContractID]fileContract{
+		{1}: {ID: types.FileContractID{1}, Pieces: []pieceData{{MerkleRoot: crypto.Hash{1}}}},
+	}
+	rt.renter.files[f.name] = f
+	rt.renter.fileTags[f.name] = map[string]string{"foo": "bar"}
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: "/foo"}
+	err = rt.renter.CopyFile("1", "1a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.FileList()) != 2 {
+		t.Fatal("FileList has unexpected number of files:", len(rt.renter.FileList()))
+	}
+	copied := rt.renter.files["1a"]
+	if copied == f || copied.name != "1a" || copied.masterKey != f.masterKey || len(copied.contracts) != 1 {
+		t.Fatal("copy does not share the pieces of the original:", copied)
+	}
+	if rt.renter.fileTags["1a"]["foo"] != "bar" || rt.renter.tracking["1a"].RepairPath != "/foo" {
+		t.Fatal("metadata was not copied")
+	}
+
+	// Modifying the copy should not modify the original.
+	fc := copied.contracts[types.FileContractID{1}]
+	fc.Pieces[0].MerkleRoot = crypto.Hash{2}
+	if f.contracts[types.FileContractID{1}].Pieces[0].MerkleRoot != (crypto.Hash{1}) {
+		t.Fatal("pieces of the copy are not a copy")
+	}
+	fc.Pieces[0].MerkleRoot = crypto.Hash{1}
+
+	// The pieces of the original are still referenced after it is removed.
+	delete(rt.renter.files, "1")
+	if !rt.renter.referencedRoots()[crypto.Hash{1}] {
+		t.Fatal("pieces of the copy are not referenced")
+	}
+
+	// Renaming the copy should move its repair metadata.
+	err = rt.renter.RenameFile("1a", "1b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := rt.renter.tracking["1a"]; exists || rt.renter.tracking["1b"].RepairPath != "/foo" {
+		t.Fatal("repair metadata was not moved to the renamed file")
+	}
+
+	// Copy a file to an existing or invalid name.
+	if err = rt.renter.CopyFile("1b", "1b"); err != ErrPathOverload {
+		t.Error("Expecting ErrPathOverload, got", err)
+	}
+	if err = rt.renter.CopyFile("1b", ""); err != ErrEmptyFilename {
+		t.Error("Expecting ErrEmptyFilename, got", err)
+	}
+	if err = rt.renter.CopyFile("1b", "/1c"); err != errLeadingSlash {
+		t.Error("Expecting errLeadingSlash, got", err)
+	}
+}
+
+// TestRenterFileTags probes the tagging of files in the renter.
+func TestRenterFileTags(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestRenterFileTags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Tagging an unknown file should fail.
+	err = rt.renter.SetFileTags("dne", map[string]string{"foo": "bar"})
+	if err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Put a file in the renter and tag it.
+	f := newTestingFile()
+	f.name = "1"
+	rt.renter.files[f.name] = f
+	err = rt.renter.SetFileTags(f.name, map[string]string{"": "bar"})
+	if err != errEmptyTagKey {
+		t.Fatal("expected errEmptyTagKey, got", err)
+	}
+	err = rt.renter.SetFileTags(f.name, map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := rt.renter.FileList()
+	if len(files) != 1 || files[0].Tags["foo"] != "bar" {
+		t.Fatal("FileList does not report the tags of the file:", files)
+	}
+	// Modifying the returned tags should not modify the renter's tags.
+	files[0].Tags["foo"] = "baz"
+	if rt.renter.FileList()[0].Tags["foo"] != "bar" {
+		t.Fatal("tags returned by FileList are not a copy")
+	}
+
+	// Tags should follow the file when it is renamed, and survive a reload.
+	err = rt.renter.RenameFile(f.name, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.fileTags = make(map[string]map[string]string)
+	err = rt.renter.load()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.renter.fileTags["one"]["foo"] != "bar" {
+		t.Fatal("tags were not moved to the renamed file:", rt.renter.fileTags)
+	}
+
+	// Deleting the file should remove its tags.
+	err = rt.renter.DeleteFile("one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.fileTags) != 0 {
+		t.Fatal("tags of a deleted file were not removed")
+	}
+}
+
+// TestRenterFileKeySource checks that FileList reports where the encryption
+// key of each file came from.
+func TestRenterFileKeySource(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestRenterFileKeySource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// A file with a renter-generated key.
+	f := newTestingFile()
+	f.name = "renter"
+	f.pieceSize = pieceSize
+	rt.renter.files[f.name] = f
+	// A file with a caller-supplied key.
+	f2 := newTestingFile()
+	f2.name = "caller"
+	f2.pieceSize = pieceSize
+	rt.renter.files[f2.name] = f2
+	rt.renter.fileKeys[f2.name] = modules.KeySourceCaller
+	// A file that was encrypted before upload.
+	f3 := newTestingFile()
+	f3.name = "preencrypted"
+	f3.preEncrypted = true
+	rt.renter.files[f3.name] = f3
+
+	for _, fi := range rt.renter.FileList() {
+		if fi.KeySource != fi.SiaPath {
+			t.Errorf("file %v reported key source %v", fi.SiaPath, fi.KeySource)
+		}
+	}
+
+	// The key source should follow the file when it is renamed.
+	err = rt.renter.RenameFile(f2.name, "renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.renter.fileKeys["renamed"] != modules.KeySourceCaller {
+		t.Fatal("key source was not moved to the renamed file:", rt.renter.fileKeys)
+	}
+}
+
+// TestRenterFileChecksum checks that the checksum of a file follows it when
+// it is renamed, survives a reload, and is removed with the file.
+func TestRenterFileChecksum(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestRenterFileChecksum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	f := newTestingFile()
+	rt.renter.files[f.name] = f
+	rt.renter.fileChecksums[f.name] = "abcd"
+	if files := rt.renter.FileList(); len(files) != 1 || files[0].Checksum != "abcd" {
+		t.Fatal("FileList does not report the checksum of the file:", files)
+	}
+
+	err = rt.renter.RenameFile(f.name, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.fileChecksums = make(map[string]string)
+	err = rt.renter.load()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.renter.fileChecksums["one"] != "abcd" {
+		t.Fatal("checksum was not moved to the renamed file:", rt.renter.fileChecksums)
+	}
+
+	err = rt.renter.DeleteFile("one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.renter.fileChecksums) != 0 {
+		t.Fatal("checksum of a deleted file was not removed")
+	}
+}
+
+// TestRenterExportFileKeys checks that the exported keys of a file are the
+// derived keys of its pieces, sorted by chunk and piece.
+func TestRenterExportFileKeys(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestRenterExportFileKeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if _, err := rt.renter.ExportFileKeys("missing"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	f := newTestingFile()
+	f.name = "exported"
+	f.pieceSize = pieceSize
+	f.contracts = map[types.FileContractID]fileContract{
+		{1}: {ID: types.FileContractID{1}, IP: "foo:1234", Pieces: []pieceData{{Chunk: 1, Piece: 0}, {Chunk: 0, Piece: 1}}},
+		{2}: {ID: types.FileContractID{2}, IP: "bar:1234", Pieces: []pieceData{{Chunk: 0, Piece: 0}}},
+	}
+	rt.renter.files[f.name] = f
+
+	fk, err := rt.renter.ExportFileKeys(f.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fk.SiaPath != f.name || fk.Filesize != f.size || !fk.Encrypted || fk.Cipher != crypto.CipherTwofish || len(fk.Pieces) != 3 {
+		t.Fatal("unexpected file keys:", fk)
+	}
+	for i, expected := range []pieceData{{Chunk: 0, Piece: 0}, {Chunk: 0, Piece: 1}, {Chunk: 1, Piece: 0}} {
+		pk := fk.Pieces[i]
+		if pk.Chunk != expected.Chunk || pk.Piece != expected.Piece {
+			t.Fatalf("piece %v is chunk %v piece %v", i, pk.Chunk, pk.Piece)
+		}
+		key := deriveKey(f.masterKey, pk.Chunk, pk.Piece)
+		if pk.Key != hex.EncodeToString(key[:]) {
+			t.Errorf("piece %v has the wrong key", i)
+		}
+		if pk.Key == hex.EncodeToString(f.masterKey[:]) {
+			t.Error("master key was exported")
+		}
+	}
+
+	// Files that were encrypted before upload have no piece keys.
+	f.preEncrypted = true
+	fk, err = rt.renter.ExportFileKeys(f.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fk.Encrypted || fk.Pieces[0].Key != "" {
+		t.Error("keys were exported for a pre-encrypted file")
+	}
+}
+
+// TestFilePieceKey checks that the pieces of a file are encrypted with the
+// file's cipher, and that files without a recorded cipher use Twofish.
+func TestFilePieceKey(t *testing.T) {
+	f := newTestingFile()
+	plaintext := []byte("piece data")
+	for _, ct := range crypto.CipherTypes() {
+		f.cipherType = ct
+		key, err := f.pieceKey(1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext, err := key.EncryptBytes(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := crypto.NewCipherKey(ct, deriveKey(f.masterKey, 1, 2))
+		if _, err := expected.DecryptBytes(ciphertext); err != nil {
+			t.Errorf("%v piece could not be decrypted: %v", ct, err)
+		}
+	}
+
+	f.cipherType = ""
+	if f.cipher() != crypto.CipherTwofish {
+		t.Error("file without a cipher does not use Twofish:", f.cipher())
+	}
+	f.cipherType = "rot13"
+	if _, err := f.pieceKey(0, 0); err != crypto.ErrUnknownCipher {
+		t.Error("expected ErrUnknownCipher, got", err)
+	}
+}
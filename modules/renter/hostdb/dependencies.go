@@ -31,11 +31,12 @@ type (
 	}
 )
 
-// stdDialer implements the dialer interface via net.DialTimeout.
+// stdDialer implements the dialer interface via modules.Dial, so that the
+// connection goes through the proxy if one is set.
 type stdDialer struct{}
 
 func (d stdDialer) DialTimeout(addr modules.NetAddress, timeout time.Duration) (net.Conn, error) {
-	return net.DialTimeout("tcp", string(addr), timeout)
+	return modules.Dial(&net.Dialer{Timeout: timeout}, addr)
 }
 
 // stdSleeper implements the sleeper interface via time.Sleep.
@@ -43,24 +44,24 @@ type stdSleeper struct{}
 
 func (s stdSleeper) Sleep(d time.Duration) { time.Sleep(d) }
 
-// stdPersist implements the persister interface via persist.SaveFile and
-// persist.LoadFile. The metadata and filename required by these functions is
-// internal to stdPersist.
+// stdPersist implements the persister interface via persist.SaveFileBackups
+// and persist.LoadFileBackups. The metadata and filename required by these
+// functions is internal to stdPersist.
 type stdPersist struct {
 	meta     persist.Metadata
 	filename string
 }
 
 func (p *stdPersist) save(data hdbPersist) error {
-	return persist.SaveFile(p.meta, data, p.filename)
+	return persist.SaveFileBackups(p.meta, data, p.filename, persist.DefaultBackups)
 }
 
 func (p *stdPersist) saveSync(data hdbPersist) error {
-	return persist.SaveFileSync(p.meta, data, p.filename)
+	return persist.SaveFileBackupsSync(p.meta, data, p.filename, persist.DefaultBackups)
 }
 
 func (p *stdPersist) load(data *hdbPersist) error {
-	return persist.LoadFile(p.meta, data, p.filename)
+	return persist.LoadFileBackups(p.meta, data, p.filename)
 }
 
 func newPersist(dir string) *stdPersist {
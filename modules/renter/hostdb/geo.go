@@ -0,0 +1,120 @@
+package hostdb
+
+// geo.go contains the database that locates hosts by IP address, allowing
+// uploads to be restricted to hosts in certain countries.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+type (
+	// geoRange is a range of IP addresses that are located in a country.
+	// Addresses are stored in their 16-byte form, so IPv4 and IPv6 ranges can
+	// be compared.
+	geoRange struct {
+		first   net.IP
+		last    net.IP
+		country string
+	}
+
+	// A geoDB maps IP addresses to countries. Its ranges are sorted by their
+	// first address and do not overlap.
+	geoDB struct {
+		ranges []geoRange
+	}
+
+	// geoRanges sorts ranges by their first address.
+	geoRanges []geoRange
+)
+
+func (gr geoRanges) Len() int           { return len(gr) }
+func (gr geoRanges) Less(i, j int) bool { return bytes.Compare(gr[i].first, gr[j].first) < 0 }
+func (gr geoRanges) Swap(i, j int)      { gr[i], gr[j] = gr[j], gr[i] }
+
+// loadGeoDB reads a geoDB from the file at path, in the format described by
+// modules.GeoIPFilename. A nil geoDB is returned if the file does not exist.
+func loadGeoDB(path string) (*geoDB, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readGeoDB(f)
+}
+
+// readGeoDB reads a geoDB from r.
+func readGeoDB(r io.Reader) (*geoDB, error) {
+	var ranges []geoRange
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %v: expected 3 fields, got %v", line, len(fields))
+		}
+		first := net.ParseIP(strings.TrimSpace(fields[0]))
+		last := net.ParseIP(strings.TrimSpace(fields[1]))
+		country := strings.ToUpper(strings.TrimSpace(fields[2]))
+		if first == nil || last == nil {
+			return nil, fmt.Errorf("line %v: invalid IP address", line)
+		} else if bytes.Compare(first.To16(), last.To16()) > 0 {
+			return nil, fmt.Errorf("line %v: range ends before it starts", line)
+		} else if !modules.IsCountryCode(country) {
+			return nil, fmt.Errorf("line %v: invalid country code %q", line, country)
+		}
+		ranges = append(ranges, geoRange{first.To16(), last.To16(), country})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Sort(geoRanges(ranges))
+	for i := 1; i < len(ranges); i++ {
+		if bytes.Compare(ranges[i].first, ranges[i-1].last) <= 0 {
+			return nil, fmt.Errorf("ranges starting at %v and %v overlap", ranges[i-1].first, ranges[i].first)
+		}
+	}
+	return &geoDB{ranges: ranges}, nil
+}
+
+// country returns the country of an IP address, or the empty string if the
+// address is not in any range.
+func (db *geoDB) country(ip net.IP) string {
+	if db == nil || ip == nil {
+		return ""
+	}
+	ip = ip.To16()
+	// Find the last range that starts at or before ip.
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].first, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, db.ranges[i].last) > 0 {
+		return ""
+	}
+	return db.ranges[i].country
+}
+
+// HostCountry returns the country that a host was located in when it was
+// last scanned, or the empty string if the country is not known.
+func (hdb *HostDB) HostCountry(addr modules.NetAddress) string {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	if entry, ok := hdb.allHosts[addr]; ok {
+		return entry.Country
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package hostdb
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// TestGeoDB checks that IP addresses are located in the ranges of a geoDB.
+func TestGeoDB(t *testing.T) {
+	db, err := readGeoDB(strings.NewReader(`# first,last,country
+5.0.0.0,5.255.255.255,de
+
+1.0.0.0,1.0.0.255,AU
+2001:db8::,2001:db8::ffff,CH
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip      string
+		country string
+	}{
+		{"1.0.0.0", "AU"},
+		{"1.0.0.128", "AU"},
+		{"1.0.0.255", "AU"},
+		{"1.0.1.0", ""},
+		{"0.255.255.255", ""},
+		{"5.1.2.3", "DE"},
+		{"6.0.0.0", ""},
+		{"2001:db8::1", "CH"},
+		{"2001:db8::1:0", ""},
+	}
+	for _, test := range tests {
+		if c := db.country(net.ParseIP(test.ip)); c != test.country {
+			t.Errorf("%v: expected %q, got %q", test.ip, test.country, c)
+		}
+	}
+
+	// A nil geoDB knows no countries.
+	var nilDB *geoDB
+	if c := nilDB.country(net.ParseIP("1.0.0.1")); c != "" {
+		t.Error("nil geoDB located an address in", c)
+	}
+
+	// Invalid data is rejected.
+	for _, bad := range []string{
+		"1.0.0.0,1.0.0.255",
+		"1.0.0.0,foo,AU",
+		"1.0.0.255,1.0.0.0,AU",
+		"1.0.0.0,1.0.0.255,Australia",
+		"1.0.0.0,1.0.0.255,AU\n1.0.0.128,1.0.1.0,NZ",
+	} {
+		if _, err := readGeoDB(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+
+	// A missing file is not an error.
+	db, err = loadGeoDB(filepath.Join(build.TempDir("hostdb", "TestGeoDB"), "missing.csv"))
+	if err != nil || db != nil {
+		t.Fatal("expected no geoDB for a missing file, got", db, err)
+	}
+}
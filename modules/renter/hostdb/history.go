@@ -0,0 +1,73 @@
+package hostdb
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// maxSettingsHistory is the number of settings changes that are
+	// remembered for each host. Older changes are discarded.
+	maxSettingsHistory = func() int {
+		switch build.Release {
+		case "dev":
+			return 50
+		case "standard":
+			return 100
+		case "testing":
+			return 5
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+)
+
+// sameTerms returns true if two settings changes advertise the same prices,
+// collateral, and capacity.
+func sameTerms(a, b modules.HostSettingsChange) bool {
+	return a.ContractPrice.Cmp(b.ContractPrice) == 0 &&
+		a.DownloadBandwidthPrice.Cmp(b.DownloadBandwidthPrice) == 0 &&
+		a.StoragePrice.Cmp(b.StoragePrice) == 0 &&
+		a.UploadBandwidthPrice.Cmp(b.UploadBandwidthPrice) == 0 &&
+		a.Collateral.Cmp(b.Collateral) == 0 &&
+		a.MaxCollateral.Cmp(b.MaxCollateral) == 0 &&
+		a.TotalStorage == b.TotalStorage
+}
+
+// recordSettings adds the settings of a successful scan to the host's
+// settings history if they differ from the most recently recorded settings.
+func (hdb *HostDB) recordSettings(entry *hostEntry, settings modules.HostExternalSettings) {
+	change := modules.HostSettingsChange{
+		Timestamp:   types.CurrentTimestamp(),
+		BlockHeight: hdb.blockHeight,
+
+		ContractPrice:          settings.ContractPrice,
+		DownloadBandwidthPrice: settings.DownloadBandwidthPrice,
+		StoragePrice:           settings.StoragePrice,
+		UploadBandwidthPrice:   settings.UploadBandwidthPrice,
+		Collateral:             settings.Collateral,
+		MaxCollateral:          settings.MaxCollateral,
+		TotalStorage:           settings.TotalStorage,
+	}
+	if n := len(entry.SettingsHistory); n > 0 && sameTerms(entry.SettingsHistory[n-1], change) {
+		return
+	}
+	entry.SettingsHistory = append(entry.SettingsHistory, change)
+	if len(entry.SettingsHistory) > maxSettingsHistory {
+		entry.SettingsHistory = entry.SettingsHistory[len(entry.SettingsHistory)-maxSettingsHistory:]
+	}
+}
+
+// HostSettingsHistory returns the observed changes to the prices, collateral,
+// and capacity of a host, oldest first. The first element holds the settings
+// of the first successful scan. If the host is not known, false is returned.
+func (hdb *HostDB) HostSettingsHistory(addr modules.NetAddress) ([]modules.HostSettingsChange, bool) {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	entry, ok := hdb.allHosts[addr]
+	if !ok || entry == nil {
+		return nil, false
+	}
+	return append([]modules.HostSettingsChange(nil), entry.SettingsHistory...), true
+}
@@ -0,0 +1,70 @@
+package hostdb
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestHostSettingsHistory checks that changes to a host's settings are
+// recorded and persisted, and that unchanged settings are not.
+func TestHostSettingsHistory(t *testing.T) {
+	hdb := bareHostDB()
+	hdb.persist = &memPersist{}
+
+	if _, ok := hdb.HostSettingsHistory("foo:1234"); ok {
+		t.Fatal("unknown host should have no history")
+	}
+
+	h := new(hostEntry)
+	h.NetAddress = "foo:1234"
+	h.Reliability = DefaultReliability
+	settings := modules.HostExternalSettings{
+		StoragePrice: types.NewCurrency64(10),
+		TotalStorage: 1e9,
+	}
+
+	// The first scan is always recorded, and repeated scans with the same
+	// settings are not.
+	hdb.managedUpdateEntry(h, settings, nil, nil)
+	hdb.managedUpdateEntry(h, settings, nil, nil)
+	history, ok := hdb.HostSettingsHistory(h.NetAddress)
+	if !ok || len(history) != 1 {
+		t.Fatal("expected 1 settings change, got", len(history))
+	}
+
+	// Changes that do not affect prices, collateral, or capacity are not
+	// recorded.
+	settings.RemainingStorage = 5e8
+	settings.RevisionNumber++
+	hdb.managedUpdateEntry(h, settings, nil, nil)
+	if history, _ = hdb.HostSettingsHistory(h.NetAddress); len(history) != 1 {
+		t.Fatal("expected 1 settings change, got", len(history))
+	}
+
+	// A price that changes back and forth is recorded every time, and the
+	// oldest changes are discarded.
+	for i := 0; i < 5; i++ {
+		settings.StoragePrice = types.NewCurrency64(uint64(20 + 10*(i%2)))
+		hdb.managedUpdateEntry(h, settings, nil, nil)
+	}
+	if history, _ = hdb.HostSettingsHistory(h.NetAddress); len(history) != maxSettingsHistory {
+		t.Fatal("expected", maxSettingsHistory, "settings changes, got", len(history))
+	}
+	if history[len(history)-1].StoragePrice.Cmp(settings.StoragePrice) != 0 {
+		t.Fatal("most recent change should be last")
+	}
+
+	// The history is persisted with the host.
+	mp := hdb.persist.(*memPersist)
+	var persisted []modules.HostSettingsChange
+	for _, entry := range mp.AllHosts {
+		if entry.NetAddress == h.NetAddress {
+			persisted = entry.SettingsHistory
+		}
+	}
+	if len(persisted) != len(history) {
+		t.Fatal("settings history was not persisted")
+	}
+}
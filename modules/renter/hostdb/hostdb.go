@@ -71,6 +71,10 @@ type HostDB struct {
 
 	blockHeight types.BlockHeight
 	lastChange  modules.ConsensusChangeID
+
+	// geo locates hosts by the IP address they are reached at. It is nil if
+	// no geolocation data was supplied.
+	geo *geoDB
 }
 
 // New returns a new HostDB.
@@ -91,8 +95,21 @@ func New(cs consensusSet, persistDir string) (*HostDB, error) {
 		return nil, err
 	}
 
+	// Load the geolocation data, if it was supplied.
+	geo, err := loadGeoDB(filepath.Join(persistDir, modules.GeoIPFilename))
+	if err != nil {
+		return nil, errors.New("could not load geolocation data: " + err.Error())
+	}
+
 	// Create HostDB using production dependencies.
-	return newHostDB(cs, stdDialer{}, stdSleeper{}, newPersist(persistDir), logger)
+	hdb, err := newHostDB(cs, stdDialer{}, stdSleeper{}, newPersist(persistDir), logger)
+	if err != nil {
+		return nil, err
+	}
+	hdb.mu.Lock()
+	hdb.geo = geo
+	hdb.mu.Unlock()
+	return hdb, nil
 }
 
 // newHostDB creates a HostDB using the provided dependencies. It loads the old
@@ -111,6 +128,7 @@ func newHostDB(cs consensusSet, d dialer, s sleeper, p persister, l *persist.Log
 		allHosts:    make(map[modules.NetAddress]*hostEntry),
 		scanPool:    make(chan *hostEntry, scanPoolSize),
 	}
+	hdb.tg.SetName("hostdb")
 
 	// Load the prior persistence structures.
 	err := hdb.load()
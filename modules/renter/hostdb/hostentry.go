@@ -15,6 +15,14 @@ type hostEntry struct {
 	Weight      types.Currency
 	Reliability types.Currency
 	Online      bool
+
+	// Country is the country that the host was located in when it was last
+	// scanned successfully, or empty if it is not known.
+	Country string
+
+	// SettingsHistory holds the most recent changes to the host's prices,
+	// collateral, and capacity, oldest first.
+	SettingsHistory []modules.HostSettingsChange
 }
 
 // insertHost adds a host entry to the state. The host will be inserted into
@@ -123,8 +123,8 @@ func (hdb *HostDB) decrementReliability(addr modules.NetAddress, penalty types.C
 }
 
 // managedUpdateEntry updates an entry in the hostdb after a scan has taken
-// place.
-func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.HostExternalSettings, netErr error) {
+// place. ip is the address that the host was reached at.
+func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.HostExternalSettings, ip net.IP, netErr error) {
 	hdb.mu.Lock()
 	defer hdb.mu.Unlock()
 
@@ -145,6 +145,7 @@ func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.Host
 		}
 		return
 	}
+	hdb.recordSettings(entry, newSettings)
 
 	// The host entry should be updated to reflect the new weight. The safety
 	// properties of the tree require that the weight does not change while the
@@ -165,6 +166,7 @@ func (hdb *HostDB) managedUpdateEntry(entry *hostEntry, newSettings modules.Host
 	entry.HostExternalSettings = newSettings
 	entry.Reliability = MaxReliability
 	entry.Online = true
+	entry.Country = hdb.geo.country(ip)
 	entry.Weight = calculateHostWeight(hdb.blockHeight, *entry)
 	hdb.insertNode(entry)
 
@@ -188,12 +190,13 @@ func (hdb *HostDB) managedScanHost(hostEntry *hostEntry) {
 	hdb.mu.RUnlock()
 	hdb.log.Debugln("Scanning", netAddr, pubKey)
 	var settings modules.HostExternalSettings
+	var ip net.IP
 	err := func() error {
 		dialer := &net.Dialer{
 			Cancel:  hdb.tg.StopChan(),
 			Timeout: hostRequestTimeout,
 		}
-		conn, err := dialer.Dial("tcp", string(netAddr))
+		conn, err := modules.Dial(dialer, netAddr)
 		if err != nil {
 			return err
 		}
@@ -207,6 +210,13 @@ func (hdb *HostDB) managedScanHost(hostEntry *hostEntry) {
 		}()
 		defer close(connCloseChan)
 		conn.SetDeadline(time.Now().Add(hostScanDeadline))
+		// Through a proxy, the remote address is that of the proxy, so the
+		// host can only be located if its address is an IP address.
+		if modules.Proxy() != "" {
+			ip = net.ParseIP(netAddr.Host())
+		} else if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			ip = addr.IP
+		}
 
 		err = encoding.WriteObject(conn, modules.RPCSettings)
 		if err != nil {
@@ -223,18 +233,18 @@ func (hdb *HostDB) managedScanHost(hostEntry *hostEntry) {
 	}
 
 	// Update the host tree to have a new entry.
-	hdb.managedUpdateEntry(hostEntry, settings, err)
+	hdb.managedUpdateEntry(hostEntry, settings, ip, err)
 }
 
 // threadedProbeHosts tries to fetch the settings of a host. If successful, the
 // host is put in the set of active hosts. If unsuccessful, the host id deleted
 // from the set of active hosts.
 func (hdb *HostDB) threadedProbeHosts() {
-	err := hdb.tg.Add()
+	err := hdb.tg.AddNamed("threadedProbeHosts")
 	if err != nil {
 		return
 	}
-	defer hdb.tg.Done()
+	defer hdb.tg.DoneNamed("threadedProbeHosts")
 
 	for {
 		select {
@@ -249,11 +259,11 @@ func (hdb *HostDB) threadedProbeHosts() {
 // threadedScan is an ongoing function which will query the full set of hosts
 // every few hours to see who is online and available for uploading.
 func (hdb *HostDB) threadedScan() {
-	err := hdb.tg.Add()
+	err := hdb.tg.AddNamed("threadedScan")
 	if err != nil {
 		return
 	}
-	defer hdb.tg.Done()
+	defer hdb.tg.DoneNamed("threadedScan")
 
 	for {
 		// Determine who to scan. At most 'maxActiveHosts' will be scanned,
@@ -0,0 +1,163 @@
+package renter
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// stallMultiplier is the number of times longer than its average fetch
+	// time that a host may take to return a piece before it is considered to
+	// have stalled.
+	stallMultiplier = 4
+)
+
+var (
+	// minStallTimeout is the least amount of time that a host is given to
+	// return a piece before it is considered to have stalled. Hosts that have
+	// not been measured yet are given exactly this long.
+	minStallTimeout = func() time.Duration {
+		if build.Release == "dev" {
+			return 10 * time.Second
+		}
+		if build.Release == "standard" {
+			return 60 * time.Second
+		}
+		if build.Release == "testing" {
+			return 50 * time.Millisecond
+		}
+		panic("unrecognized release constant in renter - minStallTimeout")
+	}()
+)
+
+// hostLatency records the recent performance of a host when fetching pieces.
+type hostLatency struct {
+	// average is an exponentially weighted moving average of the time taken
+	// by successful fetches.
+	average time.Duration
+
+	// failures is the number of consecutive fetches that have failed.
+	failures int
+
+	// stalled is set while a fetch from the host has exceeded its stall
+	// timeout and has not yet returned.
+	stalled bool
+
+	// lastFetch is the time at which the most recent fetch returned.
+	lastFetch time.Time
+}
+
+// A latencyTracker records the performance of the hosts that the renter
+// downloads from, so that the fastest hosts can be tried first. Sectors are
+// always fetched in full, so the time taken by fetches from different files
+// can be compared directly.
+type latencyTracker struct {
+	hosts map[modules.NetAddress]*hostLatency
+	mu    sync.Mutex
+}
+
+// newLatencyTracker returns an empty latencyTracker.
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		hosts: make(map[modules.NetAddress]*hostLatency),
+	}
+}
+
+// host returns the entry for addr, creating it if necessary.
+func (lt *latencyTracker) host(addr modules.NetAddress) *hostLatency {
+	hl, exists := lt.hosts[addr]
+	if !exists {
+		hl = new(hostLatency)
+		lt.hosts[addr] = hl
+	}
+	return hl
+}
+
+// record updates the performance of a host after a fetch has returned.
+func (lt *latencyTracker) record(addr modules.NetAddress, elapsed time.Duration, err error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	hl := lt.host(addr)
+	hl.stalled = false
+	hl.lastFetch = time.Now()
+	if err != nil {
+		hl.failures++
+		return
+	}
+	hl.failures = 0
+	if hl.average == 0 {
+		hl.average = elapsed
+	} else {
+		hl.average = (3*hl.average + elapsed) / 4
+	}
+}
+
+// stall marks a host as stalled, demoting it until the fetch that began at
+// 'start' returns. Nothing is marked if that fetch has already returned.
+func (lt *latencyTracker) stall(addr modules.NetAddress, start time.Time) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	hl := lt.host(addr)
+	if hl.lastFetch.After(start) {
+		return
+	}
+	hl.stalled = true
+}
+
+// stallTimeout returns the amount of time that a host is given to return a
+// piece before it is considered to have stalled.
+func (lt *latencyTracker) stallTimeout(addr modules.NetAddress) time.Duration {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	timeout := lt.host(addr).average * stallMultiplier
+	if timeout < minStallTimeout {
+		timeout = minStallTimeout
+	}
+	return timeout
+}
+
+// fetchersByLatency sorts fetchers so that the most responsive hosts come
+// first. Stalled hosts are sorted last, followed by hosts whose latest fetch
+// failed; the rest are sorted by their average fetch time. Hosts that have
+// not been measured yet have an average of zero, so they are tried early and
+// measured.
+type fetchersByLatency struct {
+	fetchers []fetcher
+	stats    []hostLatency
+}
+
+func (fl fetchersByLatency) Len() int { return len(fl.fetchers) }
+func (fl fetchersByLatency) Less(i, j int) bool {
+	a, b := fl.stats[i], fl.stats[j]
+	if a.stalled != b.stalled {
+		return b.stalled
+	}
+	if (a.failures > 0) != (b.failures > 0) {
+		return b.failures > 0
+	}
+	return a.average < b.average
+}
+func (fl fetchersByLatency) Swap(i, j int) {
+	fl.fetchers[i], fl.fetchers[j] = fl.fetchers[j], fl.fetchers[i]
+	fl.stats[i], fl.stats[j] = fl.stats[j], fl.stats[i]
+}
+
+// order returns a copy of hosts sorted by their recent performance. Hosts
+// with equal performance keep their relative order.
+func (lt *latencyTracker) order(hosts []fetcher) []fetcher {
+	fl := fetchersByLatency{
+		fetchers: append([]fetcher(nil), hosts...),
+		stats:    make([]hostLatency, len(hosts)),
+	}
+	lt.mu.Lock()
+	for i, h := range fl.fetchers {
+		fl.stats[i] = *lt.host(h.host())
+	}
+	lt.mu.Unlock()
+	sort.Stable(fl)
+	return fl.fetchers
+}
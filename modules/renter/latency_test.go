@@ -0,0 +1,128 @@
+package renter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// TestLatencyTrackerOrder checks that hosts are ordered by their measured
+// performance.
+func TestLatencyTrackerOrder(t *testing.T) {
+	hosts := make([]fetcher, 4)
+	for i := range hosts {
+		hosts[i] = new(testFetcher)
+	}
+	lt := newLatencyTracker()
+	lt.record(hosts[0].host(), 30*time.Millisecond, nil)
+	lt.record(hosts[1].host(), 10*time.Millisecond, nil)
+	lt.record(hosts[2].host(), time.Millisecond, errors.New("failed"))
+	lt.record(hosts[3].host(), 20*time.Millisecond, nil)
+
+	expected := []fetcher{hosts[1], hosts[3], hosts[0], hosts[2]}
+	ordered := lt.order(hosts)
+	for i := range expected {
+		if ordered[i] != expected[i] {
+			t.Fatalf("host %v is out of order", i)
+		}
+	}
+
+	// A stalled host should be sorted last, until its fetch returns.
+	start := time.Now()
+	lt.stall(hosts[1].host(), start)
+	if ordered := lt.order(hosts); ordered[3] != hosts[1] {
+		t.Fatal("stalled host was not demoted")
+	}
+	lt.record(hosts[1].host(), 10*time.Millisecond, nil)
+	if ordered := lt.order(hosts); ordered[0] != hosts[1] {
+		t.Fatal("host was not restored after its stalled fetch returned")
+	}
+
+	// A stall reported after the fetch returned should be ignored.
+	lt.stall(hosts[1].host(), start)
+	if ordered := lt.order(hosts); ordered[0] != hosts[1] {
+		t.Fatal("host was demoted by a fetch that already returned")
+	}
+}
+
+// TestDownloadStalledHost checks that a host that stalls mid-download is
+// demoted, and that the download continues using the other hosts.
+func TestDownloadStalledHost(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	const dataSize = 777
+	data, err := crypto.RandBytes(dataSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, err := NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each host holds one piece of every chunk. The first host takes far
+	// longer than its stall timeout to return a piece.
+	const pieceSize = 10
+	hosts := make([]fetcher, rsc.NumPieces())
+	for i := range hosts {
+		hosts[i] = &testFetcher{
+			sectors:   make(map[crypto.Hash][]byte),
+			pieceMap:  make(map[uint64][]pieceData),
+			pieceSize: pieceSize,
+			failRate:  1e9, // effectively never fail
+		}
+	}
+	hosts[0].(*testFetcher).delay = 10 * minStallTimeout
+	r := bytes.NewReader(data)
+	chunk := make([]byte, pieceSize*rsc.MinPieces())
+	var numChunks uint64
+	for ; ; numChunks++ {
+		_, err := io.ReadFull(r, chunk)
+		if err == io.EOF {
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatal(err)
+		}
+		pieces, err := rsc.Encode(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, p := range pieces {
+			root := crypto.MerkleRoot(p)
+			host := hosts[j].(*testFetcher)
+			host.pieceMap[numChunks] = append(host.pieceMap[numChunks], pieceData{
+				Chunk:      numChunks,
+				Piece:      uint64(j),
+				MerkleRoot: root,
+			})
+			host.sectors[root] = p
+		}
+	}
+
+	d := newFile("foo", rsc, pieceSize, dataSize).newDownload(hosts, "")
+	buf := new(bytes.Buffer)
+	start := time.Now()
+	if err := d.run(buf); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Duration(numChunks)*minStallTimeout {
+		t.Error("download waited on the stalled host:", elapsed)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("recovered data does not match original")
+	}
+
+	// Wait for any fetch from the slow host to return before inspecting it.
+	if done, exists := d.inFlight[hosts[0]]; exists {
+		<-done
+	}
+	if n := hosts[0].(*testFetcher).nAttempt; n > 1 {
+		t.Errorf("stalled host was asked for %v pieces", n)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
@@ -32,6 +33,17 @@ var (
 	shareHeader  = [15]byte{'S', 'i', 'a', ' ', 'S', 'h', 'a', 'r', 'e', 'd', ' ', 'F', 'i', 'l', 'e'}
 	shareVersion = "0.4"
 
+	// shareVersionCipher is the version of .sia files that record the
+	// cipher of each file after the file. It is only used when one of the
+	// files does not use Twofish, so that older clients can still load the
+	// files that they can decrypt.
+	shareVersionCipher = "0.5"
+
+	// shareVersionEncryption is the version of .sia files that also record
+	// whether the renter encrypts the pieces of each file. It is only used
+	// when one of the files was encrypted before it was uploaded.
+	shareVersionEncryption = "0.6"
+
 	saveMetadata = persist.Metadata{
 		Header:  "Renter Persistence",
 		Version: "0.4",
@@ -178,20 +190,37 @@ func (r *Renter) saveFile(f *file) error {
 	return handle.Commit()
 }
 
-// save stores the current renter data to disk.
+// persistData contains the renter data that is saved to disk.
+type persistData struct {
+	Tracking  map[string]trackedFile
+	Downloads map[string]downloadProgress
+	FileTags  map[string]map[string]string
+	FileKeys  map[string]string
+
+	FileChecksums map[string]string
+	ChunkHashes   map[string][]crypto.Hash
+	FileRetention map[string]types.Timestamp
+}
+
+// save stores the current renter data and transfer history to disk.
 func (r *Renter) save() error {
-	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
-	return persist.SaveFile(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
+	data := persistData{r.tracking, r.downloads, r.fileTags, r.fileKeys, r.fileChecksums, r.chunkHashes, r.fileRetention}
+	err := persist.SaveFileBackups(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename), persist.DefaultBackups)
+	if err != nil {
+		return err
+	}
+	return r.history.save(r.persistDir)
 }
 
-// saveSync stores the current renter data to disk and then syncs to disk.
+// saveSync stores the current renter data to disk and then syncs to disk. The
+// transfer history is saved as well, but is not synced.
 func (r *Renter) saveSync() error {
-	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
-	return persist.SaveFileSync(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
+	data := persistData{r.tracking, r.downloads, r.fileTags, r.fileKeys, r.fileChecksums, r.chunkHashes, r.fileRetention}
+	err := persist.SaveFileBackupsSync(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename), persist.DefaultBackups)
+	if err != nil {
+		return err
+	}
+	return r.history.save(r.persistDir)
 }
 
 // load fetches the saved renter data from disk.
@@ -233,15 +262,43 @@ func (r *Renter) load() error {
 	// Load contracts, repair set, and entropy.
 	data := struct {
 		Tracking  map[string]trackedFile
+		Downloads map[string]downloadProgress
+		FileTags  map[string]map[string]string
+		FileKeys  map[string]string
 		Repairing map[string]string // COMPATv0.4.8
+
+		FileChecksums map[string]string
+		ChunkHashes   map[string][]crypto.Hash
+		FileRetention map[string]types.Timestamp
 	}{}
-	err = persist.LoadFile(saveMetadata, &data, filepath.Join(r.persistDir, PersistFilename))
+	err = persist.LoadFileBackups(saveMetadata, &data, filepath.Join(r.persistDir, PersistFilename))
 	if err != nil {
 		return err
 	}
 	if data.Tracking != nil {
 		r.tracking = data.Tracking
 	}
+	if data.Downloads != nil {
+		r.downloads = data.Downloads
+	}
+	if data.FileTags != nil {
+		r.fileTags = data.FileTags
+	}
+	if data.FileKeys != nil {
+		r.fileKeys = data.FileKeys
+	}
+	if data.FileChecksums != nil {
+		r.fileChecksums = data.FileChecksums
+	}
+	if data.ChunkHashes != nil {
+		r.chunkHashes = data.ChunkHashes
+		for name := range r.chunkHashes {
+			r.indexFileChunks(name)
+		}
+	}
+	if data.FileRetention != nil {
+		r.fileRetention = data.FileRetention
+	}
 
 	return nil
 }
@@ -249,10 +306,20 @@ func (r *Renter) load() error {
 // shareFiles writes the specified files to w. First a header is written,
 // followed by the gzipped concatenation of each file.
 func shareFiles(files []*file, w io.Writer) error {
+	version := shareVersion
+	for _, f := range files {
+		if f.cipher() != crypto.CipherTwofish && version == shareVersion {
+			version = shareVersionCipher
+		}
+		if f.preEncrypted {
+			version = shareVersionEncryption
+		}
+	}
+
 	// Write header.
 	err := encoding.NewEncoder(w).EncodeAll(
 		shareHeader,
-		shareVersion,
+		version,
 		uint64(len(files)),
 	)
 	if err != nil {
@@ -269,6 +336,18 @@ func shareFiles(files []*file, w io.Writer) error {
 		if err != nil {
 			return err
 		}
+		if version == shareVersionCipher || version == shareVersionEncryption {
+			err = enc.Encode(string(f.cipher()))
+			if err != nil {
+				return err
+			}
+		}
+		if version == shareVersionEncryption {
+			err = enc.Encode(f.preEncrypted)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return zip.Close()
@@ -349,7 +428,7 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 		return nil, err
 	} else if header != shareHeader {
 		return nil, ErrBadFile
-	} else if version != shareVersion {
+	} else if version != shareVersion && version != shareVersionCipher && version != shareVersionEncryption {
 		return nil, ErrIncompatible
 	}
 
@@ -368,6 +447,29 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
+		files[i].cipherType = crypto.CipherTwofish
+		if version == shareVersionCipher || version == shareVersionEncryption {
+			var cipherType string
+			if err := dec.Decode(&cipherType); err != nil {
+				return nil, err
+			}
+			files[i].cipherType = crypto.CipherType(cipherType)
+			if !files[i].cipherType.Valid() {
+				return nil, crypto.ErrUnknownCipher
+			}
+		}
+		if version == shareVersionEncryption {
+			if err := dec.Decode(&files[i].preEncrypted); err != nil {
+				return nil, err
+			}
+		} else {
+			// COMPATv1.0.4 - older .sia files do not record whether the
+			// data was encrypted before it was uploaded. Such files could not
+			// have a custom chunk size, so their pieces fill an entire
+			// sector, while the pieces of files that the renter encrypts
+			// always leave room for the encryption overhead.
+			files[i].preEncrypted = files[i].pieceSize == modules.SectorSize
+		}
 
 		// Make sure the file's name does not conflict with existing files.
 		dupCount := 0
@@ -416,6 +518,12 @@ func (r *Renter) initPersist() error {
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+
+	// Load the transfer history, which is saved in its own file.
+	err = r.history.load(r.persistDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
 )
 
 // newTestingFile initializes a file object with random parameters.
@@ -47,6 +48,12 @@ func equalFiles(f1, f2 *file) error {
 	if f1.pieceSize != f2.pieceSize {
 		return fmt.Errorf("pieceSizes do not match: %v %v", f1.pieceSize, f2.pieceSize)
 	}
+	if f1.cipher() != f2.cipher() {
+		return fmt.Errorf("ciphers do not match: %v %v", f1.cipher(), f2.cipher())
+	}
+	if f1.preEncrypted != f2.preEncrypted {
+		return fmt.Errorf("encryption does not match: %v %v", f1.preEncrypted, f2.preEncrypted)
+	}
 	return nil
 }
 
@@ -137,6 +144,126 @@ func TestFileShareLoad(t *testing.T) {
 	}
 }
 
+// TestFileShareLoadCipher checks that the cipher of a file is recorded in .sia
+// files, and that .sia files of Twofish files can still be read by older
+// clients.
+func TestFileShareLoadCipher(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestFileShareLoadCipher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	twofishFile := newTestingFile()
+	aesFile := newTestingFile()
+	aesFile.name = twofishFile.name + "-aes"
+	aesFile.cipherType = crypto.CipherAES
+	rt.renter.files[twofishFile.name] = twofishFile
+	rt.renter.files[aesFile.name] = aesFile
+
+	// Files that all use Twofish are shared in the old format.
+	buf := new(bytes.Buffer)
+	err = shareFiles([]*file{twofishFile}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header [15]byte
+	var version string
+	err = encoding.NewDecoder(bytes.NewReader(buf.Bytes())).DecodeAll(&header, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != shareVersion {
+		t.Fatal("expected version", shareVersion, "got", version)
+	}
+
+	// Share both files, and load them back.
+	buf.Reset()
+	err = shareFiles([]*file{twofishFile, aesFile}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = encoding.NewDecoder(bytes.NewReader(buf.Bytes())).DecodeAll(&header, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != shareVersionCipher {
+		t.Fatal("expected version", shareVersionCipher, "got", version)
+	}
+	delete(rt.renter.files, twofishFile.name)
+	delete(rt.renter.files, aesFile.name)
+	names, err := rt.renter.loadSharedFiles(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatal("files not loaded properly:", names)
+	}
+	if err := equalFiles(rt.renter.files[twofishFile.name], twofishFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := equalFiles(rt.renter.files[aesFile.name], aesFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFileShareLoadPreEncrypted checks that .sia files record whether a file
+// was encrypted before it was uploaded, independent of its piece size.
+func TestFileShareLoadPreEncrypted(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestFileShareLoadPreEncrypted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// A pre-encrypted file with a custom chunk size is shared in the new
+	// format.
+	f := newTestingFile()
+	f.pieceSize = 4096
+	f.preEncrypted = true
+	buf := new(bytes.Buffer)
+	if err := shareFiles([]*file{f}, buf); err != nil {
+		t.Fatal(err)
+	}
+	var header [15]byte
+	var version string
+	err = encoding.NewDecoder(bytes.NewReader(buf.Bytes())).DecodeAll(&header, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != shareVersionEncryption {
+		t.Fatal("expected version", shareVersionEncryption, "got", version)
+	}
+	if _, err := rt.renter.loadSharedFiles(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := equalFiles(rt.renter.files[f.name], f); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files in the old format are pre-encrypted if their pieces fill a
+	// sector.
+	old := newTestingFile()
+	old.name = f.name + "-old"
+	old.pieceSize = modules.SectorSize
+	buf.Reset()
+	if err := shareFiles([]*file{old}, buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.renter.loadSharedFiles(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.renter.files[old.name].preEncrypted {
+		t.Fatal("old pre-encrypted file was not recognized")
+	}
+}
+
 // TestFileShareLoadASCII tests the ASCII sharing/loading functions.
 func TestFileShareLoadASCII(t *testing.T) {
 	if testing.Short() {
@@ -1,68 +1,152 @@
 package renter
 
 import (
-	"github.com/NebulousLabs/Sia/build"
+	"sync"
+
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 )
 
+// A poolHost is a host in a hostPool. The renter may have several contracts
+// with a host, each of which is revised over its own Editor. Uploads to the
+// host are spread across the Editors, so that they can proceed in parallel
+// instead of serializing on a single contract.
+type poolHost struct {
+	address modules.NetAddress
+	editors []contractor.Editor
+	busy    []int // number of uploads in progress on each editor
+}
+
+// idleEditor returns the index of the editor with the fewest uploads in
+// progress.
+func (ph *poolHost) idleEditor() int {
+	best := 0
+	for i := range ph.editors {
+		if ph.busy[i] < ph.busy[best] {
+			best = i
+		}
+	}
+	return best
+}
+
 // A hostPool is a collection of active host connections, in the form of
 // Editors. The renter uses a hostPool to prevent connecting to the same host
-// more than once. This is more efficient, and also makes it easier to
-// serialize contract revisions.
+// more than once per contract. This is more efficient, and also makes it
+// easier to serialize contract revisions. hostPools are safe for use by
+// multiple goroutines.
 type hostPool struct {
-	hosts          []contractor.Editor
+	hosts          []*poolHost
 	blacklist      []modules.NetAddress
 	hostContractor hostContractor
 	hdb            hostDB
+	mu             sync.Mutex
 }
 
 // Close closes all of the hostPool's open host connections.
 func (p *hostPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	for _, h := range p.hosts {
-		h.Close()
+		for _, e := range h.editors {
+			e.Close()
+		}
 	}
 	return nil
 }
 
-// add adds a contract's host to the hostPool and returns it as an Editor.
-func (p *hostPool) add(contract modules.RenterContract) (contractor.Editor, error) {
-	for _, h := range p.hosts {
-		if h.Address() == contract.NetAddress {
-			return h, nil
+// add opens an Editor for each of the renter's contracts with a host, and
+// adds the host to the hostPool. If no Editor could be opened, the host is
+// blacklisted and nil is returned.
+func (p *hostPool) add(addr modules.NetAddress, contracts []modules.RenterContract) *poolHost {
+	ph := &poolHost{address: addr}
+	for _, contract := range contracts {
+		e, err := p.hostContractor.Editor(contract.ID)
+		if err != nil {
+			continue
 		}
+		ph.editors = append(ph.editors, e)
+		ph.busy = append(ph.busy, 0)
 	}
-	hu, err := p.hostContractor.Editor(contract.ID)
-	if err != nil {
-		p.blacklist = append(p.blacklist, contract.NetAddress)
-		return nil, err
+	if len(ph.editors) == 0 {
+		p.blacklist = append(p.blacklist, addr)
+		return nil
 	}
-	p.hosts = append(p.hosts, hu)
-	return hu, nil
+	p.hosts = append(p.hosts, ph)
+	return ph
 }
 
-// remove disconnects from a host and adds it to the blacklist.
+// remove disconnects from a host and adds it to the blacklist. Since uploads
+// run in parallel, several of them may fail on the same host, so removing a
+// host that has already been removed is a no-op.
 func (p *hostPool) remove(addr modules.NetAddress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	for i, h := range p.hosts {
-		if h.Address() == addr {
-			h.Close()
+		if h.address == addr {
+			for _, e := range h.editors {
+				e.Close()
+			}
 			p.hosts = append(p.hosts[:i], p.hosts[i+1:]...)
 			p.blacklist = append(p.blacklist, addr)
 			return
 		}
 	}
-	build.Critical("could not remove host from pool: no record of host", addr)
 }
 
-// uniqueHosts will return up to 'n' unique hosts that are not in 'exclude'.
-// The pool draws from its set of active connections first, and then negotiates
-// new contracts if more hosts are required. Note that this latter case
-// requires network I/O, so the caller should always assume that uniqueHosts
-// will block.
+// sessions returns the largest number of Editors that the pool holds with a
+// single host, which is the number of uploads that can be sent to each host
+// in parallel without sharing a contract.
+func (p *hostPool) sessions() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 1
+	for _, h := range p.hosts {
+		if len(h.editors) > n {
+			n = len(h.editors)
+		}
+	}
+	return n
+}
+
+// acquire marks the least busy editor of a host as busy and returns it.
+func (p *hostPool) acquire(ph *poolHost) contractor.Editor {
+	i := ph.idleEditor()
+	ph.busy[i]++
+	return ph.editors[i]
+}
+
+// release marks the provided Editors, which must have been returned by
+// uniqueHosts, as no longer busy.
+func (p *hostPool) release(editors []contractor.Editor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+outer:
+	for _, e := range editors {
+		for _, h := range p.hosts {
+			for i := range h.editors {
+				if h.editors[i] == e && h.busy[i] > 0 {
+					h.busy[i]--
+					continue outer
+				}
+			}
+		}
+	}
+}
+
+// uniqueHosts will return up to 'n' Editors for unique hosts that are not in
+// 'exclude'. For each host, the Editor with the fewest uploads in progress is
+// chosen, and hosts that have an idle Editor are preferred. The pool draws
+// from its set of active connections first, and then opens connections to new
+// hosts if more hosts are required. Note that this latter case requires
+// network I/O, so the caller should always assume that uniqueHosts will
+// block. The Editors must be returned to the pool with release once the
+// caller is done with them.
 func (p *hostPool) uniqueHosts(n int, exclude []modules.NetAddress) (hosts []contractor.Editor) {
 	if n == 0 {
 		return
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
 	// convert slice to map for easier lookups
 	excludeSet := make(map[modules.NetAddress]struct{})
@@ -70,12 +154,17 @@ func (p *hostPool) uniqueHosts(n int, exclude []modules.NetAddress) (hosts []con
 		excludeSet[ip] = struct{}{}
 	}
 
-	// First reuse existing connections.
+	// First reuse existing connections that are idle.
+	var busy []*poolHost
 	for _, h := range p.hosts {
-		if _, ok := excludeSet[h.Address()]; ok {
+		if _, ok := excludeSet[h.address]; ok {
+			continue
+		}
+		if h.busy[h.idleEditor()] > 0 {
+			busy = append(busy, h)
 			continue
 		}
-		hosts = append(hosts, h)
+		hosts = append(hosts, p.acquire(h))
 		if len(hosts) >= n {
 			return hosts
 		}
@@ -87,25 +176,39 @@ func (p *hostPool) uniqueHosts(n int, exclude []modules.NetAddress) (hosts []con
 		excludeSet[ip] = struct{}{}
 	}
 	for _, h := range p.hosts {
-		excludeSet[h.Address()] = struct{}{}
+		excludeSet[h.address] = struct{}{}
 	}
 
-	// Next try to reuse existing contracts.
+	// Next try to reuse existing contracts, grouping them by host.
+	var addrs []modules.NetAddress
+	hostContracts := make(map[modules.NetAddress][]modules.RenterContract)
 	for _, contract := range p.hostContractor.Contracts() {
 		if _, ok := excludeSet[contract.NetAddress]; ok {
 			continue
 		}
-		hu, err := p.add(contract)
-		if err != nil {
+		if _, ok := hostContracts[contract.NetAddress]; !ok {
+			addrs = append(addrs, contract.NetAddress)
+		}
+		hostContracts[contract.NetAddress] = append(hostContracts[contract.NetAddress], contract)
+	}
+	for _, addr := range addrs {
+		if len(hosts) >= n {
+			return hosts
+		}
+		h := p.add(addr, hostContracts[addr])
+		if h == nil {
 			continue
 		}
-		hosts = append(hosts, hu)
-		excludeSet[hu.Address()] = struct{}{}
+		hosts = append(hosts, p.acquire(h))
+	}
+
+	// Finally, share busy connections.
+	for _, h := range busy {
 		if len(hosts) >= n {
 			break
 		}
+		hosts = append(hosts, p.acquire(h))
 	}
-
 	return hosts
 }
 
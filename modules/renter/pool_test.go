@@ -0,0 +1,87 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// poolContractor is a hostContractor that has several contracts with each of
+// its hosts, and returns a distinct Editor for each contract.
+type poolContractor struct {
+	stubContractor
+	contracts []modules.RenterContract
+}
+
+func (pc *poolContractor) Contracts() []modules.RenterContract { return pc.contracts }
+
+func (pc *poolContractor) Editor(id types.FileContractID) (contractor.Editor, error) {
+	for _, c := range pc.contracts {
+		if c.ID == id {
+			return &testHost{ip: c.NetAddress}, nil
+		}
+	}
+	return nil, nil
+}
+
+// TestHostPoolSessions checks that the hostPool spreads uploads across the
+// contracts that the renter has with each host.
+func TestHostPoolSessions(t *testing.T) {
+	pc := new(poolContractor)
+	for i, addr := range []modules.NetAddress{"foo", "bar"} {
+		for j := 0; j < 3; j++ {
+			pc.contracts = append(pc.contracts, modules.RenterContract{
+				ID:         types.FileContractID{byte(i), byte(j)},
+				NetAddress: addr,
+			})
+		}
+	}
+	pool := &hostPool{hostContractor: pc}
+	defer pool.Close()
+
+	// Each call should return one Editor per host, using a different
+	// contract until all of them are in use.
+	used := make(map[contractor.Editor]bool)
+	var acquired [][]contractor.Editor
+	for i := 0; i < 3; i++ {
+		hosts := pool.uniqueHosts(2, nil)
+		if len(hosts) != 2 || hosts[0].Address() == hosts[1].Address() {
+			t.Fatal("expected 2 unique hosts, got", len(hosts))
+		}
+		for _, h := range hosts {
+			if used[h] {
+				t.Fatal("editor was reused while other editors were idle")
+			}
+			used[h] = true
+		}
+		acquired = append(acquired, hosts)
+	}
+	if pool.sessions() != 3 {
+		t.Fatal("expected 3 sessions per host, got", pool.sessions())
+	}
+
+	// With every Editor in use, the busy Editors are shared.
+	hosts := pool.uniqueHosts(2, nil)
+	if len(hosts) != 2 {
+		t.Fatal("expected busy editors to be shared, got", len(hosts))
+	}
+	pool.release(hosts)
+
+	// Releasing Editors makes them preferred again.
+	pool.release(acquired[1])
+	hosts = pool.uniqueHosts(1, []modules.NetAddress{"bar"})
+	if len(hosts) != 1 || hosts[0] != acquired[1][0] {
+		t.Fatal("expected the released editor to be reused")
+	}
+
+	// Removed hosts are no longer returned, and removing them again is a
+	// no-op.
+	pool.remove("foo")
+	pool.remove("foo")
+	hosts = pool.uniqueHosts(2, nil)
+	if len(hosts) != 1 || hosts[0].Address() != "bar" {
+		t.Fatal("expected only the remaining host to be returned")
+	}
+}
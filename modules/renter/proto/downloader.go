@@ -25,25 +25,35 @@ type Downloader struct {
 }
 
 // Sector retrieves the sector with the specified Merkle root, and revises
-// the underlying contract to pay the host proportionally to the data
-// retrieve.
+// the underlying contract to pay the host for the bytes it serves. The host is
+// paid at the price in the settings it sends for this request, which may not
+// exceed the price it was chosen at. The host stores the payment revision
+// before it sends the data.
+//
+// The host charges for the bytes requested by each download action, but
+// Sector always requests the whole sector: the download RPC does not send
+// Merkle proofs, so a partial sector could not be checked against its root.
+// The payment is therefore the price of SectorSize bytes.
 func (hd *Downloader) Sector(root crypto.Hash) (modules.RenterContract, []byte, error) {
 	extendDeadline(hd.conn, modules.NegotiateDownloadTime)
 	defer extendDeadline(hd.conn, time.Hour) // reset deadline when finished
 
-	// calculate price
-	sectorPrice := hd.host.DownloadBandwidthPrice.Mul64(modules.SectorSize)
-	if hd.contract.RenterFunds().Cmp(sectorPrice) < 0 {
+	// check that the contract can pay for the sector at the known price
+	if hd.contract.RenterFunds().Cmp(hd.host.DownloadBandwidthPrice.Mul64(modules.SectorSize)) < 0 {
 		return modules.RenterContract{}, nil, errors.New("contract has insufficient funds to support download")
 	}
 
-	// create the download revision
-	rev := newDownloadRevision(hd.contract.LastRevision, sectorPrice)
-
 	// initiate download by confirming host settings
-	if err := startDownload(hd.conn, hd.host); err != nil {
+	recvHost, err := startDownload(hd.conn, hd.host)
+	if err != nil {
 		return modules.RenterContract{}, nil, err
 	}
+	hd.contract.HostVersion = recvHost.Version
+
+	// create the download revision, paying for the bytes of the sector at
+	// the price the host just quoted
+	sectorPrice := recvHost.DownloadBandwidthPrice.Mul64(modules.SectorSize)
+	rev := newDownloadRevision(hd.contract.LastRevision, sectorPrice)
 
 	// Before we continue, save the revision. Unexpected termination (e.g.
 	// power failure) during the signature transfer leaves in an ambiguous
@@ -57,7 +67,7 @@ func (hd *Downloader) Sector(root crypto.Hash) (modules.RenterContract, []byte,
 	}
 
 	// send download action
-	err := encoding.WriteObject(hd.conn, []modules.DownloadAction{{
+	err = encoding.WriteObject(hd.conn, []modules.DownloadAction{{
 		MerkleRoot: root,
 		Offset:     0,
 		Length:     modules.SectorSize,
@@ -79,7 +89,7 @@ func (hd *Downloader) Sector(root crypto.Hash) (modules.RenterContract, []byte,
 
 	// read sector data, completing one iteration of the download loop
 	var sectors [][]byte
-	if err := encoding.ReadObject(hd.conn, &sectors, modules.SectorSize+16); err != nil {
+	if err := encoding.ReadObjectStream(hd.conn, &sectors, modules.SectorSize+16, modules.SectorSize); err != nil {
 		return modules.RenterContract{}, nil, err
 	} else if len(sectors) != 1 {
 		return modules.RenterContract{}, nil, errors.New("host did not send enough sectors")
@@ -122,7 +132,7 @@ func NewDownloader(host modules.HostDBEntry, contract modules.RenterContract) (*
 	}
 
 	// initiate download loop
-	conn, err := net.DialTimeout("tcp", string(contract.NetAddress), 15*time.Second)
+	conn, err := modules.Dial(&net.Dialer{Timeout: 15 * time.Second}, contract.NetAddress)
 	if err != nil {
 		return nil, err
 	}
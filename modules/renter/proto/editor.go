@@ -5,34 +5,12 @@ import (
 	"net"
 	"time"
 
-	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
-var (
-	// sectorHeight is the height of a Merkle tree that covers a single
-	// sector. It is log2(modules.SectorSize / crypto.SegmentSize)
-	sectorHeight = func() uint64 {
-		height := uint64(0)
-		for 1<<height < (modules.SectorSize / crypto.SegmentSize) {
-			height++
-		}
-		return height
-	}()
-)
-
-// cachedMerkleRoot calculates the root of a set of existing Merkle roots.
-func cachedMerkleRoot(roots []crypto.Hash) crypto.Hash {
-	tree := crypto.NewCachedTree(sectorHeight) // NOTE: height is not strictly necessary here
-	for _, h := range roots {
-		tree.Push(h)
-	}
-	return tree.Root()
-}
-
 // A Editor modifies a Contract by calling the revise RPC on a host. It
 // Editors are NOT thread-safe; calls to Upload must happen in serial.
 type Editor struct {
@@ -41,6 +19,7 @@ type Editor struct {
 
 	height   types.BlockHeight
 	contract modules.RenterContract // updated after each revision
+	tree     *crypto.SectorTree     // tree of contract.MerkleRoots
 
 	SaveFn revisionSaver
 
@@ -61,12 +40,17 @@ func (he *Editor) Close() error {
 
 // runRevisionIteration submits actions and their accompanying revision to the
 // host for approval. If negotiation is successful, it updates the underlying
-// Contract.
-func (he *Editor) runRevisionIteration(actions []modules.RevisionAction, rev types.FileContractRevision, newRoots []crypto.Hash) error {
+// Contract. sectors contains the data of each ActionInsertResumable in
+// actions, in order.
+func (he *Editor) runRevisionIteration(actions []modules.RevisionAction, sectors [][]byte, rev types.FileContractRevision, newRoots []crypto.Hash) error {
 	// initiate revision
-	if err := startRevision(he.conn, he.host); err != nil {
+	recvHost, err := startRevision(he.conn, he.host)
+	if err != nil {
 		return err
 	}
+	// record the host's version, so that the contract reflects any upgrades
+	// the host has made since the contract was formed
+	he.contract.HostVersion = recvHost.Version
 
 	// Before we continue, save the revision. Unexpected termination (e.g.
 	// power failure) during the signature transfer leaves in an ambiguous
@@ -83,6 +67,11 @@ func (he *Editor) runRevisionIteration(actions []modules.RevisionAction, rev typ
 	if err := encoding.WriteObject(he.conn, actions); err != nil {
 		return err
 	}
+	for _, sector := range sectors {
+		if err := sendResumableSector(he.conn, sector); err != nil {
+			return err
+		}
+	}
 
 	// send revision to host and exchange signatures
 	signedTxn, err := negotiateRevision(he.conn, rev, he.contract.SecretKey)
@@ -104,6 +93,25 @@ func (he *Editor) runRevisionIteration(actions []modules.RevisionAction, rev typ
 
 // Upload negotiates a revision that adds a sector to a file contract.
 func (he *Editor) Upload(data []byte) (modules.RenterContract, crypto.Hash, error) {
+	sectorRoot := crypto.MerkleRoot(data)
+	contract, err := he.insert(sectorRoot, data)
+	if err != nil {
+		return modules.RenterContract{}, crypto.Hash{}, err
+	}
+	return contract, sectorRoot, nil
+}
+
+// UploadRoot negotiates a revision that adds a sector that the host is
+// already storing to a file contract. Only the Merkle root of the sector is
+// sent, so the renter does not pay for upload bandwidth. The host must
+// support modules.HostFeatureSectorDedup.
+func (he *Editor) UploadRoot(sectorRoot crypto.Hash) (modules.RenterContract, error) {
+	return he.insert(sectorRoot, nil)
+}
+
+// insert negotiates a revision that adds a sector to a file contract. If data
+// is nil, the host is asked to insert a sector that it is already storing.
+func (he *Editor) insert(sectorRoot crypto.Hash, data []byte) (modules.RenterContract, error) {
 	// allot 10 minutes for this exchange; sufficient to transfer 4 MB over 50 kbps
 	extendDeadline(he.conn, modules.NegotiateFileContractRevisionTime)
 	defer extendDeadline(he.conn, time.Hour) // reset deadline
@@ -112,46 +120,59 @@ func (he *Editor) Upload(data []byte) (modules.RenterContract, crypto.Hash, erro
 	// TODO: height is never updated, so we'll wind up overpaying on long-running uploads
 	blockBytes := types.NewCurrency64(modules.SectorSize * uint64(he.contract.FileContract.WindowEnd-he.height))
 	sectorStoragePrice := he.host.StoragePrice.Mul(blockBytes)
-	sectorBandwidthPrice := he.host.UploadBandwidthPrice.Mul64(modules.SectorSize)
+	sectorBandwidthPrice := types.ZeroCurrency
+	if data != nil {
+		sectorBandwidthPrice = he.host.UploadBandwidthPrice.Mul64(modules.SectorSize)
+	}
 	sectorPrice := sectorStoragePrice.Add(sectorBandwidthPrice)
 	if he.contract.RenterFunds().Cmp(sectorPrice) < 0 {
-		return modules.RenterContract{}, crypto.Hash{}, errors.New("contract has insufficient funds to support upload")
+		return modules.RenterContract{}, errors.New("contract has insufficient funds to support upload")
 	}
 	sectorCollateral := he.host.Collateral.Mul(blockBytes)
 	if he.contract.LastRevision.NewMissedProofOutputs[1].Value.Cmp(sectorCollateral) < 0 {
-		return modules.RenterContract{}, crypto.Hash{}, errors.New("contract has insufficient collateral to support upload")
+		return modules.RenterContract{}, errors.New("contract has insufficient collateral to support upload")
 	}
 	// to mitigate small errors (e.g. differing block heights), fudge the
-	// price and collateral by 0.2%. This is only applied to hosts above
-	// v1.0.1; older hosts use stricter math.
-	if build.VersionCmp(he.host.Version, "1.0.1") > 0 {
+	// price and collateral by 0.2%. This is only applied to hosts that
+	// support it; older hosts use stricter math.
+	if he.contract.SupportsFeature(modules.HostFeaturePriceTolerance) {
 		sectorPrice = sectorPrice.MulFloat(1.002)
 		sectorCollateral = sectorCollateral.MulFloat(0.998)
 	}
 
 	// calculate the new Merkle root
-	sectorRoot := crypto.MerkleRoot(data)
 	newRoots := append(he.contract.MerkleRoots, sectorRoot)
-	merkleRoot := cachedMerkleRoot(newRoots)
+	he.tree.Append(sectorRoot)
+	merkleRoot := he.tree.Root()
 
 	// create the action and revision
-	actions := []modules.RevisionAction{{
+	action := modules.RevisionAction{
 		Type:        modules.ActionInsert,
 		SectorIndex: uint64(len(he.contract.MerkleRoots)),
 		Data:        data,
-	}}
+	}
+	var sectors [][]byte
+	if data == nil {
+		action.Type = modules.ActionInsertRoot
+		action.Data = sectorRoot[:]
+	} else if he.contract.SupportsFeature(modules.HostFeatureResumableUpload) {
+		action.Type = modules.ActionInsertResumable
+		action.Data = sectorRoot[:]
+		sectors = [][]byte{data}
+	}
 	rev := newUploadRevision(he.contract.LastRevision, merkleRoot, sectorPrice, sectorCollateral)
 
 	// run the revision iteration
-	if err := he.runRevisionIteration(actions, rev, newRoots); err != nil {
-		return modules.RenterContract{}, crypto.Hash{}, err
+	if err := he.runRevisionIteration([]modules.RevisionAction{action}, sectors, rev, newRoots); err != nil {
+		he.tree.Truncate(uint64(len(he.contract.MerkleRoots)))
+		return modules.RenterContract{}, err
 	}
 
 	// update metrics
 	he.StorageSpending = he.StorageSpending.Add(sectorStoragePrice)
 	he.UploadSpending = he.UploadSpending.Add(sectorBandwidthPrice)
 
-	return he.contract, sectorRoot, nil
+	return he.contract, nil
 }
 
 // Delete negotiates a revision that removes a sector from a file contract.
@@ -173,7 +194,9 @@ func (he *Editor) Delete(root crypto.Hash) (modules.RenterContract, error) {
 	if index == -1 {
 		return modules.RenterContract{}, errors.New("no record of that sector root")
 	}
-	merkleRoot := cachedMerkleRoot(newRoots)
+	// removing a sector moves every sector after it, so the tree is rebuilt
+	tree := crypto.NewSectorTree(newRoots)
+	merkleRoot := tree.Root()
 
 	// create the action and accompanying revision
 	actions := []modules.RevisionAction{{
@@ -183,9 +206,10 @@ func (he *Editor) Delete(root crypto.Hash) (modules.RenterContract, error) {
 	rev := newDeleteRevision(he.contract.LastRevision, merkleRoot)
 
 	// run the revision iteration
-	if err := he.runRevisionIteration(actions, rev, newRoots); err != nil {
+	if err := he.runRevisionIteration(actions, nil, rev, newRoots); err != nil {
 		return modules.RenterContract{}, err
 	}
+	he.tree = tree
 	return he.contract, nil
 }
 
@@ -208,6 +232,7 @@ func (he *Editor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newData []
 		if h == oldRoot {
 			index = i
 			newRoots[i] = newRoot
+			he.tree.SetRoot(uint64(i), newRoot)
 		} else {
 			newRoots[i] = h
 		}
@@ -215,7 +240,7 @@ func (he *Editor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newData []
 	if index == -1 {
 		return modules.RenterContract{}, errors.New("no record of that sector root")
 	}
-	merkleRoot := cachedMerkleRoot(newRoots)
+	merkleRoot := he.tree.Root()
 
 	// create the action and revision
 	actions := []modules.RevisionAction{{
@@ -227,7 +252,8 @@ func (he *Editor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newData []
 	rev := newModifyRevision(he.contract.LastRevision, merkleRoot, sectorBandwidthPrice)
 
 	// run the revision iteration
-	if err := he.runRevisionIteration(actions, rev, newRoots); err != nil {
+	if err := he.runRevisionIteration(actions, nil, rev, newRoots); err != nil {
+		he.tree = crypto.NewSectorTree(he.contract.MerkleRoots)
 		return modules.RenterContract{}, err
 	}
 
@@ -237,16 +263,24 @@ func (he *Editor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newData []
 	return he.contract, nil
 }
 
-// NewEditor initiates the contract revision process with a host, and returns
-// an Editor.
-func NewEditor(host modules.HostDBEntry, contract modules.RenterContract, currentHeight types.BlockHeight) (*Editor, error) {
-	// check that contract has enough value to support an upload
-	if len(contract.LastRevision.NewValidProofOutputs) != 2 {
-		return nil, errors.New("invalid contract")
+// Reconnect closes the Editor's connection and initiates the contract
+// revision process with the host again. After a sector transfer is
+// interrupted, uploading the same sector on the new connection resumes the
+// transfer.
+func (he *Editor) Reconnect() error {
+	conn, err := initiateRevisionLoop(he.contract)
+	if err != nil {
+		return err
 	}
+	he.conn.Close()
+	he.conn = conn
+	return nil
+}
 
-	// initiate revision loop
-	conn, err := net.DialTimeout("tcp", string(contract.NetAddress), 15*time.Second)
+// initiateRevisionLoop dials the host of a contract and calls the revise RPC,
+// returning the connection once the host is ready to accept revisions.
+func initiateRevisionLoop(contract modules.RenterContract) (net.Conn, error) {
+	conn, err := modules.Dial(&net.Dialer{Timeout: 15 * time.Second}, contract.NetAddress)
 	if err != nil {
 		return nil, err
 	}
@@ -261,12 +295,29 @@ func NewEditor(host modules.HostDBEntry, contract modules.RenterContract, curren
 		conn.Close() // TODO: close gracefully if host has entered revision loop
 		return nil, err
 	}
+	return conn, nil
+}
+
+// NewEditor initiates the contract revision process with a host, and returns
+// an Editor.
+func NewEditor(host modules.HostDBEntry, contract modules.RenterContract, currentHeight types.BlockHeight) (*Editor, error) {
+	// check that contract has enough value to support an upload
+	if len(contract.LastRevision.NewValidProofOutputs) != 2 {
+		return nil, errors.New("invalid contract")
+	}
+
+	// initiate revision loop
+	conn, err := initiateRevisionLoop(contract)
+	if err != nil {
+		return nil, err
+	}
 
 	// the host is now ready to accept revisions
 	return &Editor{
 		host:     host,
 		height:   currentHeight,
 		contract: contract,
+		tree:     crypto.NewSectorTree(contract.MerkleRoots),
 		conn:     conn,
 	}, nil
 }
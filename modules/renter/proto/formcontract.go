@@ -3,6 +3,7 @@ package proto
 import (
 	"errors"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -15,18 +16,91 @@ const (
 	// estTxnSize is the estimated size of an encoded file contract
 	// transaction set.
 	estTxnSize = 2048
+
+	// defaultDialTimeout is how long the renter waits to connect to a host.
+	defaultDialTimeout = 15 * time.Second
 )
 
+// ErrFormCancelled is returned by FormContract if the negotiation was
+// abandoned by closing the Cancel channel of its params.
+var ErrFormCancelled = errors.New("contract negotiation was abandoned")
+
+// A formCanceler closes the connection of a negotiation when its cancel
+// channel is closed, until the negotiation is committed. Once the renter has
+// sent its signatures, the host can submit the contract, so the negotiation
+// must be finished for the renter to learn about it.
+type formCanceler struct {
+	conn      net.Conn
+	cancelled bool
+	committed bool
+	done      chan struct{}
+	mu        sync.Mutex
+}
+
+// newFormCanceler returns a formCanceler that watches cancel, which may be
+// nil.
+func newFormCanceler(conn net.Conn, cancel <-chan struct{}) *formCanceler {
+	fc := &formCanceler{
+		conn: conn,
+		done: make(chan struct{}),
+	}
+	if cancel != nil {
+		go func() {
+			select {
+			case <-cancel:
+			case <-fc.done:
+				return
+			}
+			fc.mu.Lock()
+			defer fc.mu.Unlock()
+			if !fc.committed {
+				fc.cancelled = true
+				_ = fc.conn.Close()
+			}
+		}()
+	}
+	return fc
+}
+
+// commit prevents the negotiation from being cancelled. It returns
+// ErrFormCancelled if the negotiation was already cancelled.
+func (fc *formCanceler) commit() error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.cancelled {
+		return ErrFormCancelled
+	}
+	fc.committed = true
+	return nil
+}
+
+// stop stops watching the cancel channel. If the negotiation was cancelled,
+// ErrFormCancelled is returned in place of err, which is then the result of
+// the closed connection.
+func (fc *formCanceler) stop(err error) error {
+	close(fc.done)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.cancelled && err != nil {
+		return ErrFormCancelled
+	}
+	return err
+}
+
 // FormContract forms a contract with a host and submits the contract
 // transaction to tpool.
-func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool transactionPool) (modules.RenterContract, error) {
+func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool transactionPool) (_ modules.RenterContract, err error) {
 	// extract vars from params, for convenience
 	host, filesize, startHeight, endHeight, refundAddress := params.Host, params.Filesize, params.StartHeight, params.EndHeight, params.RefundAddress
 
-	// create our key
-	ourSK, ourPK, err := crypto.GenerateKeyPair()
-	if err != nil {
-		return modules.RenterContract{}, err
+	// use the supplied key, or create one if none was supplied
+	ourSK, ourPK := params.SecretKey, params.SecretKey.PublicKey()
+	if ourSK == (crypto.SecretKey{}) {
+		var err error
+		ourSK, ourPK, err = crypto.GenerateKeyPair()
+		if err != nil {
+			return modules.RenterContract{}, err
+		}
 	}
 	ourPublicKey := types.SiaPublicKey{
 		Algorithm: types.SignatureEd25519,
@@ -100,14 +174,17 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	txnSet := append(parentTxns, txn)
 
 	// initiate connection
-	conn, err := net.DialTimeout("tcp", string(host.NetAddress), 15*time.Second)
+	timeouts := params.Timeouts.withDefaults()
+	conn, err := modules.Dial(&net.Dialer{Timeout: timeouts.Dial}, host.NetAddress)
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
 	defer func() { _ = conn.Close() }()
+	canceler := newFormCanceler(conn, params.Cancel)
+	defer func() { err = canceler.stop(err) }()
 
 	// allot time for sending RPC ID + verifySettings
-	extendDeadline(conn, modules.NegotiateSettingsTime)
+	extendDeadline(conn, timeouts.Settings)
 	if err = encoding.WriteObject(conn, modules.RPCFormContract); err != nil {
 		return modules.RenterContract{}, err
 	}
@@ -122,7 +199,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	}
 
 	// allot time for negotiation
-	extendDeadline(conn, modules.NegotiateFileContractTime)
+	extendDeadline(conn, timeouts.Negotiation)
 
 	// send acceptance, txn signed by us, and pubkey
 	if err = modules.WriteNegotiationAcceptance(conn); err != nil {
@@ -144,13 +221,13 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	var newParents []types.Transaction
 	var newInputs []types.SiacoinInput
 	var newOutputs []types.SiacoinOutput
-	if err = encoding.ReadObject(conn, &newParents, types.BlockSizeLimit); err != nil {
+	if err = encoding.ReadObjectStream(conn, &newParents, types.BlockSizeLimit, types.BlockSizeLimit); err != nil {
 		return modules.RenterContract{}, errors.New("couldn't read the host's added parents: " + err.Error())
 	}
-	if err = encoding.ReadObject(conn, &newInputs, types.BlockSizeLimit); err != nil {
+	if err = encoding.ReadObjectStream(conn, &newInputs, types.BlockSizeLimit, types.BlockSizeLimit); err != nil {
 		return modules.RenterContract{}, errors.New("couldn't read the host's added inputs: " + err.Error())
 	}
-	if err = encoding.ReadObject(conn, &newOutputs, types.BlockSizeLimit); err != nil {
+	if err = encoding.ReadObjectStream(conn, &newOutputs, types.BlockSizeLimit, types.BlockSizeLimit); err != nil {
 		return modules.RenterContract{}, errors.New("couldn't read the host's added outputs: " + err.Error())
 	}
 
@@ -207,7 +284,11 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	}
 	revisionTxn.TransactionSignatures[0].Signature = encodedSig[:]
 
-	// Send acceptance and signatures
+	// Send acceptance and signatures. The negotiation can no longer be
+	// abandoned once they are sent.
+	if err = canceler.commit(); err != nil {
+		return modules.RenterContract{}, err
+	}
 	if err = modules.WriteNegotiationAcceptance(conn); err != nil {
 		return modules.RenterContract{}, errors.New("couldn't send transaction acceptance: " + err.Error())
 	}
@@ -260,5 +341,6 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 		LastRevisionTxn: revisionTxn,
 		NetAddress:      host.NetAddress,
 		SecretKey:       ourSK,
+		HostVersion:     host.Version,
 	}, nil
 }
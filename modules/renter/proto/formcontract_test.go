@@ -0,0 +1,56 @@
+package proto
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestFormCanceler checks that a negotiation can be abandoned until it is
+// committed, and not afterwards.
+func TestFormCanceler(t *testing.T) {
+	// Cancelling before the commit closes the connection and replaces the
+	// resulting error.
+	rConn, hConn := net.Pipe()
+	defer hConn.Close()
+	cancel := make(chan struct{})
+	fc := newFormCanceler(rConn, cancel)
+	close(cancel)
+	_, err := rConn.Read(make([]byte, 1))
+	if err == nil {
+		t.Fatal("connection was not closed by cancel")
+	}
+	if err := fc.commit(); err != ErrFormCancelled {
+		t.Fatal("expected ErrFormCancelled, got", err)
+	}
+	if err := fc.stop(err); err != ErrFormCancelled {
+		t.Fatal("expected ErrFormCancelled, got", err)
+	}
+
+	// Cancelling after the commit has no effect.
+	rConn, hConn = net.Pipe()
+	defer rConn.Close()
+	defer hConn.Close()
+	cancel = make(chan struct{})
+	fc = newFormCanceler(rConn, cancel)
+	if err := fc.commit(); err != nil {
+		t.Fatal(err)
+	}
+	close(cancel)
+	go hConn.Write([]byte{1})
+	rConn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := rConn.Read(make([]byte, 1)); err != nil {
+		t.Fatal("connection was closed after the commit:", err)
+	}
+	sentinel := errors.New("sentinel")
+	if err := fc.stop(sentinel); err != sentinel {
+		t.Fatal("expected the original error, got", err)
+	}
+
+	// A nil cancel channel never cancels.
+	fc = newFormCanceler(rConn, nil)
+	if err := fc.stop(nil); err != nil {
+		t.Fatal(err)
+	}
+}
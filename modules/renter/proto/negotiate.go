@@ -12,29 +12,39 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
+// errDownloadPriceRaised is returned when a host asks for a higher download
+// price than the one it was chosen at.
+var errDownloadPriceRaised = errors.New("host raised its download price")
+
 // extendDeadline is a helper function for extending the connection timeout.
 func extendDeadline(conn net.Conn, d time.Duration) { _ = conn.SetDeadline(time.Now().Add(d)) }
 
 // startRevision is run at the beginning of each revision iteration. It reads
-// the host's settings confirms that the values are acceptable, and writes an acceptance.
-func startRevision(conn net.Conn, host modules.HostDBEntry) error {
+// the host's settings confirms that the values are acceptable, and writes an
+// acceptance. The received settings are returned.
+func startRevision(conn net.Conn, host modules.HostDBEntry) (modules.HostDBEntry, error) {
 	// verify the host's settings and confirm its identity
-	_, err := verifySettings(conn, host)
+	recvHost, err := verifySettings(conn, host)
 	if err != nil {
-		return err
+		return modules.HostDBEntry{}, err
 	}
-	return modules.WriteNegotiationAcceptance(conn)
+	return recvHost, modules.WriteNegotiationAcceptance(conn)
 }
 
 // startDownload is run at the beginning of each download iteration. It reads
-// the host's settings confirms that the values are acceptable, and writes an acceptance.
-func startDownload(conn net.Conn, host modules.HostDBEntry) error {
+// the host's settings confirms that the values are acceptable, and writes an
+// acceptance. The received settings are returned. The host is rejected if it
+// has raised its download price above the price in the known settings.
+func startDownload(conn net.Conn, host modules.HostDBEntry) (modules.HostDBEntry, error) {
 	// verify the host's settings and confirm its identity
-	_, err := verifySettings(conn, host)
+	recvHost, err := verifySettings(conn, host)
 	if err != nil {
-		return err
+		return modules.HostDBEntry{}, err
 	}
-	return modules.WriteNegotiationAcceptance(conn)
+	if recvHost.DownloadBandwidthPrice.Cmp(host.DownloadBandwidthPrice) > 0 {
+		return modules.HostDBEntry{}, modules.WriteNegotiationRejection(conn, errDownloadPriceRaised)
+	}
+	return recvHost, modules.WriteNegotiationAcceptance(conn)
 }
 
 // verifySettings reads a signed HostSettings object from conn, validates the
@@ -166,6 +176,24 @@ func negotiateRevision(conn net.Conn, rev types.FileContractRevision, secretKey
 	return signedTxn, responseErr
 }
 
+// sendResumableSector sends the data of a sector inserted with
+// modules.ActionInsertResumable. The host first reports how many bytes of the
+// sector it received before an earlier transfer was interrupted, and only the
+// rest of the sector is sent.
+func sendResumableSector(conn net.Conn, sector []byte) error {
+	var offset uint64
+	if err := encoding.ReadObject(conn, &offset, 16); err != nil {
+		return &interruptedTransferError{err}
+	}
+	if offset > uint64(len(sector)) {
+		return errors.New("host reported an invalid resume offset")
+	}
+	if _, err := conn.Write(sector[offset:]); err != nil {
+		return &interruptedTransferError{err}
+	}
+	return nil
+}
+
 // newRevision creates a copy of current with its revision number incremented,
 // and with cost transferred from the renter to the host.
 func newRevision(current types.FileContractRevision, cost types.Currency) types.FileContractRevision {
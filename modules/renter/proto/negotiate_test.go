@@ -62,3 +62,45 @@ func TestNegotiateRevisionStopResponse(t *testing.T) {
 	}
 	rConn.Close()
 }
+
+// TestStartDownloadPrice checks that startDownload accepts a host that
+// lowers its download price, and rejects a host that raises it.
+func TestStartDownloadPrice(t *testing.T) {
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := modules.HostDBEntry{PublicKey: types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}}
+	host.DownloadBandwidthPrice = types.NewCurrency64(10)
+
+	for _, price := range []uint64{5, 10, 11} {
+		rConn, hConn := net.Pipe()
+		// handle the host's half of the pipe
+		response := make(chan error)
+		go func() {
+			defer hConn.Close()
+			settings := modules.HostExternalSettings{DownloadBandwidthPrice: types.NewCurrency64(price)}
+			crypto.WriteSignedObject(hConn, settings, sk)
+			response <- modules.ReadNegotiationAcceptance(hConn)
+		}()
+
+		recvHost, err := startDownload(rConn, host)
+		hostErr := <-response
+		rConn.Close()
+		if price > 10 {
+			if err != errDownloadPriceRaised || hostErr == nil {
+				t.Fatalf("price %v: expected the host to be rejected, got %v and %v", price, err, hostErr)
+			}
+			continue
+		}
+		if err != nil || hostErr != nil {
+			t.Fatalf("price %v: expected the host to be accepted, got %v and %v", price, err, hostErr)
+		}
+		if recvHost.DownloadBandwidthPrice.Cmp(types.NewCurrency64(price)) != 0 {
+			t.Fatalf("price %v: received settings were not returned", price)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package proto
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -36,7 +37,39 @@ type ContractParams struct {
 	StartHeight   types.BlockHeight
 	EndHeight     types.BlockHeight
 	RefundAddress types.UnlockHash
-	// TODO: add optional keypair
+
+	// SecretKey is the key that the renter will use to sign the contract. If
+	// it is left empty, a random key is generated.
+	SecretKey crypto.SecretKey
+
+	// Timeouts limit each phase of the negotiation.
+	Timeouts FormTimeouts
+
+	// Cancel, if non-nil, abandons the negotiation when it is closed, unless
+	// the renter has already sent its signatures to the host.
+	Cancel <-chan struct{}
+}
+
+// FormTimeouts are the time limits of the phases of forming a contract. Zero
+// values use the defaults.
+type FormTimeouts struct {
+	Dial        time.Duration // connecting to the host
+	Settings    time.Duration // sending the RPC ID and verifying the host's settings
+	Negotiation time.Duration // negotiating and signing the contract
+}
+
+// withDefaults returns the timeouts with the defaults filled in.
+func (ft FormTimeouts) withDefaults() FormTimeouts {
+	if ft.Dial == 0 {
+		ft.Dial = defaultDialTimeout
+	}
+	if ft.Settings == 0 {
+		ft.Settings = modules.NegotiateSettingsTime
+	}
+	if ft.Negotiation == 0 {
+		ft.Negotiation = modules.NegotiateFileContractTime
+	}
+	return ft
 }
 
 // A revisionSaver is called just before we send our revision signature to the host; this
@@ -59,3 +92,21 @@ func IsRevisionMismatch(err error) bool {
 	_, ok := err.(*recentRevisionError)
 	return ok
 }
+
+// An interruptedTransferError occurs if the transfer of a resumable sector to
+// the host fails. The host keeps the part of the sector that it received.
+type interruptedTransferError struct {
+	err error
+}
+
+func (e *interruptedTransferError) Error() string {
+	return "sector transfer was interrupted: " + e.err.Error()
+}
+
+// IsInterruptedTransfer returns true if err was caused by the transfer of a
+// sector being interrupted. The transfer can be resumed by reconnecting to
+// the host and uploading the same sector again.
+func IsInterruptedTransfer(err error) bool {
+	_, ok := err.(*interruptedTransferError)
+	return ok
+}
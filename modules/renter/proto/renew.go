@@ -91,7 +91,7 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 	txnSet := append(parentTxns, txn)
 
 	// initiate connection
-	conn, err := net.DialTimeout("tcp", string(host.NetAddress), 15*time.Second)
+	conn, err := modules.Dial(&net.Dialer{Timeout: 15 * time.Second}, host.NetAddress)
 	if err != nil {
 		return modules.RenterContract{}, err
 	}
@@ -138,13 +138,13 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 	var newParents []types.Transaction
 	var newInputs []types.SiacoinInput
 	var newOutputs []types.SiacoinOutput
-	if err = encoding.ReadObject(conn, &newParents, types.BlockSizeLimit); err != nil {
+	if err = encoding.ReadObjectStream(conn, &newParents, types.BlockSizeLimit, types.BlockSizeLimit); err != nil {
 		return modules.RenterContract{}, errors.New("couldn't read the host's added parents: " + err.Error())
 	}
-	if err = encoding.ReadObject(conn, &newInputs, types.BlockSizeLimit); err != nil {
+	if err = encoding.ReadObjectStream(conn, &newInputs, types.BlockSizeLimit, types.BlockSizeLimit); err != nil {
 		return modules.RenterContract{}, errors.New("couldn't read the host's added inputs: " + err.Error())
 	}
-	if err = encoding.ReadObject(conn, &newOutputs, types.BlockSizeLimit); err != nil {
+	if err = encoding.ReadObjectStream(conn, &newOutputs, types.BlockSizeLimit, types.BlockSizeLimit); err != nil {
 		return modules.RenterContract{}, errors.New("couldn't read the host's added outputs: " + err.Error())
 	}
 
@@ -255,5 +255,6 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 		MerkleRoots:     contract.MerkleRoots,
 		NetAddress:      host.NetAddress,
 		SecretKey:       ourSK,
+		HostVersion:     host.Version,
 	}, nil
 }
@@ -3,6 +3,8 @@ package renter
 import (
 	"errors"
 
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/modules/renter/hostdb"
@@ -15,6 +17,9 @@ var (
 	errNilCS    = errors.New("cannot create renter with nil consensus set")
 	errNilTpool = errors.New("cannot create renter with nil transaction pool")
 	errNilHdb   = errors.New("cannot create renter with nil hostdb")
+
+	errNoContracts  = errors.New("renter has an allowance but no contracts")
+	errRenterClosed = errors.New("renter has been closed")
 )
 
 // A hostDB is a database of hosts that the renter can use for figuring out who
@@ -34,6 +39,14 @@ type hostDB interface {
 	// Close closes the hostdb.
 	Close() error
 
+	// HostCountry returns the country that a host is located in, or the
+	// empty string if it is not known.
+	HostCountry(modules.NetAddress) string
+
+	// HostSettingsHistory returns the observed changes to a host's settings,
+	// oldest first.
+	HostSettingsHistory(modules.NetAddress) ([]modules.HostSettingsChange, bool)
+
 	// IsOffline reports whether a host is consider offline.
 	IsOffline(modules.NetAddress) bool
 }
@@ -56,6 +69,10 @@ type hostContractor interface {
 	// Contracts returns the contracts formed by the contractor.
 	Contracts() []modules.RenterContract
 
+	// ContractMetrics returns the spending and renewal status of each
+	// contract formed by the contractor.
+	ContractMetrics() []modules.RenterContractMetrics
+
 	// Editor creates an Editor from the specified contract ID, allowing the
 	// insertion, deletion, and modification of sectors.
 	Editor(types.FileContractID) (contractor.Editor, error)
@@ -75,6 +92,23 @@ type hostContractor interface {
 type trackedFile struct {
 	// location of original file on disk
 	RepairPath string
+
+	// minimum version that a host must be running to receive pieces of the
+	// file; empty if any host may be used
+	MinHostVersion string
+
+	// regions that a host must be located in to receive pieces of the
+	// file; empty if any host may be used
+	Regions []string
+}
+
+// A downloadProgress records how much of a download has been written to its
+// destination. Chunks are written in order, so only the number of completed
+// chunks needs to be recorded.
+type downloadProgress struct {
+	SiaPath         string
+	FileSize        uint64
+	ChunksCompleted uint64
 }
 
 // A Renter is responsible for tracking all of the files that a user has
@@ -86,12 +120,23 @@ type Renter struct {
 	// resources
 	hostDB         hostDB
 	hostContractor hostContractor
+	latencies      *latencyTracker
 	log            *persist.Logger
+	events         *modules.EventFeed
+	transfers      *transferCounters
+	history        *transferHistory
 
 	// variables
 	files         map[string]*file
 	tracking      map[string]trackedFile // map from nickname to metadata
 	downloadQueue []*download
+	downloads     map[string]downloadProgress           // map from destination to progress of unfinished downloads
+	fileTags      map[string]map[string]string          // map from nickname to the tags of the file
+	fileRetention map[string]types.Timestamp            // map from nickname to the time until which the file is kept, if not forever
+	fileKeys      map[string]string                     // map from nickname to the key source of the file, if not generated by the renter
+	fileChecksums map[string]string                     // map from nickname to the hex SHA-256 of the uploaded data
+	chunkHashes   map[string][]crypto.Hash              // map from nickname to the deduplication hashes of the file's chunks
+	dedupIndex    map[crypto.Hash]map[chunkRef]struct{} // map from deduplication hash to the chunks that have it
 	uploading     bool
 	downloading   bool
 
@@ -99,6 +144,7 @@ type Renter struct {
 	persistDir string
 
 	mu *sync.RWMutex
+	tg sync.ThreadGroup
 }
 
 // New returns an initialized renter.
@@ -107,16 +153,24 @@ func New(cs modules.ConsensusSet, wallet modules.Wallet, tpool modules.Transacti
 	if err != nil {
 		return nil, err
 	}
-	hc, err := contractor.New(cs, wallet, tpool, hdb, persistDir)
+	// The contractor announces its contracts on the renter's event feed.
+	events := new(modules.EventFeed)
+	hc, err := contractor.New(cs, wallet, tpool, hdb, events, persistDir)
 	if err != nil {
 		return nil, err
 	}
 
-	return newRenter(cs, tpool, hdb, hc, persistDir)
+	r, err := newRenter(cs, tpool, hdb, hc, events, persistDir)
+	if err != nil {
+		return nil, err
+	}
+	// Contracts that only hold files past their retention are not renewed.
+	hc.SetRenewFilter(r.managedRetainContract)
+	return r, nil
 }
 
 // newRenter initializes a renter and returns it.
-func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostDB, hc hostContractor, persistDir string) (*Renter, error) {
+func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostDB, hc hostContractor, events *modules.EventFeed, persistDir string) (*Renter, error) {
 	if cs == nil {
 		return nil, errNilCS
 	}
@@ -132,13 +186,27 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 		cs:             cs,
 		hostDB:         hdb,
 		hostContractor: hc,
+		latencies:      newLatencyTracker(),
+		events:         events,
+		transfers:      new(transferCounters),
+		history:        new(transferHistory),
+
+		files:     make(map[string]*file),
+		tracking:  make(map[string]trackedFile),
+		downloads: make(map[string]downloadProgress),
+		fileTags:  make(map[string]map[string]string),
+		fileKeys:  make(map[string]string),
+
+		fileRetention: make(map[string]types.Timestamp),
 
-		files:    make(map[string]*file),
-		tracking: make(map[string]trackedFile),
+		fileChecksums: make(map[string]string),
+		chunkHashes:   make(map[string][]crypto.Hash),
+		dedupIndex:    make(map[crypto.Hash]map[chunkRef]struct{}),
 
 		persistDir: persistDir,
 		mu:         sync.New(modules.SafeMutexDelay, 1),
 	}
+	r.tg.SetName("renter")
 	if err := r.initPersist(); err != nil {
 		return nil, err
 	}
@@ -148,17 +216,50 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 	return r, nil
 }
 
-// Close closes the Renter and its dependencies
+// Close closes the Renter and its dependencies. New uploads and downloads are
+// rejected, and Close waits for the chunks that are currently being uploaded
+// to finish, so that every revision confirmed by a host is recorded in the
+// renter's files before the host sessions are closed.
 func (r *Renter) Close() error {
-	return r.hostDB.Close()
+	err := r.tg.Stop()
+	id := r.mu.Lock()
+	saveErr := r.saveSync()
+	r.mu.Unlock(id)
+	return build.JoinErrors([]error{err, saveErr, r.hostDB.Close()}, "; ")
+}
+
+// Healthy returns an error if the renter is closed, or if it has an allowance
+// but no contracts to upload with.
+func (r *Renter) Healthy() error {
+	if err := r.tg.Add(); err != nil {
+		return errRenterClosed
+	}
+	defer r.tg.Done()
+	if r.hostContractor.Allowance().Hosts > 0 && len(r.hostContractor.Contracts()) == 0 {
+		return errNoContracts
+	}
+	return nil
+}
+
+// SubscribeEvents returns a channel that receives an event for each completed
+// upload and each contract formed by the renter, and a function that ends the
+// subscription.
+func (r *Renter) SubscribeEvents() (<-chan modules.Event, func()) {
+	return r.events.Subscribe()
 }
 
 // hostdb passthroughs
 func (r *Renter) ActiveHosts() []modules.HostDBEntry { return r.hostDB.ActiveHosts() }
 func (r *Renter) AllHosts() []modules.HostDBEntry    { return r.hostDB.AllHosts() }
+func (r *Renter) HostSettingsHistory(addr modules.NetAddress) ([]modules.HostSettingsChange, bool) {
+	return r.hostDB.HostSettingsHistory(addr)
+}
 
 // contractor passthroughs
 func (r *Renter) Contracts() []modules.RenterContract { return r.hostContractor.Contracts() }
+func (r *Renter) ContractMetrics() []modules.RenterContractMetrics {
+	return r.hostContractor.ContractMetrics()
+}
 func (r *Renter) FinancialMetrics() modules.RenterFinancialMetrics {
 	return r.hostContractor.FinancialMetrics()
 }
@@ -1,7 +1,11 @@
 package renter
 
 import (
+	"bytes"
+	"os"
 	"path/filepath"
+	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -12,6 +16,8 @@ import (
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/modules/transactionpool"
 	"github.com/NebulousLabs/Sia/modules/wallet"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -131,7 +137,7 @@ func newContractorTester(name string, hdb hostDB, hc hostContractor) (*renterTes
 	if err != nil {
 		return nil, err
 	}
-	r, err := newRenter(cs, tp, hdb, hc, filepath.Join(testdir, modules.RenterDir))
+	r, err := newRenter(cs, tp, hdb, hc, new(modules.EventFeed), filepath.Join(testdir, modules.RenterDir))
 	if err != nil {
 		return nil, err
 	}
@@ -166,11 +172,15 @@ func newContractorTester(name string, hdb hostDB, hc hostContractor) (*renterTes
 // of the hostDB's methods on every mock.
 type stubHostDB struct{}
 
-func (stubHostDB) ActiveHosts() []modules.HostDBEntry   { return nil }
-func (stubHostDB) AllHosts() []modules.HostDBEntry      { return nil }
-func (stubHostDB) AverageContractPrice() types.Currency { return types.Currency{} }
-func (stubHostDB) Close() error                         { return nil }
-func (stubHostDB) IsOffline(modules.NetAddress) bool    { return true }
+func (stubHostDB) ActiveHosts() []modules.HostDBEntry    { return nil }
+func (stubHostDB) AllHosts() []modules.HostDBEntry       { return nil }
+func (stubHostDB) AverageContractPrice() types.Currency  { return types.Currency{} }
+func (stubHostDB) Close() error                          { return nil }
+func (stubHostDB) HostCountry(modules.NetAddress) string { return "" }
+func (stubHostDB) HostSettingsHistory(modules.NetAddress) ([]modules.HostSettingsChange, bool) {
+	return nil, false
+}
+func (stubHostDB) IsOffline(modules.NetAddress) bool { return true }
 
 // stubContractor is the minimal implementation of the hostContractor
 // interface.
@@ -182,8 +192,117 @@ func (stubContractor) Contract(modules.NetAddress) (modules.RenterContract, bool
 	return modules.RenterContract{}, false
 }
 func (stubContractor) Contracts() []modules.RenterContract                    { return nil }
+func (stubContractor) ContractMetrics() []modules.RenterContractMetrics       { return nil }
 func (stubContractor) FinancialMetrics() (m modules.RenterFinancialMetrics)   { return }
 func (stubContractor) Editor(types.FileContractID) (contractor.Editor, error) { return nil, nil }
 func (stubContractor) Downloader(types.FileContractID) (contractor.Downloader, error) {
 	return nil, nil
 }
+
+// shutdownContractor is a hostContractor with one contract per host, whose
+// Editors upload slowly enough for the renter to be stopped mid-upload.
+type shutdownContractor struct {
+	stubContractor
+	contracts []modules.RenterContract
+	hosts     map[types.FileContractID]*testHost
+}
+
+func (sc *shutdownContractor) Contracts() []modules.RenterContract { return sc.contracts }
+
+func (sc *shutdownContractor) Editor(id types.FileContractID) (contractor.Editor, error) {
+	return sc.hosts[id], nil
+}
+
+// TestRenterCloseRejectsWork checks that a closed renter does not accept new
+// uploads or downloads.
+func TestRenterCloseRejectsWork(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newContractorTester("TestRenterCloseRejectsWork", stubHostDB{}, stubContractor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.Upload(modules.FileUploadParams{SiaPath: "foo"}); err != sync.ErrStopped {
+		t.Error("expected ErrStopped from Upload, got", err)
+	}
+	if err := rt.renter.Download("foo", "bar"); err != sync.ErrStopped {
+		t.Error("expected ErrStopped from Download, got", err)
+	}
+}
+
+// TestRepairChunksShutdown checks that stopping the renter during a repair
+// lets the chunk in flight finish and be saved, without starting new chunks.
+func TestRepairChunksShutdown(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	dir := build.TempDir("renter", "TestRepairChunksShutdown")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := persist.NewFileLogger(filepath.Join(dir, "renter.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	r := &Renter{
+		log:        logger,
+		persistDir: dir,
+		transfers:  new(transferCounters),
+		history:    new(transferHistory),
+		mu:         sync.New(modules.SafeMutexDelay, 1),
+	}
+
+	// Each upload takes 200ms, and each host has a single contract, so only
+	// one chunk is uploaded at a time.
+	sc := &shutdownContractor{hosts: make(map[types.FileContractID]*testHost)}
+	for i, addr := range []modules.NetAddress{"foo", "bar"} {
+		id := types.FileContractID{byte(i)}
+		sc.contracts = append(sc.contracts, modules.RenterContract{ID: id, NetAddress: addr})
+		sc.hosts[id] = &testHost{
+			ip:       addr,
+			sectors:  make(map[crypto.Hash][]byte),
+			delay:    200 * time.Millisecond,
+			failRate: 1 << 30,
+		}
+	}
+	pool := &hostPool{hostContractor: sc}
+	defer pool.Close()
+
+	rsc, err := NewRSCode(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFile("foo", rsc, 10, 30)
+	if err := r.tg.Add(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		r.repairChunks(f, bytes.NewReader(make([]byte, 30)), f.incompleteChunks(), pool, nil)
+		r.tg.Done()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := r.tg.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first chunk should have been uploaded, and it should be
+	// recorded both in memory and on disk.
+	if n := len(f.incompleteChunks()); n != 2 {
+		t.Fatal("expected 2 incomplete chunks after shutdown, got", n)
+	}
+	for _, h := range sc.hosts {
+		if len(h.sectors) != 1 {
+			t.Errorf("host %v received %v sectors, expected 1", h.ip, len(h.sectors))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, f.name+ShareExtension)); err != nil {
+		t.Fatal("repaired file was not saved:", err)
+	}
+}
@@ -53,25 +53,47 @@ func (hs hostErrs) Error() string {
 	return build.JoinErrors(errs, "\n").Error()
 }
 
+// readChunk reads the data of a chunk from r. The last chunk of the file is
+// padded with zeros.
+func (f *file) readChunk(chunkIndex uint64, r io.ReaderAt) ([]byte, error) {
+	chunk := make([]byte, f.chunkSize())
+	_, err := r.ReadAt(chunk, int64(chunkIndex*f.chunkSize()))
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return chunk, nil
+}
+
 // repair attempts to repair a file chunk by uploading its pieces to more
 // hosts.
 func (f *file) repair(chunkIndex uint64, missingPieces []uint64, r io.ReaderAt, hosts []contractor.Editor) error {
 	// read chunk data and encode
-	chunk := make([]byte, f.chunkSize())
-	_, err := r.ReadAt(chunk, int64(chunkIndex*f.chunkSize()))
-	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+	chunk, err := f.readChunk(chunkIndex, r)
+	if err != nil {
 		return err
 	}
 	pieces, err := f.erasureCode.Encode(chunk)
 	if err != nil {
 		return err
 	}
-	// encrypt pieces
+	// encrypt pieces, unless the data was encrypted before it was uploaded
+	if f.encrypted() {
+		for i := range pieces {
+			key, err := f.pieceKey(chunkIndex, uint64(i))
+			if err != nil {
+				return err
+			}
+			pieces[i], err = key.EncryptBytes(pieces[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// hosts only store full sectors, so pad pieces of files with a small
+	// chunk size
 	for i := range pieces {
-		key := deriveKey(f.masterKey, chunkIndex, uint64(i))
-		pieces[i], err = key.EncryptBytes(pieces[i])
-		if err != nil {
-			return err
+		if uint64(len(pieces[i])) < modules.SectorSize {
+			pieces[i] = append(pieces[i], make([]byte, modules.SectorSize-uint64(len(pieces[i])))...)
 		}
 	}
 
@@ -213,47 +235,81 @@ func (f *file) offlineChunks(hdb hostDB) map[uint64][]uint64 {
 
 // threadedRepairLoop improves the health of files tracked by the renter by
 // reuploading their missing pieces. Multiple repair attempts may be necessary
-// before the file reaches full redundancy.
+// before the file reaches full redundancy. The loop exits when the renter is
+// stopped.
 func (r *Renter) threadedRepairLoop() {
 	for {
-		time.Sleep(5 * time.Second)
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(5 * time.Second):
+		}
 
 		if len(r.hostContractor.Contracts()) == 0 {
 			// nothing to revise
 			continue
 		}
+		r.managedRepairIteration()
+	}
+}
 
-		// if the downloading flag is set, abort early. Otherwise, set the
-		// uploading flag.
-		id := r.mu.Lock()
-		downloading := r.downloading
-		if !downloading {
-			r.uploading = true
-		}
-		r.mu.Unlock(id)
-		if downloading {
-			continue
-		}
+// managedRepairIteration performs a single pass of the repair loop over all
+// tracked files. The renter's ThreadGroup is held for the duration of the
+// pass, so that Close waits for in-flight chunk uploads to be recorded and
+// for the host sessions to be closed with their final revisions.
+func (r *Renter) managedRepairIteration() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	// if the downloading flag is set, abort early. Otherwise, set the
+	// uploading flag.
+	id := r.mu.Lock()
+	downloading := r.downloading
+	if !downloading {
+		r.uploading = true
+	}
+	r.mu.Unlock(id)
+	if downloading {
+		return
+	}
 
-		// make copy of repair set under lock
-		repairing := make(map[string]trackedFile)
-		id = r.mu.RLock()
-		for name, meta := range r.tracking {
+	// make copy of repair set under lock, skipping files that no longer
+	// need to be kept
+	repairing := make(map[string]trackedFile)
+	id = r.mu.RLock()
+	for name, meta := range r.tracking {
+		if r.retained(name) {
 			repairing[name] = meta
 		}
-		r.mu.RUnlock(id)
+	}
+	r.mu.RUnlock(id)
 
-		// create host pool
-		pool := r.newHostPool()
-		for name, meta := range repairing {
-			r.threadedRepairFile(name, meta, pool)
+	// create host pool
+	pool := r.newHostPool()
+	for name, meta := range repairing {
+		if r.stopped() {
+			break
 		}
-		pool.Close() // heh
+		r.threadedRepairFile(name, meta, pool)
+	}
+	pool.Close() // heh
 
-		// unset uploading flag
-		id = r.mu.Lock()
-		r.uploading = false
-		r.mu.Unlock(id)
+	// unset uploading flag
+	id = r.mu.Lock()
+	r.uploading = false
+	r.mu.Unlock(id)
+}
+
+// stopped returns true if the renter is shutting down, in which case no new
+// work should be started.
+func (r *Renter) stopped() bool {
+	select {
+	case <-r.tg.StopChan():
+		return true
+	default:
+		return false
 	}
 }
 
@@ -290,25 +346,34 @@ func (r *Renter) threadedRepairFile(name string, meta trackedFile, pool *hostPoo
 	}
 	defer handle.Close()
 
+	// reference the pieces of identical chunks that were already uploaded
+	exclude := r.excludedHosts(meta)
+	incChunks = r.managedDedupChunks(f, handle, incChunks, exclude)
+
 	// repair incomplete chunks
 	if len(incChunks) != 0 {
 		r.log.Printf("repairing %v chunks of %v", len(incChunks), f.name)
-		r.repairChunks(f, handle, incChunks, pool)
+		r.repairChunks(f, handle, incChunks, pool, exclude)
+	}
+
+	// announce the file once every chunk has been uploaded
+	if len(f.incompleteChunks()) == 0 {
+		r.events.Publish(modules.EventUploadComplete, modules.UploadCompleteEvent{SiaPath: name})
 	}
 }
 
-// repairChunks uploads missing chunks of f to new hosts.
-func (r *Renter) repairChunks(f *file, handle io.ReaderAt, chunks map[uint64][]uint64, pool *hostPool) {
-	for chunk, pieces := range chunks {
-		// Determine host set. We want one host for each missing piece, and no
-		// repeats of other hosts of this chunk.
-		hosts := pool.uniqueHosts(len(pieces), f.chunkHosts(chunk))
-		if len(hosts) == 0 {
-			r.log.Debugf("aborting repair of %v: host pool is empty", f.name)
-			return
-		}
-		// upload to new hosts
-		err := f.repair(chunk, pieces, handle, hosts)
+// repairChunks uploads missing chunks of f to new hosts. Hosts in 'exclude'
+// will not be used. Chunks are repaired in parallel, up to the number of
+// contracts that the pool holds with a single host, so that each host
+// receives pieces over all of its contracts at once.
+func (r *Renter) repairChunks(f *file, handle io.ReaderAt, chunks map[uint64][]uint64, pool *hostPool, exclude []modules.NetAddress) {
+	errChan := make(chan error)
+	active := 0
+
+	// handleResult processes the result of a chunk repair, returning false
+	// if no further chunks should be repaired.
+	handleResult := func(err error) bool {
+		active--
 		if err != nil {
 			if he, ok := err.(hostErrs); ok {
 				// if a specific host failed, remove it from the pool
@@ -322,7 +387,7 @@ func (r *Renter) repairChunks(f *file, handle io.ReaderAt, chunks map[uint64][]u
 			} else {
 				// any other type of error indicates a serious problem
 				r.log.Printf("aborting repair of %v: %v", f.name, err)
-				return
+				return false
 			}
 		}
 
@@ -334,15 +399,46 @@ func (r *Renter) repairChunks(f *file, handle io.ReaderAt, chunks map[uint64][]u
 			// If saving failed for this chunk, it will probably fail for the
 			// next chunk as well. Better to try again on the next cycle.
 			r.log.Printf("failed to save repaired file %v: %v", f.name, err)
-			return
+			return false
 		}
 
-		// check for download interruption
+		// check for download interruption or shutdown. Chunks that are
+		// already being uploaded are allowed to finish, so that the pieces
+		// the hosts have confirmed are saved above.
 		id := r.mu.RLock()
 		downloading := r.downloading
 		r.mu.RUnlock(id)
-		if downloading {
-			return
+		return !downloading && !r.stopped()
+	}
+
+	ok := !r.stopped()
+	for chunk, pieces := range chunks {
+		// Wait for a repair to finish if every session is in use.
+		for ok && active >= pool.sessions() {
+			ok = handleResult(<-errChan)
+		}
+		if !ok {
+			break
+		}
+
+		// Determine host set. We want one host for each missing piece, and no
+		// repeats of other hosts of this chunk.
+		hosts := pool.uniqueHosts(len(pieces), append(f.chunkHosts(chunk), exclude...))
+		if len(hosts) == 0 {
+			r.log.Debugf("aborting repair of %v: host pool is empty", f.name)
+			break
 		}
+		// upload to new hosts
+		active++
+		go func(chunk uint64, pieces []uint64, hosts []contractor.Editor) {
+			err := f.repair(chunk, pieces, handle, r.countingEditors(f.name, hosts))
+			pool.release(hosts)
+			errChan <- err
+		}(chunk, pieces, hosts)
+	}
+
+	// Wait for the remaining repairs to finish.
+	for active > 0 {
+		handleResult(<-errChan)
 	}
 }
@@ -33,6 +33,7 @@ func (h *testHost) Address() modules.NetAddress                           { retu
 func (h *testHost) Delete(crypto.Hash) error                              { return nil }
 func (h *testHost) Modify(crypto.Hash, crypto.Hash, uint64, []byte) error { return nil }
 func (h *testHost) EndHeight() types.BlockHeight                          { return 0 }
+func (h *testHost) Spending() types.Currency                              { return types.ZeroCurrency }
 func (h *testHost) Close() error                                          { return nil }
 
 // ContractID returns a fake (but unique) file contract ID.
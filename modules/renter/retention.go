@@ -0,0 +1,71 @@
+package renter
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// retained returns whether a file still needs to be kept. Files without a
+// retention time are kept forever. The caller must hold the renter lock.
+func (r *Renter) retained(nickname string) bool {
+	until, exists := r.fileRetention[nickname]
+	return !exists || types.Timestamp(time.Now().Unix()) < until
+}
+
+// SetFileRetention sets the time after which a file no longer needs to be
+// kept. Once that time has passed, the file is no longer repaired, and the
+// contracts that only hold data of files that are no longer kept are not
+// renewed. Passing zero keeps the file forever.
+func (r *Renter) SetFileRetention(nickname string, until types.Timestamp) error {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	if _, exists := r.files[nickname]; !exists {
+		return ErrUnknownPath
+	}
+	if until == 0 {
+		delete(r.fileRetention, nickname)
+	} else {
+		r.fileRetention[nickname] = until
+	}
+	return r.saveSync()
+}
+
+// managedRetainContract returns whether a contract should be renewed. A
+// contract is left to expire if it holds data of files that are no longer
+// kept, and none of the files that are still kept. Contracts that hold no
+// data of any file are renewed, as they are needed for future uploads.
+func (r *Renter) managedRetainContract(contract modules.RenterContract) bool {
+	lockID := r.mu.RLock()
+	defer r.mu.RUnlock(lockID)
+	if len(r.fileRetention) == 0 {
+		return true
+	}
+
+	kept := make(map[crypto.Hash]bool)
+	expired := make(map[crypto.Hash]bool)
+	for name, f := range r.files {
+		roots := kept
+		if !r.retained(name) {
+			roots = expired
+		}
+		f.mu.RLock()
+		for _, fc := range f.contracts {
+			for _, p := range fc.Pieces {
+				roots[p.MerkleRoot] = true
+			}
+		}
+		f.mu.RUnlock()
+	}
+
+	holdsExpired := false
+	for _, root := range contract.MerkleRoots {
+		if kept[root] {
+			return true
+		}
+		holdsExpired = holdsExpired || expired[root]
+	}
+	return !holdsExpired
+}
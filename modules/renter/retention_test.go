@@ -0,0 +1,85 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterFileRetention checks that files past their retention are no
+// longer reported as renewing, and that only contracts holding nothing but
+// such files are left to expire.
+func TestRenterFileRetention(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester("TestRenterFileRetention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	err = rt.renter.SetFileRetention("dne", 1)
+	if err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Put two files in the renter, each with a piece in its own contract.
+	kept, expired := newTestingFile(), newTestingFile()
+	kept.name, expired.name = "kept", "expired"
+	keptRoot, expiredRoot := crypto.Hash{1}, crypto.Hash{2}
+	kept.contracts = map[types.FileContractID]fileContract{
+		{1}: {ID: types.FileContractID{1}, Pieces: []pieceData{{MerkleRoot: keptRoot}}},
+	}
+	expired.contracts = map[types.FileContractID]fileContract{
+		{2}: {ID: types.FileContractID{2}, Pieces: []pieceData{{MerkleRoot: expiredRoot}}},
+	}
+	rt.renter.files[kept.name] = kept
+	rt.renter.files[expired.name] = expired
+
+	// Without retention times, every contract is renewed.
+	if !rt.renter.managedRetainContract(modules.RenterContract{MerkleRoots: []crypto.Hash{expiredRoot}}) {
+		t.Fatal("contract was not renewed without retention times")
+	}
+
+	future := types.Timestamp(time.Now().Add(time.Hour).Unix())
+	past := types.Timestamp(time.Now().Add(-time.Hour).Unix())
+	if err := rt.renter.SetFileRetention(kept.name, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.SetFileRetention(expired.name, past); err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range rt.renter.FileList() {
+		if fi.Renewing != (fi.SiaPath == kept.name) {
+			t.Error("wrong renewing status for", fi.SiaPath)
+		}
+	}
+
+	tests := []struct {
+		roots []crypto.Hash
+		renew bool
+	}{
+		{nil, true},
+		{[]crypto.Hash{keptRoot}, true},
+		{[]crypto.Hash{expiredRoot}, false},
+		{[]crypto.Hash{expiredRoot, keptRoot}, true},
+		{[]crypto.Hash{{3}}, true},
+	}
+	for _, test := range tests {
+		if rt.renter.managedRetainContract(modules.RenterContract{MerkleRoots: test.roots}) != test.renew {
+			t.Errorf("contract holding %v: expected renew to be %v", test.roots, test.renew)
+		}
+	}
+
+	// Keeping the file forever again should renew its contract.
+	if err := rt.renter.SetFileRetention(expired.name, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.renter.managedRetainContract(modules.RenterContract{MerkleRoots: []crypto.Hash{expiredRoot}}) {
+		t.Fatal("contract of a file kept forever is not renewed")
+	}
+}
@@ -0,0 +1,183 @@
+package renter
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// historyFilename is the file in which the transfer history is saved.
+	historyFilename = "transfers.json"
+)
+
+var (
+	// maxTransferHistory is the number of transfers that are remembered by
+	// the renter. Older transfers are discarded.
+	maxTransferHistory = func() int {
+		switch build.Release {
+		case "dev":
+			return 1e3
+		case "standard":
+			return 10e3
+		case "testing":
+			return 10
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	historyMetadata = persist.Metadata{
+		Header:  "Renter Transfer History",
+		Version: "1.0",
+	}
+)
+
+type (
+	// transferCounters count the bytes that the renter has transferred to
+	// and from hosts. The counters are updated atomically.
+	transferCounters struct {
+		downloaded uint64
+		uploaded   uint64
+	}
+
+	// A transferHistory holds the most recent transfers between the renter
+	// and its hosts. Sectors are transferred far more often than the renter
+	// is saved, so the history is only written to disk when it has changed.
+	transferHistory struct {
+		transfers []modules.RenterTransfer
+		changed   bool
+		mu        sync.Mutex
+	}
+
+	// countingEditor is an Editor that counts and records the sectors that
+	// it uploads.
+	countingEditor struct {
+		contractor.Editor
+		counters *transferCounters
+		history  *transferHistory
+		siapath  string
+	}
+
+	// countingDownloader is a Downloader that counts and records the sectors
+	// that it downloads.
+	countingDownloader struct {
+		contractor.Downloader
+		counters *transferCounters
+		history  *transferHistory
+		host     modules.NetAddress
+		siapath  string
+	}
+)
+
+// record adds a transfer to the history, discarding the oldest transfer if
+// the history is full.
+func (th *transferHistory) record(t modules.RenterTransfer) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.transfers = append(th.transfers, t)
+	if len(th.transfers) > maxTransferHistory {
+		th.transfers = th.transfers[len(th.transfers)-maxTransferHistory:]
+	}
+	th.changed = true
+}
+
+// load reads the history from the renter's persist directory.
+func (th *transferHistory) load(dir string) error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	return persist.LoadFile(historyMetadata, &th.transfers, filepath.Join(dir, historyFilename))
+}
+
+// save writes the history to the renter's persist directory if it has
+// changed since it was last saved.
+func (th *transferHistory) save(dir string) error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	if !th.changed {
+		return nil
+	}
+	err := persist.SaveFile(historyMetadata, th.transfers, filepath.Join(dir, historyFilename))
+	if err == nil {
+		th.changed = false
+	}
+	return err
+}
+
+// newTransfer returns a record of a transfer that started at start.
+func newTransfer(siapath string, host modules.NetAddress, direction string, start time.Time, bytes uint64, price types.Currency, err error) modules.RenterTransfer {
+	t := modules.RenterTransfer{
+		SiaPath:   siapath,
+		Host:      host,
+		Direction: direction,
+		Price:     price,
+		Time:      start,
+		Duration:  time.Since(start),
+	}
+	if err != nil {
+		t.Error = err.Error()
+	} else {
+		t.Bytes = bytes
+	}
+	return t
+}
+
+// Upload uploads a sector to the host, counting its bytes if the upload
+// succeeds.
+func (ce countingEditor) Upload(data []byte) (crypto.Hash, error) {
+	spent := ce.Editor.Spending()
+	start := time.Now()
+	root, err := ce.Editor.Upload(data)
+	if err == nil {
+		atomic.AddUint64(&ce.counters.uploaded, uint64(len(data)))
+	}
+	price := ce.Editor.Spending().Sub(spent)
+	ce.history.record(newTransfer(ce.siapath, ce.Address(), modules.TransferUpload, start, uint64(len(data)), price, err))
+	return root, err
+}
+
+// Sector downloads a sector from the host, counting its bytes.
+func (cd countingDownloader) Sector(root crypto.Hash) ([]byte, error) {
+	spent := cd.Downloader.Spending()
+	start := time.Now()
+	data, err := cd.Downloader.Sector(root)
+	atomic.AddUint64(&cd.counters.downloaded, uint64(len(data)))
+	price := cd.Downloader.Spending().Sub(spent)
+	cd.history.record(newTransfer(cd.siapath, cd.host, modules.TransferDownload, start, uint64(len(data)), price, err))
+	return data, err
+}
+
+// countingEditors wraps a set of editors so that their uploads of the pieces
+// of a file are counted.
+func (r *Renter) countingEditors(siapath string, editors []contractor.Editor) []contractor.Editor {
+	counted := make([]contractor.Editor, len(editors))
+	for i, e := range editors {
+		counted[i] = countingEditor{Editor: e, counters: r.transfers, history: r.history, siapath: siapath}
+	}
+	return counted
+}
+
+// TransferHistory returns the most recent transfers between the renter and
+// its hosts, oldest first.
+func (r *Renter) TransferHistory() []modules.RenterTransfer {
+	r.history.mu.Lock()
+	defer r.history.mu.Unlock()
+	return append([]modules.RenterTransfer(nil), r.history.transfers...)
+}
+
+// TransferMetrics returns the number of bytes that the renter has transferred
+// to and from hosts since startup.
+func (r *Renter) TransferMetrics() modules.RenterTransferMetrics {
+	return modules.RenterTransferMetrics{
+		Downloaded: atomic.LoadUint64(&r.transfers.downloaded),
+		Uploaded:   atomic.LoadUint64(&r.transfers.uploaded),
+	}
+}
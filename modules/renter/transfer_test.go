@@ -0,0 +1,85 @@
+package renter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestTransferHistory checks that the transfer history discards its oldest
+// transfers when full, and that it survives being saved and loaded.
+func TestTransferHistory(t *testing.T) {
+	dir := build.TempDir("renter", "TestTransferHistory")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	th := new(transferHistory)
+	for i := 0; i < maxTransferHistory+5; i++ {
+		th.record(modules.RenterTransfer{Bytes: uint64(i)})
+	}
+	if len(th.transfers) != maxTransferHistory {
+		t.Fatalf("expected %v transfers, got %v", maxTransferHistory, len(th.transfers))
+	}
+	if th.transfers[0].Bytes != 5 {
+		t.Fatal("oldest transfers were not discarded first")
+	}
+
+	if err := th.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if th.changed {
+		t.Fatal("history is still marked as changed after saving")
+	}
+	loaded := new(transferHistory)
+	if err := loaded.load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.transfers) != maxTransferHistory || loaded.transfers[0].Bytes != 5 {
+		t.Fatal("loaded history does not match saved history")
+	}
+}
+
+// TestCountingEditorHistory checks that uploads through a countingEditor are
+// recorded with the file, host, and outcome of each transfer.
+func TestCountingEditorHistory(t *testing.T) {
+	r := &Renter{
+		transfers: new(transferCounters),
+		history:   new(transferHistory),
+	}
+	ok := &testHost{ip: "ok", sectors: make(map[crypto.Hash][]byte), failRate: 1e9}
+	failing := &testHost{ip: "failing", sectors: make(map[crypto.Hash][]byte), failRate: 1}
+	editors := r.countingEditors("foo", []contractor.Editor{ok, failing})
+
+	data := []byte("sector data")
+	if _, err := editors[0].Upload(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := editors[1].Upload(data); err == nil {
+		t.Fatal("expected upload to failing host to fail")
+	}
+
+	history := r.TransferHistory()
+	if len(history) != 2 {
+		t.Fatalf("expected 2 transfers, got %v", len(history))
+	}
+	for _, transfer := range history {
+		if transfer.SiaPath != "foo" || transfer.Direction != modules.TransferUpload {
+			t.Error("transfer recorded with wrong file or direction:", transfer)
+		}
+		if transfer.Price.Cmp(types.ZeroCurrency) != 0 {
+			t.Error("test hosts should not charge for uploads")
+		}
+	}
+	if history[0].Host != "ok" || history[0].Error != "" || history[0].Bytes != uint64(len(data)) {
+		t.Error("successful upload recorded incorrectly:", history[0])
+	}
+	if history[1].Host != "failing" || history[1].Error == "" || history[1].Bytes != 0 {
+		t.Error("failed upload recorded incorrectly:", history[1])
+	}
+}
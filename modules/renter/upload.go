@@ -1,8 +1,11 @@
 package renter
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -13,6 +16,10 @@ import (
 
 var (
 	errInsufficientContracts = errors.New("not enough contracts to upload file")
+	errInvalidMinHostVersion = errors.New("minimum host version is not a valid version")
+	errKeyAndPreEncrypted    = errors.New("an encryption key cannot be supplied for pre-encrypted data")
+	errCipherPreEncrypted    = errors.New("a cipher cannot be supplied for pre-encrypted data")
+	errInvalidChunkSize      = errors.New("chunk size must be a multiple of the number of data pieces, and the resulting piece size must be a multiple of the segment size that fits in a sector")
 
 	// Erasure-coded piece size
 	pieceSize = modules.SectorSize - crypto.TwofishOverhead
@@ -45,15 +52,70 @@ var (
 	}()
 )
 
+// customPieceSize returns the piece size of a file uploaded with the provided
+// chunk size. Each piece is stored in its own sector, so the piece size cannot
+// exceed maxSize, which is the default piece size for data that the renter
+// encrypts and a full sector otherwise. It must also be a multiple of the
+// segment size so that storage proofs stay aligned.
+func customPieceSize(chunkSize uint64, ec modules.ErasureCoder, maxSize uint64) (uint64, error) {
+	if chunkSize == 0 || chunkSize%uint64(ec.MinPieces()) != 0 {
+		return 0, errInvalidChunkSize
+	}
+	size := chunkSize / uint64(ec.MinPieces())
+	if size > maxSize || size%crypto.SegmentSize != 0 {
+		return 0, errInvalidChunkSize
+	}
+	return size, nil
+}
+
+// dataChecksum returns the hex-encoded SHA-256 hash of the data read from r.
+func dataChecksum(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 hash of the file at path.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return dataChecksum(f)
+}
+
 // Upload instructs the renter to start tracking a file. The renter will
 // automatically upload and repair tracked files using a background loop.
 func (r *Renter) Upload(up modules.FileUploadParams) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
 	// Enforce nickname rules.
-	if strings.HasPrefix(up.SiaPath, "/") {
-		return errors.New("nicknames cannot begin with /")
+	if err := validateSiaPath(up.SiaPath); err != nil {
+		return err
+	}
+	if up.MinHostVersion != "" && !build.IsVersion(up.MinHostVersion) {
+		return errInvalidMinHostVersion
+	}
+	if _, err := modules.ExpandRegions(up.Regions); err != nil {
+		return err
+	}
+	if err := validateFileTags(up.Tags); err != nil {
+		return err
+	}
+	if up.PreEncrypted && up.EncryptionKey != (crypto.TwofishKey{}) {
+		return errKeyAndPreEncrypted
 	}
-	if up.SiaPath == "" {
-		return ErrEmptyFilename
+	if up.PreEncrypted && up.Cipher != "" {
+		return errCipherPreEncrypted
+	}
+	if up.Cipher != "" && !up.Cipher.Valid() {
+		return crypto.ErrUnknownCipher
 	}
 
 	// Check for a nickname conflict.
@@ -75,21 +137,63 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 
 	// Check that we have contracts to upload to. We need at least (data +
 	// parity/2) contracts; since NumPieces = data + parity, we arrive at the
-	// expression below.
-	if nContracts := len(r.hostContractor.Contracts()); nContracts < (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2 && build.Release != "testing" {
+	// expression below. Only contracts with hosts that satisfy the minimum
+	// version and the region constraint are counted.
+	meta := trackedFile{
+		RepairPath:     up.Source,
+		MinHostVersion: up.MinHostVersion,
+		Regions:        normalizeRegions(up.Regions),
+	}
+	if nContracts := len(r.hostContractor.Contracts()) - len(r.excludedHosts(meta)); nContracts < (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2 && build.Release != "testing" {
 		return fmt.Errorf("not enough contracts to upload file: got %v, needed %v", nContracts, (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2)
 	}
 
-	// Create file object.
-	f := newFile(up.SiaPath, up.ErasureCode, pieceSize, uint64(fileInfo.Size()))
+	// Create file object. Data that is already encrypted is not encrypted
+	// again, so its pieces can fill an entire sector.
+	keySource := modules.KeySourceRenter
+	filePieceSize := pieceSize
+	if up.PreEncrypted {
+		keySource = modules.KeySourcePreEncrypted
+		filePieceSize = modules.SectorSize
+	}
+	if up.ChunkSize != 0 {
+		filePieceSize, err = customPieceSize(up.ChunkSize, up.ErasureCode, filePieceSize)
+		if err != nil {
+			return err
+		}
+	}
+	f := newFile(up.SiaPath, up.ErasureCode, filePieceSize, uint64(fileInfo.Size()))
 	f.mode = uint32(fileInfo.Mode())
+	f.preEncrypted = up.PreEncrypted
+	if up.EncryptionKey != (crypto.TwofishKey{}) {
+		keySource = modules.KeySourceCaller
+		f.masterKey = up.EncryptionKey
+	}
+	if up.Cipher != "" {
+		f.cipherType = up.Cipher
+	}
+
+	// Record the checksum of the data, so that downloads of the file can be
+	// verified against it.
+	checksum, err := fileChecksum(up.Source)
+	if err != nil {
+		return err
+	}
 
 	// Add file to renter.
 	lockID = r.mu.Lock()
 	r.files[up.SiaPath] = f
-	r.tracking[up.SiaPath] = trackedFile{
-		RepairPath: up.Source,
+	r.tracking[up.SiaPath] = meta
+	if len(up.Tags) > 0 {
+		r.fileTags[up.SiaPath] = copyFileTags(up.Tags)
+	}
+	if up.RetainUntil != 0 {
+		r.fileRetention[up.SiaPath] = up.RetainUntil
+	}
+	if keySource != modules.KeySourceRenter {
+		r.fileKeys[up.SiaPath] = keySource
 	}
+	r.fileChecksums[up.SiaPath] = checksum
 	r.saveSync()
 	r.mu.Unlock(lockID)
 
@@ -101,3 +205,67 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 
 	return nil
 }
+
+// incompatibleHosts returns the addresses of contracted hosts that are running
+// a version older than minVersion. If minVersion is empty, no hosts are
+// returned.
+func (r *Renter) incompatibleHosts(minVersion string) []modules.NetAddress {
+	if minVersion == "" {
+		return nil
+	}
+	var hosts []modules.NetAddress
+	for _, c := range r.hostContractor.Contracts() {
+		if build.VersionCmp(c.HostVersion, minVersion) < 0 {
+			hosts = append(hosts, c.NetAddress)
+		}
+	}
+	return hosts
+}
+
+// disallowedHosts returns the addresses of contracted hosts that are not
+// located in any of the given regions. If regions is empty, no hosts are
+// returned.
+func (r *Renter) disallowedHosts(regions []string) []modules.NetAddress {
+	countries, err := modules.ExpandRegions(regions)
+	if err != nil || countries == nil {
+		return nil
+	}
+	var hosts []modules.NetAddress
+	for _, c := range r.hostContractor.Contracts() {
+		if !countries[r.hostDB.HostCountry(c.NetAddress)] {
+			hosts = append(hosts, c.NetAddress)
+		}
+	}
+	return hosts
+}
+
+// excludedHosts returns the addresses of contracted hosts that may not receive
+// pieces of a tracked file, either because of their version or because of
+// their location.
+func (r *Renter) excludedHosts(meta trackedFile) []modules.NetAddress {
+	hosts := r.incompatibleHosts(meta.MinHostVersion)
+	excluded := make(map[modules.NetAddress]bool)
+	for _, addr := range hosts {
+		excluded[addr] = true
+	}
+	for _, addr := range r.disallowedHosts(meta.Regions) {
+		if !excluded[addr] {
+			excluded[addr] = true
+			hosts = append(hosts, addr)
+		}
+	}
+	return hosts
+}
+
+// normalizeRegions returns regions in upper case, without surrounding
+// whitespace. It returns nil if regions is empty.
+func normalizeRegions(regions []string) []string {
+	if len(regions) == 0 {
+		return nil
+	}
+	normalized := make([]string, len(regions))
+	for i, region := range regions {
+		normalized[i] = strings.ToUpper(strings.TrimSpace(region))
+	}
+	return normalized
+}
@@ -2,6 +2,8 @@ package renter
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"io/ioutil"
 	"path/filepath"
 	"sync"
@@ -65,6 +67,7 @@ func (*uploadDownloadContractor) Delete(crypto.Hash) error
 func (*uploadDownloadContractor) Modify(crypto.Hash, crypto.Hash, uint64, []byte) error { return nil }
 func (*uploadDownloadContractor) ContractID() types.FileContractID                      { return types.FileContractID{} }
 func (*uploadDownloadContractor) EndHeight() types.BlockHeight                          { return 10000 }
+func (*uploadDownloadContractor) Spending() types.Currency                              { return types.ZeroCurrency }
 func (*uploadDownloadContractor) Close() error                                          { return nil }
 
 // TestUploadDownload tests the Upload and Download methods using a mock
@@ -111,6 +114,10 @@ func TestUploadDownload(t *testing.T) {
 	if len(files) != 1 {
 		t.Fatal("expected 1 file, got", len(files))
 	}
+	sum := sha256.Sum256(data)
+	if files[0].Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatal("wrong checksum recorded for the file:", files[0].Checksum)
+	}
 
 	// wait for repair loop for fully upload file
 	for i := 0; i < 10 && !files[0].Available; i++ {
@@ -136,3 +143,94 @@ func TestUploadDownload(t *testing.T) {
 		t.Fatal("recovered data does not match original")
 	}
 }
+
+// TestCustomPieceSize checks that customPieceSize only accepts chunk sizes
+// that divide evenly into segment-aligned pieces that fit in a sector.
+func TestCustomPieceSize(t *testing.T) {
+	rsc, _ := NewRSCode(2, 10)
+	tests := []struct {
+		chunkSize uint64
+		pieceSize uint64
+		valid     bool
+	}{
+		{0, 0, false},
+		{crypto.SegmentSize, 0, false},
+		{2*crypto.SegmentSize + 2, 0, false},
+		{2 * crypto.SegmentSize, crypto.SegmentSize, true},
+		{2 * 4096, 4096, true},
+		{2 * (pieceSize + crypto.SegmentSize), 0, false},
+	}
+	for _, test := range tests {
+		size, err := customPieceSize(test.chunkSize, rsc, pieceSize)
+		if test.valid && (err != nil || size != test.pieceSize) {
+			t.Errorf("chunk size %v: expected piece size %v, got %v (%v)", test.chunkSize, test.pieceSize, size, err)
+		} else if !test.valid && err != errInvalidChunkSize {
+			t.Errorf("chunk size %v: expected errInvalidChunkSize, got %v", test.chunkSize, err)
+		}
+	}
+
+	// Pre-encrypted pieces may fill an entire sector.
+	if size, err := customPieceSize(2*modules.SectorSize, rsc, modules.SectorSize); err != nil || size != modules.SectorSize {
+		t.Error("expected a full sector piece size, got", size, err)
+	}
+}
+
+// geoContractor is a mocked hostContractor that has a contract with each of
+// its hosts.
+type geoContractor struct {
+	stubContractor
+	contracts []modules.RenterContract
+}
+
+func (gc geoContractor) Contracts() []modules.RenterContract { return gc.contracts }
+
+// geoHostDB is a mocked hostDB that locates hosts using a map.
+type geoHostDB struct {
+	stubHostDB
+	countries map[modules.NetAddress]string
+}
+
+func (hdb geoHostDB) HostCountry(addr modules.NetAddress) string { return hdb.countries[addr] }
+
+// TestExcludedHosts checks that excludedHosts returns the hosts that are
+// running an old version or are located outside of the permitted regions,
+// without duplicates.
+func TestExcludedHosts(t *testing.T) {
+	r := &Renter{
+		hostContractor: geoContractor{contracts: []modules.RenterContract{
+			{NetAddress: "de", HostVersion: "1.0.0"},
+			{NetAddress: "ch", HostVersion: "1.0.0"},
+			{NetAddress: "unknown", HostVersion: "1.0.0"},
+			{NetAddress: "old-us", HostVersion: "0.5.0"},
+		}},
+		hostDB: geoHostDB{countries: map[modules.NetAddress]string{
+			"de":     "DE",
+			"ch":     "CH",
+			"old-us": "US",
+		}},
+	}
+
+	tests := []struct {
+		meta     trackedFile
+		excluded []modules.NetAddress
+	}{
+		{trackedFile{}, nil},
+		{trackedFile{MinHostVersion: "1.0.0"}, []modules.NetAddress{"old-us"}},
+		{trackedFile{Regions: []string{"EU"}}, []modules.NetAddress{"ch", "unknown", "old-us"}},
+		{trackedFile{Regions: []string{"EU", "CH"}}, []modules.NetAddress{"unknown", "old-us"}},
+		{trackedFile{MinHostVersion: "1.0.0", Regions: []string{"US"}}, []modules.NetAddress{"old-us", "de", "ch", "unknown"}},
+	}
+	for _, test := range tests {
+		excluded := r.excludedHosts(test.meta)
+		if len(excluded) != len(test.excluded) {
+			t.Errorf("%+v: expected %v, got %v", test.meta, test.excluded, excluded)
+			continue
+		}
+		for i := range excluded {
+			if excluded[i] != test.excluded[i] {
+				t.Errorf("%+v: expected %v, got %v", test.meta, test.excluded, excluded)
+				break
+			}
+		}
+	}
+}
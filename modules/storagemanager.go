@@ -19,6 +19,12 @@ type (
 		CapacityRemaining uint64 `json:"capacityremaining"` // bytes
 		Path              string `json:"path"`
 
+		// Missing is set if the storage folder was not found at Path when
+		// the host started, for example because its drive is mounted
+		// somewhere else. The storage folder should be relinked to its new
+		// path.
+		Missing bool `json:"missing"`
+
 		// Below are statistics about the filesystem. FailedReads and
 		// FailedWrites are only incremented if the filesystem is returning
 		// errors when operations are being performed. A large number of
@@ -42,7 +48,9 @@ type (
 		// safely deleted at, though typically the host will manually delete
 		// the sector before the expiry height. The same sector can be added
 		// multiple times at different expiry heights, and the storage manager
-		// is expected to only store the data once.
+		// is expected to only store the data once. Sectors are written after
+		// any waiting reads, and an error is returned if too many writes are
+		// already waiting.
 		AddSector(sectorRoot crypto.Hash, expiryHeight types.BlockHeight, sectorData []byte) error
 
 		// AddSectorBatch is a performance optimization over AddSector when
@@ -55,8 +63,14 @@ type (
 		// AddStorageFolder adds a storage folder to the manager. The manager
 		// may not check that there is enough space available on-disk to
 		// support as much storage as requested, though the manager should
-		// gracefully handle running out of storage unexpectedly.
-		AddStorageFolder(path string, size uint64) error
+		// gracefully handle running out of storage unexpectedly. The
+		// progress of the operation is reported to 'progress'.
+		AddStorageFolder(path string, size uint64, progress ProgressFunc) error
+
+		// BackupMetadata writes a consistent copy of the storage manager's
+		// settings and database to the given directory. The sector data is
+		// not copied.
+		BackupMetadata(dir string) error
 
 		// The storage manager needs to be able to shut down.
 		Close() error
@@ -69,15 +83,33 @@ type (
 		// requests to remove data.
 		DeleteSector(sectorRoot crypto.Hash) error
 
+		// HasSector indicates whether the storage manager is storing the
+		// sector with the given root. HasSector does not read the sector from
+		// disk, making it a cheap way to detect data that is already stored.
+		HasSector(sectorRoot crypto.Hash) bool
+
 		// ReadSector will read a sector from the storage manager, returning the
-		// bytes that match the input sector root.
+		// bytes that match the input sector root. The read is performed ahead
+		// of any calls to AddSector that are waiting for the disk.
 		ReadSector(sectorRoot crypto.Hash) ([]byte, error)
 
+		// ReadSectorPriority is the same as ReadSector, except that the read
+		// is performed ahead of any ordinary reads that are waiting for the
+		// same disk. It is used when building storage proofs that are close
+		// to their deadline. Reads from different disks may be performed in
+		// parallel.
+		ReadSectorPriority(sectorRoot crypto.Hash) ([]byte, error)
+
 		// RemoveSector will remove a sector from the storage manager. The
 		// height at which the sector expires should be provided, so that the
 		// auto-expiry information for that sector can be properly updated.
 		RemoveSector(sectorRoot crypto.Hash, expiryHeight types.BlockHeight) error
 
+		// RelinkStorageFolder points a storage folder that has been moved,
+		// for example to a new mount point, at its new path. The new path
+		// must hold the same storage folder.
+		RelinkStorageFolder(index int, newPath string) error
+
 		// RemoveStorageFolder will remove a storage folder from the manager.
 		// All storage on the folder will be moved to other storage folders,
 		// meaning that no data will be lost. If the manager is unable to save
@@ -95,11 +127,17 @@ type (
 		// folder, any data in the folder that needs to be moved will be placed
 		// into other storage folders, meaning that no data will be lost. If
 		// the manager is unable to migrate the data, an error will be returned
-		// and the operation will be stopped.
-		ResizeStorageFolder(index int, newSize uint64) error
+		// and the operation will be stopped. The progress of the migration is
+		// reported to 'progress'.
+		ResizeStorageFolder(index int, newSize uint64, progress ProgressFunc) error
 
 		// StorageFolders will return a list of storage folders tracked by the
 		// manager.
 		StorageFolders() []StorageFolderMetadata
+
+		// SubscribeEvents returns a channel that receives an event for each
+		// sector read or write that fails, and a function that ends the
+		// subscription.
+		SubscribeEvents() (<-chan Event, func())
 	}
 )
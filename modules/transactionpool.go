@@ -19,6 +19,19 @@ const (
 )
 
 var (
+	// MinimumRecommendedFee and MaximumRecommendedFee are the fee rates, per
+	// byte, that are returned by FeeEstimation.
+	//
+	// TODO: The current minimum has been reduced significantly to account for
+	// legacy renters that are not correctly adding transaction fees. The
+	// minimum has been set to 1 siacoin per kb (or 1/1000 SC per byte), but
+	// really should look more like 10 SC per kb. But, legacy renters are using
+	// a much lower value, which means hosts would be incompatible if the
+	// minimum recommended were set to 10. The value has been set to 1, which
+	// should be okay temporarily while the renters are given time to upgrade.
+	MinimumRecommendedFee = types.SiacoinPrecision.Mul64(1).Div64(1e3)
+	MaximumRecommendedFee = types.SiacoinPrecision.Mul64(5).Div64(1e3)
+
 	// ErrDuplicateTransactionSet is the error that gets returned if a
 	// duplicate transaction set is given to the transaction pool.
 	ErrDuplicateTransactionSet = errors.New("transaction set contains only duplicate transactions")
@@ -51,6 +64,36 @@ var (
 	TransactionPoolDir = "transactionpool"
 )
 
+// A FeeRecommendation is the fee rate that the transaction pool recommends
+// for a transaction set to be confirmed within a given number of blocks.
+type FeeRecommendation struct {
+	Blocks  types.BlockHeight `json:"blocks"`
+	FeeRate types.Currency    `json:"feerate"` // hastings / byte
+}
+
+// TransactionPoolMetrics describes the utilization of the transaction pool
+// and its orphan pool, along with the limits enforced on them. Sizes are in
+// bytes. When a limit of the transaction pool is reached, transaction sets
+// paying the lowest fee rate are evicted to make room for new sets, and new
+// sets that do not pay enough to evict them are rejected.
+type TransactionPoolMetrics struct {
+	Size                int `json:"size"`
+	SizeLimit           int `json:"sizelimit"`
+	TransactionSets     int `json:"transactionsets"`
+	TransactionSetLimit int `json:"transactionsetlimit"`
+	Transactions        int `json:"transactions"`
+	Objects             int `json:"objects"`
+	ObjectLimit         int `json:"objectlimit"`
+
+	Orphans         int `json:"orphans"`
+	OrphanLimit     int `json:"orphanlimit"`
+	OrphanSize      int `json:"orphansize"`
+	OrphanSizeLimit int `json:"orphansizelimit"`
+
+	Evictions  uint64 `json:"evictions"`
+	Rejections uint64 `json:"rejections"`
+}
+
 // A TransactionPoolSubscriber receives updates about the confirmed and
 // unconfirmed set from the transaction pool. Generally, there is no need to
 // subscribe to both the consensus set and the transaction pool.
@@ -61,6 +104,79 @@ type TransactionPoolSubscriber interface {
 	ReceiveUpdatedUnconfirmedTransactions([]types.Transaction, ConsensusChange)
 }
 
+// A TransactionPoolFilteredSubscriber is a TransactionPoolSubscriber that
+// only receives the unconfirmed transactions matching its filter. The
+// consensus change sent to a filtered subscriber only contains the diffs of
+// transaction sets that have at least one matching transaction.
+type TransactionPoolFilteredSubscriber interface {
+	TransactionPoolSubscriber
+
+	// TransactionPoolFilter returns the filter that should be applied to the
+	// next update sent to the subscriber. The filter is requested each time
+	// that the transaction pool changes, so that the subscriber can follow
+	// new addresses and contracts.
+	TransactionPoolFilter() TransactionPoolFilter
+}
+
+// A TransactionPoolFilter describes the categories of unconfirmed
+// transactions that a filtered subscriber is interested in. A transaction
+// matches the filter if it matches any of the categories.
+type TransactionPoolFilter struct {
+	// FileContracts matches all transactions that contain file contracts,
+	// file contract revisions, or storage proofs.
+	FileContracts bool
+
+	// FileContractIDs matches transactions that revise or submit a storage
+	// proof for any of the listed file contracts.
+	FileContractIDs map[types.FileContractID]struct{}
+
+	// UnlockHashes matches transactions that spend from or send to any of the
+	// listed addresses.
+	UnlockHashes map[types.UnlockHash]struct{}
+}
+
+// Matches returns true if the transaction matches the filter.
+func (tpf TransactionPoolFilter) Matches(txn types.Transaction) bool {
+	if tpf.FileContracts && (len(txn.FileContracts) > 0 || len(txn.FileContractRevisions) > 0 || len(txn.StorageProofs) > 0) {
+		return true
+	}
+	if len(tpf.FileContractIDs) > 0 {
+		for _, fcr := range txn.FileContractRevisions {
+			if _, exists := tpf.FileContractIDs[fcr.ParentID]; exists {
+				return true
+			}
+		}
+		for _, sp := range txn.StorageProofs {
+			if _, exists := tpf.FileContractIDs[sp.ParentID]; exists {
+				return true
+			}
+		}
+	}
+	if len(tpf.UnlockHashes) > 0 {
+		for _, sci := range txn.SiacoinInputs {
+			if _, exists := tpf.UnlockHashes[sci.UnlockConditions.UnlockHash()]; exists {
+				return true
+			}
+		}
+		for _, sco := range txn.SiacoinOutputs {
+			if _, exists := tpf.UnlockHashes[sco.UnlockHash]; exists {
+				return true
+			}
+		}
+		for _, sfi := range txn.SiafundInputs {
+			if _, exists := tpf.UnlockHashes[sfi.UnlockConditions.UnlockHash()]; exists {
+				return true
+			}
+		}
+		for _, sfo := range txn.SiafundOutputs {
+			if _, exists := tpf.UnlockHashes[sfo.UnlockHash]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // A TransactionPool manages unconfirmed transactions.
 type TransactionPool interface {
 	// AcceptTransactionSet accepts a set of potentially interdependent
@@ -78,10 +194,19 @@ type TransactionPool interface {
 	// within 10 blocks.
 	FeeEstimation() (minimumRecommended, maximumRecommended types.Currency)
 
+	// FeeRecommendations returns the fee rates that a transaction set needs
+	// to pay to be confirmed within several different numbers of blocks,
+	// given the current contents of the transaction pool.
+	FeeRecommendations() []FeeRecommendation
+
 	// IsStandardTransaction returns `err = nil` if the transaction is
 	// standard, otherwise it returns an error explaining what is not standard.
 	IsStandardTransaction(types.Transaction) error
 
+	// Metrics returns the current utilization of the transaction pool and
+	// the limits that are enforced on it.
+	Metrics() TransactionPoolMetrics
+
 	// PurgeTransactionPool is a temporary function available to the miner. In
 	// the event that a miner mines an unacceptable block, the transaction pool
 	// will be purged to clear out the transaction pool and get rid of the
@@ -96,7 +221,9 @@ type TransactionPool interface {
 
 	// TransactionPoolSubscribe adds a subscriber to the transaction pool.
 	// Subscribers will receive all consensus set changes as well as
-	// transaction pool changes, and should not subscribe to both.
+	// transaction pool changes, and should not subscribe to both. Subscribers
+	// that implement TransactionPoolFilteredSubscriber only receive the
+	// transactions that match their filter.
 	TransactionPoolSubscribe(TransactionPoolSubscriber)
 
 	// Unsubscribe removes a subscriber from the transaction pool.
@@ -15,14 +15,9 @@ import (
 )
 
 const (
-	// The TransactionPoolSizeLimit is first checked, and then a transaction
-	// set is added. The current transaction pool does not do any priority
-	// ordering, so the size limit is such that the transaction pool will never
-	// exceed the size of a block.
-	//
-	// TODO: Add a priority structure that will allow the transaction pool to
-	// fill up beyond the size of a single block, without being subject to
-	// manipulation.
+	// The TransactionPoolSizeLimit is the maximum size of the transaction
+	// pool. When the pool is full, a new transaction set can only be added by
+	// evicting transaction sets that pay a lower fee rate.
 	//
 	// The first ~1/4 of the transaction pool can be filled for free. This is
 	// mostly to preserve compatibility with clients that do not add fees.
@@ -76,25 +71,13 @@ func relatedObjectIDs(ts []types.Transaction) []ObjectID {
 // checkMinerFees checks that the total amount of transaction fees in the
 // transaction set is sufficient to earn a spot in the transaction pool.
 func (tp *TransactionPool) checkMinerFees(ts []types.Transaction) error {
-	// Transactions cannot be added after the TransactionPoolSizeLimit has been
-	// hit.
-	if tp.transactionListSize > TransactionPoolSizeLimit {
-		return errFullTransactionPool
-	}
-
 	// The first TransactionPoolSizeForFee transactions do not need fees.
 	if tp.transactionListSize > TransactionPoolSizeForFee {
 		// Currently required fees are set on a per-transaction basis. 2 coins
 		// are required per transaction if the free-fee limit has been reached,
 		// adding a larger fee is not useful.
-		var feeSum types.Currency
-		for i := range ts {
-			for _, fee := range ts[i].MinerFees {
-				feeSum = feeSum.Add(fee)
-			}
-		}
 		feeRequired := TransactionMinFee.Mul64(uint64(len(ts)))
-		if feeSum.Cmp(feeRequired) < 0 {
+		if totalMinerFees(ts).Cmp(feeRequired) < 0 {
 			return errLowMinerFees
 		}
 	}
@@ -203,9 +186,15 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 	}
 
 	// Check that the transaction set is valid.
-	cc, err := tp.consensusSet.TryTransactionSet(superset)
+	cc, err := tp.tryTransactionSet(superset)
 	if err != nil {
-		return modules.NewConsensusConflict(err.Error())
+		return err
+	}
+
+	// Make room for the superset, evicting cheaper sets if the pool is full.
+	err = tp.makeRoom(superset, supersetMap)
+	if err != nil {
+		return err
 	}
 
 	// Remove the conflicts from the transaction pool. The diffs do not need to
@@ -278,12 +267,21 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) error {
 			conflicts = append(conflicts, conflict)
 		}
 	}
+	if replaced := tp.doubleSpentSets(ts, conflicts); len(replaced) > 0 {
+		return tp.replaceTransactionSets(ts, replaced)
+	}
 	if len(conflicts) > 0 {
 		return tp.handleConflicts(ts, conflicts)
 	}
-	cc, err := tp.consensusSet.TryTransactionSet(ts)
+	cc, err := tp.tryTransactionSet(ts)
 	if err != nil {
-		return modules.NewConsensusConflict(err.Error())
+		return err
+	}
+
+	// Make room for the set, evicting cheaper sets if the pool is full.
+	err = tp.makeRoom(ts, nil)
+	if err != nil {
+		return err
 	}
 
 	// Add the transaction set to the pool.
@@ -297,32 +295,67 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) error {
 	return nil
 }
 
-// AcceptTransaction adds a transaction to the unconfirmed set of
-// transactions. If the transaction is accepted, it will be relayed to
-// connected peers.
-func (tp *TransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
+// managedAcceptTransactionSet adds a transaction set to the pool, relaying it
+// and any orphans that it allowed to be adopted to connected peers. Sets from
+// peers which are orphans are held in the orphan pool, as their parents are
+// likely still propagating through the network. Local sets are expected to
+// have known parents, so a local orphan is treated as a consensus conflict.
+// Local transaction sets are periodically rebroadcast until they are
+// confirmed.
+func (tp *TransactionPool) managedAcceptTransactionSet(ts []types.Transaction, local bool) error {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
 
 	err := tp.acceptTransactionSet(ts)
-	if err != nil {
+	if err == errOrphanTransactionSet && local {
+		return modules.NewConsensusConflict(err.Error())
+	} else if err == errOrphanTransactionSet {
+		tp.addOrphan(ts)
 		return err
+	} else if err != nil {
+		return err
+	}
+	if local {
+		for _, txn := range ts {
+			tp.localTransactions[txn.ID()] = struct{}{}
+		}
 	}
 
-	// Notify subscribers and broadcast the transaction set.
-	go tp.gateway.Broadcast("RelayTransactionSet", ts, tp.gateway.Peers())
+	// The new set may have provided the parents of some orphans. Notify
+	// subscribers and broadcast the new sets.
+	sets := append([][]types.Transaction{ts}, tp.adoptOrphans(createdOutputIDs(ts))...)
+	for _, set := range sets {
+		go tp.gateway.Broadcast("RelayTransactionSet", set, tp.gateway.Peers())
+	}
 	tp.updateSubscribersTransactions()
 	return nil
 }
 
+// AcceptTransaction adds a transaction to the unconfirmed set of
+// transactions. If the transaction is accepted, it will be relayed to
+// connected peers.
+func (tp *TransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
+	return tp.managedAcceptTransactionSet(ts, true)
+}
+
 // relayTransactionSet is an RPC that accepts a transaction set from a peer. If
 // the accept is successful, the transaction will be relayed to the gateway's
 // other peers.
 func (tp *TransactionPool) relayTransactionSet(conn modules.PeerConn) error {
 	var ts []types.Transaction
-	err := encoding.ReadObject(conn, &ts, types.BlockSizeLimit)
+	err := encoding.ReadObjectStream(conn, &ts, types.BlockSizeLimit, types.BlockSizeLimit)
 	if err != nil {
-		return err
+		return modules.DecodeViolation(err)
+	}
+
+	// A transaction that is invalid regardless of the consensus set, such as
+	// one with a bad signature, is a protocol violation. Conflicts with the
+	// consensus set or the pool may just be a race between two peers.
+	height := tp.consensusSet.Height()
+	for _, txn := range ts {
+		if err := txn.StandaloneValid(height); err != nil {
+			return modules.NewProtocolViolation(err)
+		}
 	}
-	return tp.AcceptTransactionSet(ts)
+	return tp.managedAcceptTransactionSet(ts, false)
 }
@@ -102,15 +102,21 @@ func TestIntegrationConflictingTransactionSets(t *testing.T) {
 		t.Error("transaction should not have passed inspection")
 	}
 
-	// Purge and try the sets in the reverse order.
+	// Purge and try the sets in the reverse order. The set that spends the
+	// money in a miner fee pays a higher fee, and should replace the set that
+	// creates a siacoin output.
 	tpt.tpool.PurgeTransactionPool()
 	err = tpt.tpool.AcceptTransactionSet(txnSetDoubleSpend)
 	if err != nil {
 		t.Error(err)
 	}
 	err = tpt.tpool.AcceptTransactionSet(txnSet)
-	if err == nil {
-		t.Error("transaction should not have passed inspection")
+	if err != nil {
+		t.Error(err)
+	}
+	txns := tpt.tpool.TransactionList()
+	if len(txns) != len(txnSet) || txns[txnIndex].ID() != txnSet[txnIndex].ID() {
+		t.Error("replacement transaction set was not swapped into the pool")
 	}
 }
 
@@ -0,0 +1,95 @@
+package transactionpool
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// maxOrphanSets is the maximum number of orphan transaction sets that the
+	// transaction pool will hold while waiting for their parents to arrive.
+	maxOrphanSets = 100
+
+	// maxOrphanSize is the maximum combined size, in bytes, of the orphan
+	// transaction sets held by the transaction pool. Without it, the orphan
+	// pool could hold maxOrphanSets sets of the largest allowed size.
+	maxOrphanSize = 2e6
+
+	// maxReplacedTransactions is the maximum number of transactions that can
+	// be evicted from the pool by a single replacement transaction set.
+	maxReplacedTransactions = 100
+)
+
+var (
+	// maxTransactionSets is the maximum number of transaction sets that the
+	// transaction pool will hold. Together with maxKnownObjects, it bounds the
+	// memory used by the pool's bookkeeping, which is not reflected in the
+	// size limit of the pool; a pool full of tiny sets would otherwise use far
+	// more memory than a pool full of large ones.
+	maxTransactionSets = func() int {
+		switch build.Release {
+		case "dev":
+			return 5e3
+		case "standard":
+			return 10e3
+		case "testing":
+			return 1e3
+		default:
+			panic("unrecognized build.Release in maxTransactionSets")
+		}
+	}()
+
+	// maxKnownObjects is the maximum number of objects, such as siacoin
+	// outputs and file contracts, that the transaction sets in the pool may
+	// create or spend.
+	maxKnownObjects = func() int {
+		switch build.Release {
+		case "dev":
+			return 25e3
+		case "standard":
+			return 50e3
+		case "testing":
+			return 5e3
+		default:
+			panic("unrecognized build.Release in maxKnownObjects")
+		}
+	}()
+
+	// replacementFeeIncrement is the fee rate, in hastings per byte, that a
+	// replacement transaction set must pay on top of the fees of the sets
+	// that it replaces. Without the increment, a set could be replaced over
+	// and over for a negligible cost, consuming the bandwidth of the network.
+	replacementFeeIncrement = types.SiacoinPrecision.Div64(1e4)
+
+	// orphanTimeout is the amount of time that an orphan transaction set is
+	// held before being discarded, if its parents have not arrived.
+	orphanTimeout = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 5 * time.Minute
+		case "standard":
+			return 1 * time.Hour
+		case "testing":
+			return 5 * time.Second
+		default:
+			panic("unrecognized build.Release in orphanTimeout")
+		}
+	}()
+
+	// rebroadcastInterval is the amount of time that is waited between
+	// rebroadcasts of unconfirmed local transactions.
+	rebroadcastInterval = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 1 * time.Minute
+		case "standard":
+			return 10 * time.Minute
+		case "testing":
+			return 500 * time.Millisecond
+		default:
+			panic("unrecognized build.Release in rebroadcastInterval")
+		}
+	}()
+)
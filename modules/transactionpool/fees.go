@@ -0,0 +1,168 @@
+package transactionpool
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// feeRecommendationTargets are the confirmation targets, in blocks, for
+	// which fee recommendations are provided.
+	feeRecommendationTargets = []types.BlockHeight{1, 3, 6, 12}
+)
+
+type (
+	// A prioritizedSet is a transaction set in the pool along with the
+	// information needed to compare its priority against other sets.
+	prioritizedSet struct {
+		id      TransactionSetID
+		feeRate types.Currency
+		size    int
+	}
+
+	// prioritizedSets implements sort.Interface, ordering sets from highest
+	// to lowest fee rate. Sets with equal fee rates are ordered by id so that
+	// the order is deterministic.
+	prioritizedSets []prioritizedSet
+)
+
+func (ps prioritizedSets) Len() int      { return len(ps) }
+func (ps prioritizedSets) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps prioritizedSets) Less(i, j int) bool {
+	if c := ps[i].feeRate.Cmp(ps[j].feeRate); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(ps[i].id[:], ps[j].id[:]) < 0
+}
+
+// prioritizedTransactionSets returns all of the transaction sets in the pool,
+// ordered from highest to lowest fee rate.
+func (tp *TransactionPool) prioritizedTransactionSets() prioritizedSets {
+	ps := make(prioritizedSets, 0, len(tp.transactionSets))
+	for id, ts := range tp.transactionSets {
+		ps = append(ps, prioritizedSet{
+			id:      id,
+			feeRate: modules.CalculateFee(ts),
+			size:    len(encoding.Marshal(ts)),
+		})
+	}
+	sort.Sort(ps)
+	return ps
+}
+
+// prioritizedTransactions returns all of the transactions in the pool, with
+// the transaction sets that pay the highest fee rate first.
+func (tp *TransactionPool) prioritizedTransactions() []types.Transaction {
+	var txns []types.Transaction
+	for _, set := range tp.prioritizedTransactionSets() {
+		txns = append(txns, tp.transactionSets[set.id]...)
+	}
+	return txns
+}
+
+// removeTransactionSet removes a transaction set and all of its objects from
+// the pool.
+func (tp *TransactionPool) removeTransactionSet(id TransactionSetID) {
+	for _, oid := range relatedObjectIDs(tp.transactionSets[id]) {
+		if tp.knownObjects[oid] == id {
+			delete(tp.knownObjects, oid)
+		}
+	}
+	tp.transactionListSize -= len(encoding.Marshal(tp.transactionSets[id]))
+	delete(tp.transactionSets, id)
+	delete(tp.transactionSetDiffs, id)
+}
+
+// makeRoom ensures that there is enough space in the pool to add the provided
+// transaction set. The pool is full if adding the set would exceed the size
+// limit of the pool, maxTransactionSets, or maxKnownObjects. The sets in
+// 'replaced' are about to be removed from the pool, and are neither counted
+// against the limits nor evicted. If the pool is full, sets paying a lower fee
+// rate than the new set are evicted, lowest fee rate first. If evicting every
+// cheaper set would still not make enough room, nothing is evicted and
+// errFullTransactionPool is returned.
+func (tp *TransactionPool) makeRoom(ts []types.Transaction, replaced map[TransactionSetID]struct{}) error {
+	poolSize := tp.transactionListSize + len(encoding.Marshal(ts))
+	numSets := len(tp.transactionSets) + 1
+	numObjects := len(tp.knownObjects) + len(relatedObjectIDs(ts))
+	for id := range replaced {
+		poolSize -= len(encoding.Marshal(tp.transactionSets[id]))
+		numSets--
+		numObjects -= len(relatedObjectIDs(tp.transactionSets[id]))
+	}
+	full := func() bool {
+		return poolSize > TransactionPoolSizeLimit || numSets > maxTransactionSets || numObjects > maxKnownObjects
+	}
+	if !full() {
+		return nil
+	}
+
+	// Walk the sets from lowest to highest fee rate, collecting sets to evict
+	// until the new set fits.
+	feeRate := modules.CalculateFee(ts)
+	sets := tp.prioritizedTransactionSets()
+	var evict []TransactionSetID
+	for i := len(sets) - 1; i >= 0 && full(); i-- {
+		if sets[i].feeRate.Cmp(feeRate) >= 0 {
+			break
+		}
+		if _, exists := replaced[sets[i].id]; exists {
+			continue
+		}
+		evict = append(evict, sets[i].id)
+		poolSize -= sets[i].size
+		numSets--
+		numObjects -= len(relatedObjectIDs(tp.transactionSets[sets[i].id]))
+	}
+	if full() {
+		tp.rejectedSets++
+		return errFullTransactionPool
+	}
+	for _, id := range evict {
+		tp.removeTransactionSet(id)
+	}
+	tp.evictedSets += uint64(len(evict))
+	return nil
+}
+
+// feeRecommendations computes the fee rate required to be confirmed within
+// each of the feeRecommendationTargets. A set is expected to be confirmed
+// within n blocks if it outbids everything beyond the first n blocks worth of
+// the pool. The recommendations never fall below the minimum recommended fee.
+func (tp *TransactionPool) feeRecommendations() []modules.FeeRecommendation {
+	minFee, _ := tp.FeeEstimation()
+	sets := tp.prioritizedTransactionSets()
+
+	var recs []modules.FeeRecommendation
+	for _, target := range feeRecommendationTargets {
+		feeRate := minFee
+		space := int(modules.BlockTransactionCapacity) * int(target)
+		for _, set := range sets {
+			space -= set.size
+			if space < 0 {
+				// The set at the boundary must be outbid.
+				if outbid := set.feeRate.Add(types.NewCurrency64(1)); outbid.Cmp(feeRate) > 0 {
+					feeRate = outbid
+				}
+				break
+			}
+		}
+		recs = append(recs, modules.FeeRecommendation{
+			Blocks:  target,
+			FeeRate: feeRate,
+		})
+	}
+	return recs
+}
+
+// FeeRecommendations returns the fee rates that a transaction set needs to
+// pay to be confirmed within several different numbers of blocks.
+func (tp *TransactionPool) FeeRecommendations() []modules.FeeRecommendation {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.feeRecommendations()
+}
@@ -0,0 +1,247 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// newFeeTestPool returns a bare transaction pool, suitable for testing the
+// fee logic without any dependencies.
+func newFeeTestPool() *TransactionPool {
+	return &TransactionPool{
+		knownObjects:        make(map[ObjectID]TransactionSetID),
+		transactionSets:     make(map[TransactionSetID][]types.Transaction),
+		transactionSetDiffs: make(map[TransactionSetID]modules.ConsensusChange),
+	}
+}
+
+// feeSet returns a transaction set of roughly 'size' bytes that pays the
+// provided miner fee.
+func feeSet(size int, fee types.Currency) []types.Transaction {
+	arbData := make([]byte, size)
+	copy(arbData, modules.PrefixNonSia[:])
+	copy(arbData[16:], fastrand(16))
+	return []types.Transaction{{
+		MinerFees:     []types.Currency{fee},
+		ArbitraryData: [][]byte{arbData},
+	}}
+}
+
+// fastrand returns n random bytes, panicking on error.
+func fastrand(n int) []byte {
+	b, err := crypto.RandBytes(n)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// addFeeSet adds a transaction set directly to the pool, bypassing
+// validation.
+func (tp *TransactionPool) addFeeSet(ts []types.Transaction) TransactionSetID {
+	id := TransactionSetID(crypto.HashObject(ts))
+	tp.transactionSets[id] = ts
+	for _, oid := range relatedObjectIDs(ts) {
+		tp.knownObjects[oid] = id
+	}
+	tp.transactionListSize += len(encoding.Marshal(ts))
+	return id
+}
+
+// TestPrioritizedTransactions checks that transactions are returned in order
+// of decreasing fee rate.
+func TestPrioritizedTransactions(t *testing.T) {
+	tp := newFeeTestPool()
+	low := tp.addFeeSet(feeSet(1e3, types.NewCurrency64(1e3)))
+	high := tp.addFeeSet(feeSet(1e3, types.NewCurrency64(100e3)))
+	mid := tp.addFeeSet(feeSet(1e3, types.NewCurrency64(10e3)))
+
+	txns := tp.prioritizedTransactions()
+	if len(txns) != 3 {
+		t.Fatal("wrong number of transactions:", len(txns))
+	}
+	for i, id := range []TransactionSetID{high, mid, low} {
+		if txns[i].ID() != tp.transactionSets[id][0].ID() {
+			t.Error("transactions are not ordered by fee rate")
+		}
+	}
+}
+
+// TestMakeRoom checks that makeRoom evicts the cheapest transaction sets when
+// the pool is full, and refuses sets that do not outbid enough of the pool.
+func TestMakeRoom(t *testing.T) {
+	tp := newFeeTestPool()
+	low := tp.addFeeSet(feeSet(10e3, types.NewCurrency64(1e3)))
+	high := tp.addFeeSet(feeSet(10e3, types.NewCurrency64(100e6)))
+
+	// Pretend that the rest of the pool is full of sets that cannot be
+	// evicted.
+	tp.transactionListSize = TransactionPoolSizeLimit
+
+	// A set paying a lower fee rate than everything in the pool should be
+	// rejected.
+	err := tp.makeRoom(feeSet(5e3, types.NewCurrency64(1)), nil)
+	if err != errFullTransactionPool {
+		t.Fatal("expected errFullTransactionPool, got", err)
+	}
+	if len(tp.transactionSets) != 2 {
+		t.Fatal("sets were evicted for a rejected set")
+	}
+
+	// A set paying a higher fee rate than the cheapest set should evict only
+	// the cheapest set.
+	err = tp.makeRoom(feeSet(5e3, types.NewCurrency64(10e6)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := tp.transactionSets[low]; exists {
+		t.Error("cheapest set was not evicted")
+	}
+	if _, exists := tp.transactionSets[high]; !exists {
+		t.Error("expensive set was evicted")
+	}
+	for oid, id := range tp.knownObjects {
+		if id == low {
+			t.Error("evicted set still has known objects:", oid)
+		}
+	}
+
+	// A set that needs more room than the cheaper sets can provide should be
+	// rejected without evicting anything.
+	err = tp.makeRoom(feeSet(50e3, types.NewCurrency64(1e9)), nil)
+	if err != errFullTransactionPool {
+		t.Fatal("expected errFullTransactionPool, got", err)
+	}
+	if _, exists := tp.transactionSets[high]; !exists {
+		t.Error("set was evicted for a rejected set")
+	}
+}
+
+// TestMakeRoomSetLimit checks that makeRoom enforces maxTransactionSets, and
+// that evictions and rejections are reported by Metrics.
+func TestMakeRoomSetLimit(t *testing.T) {
+	tp := newFeeTestPool()
+	low := tp.addFeeSet(feeSet(100, types.NewCurrency64(1)))
+	for len(tp.transactionSets) < maxTransactionSets {
+		tp.addFeeSet(feeSet(100, types.NewCurrency64(1e3)))
+	}
+
+	// The pool is far below its size limit, but holds the maximum number of
+	// sets. A set that does not outbid the pool should be rejected.
+	err := tp.makeRoom(feeSet(100, types.NewCurrency64(1)), nil)
+	if err != errFullTransactionPool {
+		t.Fatal("expected errFullTransactionPool, got", err)
+	}
+
+	// A set that outbids the cheapest set should evict it.
+	err = tp.makeRoom(feeSet(100, types.NewCurrency64(1e6)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := tp.transactionSets[low]; exists {
+		t.Error("cheapest set was not evicted")
+	}
+	if len(tp.transactionSets) != maxTransactionSets-1 {
+		t.Error("wrong number of sets evicted:", maxTransactionSets-len(tp.transactionSets))
+	}
+
+	m := tp.Metrics()
+	if m.TransactionSets != maxTransactionSets-1 || m.Transactions != maxTransactionSets-1 {
+		t.Error("metrics report the wrong number of sets:", m.TransactionSets, m.Transactions)
+	}
+	if m.Evictions != 1 || m.Rejections != 1 {
+		t.Error("metrics report the wrong number of evictions and rejections:", m.Evictions, m.Rejections)
+	}
+	if m.Size != tp.transactionListSize || m.Objects != len(tp.knownObjects) {
+		t.Error("metrics do not match the pool:", m)
+	}
+}
+
+// TestFeeRecommendations checks that the fee recommendations reflect the
+// contents of the pool.
+func TestFeeRecommendations(t *testing.T) {
+	tp := newFeeTestPool()
+	minFee, _ := tp.FeeEstimation()
+
+	// An empty pool should recommend the minimum fee for every target.
+	recs := tp.feeRecommendations()
+	if len(recs) != len(feeRecommendationTargets) {
+		t.Fatal("wrong number of recommendations:", len(recs))
+	}
+	for _, rec := range recs {
+		if rec.FeeRate.Cmp(minFee) != 0 {
+			t.Error("empty pool should recommend the minimum fee, got", rec.FeeRate)
+		}
+	}
+
+	// Fill more than one block worth of the pool with expensive
+	// transactions. The one block target should require outbidding them,
+	// while the longer targets should not.
+	var expensive types.Currency
+	for size := 0; size <= int(modules.BlockTransactionCapacity); size += 200e3 {
+		ts := feeSet(200e3, types.SiacoinPrecision.Mul64(200e3))
+		expensive = modules.CalculateFee(ts)
+		tp.addFeeSet(ts)
+	}
+	recs = tp.feeRecommendations()
+	if recs[0].FeeRate.Cmp(expensive) <= 0 {
+		t.Error("one block target does not outbid the pool:", recs[0].FeeRate)
+	}
+	if recs[len(recs)-1].FeeRate.Cmp(minFee) != 0 {
+		t.Error("long target should recommend the minimum fee, got", recs[len(recs)-1].FeeRate)
+	}
+}
+
+// TestCheckReplacement probes the replacement rules of the transaction pool.
+func TestCheckReplacement(t *testing.T) {
+	tp := newFeeTestPool()
+
+	// Create a stuck set and a set that double spends it.
+	parent := types.SiacoinOutputID{1}
+	stuck := feeSet(1e3, types.NewCurrency64(1e3))
+	stuck[0].SiacoinInputs = []types.SiacoinInput{{ParentID: parent}}
+	stuckID := tp.addFeeSet(stuck)
+	unrelatedID := tp.addFeeSet(feeSet(1e3, types.NewCurrency64(1e3)))
+
+	replacement := feeSet(1e3, types.NewCurrency64(1e3))
+	replacement[0].SiacoinInputs = []types.SiacoinInput{{ParentID: parent}}
+	replaced := tp.doubleSpentSets(replacement, []TransactionSetID{stuckID, unrelatedID})
+	if len(replaced) != 1 || replaced[0] != stuckID {
+		t.Fatal("double spent set was not detected:", replaced)
+	}
+
+	// The stuck set itself does not double spend the pool.
+	if len(tp.doubleSpentSets(stuck, []TransactionSetID{stuckID})) != 0 {
+		t.Fatal("set is reported as double spending itself")
+	}
+
+	// A replacement paying the same fee is rejected.
+	if err := tp.checkReplacement(replacement, replaced); err != errLowReplacementFee {
+		t.Fatal("expected errLowReplacementFee, got", err)
+	}
+	// A replacement paying a higher fee rate, but not covering the
+	// increment, is rejected.
+	replacement[0].MinerFees = []types.Currency{types.NewCurrency64(2e3)}
+	if err := tp.checkReplacement(replacement, replaced); err != errLowReplacementFee {
+		t.Fatal("expected errLowReplacementFee, got", err)
+	}
+	// A replacement covering the increment is accepted.
+	replacement[0].MinerFees = []types.Currency{types.SiacoinPrecision}
+	if err := tp.checkReplacement(replacement, replaced); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing too many transactions is rejected.
+	for i := 0; i < maxReplacedTransactions; i++ {
+		stuck = append(stuck, types.Transaction{ArbitraryData: [][]byte{fastrand(16)}})
+	}
+	delete(tp.transactionSets, stuckID)
+	stuckID = tp.addFeeSet(stuck)
+	if err := tp.checkReplacement(replacement, []TransactionSetID{stuckID}); err != errTooManyReplacements {
+		t.Fatal("expected errTooManyReplacements, got", err)
+	}
+}
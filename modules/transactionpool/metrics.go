@@ -0,0 +1,34 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// Metrics returns the current utilization of the transaction pool and its
+// orphan pool, along with the limits that are enforced on them.
+func (tp *TransactionPool) Metrics() modules.TransactionPoolMetrics {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	var numTxns int
+	for _, set := range tp.transactionSets {
+		numTxns += len(set)
+	}
+	return modules.TransactionPoolMetrics{
+		Size:                tp.transactionListSize,
+		SizeLimit:           TransactionPoolSizeLimit,
+		TransactionSets:     len(tp.transactionSets),
+		TransactionSetLimit: maxTransactionSets,
+		Transactions:        numTxns,
+		Objects:             len(tp.knownObjects),
+		ObjectLimit:         maxKnownObjects,
+
+		Orphans:         len(tp.orphans),
+		OrphanLimit:     maxOrphanSets,
+		OrphanSize:      tp.orphanSize(),
+		OrphanSizeLimit: maxOrphanSize,
+
+		Evictions:  tp.evictedSets,
+		Rejections: tp.rejectedSets,
+	}
+}
@@ -0,0 +1,215 @@
+package transactionpool
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errOrphanTransactionSet is returned if a transaction set spends outputs
+	// that are not known to the consensus set or the transaction pool. The
+	// set is held in the orphan pool, and will be added to the transaction
+	// pool if its parents arrive.
+	errOrphanTransactionSet = errors.New("transaction set spends unknown outputs - holding until its parents arrive")
+
+	// errSpentOutput is returned if a transaction set spends an output that
+	// has already been spent by a confirmed transaction.
+	errSpentOutput = modules.NewConsensusConflict("transaction set spends an output that has already been spent on the blockchain")
+)
+
+// An orphanSet is a transaction set that spends outputs which do not exist
+// yet, along with its size in bytes, the time it was received, and the ids of
+// the outputs it spends that are not created by the set itself or by the
+// transaction pool.
+type orphanSet struct {
+	transactions []types.Transaction
+	size         int
+	received     time.Time
+	parents      []types.OutputID
+}
+
+// createdOutputIDs returns the ids of the siacoin and siafund outputs created
+// by a transaction set.
+func createdOutputIDs(ts []types.Transaction) []types.OutputID {
+	var ids []types.OutputID
+	for _, txn := range ts {
+		for i := range txn.SiacoinOutputs {
+			ids = append(ids, types.OutputID(txn.SiacoinOutputID(uint64(i))))
+		}
+		for i := range txn.SiafundOutputs {
+			ids = append(ids, types.OutputID(txn.SiafundOutputID(uint64(i))))
+		}
+	}
+	return ids
+}
+
+// spentOutputIDs returns the ids of the siacoin and siafund outputs spent by
+// a transaction set.
+func spentOutputIDs(ts []types.Transaction) []types.OutputID {
+	var ids []types.OutputID
+	for _, txn := range ts {
+		for _, sci := range txn.SiacoinInputs {
+			ids = append(ids, types.OutputID(sci.ParentID))
+		}
+		for _, sfi := range txn.SiafundInputs {
+			ids = append(ids, types.OutputID(sfi.ParentID))
+		}
+	}
+	return ids
+}
+
+// orphanParents returns the ids of the outputs spent by a transaction set
+// that are created neither by the set nor by a set in the transaction pool.
+// The missing parents of an orphan are among them; the others are confirmed
+// outputs.
+func (tp *TransactionPool) orphanParents(ts []types.Transaction) []types.OutputID {
+	created := make(map[types.OutputID]struct{})
+	for _, id := range createdOutputIDs(ts) {
+		created[id] = struct{}{}
+	}
+	var parents []types.OutputID
+	for _, id := range spentOutputIDs(ts) {
+		if _, exists := created[id]; exists {
+			continue
+		}
+		if _, exists := tp.knownObjects[ObjectID(id)]; exists {
+			continue
+		}
+		parents = append(parents, id)
+	}
+	return parents
+}
+
+// tryTransactionSet checks that a transaction set is valid in the current
+// consensus set. Sets which are invalid only because they spend outputs that
+// do not exist are reported as orphans, unless they spend an output that has
+// already been spent on the blockchain, which can never become valid.
+func (tp *TransactionPool) tryTransactionSet(ts []types.Transaction) (modules.ConsensusChange, error) {
+	cc, err := tp.consensusSet.TryTransactionSet(ts)
+	if err == modules.ErrMissingSiacoinOutput || err == modules.ErrMissingSiafundOutput {
+		var spent bool
+		dbErr := tp.db.View(func(tx *bolt.Tx) error {
+			for _, id := range spentOutputIDs(ts) {
+				if tp.outputSpent(tx, id) {
+					spent = true
+					break
+				}
+			}
+			return nil
+		})
+		if dbErr != nil {
+			return modules.ConsensusChange{}, dbErr
+		} else if spent {
+			return modules.ConsensusChange{}, errSpentOutput
+		}
+		return modules.ConsensusChange{}, errOrphanTransactionSet
+	} else if err != nil {
+		return modules.ConsensusChange{}, modules.NewConsensusConflict(err.Error())
+	}
+	return cc, nil
+}
+
+// orphanSize returns the combined size of the sets in the orphan pool.
+func (tp *TransactionPool) orphanSize() int {
+	var size int
+	for _, orphan := range tp.orphans {
+		size += orphan.size
+	}
+	return size
+}
+
+// removeOrphan removes a transaction set from the orphan pool and from the
+// index of its parents.
+func (tp *TransactionPool) removeOrphan(setID TransactionSetID) {
+	orphan, exists := tp.orphans[setID]
+	if !exists {
+		return
+	}
+	for _, parent := range orphan.parents {
+		delete(tp.orphanParentIndex[parent], setID)
+		if len(tp.orphanParentIndex[parent]) == 0 {
+			delete(tp.orphanParentIndex, parent)
+		}
+	}
+	delete(tp.orphans, setID)
+}
+
+// addOrphan adds a transaction set to the orphan pool, indexed by the outputs
+// that it is waiting for. Orphans that have been held for longer than
+// orphanTimeout are discarded, and if the orphan pool is still full,
+// arbitrary orphans are evicted to make room.
+func (tp *TransactionPool) addOrphan(ts []types.Transaction) {
+	setID := TransactionSetID(crypto.HashObject(ts))
+	if _, exists := tp.orphans[setID]; exists {
+		return
+	}
+	size := len(encoding.Marshal(ts))
+	if size > maxOrphanSize {
+		return
+	}
+	for id, orphan := range tp.orphans {
+		if time.Since(orphan.received) > orphanTimeout {
+			tp.removeOrphan(id)
+		}
+	}
+	orphanSize := tp.orphanSize()
+	for id, orphan := range tp.orphans {
+		if len(tp.orphans) < maxOrphanSets && orphanSize+size <= maxOrphanSize {
+			break
+		}
+		tp.removeOrphan(id)
+		orphanSize -= orphan.size
+	}
+	orphan := orphanSet{
+		transactions: ts,
+		size:         size,
+		received:     time.Now(),
+		parents:      tp.orphanParents(ts),
+	}
+	tp.orphans[setID] = orphan
+	for _, parent := range orphan.parents {
+		if tp.orphanParentIndex[parent] == nil {
+			tp.orphanParentIndex[parent] = make(map[TransactionSetID]struct{})
+		}
+		tp.orphanParentIndex[parent][setID] = struct{}{}
+	}
+}
+
+// adoptOrphans attempts to move the orphan transaction sets that spend the
+// newly created outputs into the transaction pool. Only the orphans waiting
+// for one of the outputs are retried. The outputs created by an adopted
+// orphan may be the parents of other orphans, which are retried in turn.
+// Orphans which have become invalid for any reason other than missing
+// parents, or which have been held for longer than orphanTimeout, are
+// discarded. The adopted sets are returned so that they can be broadcast.
+func (tp *TransactionPool) adoptOrphans(created []types.OutputID) [][]types.Transaction {
+	var adopted [][]types.Transaction
+	for len(created) > 0 {
+		parent := created[0]
+		created = created[1:]
+		for id := range tp.orphanParentIndex[parent] {
+			orphan := tp.orphans[id]
+			if time.Since(orphan.received) > orphanTimeout {
+				tp.removeOrphan(id)
+				continue
+			}
+			err := tp.acceptTransactionSet(orphan.transactions)
+			if err == errOrphanTransactionSet {
+				continue
+			}
+			tp.removeOrphan(id)
+			if err == nil {
+				adopted = append(adopted, orphan.transactions)
+				created = append(created, createdOutputIDs(orphan.transactions)...)
+			}
+		}
+	}
+	return adopted
+}
@@ -0,0 +1,215 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestOrphanAdoption checks that a transaction set received from a peer
+// before its parents is held in the orphan pool, and is added to the
+// transaction pool once its parents arrive.
+func TestOrphanAdoption(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestOrphanAdoption")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create a transaction set where the second transaction depends on the
+	// first.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet) <= 1 {
+		t.Fatal("test is invalid unless the transaction set has two or more transactions")
+	}
+
+	// A local orphan should be rejected outright.
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err == nil || err == errOrphanTransactionSet {
+		t.Fatal("expected a consensus conflict, got", err)
+	}
+	if len(tpt.tpool.orphans) != 0 {
+		t.Fatal("local orphan was added to the orphan pool")
+	}
+
+	// An orphan from a peer should be held.
+	err = tpt.tpool.managedAcceptTransactionSet(txnSet[1:], false)
+	if err != errOrphanTransactionSet {
+		t.Fatal("expected errOrphanTransactionSet, got", err)
+	}
+	if len(tpt.tpool.orphans) != 1 {
+		t.Fatal("orphan was not added to the orphan pool")
+	}
+	for _, id := range createdOutputIDs(txnSet[:1]) {
+		for _, parent := range spentOutputIDs(txnSet[1:]) {
+			if parent == id && len(tpt.tpool.orphanParentIndex[id]) != 1 {
+				t.Fatal("orphan is not indexed by its missing parent")
+			}
+		}
+	}
+
+	// Unrelated sets should not cause the orphan to be retried.
+	_, err = tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.orphans) != 1 {
+		t.Fatal("orphan was removed by an unrelated set")
+	}
+
+	// Submitting the parent should cause the orphan to be adopted.
+	err = tpt.tpool.AcceptTransactionSet(txnSet[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.orphans) != 0 {
+		t.Fatal("orphan was not adopted")
+	}
+	inPool := make(map[types.TransactionID]bool)
+	for _, txn := range tpt.tpool.TransactionList() {
+		inPool[txn.ID()] = true
+	}
+	for _, txn := range txnSet {
+		if !inPool[txn.ID()] {
+			t.Error("transaction missing from the pool after adoption")
+		}
+	}
+}
+
+// TestLocalTransactionSets checks that local transaction sets are tracked for
+// rebroadcast until they are confirmed.
+func TestLocalTransactionSets(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestLocalTransactionSets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	_, err = tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.mu.Lock()
+	sets := tpt.tpool.localTransactionSets()
+	tpt.tpool.mu.Unlock()
+	if len(sets) == 0 {
+		t.Fatal("local transaction was not tracked for rebroadcast")
+	}
+
+	// Once the transactions are confirmed, they should no longer be
+	// rebroadcast.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.mu.Lock()
+	sets = tpt.tpool.localTransactionSets()
+	numLocal := len(tpt.tpool.localTransactions)
+	tpt.tpool.mu.Unlock()
+	if len(sets) != 0 || numLocal != 0 {
+		t.Fatal("confirmed local transactions are still tracked for rebroadcast")
+	}
+}
+
+// TestAddOrphanLimits checks that the orphan pool is limited both in the
+// number of sets and in their combined size.
+func TestAddOrphanLimits(t *testing.T) {
+	tp := newFeeTestPool()
+	tp.orphans = make(map[TransactionSetID]orphanSet)
+	tp.orphanParentIndex = make(map[types.OutputID]map[TransactionSetID]struct{})
+
+	for i := 0; i < maxOrphanSets*2; i++ {
+		tp.addOrphan(feeSet(100, types.NewCurrency64(1)))
+	}
+	if len(tp.orphans) != maxOrphanSets {
+		t.Fatal("orphan pool holds the wrong number of sets:", len(tp.orphans))
+	}
+
+	for i := 0; i < 2*maxOrphanSize/200e3; i++ {
+		tp.addOrphan(feeSet(200e3, types.NewCurrency64(1)))
+	}
+	if size := tp.orphanSize(); size > maxOrphanSize {
+		t.Fatal("orphan pool exceeds its size limit:", size)
+	}
+	if m := tp.Metrics(); m.Orphans != len(tp.orphans) || m.OrphanSize != tp.orphanSize() {
+		t.Fatal("metrics do not match the orphan pool:", m)
+	}
+}
+
+// TestOrphanSpentOutput checks that a transaction set from a peer that spends
+// an output that was already spent on the blockchain is rejected instead of
+// being held in the orphan pool.
+func TestOrphanSpentOutput(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestOrphanSpentOutput")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create two transaction sets that spend the same outputs.
+	fund := types.NewCurrency64(30e6)
+	var sets [2][]types.Transaction
+	for i := range sets {
+		txnBuilder := tpt.wallet.StartTransaction()
+		err = txnBuilder.FundSiacoins(fund)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txnBuilder.AddMinerFee(fund)
+		sets[i], err = txnBuilder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Make the outputs available to the next transaction builder.
+		txnBuilder.Drop()
+	}
+	spent := make(map[types.OutputID]struct{})
+	for _, id := range spentOutputIDs(sets[0]) {
+		spent[id] = struct{}{}
+	}
+	overlap := false
+	for _, id := range spentOutputIDs(sets[1]) {
+		if _, exists := spent[id]; exists {
+			overlap = true
+		}
+	}
+	if !overlap {
+		t.Fatal("test is invalid unless the sets spend the same outputs")
+	}
+
+	// Confirm the first set, then relay the second set from a peer.
+	err = tpt.tpool.AcceptTransactionSet(sets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.managedAcceptTransactionSet(sets[1], false)
+	if err != errSpentOutput {
+		t.Fatal("expected errSpentOutput, got", err)
+	}
+	if len(tpt.tpool.orphans) != 0 {
+		t.Fatal("double spend was added to the orphan pool")
+	}
+}
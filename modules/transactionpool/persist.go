@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
@@ -21,6 +22,18 @@ var (
 	// been confirmed on the blockchain.
 	bucketConfirmedTransactions = []byte("ConfirmedTransactions")
 
+	// bucketSpentOutputs holds the ids of every siacoin and siafund output
+	// that has been spent by a confirmed transaction.
+	bucketSpentOutputs = []byte("SpentOutputs")
+
+	// bucketLocalTransactions holds the ids of the local transactions that
+	// were unconfirmed when the transaction pool was shut down.
+	bucketLocalTransactions = []byte("LocalTransactions")
+
+	// bucketTransactionSets holds the transaction sets that were in the
+	// transaction pool when it was shut down, mapped from their set id.
+	bucketTransactionSets = []byte("TransactionSets")
+
 	// errNilConsensusChange is returned if there is no consensus change in the
 	// database.
 	errNilConsensusChange = errors.New("no consensus change found")
@@ -32,16 +45,17 @@ var (
 
 // resetDB deletes all consensus related persistence from the transaction pool.
 func (tp *TransactionPool) resetDB(tx *bolt.Tx) error {
-	err := tx.DeleteBucket(bucketConfirmedTransactions)
-	if err != nil {
-		return err
-	}
-	err = tp.putRecentConsensusChange(tx, modules.ConsensusChangeBeginning)
-	if err != nil {
-		return err
+	for _, bucket := range [][]byte{bucketConfirmedTransactions, bucketSpentOutputs} {
+		err := tx.DeleteBucket(bucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket(bucket)
+		if err != nil {
+			return err
+		}
 	}
-	_, err = tx.CreateBucket(bucketConfirmedTransactions)
-	return err
+	return tp.putRecentConsensusChange(tx, modules.ConsensusChangeBeginning)
 }
 
 // initPersist creates buckets in the database
@@ -61,10 +75,17 @@ func (tp *TransactionPool) initPersist() error {
 	// Create the database and get the most recent consensus change.
 	var cc modules.ConsensusChangeID
 	err = tp.db.Update(func(tx *bolt.Tx) error {
+		// COMPAT: databases created before spent outputs were tracked need to
+		// rescan the blockchain to fill in the spent outputs.
+		rescan := tx.Bucket(bucketConfirmedTransactions) != nil && tx.Bucket(bucketSpentOutputs) == nil
+
 		// Create the database buckets.
 		buckets := [][]byte{
 			bucketRecentConsensusChange,
 			bucketConfirmedTransactions,
+			bucketSpentOutputs,
+			bucketLocalTransactions,
+			bucketTransactionSets,
 		}
 		for _, bucket := range buckets {
 			_, err := tx.CreateBucketIfNotExists(bucket)
@@ -73,6 +94,11 @@ func (tp *TransactionPool) initPersist() error {
 			}
 		}
 
+		if rescan {
+			cc = modules.ConsensusChangeBeginning
+			return tp.resetDB(tx)
+		}
+
 		// Get the recent consensus change.
 		cc, err = tp.getRecentConsensusChange(tx)
 		if err == errNilConsensusChange {
@@ -100,6 +126,83 @@ func (tp *TransactionPool) initPersist() error {
 	return err
 }
 
+// saveTransactionSets replaces the transaction sets stored in the database
+// with the sets currently in the pool, so that they can be restored when the
+// transaction pool is next started.
+func (tp *TransactionPool) saveTransactionSets() error {
+	return tp.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketLocalTransactions, bucketTransactionSets} {
+			err := tx.DeleteBucket(bucket)
+			if err != nil {
+				return err
+			}
+			_, err = tx.CreateBucket(bucket)
+			if err != nil {
+				return err
+			}
+		}
+
+		for id, ts := range tp.transactionSets {
+			err := tx.Bucket(bucketTransactionSets).Put(id[:], encoding.Marshal(ts))
+			if err != nil {
+				return err
+			}
+		}
+		for txid := range tp.localTransactions {
+			err := tx.Bucket(bucketLocalTransactions).Put(txid[:], []byte{})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadTransactionSets adds the transaction sets that were saved when the
+// transaction pool was last shut down back into the pool. Sets which have
+// been confirmed or are no longer valid are discarded. Local transactions
+// continue to be rebroadcast until they are confirmed.
+func (tp *TransactionPool) loadTransactionSets() error {
+	var sets [][]types.Transaction
+	local := make(map[types.TransactionID]struct{})
+	err := tp.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(bucketTransactionSets).ForEach(func(_, setBytes []byte) error {
+			var ts []types.Transaction
+			if err := encoding.Unmarshal(setBytes, &ts); err != nil {
+				return err
+			}
+			sets = append(sets, ts)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketLocalTransactions).ForEach(func(txidBytes, _ []byte) error {
+			var txid types.TransactionID
+			copy(txid[:], txidBytes)
+			local[txid] = struct{}{}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range sets {
+		// Errors are ignored, as sets may have been confirmed or invalidated
+		// while the transaction pool was offline.
+		if tp.acceptTransactionSet(ts) != nil {
+			continue
+		}
+		for _, txn := range ts {
+			if _, exists := local[txn.ID()]; exists {
+				tp.localTransactions[txn.ID()] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
 // getRecentConsensusChange returns the most recent consensus change from the
 // database.
 func (tp *TransactionPool) getRecentConsensusChange(tx *bolt.Tx) (cc modules.ConsensusChangeID, err error) {
@@ -138,3 +241,32 @@ func (tp *TransactionPool) addTransaction(tx *bolt.Tx, id types.TransactionID) e
 func (tp *TransactionPool) deleteTransaction(tx *bolt.Tx, id types.TransactionID) error {
 	return tx.Bucket(bucketConfirmedTransactions).Delete(id[:])
 }
+
+// outputSpent returns true if the output has been spent by a confirmed
+// transaction.
+func (tp *TransactionPool) outputSpent(tx *bolt.Tx, id types.OutputID) bool {
+	return tx.Bucket(bucketSpentOutputs).Get(id[:]) != nil
+}
+
+// addSpentOutputs records the outputs spent by a confirmed transaction.
+func (tp *TransactionPool) addSpentOutputs(tx *bolt.Tx, txn types.Transaction) error {
+	for _, id := range spentOutputIDs([]types.Transaction{txn}) {
+		err := tx.Bucket(bucketSpentOutputs).Put(id[:], []byte{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSpentOutputs removes the outputs spent by a transaction that is no
+// longer confirmed.
+func (tp *TransactionPool) deleteSpentOutputs(tx *bolt.Tx, txn types.Transaction) error {
+	for _, id := range spentOutputIDs([]types.Transaction{txn}) {
+		err := tx.Bucket(bucketSpentOutputs).Delete(id[:])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
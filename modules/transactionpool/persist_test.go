@@ -93,3 +93,66 @@ func TestRescan(t *testing.T) {
 		t.Fatal("expecting modules.ErrDuplicateTransactionSet, got:", err)
 	}
 }
+
+// TestPersistTransactionSets checks that unconfirmed transaction sets are
+// restored after the transaction pool is restarted.
+func TestPersistTransactionSets(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	tpt, err := createTpoolTester("TestPersistTransactionSets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create a valid transaction set using the wallet.
+	txns, err := tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.transactionSets) != 1 {
+		t.Fatal("sending coins did not increase the transaction sets by 1")
+	}
+
+	// Restart the tpool. The transaction set should be restored, and should
+	// still be treated as local.
+	persistDir := tpt.tpool.persistDir
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool, err = New(tpt.cs, tpt.gateway, persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.transactionSets) != 1 {
+		t.Fatal("transaction set was not restored after restart")
+	}
+	err = tpt.tpool.AcceptTransactionSet(txns)
+	if err != modules.ErrDuplicateTransactionSet {
+		t.Fatal("expecting modules.ErrDuplicateTransactionSet, got:", err)
+	}
+	if _, exists := tpt.tpool.localTransactions[txns[len(txns)-1].ID()]; !exists {
+		t.Fatal("local transaction was not restored as local")
+	}
+
+	// Mine the transaction into a block and restart the tpool again. The
+	// confirmed set should not be restored.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool, err = New(tpt.cs, tpt.gateway, persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.transactionSets) != 0 {
+		t.Fatal("confirmed transaction set was restored after restart")
+	}
+}
@@ -0,0 +1,64 @@
+package transactionpool
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// localTransactionSets returns every transaction set in the pool that contains
+// a local transaction. Local transactions which are no longer in the pool or
+// the orphan pool were dropped, and are forgotten.
+func (tp *TransactionPool) localTransactionSets() [][]types.Transaction {
+	known := make(map[types.TransactionID]struct{})
+	var sets [][]types.Transaction
+	for _, ts := range tp.transactionSets {
+		local := false
+		for _, txn := range ts {
+			txid := txn.ID()
+			known[txid] = struct{}{}
+			if _, exists := tp.localTransactions[txid]; exists {
+				local = true
+			}
+		}
+		if local {
+			sets = append(sets, ts)
+		}
+	}
+	for _, orphan := range tp.orphans {
+		for _, txn := range orphan.transactions {
+			known[txn.ID()] = struct{}{}
+		}
+	}
+	for txid := range tp.localTransactions {
+		if _, exists := known[txid]; !exists {
+			delete(tp.localTransactions, txid)
+		}
+	}
+	return sets
+}
+
+// threadedRebroadcast periodically rebroadcasts the unconfirmed local
+// transaction sets, in case they were dropped by the peers that originally
+// received them.
+func (tp *TransactionPool) threadedRebroadcast() {
+	if err := tp.tg.AddNamed("threadedRebroadcast"); err != nil {
+		return
+	}
+	defer tp.tg.DoneNamed("threadedRebroadcast")
+
+	for {
+		select {
+		case <-tp.tg.StopChan():
+			return
+		case <-time.After(rebroadcastInterval):
+		}
+
+		tp.mu.Lock()
+		sets := tp.localTransactionSets()
+		tp.mu.Unlock()
+		for _, set := range sets {
+			tp.gateway.Broadcast("RelayTransactionSet", set, tp.gateway.Peers())
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package transactionpool
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errLowReplacementFee   = errors.New("replacement transaction set does not pay enough fees to replace the conflicting transaction sets")
+	errTooManyReplacements = errors.New("replacement transaction set conflicts with too many transactions")
+)
+
+// spentObjectIDs returns the ids of all the objects that are consumed by a
+// transaction. Two transactions that consume the same object are double
+// spends of each other.
+func spentObjectIDs(t types.Transaction) []ObjectID {
+	var oids []ObjectID
+	for _, sci := range t.SiacoinInputs {
+		oids = append(oids, ObjectID(sci.ParentID))
+	}
+	for _, fcr := range t.FileContractRevisions {
+		oids = append(oids, ObjectID(fcr.ParentID))
+	}
+	for _, sp := range t.StorageProofs {
+		oids = append(oids, ObjectID(sp.ParentID))
+	}
+	for _, sfi := range t.SiafundInputs {
+		oids = append(oids, ObjectID(sfi.ParentID))
+	}
+	return oids
+}
+
+// doubleSpentSets returns the ids of the conflicting transaction sets that
+// spend an object which is also spent by a different transaction in ts.
+// Conflicts which are the parents of ts, or which contain the same
+// transactions as ts, are not double spends and are not returned.
+func (tp *TransactionPool) doubleSpentSets(ts []types.Transaction, conflicts []TransactionSetID) []TransactionSetID {
+	type spender struct {
+		setID TransactionSetID
+		txid  types.TransactionID
+	}
+	spenders := make(map[ObjectID]spender)
+	for _, conflict := range conflicts {
+		for _, txn := range tp.transactionSets[conflict] {
+			txid := txn.ID()
+			for _, oid := range spentObjectIDs(txn) {
+				spenders[oid] = spender{setID: conflict, txid: txid}
+			}
+		}
+	}
+
+	doubleSpent := make(map[TransactionSetID]struct{})
+	var ids []TransactionSetID
+	for _, txn := range ts {
+		txid := txn.ID()
+		for _, oid := range spentObjectIDs(txn) {
+			s, exists := spenders[oid]
+			if !exists || s.txid == txid {
+				continue
+			}
+			if _, exists := doubleSpent[s.setID]; !exists {
+				doubleSpent[s.setID] = struct{}{}
+				ids = append(ids, s.setID)
+			}
+		}
+	}
+	return ids
+}
+
+// checkReplacement checks that ts is allowed to replace the provided
+// transaction sets. The replacement must pay a strictly higher fee rate than
+// each of the sets it replaces, and its total fees must cover the fees of the
+// replaced sets plus replacementFeeIncrement for each of its own bytes. A
+// single replacement may not evict more than maxReplacedTransactions
+// transactions.
+func (tp *TransactionPool) checkReplacement(ts []types.Transaction, replaced []TransactionSetID) error {
+	feeRate := modules.CalculateFee(ts)
+	var replacedFees types.Currency
+	var replacedTxns int
+	for _, id := range replaced {
+		set := tp.transactionSets[id]
+		if modules.CalculateFee(set).Cmp(feeRate) >= 0 {
+			return errLowReplacementFee
+		}
+		replacedFees = replacedFees.Add(totalMinerFees(set))
+		replacedTxns += len(set)
+	}
+	if replacedTxns > maxReplacedTransactions {
+		return errTooManyReplacements
+	}
+
+	size := len(encoding.Marshal(ts))
+	required := replacedFees.Add(replacementFeeIncrement.Mul64(uint64(size)))
+	if totalMinerFees(ts).Cmp(required) < 0 {
+		return errLowReplacementFee
+	}
+	return nil
+}
+
+// replaceTransactionSets replaces the provided transaction sets with ts. If
+// ts is not accepted after the replaced sets have been removed, the replaced
+// sets are restored to the pool.
+func (tp *TransactionPool) replaceTransactionSets(ts []types.Transaction, replaced []TransactionSetID) error {
+	err := tp.checkReplacement(ts, replaced)
+	if err != nil {
+		return err
+	}
+
+	// Remove the replaced sets, keeping them around in case they need to be
+	// restored.
+	removedSets := make(map[TransactionSetID][]types.Transaction)
+	removedDiffs := make(map[TransactionSetID]modules.ConsensusChange)
+	for _, id := range replaced {
+		removedSets[id] = tp.transactionSets[id]
+		removedDiffs[id] = tp.transactionSetDiffs[id]
+		tp.removeTransactionSet(id)
+	}
+
+	err = tp.acceptTransactionSet(ts)
+	if err != nil {
+		for id, set := range removedSets {
+			tp.transactionSets[id] = set
+			tp.transactionSetDiffs[id] = removedDiffs[id]
+			for _, oid := range relatedObjectIDs(set) {
+				tp.knownObjects[oid] = id
+			}
+			tp.transactionListSize += len(encoding.Marshal(set))
+		}
+		return err
+	}
+	return nil
+}
+
+// totalMinerFees returns the sum of the miner fees in a transaction set.
+func totalMinerFees(ts []types.Transaction) types.Currency {
+	var sum types.Currency
+	for _, t := range ts {
+		for _, fee := range t.MinerFees {
+			sum = sum.Add(fee)
+		}
+	}
+	return sum
+}
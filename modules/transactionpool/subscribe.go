@@ -5,25 +5,55 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
-// updateSubscribersTransactions sends a new transaction pool update to all
-// subscribers.
-func (tp *TransactionPool) updateSubscribersTransactions() {
+// subscriberUpdate returns the transactions and consensus change that should
+// be sent to a subscriber, given the prioritized transaction sets of the
+// pool. Filtered subscribers only receive the transactions that match their
+// filter, along with the diffs of the sets containing those transactions.
+func (tp *TransactionPool) subscriberUpdate(subscriber modules.TransactionPoolSubscriber, sets prioritizedSets) ([]types.Transaction, modules.ConsensusChange) {
+	fs, filtered := subscriber.(modules.TransactionPoolFilteredSubscriber)
+	var filter modules.TransactionPoolFilter
+	if filtered {
+		filter = fs.TransactionPoolFilter()
+	}
+
 	var txns []types.Transaction
 	var cc modules.ConsensusChange
-	for _, tSet := range tp.transactionSets {
-		txns = append(txns, tSet...)
-	}
-	for _, tSetDiff := range tp.transactionSetDiffs {
-		cc = cc.Append(tSetDiff)
+	for _, set := range sets {
+		ts := tp.transactionSets[set.id]
+		if !filtered {
+			txns = append(txns, ts...)
+			cc = cc.Append(tp.transactionSetDiffs[set.id])
+			continue
+		}
+
+		matched := false
+		for _, txn := range ts {
+			if filter.Matches(txn) {
+				txns = append(txns, txn)
+				matched = true
+			}
+		}
+		if matched {
+			cc = cc.Append(tp.transactionSetDiffs[set.id])
+		}
 	}
+	return txns, cc
+}
+
+// updateSubscribersTransactions sends a new transaction pool update to all
+// subscribers.
+func (tp *TransactionPool) updateSubscribersTransactions() {
+	sets := tp.prioritizedTransactionSets()
 	for _, subscriber := range tp.subscribers {
-		subscriber.ReceiveUpdatedUnconfirmedTransactions(txns, cc)
+		subscriber.ReceiveUpdatedUnconfirmedTransactions(tp.subscriberUpdate(subscriber, sets))
 	}
 }
 
 // TransactionPoolSubscribe adds a subscriber to the transaction pool.
 // Subscribers will receive the full transaction set every time there is a
-// significant change to the transaction pool.
+// significant change to the transaction pool. Subscribers implementing
+// modules.TransactionPoolFilteredSubscriber only receive the transactions
+// that match their filter.
 func (tp *TransactionPool) TransactionPoolSubscribe(subscriber modules.TransactionPoolSubscriber) {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
@@ -32,15 +62,7 @@ func (tp *TransactionPool) TransactionPoolSubscribe(subscriber modules.Transacti
 	tp.subscribers = append(tp.subscribers, subscriber)
 
 	// Send the new subscriber the transaction pool set.
-	var txns []types.Transaction
-	for _, tSet := range tp.transactionSets {
-		txns = append(txns, tSet...)
-	}
-	var cc modules.ConsensusChange
-	for _, tSetDiff := range tp.transactionSetDiffs {
-		cc = cc.Append(tSetDiff)
-	}
-	subscriber.ReceiveUpdatedUnconfirmedTransactions(txns, cc)
+	subscriber.ReceiveUpdatedUnconfirmedTransactions(tp.subscriberUpdate(subscriber, tp.prioritizedTransactionSets()))
 }
 
 // Unsubscribe removes a subscriber from the transaction pool. If the
@@ -69,3 +69,62 @@ func TestSubscription(t *testing.T) {
 		t.Error("transaction pool failed to unsubscribe mock subscriber")
 	}
 }
+
+// mockFilteredSubscriber is a mockSubscriber that only receives the
+// transactions matching its filter.
+type mockFilteredSubscriber struct {
+	mockSubscriber
+	filter modules.TransactionPoolFilter
+}
+
+// TransactionPoolFilter returns the filter of the mockFilteredSubscriber,
+// allowing it to satisfy the modules.TransactionPoolFilteredSubscriber
+// interface.
+func (mfs *mockFilteredSubscriber) TransactionPoolFilter() modules.TransactionPoolFilter {
+	return mfs.filter
+}
+
+// TestFilteredSubscription checks that filtered subscribers only receive the
+// transactions that match their filter.
+func TestFilteredSubscription(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	tpt, err := createTpoolTester("TestFilteredSubscription")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Subscribe one subscriber that watches the destination address and one
+	// that watches an unrelated address.
+	dest := types.UnlockHash{1}
+	watching := &mockFilteredSubscriber{
+		filter: modules.TransactionPoolFilter{
+			UnlockHashes: map[types.UnlockHash]struct{}{dest: {}},
+		},
+	}
+	ignoring := &mockFilteredSubscriber{
+		filter: modules.TransactionPoolFilter{
+			UnlockHashes: map[types.UnlockHash]struct{}{{2}: {}},
+		},
+	}
+	tpt.tpool.TransactionPoolSubscribe(watching)
+	tpt.tpool.TransactionPoolSubscribe(ignoring)
+
+	_, err = tpt.wallet.SendSiacoins(types.NewCurrency64(100), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watching.txns) == 0 {
+		t.Fatal("filtered subscriber did not receive a matching transaction")
+	}
+	for _, txn := range watching.txns {
+		if !watching.filter.Matches(txn) {
+			t.Fatal("filtered subscriber received a transaction that does not match its filter")
+		}
+	}
+	if len(ignoring.txns) != 0 {
+		t.Fatal("filtered subscriber received transactions that do not match its filter")
+	}
+}
@@ -5,9 +5,11 @@ import (
 
 	"github.com/NebulousLabs/demotemutex"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
+	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -56,6 +58,13 @@ type (
 		transactionSets     map[TransactionSetID][]types.Transaction
 		transactionSetDiffs map[TransactionSetID]modules.ConsensusChange
 		transactionListSize int
+
+		// evictedSets and rejectedSets count the transaction sets that have
+		// been evicted from the pool to make room for sets paying a higher
+		// fee rate, and the sets that were rejected because the pool was
+		// full.
+		evictedSets  uint64
+		rejectedSets uint64
 		// TODO: Write a consistency check comparing transactionSets,
 		// transactionSetDiffs.
 		//
@@ -68,10 +77,20 @@ type (
 		// subscriber.
 		subscribers []modules.TransactionPoolSubscriber
 
+		// Transaction sets that spend outputs which are not yet known are
+		// held in the orphan pool until their parents arrive, and are indexed
+		// by the ids of the outputs they are waiting for. Transactions that
+		// were submitted locally are tracked so that they can be rebroadcast
+		// until they are confirmed.
+		localTransactions map[types.TransactionID]struct{}
+		orphans           map[TransactionSetID]orphanSet
+		orphanParentIndex map[types.OutputID]map[TransactionSetID]struct{}
+
 		// Utilities.
 		db         *persist.BoltDatabase
 		mu         demotemutex.DemoteMutex
 		persistDir string
+		tg         siasync.ThreadGroup
 	}
 )
 
@@ -94,8 +113,13 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string) (*Transa
 		transactionSets:     make(map[TransactionSetID][]types.Transaction),
 		transactionSetDiffs: make(map[TransactionSetID]modules.ConsensusChange),
 
+		localTransactions: make(map[types.TransactionID]struct{}),
+		orphans:           make(map[TransactionSetID]orphanSet),
+		orphanParentIndex: make(map[types.OutputID]map[TransactionSetID]struct{}),
+
 		persistDir: persistDir,
 	}
+	tp.tg.SetName("transactionpool")
 
 	// Open the tpool database.
 	err := tp.initPersist()
@@ -103,15 +127,36 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string) (*Transa
 		return nil, err
 	}
 
+	// Restore the transaction sets that were in the pool at shutdown.
+	err = tp.loadTransactionSets()
+	if err != nil {
+		return nil, err
+	}
+
 	// Register RPCs
 	g.RegisterRPC("RelayTransactionSet", tp.relayTransactionSet)
+
+	// Let the consensus set rebuild relayed blocks from the pool.
+	cs.SetTransactionSource(tp)
+
+	go tp.threadedRebroadcast()
 	return tp, nil
 }
 
 func (tp *TransactionPool) Close() error {
+	err := tp.tg.Stop()
+	if err != nil {
+		return err
+	}
 	tp.gateway.UnregisterRPC("RelayTransactionSet")
 	tp.consensusSet.Unsubscribe(tp)
-	return tp.db.Close()
+	tp.consensusSet.SetTransactionSource(nil)
+
+	// Save the unconfirmed transaction sets so that they survive a restart.
+	tp.mu.Lock()
+	saveErr := tp.saveTransactionSets()
+	tp.mu.Unlock()
+	return build.JoinErrors([]error{saveErr, tp.db.Close()}, "; ")
 }
 
 // FeeEstimation returns an estimation for what fee should be applied to
@@ -120,24 +165,14 @@ func (tp *TransactionPool) FeeEstimation() (min, max types.Currency) {
 	// TODO: The fee estimation tool should look at the recent blocks and use
 	// them to guage what sort of fee should be required, as opposed to just
 	// guessing blindly.
-	//
-	// TODO: The current minimum has been reduced significantly to account for
-	// legacy renters that are not correctly adding transaction fees. The
-	// minimum has been set to 1 siacoin per kb (or 1/1000 SC per byte), but
-	// really should look more like 10 SC per kb. But, legacy renters are using
-	// a much lower value, which means hosts would be incompatible if the
-	// minimum recommended were set to 10. The value has been set to 1, which
-	// should be okay temporarily while the renters are given time to upgrade.
-	return types.SiacoinPrecision.Mul64(1).Div64(1e3), types.SiacoinPrecision.Mul64(5).Div64(1e3)
+	return modules.MinimumRecommendedFee, modules.MaximumRecommendedFee
 }
 
 // TransactionList returns a list of all transactions in the transaction pool.
 // The transactions are provided in an order that can acceptably be put into a
-// block.
+// block, with the transaction sets paying the highest fee rate first.
 func (tp *TransactionPool) TransactionList() []types.Transaction {
-	var txns []types.Transaction
-	for _, tSet := range tp.transactionSets {
-		txns = append(txns, tSet...)
-	}
-	return txns
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return tp.prioritizedTransactions()
 }
@@ -28,6 +28,10 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 				if err != nil {
 					return err
 				}
+				err = tp.deleteSpentOutputs(tx, txn)
+				if err != nil {
+					return err
+				}
 			}
 		}
 		for _, block := range cc.AppliedBlocks {
@@ -36,6 +40,10 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 				if err != nil {
 					return err
 				}
+				err = tp.addSpentOutputs(tx, txn)
+				if err != nil {
+					return err
+				}
 			}
 		}
 		return tp.putRecentConsensusChange(tx, cc.ID)
@@ -111,6 +119,27 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 		tp.acceptTransactionSet(set) // Error is not checked.
 	}
 
+	// Confirmed local transactions no longer need to be rebroadcast.
+	for txid := range txids {
+		delete(tp.localTransactions, txid)
+	}
+
+	// The consensus change may have provided the parents of some orphans.
+	var created []types.OutputID
+	for _, diff := range cc.SiacoinOutputDiffs {
+		if diff.Direction == modules.DiffApply {
+			created = append(created, types.OutputID(diff.ID))
+		}
+	}
+	for _, diff := range cc.SiafundOutputDiffs {
+		if diff.Direction == modules.DiffApply {
+			created = append(created, types.OutputID(diff.ID))
+		}
+	}
+	for _, set := range tp.adoptOrphans(created) {
+		go tp.gateway.Broadcast("RelayTransactionSet", set, tp.gateway.Peers())
+	}
+
 	// Inform subscribers that an update has executed.
 	tp.mu.Demote()
 	tp.updateSubscribersTransactions()
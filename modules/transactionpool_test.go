@@ -106,3 +106,43 @@ func TestCalculateFee(t *testing.T) {
 		t.Error("got the wrong fee for a multi transaction set")
 	}
 }
+
+// TestTransactionPoolFilterMatches probes the Matches method of the
+// TransactionPoolFilter.
+func TestTransactionPoolFilterMatches(t *testing.T) {
+	t.Parallel()
+
+	fcid := types.FileContractID{1}
+	addr := types.UnlockHash{2}
+	revision := types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{{ParentID: fcid}},
+	}
+	payment := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{UnlockHash: addr}},
+	}
+
+	// An empty filter matches nothing.
+	var tpf TransactionPoolFilter
+	if tpf.Matches(revision) || tpf.Matches(payment) {
+		t.Error("empty filter matched a transaction")
+	}
+
+	tpf.FileContracts = true
+	if !tpf.Matches(revision) || tpf.Matches(payment) {
+		t.Error("file contract filter is not matching correctly")
+	}
+
+	tpf = TransactionPoolFilter{FileContractIDs: map[types.FileContractID]struct{}{fcid: {}}}
+	if !tpf.Matches(revision) || tpf.Matches(payment) {
+		t.Error("file contract id filter is not matching correctly")
+	}
+	tpf.FileContractIDs = map[types.FileContractID]struct{}{{3}: {}}
+	if tpf.Matches(revision) {
+		t.Error("file contract id filter matched an unrelated revision")
+	}
+
+	tpf = TransactionPoolFilter{UnlockHashes: map[types.UnlockHash]struct{}{addr: {}}}
+	if tpf.Matches(revision) || !tpf.Matches(payment) {
+		t.Error("unlock hash filter is not matching correctly")
+	}
+}
@@ -45,6 +45,14 @@ var (
 	// ErrLockedWallet is returned when an action cannot be performed due to
 	// the wallet being locked.
 	ErrLockedWallet = errors.New("wallet must be unlocked before it can be used")
+
+	// KeyPurposeRenterContract is the purpose used by the renter when
+	// deriving the keys that sign its file contracts.
+	KeyPurposeRenterContract = types.Specifier{'r', 'e', 'n', 't', 'e', 'r', ' ', 'c', 'o', 'n', 't', 'r', 'a', 'c', 't'}
+
+	// KeyPurposeHostSigning is the purpose used by the host when deriving
+	// the key that it announces and signs contract revisions with.
+	KeyPurposeHostSigning = types.Specifier{'h', 'o', 's', 't', ' ', 's', 'i', 'g', 'n', 'i', 'n', 'g'}
 )
 
 type (
@@ -102,6 +110,36 @@ type (
 		Outputs []ProcessedOutput `json:"outputs"`
 	}
 
+	// A ScheduledPayment is a siacoin payment that the wallet has signed,
+	// but holds until the blockchain reaches a certain height. The signatures
+	// of the payment are timelocked, so the payment is not valid before that
+	// height even if it is broadcast by someone else. The outputs funding the
+	// payment are not spent by the wallet in the meantime.
+	//
+	// ID is the ID of the transaction that pays the destination, which is the
+	// last transaction in the set.
+	ScheduledPayment struct {
+		ID           types.TransactionID `json:"id"`
+		Amount       types.Currency      `json:"amount"`
+		Destination  types.UnlockHash    `json:"destination"`
+		Height       types.BlockHeight   `json:"height"`
+		Transactions []types.Transaction `json:"transactions"`
+	}
+
+	// A WalletAccount is a named account within the wallet. Each account
+	// derives its addresses from a separate range of indices of the primary
+	// seed, so its siacoins and siafunds are kept apart from the rest of the
+	// wallet while remaining recoverable from the seed. Unconfirmed balances
+	// are reported the same way as by UnconfirmedBalance.
+	WalletAccount struct {
+		Name                        string         `json:"name"`
+		ConfirmedSiacoinBalance     types.Currency `json:"confirmedsiacoinbalance"`
+		UnconfirmedOutgoingSiacoins types.Currency `json:"unconfirmedoutgoingsiacoins"`
+		UnconfirmedIncomingSiacoins types.Currency `json:"unconfirmedincomingsiacoins"`
+		SiafundBalance              types.Currency `json:"siafundbalance"`
+		AddressCount                uint64         `json:"addresscount"`
+	}
+
 	// TransactionBuilder is used to construct custom transactions. A transaction
 	// builder is initialized via 'RegisterTransaction' and then can be modified by
 	// adding funds or other fields. The transaction is completed by calling
@@ -276,6 +314,12 @@ type (
 		// primary seed.
 		NextAddress() (types.UnlockConditions, error)
 
+		// KeyAtIndex deterministically derives a keypair from the primary
+		// seed. Keys derived for different purposes are independent of each
+		// other and of the keys used for coin addresses, which allows modules
+		// to derive their signing keys from the seed without risk of reuse.
+		KeyAtIndex(purpose types.Specifier, index uint64) (crypto.SecretKey, crypto.PublicKey, error)
+
 		// CreateBackup will create a backup of the wallet at the provided
 		// filepath. The backup will have all seeds and keys.
 		CreateBackup(string) error
@@ -292,13 +336,19 @@ type (
 		// LoadSeed will recreate a wallet file using the recovery phrase.
 		// LoadSeed only needs to be called if the original seed file or
 		// encryption password was lost. The master key is used to encrypt the
-		// recovery seed before saving it to disk.
-		LoadSeed(crypto.TwofishKey, Seed) error
+		// recovery seed before saving it to disk. The progress of generating
+		// the keys of the seed is reported to the ProgressFunc.
+		LoadSeed(crypto.TwofishKey, Seed, ProgressFunc) error
 
 		// LoadSiagKeys will take a set of filepaths that point to a siag key
 		// and will have the siag keys loaded into the wallet so that they will
 		// become spendable.
 		LoadSiagKeys(crypto.TwofishKey, []string) error
+
+		// LoadLooseKey loads the secret key of a standalone key pair, such
+		// as a paper wallet, into the wallet so that the coins sent to its
+		// address become spendable.
+		LoadLooseKey(crypto.TwofishKey, crypto.SecretKey) error
 	}
 
 	// Wallet stores and manages siacoins and siafunds. The wallet file is
@@ -313,16 +363,51 @@ type (
 
 		// ConfirmedBalance returns the confirmed balance of the wallet, minus
 		// any outgoing transactions. ConfirmedBalance will include unconfirmed
-		// refund transactions.
+		// refund transactions. The balances of accounts are not included.
 		ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siacoinClaimBalance types.Currency)
 
 		// UnconfirmedBalance returns the unconfirmed balance of the wallet.
 		// Outgoing funds and incoming funds are reported separately. Refund
 		// outputs are included, meaning that sending a single coin to
 		// someone could result in 'outgoing: 12, incoming: 11'. Siafunds are
-		// not considered in the unconfirmed balance.
+		// not considered in the unconfirmed balance. The balances of
+		// accounts are not included.
 		UnconfirmedBalance() (outgoingSiacoins types.Currency, incomingSiacoins types.Currency)
 
+		// CreateAccount adds a named account to the wallet. The siacoins and
+		// siafunds of the account are not part of the wallet's balance, and
+		// are not spent by SendSiacoins or by transaction builders. Accounts
+		// take the next free range of seed indices, so accounts that are
+		// created in the same order on a restored wallet have the same
+		// addresses.
+		CreateAccount(name string) error
+
+		// Accounts returns the accounts of the wallet, in the order they
+		// were created.
+		Accounts() ([]WalletAccount, error)
+
+		// AccountAddress returns a new address of an account.
+		AccountAddress(name string) (types.UnlockConditions, error)
+
+		// AccountTransactions returns the confirmed and unconfirmed
+		// transactions that spend from or pay to an account.
+		AccountTransactions(name string) ([]ProcessedTransaction, error)
+
+		// AccountSendSiacoins sends siacoins from an account to an address.
+		// The miner fee and any change are paid by and returned to the
+		// account.
+		AccountSendSiacoins(name string, amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
+
+		// ReserveSiacoins sets the number of siacoins reserved under a name,
+		// replacing any previous reservation with that name. Reserved
+		// siacoins can still be spent using a TransactionBuilder, but
+		// SendSiacoins and SendSiafunds will not spend them. Reserving zero
+		// siacoins removes the reservation.
+		ReserveSiacoins(name string, amount types.Currency)
+
+		// ReservedSiacoins returns the total number of reserved siacoins.
+		ReservedSiacoins() types.Currency
+
 		// AddressTransactions returns all of the transactions that are related
 		// to a given address.
 		AddressTransactions(types.UnlockHash) []ProcessedTransaction
@@ -356,14 +441,51 @@ type (
 		// SendSiacoins is a tool for sending siacoins from the wallet to an
 		// address. Sending money usually results in multiple transactions. The
 		// transactions are automatically given to the transaction pool, and
-		// are also returned to the caller.
+		// are also returned to the caller. Reserved siacoins are not sent.
 		SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
 
+		// SendSiacoinsMulti sends siacoins to many addresses in a single
+		// transaction, creating each of the provided outputs. The miner fee
+		// grows with the number of outputs. The transactions are
+		// automatically given to the transaction pool, and are also
+		// returned to the caller. Reserved siacoins are not sent.
+		SendSiacoinsMulti(outputs []types.SiacoinOutput) ([]types.Transaction, error)
+
+		// MaxSendableSiacoins returns the largest amount of siacoins that can
+		// be sent to a single address in one transaction set, after paying the
+		// miner fee, which is also returned. The number of inputs is limited
+		// by the size of a standard transaction, and the fee grows with the
+		// size of the set. Reserved siacoins are not counted.
+		MaxSendableSiacoins() (amount types.Currency, fee types.Currency, err error)
+
+		// SendMaxSiacoins sends the amount returned by MaxSendableSiacoins to
+		// an address, returning the transactions and the amount sent.
+		SendMaxSiacoins(dest types.UnlockHash) ([]types.Transaction, types.Currency, error)
+
 		// SendSiafunds is a tool for sending siafunds from the wallet to an
 		// address. Sending money usually results in multiple transactions. The
 		// transactions are automatically given to the transaction pool, and
 		// are also returned to the caller.
 		SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
+
+		// SweepKeys sends the outputs spendable by the provided secret keys,
+		// such as the keys of paper wallets, to an address of the primary
+		// seed. It returns the siacoins, after miner fees, and the siafunds
+		// that were swept.
+		SweepKeys([]crypto.SecretKey) (coins, funds types.Currency, err error)
+
+		// ScheduleSiacoins creates a payment of 'amount' siacoins to 'dest'
+		// that is held by the wallet and automatically given to the
+		// transaction pool once the blockchain reaches 'height'.
+		ScheduleSiacoins(amount types.Currency, dest types.UnlockHash, height types.BlockHeight) (ScheduledPayment, error)
+
+		// ScheduledPayments returns the payments that are waiting to be
+		// broadcast, ordered by height.
+		ScheduledPayments() []ScheduledPayment
+
+		// CancelScheduledPayment discards a payment that has not been
+		// broadcast yet, making the siacoins that fund it spendable again.
+		CancelScheduledPayment(id types.TransactionID) error
 	}
 )
 
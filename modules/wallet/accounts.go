@@ -0,0 +1,213 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// accountSeedShift determines the ranges of primary seed indices used by
+// accounts. The keys of account n are derived from the indices starting at
+// n << accountSeedShift, leaving the wallet itself, account 0, with the first
+// range.
+const accountSeedShift = 32
+
+var (
+	errAccountExists    = errors.New("an account with that name already exists")
+	errEmptyAccountName = errors.New("account name cannot be empty")
+	errUnknownAccount   = errors.New("no account with that name")
+)
+
+// accountSeedIndex returns the primary seed index of the i'th key of an
+// account.
+func accountSeedIndex(account, i uint64) uint64 {
+	return account<<accountSeedShift + i
+}
+
+// accountOf returns the account that an address belongs to.
+func (w *Wallet) accountOf(uh types.UnlockHash) uint64 {
+	return w.accountKeys[uh]
+}
+
+// accountNumber returns the number of the account with the given name.
+func (w *Wallet) accountNumber(name string) (uint64, error) {
+	for i, ap := range w.persist.Accounts {
+		if ap.Name == name {
+			return uint64(i + 1), nil
+		}
+	}
+	return 0, errUnknownAccount
+}
+
+// integrateAccountKey adds the i'th key of an account to the wallet.
+func (w *Wallet) integrateAccountKey(account, i uint64) types.UnlockConditions {
+	spendableKey := generateSpendableKey(w.primarySeed, accountSeedIndex(account, i))
+	uh := spendableKey.UnlockConditions.UnlockHash()
+	w.keys[uh] = spendableKey
+	w.accountKeys[uh] = account
+	return spendableKey.UnlockConditions
+}
+
+// initAccountKeys loads the keys of every account into the wallet. Like the
+// primary seed, accounts preload keys beyond the ones that have been handed
+// out. initAccountKeys must be called after the primary seed is loaded.
+func (w *Wallet) initAccountKeys() {
+	for n, ap := range w.persist.Accounts {
+		for i := uint64(0); i < ap.Progress+modules.WalletSeedPreloadDepth; i++ {
+			w.integrateAccountKey(uint64(n+1), i)
+		}
+	}
+}
+
+// nextAccountAddress fetches the next address of an account. Account 0 uses
+// the primary seed's addresses.
+func (w *Wallet) nextAccountAddress(account uint64) (types.UnlockConditions, error) {
+	if account == 0 {
+		return w.nextPrimarySeedAddress()
+	}
+	if !w.unlocked {
+		return types.UnlockConditions{}, modules.ErrLockedWallet
+	}
+	ap := &w.persist.Accounts[account-1]
+	uc := w.integrateAccountKey(account, ap.Progress+modules.WalletSeedPreloadDepth)
+	ap.Progress++
+	err := w.saveSettingsSync()
+	if err != nil {
+		return types.UnlockConditions{}, err
+	}
+	return uc, nil
+}
+
+// accountRelated returns true if a transaction spends from or pays to an
+// account.
+func (w *Wallet) accountRelated(pt modules.ProcessedTransaction, account uint64) bool {
+	for _, input := range pt.Inputs {
+		if input.WalletAddress && w.accountOf(input.RelatedAddress) == account {
+			return true
+		}
+	}
+	for _, output := range pt.Outputs {
+		if output.WalletAddress && w.accountOf(output.RelatedAddress) == account {
+			return true
+		}
+	}
+	return false
+}
+
+// startAccountTransaction returns a transaction builder whose inputs are
+// funded by an account.
+func (w *Wallet) startAccountTransaction(account uint64) modules.TransactionBuilder {
+	return &transactionBuilder{
+		account: account,
+		wallet:  w,
+	}
+}
+
+// CreateAccount adds a named account to the wallet. The account uses the next
+// free range of primary seed indices.
+func (w *Wallet) CreateAccount(name string) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return modules.ErrLockedWallet
+	}
+	if name == "" {
+		return errEmptyAccountName
+	}
+	if _, err := w.accountNumber(name); err == nil {
+		return errAccountExists
+	}
+
+	w.persist.Accounts = append(w.persist.Accounts, AccountPersist{Name: name})
+	account := uint64(len(w.persist.Accounts))
+	for i := uint64(0); i < modules.WalletSeedPreloadDepth; i++ {
+		w.integrateAccountKey(account, i)
+	}
+	return w.saveSettingsSync()
+}
+
+// Accounts returns the accounts of the wallet, in the order they were
+// created.
+func (w *Wallet) Accounts() ([]modules.WalletAccount, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	accounts := make([]modules.WalletAccount, 0, len(w.persist.Accounts))
+	for i, ap := range w.persist.Accounts {
+		account := uint64(i + 1)
+		siacoins, siafunds, _ := w.confirmedBalance(account)
+		outgoing, incoming := w.unconfirmedBalance(account)
+		accounts = append(accounts, modules.WalletAccount{
+			Name:                        ap.Name,
+			ConfirmedSiacoinBalance:     siacoins,
+			UnconfirmedOutgoingSiacoins: outgoing,
+			UnconfirmedIncomingSiacoins: incoming,
+			SiafundBalance:              siafunds,
+			AddressCount:                ap.Progress,
+		})
+	}
+	return accounts, nil
+}
+
+// AccountAddress returns a new address of an account.
+func (w *Wallet) AccountAddress(name string) (types.UnlockConditions, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.UnlockConditions{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	account, err := w.accountNumber(name)
+	if err != nil {
+		return types.UnlockConditions{}, err
+	}
+	return w.nextAccountAddress(account)
+}
+
+// AccountTransactions returns the confirmed and unconfirmed transactions that
+// spend from or pay to an account. Confirmed transactions come first, in
+// chronological order.
+func (w *Wallet) AccountTransactions(name string) ([]modules.ProcessedTransaction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	account, err := w.accountNumber(name)
+	if err != nil {
+		return nil, err
+	}
+	var pts []modules.ProcessedTransaction
+	for _, pt := range w.processedTransactions {
+		if w.accountRelated(pt, account) {
+			pts = append(pts, pt)
+		}
+	}
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		if w.accountRelated(pt, account) {
+			pts = append(pts, pt)
+		}
+	}
+	return pts, nil
+}
+
+// AccountSendSiacoins sends siacoins from an account to an address. The
+// transaction set is submitted to the transaction pool and is also returned.
+func (w *Wallet) AccountSendSiacoins(name string, amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	w.mu.RLock()
+	account, err := w.accountNumber(name)
+	w.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	output := types.SiacoinOutput{
+		Value:      amount,
+		UnlockHash: dest,
+	}
+	return w.managedSendOutputs(account, []types.SiacoinOutput{output}, defaultSendFee)
+}
@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestAccounts checks that the siacoins of an account are kept apart from
+// the rest of the wallet.
+func TestAccounts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestAccounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	if err := wt.wallet.CreateAccount(""); err != errEmptyAccountName {
+		t.Fatal("expected errEmptyAccountName, got", err)
+	}
+	if err := wt.wallet.CreateAccount("hosting"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.CreateAccount("hosting"); err != errAccountExists {
+		t.Fatal("expected errAccountExists, got", err)
+	}
+	if _, err := wt.wallet.AccountAddress("unknown"); err != errUnknownAccount {
+		t.Fatal("expected errUnknownAccount, got", err)
+	}
+
+	// Pay the account from the wallet.
+	uc, err := wt.wallet.AccountAddress("hosting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := types.SiacoinPrecision.Mul64(1000)
+	_, err = wt.wallet.SendSiacoins(amount, uc.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := wt.wallet.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].UnconfirmedIncomingSiacoins.Cmp(amount) != 0 || accounts[0].AddressCount != 1 {
+		t.Fatal("account does not show the unconfirmed payment:", accounts)
+	}
+	b, _ := wt.miner.FindBlock()
+	if err := wt.cs.AcceptBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// The payment should have left the wallet's balance, and appear in the
+	// account's balance and history.
+	var total types.Currency
+	wt.wallet.mu.Lock()
+	for _, sco := range wt.wallet.siacoinOutputs {
+		total = total.Add(sco.Value)
+	}
+	wt.wallet.mu.Unlock()
+	walletBal, _, _ := wt.wallet.ConfirmedBalance()
+	if walletBal.Cmp(total.Sub(amount)) != 0 {
+		t.Fatal("wallet balance does not exclude the account:", walletBal, total.Sub(amount))
+	}
+	accounts, _ = wt.wallet.Accounts()
+	if accounts[0].ConfirmedSiacoinBalance.Cmp(amount) != 0 {
+		t.Fatal("account balance is wrong:", accounts[0].ConfirmedSiacoinBalance)
+	}
+	pts, err := wt.wallet.AccountTransactions("hosting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 1 {
+		t.Fatal("expected 1 account transaction, got", len(pts))
+	}
+
+	// The wallet cannot spend the account's siacoins.
+	wt.wallet.mu.Lock()
+	spendable, _ := wt.wallet.spendableSiacoinOutputs(0)
+	wt.wallet.mu.Unlock()
+	for _, sco := range spendable.outputs {
+		if sco.UnlockHash == uc.UnlockHash() {
+			t.Fatal("wallet can spend the account's siacoins")
+		}
+	}
+
+	// Send from the account. The fee and change stay within the account.
+	sent := types.SiacoinPrecision.Mul64(100)
+	_, err = wt.wallet.AccountSendSiacoins("hosting", sent, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = wt.miner.FindBlock()
+	if err := wt.cs.AcceptBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	accounts, _ = wt.wallet.Accounts()
+	if accounts[0].ConfirmedSiacoinBalance.Cmp(amount.Sub(sent).Sub(defaultSendFee)) != 0 {
+		t.Fatal("account balance is wrong after sending:", accounts[0].ConfirmedSiacoinBalance)
+	}
+	if _, err := wt.wallet.AccountSendSiacoins("hosting", amount, types.UnlockHash{}); err != modules.ErrLowBalance {
+		t.Fatal("expected ErrLowBalance, got", err)
+	}
+
+	// The account keys should be restored when the wallet is unlocked again.
+	if err := wt.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.Lock()
+	wt.wallet.accountKeys = make(map[types.UnlockHash]uint64)
+	wt.wallet.mu.Unlock()
+	if err := wt.wallet.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+	accounts, _ = wt.wallet.Accounts()
+	if accounts[0].ConfirmedSiacoinBalance.Cmp(amount.Sub(sent).Sub(defaultSendFee)) != 0 {
+		t.Fatal("account balance was not restored:", accounts[0].ConfirmedSiacoinBalance)
+	}
+}
@@ -130,7 +130,13 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 
 	// Subscribe to the consensus set if this is the first unlock for the
 	// wallet object.
-	if !subscribed {
+	if !subscribed && w.lcs != nil {
+		// A light wallet scans the blockchain in the background.
+		go w.threadedLightScanLoop()
+		w.mu.Lock()
+		w.subscribed = true
+		w.mu.Unlock()
+	} else if !subscribed {
 		// During rescan, print height every 3 seconds.
 		if build.Release != "testing" {
 			go func() {
@@ -148,7 +154,20 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 				}
 			}()
 		}
-		err = w.cs.ConsensusSetSubscribe(w, modules.ConsensusChangeBeginning)
+		// A snapshot only contains the outputs of the wallet's keys, rather
+		// than the diffs of every block. The blocks are still needed to
+		// build the transaction history, though claim outputs in the
+		// history are valued using the current siafund pool.
+		w.mu.RLock()
+		keys := make(map[types.UnlockHash]struct{}, len(w.keys))
+		for uh := range w.keys {
+			keys[uh] = struct{}{}
+		}
+		w.mu.RUnlock()
+		err = w.cs.ConsensusSetSnapshotSubscribe(w, modules.ConsensusChangeBeginning, func(uh types.UnlockHash) bool {
+			_, exists := keys[uh]
+			return exists
+		})
 		if err != nil {
 			return errors.New("wallet subscription failed: " + err.Error())
 		}
@@ -216,6 +235,19 @@ func (w *Wallet) Unlocked() bool {
 	return w.unlocked
 }
 
+// Healthy returns an error if the wallet is closed or locked, as a locked
+// wallet cannot fund transactions for the other modules.
+func (w *Wallet) Healthy() error {
+	if err := w.tg.Add(); err != nil {
+		return errWalletClosed
+	}
+	defer w.tg.Done()
+	if !w.Unlocked() {
+		return modules.ErrLockedWallet
+	}
+	return nil
+}
+
 // Lock will erase all keys from memory and prevent the wallet from spending
 // coins until it is unlocked.
 func (w *Wallet) Lock() error {
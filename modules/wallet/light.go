@@ -0,0 +1,280 @@
+package wallet
+
+// light.go implements the light wallet, which runs on top of a light
+// consensus set instead of a full consensus set and transaction pool. The
+// wallet periodically fetches the transactions relevant to its addresses from
+// full nodes, checks that each one belongs to a block of its header chain, and
+// applies them as if they were blocks containing only those transactions.
+// Transactions are relayed to the peers of the gateway instead of being given
+// to a transaction pool.
+//
+// A light wallet cannot see the miner payouts, file contract payouts, and
+// siafund claims of its addresses, because they are not created by
+// transactions, and it does not know the siafund pool. It also cannot sweep
+// keys, which requires the set of unspent outputs.
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// lightScanInterval is the amount of time that a light wallet waits
+	// between fetching the transactions of new blocks.
+	lightScanInterval = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return 20 * time.Second
+		case "standard":
+			return 2 * time.Minute
+		case "testing":
+			return 250 * time.Millisecond
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+
+	errLightProofStale = errors.New("transaction proof no longer matches the header chain")
+	errLightWallet     = errors.New("operation is not supported by a light wallet")
+	errNilGateway      = errors.New("light wallet cannot initialize with a nil gateway")
+	errNilLightConsSet = errors.New("light wallet cannot initialize with a nil light consensus set")
+	errNoPeers         = errors.New("light wallet has no peers to relay the transaction to")
+)
+
+// transactionPool is the part of the transaction pool that is used by the
+// wallet. A light wallet uses a lightTransactionPool instead.
+type transactionPool interface {
+	AcceptTransactionSet([]types.Transaction) error
+	FeeEstimation() (min, max types.Currency)
+	TransactionPoolSubscribe(modules.TransactionPoolSubscriber)
+	Unsubscribe(modules.TransactionPoolSubscriber)
+}
+
+// A lightTransactionPool relays the transactions of a light wallet to the
+// peers of the gateway. It does not track unconfirmed transactions.
+type lightTransactionPool struct {
+	gateway modules.Gateway
+}
+
+// AcceptTransactionSet relays the transaction set to every peer. The set is
+// validated by the peers, not by the light wallet.
+func (ltp lightTransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
+	if len(ltp.gateway.Peers()) == 0 {
+		return errNoPeers
+	}
+	go ltp.gateway.Broadcast("RelayTransactionSet", ts, ltp.gateway.Peers())
+	return nil
+}
+
+// FeeEstimation returns the same estimation as the transaction pool, which
+// does not depend on the transaction pool's contents.
+func (ltp lightTransactionPool) FeeEstimation() (min, max types.Currency) {
+	return modules.MinimumRecommendedFee, modules.MaximumRecommendedFee
+}
+
+// TransactionPoolSubscribe does nothing, as a light wallet does not see the
+// unconfirmed transactions of its peers.
+func (ltp lightTransactionPool) TransactionPoolSubscribe(modules.TransactionPoolSubscriber) {}
+
+// Unsubscribe does nothing.
+func (ltp lightTransactionPool) Unsubscribe(modules.TransactionPoolSubscriber) {}
+
+// NewLight creates a new light wallet, which follows the blockchain through a
+// light consensus set and relays its transactions through the gateway. As with
+// New, keys are not loaded until the wallet is unlocked.
+func NewLight(lcs modules.LightConsensusSet, g modules.Gateway, persistDir string) (*Wallet, error) {
+	// Check for nil dependencies.
+	if lcs == nil {
+		return nil, errNilLightConsSet
+	}
+	if g == nil {
+		return nil, errNilGateway
+	}
+
+	w := newWallet(nil, lightTransactionPool{gateway: g}, persistDir)
+	w.lcs = lcs
+	err := w.initPersist()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// lightBlock returns a block holding the transactions of the proofs, which
+// must all belong to the block with the given header.
+func lightBlock(header types.BlockHeader, proofs []modules.TransactionProof) types.Block {
+	b := types.Block{
+		ParentID:  header.ParentID,
+		Nonce:     header.Nonce,
+		Timestamp: header.Timestamp,
+	}
+	for _, proof := range proofs {
+		b.Transactions = append(b.Transactions, proof.Transaction)
+	}
+	return b
+}
+
+// lightOutputDiffs returns the diffs that the transactions of a block cause to
+// the outputs of the wallet. Outputs that are created and spent within the
+// block produce both an apply and a revert diff.
+func (w *Wallet) lightOutputDiffs(b types.Block) (scods []modules.SiacoinOutputDiff, sfods []modules.SiafundOutputDiff) {
+	// Outputs created earlier in the block are not in the wallet yet.
+	createdCoins := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	createdFunds := make(map[types.SiafundOutputID]types.SiafundOutput)
+	for _, txn := range b.Transactions {
+		for _, sci := range txn.SiacoinInputs {
+			sco, exists := createdCoins[sci.ParentID]
+			if !exists {
+				sco, exists = w.siacoinOutputs[sci.ParentID]
+			}
+			if exists {
+				scods = append(scods, modules.SiacoinOutputDiff{Direction: modules.DiffRevert, ID: sci.ParentID, SiacoinOutput: sco})
+			}
+		}
+		for i, sco := range txn.SiacoinOutputs {
+			if _, exists := w.keys[sco.UnlockHash]; exists {
+				id := txn.SiacoinOutputID(uint64(i))
+				scods = append(scods, modules.SiacoinOutputDiff{Direction: modules.DiffApply, ID: id, SiacoinOutput: sco})
+				createdCoins[id] = sco
+			}
+		}
+		for _, sfi := range txn.SiafundInputs {
+			sfo, exists := createdFunds[sfi.ParentID]
+			if !exists {
+				sfo, exists = w.siafundOutputs[sfi.ParentID]
+			}
+			if exists {
+				sfods = append(sfods, modules.SiafundOutputDiff{Direction: modules.DiffRevert, ID: sfi.ParentID, SiafundOutput: sfo})
+			}
+		}
+		for i, sfo := range txn.SiafundOutputs {
+			if _, exists := w.keys[sfo.UnlockHash]; exists {
+				id := txn.SiafundOutputID(uint64(i))
+				sfods = append(sfods, modules.SiafundOutputDiff{Direction: modules.DiffApply, ID: id, SiafundOutput: sfo})
+				createdFunds[id] = sfo
+			}
+		}
+	}
+	return scods, sfods
+}
+
+// resetLightState discards the outputs and history of a light wallet, so that
+// the blockchain can be scanned again after a reorg.
+func (w *Wallet) resetLightState() {
+	w.siacoinOutputs = make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	w.siafundOutputs = make(map[types.SiafundOutputID]types.SiafundOutput)
+	w.processedTransactions = nil
+	w.processedTransactionMap = make(map[types.TransactionID]*modules.ProcessedTransaction)
+	w.consensusSetHeight = 0
+	w.lightScanned = 0
+	w.lightTip = types.BlockID{}
+}
+
+// managedLightScan fetches the transactions relevant to the wallet in the
+// blocks that have not been scanned yet, and applies them. If the last
+// scanned block is no longer part of the header chain, the whole blockchain
+// is scanned again.
+func (w *Wallet) managedLightScan() error {
+	w.mu.Lock()
+	if w.lightScanned > 0 {
+		header, exists := w.lcs.HeaderAtHeight(w.lightScanned - 1)
+		if !exists || header.ID() != w.lightTip {
+			w.log.Println("Light wallet scan was reorged out of the header chain, rescanning")
+			w.resetLightState()
+		}
+	}
+	start := w.lightScanned
+	addrs := make([]types.UnlockHash, 0, len(w.keys))
+	for uh := range w.keys {
+		addrs = append(addrs, uh)
+	}
+	w.mu.Unlock()
+
+	tip, height := w.lcs.CurrentHeader()
+	if start > height {
+		return nil
+	}
+	proofs, err := w.lcs.RelevantTransactions(addrs, start)
+	if err != nil {
+		return err
+	}
+
+	// Check every proof against the header chain again, because the chain
+	// may have changed while the proofs were being downloaded.
+	headers := make(map[types.BlockHeight]types.BlockHeader)
+	for _, proof := range proofs {
+		if proof.Height > height {
+			continue
+		}
+		header, exists := w.lcs.HeaderAtHeight(proof.Height)
+		if !exists || header.ID() != proof.BlockID || !header.VerifyTransactionProof(proof.Transaction, proof.ProofIndex, proof.NumLeaves, proof.HashSet) {
+			return errLightProofStale
+		}
+		headers[proof.Height] = header
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.lightScanned != start {
+		// Another scan has completed in the meantime.
+		return nil
+	}
+	for i := 0; i < len(proofs); {
+		j := i
+		for j < len(proofs) && proofs[j].Height == proofs[i].Height {
+			j++
+		}
+		if proofs[i].Height <= height {
+			b := lightBlock(headers[proofs[i].Height], proofs[i:j])
+			scods, sfods := w.lightOutputDiffs(b)
+			cc := modules.ConsensusChange{
+				AppliedBlocks:      []types.Block{b},
+				SiacoinOutputDiffs: scods,
+				SiafundOutputDiffs: sfods,
+			}
+			// applyHistory confirms the transactions at the height after
+			// consensusSetHeight.
+			w.consensusSetHeight = proofs[i].Height - 1
+			w.updateConfirmedSet(cc)
+			w.applyHistory(cc)
+		}
+		i = j
+	}
+	w.consensusSetHeight = height
+	w.lightScanned = height + 1
+	w.lightTip = tip.ID()
+
+	w.reserveScheduledOutputs()
+	if len(w.duePayments()) > 0 {
+		go w.threadedBroadcastScheduled()
+	}
+	return nil
+}
+
+// threadedLightScanLoop scans the blockchain for the transactions of the
+// wallet once the light consensus set is synced, and then scans the new
+// blocks every lightScanInterval.
+func (w *Wallet) threadedLightScanLoop() {
+	if err := w.tg.Add(); err != nil {
+		return
+	}
+	defer w.tg.Done()
+
+	for {
+		if w.lcs.Synced() {
+			if err := w.managedLightScan(); err != nil {
+				w.log.Println("WARN: light wallet scan failed:", err)
+			}
+		}
+
+		select {
+		case <-w.tg.StopChan():
+			return
+		case <-time.After(lightScanInterval):
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/consensus"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestLightWallet checks that a light wallet finds the siacoins sent to it by
+// a full node, and that it can send siacoins through its peers.
+func TestLightWallet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestLightWallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Create a light wallet connected to the full node.
+	testdir := build.TempDir(modules.WalletDir, "TestLightWalletLight")
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	lcs, err := consensus.NewLight(g, filepath.Join(testdir, modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lcs.Close()
+	lw, err := NewLight(lcs, g, filepath.Join(testdir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lw.Close()
+	var masterKey crypto.TwofishKey
+	if _, err := rand.Read(masterKey[:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lw.Encrypt(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := lw.Unlock(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Connect(wt.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	// waitForBalance waits for the light wallet to report a balance.
+	waitForBalance := func(balance types.Currency) {
+		for i := 0; i < 100; i++ {
+			if bal, _, _ := lw.ConfirmedBalance(); bal.Cmp(balance) == 0 {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		bal, _, _ := lw.ConfirmedBalance()
+		t.Fatalf("light wallet has balance %v, expected %v", bal, balance)
+	}
+
+	// Send siacoins to the light wallet.
+	uc, err := lw.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := types.SiacoinPrecision.Mul64(100)
+	if _, err := wt.wallet.SendSiacoins(amount, uc.UnlockHash()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	waitForBalance(amount)
+
+	// Send siacoins back to the full node. The transaction is relayed to the
+	// full node's transaction pool.
+	fullUC, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns, err := lw.SendSiacoins(types.SiacoinPrecision.Mul64(10), fullUC.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && len(wt.tpool.TransactionList()) == 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(wt.tpool.TransactionList()) == 0 {
+		t.Fatal("transaction of the light wallet was not relayed to the full node")
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	expected := amount.Sub(types.SiacoinPrecision.Mul64(10))
+	for _, txn := range txns {
+		for _, fee := range txn.MinerFees {
+			expected = expected.Sub(fee)
+		}
+	}
+	waitForBalance(expected)
+}
@@ -1,10 +1,32 @@
 package wallet
 
 import (
+	"errors"
+
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
+var (
+	// defaultSendFee is the miner fee paid by SendSiacoins and SendSiafunds.
+	// It is also the smallest fee paid by SendMaxSiacoins, which pays more if
+	// the transaction set is large.
+	defaultSendFee = types.SiacoinPrecision.Mul64(10) // TODO: better fee algo.
+)
+
+var (
+	// errNoOutputs is returned if SendSiacoinsMulti is called without any
+	// outputs.
+	errNoOutputs = errors.New("no outputs were provided")
+
+	// errTooManyOutputs is returned if the outputs passed to
+	// SendSiacoinsMulti do not fit in a standard transaction.
+	errTooManyOutputs = errors.New("outputs do not fit in a single transaction")
+)
+
 // sortedOutputs is a struct containing a slice of siacoin outputs and their
 // corresponding ids. sortedOutputs can be sorted using the sort package.
 type sortedOutputs struct {
@@ -12,37 +34,34 @@ type sortedOutputs struct {
 	outputs []types.SiacoinOutput
 }
 
-// ConfirmedBalance returns the balance of the wallet according to all of the
+// confirmedBalance returns the balance of an account according to all of the
 // confirmed transactions.
-func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siafundClaimBalance types.Currency) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
+func (w *Wallet) confirmedBalance(account uint64) (siacoinBalance types.Currency, siafundBalance types.Currency, siafundClaimBalance types.Currency) {
 	for _, sco := range w.siacoinOutputs {
-		siacoinBalance = siacoinBalance.Add(sco.Value)
+		if w.accountOf(sco.UnlockHash) == account {
+			siacoinBalance = siacoinBalance.Add(sco.Value)
+		}
 	}
 	for _, sfo := range w.siafundOutputs {
-		siafundBalance = siafundBalance.Add(sfo.Value)
-		siafundClaimBalance = siafundClaimBalance.Add(w.siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value).Div(types.SiafundCount))
+		if w.accountOf(sfo.UnlockHash) == account {
+			siafundBalance = siafundBalance.Add(sfo.Value)
+			siafundClaimBalance = siafundClaimBalance.Add(w.siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value).Div(types.SiafundCount))
+		}
 	}
 	return
 }
 
-// UnconfirmedBalance returns the number of outgoing and incoming siacoins in
-// the unconfirmed transaction set. Refund outputs are included in this
-// reporting.
-func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incomingSiacoins types.Currency) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
+// unconfirmedBalance returns the number of outgoing and incoming siacoins of
+// an account in the unconfirmed transaction set.
+func (w *Wallet) unconfirmedBalance(account uint64) (outgoingSiacoins types.Currency, incomingSiacoins types.Currency) {
 	for _, upt := range w.unconfirmedProcessedTransactions {
 		for _, input := range upt.Inputs {
-			if input.FundType == types.SpecifierSiacoinInput && input.WalletAddress {
+			if input.FundType == types.SpecifierSiacoinInput && input.WalletAddress && w.accountOf(input.RelatedAddress) == account {
 				outgoingSiacoins = outgoingSiacoins.Add(input.Value)
 			}
 		}
 		for _, output := range upt.Outputs {
-			if output.FundType == types.SpecifierSiacoinOutput && output.WalletAddress {
+			if output.FundType == types.SpecifierSiacoinOutput && output.WalletAddress && w.accountOf(output.RelatedAddress) == account {
 				incomingSiacoins = incomingSiacoins.Add(output.Value)
 			}
 		}
@@ -50,27 +69,183 @@ func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incoming
 	return
 }
 
-// SendSiacoins creates a transaction sending 'amount' to 'dest'. The transaction
-// is submitted to the transaction pool and is also returned.
-func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
-	if err := w.tg.Add(); err != nil {
-		return nil, err
+// ConfirmedBalance returns the balance of the wallet according to all of the
+// confirmed transactions. The balances of accounts are not included.
+func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siafundClaimBalance types.Currency) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.confirmedBalance(0)
+}
+
+// UnconfirmedBalance returns the number of outgoing and incoming siacoins in
+// the unconfirmed transaction set. Refund outputs are included in this
+// reporting. The balances of accounts are not included.
+func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incomingSiacoins types.Currency) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.unconfirmedBalance(0)
+}
+
+// ReserveSiacoins sets the number of siacoins reserved under 'name',
+// replacing any previous reservation with that name. Reserving zero siacoins
+// removes the reservation.
+func (w *Wallet) ReserveSiacoins(name string, amount types.Currency) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if amount.IsZero() {
+		delete(w.reservations, name)
+		return
 	}
-	defer w.tg.Done()
+	w.reservations[name] = amount
+}
+
+// ReservedSiacoins returns the total number of siacoins reserved by other
+// modules.
+func (w *Wallet) ReservedSiacoins() types.Currency {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reservedSiacoins()
+}
+
+// reservedSiacoins returns the total number of siacoins reserved by other
+// modules. w.mu must be held.
+func (w *Wallet) reservedSiacoins() (reserved types.Currency) {
+	for _, amount := range w.reservations {
+		reserved = reserved.Add(amount)
+	}
+	return reserved
+}
+
+// signatureSize returns the encoded size of a transaction signature created
+// by the wallet.
+func signatureSize() uint64 {
+	return uint64(len(encoding.Marshal(types.TransactionSignature{
+		CoveredFields: types.FullCoveredFields,
+		Signature:     make([]byte, crypto.SignatureSize),
+	})))
+}
+
+// siacoinInputSize returns the encoded size of a siacoin input spending from
+// the provided unlock conditions, including its signatures.
+func siacoinInputSize(uc types.UnlockConditions) uint64 {
+	inputSize := uint64(len(encoding.Marshal(types.SiacoinInput{UnlockConditions: uc})))
+	return inputSize + uc.SignaturesRequired*signatureSize()
+}
+
+// sendBaseSizes returns the encoded sizes of the parent transaction and of
+// the whole transaction set created when sending siacoins, not counting the
+// inputs of the parent transaction. Every currency field is encoded as
+// 'value', which should be at least as large as any of the real values, so
+// that the sizes are not underestimated.
+func sendBaseSizes(value types.Currency) (parentSize, setSize uint64) {
+	// The parent has an output of the exact amount being sent, and possibly a
+	// refund output.
+	uc := generateUnlockConditions(crypto.PublicKey{})
+	parent := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: value}, {Value: value}},
+	}
+	// The child spends the exact output of the parent, paying the miner fee
+	// and sending the rest to the destination.
+	child := types.Transaction{
+		SiacoinInputs:  []types.SiacoinInput{{UnlockConditions: uc}},
+		SiacoinOutputs: []types.SiacoinOutput{{Value: value}},
+		MinerFees:      []types.Currency{value},
+		TransactionSignatures: []types.TransactionSignature{{
+			CoveredFields: types.FullCoveredFields,
+			Signature:     make([]byte, crypto.SignatureSize),
+		}},
+	}
+	parentSize = uint64(len(encoding.Marshal(parent)))
+	setSize = uint64(len(encoding.Marshal([]types.Transaction{parent, child})))
+	return parentSize, setSize
+}
+
+// managedMaxSendableSiacoins returns the largest amount of siacoins that can
+// be sent to a single address in one transaction set, along with the miner
+// fee that the set pays. Only as many outputs as fit in a standard
+// transaction are spent, starting with the largest. The fee is
+// defaultSendFee, or the size of the set at the maximum recommended fee rate
+// if that is more. Siacoins that are reserved by other modules are not
+// counted.
+func (w *Wallet) managedMaxSendableSiacoins() (amount, fee types.Currency, err error) {
+	// Determine how many siacoins can be sent without dipping into the
+	// reserved siacoins.
+	balance, _, _ := w.ConfirmedBalance()
+	outgoing, incoming := w.UnconfirmedBalance()
+	required := w.ReservedSiacoins().Add(outgoing)
+	var available types.Currency
+	if balance.Add(incoming).Cmp(required) > 0 {
+		available = balance.Add(incoming).Sub(required)
+	}
+	_, feeRate := w.tpool.FeeEstimation()
 
-	tpoolFee := types.SiacoinPrecision.Mul64(10) // TODO: better fee algo.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return types.Currency{}, types.Currency{}, modules.ErrLockedWallet
+	}
+
+	// Add the largest outputs until the parent transaction is full.
+	spendable, _ := w.spendableSiacoinOutputs(0)
+	var total types.Currency
+	for _, sco := range spendable.outputs {
+		total = total.Add(sco.Value)
+	}
+	parentSize, setSize := sendBaseSizes(total)
+	var fund types.Currency
+	for _, sco := range spendable.outputs {
+		inputSize := siacoinInputSize(w.keys[sco.UnlockHash].UnlockConditions)
+		if parentSize+inputSize > modules.TransactionSizeLimit {
+			break
+		}
+		parentSize += inputSize
+		setSize += inputSize
+		fund = fund.Add(sco.Value)
+	}
+	if fund.Cmp(available) > 0 {
+		fund = available
+	}
+
+	fee = feeRate.Mul64(setSize)
+	if fee.Cmp(defaultSendFee) < 0 {
+		fee = defaultSendFee
+	}
+	if fund.Cmp(fee) <= 0 {
+		return types.Currency{}, types.Currency{}, modules.ErrLowBalance
+	}
+	return fund.Sub(fee), fee, nil
+}
+
+// managedSendSiacoins creates a transaction sending 'amount' to 'dest' and
+// paying 'fee' to the miners. The transaction is submitted to the transaction
+// pool and is also returned.
+func (w *Wallet) managedSendSiacoins(amount, fee types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
 	output := types.SiacoinOutput{
 		Value:      amount,
 		UnlockHash: dest,
 	}
+	return w.managedSendOutputs(0, []types.SiacoinOutput{output}, fee)
+}
 
-	txnBuilder := w.StartTransaction()
-	err := txnBuilder.FundSiacoins(amount.Add(tpoolFee))
+// managedSendOutputs creates a transaction funded by 'account' that creates
+// 'outputs' and pays 'fee' to the miners. The transaction is submitted to the
+// transaction pool and is also returned. Reserved siacoins are only checked
+// for the wallet itself, as accounts cannot be reserved.
+func (w *Wallet) managedSendOutputs(account uint64, outputs []types.SiacoinOutput, fee types.Currency) ([]types.Transaction, error) {
+	amount := fee
+	for _, sco := range outputs {
+		amount = amount.Add(sco.Value)
+	}
+	txnBuilder := w.startAccountTransaction(account)
+	txnBuilder.(*transactionBuilder).unreserved = account == 0
+	err := txnBuilder.FundSiacoins(amount)
 	if err != nil {
 		return nil, err
 	}
-	txnBuilder.AddMinerFee(tpoolFee)
-	txnBuilder.AddSiacoinOutput(output)
+	txnBuilder.AddMinerFee(fee)
+	for _, sco := range outputs {
+		txnBuilder.AddSiacoinOutput(sco)
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		return nil, err
@@ -82,20 +257,105 @@ func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]t
 	return txnSet, nil
 }
 
+// multiSendFee returns the miner fee paid by SendSiacoinsMulti when creating
+// 'outputs': defaultSendFee, or the size of the transaction set at the
+// recommended fee rate if that is more. An error is returned if the outputs
+// do not fit in a standard transaction.
+func (w *Wallet) multiSendFee(outputs []types.SiacoinOutput) (types.Currency, error) {
+	var total types.Currency
+	for _, sco := range outputs {
+		total = total.Add(sco.Value)
+	}
+	parentSize, setSize := sendBaseSizes(total)
+	outputsSize := uint64(len(encoding.Marshal(outputs)))
+	if setSize-parentSize+outputsSize > modules.TransactionSizeLimit {
+		return types.Currency{}, errTooManyOutputs
+	}
+
+	_, feeRate := w.tpool.FeeEstimation()
+	fee := feeRate.Mul64(setSize + outputsSize)
+	if fee.Cmp(defaultSendFee) < 0 {
+		fee = defaultSendFee
+	}
+	return fee, nil
+}
+
+// MaxSendableSiacoins returns the largest amount of siacoins that
+// SendMaxSiacoins would send, and the miner fee that it would pay.
+func (w *Wallet) MaxSendableSiacoins() (amount, fee types.Currency, err error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Currency{}, types.Currency{}, err
+	}
+	defer w.tg.Done()
+	return w.managedMaxSendableSiacoins()
+}
+
+// SendSiacoins creates a transaction sending 'amount' to 'dest'. The transaction
+// is submitted to the transaction pool and is also returned. Siacoins that are
+// reserved by other modules are not sent.
+func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	return w.managedSendSiacoins(amount, defaultSendFee, dest)
+}
+
+// SendSiacoinsMulti creates a single transaction that creates every output
+// in 'outputs', paying many recipients at once. The transaction set is
+// submitted to the transaction pool and is also returned. Siacoins that are
+// reserved by other modules are not sent.
+func (w *Wallet) SendSiacoinsMulti(outputs []types.SiacoinOutput) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	if len(outputs) == 0 {
+		return nil, errNoOutputs
+	}
+	fee, err := w.multiSendFee(outputs)
+	if err != nil {
+		return nil, err
+	}
+	return w.managedSendOutputs(0, outputs, fee)
+}
+
+// SendMaxSiacoins sends as many siacoins as possible to 'dest' in a single
+// transaction set, as computed by MaxSendableSiacoins. The transaction set is
+// submitted to the transaction pool and is also returned, along with the
+// amount that was sent.
+func (w *Wallet) SendMaxSiacoins(dest types.UnlockHash) ([]types.Transaction, types.Currency, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, types.Currency{}, err
+	}
+	defer w.tg.Done()
+	amount, fee, err := w.managedMaxSendableSiacoins()
+	if err != nil {
+		return nil, types.Currency{}, err
+	}
+	txnSet, err := w.managedSendSiacoins(amount, fee, dest)
+	if err != nil {
+		return nil, types.Currency{}, err
+	}
+	return txnSet, amount, nil
+}
+
 // SendSiafunds creates a transaction sending 'amount' to 'dest'. The transaction
-// is submitted to the transaction pool and is also returned.
+// is submitted to the transaction pool and is also returned. The transaction
+// fee is not paid with siacoins that are reserved by other modules.
 func (w *Wallet) SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
 	if err := w.tg.Add(); err != nil {
 		return nil, err
 	}
 	defer w.tg.Done()
-	tpoolFee := types.SiacoinPrecision.Mul64(10) // TODO: better fee algo.
+	tpoolFee := defaultSendFee
 	output := types.SiafundOutput{
 		Value:      amount,
 		UnlockHash: dest,
 	}
 
 	txnBuilder := w.StartTransaction()
+	txnBuilder.(*transactionBuilder).unreserved = true
 	err := txnBuilder.FundSiacoins(tpoolFee)
 	if err != nil {
 		return nil, err
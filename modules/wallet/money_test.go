@@ -4,6 +4,7 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -69,6 +70,59 @@ func TestSendSiacoins(t *testing.T) {
 	}
 }
 
+// TestSendSiacoinsMulti probes the SendSiacoinsMulti method of the wallet.
+func TestSendSiacoinsMulti(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSendSiacoinsMulti")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Sending without outputs should fail.
+	if _, err := wt.wallet.SendSiacoinsMulti(nil); err != errNoOutputs {
+		t.Fatal("expected errNoOutputs, got", err)
+	}
+
+	// Send to several addresses in one transaction.
+	var outputs []types.SiacoinOutput
+	var total types.Currency
+	for i := 0; i < 50; i++ {
+		sco := types.SiacoinOutput{
+			Value:      types.NewCurrency64(uint64(1000 + i)),
+			UnlockHash: types.UnlockHash{byte(i)},
+		}
+		outputs = append(outputs, sco)
+		total = total.Add(sco.Value)
+	}
+	txnSet, err := wt.wallet.SendSiacoinsMulti(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every output should be created by the last transaction of the set.
+	created := make(map[types.UnlockHash]types.Currency)
+	for _, sco := range txnSet[len(txnSet)-1].SiacoinOutputs {
+		created[sco.UnlockHash] = sco.Value
+	}
+	for _, sco := range outputs {
+		if created[sco.UnlockHash].Cmp(sco.Value) != 0 {
+			t.Fatal("output was not created:", sco.UnlockHash)
+		}
+	}
+	unconfirmedOut, unconfirmedIn := wt.wallet.UnconfirmedBalance()
+	if unconfirmedOut.Cmp(unconfirmedIn.Add(total).Add(defaultSendFee)) < 0 {
+		t.Error("sending siacoins appears to be ineffective")
+	}
+
+	// Outputs that do not fit in a transaction should be rejected.
+	outputs = make([]types.SiacoinOutput, modules.TransactionSizeLimit/32)
+	if _, err := wt.wallet.SendSiacoinsMulti(outputs); err != errTooManyOutputs {
+		t.Fatal("expected errTooManyOutputs, got", err)
+	}
+}
+
 // TestIntegrationSendOverUnder sends too many siacoins, resulting in an error,
 // followed by sending few enough siacoins that the send should complete.
 //
@@ -182,3 +236,149 @@ func TestIntegrationSortedOutputsSorting(t *testing.T) {
 		}
 	}
 }
+
+// TestReserveSiacoins checks that SendSiacoins will not spend siacoins that
+// have been reserved, while transaction builders still can.
+func TestReserveSiacoins(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestReserveSiacoins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Reserve all but 100 SC of the balance.
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+	unreserved := types.SiacoinPrecision.Mul64(100)
+	wt.wallet.ReserveSiacoins("foo", balance.Sub(unreserved).Div64(2))
+	wt.wallet.ReserveSiacoins("bar", balance.Sub(unreserved).Div64(2))
+	if wt.wallet.ReservedSiacoins().Cmp(balance.Sub(unreserved)) != 0 {
+		t.Fatal("reservations were not summed:", wt.wallet.ReservedSiacoins())
+	}
+
+	// Sending more than the unreserved balance, including the fee, should
+	// fail.
+	_, err = wt.wallet.SendSiacoins(unreserved, types.UnlockHash{})
+	if err != errReservedFunds {
+		t.Fatalf("expected %v, got %v", errReservedFunds, err)
+	}
+
+	// Transaction builders are allowed to spend reserved siacoins.
+	txnBuilder := wt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(unreserved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.Drop()
+
+	// Payments that are being built count against the unreserved balance,
+	// even before they reach the transaction pool.
+	builder1 := wt.wallet.StartTransaction()
+	builder1.(*transactionBuilder).unreserved = true
+	builder2 := wt.wallet.StartTransaction()
+	builder2.(*transactionBuilder).unreserved = true
+	err = builder1.FundSiacoins(types.SiacoinPrecision.Mul64(60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = builder2.FundSiacoins(types.SiacoinPrecision.Mul64(60))
+	if err != errReservedFunds {
+		t.Fatalf("expected %v, got %v", errReservedFunds, err)
+	}
+	builder1.Drop()
+	builder2.Drop()
+
+	// Sending less than the unreserved balance should succeed.
+	_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision.Mul64(50), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := wt.miner.FindBlock()
+	err = wt.cs.AcceptBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing a reservation frees its siacoins.
+	wt.wallet.ReserveSiacoins("foo", types.ZeroCurrency)
+	wt.wallet.ReserveSiacoins("bar", types.ZeroCurrency)
+	if !wt.wallet.ReservedSiacoins().IsZero() {
+		t.Fatal("reservations were not removed")
+	}
+	_, err = wt.wallet.SendSiacoins(unreserved, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSendMaxSiacoins checks that MaxSendableSiacoins reports the full
+// unreserved balance minus the fee, and that SendMaxSiacoins empties the
+// wallet.
+func TestSendMaxSiacoins(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSendMaxSiacoins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// The wallet has a single output, so the fee should be the default fee.
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+	amount, fee, err := wt.wallet.MaxSendableSiacoins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.Cmp(defaultSendFee) != 0 {
+		t.Fatal("expected the default fee, got", fee)
+	}
+	if amount.Add(fee).Cmp(balance) != 0 {
+		t.Fatalf("expected to be able to send %v, got %v", balance.Sub(fee), amount)
+	}
+
+	// Reserved siacoins should not be counted.
+	reserved := types.SiacoinPrecision.Mul64(1000)
+	wt.wallet.ReserveSiacoins("foo", reserved)
+	amount, _, err = wt.wallet.MaxSendableSiacoins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount.Add(fee).Add(reserved).Cmp(balance) != 0 {
+		t.Fatal("reserved siacoins were counted as sendable:", amount)
+	}
+	wt.wallet.ReserveSiacoins("foo", types.ZeroCurrency)
+
+	// Send the maximum amount. The whole balance should be leaving the
+	// wallet, and the transaction set should not be larger than estimated.
+	txnSet, sent, err := wt.wallet.SendMaxSiacoins(types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Cmp(balance.Sub(fee)) != 0 {
+		t.Fatal("sent the wrong amount:", sent)
+	}
+	outgoing, incoming := wt.wallet.UnconfirmedBalance()
+	if outgoing.Sub(incoming).Cmp(balance) != 0 {
+		t.Fatal("sending the maximum amount did not empty the wallet")
+	}
+	_, estimatedSize := sendBaseSizes(balance)
+	for _, sci := range txnSet[0].SiacoinInputs {
+		estimatedSize += siacoinInputSize(sci.UnlockConditions)
+	}
+	if size := uint64(len(encoding.Marshal(txnSet))); size > estimatedSize {
+		t.Fatalf("transaction set is %v bytes, but was estimated to be at most %v bytes", size, estimatedSize)
+	}
+
+	// There should be nothing left to send.
+	_, _, err = wt.wallet.MaxSendableSiacoins()
+	if err != modules.ErrLowBalance {
+		t.Fatal("expected ErrLowBalance, got", err)
+	}
+	_, _, err = wt.wallet.SendMaxSiacoins(types.UnlockHash{})
+	if err != modules.ErrLowBalance {
+		t.Fatal("expected ErrLowBalance, got", err)
+	}
+}
@@ -36,6 +36,12 @@ type SpendableKeyFile struct {
 	SpendableKey           crypto.Ciphertext
 }
 
+// AccountPersist contains the persistent data of a wallet account.
+type AccountPersist struct {
+	Name     string
+	Progress uint64
+}
+
 // WalletPersist contains all data that persists on disk during wallet
 // operation.
 type WalletPersist struct {
@@ -61,42 +67,50 @@ type WalletPersist struct {
 	// UnseededKeys are list of spendable keys that were not generated by a
 	// random seed.
 	UnseededKeys []SpendableKeyFile
+
+	// Accounts are the named accounts of the wallet, in the order that they
+	// were created. The keys of the account at index i are derived from the
+	// primary seed starting at accountSeedIndex(i+1, 0).
+	Accounts []AccountPersist
+
+	// ScheduledPayments are the signed payments that are waiting for the
+	// blockchain to reach their height before being broadcast.
+	ScheduledPayments []modules.ScheduledPayment
 }
 
 // loadSettings reads the wallet's settings from the wallet's settings file,
 // overwriting the settings object in memory. loadSettings should only be
 // called at startup.
 func (w *Wallet) loadSettings() error {
-	return persist.LoadFile(settingsMetadata, &w.persist, filepath.Join(w.persistDir, settingsFile))
+	return persist.LoadFileBackups(settingsMetadata, &w.persist, filepath.Join(w.persistDir, settingsFile))
 }
 
 // saveSettings writes the wallet's settings to the wallet's settings file,
 // replacing the existing file.
 func (w *Wallet) saveSettings() error {
-	return persist.SaveFile(settingsMetadata, w.persist, filepath.Join(w.persistDir, settingsFile))
+	return persist.SaveFileBackups(settingsMetadata, w.persist, filepath.Join(w.persistDir, settingsFile), persist.DefaultBackups)
 }
 
 // saveSettingsSync writes the wallet's settings to the wallet's settings file,
 // replacing the existing file, and then syncs to disk.
 func (w *Wallet) saveSettingsSync() error {
-	return persist.SaveFileSync(settingsMetadata, w.persist, filepath.Join(w.persistDir, settingsFile))
+	return persist.SaveFileBackupsSync(settingsMetadata, w.persist, filepath.Join(w.persistDir, settingsFile), persist.DefaultBackups)
 }
 
 // initSettings creates the settings object at startup. If a settings file
 // exists, the settings file will be loaded into memory. If the settings file
 // does not exist, a new.persist file will be created.
 func (w *Wallet) initSettings() error {
-	// Check if the settings file exists, if not create it.
-	settingsFilename := filepath.Join(w.persistDir, settingsFile)
-	_, err := os.Stat(settingsFilename)
-	if os.IsNotExist(err) {
+	// Check if the settings file or a backup of it exists, if not create it.
+	exists, err := persist.FileBackupsExist(filepath.Join(w.persistDir, settingsFile))
+	if err != nil {
+		return err
+	} else if !exists {
 		_, err = rand.Read(w.persist.UID[:])
 		if err != nil {
 			return err
 		}
 		return w.saveSettings()
-	} else if err != nil {
-		return err
 	}
 
 	// Load the settings file if it does exist.
@@ -0,0 +1,178 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errPastScheduleHeight = errors.New("payments can only be scheduled for a height above the current block height")
+	errUnknownPayment     = errors.New("no scheduled payment with that ID")
+)
+
+// paymentsByHeight sorts scheduled payments by the height at which they are
+// broadcast.
+type paymentsByHeight []modules.ScheduledPayment
+
+func (ps paymentsByHeight) Len() int           { return len(ps) }
+func (ps paymentsByHeight) Less(i, j int) bool { return ps[i].Height < ps[j].Height }
+func (ps paymentsByHeight) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+
+// reserveScheduledOutputs marks the outputs spent by the scheduled payments
+// as spent at the current height, so that the wallet does not spend them
+// again once RespendTimeout has passed.
+func (w *Wallet) reserveScheduledOutputs() {
+	for _, sp := range w.persist.ScheduledPayments {
+		for _, txn := range sp.Transactions {
+			for _, sci := range txn.SiacoinInputs {
+				w.spentOutputs[types.OutputID(sci.ParentID)] = w.consensusSetHeight
+			}
+		}
+	}
+}
+
+// duePayments returns the scheduled payments that can be broadcast at the
+// current height.
+func (w *Wallet) duePayments() (due []modules.ScheduledPayment) {
+	for _, sp := range w.persist.ScheduledPayments {
+		if sp.Height <= w.consensusSetHeight {
+			due = append(due, sp)
+		}
+	}
+	return due
+}
+
+// removeScheduledPayment removes a scheduled payment, returning false if no
+// payment has the provided ID.
+func (w *Wallet) removeScheduledPayment(id types.TransactionID) (modules.ScheduledPayment, bool) {
+	for i, sp := range w.persist.ScheduledPayments {
+		if sp.ID == id {
+			w.persist.ScheduledPayments = append(w.persist.ScheduledPayments[:i], w.persist.ScheduledPayments[i+1:]...)
+			return sp, true
+		}
+	}
+	return modules.ScheduledPayment{}, false
+}
+
+// threadedBroadcastScheduled gives the payments that have reached their
+// height to the transaction pool. Payments that the transaction pool rejects
+// are kept, and tried again after the next block.
+func (w *Wallet) threadedBroadcastScheduled() {
+	if err := w.tg.AddNamed("threadedBroadcastScheduled"); err != nil {
+		return
+	}
+	defer w.tg.DoneNamed("threadedBroadcastScheduled")
+
+	w.mu.RLock()
+	due := w.duePayments()
+	w.mu.RUnlock()
+
+	for _, sp := range due {
+		err := w.tpool.AcceptTransactionSet(sp.Transactions)
+		if err != nil && err != modules.ErrDuplicateTransactionSet {
+			w.log.Printf("WARN: could not broadcast the payment %v scheduled for height %v: %v", sp.ID, sp.Height, err)
+			continue
+		}
+		w.mu.Lock()
+		w.removeScheduledPayment(sp.ID)
+		err = w.saveSettingsSync()
+		w.mu.Unlock()
+		if err != nil {
+			w.log.Println("WARN: could not save the scheduled payments:", err)
+		}
+	}
+}
+
+// ScheduleSiacoins creates a payment of 'amount' siacoins to 'dest' that is
+// signed immediately, but held by the wallet until the blockchain reaches
+// 'height'. The signatures are timelocked to 'height', so the payment cannot
+// be confirmed any earlier. Siacoins that are reserved by other modules are
+// not used.
+func (w *Wallet) ScheduleSiacoins(amount types.Currency, dest types.UnlockHash, height types.BlockHeight) (modules.ScheduledPayment, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.ScheduledPayment{}, err
+	}
+	defer w.tg.Done()
+
+	w.mu.RLock()
+	unlocked := w.unlocked
+	currentHeight := w.consensusSetHeight
+	w.mu.RUnlock()
+	if !unlocked {
+		return modules.ScheduledPayment{}, modules.ErrLockedWallet
+	}
+	if height <= currentHeight {
+		return modules.ScheduledPayment{}, errPastScheduleHeight
+	}
+	fee := defaultSendFee
+
+	txnBuilder := w.StartTransaction()
+	txnBuilder.(*transactionBuilder).timelock = height
+	txnBuilder.(*transactionBuilder).unreserved = true
+	err := txnBuilder.FundSiacoins(amount.Add(fee))
+	if err != nil {
+		return modules.ScheduledPayment{}, err
+	}
+	txnBuilder.AddMinerFee(fee)
+	txnBuilder.AddSiacoinOutput(types.SiacoinOutput{
+		Value:      amount,
+		UnlockHash: dest,
+	})
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		return modules.ScheduledPayment{}, err
+	}
+	sp := modules.ScheduledPayment{
+		ID:           txnSet[len(txnSet)-1].ID(),
+		Amount:       amount,
+		Destination:  dest,
+		Height:       height,
+		Transactions: txnSet,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.persist.ScheduledPayments = append(w.persist.ScheduledPayments, sp)
+	w.reserveScheduledOutputs()
+	if err := w.saveSettingsSync(); err != nil {
+		w.removeScheduledPayment(sp.ID)
+		return modules.ScheduledPayment{}, err
+	}
+	return sp, nil
+}
+
+// ScheduledPayments returns the payments that are waiting to be broadcast,
+// ordered by height.
+func (w *Wallet) ScheduledPayments() []modules.ScheduledPayment {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	payments := append([]modules.ScheduledPayment(nil), w.persist.ScheduledPayments...)
+	sort.Stable(paymentsByHeight(payments))
+	return payments
+}
+
+// CancelScheduledPayment discards a scheduled payment that has not been
+// broadcast yet. The outputs that funded the payment can be spent again
+// immediately.
+func (w *Wallet) CancelScheduledPayment(id types.TransactionID) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	sp, exists := w.removeScheduledPayment(id)
+	if !exists {
+		return errUnknownPayment
+	}
+	for _, txn := range sp.Transactions {
+		for _, sci := range txn.SiacoinInputs {
+			delete(w.spentOutputs, types.OutputID(sci.ParentID))
+		}
+	}
+	return w.saveSettingsSync()
+}
@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestScheduleSiacoins checks that a scheduled payment is held until its
+// height, and is then broadcast automatically.
+func TestScheduleSiacoins(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestScheduleSiacoins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	height := wt.cs.Height()
+	_, err = wt.wallet.ScheduleSiacoins(types.NewCurrency64(5000), types.UnlockHash{}, height)
+	if err != errPastScheduleHeight {
+		t.Fatal("expected errPastScheduleHeight, got", err)
+	}
+	sp, err := wt.wallet.ScheduleSiacoins(types.NewCurrency64(5000), types.UnlockHash{}, height+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments := wt.wallet.ScheduledPayments()
+	if len(payments) != 1 || payments[0].ID != sp.ID {
+		t.Fatal("payment was not scheduled:", payments)
+	}
+	child := sp.Transactions[len(sp.Transactions)-1]
+	for _, sig := range child.TransactionSignatures {
+		if sig.Timelock != height+2 {
+			t.Fatal("signature was not timelocked:", sig.Timelock)
+		}
+	}
+
+	// The payment is not valid yet.
+	if err := wt.tpool.AcceptTransactionSet(sp.Transactions); err == nil {
+		t.Fatal("transaction pool accepted a premature payment")
+	}
+
+	// The outputs funding the payment should stay reserved past the respend
+	// timeout.
+	wt.wallet.mu.Lock()
+	wt.wallet.consensusSetHeight += RespendTimeout + 1
+	spendable, _ := wt.wallet.spendableSiacoinOutputs(0)
+	wt.wallet.consensusSetHeight -= RespendTimeout + 1
+	wt.wallet.mu.Unlock()
+	for _, id := range spendable.ids {
+		for _, sci := range sp.Transactions[0].SiacoinInputs {
+			if id == sci.ParentID {
+				t.Fatal("output funding a scheduled payment is spendable")
+			}
+		}
+	}
+
+	// Once the height is reached, the payment is broadcast.
+	for i := 0; i < 2; i++ {
+		if err := wt.addBlockNoPayout(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 50 && len(wt.wallet.ScheduledPayments()) != 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(wt.wallet.ScheduledPayments()) != 0 {
+		t.Fatal("payment was not broadcast")
+	}
+	found := false
+	for _, txn := range wt.tpool.TransactionList() {
+		if txn.ID() == sp.ID {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("payment is not in the transaction pool")
+	}
+}
+
+// TestCancelScheduledPayment checks that cancelling a scheduled payment
+// releases the outputs that funded it.
+func TestCancelScheduledPayment(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestCancelScheduledPayment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	sp, err := wt.wallet.ScheduleSiacoins(types.NewCurrency64(5000), types.UnlockHash{}, wt.cs.Height()+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.CancelScheduledPayment(types.TransactionID{}); err != errUnknownPayment {
+		t.Fatal("expected errUnknownPayment, got", err)
+	}
+	if err := wt.wallet.CancelScheduledPayment(sp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(wt.wallet.ScheduledPayments()) != 0 {
+		t.Fatal("payment was not cancelled")
+	}
+
+	// The whole balance should be spendable again.
+	if _, _, err := wt.wallet.SendMaxSiacoins(types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+}
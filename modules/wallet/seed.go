@@ -40,13 +40,7 @@ type (
 // generateUnlockConditions provides the unlock conditions that would be
 // automatically generated from the input public key.
 func generateUnlockConditions(pk crypto.PublicKey) types.UnlockConditions {
-	return types.UnlockConditions{
-		PublicKeys: []types.SiaPublicKey{{
-			Algorithm: types.SignatureEd25519,
-			Key:       pk[:],
-		}},
-		SignaturesRequired: 1,
-	}
+	return modules.StandardUnlockConditions(pk)
 }
 
 // generateSpendableKey creates the keys and unlock conditions a given index of a
@@ -61,6 +55,14 @@ func generateSpendableKey(seed modules.Seed, index uint64) spendableKey {
 	}
 }
 
+// generateKeyAtIndex derives the keypair at a given index of a seed for the
+// specified purpose. The purpose is mixed into the entropy so that the keys
+// can never collide with the spendable keys of the seed.
+func generateKeyAtIndex(seed modules.Seed, purpose types.Specifier, index uint64) (crypto.SecretKey, crypto.PublicKey) {
+	entropy := crypto.HashAll(seed, purpose, index)
+	return crypto.GenerateKeyPairDeterministic(entropy)
+}
+
 // encryptAndSaveSeedFile encrypts and saves a seed file.
 func (w *Wallet) encryptAndSaveSeedFile(masterKey crypto.TwofishKey, seed modules.Seed) (SeedFile, error) {
 	var sf SeedFile
@@ -109,19 +111,22 @@ func decryptSeedFile(masterKey crypto.TwofishKey, sf SeedFile) (seed modules.See
 }
 
 // integrateSeed takes an address seed as input and from that generates
-// 'publicKeysPerSeed' addresses that the wallet is able to spend.
+// 'publicKeysPerSeed' addresses that the wallet is able to spend. The fraction
+// of the addresses that have been generated is reported to 'progress'.
 // integrateSeed should not be called with the primary seed.
-func (w *Wallet) integrateSeed(seed modules.Seed) {
+func (w *Wallet) integrateSeed(seed modules.Seed, progress modules.ProgressFunc) {
 	for i := uint64(0); i < modules.PublicKeysPerSeed; i++ {
 		// Generate the key and check it is new to the wallet.
 		spendableKey := generateSpendableKey(seed, i)
 		w.keys[spendableKey.UnlockConditions.UnlockHash()] = spendableKey
+		progress.Report(float64(i+1) / modules.PublicKeysPerSeed)
 	}
 	w.seeds = append(w.seeds, seed)
 }
 
-// recoverSeed integrates a recovery seed into the wallet.
-func (w *Wallet) recoverSeed(masterKey crypto.TwofishKey, seed modules.Seed) error {
+// recoverSeed integrates a recovery seed into the wallet, reporting the
+// progress of generating its addresses to 'progress'.
+func (w *Wallet) recoverSeed(masterKey crypto.TwofishKey, seed modules.Seed, progress modules.ProgressFunc) error {
 	// Because the recovery seed does not have a UID, duplication must be
 	// prevented by comparing with the list of decrypted seeds. This can only
 	// occur while the wallet is unlocked.
@@ -150,7 +155,7 @@ func (w *Wallet) recoverSeed(masterKey crypto.TwofishKey, seed modules.Seed) err
 	if err != nil {
 		return err
 	}
-	w.integrateSeed(seed)
+	w.integrateSeed(seed, progress)
 	return nil
 
 }
@@ -189,6 +194,7 @@ func (w *Wallet) initPrimarySeed(masterKey crypto.TwofishKey) error {
 	}
 	w.primarySeed = seed
 	w.seeds = append(w.seeds, seed)
+	w.initAccountKeys()
 	return nil
 }
 
@@ -203,7 +209,7 @@ func (w *Wallet) initAuxiliarySeeds(masterKey crypto.TwofishKey) error {
 			w.log.Println("UNLOCK: failed to load an auxiliary seed:", err)
 			continue
 		}
-		w.integrateSeed(seed)
+		w.integrateSeed(seed, nil)
 	}
 	return nil
 }
@@ -248,6 +254,20 @@ func (w *Wallet) PrimarySeed() (modules.Seed, uint64, error) {
 	return w.primarySeed, w.persist.PrimarySeedProgress, nil
 }
 
+// KeyAtIndex derives the keypair at the given index of the primary seed for
+// the specified purpose. Keys are derived rather than stored, so calling
+// KeyAtIndex with the same arguments will always produce the same keypair,
+// including after the wallet has been restored from its seed.
+func (w *Wallet) KeyAtIndex(purpose types.Specifier, index uint64) (crypto.SecretKey, crypto.PublicKey, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return crypto.SecretKey{}, crypto.PublicKey{}, modules.ErrLockedWallet
+	}
+	sk, pk := generateKeyAtIndex(w.primarySeed, purpose, index)
+	return sk, pk, nil
+}
+
 // NextAddress returns an unlock hash that is ready to receive siacoins or
 // siafunds. The address is generated using the primary address seed.
 func (w *Wallet) NextAddress() (types.UnlockConditions, error) {
@@ -263,8 +283,9 @@ func (w *Wallet) NextAddress() (types.UnlockConditions, error) {
 // LoadSeed will track all of the addresses generated by the input seed,
 // reclaiming any funds that were lost due to a deleted file or lost encryption
 // key. An error will be returned if the seed has already been integrated with
-// the wallet.
-func (w *Wallet) LoadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error {
+// the wallet. The progress of generating the addresses is reported to
+// 'progress'.
+func (w *Wallet) LoadSeed(masterKey crypto.TwofishKey, seed modules.Seed, progress modules.ProgressFunc) error {
 	if err := w.tg.Add(); err != nil {
 		return err
 	}
@@ -275,5 +296,5 @@ func (w *Wallet) LoadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error
 	if err != nil {
 		return err
 	}
-	return w.recoverSeed(masterKey, seed)
+	return w.recoverSeed(masterKey, seed, progress)
 }
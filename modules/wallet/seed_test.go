@@ -82,6 +82,59 @@ func TestPrimarySeed(t *testing.T) {
 	}
 }
 
+// TestKeyAtIndex checks that KeyAtIndex derives keys deterministically and
+// that keys for different purposes and indices are distinct.
+func TestKeyAtIndex(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createBlankWalletTester("TestKeyAtIndex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// KeyAtIndex should fail while the wallet is locked.
+	_, _, err = wt.wallet.KeyAtIndex(modules.KeyPurposeRenterContract, 0)
+	if err != modules.ErrLockedWallet {
+		t.Fatal("expected ErrLockedWallet, got", err)
+	}
+
+	seed, err := wt.wallet.Encrypt(crypto.TwofishKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.Unlock(crypto.TwofishKey(crypto.HashObject(seed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sk, pk, err := wt.wallet.KeyAtIndex(modules.KeyPurposeRenterContract, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.PublicKey() != pk {
+		t.Error("derived public key does not match derived secret key")
+	}
+	sk2, _, err := wt.wallet.KeyAtIndex(modules.KeyPurposeRenterContract, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk != sk2 {
+		t.Error("KeyAtIndex is not deterministic")
+	}
+	skIndex, _, _ := wt.wallet.KeyAtIndex(modules.KeyPurposeRenterContract, 1)
+	skPurpose, _, _ := wt.wallet.KeyAtIndex(modules.KeyPurposeHostSigning, 0)
+	if sk == skIndex || sk == skPurpose {
+		t.Error("KeyAtIndex returned the same key for different inputs")
+	}
+
+	// The derived keys should not overlap with the spendable keys.
+	if generateSpendableKey(seed, 0).SecretKeys[0] == sk {
+		t.Error("KeyAtIndex collides with spendable keys")
+	}
+}
+
 // TestLoadSeed checks that a seed can be successfully recovered from a wallet,
 // and then remain available on subsequent loads of the wallet.
 func TestLoadSeed(t *testing.T) {
@@ -126,10 +179,19 @@ func TestLoadSeed(t *testing.T) {
 	if siacoinBal.Cmp(types.NewCurrency64(0)) != 0 {
 		t.Error("fresh wallet should not have a balance")
 	}
-	err = w.LoadSeed(crypto.TwofishKey(crypto.HashObject(newSeed)), seed)
+	var progress float64
+	err = w.LoadSeed(crypto.TwofishKey(crypto.HashObject(newSeed)), seed, func(fraction float64) {
+		if fraction < progress {
+			t.Error("progress went backwards")
+		}
+		progress = fraction
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if progress != 1 {
+		t.Error("loading the seed did not report its completion:", progress)
+	}
 	allSeeds, err = w.AllSeeds()
 	if err != nil {
 		t.Fatal(err)
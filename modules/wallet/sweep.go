@@ -0,0 +1,289 @@
+package wallet
+
+// sweep.go moves the coins and funds held by secret keys that are not part of
+// the wallet, such as the keys of paper wallets, to addresses of the primary
+// seed. Unlike loading a key, sweeping does not require a rescan, and the key
+// does not need to be kept once the sweep is confirmed.
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errNothingToSweep is returned if the swept keys hold no outputs.
+	errNothingToSweep = errors.New("no outputs are spendable by the provided keys")
+
+	// errSweepBelowFee is returned if the swept siacoins do not cover the
+	// miner fee and the wallet has no siacoins to pay it.
+	errSweepBelowFee = errors.New("swept siacoins do not cover the miner fee")
+)
+
+// sweepParentReserve is the number of bytes of a sweep transaction that are
+// left for the input and refund output added when the wallet pays the miner
+// fee.
+const sweepParentReserve = 1e3
+
+// sweepScanner records the outputs of the snapshot sent when subscribing to
+// the consensus set, ignoring any later changes.
+type sweepScanner struct {
+	siacoinOutputs []modules.SiacoinOutputDiff
+	siafundOutputs []modules.SiafundOutputDiff
+	received       bool
+}
+
+// ProcessConsensusChange records the outputs of the first consensus change it
+// receives.
+func (ss *sweepScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	if ss.received {
+		return
+	}
+	ss.received = true
+	for _, diff := range cc.SiacoinOutputDiffs {
+		if diff.Direction == modules.DiffApply {
+			ss.siacoinOutputs = append(ss.siacoinOutputs, diff)
+		}
+	}
+	for _, diff := range cc.SiafundOutputDiffs {
+		if diff.Direction == modules.DiffApply {
+			ss.siafundOutputs = append(ss.siafundOutputs, diff)
+		}
+	}
+}
+
+// sweepBatch is a set of outputs that is swept by a single transaction.
+type sweepBatch struct {
+	siacoinOutputs []modules.SiacoinOutputDiff
+	siafundOutputs []modules.SiafundOutputDiff
+}
+
+// draft returns a transaction of the same encoded size as the transaction
+// sweeping the batch, without its signatures.
+func (sb sweepBatch) draft(keys map[types.UnlockHash]spendableKey) types.Transaction {
+	var txn types.Transaction
+	var siacoins, siafunds types.Currency
+	for _, diff := range sb.siacoinOutputs {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         diff.ID,
+			UnlockConditions: keys[diff.SiacoinOutput.UnlockHash].UnlockConditions,
+		})
+		siacoins = siacoins.Add(diff.SiacoinOutput.Value)
+	}
+	for _, diff := range sb.siafundOutputs {
+		txn.SiafundInputs = append(txn.SiafundInputs, types.SiafundInput{
+			ParentID:         diff.ID,
+			UnlockConditions: keys[diff.SiafundOutput.UnlockHash].UnlockConditions,
+		})
+		siafunds = siafunds.Add(diff.SiafundOutput.Value)
+	}
+	txn.SiacoinOutputs = []types.SiacoinOutput{{Value: siacoins}}
+	txn.MinerFees = []types.Currency{siacoins}
+	if len(sb.siafundOutputs) > 0 {
+		txn.SiafundOutputs = []types.SiafundOutput{{Value: siafunds}}
+	}
+	return txn
+}
+
+// size returns the encoded size of the transaction sweeping the batch,
+// including its signatures.
+func (sb sweepBatch) size(keys map[types.UnlockHash]spendableKey) uint64 {
+	size := uint64(len(encoding.Marshal(sb.draft(keys))))
+	return size + uint64(len(sb.siacoinOutputs)+len(sb.siafundOutputs))*signatureSize()
+}
+
+// batchSweepOutputs splits the outputs into batches that each fit in a
+// standard transaction, leaving room for the wallet to pay the miner fee.
+func batchSweepOutputs(scos []modules.SiacoinOutputDiff, sfos []modules.SiafundOutputDiff, keys map[types.UnlockHash]spendableKey) []sweepBatch {
+	var batches []sweepBatch
+	var current sweepBatch
+	full := func() bool {
+		return current.size(keys)+sweepParentReserve > modules.TransactionSizeLimit
+	}
+	// Siafund outputs are swept first, so that the siacoins of the first
+	// batches can pay their fees.
+	for _, diff := range sfos {
+		current.siafundOutputs = append(current.siafundOutputs, diff)
+		if full() && len(current.siafundOutputs) > 1 {
+			current.siafundOutputs = current.siafundOutputs[:len(current.siafundOutputs)-1]
+			batches = append(batches, current)
+			current = sweepBatch{siafundOutputs: []modules.SiafundOutputDiff{diff}}
+		}
+	}
+	for _, diff := range scos {
+		current.siacoinOutputs = append(current.siacoinOutputs, diff)
+		if full() && len(current.siacoinOutputs)+len(current.siafundOutputs) > 1 {
+			current.siacoinOutputs = current.siacoinOutputs[:len(current.siacoinOutputs)-1]
+			batches = append(batches, current)
+			current = sweepBatch{siacoinOutputs: []modules.SiacoinOutputDiff{diff}}
+		}
+	}
+	if len(current.siacoinOutputs)+len(current.siafundOutputs) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// managedSweepBatch creates a transaction sending the outputs of the batch to
+// 'dest'. If the swept siacoins do not cover the miner fee, the wallet pays
+// it, without using any of the outputs in 'excluded'. The transaction is
+// submitted to the transaction pool, and the swept outputs are marked as spent
+// once it has been accepted. The swept siacoins, after the fee, are returned.
+func (w *Wallet) managedSweepBatch(sb sweepBatch, keys map[types.UnlockHash]spendableKey, dest types.UnlockHash, excluded map[types.SiacoinOutputID]bool) (types.Currency, error) {
+	_, feeRate := w.tpool.FeeEstimation()
+	fee := feeRate.Mul64(sb.size(keys) + sweepParentReserve)
+	if fee.Cmp(defaultSendFee) < 0 {
+		fee = defaultSendFee
+	}
+
+	txnBuilder := w.StartTransaction()
+	txnBuilder.(*transactionBuilder).unreserved = true
+	txnBuilder.(*transactionBuilder).excluded = excluded
+	var siacoins, siafunds types.Currency
+	for _, diff := range sb.siacoinOutputs {
+		txnBuilder.AddSiacoinInput(types.SiacoinInput{
+			ParentID:         diff.ID,
+			UnlockConditions: keys[diff.SiacoinOutput.UnlockHash].UnlockConditions,
+		})
+		siacoins = siacoins.Add(diff.SiacoinOutput.Value)
+	}
+	for _, diff := range sb.siafundOutputs {
+		txnBuilder.AddSiafundInput(types.SiafundInput{
+			ParentID:         diff.ID,
+			UnlockConditions: keys[diff.SiafundOutput.UnlockHash].UnlockConditions,
+			ClaimUnlockHash:  dest,
+		})
+		siafunds = siafunds.Add(diff.SiafundOutput.Value)
+	}
+
+	// Pay the fee from the swept siacoins if possible, and from the wallet
+	// otherwise.
+	var swept types.Currency
+	if siacoins.Cmp(fee) >= 0 {
+		swept = siacoins.Sub(fee)
+		if !swept.IsZero() {
+			txnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: swept, UnlockHash: dest})
+		}
+	} else {
+		if err := txnBuilder.FundSiacoins(fee.Sub(siacoins)); err != nil {
+			txnBuilder.Drop()
+			return types.Currency{}, errSweepBelowFee
+		}
+	}
+	txnBuilder.AddMinerFee(fee)
+	if len(sb.siafundOutputs) > 0 {
+		txnBuilder.AddSiafundOutput(types.SiafundOutput{Value: siafunds, UnlockHash: dest})
+	}
+
+	// Sign the inputs added by the builder, then the swept inputs. Neither
+	// set of signatures covers the other.
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		txnBuilder.Drop()
+		return types.Currency{}, err
+	}
+	txn := &txnSet[len(txnSet)-1]
+	for _, sci := range txn.SiacoinInputs {
+		key, exists := keys[sci.UnlockConditions.UnlockHash()]
+		if !exists {
+			continue
+		}
+		_, err := addSignatures(txn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), key, 0)
+		if err != nil {
+			txnBuilder.Drop()
+			return types.Currency{}, err
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		key := keys[sfi.UnlockConditions.UnlockHash()]
+		_, err := addSignatures(txn, types.FullCoveredFields, sfi.UnlockConditions, crypto.Hash(sfi.ParentID), key, 0)
+		if err != nil {
+			txnBuilder.Drop()
+			return types.Currency{}, err
+		}
+	}
+
+	err = w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		txnBuilder.Drop()
+		return types.Currency{}, err
+	}
+
+	// Outputs that the wallet also tracks, for example because their key was
+	// loaded, are spent by the sweep.
+	w.mu.Lock()
+	for _, diff := range sb.siacoinOutputs {
+		w.spentOutputs[types.OutputID(diff.ID)] = w.consensusSetHeight
+	}
+	w.mu.Unlock()
+	return swept, nil
+}
+
+// SweepKeys sends every output spendable by the provided secret keys to a new
+// address of the primary seed. Each key is expected to spend from the address
+// with the standard unlock conditions of its public key, such as the keys
+// generated by modules.NewKeyPair. The siacoins and siafunds that were swept
+// are returned; miner fees are deducted from the siacoins. Outputs that do not
+// fit in a single transaction are swept by several transactions.
+func (w *Wallet) SweepKeys(sks []crypto.SecretKey) (coins, funds types.Currency, err error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Currency{}, types.Currency{}, err
+	}
+	defer w.tg.Done()
+	if w.cs == nil {
+		return types.Currency{}, types.Currency{}, errLightWallet
+	}
+
+	keys := make(map[types.UnlockHash]spendableKey)
+	for _, sk := range sks {
+		uc := generateUnlockConditions(sk.PublicKey())
+		keys[uc.UnlockHash()] = spendableKey{
+			UnlockConditions: uc,
+			SecretKeys:       []crypto.SecretKey{sk},
+		}
+	}
+
+	// Find the outputs of the keys in the current consensus set. Subscribing
+	// from the most recent change sends a snapshot without any blocks.
+	var ss sweepScanner
+	err = w.cs.ConsensusSetSnapshotSubscribe(&ss, modules.ConsensusChangeRecent, func(uh types.UnlockHash) bool {
+		_, exists := keys[uh]
+		return exists
+	})
+	if err != nil {
+		return types.Currency{}, types.Currency{}, err
+	}
+	w.cs.Unsubscribe(&ss)
+	if len(ss.siacoinOutputs) == 0 && len(ss.siafundOutputs) == 0 {
+		return types.Currency{}, types.Currency{}, errNothingToSweep
+	}
+
+	w.mu.Lock()
+	uc, err := w.nextPrimarySeedAddress()
+	w.mu.Unlock()
+	if err != nil {
+		return types.Currency{}, types.Currency{}, err
+	}
+	dest := uc.UnlockHash()
+
+	// Outputs that the wallet also tracks must not be used to pay the fees
+	// of the sweep, as they are spent by the sweep itself.
+	excluded := make(map[types.SiacoinOutputID]bool)
+	for _, diff := range ss.siacoinOutputs {
+		excluded[diff.ID] = true
+	}
+	for _, sb := range batchSweepOutputs(ss.siacoinOutputs, ss.siafundOutputs, keys) {
+		swept, err := w.managedSweepBatch(sb, keys, dest, excluded)
+		if err != nil {
+			return coins, funds, err
+		}
+		coins = coins.Add(swept)
+		for _, diff := range sb.siafundOutputs {
+			funds = funds.Add(diff.SiafundOutput.Value)
+		}
+	}
+	return coins, funds, nil
+}
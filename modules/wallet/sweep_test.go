@@ -0,0 +1,217 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationSweepKeys sweeps the siacoins sent to the addresses of two
+// standalone key pairs into the wallet.
+func TestIntegrationSweepKeys(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSweepKeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Sweeping keys without outputs is an error.
+	kp1, err := modules.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kp2, err := modules.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk1, err := modules.ParseSecretKey(kp1.SecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk2, err := modules.ParseSecretKey(kp2.SecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = wt.wallet.SweepKeys([]crypto.SecretKey{sk1, sk2})
+	if err != errNothingToSweep {
+		t.Fatal("expected errNothingToSweep, got", err)
+	}
+
+	// Send coins to the key pairs, twice to the first one.
+	amount := types.SiacoinPrecision.Mul64(100)
+	for _, addr := range []types.UnlockHash{kp1.Address, kp1.Address, kp2.Address} {
+		_, err = wt.wallet.SendSiacoins(amount, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _, _ := wt.wallet.ConfirmedBalance()
+
+	coins, funds, err := wt.wallet.SweepKeys([]crypto.SecretKey{sk1, sk2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !funds.IsZero() {
+		t.Error("swept siafunds from keys without siafunds:", funds)
+	}
+	if coins.Cmp(amount.Mul64(3)) >= 0 || coins.Cmp(amount.Mul64(3).Sub(defaultSendFee.Mul64(10))) < 0 {
+		t.Fatal("unexpected amount of siacoins swept:", coins)
+	}
+
+	// The block reward is mined to the wallet too, so the balance must grow
+	// by at least the swept siacoins.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _, _ := wt.wallet.ConfirmedBalance()
+	if after.Cmp(before.Add(coins)) < 0 {
+		t.Fatal("swept siacoins did not arrive in the wallet")
+	}
+	_, _, err = wt.wallet.SweepKeys([]crypto.SecretKey{sk1, sk2})
+	if err != errNothingToSweep {
+		t.Fatal("expected errNothingToSweep after sweeping, got", err)
+	}
+}
+
+// TestIntegrationSweepFailure checks that the outputs of a sweep that is not
+// accepted are not marked as spent.
+func TestIntegrationSweepFailure(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSweepFailure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Send the key pair less than the miner fee, and reserve the whole
+	// balance so that the wallet cannot pay the fee either.
+	kp, err := modules.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := modules.ParseSecretKey(kp.SecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, kp.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id types.SiacoinOutputID
+	for _, txn := range txns {
+		for i, sco := range txn.SiacoinOutputs {
+			if sco.UnlockHash == kp.Address {
+				id = txn.SiacoinOutputID(uint64(i))
+			}
+		}
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+	wt.wallet.ReserveSiacoins("foo", balance)
+
+	_, _, err = wt.wallet.SweepKeys([]crypto.SecretKey{sk})
+	if err != errSweepBelowFee {
+		t.Fatal("expected errSweepBelowFee, got", err)
+	}
+	wt.wallet.mu.Lock()
+	_, spent := wt.wallet.spentOutputs[types.OutputID(id)]
+	wt.wallet.mu.Unlock()
+	if spent {
+		t.Fatal("output of a failed sweep was marked as spent")
+	}
+}
+
+// TestIntegrationSweepSiag sweeps the siafunds of a 1 of 1 siag key into the
+// wallet, which pays the miner fee. The key is taken from the testing keys.
+func TestIntegrationSweepSiag(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSweepSiag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	var skp SiagKeyPair
+	err = encoding.ReadFile("../../types/siag0of1of1.siakey", &skp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coins, funds, err := wt.wallet.SweepKeys([]crypto.SecretKey{skp.SecretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coins.IsZero() || funds.Cmp(types.NewCurrency64(2000)) != 0 {
+		t.Fatal("expected to sweep 2000 siafunds, got", coins, funds)
+	}
+
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	if siafundBal.Cmp(types.NewCurrency64(2000)) != 0 {
+		t.Error("expecting a siafund balance of 2000 after sweeping the 1of1 key, got", siafundBal)
+	}
+}
+
+// TestBatchSweepOutputs checks that outputs that do not fit in a single
+// transaction are split across several batches.
+func TestBatchSweepOutputs(t *testing.T) {
+	sk, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := generateUnlockConditions(sk.PublicKey())
+	keys := map[types.UnlockHash]spendableKey{
+		uc.UnlockHash(): {UnlockConditions: uc, SecretKeys: []crypto.SecretKey{sk}},
+	}
+
+	var scos []modules.SiacoinOutputDiff
+	for i := 0; i < 500; i++ {
+		scos = append(scos, modules.SiacoinOutputDiff{
+			Direction:     modules.DiffApply,
+			ID:            types.SiacoinOutputID{byte(i), byte(i >> 8)},
+			SiacoinOutput: types.SiacoinOutput{Value: types.SiacoinPrecision, UnlockHash: uc.UnlockHash()},
+		})
+	}
+	sfos := []modules.SiafundOutputDiff{{
+		Direction:     modules.DiffApply,
+		SiafundOutput: types.SiafundOutput{Value: types.NewCurrency64(1), UnlockHash: uc.UnlockHash()},
+	}}
+
+	batches := batchSweepOutputs(scos, sfos, keys)
+	if len(batches) < 2 {
+		t.Fatal("expected the outputs to be split across batches, got", len(batches))
+	}
+	if len(batches[0].siafundOutputs) != 1 {
+		t.Error("siafund output should be swept by the first batch")
+	}
+	total := 0
+	for _, sb := range batches {
+		if sb.size(keys)+sweepParentReserve > modules.TransactionSizeLimit {
+			t.Error("batch does not fit in a standard transaction")
+		}
+		total += len(sb.siacoinOutputs)
+	}
+	if total != len(scos) {
+		t.Errorf("expected %v siacoin outputs in the batches, got %v", len(scos), total)
+	}
+}
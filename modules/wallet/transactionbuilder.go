@@ -32,13 +32,32 @@ type transactionBuilder struct {
 	siafundInputs         []int
 	transactionSignatures []int
 
+	// timelock is the height before which the signatures added by 'Sign'
+	// are not valid. It is only set by the wallet when scheduling payments.
+	timelock types.BlockHeight
+
+	// account is the wallet account that funds the transaction. Account 0
+	// is the wallet itself.
+	account uint64
+
+	// unreserved is set if the transaction may not spend siacoins that are
+	// reserved by other modules. It is only set by the wallet's own
+	// payments, as the modules that reserve siacoins build their
+	// transactions with the same builders.
+	unreserved bool
+
+	// excluded holds the outputs that may not fund the transaction, such as
+	// the outputs of keys that are being swept.
+	excluded map[types.SiacoinOutputID]bool
+
 	wallet *Wallet
 }
 
 // addSignatures will sign a transaction using a spendable key, with support
 // for multisig spendable keys. Because of the restricted input, the function
-// is compatible with both siacoin inputs and siafund inputs.
-func addSignatures(txn *types.Transaction, cf types.CoveredFields, uc types.UnlockConditions, parentID crypto.Hash, spendKey spendableKey) (newSigIndices []int, err error) {
+// is compatible with both siacoin inputs and siafund inputs. The signatures
+// are not valid before the height 'timelock'.
+func addSignatures(txn *types.Transaction, cf types.CoveredFields, uc types.UnlockConditions, parentID crypto.Hash, spendKey spendableKey, timelock types.BlockHeight) (newSigIndices []int, err error) {
 	// Try to find the matching secret key for each public key - some public
 	// keys may not have a match. Some secret keys may be used multiple times,
 	// which is why public keys are used as the outer loop.
@@ -56,6 +75,7 @@ func addSignatures(txn *types.Transaction, cf types.CoveredFields, uc types.Unlo
 				ParentID:       parentID,
 				CoveredFields:  cf,
 				PublicKeyIndex: uint64(i),
+				Timelock:       timelock,
 			}
 			newSigIndices = append(newSigIndices, len(txn.TransactionSignatures))
 			txn.TransactionSignatures = append(txn.TransactionSignatures, sig)
@@ -90,69 +110,38 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	tb.wallet.mu.Lock()
 	defer tb.wallet.mu.Unlock()
 
-	// Collect a value-sorted set of siacoin outputs.
-	var so sortedOutputs
-	for scoid, sco := range tb.wallet.siacoinOutputs {
-		so.ids = append(so.ids, scoid)
-		so.outputs = append(so.outputs, sco)
-	}
-	// Add all of the unconfirmed outputs as well.
-	for _, upt := range tb.wallet.unconfirmedProcessedTransactions {
-		for i, sco := range upt.Transaction.SiacoinOutputs {
-			// Determine if the output belongs to the wallet.
-			_, exists := tb.wallet.keys[sco.UnlockHash]
-			if !exists {
-				continue
+	// Collect the outputs that can be spent. potentialFund tracks the balance
+	// of the wallet including outputs that have been spent in other
+	// unconfirmed transactions recently. This is to provide the user with a
+	// more useful error message in the event that they are overspending.
+	spendable, potentialFund := tb.wallet.spendableSiacoinOutputs(tb.account)
+	if len(tb.excluded) > 0 {
+		var kept sortedOutputs
+		for i, id := range spendable.ids {
+			if !tb.excluded[id] {
+				kept.ids = append(kept.ids, id)
+				kept.outputs = append(kept.outputs, spendable.outputs[i])
 			}
-			so.ids = append(so.ids, upt.Transaction.SiacoinOutputID(uint64(i)))
-			so.outputs = append(so.outputs, sco)
 		}
+		spendable = kept
+	}
+	spendableValues := make([]types.Currency, len(spendable.outputs))
+	for i, sco := range spendable.outputs {
+		spendableValues[i] = sco.Value
 	}
-	sort.Sort(sort.Reverse(so))
 
 	// Create and fund a parent transaction that will add the correct amount of
 	// siacoins to the transaction.
-	var fund types.Currency
-	// potentialFund tracks the balance of the wallet including outputs that
-	// have been spent in other unconfirmed transactions recently. This is to
-	// provide the user with a more useful error message in the event that they
-	// are overspending.
-	var potentialFund types.Currency
+	selected, fund := selectSiacoinOutputs(spendableValues, amount)
 	parentTxn := types.Transaction{}
 	var spentScoids []types.SiacoinOutputID
-	for i := range so.ids {
-		scoid := so.ids[i]
-		sco := so.outputs[i]
-		// Check that this output has not recently been spent by the wallet.
-		spendHeight := tb.wallet.spentOutputs[types.OutputID(scoid)]
-		// Prevent an underflow error.
-		allowedHeight := tb.wallet.consensusSetHeight - RespendTimeout
-		if tb.wallet.consensusSetHeight < RespendTimeout {
-			allowedHeight = 0
-		}
-		if spendHeight > allowedHeight {
-			potentialFund = potentialFund.Add(sco.Value)
-			continue
-		}
-		outputUnlockConditions := tb.wallet.keys[sco.UnlockHash].UnlockConditions
-		if tb.wallet.consensusSetHeight < outputUnlockConditions.Timelock {
-			continue
-		}
-
-		// Add a siacoin input for this output.
+	for _, i := range selected {
 		sci := types.SiacoinInput{
-			ParentID:         scoid,
-			UnlockConditions: outputUnlockConditions,
+			ParentID:         spendable.ids[i],
+			UnlockConditions: tb.wallet.keys[spendable.outputs[i].UnlockHash].UnlockConditions,
 		}
 		parentTxn.SiacoinInputs = append(parentTxn.SiacoinInputs, sci)
-		spentScoids = append(spentScoids, scoid)
-
-		// Add the output to the total fund
-		fund = fund.Add(sco.Value)
-		potentialFund = potentialFund.Add(sco.Value)
-		if fund.Cmp(amount) >= 0 {
-			break
-		}
+		spentScoids = append(spentScoids, spendable.ids[i])
 	}
 	if potentialFund.Cmp(amount) >= 0 && fund.Cmp(amount) < 0 {
 		return modules.ErrIncompleteTransactions
@@ -160,10 +149,21 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	if fund.Cmp(amount) < 0 {
 		return modules.ErrLowBalance
 	}
+	// The reservations are checked while the wallet is locked, so that
+	// concurrent payments cannot each spend the same unreserved siacoins.
+	if tb.unreserved {
+		var available types.Currency
+		for _, value := range spendableValues {
+			available = available.Add(value)
+		}
+		if available.Cmp(tb.wallet.reservedSiacoins().Add(amount)) < 0 {
+			return errReservedFunds
+		}
+	}
 
 	// Create and add the output that will be used to fund the standard
 	// transaction.
-	parentUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
+	parentUnlockConditions, err := tb.wallet.nextAccountAddress(tb.account)
 	if err != nil {
 		return err
 	}
@@ -173,22 +173,25 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	}
 	parentTxn.SiacoinOutputs = append(parentTxn.SiacoinOutputs, exactOutput)
 
-	// Create a refund output if needed.
-	if amount.Cmp(fund) != 0 {
-		refundUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
+	// Create a refund output if needed. Change that is too small to be worth
+	// spending is added to the miner fees instead.
+	if change := fund.Sub(amount); change.Cmp(dustThreshold) >= 0 {
+		refundUnlockConditions, err := tb.wallet.nextAccountAddress(tb.account)
 		if err != nil {
 			return err
 		}
 		refundOutput := types.SiacoinOutput{
-			Value:      fund.Sub(amount),
+			Value:      change,
 			UnlockHash: refundUnlockConditions.UnlockHash(),
 		}
 		parentTxn.SiacoinOutputs = append(parentTxn.SiacoinOutputs, refundOutput)
+	} else if !change.IsZero() {
+		parentTxn.MinerFees = append(parentTxn.MinerFees, change)
 	}
 
 	// Sign all of the inputs to the parent trancstion.
 	for _, sci := range parentTxn.SiacoinInputs {
-		_, err := addSignatures(&parentTxn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), tb.wallet.keys[sci.UnlockConditions.UnlockHash()])
+		_, err := addSignatures(&parentTxn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), tb.wallet.keys[sci.UnlockConditions.UnlockHash()], 0)
 		if err != nil {
 			return err
 		}
@@ -214,6 +217,102 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	return nil
 }
 
+// spendableSiacoinOutputs returns the confirmed and unconfirmed siacoin
+// outputs of an account that the wallet can currently spend, sorted from
+// largest to smallest. potentialFund is the value of the spendable outputs
+// plus the value of the outputs that were spent recently by transactions that
+// have not been confirmed yet.
+func (w *Wallet) spendableSiacoinOutputs(account uint64) (spendable sortedOutputs, potentialFund types.Currency) {
+	// Collect a value-sorted set of siacoin outputs.
+	var so sortedOutputs
+	for scoid, sco := range w.siacoinOutputs {
+		if w.accountOf(sco.UnlockHash) != account {
+			continue
+		}
+		so.ids = append(so.ids, scoid)
+		so.outputs = append(so.outputs, sco)
+	}
+	// Add all of the unconfirmed outputs as well.
+	for _, upt := range w.unconfirmedProcessedTransactions {
+		for i, sco := range upt.Transaction.SiacoinOutputs {
+			// Determine if the output belongs to the wallet.
+			_, exists := w.keys[sco.UnlockHash]
+			if !exists || w.accountOf(sco.UnlockHash) != account {
+				continue
+			}
+			so.ids = append(so.ids, upt.Transaction.SiacoinOutputID(uint64(i)))
+			so.outputs = append(so.outputs, sco)
+		}
+	}
+	sort.Sort(sort.Reverse(so))
+
+	for i := range so.ids {
+		scoid := so.ids[i]
+		sco := so.outputs[i]
+		// Check that this output has not recently been spent by the wallet.
+		spendHeight := w.spentOutputs[types.OutputID(scoid)]
+		// Prevent an underflow error.
+		allowedHeight := w.consensusSetHeight - RespendTimeout
+		if w.consensusSetHeight < RespendTimeout {
+			allowedHeight = 0
+		}
+		if spendHeight > allowedHeight {
+			potentialFund = potentialFund.Add(sco.Value)
+			continue
+		}
+		outputUnlockConditions := w.keys[sco.UnlockHash].UnlockConditions
+		if w.consensusSetHeight < outputUnlockConditions.Timelock {
+			continue
+		}
+		spendable.ids = append(spendable.ids, scoid)
+		spendable.outputs = append(spendable.outputs, sco)
+		potentialFund = potentialFund.Add(sco.Value)
+	}
+	return spendable, potentialFund
+}
+
+// selectSiacoinOutputs chooses which outputs to spend in order to fund
+// 'amount', returning the indices of the chosen outputs and their total
+// value. 'values' must be sorted from largest to smallest. A single output
+// that covers the amount without leaving more than dust is preferred, as it
+// does not require a change output. Otherwise, outputs are chosen largest
+// first. If that would leave dust as change, the smallest remaining outputs
+// are added to consolidate the change into a single output of at least
+// dustThreshold. If the balance is insufficient, all outputs are returned.
+func selectSiacoinOutputs(values []types.Currency, amount types.Currency) (selected []int, fund types.Currency) {
+	maxExact := amount.Add(dustThreshold)
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i].Cmp(amount) >= 0 && values[i].Cmp(maxExact) < 0 {
+			return []int{i}, values[i]
+		}
+	}
+
+	for i := range values {
+		if fund.Cmp(amount) >= 0 {
+			break
+		}
+		selected = append(selected, i)
+		fund = fund.Add(values[i])
+	}
+	if fund.Cmp(amount) < 0 || fund.Cmp(maxExact) >= 0 || fund.Cmp(amount) == 0 {
+		return selected, fund
+	}
+
+	// The change is dust. Try to consolidate it with the smallest outputs
+	// that have not been selected. If the change remains dust even after
+	// every output has been added, it is left to the miner fees.
+	consolidated := selected
+	consolidatedFund := fund
+	for i := len(values) - 1; i >= len(selected); i-- {
+		consolidated = append(consolidated, i)
+		consolidatedFund = consolidatedFund.Add(values[i])
+		if consolidatedFund.Cmp(maxExact) >= 0 {
+			return consolidated, consolidatedFund
+		}
+	}
+	return selected, fund
+}
+
 // FundSiafunds will add a siafund input of exaclty 'amount' to the
 // transaction. A parent transaction may be needed to achieve an input with the
 // correct value. The siafund input will not be signed until 'Sign' is called
@@ -229,6 +328,9 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 	parentTxn := types.Transaction{}
 	var spentSfoids []types.SiafundOutputID
 	for sfoid, sfo := range tb.wallet.siafundOutputs {
+		if tb.wallet.accountOf(sfo.UnlockHash) != tb.account {
+			continue
+		}
 		// Check that this output has not recently been spent by the wallet.
 		spendHeight := tb.wallet.spentOutputs[types.OutputID(sfoid)]
 		// Prevent an underflow error.
@@ -246,7 +348,7 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 		}
 
 		// Add a siafund input for this output.
-		parentClaimUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
+		parentClaimUnlockConditions, err := tb.wallet.nextAccountAddress(tb.account)
 		if err != nil {
 			return err
 		}
@@ -274,7 +376,7 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 
 	// Create and add the output that will be used to fund the standard
 	// transaction.
-	parentUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
+	parentUnlockConditions, err := tb.wallet.nextAccountAddress(tb.account)
 	if err != nil {
 		return err
 	}
@@ -286,7 +388,7 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 
 	// Create a refund output if needed.
 	if amount.Cmp(fund) != 0 {
-		refundUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
+		refundUnlockConditions, err := tb.wallet.nextAccountAddress(tb.account)
 		if err != nil {
 			return err
 		}
@@ -299,14 +401,14 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 
 	// Sign all of the inputs to the parent trancstion.
 	for _, sfi := range parentTxn.SiafundInputs {
-		_, err := addSignatures(&parentTxn, types.FullCoveredFields, sfi.UnlockConditions, crypto.Hash(sfi.ParentID), tb.wallet.keys[sfi.UnlockConditions.UnlockHash()])
+		_, err := addSignatures(&parentTxn, types.FullCoveredFields, sfi.UnlockConditions, crypto.Hash(sfi.ParentID), tb.wallet.keys[sfi.UnlockConditions.UnlockHash()], 0)
 		if err != nil {
 			return err
 		}
 	}
 
 	// Add the exact output.
-	claimUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
+	claimUnlockConditions, err := tb.wallet.nextAccountAddress(tb.account)
 	if err != nil {
 		return err
 	}
@@ -497,7 +599,7 @@ func (tb *transactionBuilder) Sign(wholeTransaction bool) ([]types.Transaction,
 	for _, inputIndex := range tb.siacoinInputs {
 		input := tb.transaction.SiacoinInputs[inputIndex]
 		key := tb.wallet.keys[input.UnlockConditions.UnlockHash()]
-		newSigIndices, err := addSignatures(&tb.transaction, coveredFields, input.UnlockConditions, crypto.Hash(input.ParentID), key)
+		newSigIndices, err := addSignatures(&tb.transaction, coveredFields, input.UnlockConditions, crypto.Hash(input.ParentID), key, tb.timelock)
 		if err != nil {
 			return nil, err
 		}
@@ -507,7 +609,7 @@ func (tb *transactionBuilder) Sign(wholeTransaction bool) ([]types.Transaction,
 	for _, inputIndex := range tb.siafundInputs {
 		input := tb.transaction.SiafundInputs[inputIndex]
 		key := tb.wallet.keys[input.UnlockConditions.UnlockHash()]
-		newSigIndices, err := addSignatures(&tb.transaction, coveredFields, input.UnlockConditions, crypto.Hash(input.ParentID), key)
+		newSigIndices, err := addSignatures(&tb.transaction, coveredFields, input.UnlockConditions, crypto.Hash(input.ParentID), key, tb.timelock)
 		if err != nil {
 			return nil, err
 		}
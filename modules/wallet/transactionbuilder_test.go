@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"reflect"
 	"sync"
 	"testing"
 
@@ -449,3 +450,78 @@ func TestParallelBuilders(t *testing.T) {
 		t.Fatal("did not get the expected ending balance", expected, endingSCConfirmed, startingSCConfirmed)
 	}
 }
+
+// TestSelectSiacoinOutputs checks that output selection avoids creating dust
+// change, especially when the outputs are close to the amount being funded.
+func TestSelectSiacoinOutputs(t *testing.T) {
+	t.Parallel()
+
+	d := dustThreshold
+	halfD := dustThreshold.Div64(2)
+	tests := []struct {
+		name     string
+		values   []types.Currency
+		amount   types.Currency
+		selected []int
+		fund     types.Currency
+	}{
+		{
+			name:     "exact output",
+			values:   []types.Currency{d.Mul64(10), d.Mul64(3)},
+			amount:   d.Mul64(3),
+			selected: []int{1},
+			fund:     d.Mul64(3),
+		},
+		{
+			name:     "output within dust of the amount",
+			values:   []types.Currency{d.Mul64(10), d.Mul64(3).Add(halfD)},
+			amount:   d.Mul64(3),
+			selected: []int{1},
+			fund:     d.Mul64(3).Add(halfD),
+		},
+		{
+			name:     "largest output leaves change",
+			values:   []types.Currency{d.Mul64(10), d.Mul64(2)},
+			amount:   d.Mul64(5),
+			selected: []int{0},
+			fund:     d.Mul64(10),
+		},
+		{
+			name:     "change exactly at the dust threshold",
+			values:   []types.Currency{d.Mul64(5), d.Mul64(4)},
+			amount:   d.Mul64(8),
+			selected: []int{0, 1},
+			fund:     d.Mul64(9),
+		},
+		{
+			name:     "dust change is consolidated",
+			values:   []types.Currency{d.Mul64(5), d.Mul64(4), d.Mul64(2)},
+			amount:   d.Mul64(8).Add(halfD),
+			selected: []int{0, 1, 2},
+			fund:     d.Mul64(11),
+		},
+		{
+			name:     "dust change cannot be consolidated",
+			values:   []types.Currency{d.Mul64(5), d.Mul64(4)},
+			amount:   d.Mul64(8).Add(halfD),
+			selected: []int{0, 1},
+			fund:     d.Mul64(9),
+		},
+		{
+			name:     "insufficient balance",
+			values:   []types.Currency{d},
+			amount:   d.Mul64(2),
+			selected: []int{0},
+			fund:     d,
+		},
+	}
+	for _, test := range tests {
+		selected, fund := selectSiacoinOutputs(test.values, test.amount)
+		if !reflect.DeepEqual(selected, test.selected) {
+			t.Errorf("%v: expected outputs %v to be selected, got %v", test.name, test.selected, selected)
+		}
+		if fund.Cmp(test.fund) != 0 {
+			t.Errorf("%v: expected fund of %v, got %v", test.name, test.fund, fund)
+		}
+	}
+}
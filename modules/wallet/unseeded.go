@@ -190,6 +190,35 @@ func (w *Wallet) LoadSiagKeys(masterKey crypto.TwofishKey, keyfiles []string) er
 	return w.loadSiagKeys(masterKey, keyfiles)
 }
 
+// LoadLooseKey loads the secret key of a standalone key pair into the wallet
+// as an unseeded key, such that the funds sent to its address become
+// spendable to the current wallet.
+func (w *Wallet) LoadLooseKey(masterKey crypto.TwofishKey, sk crypto.SecretKey) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		return err
+	}
+
+	err = w.loadSpendableKey(masterKey, spendableKey{
+		UnlockConditions: generateUnlockConditions(sk.PublicKey()),
+		SecretKeys:       []crypto.SecretKey{sk},
+	})
+	if err != nil {
+		return err
+	}
+	err = w.saveSettingsSync()
+	if err != nil {
+		return err
+	}
+	return w.createBackup(filepath.Join(w.persistDir, "Sia Wallet Encrypted Backup - "+persist.RandomSuffix()+settingsFileSuffix))
+}
+
 // Load033xWallet loads a v0.3.3.x wallet as an unseeded key, such that the
 // funds become spendable to the current wallet.
 func (w *Wallet) Load033xWallet(masterKey crypto.TwofishKey, filepath033x string) error {
@@ -3,6 +3,8 @@ package wallet
 import (
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -105,3 +107,72 @@ func TestIntegrationLoad2of3Siag(t *testing.T) {
 		t.Error("expecting balance of 6988 after sending siafunds to the void")
 	}
 }
+
+// TestIntegrationLoadLooseKey loads the secret key of a standalone key pair
+// into the wallet and then spends the coins sent to its address.
+func TestIntegrationLoadLooseKey(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationLoadLooseKey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Send coins to the address of a new key pair.
+	kp, err := modules.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := modules.ParseSecretKey(kp.SecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := types.SiacoinPrecision.Mul64(100)
+	_, err = wt.wallet.SendSiacoins(amount, kp.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Load the key into the wallet. The wrong master key is rejected.
+	err = wt.wallet.LoadLooseKey(crypto.TwofishKey{}, sk)
+	if err != modules.ErrBadEncryptionKey {
+		t.Fatal("expected ErrBadEncryptionKey, got", err)
+	}
+	err = wt.wallet.LoadLooseKey(wt.walletMasterKey, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a second wallet that loads the persist structures of the existing
+	// wallet. Its addresses should include the address of the key pair.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, addr := range w.AllAddresses() {
+		if addr == kp.Address {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("address of the loose key is not in the wallet")
+	}
+
+	// Loading the same key again is an error.
+	err = w.LoadLooseKey(wt.walletMasterKey, sk)
+	if err != errDuplicateSpendableKey {
+		t.Fatal("expected errDuplicateSpendableKey, got", err)
+	}
+}
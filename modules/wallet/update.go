@@ -121,6 +121,7 @@ func (w *Wallet) applyHistory(cc modules.ConsensusChange) {
 		if relevant {
 			w.processedTransactions = append(w.processedTransactions, minerPT)
 			w.processedTransactionMap[minerPT.TransactionID] = &w.processedTransactions[len(w.processedTransactions)-1]
+			w.publishTransactionReceived(minerPT)
 		}
 		for _, txn := range block.Transactions {
 			relevant := false
@@ -201,11 +202,28 @@ func (w *Wallet) applyHistory(cc modules.ConsensusChange) {
 			if relevant {
 				w.processedTransactions = append(w.processedTransactions, pt)
 				w.processedTransactionMap[pt.TransactionID] = &w.processedTransactions[len(w.processedTransactions)-1]
+				w.publishTransactionReceived(pt)
 			}
 		}
 	}
 }
 
+// publishTransactionReceived announces that a transaction relevant to the
+// wallet has been confirmed.
+func (w *Wallet) publishTransactionReceived(pt modules.ProcessedTransaction) {
+	w.events.Publish(modules.EventTransactionReceived, modules.TransactionReceivedEvent{
+		TransactionID:      pt.TransactionID,
+		ConfirmationHeight: pt.ConfirmationHeight,
+	})
+}
+
+// SubscribeEvents returns a channel that receives an event for each confirmed
+// transaction that is relevant to the wallet, and a function that ends the
+// subscription.
+func (w *Wallet) SubscribeEvents() (<-chan modules.Event, func()) {
+	return w.events.Subscribe()
+}
+
 // ProcessConsensusChange parses a consensus change to update the set of
 // confirmed outputs known to the wallet.
 func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
@@ -221,10 +239,37 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 	w.updateConfirmedSet(cc)
 	w.revertHistory(cc)
 	w.applyHistory(cc)
+
+	// Keep the outputs of the scheduled payments from being spent, and
+	// broadcast the payments that have reached their height. The transaction
+	// pool calls back into the wallet, so the payments are broadcast in a
+	// separate goroutine.
+	w.reserveScheduledOutputs()
+	if len(w.duePayments()) > 0 {
+		go w.threadedBroadcastScheduled()
+	}
+}
+
+// TransactionPoolFilter returns a filter matching the unconfirmed transactions
+// that spend from or send to one of the wallet's addresses, allowing the
+// wallet to subscribe to the transaction pool without scanning every
+// unconfirmed transaction.
+func (w *Wallet) TransactionPoolFilter() modules.TransactionPoolFilter {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	addrs := make(map[types.UnlockHash]struct{}, len(w.keys))
+	for addr := range w.keys {
+		addrs[addr] = struct{}{}
+	}
+	return modules.TransactionPoolFilter{
+		UnlockHashes: addrs,
+	}
 }
 
 // ReceiveUpdatedUnconfirmedTransactions updates the wallet's unconfirmed
-// transaction set.
+// transaction set. Only transactions matching the wallet's
+// TransactionPoolFilter are delivered by the transaction pool.
 func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction, _ modules.ConsensusChange) {
 	if err := w.tg.Add(); err != nil {
 		// Gracefully reject transactions if the wallet's Close method has
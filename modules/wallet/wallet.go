@@ -26,8 +26,18 @@ const (
 )
 
 var (
+	// dustThreshold is the smallest change output that the wallet will
+	// create. Spending an input costs roughly 250 bytes of transaction space,
+	// which at the minimum recommended fee of 1 SC / KB means that an output
+	// worth less than 1/4 SC is worth less than the fees required to spend
+	// it. Change below the threshold is consolidated with other outputs or
+	// added to the miner fees instead.
+	dustThreshold = types.SiacoinPrecision.Div64(4)
+
 	errNilConsensusSet = errors.New("wallet cannot initialize with a nil consensus set")
 	errNilTpool        = errors.New("wallet cannot initialize with a nil transaction pool")
+	errReservedFunds   = errors.New("sending would spend siacoins that are reserved by other modules")
+	errWalletClosed    = errors.New("wallet has been closed")
 )
 
 // spendableKey is a set of secret keys plus the corresponding unlock
@@ -56,12 +66,20 @@ type Wallet struct {
 	// The wallet's dependencies. The items 'consensusSetHeight' and
 	// 'siafundPool' are tracked separately from the consensus set to minimize
 	// the number of queries that the wallet needs to make to the consensus
-	// set; queries to the consensus set are very slow.
+	// set; queries to the consensus set are very slow. A light wallet has a
+	// light consensus set instead of a consensus set.
 	cs                 modules.ConsensusSet
-	tpool              modules.TransactionPool
+	lcs                modules.LightConsensusSet
+	tpool              transactionPool
 	consensusSetHeight types.BlockHeight
 	siafundPool        types.Currency
 
+	// lightScanned is the height of the first block that a light wallet has
+	// not scanned, and lightTip is the id of the last block that it has
+	// scanned.
+	lightScanned types.BlockHeight
+	lightTip     types.BlockID
+
 	// The following set of fields are responsible for tracking the confirmed
 	// outputs, and for being able to spend them. The seeds are used to derive
 	// the keys that are tracked on the blockchain. All keys are pregenerated
@@ -77,6 +95,11 @@ type Wallet struct {
 	siafundOutputs map[types.SiafundOutputID]types.SiafundOutput
 	spentOutputs   map[types.OutputID]types.BlockHeight
 
+	// accountKeys maps the addresses of the wallet's accounts to the number
+	// of their account. Addresses that are not in the map belong to the
+	// wallet itself, which is account 0.
+	accountKeys map[types.UnlockHash]uint64
+
 	// The following fields are kept to track transaction history.
 	// processedTransactions are stored in chronological order, and have a map for
 	// constant time random access. The set of full transactions is kept as
@@ -98,6 +121,15 @@ type Wallet struct {
 	historicOutputs     map[types.OutputID]types.Currency
 	historicClaimStarts map[types.SiafundOutputID]types.Currency
 
+	// reservations maps the name of a reservation to the number of siacoins
+	// that it reserves. Reservations are not persisted; the modules that make
+	// them are expected to renew them on startup.
+	reservations map[string]types.Currency
+
+	// events announces the confirmed transactions that are relevant to the
+	// wallet.
+	events modules.EventFeed
+
 	persistDir string
 	log        *persist.Logger
 	mu         sync.RWMutex
@@ -119,7 +151,16 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir stri
 		return nil, errNilTpool
 	}
 
-	// Initialize the data structure.
+	w := newWallet(cs, tpool, persistDir)
+	err := w.initPersist()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// newWallet initializes the data structure of a wallet.
+func newWallet(cs modules.ConsensusSet, tpool transactionPool, persistDir string) *Wallet {
 	w := &Wallet{
 		cs:    cs,
 		tpool: tpool,
@@ -128,19 +169,19 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir stri
 		siacoinOutputs: make(map[types.SiacoinOutputID]types.SiacoinOutput),
 		siafundOutputs: make(map[types.SiafundOutputID]types.SiafundOutput),
 		spentOutputs:   make(map[types.OutputID]types.BlockHeight),
+		accountKeys:    make(map[types.UnlockHash]uint64),
 
 		processedTransactionMap: make(map[types.TransactionID]*modules.ProcessedTransaction),
 
 		historicOutputs:     make(map[types.OutputID]types.Currency),
 		historicClaimStarts: make(map[types.SiafundOutputID]types.Currency),
 
+		reservations: make(map[string]types.Currency),
+
 		persistDir: persistDir,
 	}
-	err := w.initPersist()
-	if err != nil {
-		return nil, err
-	}
-	return w, nil
+	w.tg.SetName("wallet")
+	return w
 }
 
 // Close terminates all ongoing processes involving the wallet, enabling
@@ -163,7 +204,9 @@ func (w *Wallet) Close() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.cs.Unsubscribe(w)
+	if w.cs != nil {
+		w.cs.Unsubscribe(w)
+	}
 	w.tpool.Unsubscribe(w)
 
 	if err := w.log.Close(); err != nil {
@@ -1,12 +1,24 @@
 package persist
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 )
 
-// Load loads json data from a reader.
+// checksum returns the checksum of the encoded data of a file, which is
+// written after the data by Save.
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Load loads json data from a reader. If the data is followed by a checksum,
+// the checksum is verified before the data is decoded. Data without a
+// checksum, written before checksums were added, is loaded as-is.
 func Load(meta Metadata, data interface{}, r io.Reader) error {
 	var header, version string
 	dec := json.NewDecoder(r)
@@ -22,11 +34,17 @@ func Load(meta Metadata, data interface{}, r io.Reader) error {
 	if version != meta.Version {
 		return ErrBadVersion
 	}
-	if err := dec.Decode(data); err != nil {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
 		return err
 	}
-
-	return nil
+	var sum string
+	if err := dec.Decode(&sum); err != nil && err != io.EOF {
+		return err
+	} else if err == nil && sum != checksum(raw) {
+		return ErrBadChecksum
+	}
+	return json.Unmarshal(raw, data)
 }
 
 // LoadFile loads json data from a file.
@@ -60,8 +78,10 @@ func Save(meta Metadata, data interface{}, w io.Writer) error {
 	if _, err = w.Write(b); err != nil {
 		return err
 	}
-
-	return nil
+	if _, err = w.Write([]byte("\n")); err != nil {
+		return err
+	}
+	return enc.Encode(checksum(b))
 }
 
 // SaveFile atomically saves json data to a file.
@@ -91,3 +111,98 @@ func SaveFileSync(meta Metadata, data interface{}, filename string) error {
 	}
 	return file.CommitSync()
 }
+
+// backupFilename returns the name of the nth most recent backup of a file
+// saved by SaveFileBackups.
+func backupFilename(filename string, n int) string {
+	return fmt.Sprintf("%v_backup%d", filename, n)
+}
+
+// saveFileBackups atomically saves json data to a file, after moving the
+// current file to the first backup, the first backup to the second, and so on.
+// The oldest backup beyond the number of backups to keep is removed.
+func saveFileBackups(meta Metadata, data interface{}, filename string, backups int, sync bool) error {
+	file, err := NewSafeFile(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	err = Save(meta, data, file)
+	if err != nil {
+		return err
+	}
+	if sync {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	// Rotate the backups. If the process is interrupted while the file is
+	// missing, LoadFileBackups will load the first backup instead.
+	if backups > 0 {
+		err = os.Remove(backupFilename(filename, backups))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for i := backups - 1; i > 0; i-- {
+			err = os.Rename(backupFilename(filename, i), backupFilename(filename, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		err = os.Rename(filename, backupFilename(filename, 1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return file.Commit()
+}
+
+// SaveFileBackups atomically saves json data to a file, keeping the given
+// number of previous versions of the file as backups. The most recent backup
+// is filename_backup1, the next most recent filename_backup2, and so on.
+func SaveFileBackups(meta Metadata, data interface{}, filename string, backups int) error {
+	return saveFileBackups(meta, data, filename, backups, false)
+}
+
+// SaveFileBackupsSync atomically saves json data to a file like
+// SaveFileBackups, and syncs the file to disk before replacing the current
+// version.
+func SaveFileBackupsSync(meta Metadata, data interface{}, filename string, backups int) error {
+	return saveFileBackups(meta, data, filename, backups, true)
+}
+
+// LoadFileBackups loads json data from a file saved by SaveFileBackups. If the
+// file is missing, corrupt, or fails its checksum, the most recent backup that
+// can be loaded is used instead, and the returned error is nil. A file with the
+// wrong header or version is not considered corrupt, and its error is
+// returned. If neither the file nor any backup could be loaded, the error of
+// the file is returned.
+func LoadFileBackups(meta Metadata, data interface{}, filename string) error {
+	err := LoadFile(meta, data, filename)
+	if err == nil || err == ErrBadHeader || err == ErrBadVersion {
+		return err
+	}
+	for i := 1; ; i++ {
+		backupErr := LoadFile(meta, data, backupFilename(filename, i))
+		if backupErr == nil {
+			return nil
+		} else if os.IsNotExist(backupErr) {
+			return err
+		}
+	}
+}
+
+// FileBackupsExist returns true if a file saved by SaveFileBackups, or its
+// most recent backup, exists.
+func FileBackupsExist(filename string) (bool, error) {
+	for _, name := range []string{filename, backupFilename(filename, 1)} {
+		_, err := os.Stat(name)
+		if err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -2,7 +2,9 @@ package persist
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -104,3 +106,113 @@ func TestSaveLoadFileSync(t *testing.T) {
 		t.Fatalf("loaded data (%v) does not match saved data (%v)", loadData, saveData)
 	}
 }
+
+// TestLoadChecksum checks that data that does not match its checksum is
+// rejected, and that data saved without a checksum can still be loaded.
+func TestLoadChecksum(t *testing.T) {
+	var meta = Metadata{"TestLoadChecksum", "0.1"}
+	buf := new(bytes.Buffer)
+	err := Save(meta, []int{1, 2, 3}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// change the data without breaking its encoding
+	corrupt := bytes.Replace(data, []byte("2"), []byte("7"), 1)
+	var loadData []int
+	err = Load(meta, &loadData, bytes.NewReader(corrupt))
+	if err != ErrBadChecksum {
+		t.Fatal("expected ErrBadChecksum, got", err)
+	}
+
+	// remove the checksum
+	legacy := data[:bytes.LastIndex(bytes.TrimSpace(data), []byte("\n"))]
+	err = Load(meta, &loadData, bytes.NewReader(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loadData) != 3 || loadData[1] != 2 {
+		t.Fatal("legacy data was not loaded:", loadData)
+	}
+}
+
+// TestSaveLoadFileBackups checks that SaveFileBackups keeps the requested
+// number of previous versions, and that LoadFileBackups falls back to the most
+// recent backup that is intact.
+func TestSaveLoadFileBackups(t *testing.T) {
+	var meta = Metadata{"TestSaveLoadFileBackups", "0.1"}
+	dir := build.TempDir("persist", "TestSaveLoadFileBackups")
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "data.json")
+
+	exists, err := FileBackupsExist(filename)
+	if err != nil || exists {
+		t.Fatal("file exists before it was saved:", exists, err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := SaveFileBackupsSync(meta, i, filename, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for n, expected := range map[int]int{0: 5, 1: 4, 2: 3} {
+		name := filename
+		if n > 0 {
+			name = backupFilename(filename, n)
+		}
+		var loadData int
+		if err := LoadFile(meta, &loadData, name); err != nil {
+			t.Fatal(err)
+		} else if loadData != expected {
+			t.Errorf("%v holds %v, expected %v", name, loadData, expected)
+		}
+	}
+	if _, err := os.Stat(backupFilename(filename, 3)); !os.IsNotExist(err) {
+		t.Error("more backups were kept than requested")
+	}
+
+	// corrupt the file and the first backup
+	if err := ioutil.WriteFile(filename, []byte(`"TestSaveLoadFileBackups"`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(backupFilename(filename, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(backupFilename(filename, 1), bytes.Replace(b, []byte("4"), []byte("6"), 1), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var loadData int
+	if err := LoadFileBackups(meta, &loadData, filename); err != nil {
+		t.Fatal(err)
+	} else if loadData != 3 {
+		t.Fatal("expected the second backup to be loaded, got", loadData)
+	}
+
+	// a missing file falls back to the backups
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	exists, err = FileBackupsExist(filename)
+	if err != nil || !exists {
+		t.Fatal("backups were not found:", exists, err)
+	}
+	if err := LoadFileBackups(meta, &loadData, filename); err != nil || loadData != 3 {
+		t.Fatal("expected the second backup to be loaded, got", loadData, err)
+	}
+
+	// the wrong version is not treated as corruption
+	if err := LoadFileBackups(Metadata{"TestSaveLoadFileBackups", "0.2"}, &loadData, backupFilename(filename, 2)); err != ErrBadVersion {
+		t.Fatal("expected ErrBadVersion, got", err)
+	}
+
+	// nothing to load
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0777)
+	if err := LoadFileBackups(meta, &loadData, filename); !os.IsNotExist(err) {
+		t.Fatal("expected a not exist error, got", err)
+	}
+}
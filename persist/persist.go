@@ -12,6 +12,10 @@ const (
 	// persistDir defines the folder that is used for testing the persist
 	// package.
 	persistDir = "persist"
+
+	// DefaultBackups is the number of previous versions that modules keep
+	// of the files that they save with SaveFileBackups.
+	DefaultBackups = 3
 )
 
 var (
@@ -22,6 +26,10 @@ var (
 	// ErrBadHeader indicates that the file opened is not the file that was
 	// expected.
 	ErrBadHeader = errors.New("wrong header")
+
+	// ErrBadChecksum indicates that the data of the file does not match the
+	// checksum that was saved with it.
+	ErrBadChecksum = errors.New("checksum does not match the data")
 )
 
 // Metadata contains the header and version of the data being stored.
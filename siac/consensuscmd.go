@@ -1,12 +1,17 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/NebulousLabs/Sia/api"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -17,8 +22,100 @@ var (
 		Long:  "Print the current state of consensus such as current block, block height, and target.",
 		Run:   wrap(consensuscmd),
 	}
+
+	consensusCheckCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check the consensus database for inconsistencies",
+		Long: `Check the consensus database for inconsistencies, such as a broken block path,
+missing delayed outputs, or incorrect siacoin and siafund totals. With --repair,
+any inconsistencies are repaired by re-deriving the consensus state from the
+blocks in the current path.`,
+		Run: wrap(consensuscheckcmd),
+	}
+
+	consensusCheckpointCmd = &cobra.Command{
+		Use:   "checkpoint [keyfile]",
+		Short: "Sign a checkpoint at the current block",
+		Long: `Sign a checkpoint committing to the current block and the current state of the
+consensus set, using the hex-encoded checkpoint secret key stored in keyfile.
+The signed checkpoint is printed in a form that can be added to the embedded
+checkpoints of the consensus package. Only sign checkpoints from a fully synced
+node whose consensus database passes 'siac consensus check'.`,
+		Run: wrap(consensuscheckpointcmd),
+	}
 )
 
+// consensuscheckcmd is the handler for the command `siac consensus check`.
+// Checks the consensus database for inconsistencies, optionally repairing
+// them.
+func consensuscheckcmd() {
+	var report api.ConsensusConsistencyGET
+	var err error
+	if consensusRepair {
+		err = postResp("/consensus/consistency", "", &report)
+	} else {
+		err = getAPI("/consensus/consistency", &report)
+	}
+	if err != nil {
+		die("Could not check consensus database:", err)
+	}
+	if len(report.Errors) == 0 {
+		fmt.Println("No inconsistencies found.")
+		return
+	}
+	fmt.Println("Inconsistencies found:")
+	for _, e := range report.Errors {
+		fmt.Println("  " + e)
+	}
+	if report.Repaired {
+		fmt.Println("The consensus database has been repaired.")
+	} else {
+		fmt.Println("Run 'siac consensus check --repair' to repair the consensus database.")
+	}
+}
+
+// consensuscheckpointcmd is the handler for the command `siac consensus
+// checkpoint [keyfile]`. Signs a checkpoint at the current block.
+func consensuscheckpointcmd(keyfile string) {
+	b, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		die("Could not read key file:", err)
+	}
+	var sk crypto.SecretKey
+	if n, err := hex.Decode(sk[:], []byte(strings.TrimSpace(string(b)))); err != nil || n != len(sk) {
+		die("Key file does not contain a hex-encoded secret key")
+	}
+	var cg api.ConsensusCheckpointGET
+	if err := getAPI("/consensus/checkpoint", &cg); err != nil {
+		die("Could not get the current checkpoint:", err)
+	}
+	cp := modules.ConsensusCheckpoint{
+		Height:        cg.Height,
+		BlockID:       cg.BlockID,
+		ConsensusHash: cg.ConsensusHash,
+	}
+	cp.Signature, err = crypto.SignHash(cp.SigHash(), sk)
+	if err != nil {
+		die("Could not sign checkpoint:", err)
+	}
+	fmt.Printf(`{
+	Height:        %v,
+	BlockID:       types.BlockID{%v},
+	ConsensusHash: crypto.Hash{%v},
+	Signature:     crypto.Signature{%v},
+},
+`, cp.Height, byteLiteral(cp.BlockID[:]), byteLiteral(cp.ConsensusHash[:]), byteLiteral(cp.Signature[:]))
+}
+
+// byteLiteral formats b as the elements of a Go byte array literal.
+func byteLiteral(b []byte) string {
+	elems := make([]string, len(b))
+	for i := range b {
+		elems[i] = fmt.Sprintf("0x%02x", b[i])
+	}
+	return strings.Join(elems, ", ")
+}
+
 // consensuscmd is the handler for the command `siac consensus`.
 // Prints the current state of consensus.
 func consensuscmd() {
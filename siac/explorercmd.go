@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/NebulousLabs/Sia/api"
+)
+
+var (
+	explorerCmd = &cobra.Command{
+		Use:   "explorer",
+		Short: "Print statistics about the network",
+		Long:  "Print statistics about the network as seen by the explorer, such as the estimated hashrate and the active file contracts.",
+		Run:   wrap(explorercmd),
+	}
+
+	explorerCheckCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check the explorer database against consensus",
+		Long: `Check the explorer database against the consensus set, comparing the indexed
+blocks, the active file contracts, and the siafund holdings. An inconsistent
+explorer database can be rebuilt by stopping siad and deleting it.`,
+		Run: wrap(explorercheckcmd),
+	}
+)
+
+// explorercmd is the handler for the command `siac explorer`.
+// Prints statistics about the network.
+func explorercmd() {
+	var eg api.ExplorerGET
+	err := getAPI("/explorer", &eg)
+	if err != nil {
+		die("Could not get network statistics:", err)
+	}
+	var sg api.ExplorerStorageGET
+	err = getAPI("/explorer/storage", &sg)
+	if err != nil {
+		die("Could not get storage statistics:", err)
+	}
+	size, _ := sg.ActiveContractSize.Uint64()
+	fmt.Printf(`Height:             %v
+Difficulty:         %v
+Estimated Hashrate: %v H/s
+Active Contracts:   %v
+Storage:            %v
+Storage Price:      %v / byte / block
+`, eg.Height, eg.Difficulty, eg.EstimatedHashrate, sg.ActiveContractCount,
+		filesizeUnits(int64(size)), currencyUnits(sg.AverageStoragePrice))
+}
+
+// explorercheckcmd is the handler for the command `siac explorer check`.
+// Checks the explorer database against consensus.
+func explorercheckcmd() {
+	var report api.ExplorerConsistencyGET
+	err := getAPI("/explorer/consistency", &report)
+	if err != nil {
+		die("Could not check explorer database:", err)
+	}
+	if len(report.Errors) == 0 {
+		fmt.Println("No inconsistencies found.")
+		return
+	}
+	fmt.Println("Inconsistencies found:")
+	for _, e := range report.Errors {
+		fmt.Println("  " + e)
+	}
+	fmt.Println("Stop siad and delete the explorer database to rebuild it.")
+}
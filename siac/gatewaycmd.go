@@ -18,6 +18,22 @@ var (
 		Run:   wrap(gatewaycmd),
 	}
 
+	gatewayBandwidthCmd = &cobra.Command{
+		Use:   "bandwidth",
+		Short: "View the bandwidth used by each RPC",
+		Long:  "View the amount of data sent and received for each RPC since siad was started, along with the limit of each RPC.",
+		Run:   wrap(gatewaybandwidthcmd),
+	}
+
+	gatewayBandwidthLimitCmd = &cobra.Command{
+		Use:   "limit [rpc] [limit]",
+		Short: "Limit the bandwidth of an RPC",
+		Long: `Limit the number of bytes per second that may be transferred for an RPC,
+summed over all peers. The limit may include a unit, for example "100KB". A
+limit of 0 removes the limit.`,
+		Run: wrap(gatewaybandwidthlimitcmd),
+	}
+
 	gatewayConnectCmd = &cobra.Command{
 		Use:   "connect [address]",
 		Short: "Connect to a peer",
@@ -32,6 +48,15 @@ var (
 		Run:   wrap(gatewaydisconnectcmd),
 	}
 
+	gatewayUnpinCmd = &cobra.Command{
+		Use:   "unpin [address]",
+		Short: "Clear the key pinned for a node",
+		Long: `Clear the public key pinned for the node that the address led to. The next
+key presented by the node is pinned instead. Use this when a node has changed
+its key pair, or when the address now belongs to a different node.`,
+		Run: wrap(gatewayunpincmd),
+	}
+
 	gatewayAddressCmd = &cobra.Command{
 		Use:   "address",
 		Short: "Print the gateway address",
@@ -47,6 +72,40 @@ var (
 	}
 )
 
+// gatewaybandwidthcmd is the handler for the command `siac gateway
+// bandwidth`. Prints the data transferred for each RPC.
+func gatewaybandwidthcmd() {
+	var bg api.GatewayBandwidthGET
+	err := getAPI("/gateway/bandwidth", &bg)
+	if err != nil {
+		die("Could not get bandwidth:", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RPC\tSent\tReceived\tLimit")
+	for _, rb := range bg.RPCs {
+		limit := "none"
+		if rb.Limit != 0 {
+			limit = filesizeUnits(int64(rb.Limit)) + "/s"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", rb.RPC, filesizeUnits(int64(rb.Sent)), filesizeUnits(int64(rb.Received)), limit)
+	}
+	w.Flush()
+}
+
+// gatewaybandwidthlimitcmd is the handler for the command `siac gateway
+// bandwidth limit [rpc] [limit]`. Sets the bandwidth limit of an RPC.
+func gatewaybandwidthlimitcmd(rpc, limit string) {
+	limit, err := parseFilesize(limit)
+	if err != nil {
+		die("Could not parse limit:", err)
+	}
+	err = post("/gateway/bandwidth", "rpc="+rpc+"&limit="+limit)
+	if err != nil {
+		die("Could not set limit:", err)
+	}
+	fmt.Println("Bandwidth limit of", rpc, "updated.")
+}
+
 // gatewayconnectcmd is the handler for the command `siac gateway add [address]`.
 // Adds a new peer to the peer list.
 func gatewayconnectcmd(addr string) {
@@ -67,6 +126,16 @@ func gatewaydisconnectcmd(addr string) {
 	fmt.Println("Removed", addr, "from peer list.")
 }
 
+// gatewayunpincmd is the handler for the command `siac gateway unpin
+// [address]`. Clears the key pinned for a node.
+func gatewayunpincmd(addr string) {
+	err := post("/gateway/unpin/"+addr, "")
+	if err != nil {
+		die("Could not unpin node:", err)
+	}
+	fmt.Println("Unpinned the key of", addr+".")
+}
+
 // gatewayaddresscmd is the handler for the command `siac gateway address`.
 // Prints the gateway's network address.
 func gatewayaddresscmd() {
@@ -88,6 +157,15 @@ func gatewaycmd() {
 	}
 	fmt.Println("Address:", info.NetAddress)
 	fmt.Println("Active peers:", len(info.Peers))
+	switch {
+	case info.NAT.LastChecked.IsZero():
+		fmt.Println("Reachable: not checked yet")
+	case info.NAT.Reachable:
+		fmt.Println("Reachable: yes")
+	default:
+		fmt.Println("Reachable: no (forward the gateway's port on your router so that other nodes can connect)")
+	}
+	fmt.Printf("Peer limits: %v total, %v inbound, %v per subnet\n", info.MaxPeers, info.MaxInboundPeers, info.MaxPeersPerSubnet)
 }
 
 // gatewaylistcmd is the handler for the command `siac gateway list`.
@@ -104,9 +182,9 @@ func gatewaylistcmd() {
 	}
 	fmt.Println(len(info.Peers), "active peers:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Version\tOutbound\tAddress")
+	fmt.Fprintln(w, "Version\tOutbound\tScore\tAddress")
 	for _, peer := range info.Peers {
-		fmt.Fprintf(w, "%v\t%v\t%v\n", peer.Version, yesNo(!peer.Inbound), peer.NetAddress)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", peer.Version, yesNo(!peer.Inbound), peer.Score, peer.NetAddress)
 	}
 	w.Flush()
 }
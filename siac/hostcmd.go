@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"math/big"
+	"net/url"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/NebulousLabs/Sia/api"
@@ -55,6 +57,24 @@ To configure the host to accept new contracts, set acceptingcontracts to true:
 		Run: wrap(hostconfigcmd),
 	}
 
+	hostAlertsCmd = &cobra.Command{
+		Use:   "alerts",
+		Short: "List the problems of the host",
+		Long: `List the operational problems of the host that may cost it money, such as
+a used up collateral budget, a locked wallet, a failing storage folder, or a
+missed storage proof. Critical alerts are listed first.`,
+		Run: wrap(hostalertscmd),
+	}
+
+	hostAlertsDismissCmd = &cobra.Command{
+		Use:   "dismiss [id]",
+		Short: "Dismiss an alert about a past problem",
+		Long: `Dismiss an alert about a past problem, such as a missed storage proof.
+Alerts about problems that are still present disappear once the problem is
+fixed, and cannot be dismissed.`,
+		Run: wrap(hostalertsdismisscmd),
+	}
+
 	hostAnnounceCmd = &cobra.Command{
 		Use:   "announce",
 		Short: "Announce yourself as a host",
@@ -68,17 +88,48 @@ Doing so will override the standard connectivity checks.`,
 		Run: hostannouncecmd,
 	}
 
+	hostAuditCmd = &cobra.Command{
+		Use:   "audit [sectors]",
+		Short: "Check that stored sectors would pass a storage proof",
+		Long: `Check that the host could build valid storage proofs for a random
+selection of its sectors, reporting any sector that would fail an on-chain
+storage proof. 10 sectors are checked by default; each check reads a sector
+from disk.`,
+		Run: hostauditcmd,
+	}
+
+	hostBackupCmd = &cobra.Command{
+		Use:   "backup [dir]",
+		Short: "Back up the host's metadata",
+		Long: `Write a snapshot of the host's settings, storage obligations, and
+storage manager metadata to dir, without stopping the host. dir must be empty
+or not exist. The sectors in the storage folders are not backed up.`,
+		Run: wrap(hostbackupcmd),
+	}
+
 	hostFolderCmd = &cobra.Command{
 		Use:   "folder",
-		Short: "Add, remove, or resize a storage folder",
-		Long:  "Add, remove, or resize a storage folder.",
+		Short: "Add, remove, resize, or relink a storage folder",
+		Long:  "Add, remove, resize, or relink a storage folder.",
 	}
 
 	hostFolderAddCmd = &cobra.Command{
 		Use:   "add [path] [size]",
 		Short: "Add a storage folder to the host",
-		Long:  "Add a storage folder to the host, specifying how much data it should store",
-		Run:   wrap(hostfolderaddcmd),
+		Long: `Add a storage folder to the host, specifying how much data it should store.
+The path may also refer to a bucket in an S3-compatible object store, in the
+form s3://endpoint/bucket[/prefix][?region=region]. The store's credentials
+are read by siad from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.`,
+		Run: wrap(hostfolderaddcmd),
+	}
+
+	hostFolderRelinkCmd = &cobra.Command{
+		Use:   "relink [path] [newpath]",
+		Short: "Point a moved storage folder at its new path",
+		Long: `Point a storage folder that has been moved, for example because its drive is
+now mounted somewhere else, at its new path. The host checks that the new path
+holds the same storage folder, so that none of its sectors are lost.`,
+		Run: wrap(hostfolderrelinkcmd),
 	}
 
 	hostFolderRemoveCmd = &cobra.Command{
@@ -297,7 +348,11 @@ RPC Stats:
 	for _, folder := range sg.Folders {
 		curSize := int64(folder.Capacity - folder.CapacityRemaining)
 		pctUsed := 100 * (float64(curSize) / float64(folder.Capacity))
-		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", filesizeUnits(curSize), filesizeUnits(int64(folder.Capacity)), pctUsed, folder.Path)
+		path := folder.Path
+		if folder.Missing {
+			path += " (missing, needs relink)"
+		}
+		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", filesizeUnits(curSize), filesizeUnits(int64(folder.Capacity)), pctUsed, path)
 	}
 	w.Flush()
 }
@@ -380,22 +435,109 @@ To revert this, run:
 `)
 }
 
+// hostauditcmd is the handler for the command `siac host audit`. Checks that
+// a random selection of the host's sectors would pass a storage proof.
+func hostauditcmd(cmd *cobra.Command, args []string) {
+	vals := ""
+	switch len(args) {
+	case 0:
+	case 1:
+		vals = "sectors=" + args[0]
+	default:
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	var audit modules.HostAudit
+	err := postResp("/host/audit", vals, &audit)
+	if err != nil {
+		die("Could not audit host:", err)
+	}
+	fmt.Printf("Checked %v of %v sectors in %v storage obligations.\n", audit.SectorsChecked, audit.Sectors, audit.Obligations)
+	if len(audit.Failures) == 0 {
+		fmt.Println("Every checked sector would pass a storage proof.")
+		return
+	}
+	fmt.Printf("%v sectors would fail a storage proof:\n", len(audit.Failures))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Obligation\tSector\tProof Deadline\tError")
+	for _, f := range audit.Failures {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", f.ObligationID, f.SectorIndex, f.ProofDeadline, f.Error)
+	}
+	w.Flush()
+	os.Exit(exitCodeGeneral)
+}
+
+// hostalertscmd lists the alerts of the host.
+func hostalertscmd() {
+	var hag api.HostAlertsGET
+	err := getAPI("/host/alerts", &hag)
+	if err != nil {
+		die("Could not fetch host alerts:", err)
+	}
+	if len(hag.Alerts) == 0 {
+		fmt.Println("The host has no alerts.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Severity\tSince\tID\tMessage")
+	for _, a := range hag.Alerts {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", a.Severity, a.Since.Format("2006-01-02 15:04"), a.ID, a.Message)
+	}
+	w.Flush()
+}
+
+// hostalertsdismisscmd dismisses an alert of the host.
+func hostalertsdismisscmd(id string) {
+	err := post("/host/alerts/dismiss", "id="+url.QueryEscape(id))
+	if err != nil {
+		die("Could not dismiss alert:", err)
+	}
+	fmt.Println("Dismissed alert", id)
+}
+
+// hostbackupcmd writes a snapshot of the host's metadata to a directory.
+func hostbackupcmd(dir string) {
+	err := post("/host/backup", "destination="+url.QueryEscape(abs(dir)))
+	if err != nil {
+		die("Could not back up host:", err)
+	}
+	fmt.Println("Host metadata backed up to", abs(dir))
+}
+
+// folderpath returns the query-escaped form of a storage folder path. Local
+// paths are made absolute; object store paths are left as they are.
+func folderpath(path string) string {
+	if !strings.Contains(path, "://") {
+		path = abs(path)
+	}
+	return url.QueryEscape(path)
+}
+
 // hostfolderaddcmd adds a folder to the host.
 func hostfolderaddcmd(path, size string) {
 	size, err := parseFilesize(size)
 	if err != nil {
 		die("Could not parse size:", err)
 	}
-	err = post("/host/storage/folders/add", fmt.Sprintf("path=%s&size=%s", abs(path), size))
+	err = post("/host/storage/folders/add", fmt.Sprintf("path=%s&size=%s", folderpath(path), size))
 	if err != nil {
 		die("Could not add folder:", err)
 	}
 	fmt.Println("Added folder", path)
 }
 
+// hostfolderrelinkcmd points a folder of the host at its new path.
+func hostfolderrelinkcmd(path, newpath string) {
+	err := post("/host/storage/folders/relink", fmt.Sprintf("path=%s&newpath=%s", folderpath(path), folderpath(newpath)))
+	if err != nil {
+		die("Could not relink folder:", err)
+	}
+	fmt.Printf("Relinked folder %v to %v\n", path, newpath)
+}
+
 // hostfolderremovecmd removes a folder from the host.
 func hostfolderremovecmd(path string) {
-	err := post("/host/storage/folders/remove", "path="+abs(path))
+	err := post("/host/storage/folders/remove", "path="+folderpath(path))
 	if err != nil {
 		die("Could not remove folder:", err)
 	}
@@ -408,7 +550,7 @@ func hostfolderresizecmd(path, newsize string) {
 	if err != nil {
 		die("Could not parse size:", err)
 	}
-	err = post("/host/storage/folders/resize", fmt.Sprintf("path=%s&newsize=%s", abs(path), newsize))
+	err = post("/host/storage/folders/resize", fmt.Sprintf("path=%s&newsize=%s", folderpath(path), newsize))
 	if err != nil {
 		die("Could not resize folder:", err)
 	}
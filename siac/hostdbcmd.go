@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -16,6 +19,15 @@ var (
 		Long:  "Add and remove hosts, or list active hosts on the network.",
 		Run:   wrap(hostdbcmd),
 	}
+
+	hostdbHistoryCmd = &cobra.Command{
+		Use:   "history [netaddress]",
+		Short: "Show the settings history of a host",
+		Long: `Show each observed change to the prices, collateral, and capacity advertised
+by a host, oldest first. Hosts that change their prices often can be avoided
+by checking their history.`,
+		Run: wrap(hostdbhistorycmd),
+	}
 )
 
 func hostdbcmd() {
@@ -34,3 +46,29 @@ func hostdbcmd() {
 		fmt.Printf("\t%v - %v / TB / Month\n", host.NetAddress, currencyUnits(price))
 	}
 }
+
+// hostdbhistorycmd prints the settings history of a host.
+func hostdbhistorycmd(addr string) {
+	var hh api.HostSettingsHistory
+	err := getAPI("/hostdb/history/"+addr, &hh)
+	if err != nil {
+		die("Could not fetch settings history:", err)
+	}
+	if len(hh.History) == 0 {
+		fmt.Println("The host has not been scanned successfully.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Observed\tHeight\tContract Price\tStorage Price (TB/Mo)\tCollateral (TB/Mo)\tDownload (TB)\tUpload (TB)\tTotal Storage")
+	for _, c := range hh.History {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			time.Unix(int64(c.Timestamp), 0).Format("Jan 02 2006 03:04 PM"), c.BlockHeight,
+			currencyUnits(c.ContractPrice),
+			currencyUnits(c.StoragePrice.Mul(modules.BlockBytesPerMonthTerabyte)),
+			currencyUnits(c.Collateral.Mul(modules.BlockBytesPerMonthTerabyte)),
+			currencyUnits(c.DownloadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+			currencyUnits(c.UploadBandwidthPrice.Mul(modules.BytesPerTerabyte)),
+			filesizeUnits(int64(c.TotalStorage)))
+	}
+	w.Flush()
+}
@@ -18,11 +18,26 @@ import (
 
 // flags
 var (
-	addr              string // override default API address
-	initPassword      bool   // supply a custom password when creating a wallet
-	hostVerbose       bool   // display additional host info
-	renterShowHistory bool   // Show download history in addition to download queue.
-	renterListVerbose bool   // Show additional info about uploaded files.
+	addr                   string   // override default API address
+	consensusRepair        bool     // repair inconsistencies found in the consensus database
+	initPassword           bool     // supply a custom password when creating a wallet
+	hostVerbose            bool     // display additional host info
+	minerCPUDutyCycle      int      // Percentage of time that each cpu mining thread mines.
+	minerCPUThreads        int      // Number of cpu mining threads.
+	minerCPUWindows        string   // Times of day during which the cpu miner mines.
+	renterCheckRepair      bool     // Repair inconsistencies found in the renter's file metadata.
+	renterContractsVerbose bool     // Show the spending and renewal status of each contract.
+	renterDownloadMatch    string   // Download the files whose siapaths match this pattern.
+	renterShowHistory      bool     // Show download history in addition to download queue.
+	renterListVerbose      bool     // Show additional info about uploaded files.
+	renterMinHostVersion   string   // Only upload to hosts running at least this version.
+	renterUploadRecursive  bool     // Upload the files of a directory and its subdirectories.
+	renterUploadTags       []string // Tags to attach to uploaded files.
+	renterUploadChunkSize  uint64   // Custom chunk size for uploaded files.
+	renterUploadCipher     string   // Cipher that encrypts uploaded files.
+	renterUploadRegions    []string // Regions that uploaded files are restricted to.
+	renterUploadRetain     string   // Date until which uploaded files are kept.
+	walletKeygenCount      int      // Number of key pairs generated by 'wallet keygen'.
 )
 
 // exit codes
@@ -235,37 +250,63 @@ func main() {
 	updateCmd.AddCommand(updateCheckCmd)
 
 	root.AddCommand(hostCmd)
-	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostFolderCmd, hostSectorCmd)
-	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostCmd.AddCommand(hostConfigCmd, hostAlertsCmd, hostAnnounceCmd, hostAuditCmd, hostBackupCmd, hostFolderCmd, hostSectorCmd)
+	hostAlertsCmd.AddCommand(hostAlertsDismissCmd)
+	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRelinkCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
 	hostSectorCmd.AddCommand(hostSectorDeleteCmd)
 	hostCmd.Flags().BoolVarP(&hostVerbose, "verbose", "v", false, "Display detailed host info")
 
 	root.AddCommand(hostdbCmd)
+	hostdbCmd.AddCommand(hostdbHistoryCmd)
 
 	root.AddCommand(minerCmd)
-	minerCmd.AddCommand(minerStartCmd, minerStopCmd)
+	minerCmd.AddCommand(minerStartCmd, minerStopCmd, minerCPUCmd, minerPayoutsCmd)
+	minerCPUCmd.AddCommand(minerCPUSetCmd)
+	minerCPUSetCmd.Flags().IntVarP(&minerCPUThreads, "threads", "t", 1, "Number of threads that mine at once")
+	minerCPUSetCmd.Flags().IntVarP(&minerCPUDutyCycle, "dutycycle", "d", 100, "Percentage of time that each thread spends mining")
+	minerCPUSetCmd.Flags().StringVarP(&minerCPUWindows, "windows", "w", "", "Times of day to mine, such as '22:00-06:00,12:00-13:00'")
+	minerPayoutsCmd.AddCommand(minerPayoutsSetCmd, minerPayoutsResetCmd)
 
 	root.AddCommand(walletCmd)
-	walletCmd.AddCommand(walletAddressCmd, walletAddressesCmd, walletInitCmd,
-		walletLoadCmd, walletLockCmd, walletSeedsCmd, walletSendCmd,
+	walletCmd.AddCommand(walletAccountsCmd, walletAddressCmd, walletAddressesCmd, walletInitCmd,
+		walletKeygenCmd, walletLoadCmd, walletLockCmd, walletSeedsCmd, walletSendCmd, walletSweepCmd,
 		walletBalanceCmd, walletTransactionsCmd, walletUnlockCmd)
 	walletInitCmd.Flags().BoolVarP(&initPassword, "password", "p", false, "Prompt for a custom password")
-	walletLoadCmd.AddCommand(walletLoad033xCmd, walletLoadSeedCmd, walletLoadSiagCmd)
-	walletSendCmd.AddCommand(walletSendSiacoinsCmd, walletSendSiafundsCmd)
+	walletAccountsCmd.AddCommand(walletAccountsAddressCmd, walletAccountsCreateCmd, walletAccountsSendCmd)
+	walletKeygenCmd.Flags().IntVarP(&walletKeygenCount, "count", "n", 1, "Number of key pairs to generate")
+	walletLoadCmd.AddCommand(walletLoad033xCmd, walletLoadLooseKeyCmd, walletLoadSeedCmd, walletLoadSiagCmd)
+	walletSendCmd.AddCommand(walletSendSiacoinsCmd, walletSendSiafundsCmd, walletSendBatchCmd)
 
 	root.AddCommand(renterCmd)
-	renterCmd.AddCommand(renterFilesDeleteCmd, renterFilesDownloadCmd,
+	renterCmd.AddCommand(renterFilesCopyCmd, renterFilesDeleteCmd, renterFilesDownloadCmd,
 		renterDownloadsCmd, renterAllowanceCmd, renterSetAllowanceCmd,
-		renterContractsCmd, renterFilesListCmd, renterFilesRenameCmd,
-		renterFilesUploadCmd, renterUploadsCmd)
+		renterContractsCmd, renterExportKeysCmd, renterFilesListCmd,
+		renterFilesRenameCmd, renterFilesRetainCmd, renterFilesUploadCmd, renterOpenKeysCmd,
+		renterUploadsCmd, renterCheckCmd)
+	renterCheckCmd.Flags().BoolVarP(&renterCheckRepair, "repair", "r", false, "Repair any inconsistencies that are found")
+	renterContractsCmd.Flags().BoolVarP(&renterContractsVerbose, "verbose", "v", false, "Show the spending and renewal status of each contract")
 	renterCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
+	renterFilesDownloadCmd.Flags().StringVarP(&renterDownloadMatch, "match", "m", "", "Download every file whose path matches a pattern, such as 'photos/2016/*', into the destination directory")
 	renterDownloadsCmd.Flags().BoolVarP(&renterShowHistory, "history", "H", false, "Show download history in addition to the download queue")
 	renterFilesListCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
+	renterFilesUploadCmd.Flags().StringVarP(&renterMinHostVersion, "min-host-version", "m", "", "Only upload to hosts running at least this version")
+	renterFilesUploadCmd.Flags().BoolVarP(&renterUploadRecursive, "recursive", "r", false, "Upload every file of a directory and its subdirectories, under [path]")
+	renterFilesUploadCmd.Flags().StringSliceVarP(&renterUploadTags, "tag", "t", nil, "Attach a 'key:value' tag to the file (may be repeated)")
+	renterFilesUploadCmd.Flags().Uint64VarP(&renterUploadChunkSize, "chunk-size", "c", 0, "Use a custom chunk size in bytes (must be a multiple of the number of data pieces)")
+	renterFilesUploadCmd.Flags().StringVarP(&renterUploadCipher, "cipher", "", "", "Encrypt the file with this cipher (twofish-gcm, aes-gcm, or chacha20-poly1305)")
+	renterFilesUploadCmd.Flags().StringSliceVarP(&renterUploadRegions, "regions", "", nil, "Only upload to hosts located in these regions, such as 'EU' or 'CH' (comma-separated)")
+	renterFilesUploadCmd.Flags().StringVarP(&renterUploadRetain, "retain-until", "", "", "Keep the file until this date (YYYY-MM-DD) instead of forever")
 
 	root.AddCommand(gatewayCmd)
-	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayAddressCmd, gatewayListCmd)
+	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayUnpinCmd, gatewayAddressCmd, gatewayListCmd, gatewayBandwidthCmd)
+	gatewayBandwidthCmd.AddCommand(gatewayBandwidthLimitCmd)
 
 	root.AddCommand(consensusCmd)
+	consensusCmd.AddCommand(consensusCheckCmd, consensusCheckpointCmd)
+	consensusCheckCmd.Flags().BoolVarP(&consensusRepair, "repair", "r", false, "Repair any inconsistencies that are found")
+
+	root.AddCommand(explorerCmd)
+	explorerCmd.AddCommand(explorerCheckCmd)
 
 	// parse flags
 	root.PersistentFlags().StringVarP(&addr, "addr", "a", "localhost:9980", "which host/port to communicate with (i.e. the host/port siad is listening on)")
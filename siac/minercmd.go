@@ -2,8 +2,13 @@ package main
 
 import (
 	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/NebulousLabs/Sia/api"
+	"github.com/NebulousLabs/Sia/modules"
 
 	"github.com/spf13/cobra"
 )
@@ -16,6 +21,52 @@ var (
 		Run:   wrap(minercmd),
 	}
 
+	minerCPUCmd = &cobra.Command{
+		Use:   "cpu",
+		Short: "View the cpu miner settings",
+		Long:  "View the thread count, duty cycle, and mining windows of the cpu miner.",
+		Run:   wrap(minercpucmd),
+	}
+
+	minerCPUSetCmd = &cobra.Command{
+		Use:   "set",
+		Short: "Change the cpu miner settings",
+		Long: `Change the thread count, duty cycle, or mining windows of the cpu miner.
+Settings that are not given are left unchanged. The duty cycle is the
+percentage of time that each thread spends mining. Mining windows are times of
+day, in the local time of siad, during which the cpu miner mines. For example:
+
+	siac miner cpu set --threads 2 --dutycycle 50 --windows 22:00-06:00
+
+Pass --windows "" to mine at all times.`,
+		Run: minercpusetcmd,
+	}
+
+	minerPayoutsCmd = &cobra.Command{
+		Use:   "payouts",
+		Short: "View how block payouts are split",
+		Long:  "View the addresses that block payouts are split between.",
+		Run:   wrap(minerpayoutscmd),
+	}
+
+	minerPayoutsResetCmd = &cobra.Command{
+		Use:   "reset",
+		Short: "Send block payouts to the wallet",
+		Long:  "Stop splitting block payouts, and send the whole payout to an address from the wallet.",
+		Run:   wrap(minerpayoutsresetcmd),
+	}
+
+	minerPayoutsSetCmd = &cobra.Command{
+		Use:   "set [address:percent,...]",
+		Short: "Split block payouts between addresses",
+		Long: `Split block payouts between addresses. Each address is followed by the
+percentage of the payout it receives, with up to two decimal places, and the
+percentages must sum to 100. For example:
+
+	siac miner payouts set <owner address>:97.5,<operator address>:2.5`,
+		Run: wrap(minerpayoutssetcmd),
+	}
+
 	minerStartCmd = &cobra.Command{
 		Use:   "start",
 		Short: "Start cpu mining",
@@ -57,8 +108,9 @@ func minercmd() {
 	fmt.Printf(`Miner status:
 CPU Mining:   %s
 CPU Hashrate: %v KH/s
-Blocks Mined: %d (%d stale)
-`, miningStr, status.CPUHashrate/1000, status.BlocksMined, status.StaleBlocksMined)
+Blocks Mined: %d (%d stale, %d orphaned)
+Stale Rate:   %.2f%%
+`, miningStr, status.CPUHashrate/1000, status.BlocksMined, status.StaleBlocksMined, status.OrphanedBlocksMined, status.StaleRate*100)
 }
 
 // minerstopcmd is the handler for the command `siac miner stop`.
@@ -70,3 +122,113 @@ func minerstopcmd() {
 	}
 	fmt.Println("Stopped mining.")
 }
+
+// minerpayoutscmd is the handler for the command `siac miner payouts`.
+// Prints the addresses that block payouts are split between.
+func minerpayoutscmd() {
+	var mpg api.MinerPayoutsGET
+	err := getAPI("/miner/payouts", &mpg)
+	if err != nil {
+		die("Could not get payout splits:", err)
+	}
+	if len(mpg.Splits) == 0 {
+		fmt.Println("Block payouts are sent to the wallet.")
+		return
+	}
+	fmt.Println("Block payouts are split between:")
+	for _, split := range mpg.Splits {
+		fmt.Printf("\t%6.2f%%  %v\n", float64(split.BasisPoints)/100, split.UnlockHash)
+	}
+}
+
+// minerpayoutsresetcmd is the handler for the command `siac miner payouts
+// reset`. Sends block payouts to the wallet.
+func minerpayoutsresetcmd() {
+	err := post("/miner/payouts", "")
+	if err != nil {
+		die("Could not reset payout splits:", err)
+	}
+	fmt.Println("Block payouts are now sent to the wallet.")
+}
+
+// minerpayoutssetcmd is the handler for the command `siac miner payouts set`.
+// Splits block payouts between addresses.
+func minerpayoutssetcmd(splits string) {
+	var addrs, shares []string
+	for _, split := range strings.Split(splits, ",") {
+		i := strings.LastIndex(split, ":")
+		if i == -1 {
+			die("Could not parse payout split", split, "- expected address:percent")
+		}
+		percent, err := strconv.ParseFloat(split[i+1:], 64)
+		if err != nil || percent <= 0 {
+			die("Could not parse percentage of payout split", split)
+		}
+		// Convert the percentage to basis points, rounding to the nearest
+		// basis point.
+		basisPoints := uint64(percent*float64(modules.MinerPayoutSplitTotal)/100 + 0.5)
+		addrs = append(addrs, split[:i])
+		shares = append(shares, strconv.FormatUint(basisPoints, 10))
+	}
+	err := post("/miner/payouts", "addresses="+strings.Join(addrs, ",")+"&basispoints="+strings.Join(shares, ","))
+	if err != nil {
+		die("Could not set payout splits:", err)
+	}
+	fmt.Printf("Block payouts are now split between %v addresses.\n", len(addrs))
+}
+
+// minercpucmd is the handler for the command `siac miner cpu`. Prints the
+// thread count, duty cycle, and mining windows of the cpu miner.
+func minercpucmd() {
+	var cs modules.MinerCPUSettings
+	err := getAPI("/miner/cpu", &cs)
+	if err != nil {
+		die("Could not get cpu miner settings:", err)
+	}
+	threads, dutyCycle := cs.Threads, cs.DutyCycle
+	if threads == 0 {
+		threads = 1
+	}
+	if dutyCycle == 0 {
+		dutyCycle = 100
+	}
+	fmt.Printf(`CPU miner settings:
+Threads:    %d
+Duty Cycle: %d%%
+`, threads, dutyCycle)
+	if len(cs.Windows) == 0 {
+		fmt.Println("Windows:    always mining")
+		return
+	}
+	fmt.Println("Windows:")
+	for _, w := range cs.Windows {
+		fmt.Printf("\t%02d:%02d-%02d:%02d\n", w.Start/60, w.Start%60, w.End/60, w.End%60)
+	}
+}
+
+// minercpusetcmd is the handler for the command `siac miner cpu set`. Changes
+// the thread count, duty cycle, or mining windows of the cpu miner.
+func minercpusetcmd(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.UsageFunc()(cmd)
+		os.Exit(exitCodeUsage)
+	}
+	values := url.Values{}
+	if cmd.Flags().Changed("threads") {
+		values.Set("threads", strconv.Itoa(minerCPUThreads))
+	}
+	if cmd.Flags().Changed("dutycycle") {
+		values.Set("dutycycle", strconv.Itoa(minerCPUDutyCycle))
+	}
+	if cmd.Flags().Changed("windows") {
+		values.Set("windows", minerCPUWindows)
+	}
+	if len(values) == 0 {
+		die("No settings given; see 'siac miner cpu set --help'")
+	}
+	err := post("/miner/cpu", values.Encode())
+	if err != nil {
+		die("Could not change cpu miner settings:", err)
+	}
+	fmt.Println("CPU miner settings updated.")
+}
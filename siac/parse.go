@@ -6,6 +6,7 @@ import (
 	"math"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -76,6 +77,20 @@ func parsePeriod(period string) (string, error) {
 	return fmt.Sprint(blocks), nil
 }
 
+// parseRetainUntil converts a date of the form 2006-01-02, in local time, to
+// a unix timestamp. "forever" is converted to zero, which keeps a file
+// forever.
+func parseRetainUntil(date string) (string, error) {
+	if date == "forever" {
+		return "0", nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return "", errors.New("date must be of the form YYYY-MM-DD, or 'forever'")
+	}
+	return fmt.Sprint(t.Unix()), nil
+}
+
 // currencyUnits converts a types.Currency to a string with human-readable
 // units. The unit used will be the largest unit that results in a value
 // greater than 1. The value is rounded to 4 significant digits.
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -72,3 +74,20 @@ func TestCurrencyUnits(t *testing.T) {
 		}
 	}
 }
+
+// TestParseRetainUntil checks that retention dates are converted to unix
+// timestamps.
+func TestParseRetainUntil(t *testing.T) {
+	if res, err := parseRetainUntil("forever"); err != nil || res != "0" {
+		t.Errorf("parseRetainUntil(forever): expected 0, got %v %v", res, err)
+	}
+	expected := time.Date(2017, time.March, 4, 0, 0, 0, 0, time.Local).Unix()
+	if res, err := parseRetainUntil("2017-03-04"); err != nil || res != fmt.Sprint(expected) {
+		t.Errorf("parseRetainUntil(2017-03-04): expected %v, got %v %v", expected, res, err)
+	}
+	for _, in := range []string{"", "2017-3-4", "04/03/2017", "never"} {
+		if _, err := parseRetainUntil(in); err == nil {
+			t.Errorf("parseRetainUntil(%q): expected an error", in)
+		}
+	}
+}
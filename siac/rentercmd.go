@@ -1,16 +1,23 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
@@ -54,6 +61,17 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run: wrap(rentersetallowancecmd),
 	}
 
+	renterCheckCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check the Renter's file metadata for inconsistencies",
+		Long: `Check the Renter's file metadata against its contracts, reporting files that
+reference expired contracts, files that reference pieces missing from their
+contracts, and contracts holding data that no file references. With --repair,
+references to missing data are removed from the files so that the Renter can
+upload the data again.`,
+		Run: wrap(rentercheckcmd),
+	}
+
 	renterContractsCmd = &cobra.Command{
 		Use:   "contracts",
 		Short: "View the Renter's contracts",
@@ -61,6 +79,14 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run:   wrap(rentercontractscmd),
 	}
 
+	renterFilesCopyCmd = &cobra.Command{
+		Use:     "copy [path] [newpath]",
+		Aliases: []string{"cp"},
+		Short:   "Copy a file",
+		Long:    "Copy a file. The copy shares the data of the original, so nothing is uploaded.",
+		Run:     wrap(renterfilescopycmd),
+	}
+
 	renterFilesDeleteCmd = &cobra.Command{
 		Use:     "delete [path]",
 		Aliases: []string{"rm"},
@@ -72,8 +98,33 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 	renterFilesDownloadCmd = &cobra.Command{
 		Use:   "download [path] [destination]",
 		Short: "Download a file",
-		Long:  "Download a previously-uploaded file to a specified destination.",
-		Run:   wrap(renterfilesdownloadcmd),
+		Long: `Download a previously-uploaded file to a specified destination.
+With --match, download every file whose path matches a pattern into the
+destination directory: siac renter download --match 'photos/2016/*' [destination]`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if renterDownloadMatch != "" {
+				wrap(renterfilesdownloadmatchcmd)(cmd, args)
+				return
+			}
+			wrap(renterfilesdownloadcmd)(cmd, args)
+		},
+	}
+
+	renterExportKeysCmd = &cobra.Command{
+		Use:   "exportkeys [path] [destination]",
+		Short: "Export the keys of a file",
+		Long: `Export the keys and piece locations of a file to [destination], encrypted
+with a password. The export lets someone else recover the file from its hosts
+without learning the master key of the file, which may protect other files.
+Use 'siac renter openkeys' to decrypt the export.`,
+		Run: wrap(renterexportkeyscmd),
+	}
+
+	renterOpenKeysCmd = &cobra.Command{
+		Use:   "openkeys [file]",
+		Short: "Decrypt exported file keys",
+		Long:  "Decrypt a file created by 'siac renter exportkeys' and print its contents as JSON.",
+		Run:   wrap(renteropenkeyscmd),
 	}
 
 	renterFilesListCmd = &cobra.Command{
@@ -92,11 +143,21 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run:     wrap(renterfilesrenamecmd),
 	}
 
+	renterFilesRetainCmd = &cobra.Command{
+		Use:   "retain [path] [date]",
+		Short: "Set how long a file is kept",
+		Long: `Keep a file until [date], given as YYYY-MM-DD, or 'forever'. Once the date
+has passed, the file is no longer repaired, and contracts that only hold files
+past their date are not renewed.`,
+		Run: wrap(renterfilesretaincmd),
+	}
+
 	renterFilesUploadCmd = &cobra.Command{
 		Use:   "upload [source] [path]",
 		Short: "Upload a file",
-		Long:  "Upload a file to [path] on the Sia network.",
-		Run:   wrap(renterfilesuploadcmd),
+		Long: `Upload a file to [path] on the Sia network. With --recursive, upload every
+file of the [source] directory and its subdirectories under [path].`,
+		Run: wrap(renterfilesuploadcmd),
 	}
 )
 
@@ -259,8 +320,49 @@ func (s byValue) Less(i, j int) bool {
 	return cmp > 0
 }
 
+// rentercheckcmd is the handler for the command `siac renter check`. Checks
+// the renter's file metadata for inconsistencies, optionally repairing them.
+func rentercheckcmd() {
+	var report modules.RenterCheckReport
+	var err error
+	if renterCheckRepair {
+		err = postResp("/renter/consistency", "", &report)
+	} else {
+		err = getAPI("/renter/consistency", &report)
+	}
+	if err != nil {
+		die("Could not check renter metadata:", err)
+	}
+	fmt.Printf("Checked %v files against %v contracts.\n", report.FilesChecked, report.ContractsChecked)
+	if len(report.Issues) == 0 {
+		fmt.Println("No inconsistencies found.")
+		return
+	}
+	fmt.Println("Inconsistencies found:")
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Issue\tCount\tFile\tHost\tContract\tRepaired")
+	unrepaired := false
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			issue.Type,
+			issue.Count,
+			issue.SiaPath,
+			issue.NetAddress,
+			issue.ContractID,
+			yesNo(issue.Repaired))
+		if !issue.Repaired && issue.Type != modules.RenterIssueOrphanedSectors {
+			unrepaired = true
+		}
+	}
+	w.Flush()
+	if unrepaired {
+		fmt.Println("Run 'siac renter check --repair' to repair the file metadata.")
+	}
+}
+
 // rentercontractscmd is the handler for the comand `siac renter contracts`.
-// It lists the Renter's contracts.
+// It lists the Renter's contracts. With --verbose, it also lists how the funds
+// of each contract have been spent and whether the contract is being renewed.
 func rentercontractscmd() {
 	var rc api.RenterContracts
 	err := getAPI("/renter/contracts", &rc)
@@ -274,6 +376,37 @@ func rentercontractscmd() {
 	sort.Sort(byValue(rc.Contracts))
 	fmt.Println("Contracts:")
 	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	if renterContractsVerbose {
+		var remaining, storage, upload, download types.Currency
+		fmt.Fprintln(w, "Host\tRemaining\tStorage\tUpload\tDownload\tData\tEnd Height\tRenewal\tID")
+		for _, c := range rc.Contracts {
+			renewal := c.RenewStatus
+			if c.RenewedFrom != (types.FileContractID{}) {
+				renewal += " (renewed)"
+			}
+			fmt.Fprintf(w, "%v\t%8s\t%8s\t%8s\t%8s\t%v\t%v\t%v\t%v\n",
+				c.NetAddress,
+				currencyUnits(c.RenterFunds),
+				currencyUnits(c.StorageSpending),
+				currencyUnits(c.UploadSpending),
+				currencyUnits(c.DownloadSpending),
+				filesizeUnits(int64(c.Size)),
+				c.EndHeight,
+				renewal,
+				c.ID)
+			remaining = remaining.Add(c.RenterFunds)
+			storage = storage.Add(c.StorageSpending)
+			upload = upload.Add(c.UploadSpending)
+			download = download.Add(c.DownloadSpending)
+		}
+		fmt.Fprintf(w, "Total\t%8s\t%8s\t%8s\t%8s\t\t\t\t\n",
+			currencyUnits(remaining),
+			currencyUnits(storage),
+			currencyUnits(upload),
+			currencyUnits(download))
+		w.Flush()
+		return
+	}
 	fmt.Fprintln(w, "Host\tValue\tData\tEnd Height\tID")
 	for _, c := range rc.Contracts {
 		fmt.Fprintf(w, "%v\t%8s\t%v\t%v\t%v\n",
@@ -306,6 +439,43 @@ func renterfilesdownloadcmd(path, destination string) {
 	fmt.Printf("Downloaded '%s' to %s.\n", path, abs(destination))
 }
 
+// renterfilesdownloadmatchcmd is the handler for the command
+// `siac renter download --match [pattern] [destination]`. Downloads the files
+// whose paths match the pattern into the destination directory, reporting
+// their aggregate progress.
+func renterfilesdownloadmatchcmd(destination string) {
+	vals := url.Values{}
+	vals.Set("match", renterDownloadMatch)
+	vals.Set("destination", abs(destination))
+	var batch api.RenterBatch
+	err := postResp("/renter/batch/download", vals.Encode(), &batch)
+	if err != nil {
+		die("Could not download files:", err)
+	}
+
+	progressCall := "/renter/batch/progress?direction=download&match=" + url.QueryEscape(renterDownloadMatch)
+	for {
+		var job api.Job
+		err = getAPI("/jobs/"+batch.JobID, &job)
+		if err != nil {
+			die("\nCould not get the status of the download:", err)
+		}
+		var progress api.RenterBatchProgress
+		err = getAPI(progressCall, &progress)
+		if err != nil {
+			die("\nCould not get the progress of the download:", err)
+		}
+		fmt.Printf("\rDownloading %v files: %v of %v done, %.2f%%", progress.Files, progress.Completed, progress.Files, progress.Progress)
+		if job.Status == api.JobStatusFailed {
+			die("\nCould not download files:", job.Error)
+		} else if job.Status == api.JobStatusSucceeded {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	fmt.Printf("\nDownloaded %v files to %s.\n", len(batch.SiaPaths), abs(destination))
+}
+
 // bySiaPath implements sort.Interface for [] modules.FileInfo based on the
 // SiaPath field.
 type bySiaPath []modules.FileInfo
@@ -356,6 +526,16 @@ func renterfileslistcmd() {
 	w.Flush()
 }
 
+// renterfilescopycmd is the handler for the command `siac renter copy [path] [newpath]`.
+// Copies a file on the Sia network.
+func renterfilescopycmd(path, newpath string) {
+	err := post("/renter/copy/"+path, "newsiapath="+newpath)
+	if err != nil {
+		die("Could not copy file:", err)
+	}
+	fmt.Printf("Copied %s to %s\n", path, newpath)
+}
+
 // renterfilesrenamecmd is the handler for the command `siac renter rename [path] [newpath]`.
 // Renames a file on the Sia network.
 func renterfilesrenamecmd(path, newpath string) {
@@ -366,12 +546,169 @@ func renterfilesrenamecmd(path, newpath string) {
 	fmt.Printf("Renamed %s to %s\n", path, newpath)
 }
 
+// renterfilesretaincmd is the handler for the command `siac renter retain
+// [path] [date]`. Sets the date until which a file is kept.
+func renterfilesretaincmd(path, date string) {
+	until, err := parseRetainUntil(date)
+	if err != nil {
+		die("Could not parse date:", err)
+	}
+	err = post("/renter/retention/"+path, "until="+until)
+	if err != nil {
+		die("Could not set file retention:", err)
+	}
+	if until == "0" {
+		fmt.Printf("Keeping %s forever\n", path)
+	} else {
+		fmt.Printf("Keeping %s until %s\n", path, date)
+	}
+}
+
+// renterexportkeyscmd is the handler for the command `siac renter exportkeys
+// [path] [destination]`. Writes the keys of a file, sealed with a password, to
+// [destination].
+func renterexportkeyscmd(path, destination string) {
+	password, err := speakeasy.Ask("Export password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	confirm, err := speakeasy.Ask("Confirm password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	if password != confirm {
+		die("Passwords do not match")
+	}
+	var sealed modules.SealedFileKeys
+	err = postResp("/renter/keys/"+path, url.Values{"password": {password}}.Encode(), &sealed)
+	if err != nil {
+		die("Could not export keys:", err)
+	}
+	b, err := json.MarshalIndent(sealed, "", "\t")
+	if err != nil {
+		die("Could not encode keys:", err)
+	}
+	if err := ioutil.WriteFile(abs(destination), b, 0600); err != nil {
+		die("Could not write keys:", err)
+	}
+	fmt.Printf("Exported the keys of %s to %s\n", path, abs(destination))
+}
+
+// renteropenkeyscmd is the handler for the command `siac renter openkeys
+// [file]`. Decrypts exported file keys and prints them.
+func renteropenkeyscmd(file string) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		die("Could not read keys:", err)
+	}
+	var sealed modules.SealedFileKeys
+	if err := json.Unmarshal(b, &sealed); err != nil {
+		die("Could not decode keys:", err)
+	}
+	password, err := speakeasy.Ask("Export password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	fk, err := modules.OpenFileKeys(sealed, password)
+	if err != nil {
+		die("Could not decrypt keys:", err)
+	}
+	b, err = json.MarshalIndent(fk, "", "\t")
+	if err != nil {
+		die("Could not encode keys:", err)
+	}
+	fmt.Println(string(b))
+}
+
 // renterfilesuploadcmd is the handler for the command `siac renter upload [source] [path]`.
 // Uploads the [source] file to [path] on the Sia network.
 func renterfilesuploadcmd(source, path string) {
-	err := post("/renter/upload/"+path, "source="+abs(source))
+	if renterUploadRecursive {
+		renterfilesuploaddircmd(source, path)
+		return
+	}
+	qs := "source=" + abs(source)
+	if renterMinHostVersion != "" {
+		qs += "&minhostversion=" + renterMinHostVersion
+	}
+	if renterUploadChunkSize != 0 {
+		qs += fmt.Sprintf("&chunksize=%d", renterUploadChunkSize)
+	}
+	if renterUploadCipher != "" {
+		qs += "&cipher=" + url.QueryEscape(renterUploadCipher)
+	}
+	if len(renterUploadRegions) > 0 {
+		qs += "&regions=" + url.QueryEscape(strings.Join(renterUploadRegions, ","))
+	}
+	if renterUploadRetain != "" {
+		until, err := parseRetainUntil(renterUploadRetain)
+		if err != nil {
+			die("Could not parse retention date:", err)
+		}
+		qs += "&retainuntil=" + until
+	}
+	for _, tag := range renterUploadTags {
+		qs += "&tag=" + url.QueryEscape(tag)
+	}
+	err := post("/renter/upload/"+path, qs)
 	if err != nil {
 		die("Could not upload file:", err)
 	}
 	fmt.Printf("Uploaded '%s' as %s.\n", abs(source), path)
 }
+
+// renterfilesuploaddircmd is the handler for the command
+// `siac renter upload --recursive [source] [path]`. Uploads every file of the
+// source directory and its subdirectories, under path.
+func renterfilesuploaddircmd(source, path string) {
+	vals := url.Values{}
+	err := filepath.Walk(abs(source), func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(abs(source), file)
+		if err != nil {
+			return err
+		}
+		vals.Add("source", file)
+		vals.Add("siapath", strings.TrimSuffix(path, "/")+"/"+filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		die("Could not read directory:", err)
+	}
+	if len(vals["source"]) == 0 {
+		die("No files found in", abs(source))
+	}
+	if renterMinHostVersion != "" {
+		vals.Set("minhostversion", renterMinHostVersion)
+	}
+	if renterUploadChunkSize != 0 {
+		vals.Set("chunksize", fmt.Sprint(renterUploadChunkSize))
+	}
+	if renterUploadCipher != "" {
+		vals.Set("cipher", renterUploadCipher)
+	}
+	if len(renterUploadRegions) > 0 {
+		vals.Set("regions", strings.Join(renterUploadRegions, ","))
+	}
+	if renterUploadRetain != "" {
+		until, err := parseRetainUntil(renterUploadRetain)
+		if err != nil {
+			die("Could not parse retention date:", err)
+		}
+		vals.Set("retainuntil", until)
+	}
+	for _, tag := range renterUploadTags {
+		vals.Add("tag", tag)
+	}
+	var batch api.RenterBatch
+	err = postResp("/renter/batch/upload", vals.Encode(), &batch)
+	if err != nil {
+		die("Could not upload files:", err)
+	}
+	for _, f := range batch.Failures {
+		fmt.Printf("Could not upload %s: %v\n", f.SiaPath, f.Error)
+	}
+	fmt.Printf("Uploading %v files from %s to %s. Run 'siac renter uploads' to view their progress.\n", len(batch.SiaPaths), abs(source), path)
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
 
 	"github.com/NebulousLabs/Sia/api"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -31,6 +38,38 @@ The smallest unit of siacoins is the hasting. One siacoin is 10^24 hastings. Oth
 		Run: wrap(walletbalancecmd),
 	}
 
+	walletAccountsCmd = &cobra.Command{
+		Use:   "accounts",
+		Short: "List wallet accounts",
+		Long: `List the accounts of the wallet and their balances. Accounts keep siacoins and
+siafunds apart from the rest of the wallet, and are not included in the wallet's
+balance. Account addresses are derived from the wallet seed; creating the same
+accounts in the same order on a restored wallet recovers their funds.`,
+		Run: wrap(walletaccountscmd),
+	}
+
+	walletAccountsAddressCmd = &cobra.Command{
+		Use:   "address [name]",
+		Short: "Get a new account address",
+		Long:  "Generate a new address that pays to an account.",
+		Run:   wrap(walletaccountsaddresscmd),
+	}
+
+	walletAccountsCreateCmd = &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create a wallet account",
+		Long:  "Add a named account to the wallet. The wallet must be unlocked.",
+		Run:   wrap(walletaccountscreatecmd),
+	}
+
+	walletAccountsSendCmd = &cobra.Command{
+		Use:   "send [name] [amount] [dest]",
+		Short: "Send siacoins from an account",
+		Long: `Send siacoins from an account to an address. The miner fee is paid by the
+account. Run 'wallet send siacoins --help' for the format of 'amount' and 'dest'.`,
+		Run: wrap(walletaccountssendcmd),
+	}
+
 	walletAddressCmd = &cobra.Command{
 		Use:   "address",
 		Short: "Get a new wallet address",
@@ -53,6 +92,17 @@ By default the wallet encryption / unlock password is the same as the generated
 		Run: wrap(walletinitcmd),
 	}
 
+	walletKeygenCmd = &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate standalone addresses and their secret keys",
+		Long: `Generate addresses that are not derived from the seed of the wallet, together
+with the secret keys that spend from them. The keys are generated by siac itself,
+so siad does not need to be running and the computer can be offline. Write the
+keys down or print them as paper wallets; use 'siac wallet sweep' or
+'siac wallet load loosekey' to spend the coins sent to an address later.`,
+		Run: wrap(walletkeygencmd),
+	}
+
 	walletLoadCmd = &cobra.Command{
 		Use:   "load",
 		Short: "Load a wallet seed, v0.3.3.x wallet, or siag keyset",
@@ -67,6 +117,15 @@ By default the wallet encryption / unlock password is the same as the generated
 		Run:   wrap(walletload033xcmd),
 	}
 
+	walletLoadLooseKeyCmd = &cobra.Command{
+		Use:   "loosekey",
+		Short: "Load the secret key of a standalone address into the wallet",
+		Long: `Load a secret key generated by 'siac wallet keygen' into the wallet, so that
+the coins sent to its address can be spent. The coins become spendable after
+siad is restarted.`,
+		Run: wrap(walletloadloosekeycmd),
+	}
+
 	walletLoadSeedCmd = &cobra.Command{
 		Use:   `seed`,
 		Short: "Add a seed to the wallet",
@@ -110,11 +169,26 @@ By default the wallet encryption / unlock password is the same as the generated
 		Long: `Send siacoins to an address. 'dest' must be a 76-byte hexadecimal address.
 'amount' can be specified in units, e.g. 1.23KS. Run 'wallet --help' for a list of units.
 If no unit is supplied, hastings will be assumed.
+If 'amount' is "max", as many siacoins as possible are sent, emptying the wallet
+except for any siacoins that are reserved.
 
-A miner fee of 10 SC is levied on all transactions.`,
+A miner fee of 10 SC is levied on all transactions. Sending the maximum amount
+may require a larger fee if many outputs need to be spent.`,
 		Run: wrap(walletsendsiacoinscmd),
 	}
 
+	walletSendBatchCmd = &cobra.Command{
+		Use:   "batch [file]",
+		Short: "Send siacoins to many addresses in one transaction",
+		Long: `Send siacoins to many addresses in a single transaction. Each line of 'file'
+holds a destination address and an amount, separated by a comma or whitespace.
+Amounts can be specified in units, as for 'wallet send siacoins'. Blank lines
+and lines starting with '#' are ignored.
+
+The miner fee is at least 10 SC, and grows with the number of addresses.`,
+		Run: wrap(walletsendbatchcmd),
+	}
+
 	walletSendSiafundsCmd = &cobra.Command{
 		Use:   "siafunds [amount] [dest]",
 		Short: "Send siafunds",
@@ -123,6 +197,16 @@ Run 'wallet send --help' to see a list of available units.`,
 		Run: wrap(walletsendsiafundscmd),
 	}
 
+	walletSweepCmd = &cobra.Command{
+		Use:   "sweep",
+		Short: "Sweep the coins of secret keys into the wallet",
+		Long: `Send the siacoins and siafunds held by secret keys that are not part of the
+wallet, such as the keys of paper wallets, to a new address of the wallet. The
+keys are read from the prompt, separated by commas. The miner fees are paid
+from the swept siacoins, or by the wallet if the keys hold too few siacoins.`,
+		Run: wrap(walletsweepcmd),
+	}
+
 	walletBalanceCmd = &cobra.Command{
 		Use:   "balance",
 		Short: "View wallet balance",
@@ -158,6 +242,60 @@ func walletaddresscmd() {
 	fmt.Printf("Created new address: %s\n", addr.Address)
 }
 
+// walletaccountscmd lists the accounts of the wallet.
+func walletaccountscmd() {
+	var wag api.WalletAccountsGET
+	err := getAPI("/wallet/accounts", &wag)
+	if err != nil {
+		die("Could not fetch accounts:", err)
+	}
+	if len(wag.Accounts) == 0 {
+		fmt.Println("No accounts.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Name\tConfirmed\tUnconfirmed Out\tUnconfirmed In\tSiafunds\tAddresses")
+	for _, a := range wag.Accounts {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v SF\t%v\n", a.Name, currencyUnits(a.ConfirmedSiacoinBalance),
+			currencyUnits(a.UnconfirmedOutgoingSiacoins), currencyUnits(a.UnconfirmedIncomingSiacoins),
+			a.SiafundBalance, a.AddressCount)
+	}
+	w.Flush()
+}
+
+// walletaccountsaddresscmd generates a new address of an account.
+func walletaccountsaddresscmd(name string) {
+	var addr api.WalletAddressGET
+	err := getAPI("/wallet/accounts/address/"+name, &addr)
+	if err != nil {
+		die("Could not generate new address:", err)
+	}
+	fmt.Printf("Created new address for account %v: %s\n", name, addr.Address)
+}
+
+// walletaccountscreatecmd adds an account to the wallet.
+func walletaccountscreatecmd(name string) {
+	err := post("/wallet/accounts/create", "name="+url.QueryEscape(name))
+	if err != nil {
+		die("Could not create account:", err)
+	}
+	fmt.Println("Created account", name)
+}
+
+// walletaccountssendcmd sends siacoins from an account to a destination
+// address.
+func walletaccountssendcmd(name, amount, dest string) {
+	hastings, err := parseCurrency(amount)
+	if err != nil {
+		die("Could not parse amount:", err)
+	}
+	err = post("/wallet/accounts/siacoins/"+name, fmt.Sprintf("amount=%s&destination=%s", hastings, dest))
+	if err != nil {
+		die("Could not send siacoins:", err)
+	}
+	fmt.Printf("Sent %s hastings from %v to %s\n", hastings, name, dest)
+}
+
 // walletaddressescmd fetches the list of addresses that the wallet knows.
 func walletaddressescmd() {
 	addrs := new(api.WalletAddressesGET)
@@ -193,6 +331,44 @@ func walletinitcmd() {
 	}
 }
 
+// walletkeygencmd generates standalone addresses and their secret keys.
+func walletkeygencmd() {
+	if walletKeygenCount < 1 {
+		die("Count must be at least 1")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Address\tSecret Key")
+	for i := 0; i < walletKeygenCount; i++ {
+		kp, err := modules.NewKeyPair()
+		if err != nil {
+			die("Could not generate key pair:", err)
+		}
+		fmt.Fprintf(w, "%v\t%v\n", kp.Address, kp.SecretKey)
+	}
+	w.Flush()
+	fmt.Println()
+	fmt.Println("Anyone who knows a secret key can spend the coins sent to its address. Keep the keys offline.")
+}
+
+// walletloadloosekeycmd loads the secret key of a standalone address into the
+// wallet.
+func walletloadloosekeycmd() {
+	secretKey, err := speakeasy.Ask("Secret key: ")
+	if err != nil {
+		die("Reading secret key failed:", err)
+	}
+	password, err := speakeasy.Ask(askPasswordText)
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	qs := fmt.Sprintf("secretkey=%s&encryptionpassword=%s", strings.TrimSpace(secretKey), password)
+	err = post("/wallet/loosekey", qs)
+	if err != nil {
+		die("Loading secret key failed:", err)
+	}
+	fmt.Println("Key loading successful. Restart siad to spend the coins sent to its address.")
+}
+
 // walletload033xcmd loads a v0.3.3.x wallet into the current wallet.
 func walletload033xcmd(source string) {
 	password, err := speakeasy.Ask(askPasswordText)
@@ -264,6 +440,15 @@ func walletseedscmd() {
 
 // walletsendsiacoinscmd sends siacoins to a destination address.
 func walletsendsiacoinscmd(amount, dest string) {
+	if amount == "max" {
+		var resp api.WalletSiacoinsPOST
+		err := postResp("/wallet/siacoins", "sendmax=true&destination="+dest, &resp)
+		if err != nil {
+			die("Could not send siacoins:", err)
+		}
+		fmt.Printf("Sent %s to %s\n", currencyUnits(resp.Amount), dest)
+		return
+	}
 	hastings, err := parseCurrency(amount)
 	if err != nil {
 		die("Could not parse amount:", err)
@@ -275,6 +460,63 @@ func walletsendsiacoinscmd(amount, dest string) {
 	fmt.Printf("Sent %s hastings to %s\n", hastings, dest)
 }
 
+// walletsendbatchcmd sends siacoins to the destinations listed in a file.
+func walletsendbatchcmd(filename string) {
+	f, err := os.Open(filename)
+	if err != nil {
+		die("Could not open file:", err)
+	}
+	defer f.Close()
+
+	var outputs []types.SiacoinOutput
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) != 2 {
+			die(fmt.Sprintf("Line %v: expected an address and an amount", line))
+		}
+		var dest types.UnlockHash
+		if err := dest.LoadString(fields[0]); err != nil {
+			die(fmt.Sprintf("Line %v: could not parse address: %v", line, err))
+		}
+		hastings, err := parseCurrency(fields[1])
+		if err != nil {
+			die(fmt.Sprintf("Line %v: could not parse amount: %v", line, err))
+		}
+		value, ok := new(big.Int).SetString(hastings, 10)
+		if !ok {
+			die(fmt.Sprintf("Line %v: could not parse amount", line))
+		}
+		outputs = append(outputs, types.SiacoinOutput{
+			Value:      types.NewCurrency(value),
+			UnlockHash: dest,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		die("Could not read file:", err)
+	}
+	if len(outputs) == 0 {
+		die("File does not list any destinations")
+	}
+
+	outputsJSON, err := json.Marshal(outputs)
+	if err != nil {
+		die("Could not encode outputs:", err)
+	}
+	var resp api.WalletSiacoinsPOST
+	err = postResp("/wallet/siacoins", "outputs="+url.QueryEscape(string(outputsJSON)), &resp)
+	if err != nil {
+		die("Could not send siacoins:", err)
+	}
+	fmt.Printf("Sent %s to %v addresses\n", currencyUnits(resp.Amount), len(outputs))
+}
+
 // walletsendsiafundscmd sends siafunds to a destination address.
 func walletsendsiafundscmd(amount, dest string) {
 	err := post("/wallet/siafunds", fmt.Sprintf("amount=%s&destination=%s", amount, dest))
@@ -284,6 +526,24 @@ func walletsendsiafundscmd(amount, dest string) {
 	fmt.Printf("Sent %s siafunds to %s\n", amount, dest)
 }
 
+// walletsweepcmd sweeps the outputs of secret keys into the wallet.
+func walletsweepcmd() {
+	secretKeys, err := speakeasy.Ask("Secret keys: ")
+	if err != nil {
+		die("Reading secret keys failed:", err)
+	}
+	var keys []string
+	for _, key := range strings.Split(secretKeys, ",") {
+		keys = append(keys, strings.TrimSpace(key))
+	}
+	var wsp api.WalletSweepPOST
+	err = postResp("/wallet/sweep", "secretkeys="+strings.Join(keys, ","), &wsp)
+	if err != nil {
+		die("Could not sweep keys:", err)
+	}
+	fmt.Printf("Swept %v and %v siafunds into the wallet\n", currencyUnits(wsp.Coins), wsp.Funds)
+}
+
 // walletbalancecmd retrieves and displays information about the wallet.
 func walletbalancecmd() {
 	status := new(api.WalletGET)
@@ -315,10 +575,11 @@ Unlock the wallet to view balance
 %s, Unlocked
 Confirmed Balance:   %v
 Unconfirmed Delta:  %v
+Reserved:            %v
 Exact:               %v H
 Siafunds:            %v SF
 Siafund Claims:      %v H
-`, encStatus, currencyUnits(status.ConfirmedSiacoinBalance), delta,
+`, encStatus, currencyUnits(status.ConfirmedSiacoinBalance), delta, currencyUnits(status.ReservedSiacoins),
 		status.ConfirmedSiacoinBalance, status.SiafundBalance, status.SiacoinClaimBalance)
 }
 
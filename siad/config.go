@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// configFile is the name of the configuration file that siad loads from
+	// the sia directory when --config is not set.
+	configFile = "siad.toml"
+
+	// gatewaySection and hostSection are the tables of the configuration
+	// file that hold the gateway and host settings. They are the only part
+	// of the file that is applied again when the configuration is reloaded.
+	gatewaySection = "gateway"
+	hostSection    = "host"
+)
+
+var (
+	errNoConfigFile = errors.New("siad was not started with a configuration file")
+)
+
+// readConfigFile reads a siad configuration file. Every top level key of the
+// file is the long name of a siad flag, and the gateway and host tables hold
+// the settings of the gateway and host.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	fc := make(map[string]interface{})
+	if _, err := toml.DecodeFile(path, &fc); err != nil {
+		return nil, fmt.Errorf("could not read configuration file %v: %v", path, err)
+	}
+	return fc, nil
+}
+
+// findConfigFile returns the path of the configuration file that siad should
+// load, or "" if there is none. The --config flag must point to an existing
+// file, while the default configuration file is optional.
+func findConfigFile(path, siaDir string) (string, error) {
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	path = filepath.Join(siaDir, configFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// applyConfigFlags sets the flags named by the top level keys of a
+// configuration file. Flags that were set on the command line take precedence
+// over the file, and are left untouched. Arrays are joined into the
+// comma-separated lists expected by flags such as --api-cors-origins.
+func applyConfigFlags(flags *pflag.FlagSet, fc map[string]interface{}) error {
+	for key, value := range fc {
+		if key == gatewaySection || key == hostSection {
+			continue
+		}
+		f := flags.Lookup(key)
+		if f == nil || key == "config" {
+			return fmt.Errorf("unknown configuration key %q", key)
+		}
+		if f.Changed {
+			continue
+		}
+		var s string
+		switch v := value.(type) {
+		case []interface{}:
+			elems := make([]string, len(v))
+			for i := range v {
+				elems[i] = fmt.Sprint(v[i])
+			}
+			s = strings.Join(elems, ",")
+		case map[string]interface{}:
+			return fmt.Errorf("configuration key %q must be a value, not a table", key)
+		default:
+			s = fmt.Sprint(v)
+		}
+		if err := flags.Set(key, s); err != nil {
+			return fmt.Errorf("invalid value for configuration key %q: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// mergeSettings overwrites the fields of settings with the values of a table
+// of the configuration file. The keys of the table are the JSON names of the
+// fields, which are also used by the API, and fields that are not in the table
+// keep their current values.
+func mergeSettings(table interface{}, settings interface{}) error {
+	values, ok := table.(map[string]interface{})
+	if !ok {
+		return errors.New("must be a table")
+	}
+	current, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(current, &fields); err != nil {
+		return err
+	}
+	for key := range values {
+		if _, exists := fields[key]; !exists {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, settings)
+}
+
+// applyModuleSettings applies the gateway and host tables of a configuration
+// file to the loaded modules. A table for a module that is not loaded is an
+// error. The bandwidth limits of the file replace all of the gateway's
+// current limits, so that removing a limit from the file lifts it.
+func applyModuleSettings(fc map[string]interface{}, g modules.Gateway, h modules.Host) error {
+	if table, ok := fc[gatewaySection]; ok {
+		if g == nil {
+			return errors.New("the configuration file has gateway settings, but the gateway is not loaded")
+		}
+		settings := g.Settings()
+		if values, ok := table.(map[string]interface{}); ok {
+			if _, ok := values["bandwidthlimits"]; ok {
+				settings.BandwidthLimits = nil
+			}
+		}
+		if err := mergeSettings(table, &settings); err != nil {
+			return fmt.Errorf("invalid gateway settings: %v", err)
+		}
+		if err := g.SetSettings(settings); err != nil {
+			return fmt.Errorf("could not apply gateway settings: %v", err)
+		}
+	}
+	if table, ok := fc[hostSection]; ok {
+		if h == nil {
+			return errors.New("the configuration file has host settings, but the host is not loaded")
+		}
+		settings := h.InternalSettings()
+		if err := mergeSettings(table, &settings); err != nil {
+			return fmt.Errorf("invalid host settings: %v", err)
+		}
+		if err := h.SetInternalSettings(settings); err != nil {
+			return fmt.Errorf("could not apply host settings: %v", err)
+		}
+	}
+	return nil
+}
+
+// reloadConfigFile reads the configuration file again and applies its gateway
+// and host settings. The flags of the file only take effect when siad is
+// restarted.
+func reloadConfigFile(path string, g modules.Gateway, h modules.Host) error {
+	if path == "" {
+		return errNoConfigFile
+	}
+	fc, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	return applyModuleSettings(fc, g, h)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/spf13/pflag"
+)
+
+// TestConfigFile checks that a configuration file sets the flags that were
+// not set on the command line, and that its module settings are merged into
+// the current settings.
+func TestConfigFile(t *testing.T) {
+	dir := build.TempDir("siad", "TestConfigFile")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without --config, a missing default configuration file is ignored.
+	path, err := findConfigFile("", dir)
+	if err != nil || path != "" {
+		t.Fatal("missing default configuration file was not ignored:", path, err)
+	}
+	if _, err := findConfigFile(filepath.Join(dir, "missing.toml"), dir); err == nil {
+		t.Fatal("missing --config file was accepted")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, configFile), []byte(`
+modules = "gctwh"
+api-addr = "localhost:9000"
+prune-depth = 1000
+api-cors-origins = ["https://a.example.com", "https://b.example.com"]
+
+[host]
+acceptingcontracts = true
+minstorageprice = "1000000"
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err = findConfigFile("", dir)
+	if err != nil || path != filepath.Join(dir, configFile) {
+		t.Fatal("default configuration file was not found:", path, err)
+	}
+	fc, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flags set on the command line take precedence over the file.
+	var config Config
+	flags := pflag.NewFlagSet("siad", pflag.ContinueOnError)
+	flags.StringVarP(&config.Siad.Modules, "modules", "M", "cghmrtw", "")
+	flags.StringVarP(&config.Siad.APIaddr, "api-addr", "", "localhost:9980", "")
+	flags.StringVarP(&config.Siad.APICORSOrigins, "api-cors-origins", "", "", "")
+	flags.Uint64VarP(&config.Siad.PruneDepth, "prune-depth", "", 0, "")
+	if err := flags.Parse([]string{"-M", "gct"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFlags(flags, fc); err != nil {
+		t.Fatal(err)
+	}
+	if config.Siad.Modules != "gct" {
+		t.Error("command line flag was overwritten by the configuration file:", config.Siad.Modules)
+	}
+	if config.Siad.APIaddr != "localhost:9000" || config.Siad.PruneDepth != 1000 {
+		t.Error("configuration file values were not applied:", config.Siad.APIaddr, config.Siad.PruneDepth)
+	}
+	if config.Siad.APICORSOrigins != "https://a.example.com,https://b.example.com" {
+		t.Error("array was not joined into a list:", config.Siad.APICORSOrigins)
+	}
+
+	// Keys that are not flags are rejected.
+	if err := applyConfigFlags(flags, map[string]interface{}{"api-adr": "localhost:9000"}); err == nil {
+		t.Error("unknown configuration key was accepted")
+	}
+
+	// Host settings that are not in the file keep their current values.
+	settings := modules.HostInternalSettings{
+		MaxDuration:      144,
+		MinStoragePrice:  types.NewCurrency64(5),
+		MinContractPrice: types.NewCurrency64(7),
+	}
+	if err := mergeSettings(fc[hostSection], &settings); err != nil {
+		t.Fatal(err)
+	}
+	if !settings.AcceptingContracts || settings.MinStoragePrice.Cmp(types.NewCurrency64(1e6)) != 0 {
+		t.Error("host settings were not applied:", settings)
+	}
+	if settings.MaxDuration != 144 || settings.MinContractPrice.Cmp(types.NewCurrency64(7)) != 0 {
+		t.Error("host settings missing from the file were changed:", settings)
+	}
+	if err := mergeSettings(map[string]interface{}{"minstorgeprice": "1"}, &settings); err == nil {
+		t.Error("unknown host setting was accepted")
+	}
+}
+
+// TestReloadConfigFile checks that reloading fails when siad was not started
+// with a configuration file, or when the file has settings for a module that
+// is not loaded.
+func TestReloadConfigFile(t *testing.T) {
+	if err := reloadConfigFile("", nil, nil); err != errNoConfigFile {
+		t.Fatal("expected errNoConfigFile, got", err)
+	}
+
+	dir := build.TempDir("siad", "TestReloadConfigFile")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, configFile)
+	err := ioutil.WriteFile(path, []byte("[gateway]\nmaxpeers = 10\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfigFile(path, nil, nil); err == nil {
+		t.Fatal("gateway settings were accepted without a gateway")
+	}
+
+	// The server refuses to reload until the modules have been loaded.
+	srv := &Server{}
+	if err := srv.reload(); err == nil {
+		t.Fatal("configuration was reloaded while loading")
+	}
+	srv.setReloader(func() error { return nil })
+	if err := srv.reload(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/NebulousLabs/Sia/api"
@@ -22,6 +24,8 @@ import (
 	"github.com/NebulousLabs/Sia/modules/transactionpool"
 	"github.com/NebulousLabs/Sia/modules/wallet"
 	"github.com/NebulousLabs/Sia/profile"
+	siasync "github.com/NebulousLabs/Sia/sync"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
@@ -30,15 +34,37 @@ import (
 // verifyAPISecurity checks that the security values are consistent with a
 // sane, secure system.
 func verifyAPISecurity(config Config) error {
+	// API tokens and authenticated reads only make sense on top of the API
+	// password.
+	if config.Siad.APITokensFile != "" && !config.Siad.AuthenticateAPI {
+		return errors.New("cannot use --api-tokens without setting an api password")
+	}
+	if config.Siad.AuthenticateAPIReads && !config.Siad.AuthenticateAPI {
+		return errors.New("cannot use --authenticate-api-reads without setting an api password")
+	}
+
+	// TLS requires both a certificate and its private key, and CORS origins
+	// must be plain origins, without a path.
+	if (config.Siad.APITLSCert == "") != (config.Siad.APITLSKey == "") {
+		return errors.New("--api-tls-cert and --api-tls-key must be used together")
+	}
+	for _, origin := range splitFlagList(config.Siad.APICORSOrigins) {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("invalid --api-cors-origins origin %q, origins look like https://example.com", origin)
+		}
+	}
+
 	// Make sure that only the loopback address is allowed unless the
-	// --disable-api-security flag has been used.
-	if !config.Siad.AllowAPIBind {
+	// --disable-api-security flag has been used, or the API is served over
+	// TLS and protected by a password.
+	if !config.Siad.AllowAPIBind && !(config.Siad.APITLSCert != "" && config.Siad.AuthenticateAPI) {
 		addr := modules.NetAddress(config.Siad.APIaddr)
 		if !addr.IsLoopback() {
 			if addr.Host() == "" {
-				return fmt.Errorf("a blank host will listen on all interfaces, did you mean localhost:%v?\nyou must pass --disable-api-security to bind Siad to a non-localhost address", addr.Port())
+				return fmt.Errorf("a blank host will listen on all interfaces, did you mean localhost:%v?\nyou must pass --disable-api-security, or use --api-tls-cert with --authenticate-api, to bind Siad to a non-localhost address", addr.Port())
 			}
-			return errors.New("you must pass --disable-api-security to bind Siad to a non-localhost address")
+			return errors.New("you must pass --disable-api-security, or use --api-tls-cert with --authenticate-api, to bind Siad to a non-localhost address")
 		}
 		return nil
 	}
@@ -51,6 +77,18 @@ func verifyAPISecurity(config Config) error {
 	return nil
 }
 
+// splitFlagList splits a comma-separated flag into its elements, ignoring
+// whitespace and empty elements.
+func splitFlagList(list string) []string {
+	var elems []string
+	for _, elem := range strings.Split(list, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
+
 // processNetAddr adds a ':' to a bare integer, so that it is a proper port
 // number.
 func processNetAddr(addr string) string {
@@ -61,11 +99,54 @@ func processNetAddr(addr string) string {
 	return addr
 }
 
+// moduleSets maps the names that can be passed to --modules in place of
+// module letters to the modules they run. Each set includes the modules its
+// main module depends on.
+var moduleSets = map[string]string{
+	"explorer": "gce",
+	"full":     "cghmrtw",
+	"host":     "gctwh",
+	"miner":    "gctwm",
+	"renter":   "gctwr",
+	"wallet":   "gctw",
+}
+
+// moduleNames maps the letter of each module to its name.
+var moduleNames = map[rune]string{
+	'c': "consensus set",
+	'e': "explorer",
+	'g': "gateway",
+	'h': "host",
+	'm': "miner",
+	'r': "renter",
+	't': "transaction pool",
+	'w': "wallet",
+}
+
+// moduleDependencies maps the letter of each module to the letters of the
+// modules it requires.
+var moduleDependencies = map[rune]string{
+	'c': "g",
+	'e': "c",
+	'h': "ctw",
+	'm': "ctw",
+	'r': "ctw",
+	't': "gc",
+	'w': "ct",
+}
+
+// lightModules are the modules that can run on top of a light consensus set.
+const lightModules = "gw"
+
 // processModules makes the modules string lowercase to make checking if a
 // module in the string easier, and returns an error if the string contains an
-// invalid module character.
+// invalid module character. The name of a module set is replaced by the
+// modules of the set.
 func processModules(modules string) (string, error) {
 	modules = strings.ToLower(modules)
+	if set, ok := moduleSets[modules]; ok {
+		return set, nil
+	}
 	validModules := "cghmrtwe"
 	invalidModules := modules
 	for _, m := range validModules {
@@ -77,6 +158,34 @@ func processModules(modules string) (string, error) {
 	return modules, nil
 }
 
+// checkModuleDependencies returns an error if a module is enabled without
+// the modules it requires.
+func checkModuleDependencies(modules string) error {
+	for _, m := range modules {
+		for _, req := range moduleDependencies[m] {
+			if !strings.ContainsRune(modules, req) {
+				return fmt.Errorf("the %v (%c) requires the %v (%c)", moduleNames[m], m, moduleNames[req], req)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLightModules returns an error if a module that requires the full
+// consensus set is enabled in light mode, or if the wallet is enabled without
+// the gateway.
+func checkLightModules(modules string) error {
+	for _, m := range modules {
+		if !strings.ContainsRune(lightModules, m) {
+			return fmt.Errorf("the %v (%c) cannot be used with --light, which only supports the gateway (g) and wallet (w)", moduleNames[m], m)
+		}
+	}
+	if strings.ContainsRune(modules, 'w') && !strings.ContainsRune(modules, 'g') {
+		return errors.New("the light wallet (w) requires the gateway (g)")
+	}
+	return nil
+}
+
 // processConfig checks the configuration values and performs cleanup on
 // incorrect-but-allowed values.
 func processConfig(config Config) (Config, error) {
@@ -85,14 +194,52 @@ func processConfig(config Config) (Config, error) {
 	config.Siad.RPCaddr = processNetAddr(config.Siad.RPCaddr)
 	config.Siad.HostAddr = processNetAddr(config.Siad.HostAddr)
 	config.Siad.Modules, err1 = processModules(config.Siad.Modules)
+	if err1 == nil && config.Siad.Light {
+		err1 = checkLightModules(config.Siad.Modules)
+	} else if err1 == nil {
+		err1 = checkModuleDependencies(config.Siad.Modules)
+	}
 	err2 := verifyAPISecurity(config)
-	err := build.JoinErrors([]error{err1, err2}, ", and ")
+	var err3 error
+	if config.Siad.PruneDepth > 0 && strings.Contains(config.Siad.Modules, "e") {
+		err3 = errors.New("the explorer requires the full blockchain and cannot be used with --prune-depth")
+	} else if config.Siad.FastBootstrap && strings.Contains(config.Siad.Modules, "e") {
+		err3 = errors.New("the explorer requires the full blockchain and cannot be used with --fast-bootstrap")
+	} else if config.Siad.FastBootstrap && config.Siad.NoBootstrap {
+		err3 = errors.New("--fast-bootstrap cannot be used with --no-bootstrap")
+	} else if config.Siad.Light && (config.Siad.FastBootstrap || config.Siad.PruneDepth > 0) {
+		err3 = errors.New("--light does not store the blockchain and cannot be used with --fast-bootstrap or --prune-depth")
+	}
+	err := build.JoinErrors([]error{err1, err2, err3}, ", and ")
 	if err != nil {
 		return Config{}, err
 	}
 	return config, nil
 }
 
+// stalledShutdownInterval is how often a shutdown that has not finished
+// reports the threads that are blocking it.
+const stalledShutdownInterval = 30 * time.Second
+
+// reportStalledShutdown prints the threads that are preventing the modules
+// from stopping every stalledShutdownInterval until done is closed.
+func reportStalledShutdown(done <-chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(stalledShutdownInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		fmt.Printf("Shutdown has not finished after %v seconds.\n", int(time.Since(start).Seconds()))
+		for _, sg := range siasync.Stopping() {
+			fmt.Printf("%v has been waiting %v seconds for: %v\n", sg.Name, int(sg.Waiting.Seconds()), strings.Join(sg.Blocked, ", "))
+		}
+	}
+}
+
 // startDaemon uses the config parameters to initialize Sia modules and start
 // siad.
 func startDaemon(config Config) (err error) {
@@ -107,19 +254,47 @@ func startDaemon(config Config) (err error) {
 		}
 	}
 
+	// Report the threads that are blocking the shutdown if closing the
+	// modules takes too long. shutdown is closed once every module has been
+	// closed.
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+
 	// Process the config variables after they are parsed by cobra.
 	config, err = processConfig(config)
 	if err != nil {
 		return err
 	}
 
+	// Load the API tokens.
+	if config.Siad.APITokensFile != "" {
+		config.APITokens, err = api.LoadTokens(config.Siad.APITokensFile)
+		if err != nil {
+			return fmt.Errorf("could not load API tokens: %v", err)
+		}
+		for _, t := range config.APITokens {
+			if t.Secret == config.APIPassword {
+				return errors.New("API tokens cannot be the same as the API password")
+			}
+		}
+	}
+
+	// Route outbound connections through the proxy before any module starts
+	// making connections.
+	if config.Siad.Proxy != "" {
+		if err := modules.SetProxy(modules.NetAddress(config.Siad.Proxy)); err != nil {
+			return err
+		}
+		fmt.Println("Routing outbound connections through proxy", config.Siad.Proxy)
+	}
+
 	// Print a startup message.
 	fmt.Println("Loading...")
 	loadStart := time.Now()
 
 	// Create the server and start serving daemon routes immediately.
 	fmt.Printf("(0/%d) Loading siad...\n", len(config.Siad.Modules))
-	srv, err := NewServer(config.Siad.APIaddr, config.Siad.RequiredUserAgent, config.APIPassword)
+	srv, err := NewServer(config)
 	if err != nil {
 		return err
 	}
@@ -146,12 +321,19 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during gateway shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('g', g)
 	}
 	var cs modules.ConsensusSet
 	if strings.Contains(config.Siad.Modules, "c") {
 		i++
 		fmt.Printf("(%d/%d) Loading consensus...\n", i, len(config.Siad.Modules))
-		cs, err = consensus.New(g, !config.Siad.NoBootstrap, filepath.Join(config.Siad.SiaDir, modules.ConsensusDir))
+		if config.Siad.FastBootstrap {
+			cs, err = consensus.NewFromCheckpoint(g, filepath.Join(config.Siad.SiaDir, modules.ConsensusDir), types.BlockHeight(config.Siad.PruneDepth))
+		} else if config.Siad.PruneDepth > 0 {
+			cs, err = consensus.NewPruned(g, !config.Siad.NoBootstrap, filepath.Join(config.Siad.SiaDir, modules.ConsensusDir), types.BlockHeight(config.Siad.PruneDepth))
+		} else {
+			cs, err = consensus.New(g, !config.Siad.NoBootstrap, filepath.Join(config.Siad.SiaDir, modules.ConsensusDir))
+		}
 		if err != nil {
 			return err
 		}
@@ -162,6 +344,22 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during consensus set shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('c', cs)
+	}
+	var lcs modules.LightConsensusSet
+	if config.Siad.Light && strings.Contains(config.Siad.Modules, "g") {
+		fmt.Println("Loading light consensus set...")
+		lcs, err = consensus.NewLight(g, filepath.Join(config.Siad.SiaDir, modules.ConsensusDir))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			fmt.Println("Closing light consensus set...")
+			err := lcs.Close()
+			if err != nil {
+				fmt.Println("Error during light consensus set shutdown:", err)
+			}
+		}()
 	}
 	var e modules.Explorer
 	if strings.Contains(config.Siad.Modules, "e") {
@@ -178,6 +376,7 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during explorer shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('e', e)
 	}
 	var tpool modules.TransactionPool
 	if strings.Contains(config.Siad.Modules, "t") {
@@ -194,12 +393,17 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during transaction pool shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('t', tpool)
 	}
 	var w modules.Wallet
 	if strings.Contains(config.Siad.Modules, "w") {
 		i++
 		fmt.Printf("(%d/%d) Loading wallet...\n", i, len(config.Siad.Modules))
-		w, err = wallet.New(cs, tpool, filepath.Join(config.Siad.SiaDir, modules.WalletDir))
+		if config.Siad.Light {
+			w, err = wallet.NewLight(lcs, g, filepath.Join(config.Siad.SiaDir, modules.WalletDir))
+		} else {
+			w, err = wallet.New(cs, tpool, filepath.Join(config.Siad.SiaDir, modules.WalletDir))
+		}
 		if err != nil {
 			return err
 		}
@@ -210,6 +414,7 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during wallet shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('w', w)
 	}
 	var m modules.Miner
 	if strings.Contains(config.Siad.Modules, "m") {
@@ -226,12 +431,20 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during miner shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('m', m)
+		if config.Siad.GetworkAddr != "" {
+			err = m.ServeGetwork(config.Siad.GetworkAddr)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Serving getwork on", config.Siad.GetworkAddr)
+		}
 	}
 	var h modules.Host
 	if strings.Contains(config.Siad.Modules, "h") {
 		i++
 		fmt.Printf("(%d/%d) Loading host...\n", i, len(config.Siad.Modules))
-		h, err = host.New(cs, tpool, w, config.Siad.HostAddr, filepath.Join(config.Siad.SiaDir, modules.HostDir))
+		h, err = host.NewWithMetadataDir(cs, tpool, w, config.Siad.HostAddr, filepath.Join(config.Siad.SiaDir, modules.HostDir), config.Siad.HostMetadataDir)
 		if err != nil {
 			return err
 		}
@@ -242,6 +455,7 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during host shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('h', h)
 	}
 	var r modules.Renter
 	if strings.Contains(config.Siad.Modules, "r") {
@@ -258,12 +472,17 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during renter shutdown:", err)
 			}
 		}()
+		srv.setModuleLoaded('r', r)
 	}
 
 	// Create the Sia API
 	a := api.New(
 		config.Siad.RequiredUserAgent,
-		config.APIPassword,
+		api.Auth{
+			Password:          config.APIPassword,
+			Tokens:            config.APITokens,
+			AuthenticateReads: config.Siad.AuthenticateAPIReads,
+		},
 		cs,
 		e,
 		g,
@@ -277,6 +496,35 @@ func startDaemon(config Config) (err error) {
 	// connect the API to the server
 	srv.mux.Handle("/", a)
 
+	// Report the health of the loaded modules via /daemon/health. Modules
+	// that were not loaded are nil interfaces and are skipped.
+	for _, nm := range []struct {
+		name   string
+		module interface{}
+	}{
+		{"consensus", cs},
+		{"gateway", g},
+		{"host", h},
+		{"renter", r},
+		{"wallet", w},
+	} {
+		if nm.module != nil {
+			srv.addHealthChecker(nm.name, nm.module)
+		}
+	}
+
+	// Apply the gateway and host settings of the configuration file, and
+	// apply them again whenever the configuration is reloaded.
+	if config.Siad.ConfigFile != "" {
+		if err := reloadConfigFile(config.Siad.ConfigFile, g, h); err != nil {
+			return err
+		}
+	}
+	srv.setReloader(func() error {
+		return reloadConfigFile(config.Siad.ConfigFile, g, h)
+	})
+	srv.setLoaded()
+
 	// stop the server if a kill signal is caught
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, os.Kill)
@@ -286,6 +534,19 @@ func startDaemon(config Config) (err error) {
 		srv.Close()
 	}()
 
+	// reload the configuration file if a hangup signal is caught
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if err := srv.reload(); err != nil {
+				fmt.Println("Could not reload configuration:", err)
+				continue
+			}
+			fmt.Println("Reloaded configuration from", config.Siad.ConfigFile)
+		}
+	}()
+
 	// Print a 'startup complete' message.
 	startupTime := time.Since(loadStart)
 	fmt.Println("Finished loading in", startupTime.Seconds(), "seconds")
@@ -295,18 +556,37 @@ func startDaemon(config Config) (err error) {
 		build.Critical(err)
 	}
 
+	// The modules are closed by the deferred calls above.
+	go reportStalledShutdown(shutdown)
 	return nil
 }
 
 // startDaemonCmd is a passthrough function for startDaemon.
 func startDaemonCmd(cmd *cobra.Command, _ []string) {
+	// Load the configuration file, which sets the flags that were not set on
+	// the command line.
+	path, err := findConfigFile(globalConfig.Siad.ConfigFile, globalConfig.Siad.SiaDir)
+	if err != nil {
+		die("Could not find configuration file:", err)
+	}
+	if path != "" {
+		fc, err := readConfigFile(path)
+		if err != nil {
+			die(err)
+		}
+		if err := applyConfigFlags(cmd.Flags(), fc); err != nil {
+			die(err)
+		}
+	}
+	globalConfig.Siad.ConfigFile = path
+
 	// Create the profiling directory if profiling is enabled.
 	if globalConfig.Siad.Profile || build.DEBUG {
 		go profile.StartContinuousProfile(globalConfig.Siad.ProfileDir)
 	}
 
 	// Start siad. startDaemon will only return when it is shutting down.
-	err := startDaemon(globalConfig)
+	err = startDaemon(globalConfig)
 	if err != nil {
 		die(err)
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -162,4 +163,112 @@ func TestVerifyAPISecurity(t *testing.T) {
 	if err != nil {
 		t.Error("public + securityOff with authentication was rejected:", err)
 	}
+
+	// Check that API tokens and authenticated reads require an api password.
+	var tokensUnauthenticated Config
+	tokensUnauthenticated.Siad.APIaddr = "localhost:9980"
+	tokensUnauthenticated.Siad.APITokensFile = "tokens"
+	err = verifyAPISecurity(tokensUnauthenticated)
+	if err == nil {
+		t.Error("api tokens were accepted without authentication")
+	}
+	var readsUnauthenticated Config
+	readsUnauthenticated.Siad.APIaddr = "localhost:9980"
+	readsUnauthenticated.Siad.AuthenticateAPIReads = true
+	err = verifyAPISecurity(readsUnauthenticated)
+	if err == nil {
+		t.Error("authenticated reads were accepted without authentication")
+	}
+
+	// Check that a public hostname is accepted over TLS with an api
+	// password, and that TLS requires both a certificate and a key.
+	var tlsPublicAuthenticated Config
+	tlsPublicAuthenticated.Siad.APIaddr = ":9980"
+	tlsPublicAuthenticated.Siad.APITLSCert = "cert.pem"
+	tlsPublicAuthenticated.Siad.APITLSKey = "key.pem"
+	tlsPublicAuthenticated.Siad.AuthenticateAPI = true
+	err = verifyAPISecurity(tlsPublicAuthenticated)
+	if err != nil {
+		t.Error("public + TLS with authentication was rejected:", err)
+	}
+	tlsPublic := tlsPublicAuthenticated
+	tlsPublic.Siad.AuthenticateAPI = false
+	err = verifyAPISecurity(tlsPublic)
+	if err == nil {
+		t.Error("public + TLS was accepted without authentication")
+	}
+	tlsNoKey := tlsPublicAuthenticated
+	tlsNoKey.Siad.APITLSKey = ""
+	err = verifyAPISecurity(tlsNoKey)
+	if err == nil {
+		t.Error("TLS certificate was accepted without a key")
+	}
+
+	// Check that CORS origins must be plain origins.
+	var corsOrigins Config
+	corsOrigins.Siad.APIaddr = "localhost:9980"
+	corsOrigins.Siad.APICORSOrigins = "https://dashboard.example.com, http://localhost:3000"
+	err = verifyAPISecurity(corsOrigins)
+	if err != nil {
+		t.Error("valid CORS origins were rejected:", err)
+	}
+	for _, origin := range []string{"dashboard.example.com", "https://example.com/path", "ftp://example.com", "*"} {
+		corsOrigins.Siad.APICORSOrigins = origin
+		if verifyAPISecurity(corsOrigins) == nil {
+			t.Error("invalid CORS origin was accepted:", origin)
+		}
+	}
+}
+
+// TestModuleSets checks that the names of module sets are expanded, and that
+// modules are rejected without the modules they require.
+func TestModuleSets(t *testing.T) {
+	for name, set := range moduleSets {
+		modules, err := processModules(strings.ToUpper(name))
+		if err != nil || modules != set {
+			t.Errorf("module set %v was expanded to %q: %v", name, modules, err)
+		}
+		if err := checkModuleDependencies(modules); err != nil {
+			t.Errorf("module set %v is missing a dependency: %v", name, err)
+		}
+	}
+
+	for _, modules := range []string{"c", "gw", "gcw", "gcth", "gctr", "ge"} {
+		if err := checkModuleDependencies(modules); err == nil {
+			t.Errorf("modules %q were accepted without their dependencies", modules)
+		}
+	}
+
+	var config Config
+	config.Siad.Modules = "gctr"
+	if _, err := processConfig(config); err == nil {
+		t.Error("processConfig accepted the renter without the wallet")
+	}
+}
+
+// TestLightModules checks that only the gateway and wallet can be run with
+// --light.
+func TestLightModules(t *testing.T) {
+	for _, modules := range []string{"g", "gw"} {
+		if err := checkLightModules(modules); err != nil {
+			t.Errorf("modules %q were rejected in light mode: %v", modules, err)
+		}
+	}
+	for _, modules := range []string{"w", "gcw", "gctw", "gwm"} {
+		if err := checkLightModules(modules); err == nil {
+			t.Errorf("modules %q were accepted in light mode", modules)
+		}
+	}
+
+	var config Config
+	config.Siad.APIaddr = "localhost:9980"
+	config.Siad.Modules = "gw"
+	config.Siad.Light = true
+	if _, err := processConfig(config); err != nil {
+		t.Error("processConfig rejected a light wallet:", err)
+	}
+	config.Siad.FastBootstrap = true
+	if _, err := processConfig(config); err == nil {
+		t.Error("processConfig accepted --light with --fast-bootstrap")
+	}
 }
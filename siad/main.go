@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/build"
 )
 
@@ -29,22 +30,40 @@ type Config struct {
 	// --authenticate-api flag is set.
 	APIPassword string
 
+	// The APITokens are loaded from the file given by the --api-tokens flag.
+	APITokens []api.Token
+
 	// The Siad variables are referenced directly by cobra, and are set
 	// according to the flags.
 	Siad struct {
 		APIaddr      string
 		RPCaddr      string
 		HostAddr     string
+		GetworkAddr  string
+		Proxy        string
 		AllowAPIBind bool
 
+		APICORSOrigins    string
+		APITLSCert        string
+		APITLSKey         string
+		APITrustedProxies string
+
+		ConfigFile        string
 		Modules           string
 		NoBootstrap       bool
+		FastBootstrap     bool
+		Light             bool
+		PruneDepth        uint64
 		RequiredUserAgent string
 		AuthenticateAPI   bool
 
-		Profile    bool
-		ProfileDir string
-		SiaDir     string
+		APITokensFile        string
+		AuthenticateAPIReads bool
+
+		Profile         bool
+		ProfileDir      string
+		SiaDir          string
+		HostMetadataDir string
 	}
 }
 
@@ -79,6 +98,20 @@ modules are run. The default modules are:
 	gateway, consensus set, host, miner, renter, transaction pool, wallet
 This is equivalent to:
 	siad -M cghmrtw
+Instead of letters, the name of a set of modules can be used to run a node with
+a single purpose, which uses less memory and starts faster:
+	wallet      gctw      a wallet-only node
+	renter      gctwr     a node that rents storage
+	host        gctwh     a node that hosts storage
+	miner       gctwm     a node that mines
+	explorer    gce       a blockchain explorer
+	full        cghmrtw   the default modules
+Example:
+	siad -M renter
+A wallet-only device that cannot store the blockchain can run the wallet in
+light mode, in which it only downloads block headers and fetches the wallet's
+transactions from full nodes:
+	siad --light -M gw
 Below is a list of all the modules available.
 
 Gateway (g):
@@ -155,15 +188,28 @@ func main() {
 
 	// Set default values, which have the lowest priority.
 	root.Flags().StringVarP(&globalConfig.Siad.RequiredUserAgent, "agent", "", "Sia-Agent", "required substring for the user agent")
+	root.Flags().StringVarP(&globalConfig.Siad.ConfigFile, "config", "", "", "configuration file whose values are used for the flags that are not set (defaults to "+configFile+" in the sia directory, if it exists)")
+	root.Flags().StringVarP(&globalConfig.Siad.GetworkAddr, "getwork-addr", "", "", "host:port on which the miner serves work to external miners using getwork (disabled if empty)")
 	root.Flags().StringVarP(&globalConfig.Siad.HostAddr, "host-addr", "", ":9982", "which port the host listens on")
+	root.Flags().StringVarP(&globalConfig.Siad.HostMetadataDir, "host-metadata-directory", "", "", "location of the host's storage metadata, which may be on a different disk from the storage folders (defaults to inside the sia directory)")
 	root.Flags().StringVarP(&globalConfig.Siad.ProfileDir, "profile-directory", "", "profiles", "location of the profiling directory")
 	root.Flags().StringVarP(&globalConfig.Siad.APIaddr, "api-addr", "", "localhost:9980", "which host:port the API server listens on")
 	root.Flags().StringVarP(&globalConfig.Siad.SiaDir, "sia-directory", "d", "", "location of the sia directory")
 	root.Flags().BoolVarP(&globalConfig.Siad.NoBootstrap, "no-bootstrap", "", false, "disable bootstrapping on this run")
+	root.Flags().BoolVarP(&globalConfig.Siad.FastBootstrap, "fast-bootstrap", "", false, "on first run, download a snapshot of the consensus set at the latest checkpoint instead of the full blockchain")
+	root.Flags().BoolVarP(&globalConfig.Siad.Light, "light", "", false, "follow the blockchain using only block headers, fetching the wallet's transactions from peers (only supports the gateway and wallet)")
 	root.Flags().BoolVarP(&globalConfig.Siad.Profile, "profile", "", false, "enable profiling")
-	root.Flags().StringVarP(&globalConfig.Siad.RPCaddr, "rpc-addr", "", ":9981", "which port the gateway listens on")
-	root.Flags().StringVarP(&globalConfig.Siad.Modules, "modules", "M", "cghmrtw", "enabled modules, see 'siad modules' for more info")
+	root.Flags().Uint64VarP(&globalConfig.Siad.PruneDepth, "prune-depth", "", 0, "discard the bodies of blocks buried deeper than this many blocks (0 disables pruning)")
+	root.Flags().StringVarP(&globalConfig.Siad.Proxy, "proxy", "", "", "host:port of a SOCKS5 proxy (such as Tor) to route outbound connections to peers and hosts through")
+	root.Flags().StringVarP(&globalConfig.Siad.RPCaddr, "rpc-addr", "", ":9981", "which port the gateway listens on; IPv6 addresses must be enclosed in brackets, e.g. [::1]:9981")
+	root.Flags().StringVarP(&globalConfig.Siad.Modules, "modules", "M", "cghmrtw", "enabled modules, as letters or the name of a set such as renter, see 'siad modules' for more info")
 	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateAPI, "authenticate-api", "", false, "enable API password protection")
+	root.Flags().StringVarP(&globalConfig.Siad.APITokensFile, "api-tokens", "", "", "file of API tokens with limited scopes that may be used in place of the API password (requires --authenticate-api)")
+	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateAPIReads, "authenticate-api-reads", "", false, "require the API password or a token for every API call, including calls that only read data (requires --authenticate-api)")
+	root.Flags().StringVarP(&globalConfig.Siad.APICORSOrigins, "api-cors-origins", "", "", "comma-separated list of origins, such as https://dashboard.example.com, from which browsers may call the API")
+	root.Flags().StringVarP(&globalConfig.Siad.APITLSCert, "api-tls-cert", "", "", "certificate file with which the API serves HTTPS (requires --api-tls-key)")
+	root.Flags().StringVarP(&globalConfig.Siad.APITLSKey, "api-tls-key", "", "", "private key file of the certificate with which the API serves HTTPS (requires --api-tls-cert)")
+	root.Flags().StringVarP(&globalConfig.Siad.APITrustedProxies, "api-trusted-proxies", "", "127.0.0.1,::1", "comma-separated list of reverse proxy addresses whose X-Forwarded-For headers are trusted when logging API requests")
 	root.Flags().BoolVarP(&globalConfig.Siad.AllowAPIBind, "disable-api-security", "", false, "allow siad to listen on a non-localhost address (DANGEROUS)")
 
 	// Parse cmdline flags, overwriting both the default values and the config
@@ -3,6 +3,7 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,13 +12,19 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/inconshreveable/go-update"
@@ -25,6 +32,14 @@ import (
 	"github.com/kardianos/osext"
 )
 
+// moduleLoadOrder lists the letters of the modules in the order in which
+// startDaemon loads them.
+const moduleLoadOrder = "gcetwmhr"
+
+// apiLogFile is the name of the file, inside the sia directory, that logs the
+// API requests.
+const apiLogFile = "api.log"
+
 var errEmptyUpdateResponse = errors.New("API call to https://api.github.com/repos/NebulousLabs/Sia/releases/latest is returning an empty response")
 
 type (
@@ -34,6 +49,46 @@ type (
 		httpServer *http.Server
 		mux        *http.ServeMux
 		listener   net.Listener
+		log        *persist.Logger // logs every API request
+
+		// healthCheckers holds the loaded modules that can report their
+		// health. loaded is set once all of the modules have been loaded.
+		healthCheckers []namedHealthChecker
+		loaded         bool
+
+		// moduleStatuses holds every module that siad was configured to
+		// load, in the order in which they are loaded. Each module is set
+		// once it has been loaded. started is the time at which the server
+		// was created.
+		moduleStatuses []namedModule
+		started        time.Time
+
+		// reloader reloads the configuration file. It is set once all of
+		// the modules have been loaded.
+		reloader func() error
+		mu       sync.Mutex
+	}
+
+	// namedHealthChecker is a module that can report its health, along with
+	// the name it is reported under.
+	namedHealthChecker struct {
+		name string
+		hc   modules.HealthChecker
+	}
+
+	// syncChecker is a module that reports whether it has caught up to the
+	// rest of the network, such as the consensus set.
+	syncChecker interface {
+		Synced() bool
+	}
+
+	// namedModule is a module that siad was configured to load, along with
+	// the letter that selects it and the name it is reported under. module is
+	// nil until the module has been loaded.
+	namedModule struct {
+		letter rune
+		name   string
+		module interface{}
 	}
 
 	// SiaConstants is a struct listing all of the constants in use.
@@ -62,6 +117,48 @@ type (
 	DaemonVersion struct {
 		Version string `json:"version"`
 	}
+	// DaemonHealth reports whether the daemon has finished loading and
+	// whether each of its modules is healthy.
+	DaemonHealth struct {
+		Healthy bool           `json:"healthy"`
+		Loaded  bool           `json:"loaded"`
+		Modules []ModuleHealth `json:"modules"`
+	}
+	// ModuleHealth is the health of a single module. Error describes the
+	// problem if the module is not healthy.
+	ModuleHealth struct {
+		Name    string `json:"name"`
+		Healthy bool   `json:"healthy"`
+		Error   string `json:"error,omitempty"`
+	}
+	// DaemonStatus aggregates the state of the daemon and its modules. Ready
+	// is true once the daemon has finished loading and every module is
+	// loaded, healthy, and synced. Stopping lists the thread groups that are
+	// blocking a pending shutdown.
+	DaemonStatus struct {
+		Ready    bool                    `json:"ready"`
+		Loaded   bool                    `json:"loaded"`
+		Version  string                  `json:"version"`
+		Uptime   time.Duration           `json:"uptime"` // nanoseconds
+		Modules  []ModuleStatus          `json:"modules"`
+		Stopping []siasync.StoppingGroup `json:"stopping"`
+	}
+	// ModuleStatus is the state of a single module. Error describes the
+	// problem if the module is not healthy.
+	ModuleStatus struct {
+		Name    string `json:"name"`
+		Loaded  bool   `json:"loaded"`
+		Healthy bool   `json:"healthy"`
+		Syncing bool   `json:"syncing"`
+		Error   string `json:"error,omitempty"`
+	}
+	// DaemonStack lists the thread groups that are waiting for their threads
+	// to finish before they can stop, along with the stack traces of every
+	// goroutine.
+	DaemonStack struct {
+		Stopping   []siasync.StoppingGroup `json:"stopping"`
+		Goroutines string                  `json:"goroutines"`
+	}
 	// UpdateInfo indicates whether an update is available, and to what
 	// version.
 	UpdateInfo struct {
@@ -296,6 +393,105 @@ func (srv *Server) daemonVersionHandler(w http.ResponseWriter, _ *http.Request,
 	api.WriteJSON(w, DaemonVersion{Version: build.Version})
 }
 
+// daemonHealthHandler handles the API call that reports the health of the
+// daemon's modules. The status code is 503 if the daemon is still loading or
+// any module is unhealthy, so that the call can be used as a readiness check
+// without parsing the response.
+func (srv *Server) daemonHealthHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	srv.mu.Lock()
+	dh := DaemonHealth{
+		Loaded:  srv.loaded,
+		Modules: []ModuleHealth{},
+	}
+	checkers := srv.healthCheckers
+	srv.mu.Unlock()
+
+	dh.Healthy = dh.Loaded
+	for _, nhc := range checkers {
+		mh := ModuleHealth{Name: nhc.name, Healthy: true}
+		if err := nhc.hc.Healthy(); err != nil {
+			mh.Healthy = false
+			mh.Error = err.Error()
+			dh.Healthy = false
+		}
+		dh.Modules = append(dh.Modules, mh)
+	}
+	if !dh.Healthy {
+		writeUnavailable(w, dh)
+		return
+	}
+	api.WriteJSON(w, dh)
+}
+
+// daemonStatusHandler handles the API call that reports the state of the
+// daemon and each of the modules it was configured to load. Like
+// /daemon/health, the status code is 503 until the daemon is ready.
+func (srv *Server) daemonStatusHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	srv.mu.Lock()
+	ds := DaemonStatus{
+		Loaded:  srv.loaded,
+		Version: build.Version,
+		Uptime:  time.Since(srv.started),
+		Modules: []ModuleStatus{},
+	}
+	statuses := srv.moduleStatuses
+	srv.mu.Unlock()
+
+	ds.Ready = ds.Loaded
+	for _, nm := range statuses {
+		ms := ModuleStatus{Name: nm.name, Loaded: nm.module != nil}
+		if ms.Loaded {
+			ms.Healthy = true
+			if hc, ok := nm.module.(modules.HealthChecker); ok {
+				if err := hc.Healthy(); err != nil {
+					ms.Healthy = false
+					ms.Error = err.Error()
+				}
+			}
+			if sc, ok := nm.module.(syncChecker); ok {
+				ms.Syncing = !sc.Synced()
+			}
+		}
+		ds.Ready = ds.Ready && ms.Loaded && ms.Healthy && !ms.Syncing
+		ds.Modules = append(ds.Modules, ms)
+	}
+	ds.Stopping = siasync.Stopping()
+	if !ds.Ready {
+		writeUnavailable(w, ds)
+		return
+	}
+	api.WriteJSON(w, ds)
+}
+
+// writeUnavailable writes obj as JSON with the status code 503.
+func writeUnavailable(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		build.Critical("failed to encode API response:", err)
+	}
+}
+
+// daemonStackHandler handles the API call that reports what the daemon's
+// goroutines are doing, so that hangs can be debugged while the daemon is
+// running.
+func (srv *Server) daemonStackHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	// Grow the buffer until it holds the stack traces of every goroutine.
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	api.WriteJSON(w, DaemonStack{
+		Stopping:   siasync.Stopping(),
+		Goroutines: string(buf),
+	})
+}
+
 // daemonStopHandler handles the API call to stop the daemon cleanly.
 func (srv *Server) daemonStopHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	// can't write after we stop the server, so lie a bit.
@@ -313,25 +509,61 @@ func (srv *Server) daemonStopHandler(w http.ResponseWriter, _ *http.Request, _ h
 	}
 }
 
+// daemonReloadHandler handles the API call to reload the configuration file.
+func (srv *Server) daemonReloadHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if err := srv.reload(); err != nil {
+		api.WriteError(w, api.Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	api.WriteSuccess(w)
+}
+
 func (srv *Server) daemonHandler(password string) http.Handler {
 	router := httprouter.New()
 
 	router.GET("/daemon/constants", srv.daemonConstantsHandler)
 	router.GET("/daemon/version", srv.daemonVersionHandler)
+	router.GET("/daemon/health", srv.daemonHealthHandler)
 	router.GET("/daemon/update", srv.daemonUpdateHandlerGET)
 	router.POST("/daemon/update", srv.daemonUpdateHandlerPOST)
+	router.GET("/daemon/status", srv.daemonStatusHandler)
+	router.GET("/daemon/stack", api.RequirePassword(srv.daemonStackHandler, password))
 	router.GET("/daemon/stop", api.RequirePassword(srv.daemonStopHandler, password))
+	router.POST("/daemon/reload", api.RequirePassword(srv.daemonReloadHandler, password))
 
 	return router
 }
 
-// NewServer creates a new net.http server listening on bindAddr.  Only the
-// /daemon/ routes are registered by this func, additional routes can be
-// registered later by calling serv.mux.Handle.
-func NewServer(bindAddr, requiredUserAgent, requiredPassword string) (*Server, error) {
+// NewServer creates a new net.http server listening on the API address of the
+// config. Only the /daemon/ routes are registered by this func, additional
+// routes can be registered later by calling serv.mux.Handle. Every request is
+// logged, and the server uses TLS if the config provides a certificate.
+func NewServer(config Config) (*Server, error) {
 	// Create the listener for the server
-	l, err := net.Listen("tcp", bindAddr)
+	l, err := net.Listen("tcp", config.Siad.APIaddr)
+	if err != nil {
+		return nil, err
+	}
+	if config.Siad.APITLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.Siad.APITLSCert, config.Siad.APITLSKey)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("could not load API TLS certificate: %v", err)
+		}
+		l = tls.NewListener(l, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+	}
+	if config.Siad.SiaDir != "" {
+		if err := os.MkdirAll(config.Siad.SiaDir, 0700); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	logger, err := persist.NewFileLogger(filepath.Join(config.Siad.SiaDir, apiLogFile))
 	if err != nil {
+		l.Close()
 		return nil, err
 	}
 
@@ -340,17 +572,78 @@ func NewServer(bindAddr, requiredUserAgent, requiredPassword string) (*Server, e
 	srv := &Server{
 		mux:      mux,
 		listener: l,
+		log:      logger,
+		started:  time.Now(),
 		httpServer: &http.Server{
-			Handler: mux,
+			Handler: api.LogRequests(api.CORS(mux, splitFlagList(config.Siad.APICORSOrigins), config.Siad.RequiredUserAgent), logger, splitFlagList(config.Siad.APITrustedProxies)),
 		},
 	}
 
+	// Every configured module is reported by /daemon/status, in the order in
+	// which the modules are loaded, including the modules that have not been
+	// loaded yet.
+	for _, m := range moduleLoadOrder {
+		if strings.ContainsRune(config.Siad.Modules, m) {
+			srv.moduleStatuses = append(srv.moduleStatuses, namedModule{letter: m, name: moduleNames[m]})
+		}
+	}
+
 	// Register siad routes
-	srv.mux.Handle("/daemon/", api.RequireUserAgent(srv.daemonHandler(requiredPassword), requiredUserAgent))
+	srv.mux.Handle("/daemon/", api.RequireUserAgent(srv.daemonHandler(config.APIPassword), config.Siad.RequiredUserAgent))
 
 	return srv, nil
 }
 
+// addHealthChecker adds a module to the modules reported by /daemon/health.
+// Modules that do not implement modules.HealthChecker are ignored.
+func (srv *Server) addHealthChecker(name string, module interface{}) {
+	hc, ok := module.(modules.HealthChecker)
+	if !ok {
+		return
+	}
+	srv.mu.Lock()
+	srv.healthCheckers = append(srv.healthCheckers, namedHealthChecker{name: name, hc: hc})
+	srv.mu.Unlock()
+}
+
+// setModuleLoaded records that the module selected by letter has been
+// loaded.
+func (srv *Server) setModuleLoaded(letter rune, module interface{}) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for i := range srv.moduleStatuses {
+		if srv.moduleStatuses[i].letter == letter {
+			srv.moduleStatuses[i].module = module
+		}
+	}
+}
+
+// setLoaded marks that all of the modules have been loaded.
+func (srv *Server) setLoaded() {
+	srv.mu.Lock()
+	srv.loaded = true
+	srv.mu.Unlock()
+}
+
+// setReloader sets the function that reloads the configuration file.
+func (srv *Server) setReloader(reloader func() error) {
+	srv.mu.Lock()
+	srv.reloader = reloader
+	srv.mu.Unlock()
+}
+
+// reload reloads the configuration file. It fails if the daemon is still
+// loading.
+func (srv *Server) reload() error {
+	srv.mu.Lock()
+	reloader := srv.reloader
+	srv.mu.Unlock()
+	if reloader == nil {
+		return errors.New("siad is still loading")
+	}
+	return reloader()
+}
+
 func (srv *Server) Serve() error {
 	// The server will run until an error is encountered or the listener is
 	// closed, via either the Close method or the signal handling above.
@@ -368,5 +661,5 @@ func (srv *Server) Close() error {
 	if err := srv.listener.Close(); err != nil {
 		return err
 	}
-	return nil
+	return srv.log.Close()
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// stubHealthChecker is a module with a fixed health.
+type stubHealthChecker struct {
+	err error
+}
+
+func (s stubHealthChecker) Healthy() error { return s.err }
+
+// TestDaemonHealthHandler checks that /daemon/health reports the health of
+// each module, and only returns 200 once the daemon is loaded and every module
+// is healthy.
+func TestDaemonHealthHandler(t *testing.T) {
+	srv := &Server{}
+	check := func(expectedCode int) DaemonHealth {
+		rec := httptest.NewRecorder()
+		srv.daemonHealthHandler(rec, nil, nil)
+		if rec.Code != expectedCode {
+			t.Fatalf("expected status %v, got %v", expectedCode, rec.Code)
+		}
+		var dh DaemonHealth
+		if err := json.NewDecoder(rec.Body).Decode(&dh); err != nil {
+			t.Fatal(err)
+		}
+		return dh
+	}
+
+	// The daemon is unhealthy while loading, even without unhealthy modules.
+	srv.addHealthChecker("consensus", stubHealthChecker{})
+	srv.addHealthChecker("miner", struct{}{})
+	if dh := check(http.StatusServiceUnavailable); dh.Loaded || dh.Healthy || len(dh.Modules) != 1 {
+		t.Fatal("unexpected health while loading:", dh)
+	}
+	srv.setLoaded()
+	if dh := check(http.StatusOK); !dh.Healthy || !dh.Modules[0].Healthy {
+		t.Fatal("unexpected health after loading:", dh)
+	}
+
+	srv.addHealthChecker("gateway", stubHealthChecker{errors.New("no peers")})
+	dh := check(http.StatusServiceUnavailable)
+	if dh.Healthy || len(dh.Modules) != 2 || dh.Modules[1].Healthy || dh.Modules[1].Error != "no peers" {
+		t.Fatal("unhealthy module was not reported:", dh)
+	}
+}
+
+// stubSyncChecker is a healthy module that reports whether it is synced.
+type stubSyncChecker struct {
+	synced bool
+}
+
+func (s stubSyncChecker) Synced() bool { return s.synced }
+
+// TestDaemonStatusHandler checks that /daemon/status reports every configured
+// module, including those that have not been loaded, and only returns 200
+// once every module is loaded, healthy, and synced.
+func TestDaemonStatusHandler(t *testing.T) {
+	srv := &Server{
+		moduleStatuses: []namedModule{
+			{letter: 'g', name: moduleNames['g']},
+			{letter: 'c', name: moduleNames['c']},
+		},
+	}
+	check := func(expectedCode int) DaemonStatus {
+		rec := httptest.NewRecorder()
+		srv.daemonStatusHandler(rec, nil, nil)
+		if rec.Code != expectedCode {
+			t.Fatalf("expected status %v, got %v", expectedCode, rec.Code)
+		}
+		var ds DaemonStatus
+		if err := json.NewDecoder(rec.Body).Decode(&ds); err != nil {
+			t.Fatal(err)
+		}
+		return ds
+	}
+
+	// While loading, unloaded modules are reported as such.
+	srv.setModuleLoaded('g', stubHealthChecker{})
+	ds := check(http.StatusServiceUnavailable)
+	if ds.Ready || ds.Loaded || ds.Version != build.Version || len(ds.Modules) != 2 {
+		t.Fatal("unexpected status while loading:", ds)
+	}
+	if !ds.Modules[0].Loaded || !ds.Modules[0].Healthy || ds.Modules[1].Loaded {
+		t.Fatal("unexpected module status while loading:", ds.Modules)
+	}
+
+	// A module that is still syncing keeps the daemon from being ready.
+	srv.setModuleLoaded('c', stubSyncChecker{synced: false})
+	srv.setLoaded()
+	ds = check(http.StatusServiceUnavailable)
+	if ds.Ready || !ds.Loaded || !ds.Modules[1].Syncing {
+		t.Fatal("syncing module was not reported:", ds)
+	}
+
+	srv.setModuleLoaded('c', stubSyncChecker{synced: true})
+	ds = check(http.StatusOK)
+	if !ds.Ready || ds.Modules[1].Syncing {
+		t.Fatal("unexpected status once synced:", ds)
+	}
+
+	// An unhealthy module keeps the daemon from being ready.
+	srv.setModuleLoaded('g', stubHealthChecker{errors.New("no peers")})
+	ds = check(http.StatusServiceUnavailable)
+	if ds.Ready || ds.Modules[0].Healthy || ds.Modules[0].Error != "no peers" {
+		t.Fatal("unhealthy module was not reported:", ds)
+	}
+}
@@ -2,13 +2,35 @@ package sync
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
 // ErrStopped is returned by ThreadGroup methods if Stop has already been
 // called.
 var ErrStopped = errors.New("ThreadGroup already stopped")
 
+// unnamedThread is the name under which threads that were added with Add
+// instead of AddNamed are reported.
+const unnamedThread = "unnamed"
+
+var (
+	// stopping holds the thread groups that are waiting in Stop for their
+	// threads to call Done, and the time at which each started waiting.
+	stopping   = make(map[*ThreadGroup]time.Time)
+	stoppingMu sync.Mutex
+)
+
+// A StoppingGroup is a thread group that is waiting in Stop for its threads
+// to call Done.
+type StoppingGroup struct {
+	Name    string        `json:"name"`
+	Waiting time.Duration `json:"waiting"` // nanoseconds
+	Blocked []string      `json:"blocked"`
+}
+
 // A ThreadGroup is a one-time-use object to manage the life cycle of a group
 // of threads. It is a sync.WaitGroup that provides functions for coordinating
 // actions and shutting down threads. After Stop() is called, the thread group
@@ -25,10 +47,14 @@ type ThreadGroup struct {
 	onStopFns    []func()
 	afterStopFns []func()
 
+	name    string
+	threads map[string]int // number of running threads with each name
+
 	once     sync.Once
 	stopChan chan struct{}
 	bmu      sync.Mutex // Ensures blocking between calls to 'Add', 'Flush', and 'Stop'
 	mu       sync.Mutex // Protects the 'onStopFns' and 'afterStopFns' variable
+	tmu      sync.Mutex // Protects the 'name' and 'threads' variables
 	wg       sync.WaitGroup
 }
 
@@ -48,8 +74,24 @@ func (tg *ThreadGroup) isStopped() bool {
 	}
 }
 
+// SetName sets the name under which the thread group is reported by
+// Stopping, usually the name of the module that owns it.
+func (tg *ThreadGroup) SetName(name string) {
+	tg.tmu.Lock()
+	tg.name = name
+	tg.tmu.Unlock()
+}
+
 // Add increments the thread group counter.
 func (tg *ThreadGroup) Add() error {
+	return tg.AddNamed(unnamedThread)
+}
+
+// AddNamed increments the thread group counter like Add, recording the name
+// of the thread so that it can be reported by DumpBlocked if it prevents the
+// thread group from stopping. Threads added with AddNamed must call DoneNamed
+// with the same name.
+func (tg *ThreadGroup) AddNamed(name string) error {
 	tg.bmu.Lock()
 	defer tg.bmu.Unlock()
 
@@ -57,6 +99,12 @@ func (tg *ThreadGroup) Add() error {
 		return ErrStopped
 	}
 	tg.wg.Add(1)
+	tg.tmu.Lock()
+	if tg.threads == nil {
+		tg.threads = make(map[string]int)
+	}
+	tg.threads[name]++
+	tg.tmu.Unlock()
 	return nil
 }
 
@@ -99,9 +147,35 @@ func (tg *ThreadGroup) OnStop(fn func()) {
 
 // Done decrements the thread group counter.
 func (tg *ThreadGroup) Done() {
+	tg.DoneNamed(unnamedThread)
+}
+
+// DoneNamed decrements the thread group counter for a thread that was added
+// with AddNamed.
+func (tg *ThreadGroup) DoneNamed(name string) {
+	tg.tmu.Lock()
+	if tg.threads[name]--; tg.threads[name] <= 0 {
+		delete(tg.threads, name)
+	}
+	tg.tmu.Unlock()
 	tg.wg.Done()
 }
 
+// DumpBlocked returns the names of the threads that have been added to the
+// thread group and have not yet called Done, along with the number of threads
+// with each name, sorted by name. While Stop is waiting, these are the threads
+// that are preventing the thread group from stopping.
+func (tg *ThreadGroup) DumpBlocked() []string {
+	tg.tmu.Lock()
+	defer tg.tmu.Unlock()
+	blocked := make([]string, 0, len(tg.threads))
+	for name, n := range tg.threads {
+		blocked = append(blocked, fmt.Sprintf("%v (%v)", name, n))
+	}
+	sort.Strings(blocked)
+	return blocked
+}
+
 // Flush will block all calls to 'tg.Add' until all current routines have
 // called 'tg.Done'. This in effect 'flushes' the module, letting it complete
 // any tasks that are open before taking on new ones.
@@ -137,7 +211,13 @@ func (tg *ThreadGroup) Stop() error {
 	tg.onStopFns = nil
 	tg.mu.Unlock()
 
+	stoppingMu.Lock()
+	stopping[tg] = time.Now()
+	stoppingMu.Unlock()
 	tg.wg.Wait()
+	stoppingMu.Lock()
+	delete(stopping, tg)
+	stoppingMu.Unlock()
 
 	// After waiting for all resources to release the thread group, iterate
 	// through the stop functions and call them in reverse oreder.
@@ -157,3 +237,36 @@ func (tg *ThreadGroup) StopChan() <-chan struct{} {
 	tg.once.Do(tg.init)
 	return tg.stopChan
 }
+
+// stoppingGroups sorts StoppingGroups from the longest waiting to the
+// shortest.
+type stoppingGroups []StoppingGroup
+
+func (sg stoppingGroups) Len() int           { return len(sg) }
+func (sg stoppingGroups) Less(i, j int) bool { return sg[i].Waiting > sg[j].Waiting }
+func (sg stoppingGroups) Swap(i, j int)      { sg[i], sg[j] = sg[j], sg[i] }
+
+// Stopping returns the thread groups that are waiting in Stop for their
+// threads to call Done, along with the threads that they are waiting for,
+// starting with the group that has been waiting the longest. Shutdowns that
+// hang can be debugged by checking which threads are blocking them.
+func Stopping() []StoppingGroup {
+	stoppingMu.Lock()
+	defer stoppingMu.Unlock()
+	groups := make([]StoppingGroup, 0, len(stopping))
+	for tg, start := range stopping {
+		tg.tmu.Lock()
+		name := tg.name
+		tg.tmu.Unlock()
+		if name == "" {
+			name = "unnamed"
+		}
+		groups = append(groups, StoppingGroup{
+			Name:    name,
+			Waiting: time.Since(start),
+			Blocked: tg.DumpBlocked(),
+		})
+	}
+	sort.Sort(stoppingGroups(groups))
+	return groups
+}
@@ -473,6 +473,76 @@ func TestAddOnStop(t *testing.T) {
 	}
 }
 
+// TestThreadGroupDumpBlocked checks that a thread group that is waiting in
+// Stop reports the names of the threads that are blocking it.
+func TestThreadGroupDumpBlocked(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	var tg ThreadGroup
+	tg.SetName("TestThreadGroupDumpBlocked")
+	release := make(chan struct{})
+	for _, name := range []string{"stuck", "stuck", "quick"} {
+		err := tg.AddNamed(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tg.Add()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked := tg.DumpBlocked()
+	if len(blocked) != 3 || blocked[0] != "quick (1)" || blocked[1] != "stuck (2)" || blocked[2] != "unnamed (1)" {
+		t.Fatal("wrong threads reported:", blocked)
+	}
+	tg.DoneNamed("quick")
+	tg.Done()
+	go func() {
+		<-release
+		tg.DoneNamed("stuck")
+		tg.DoneNamed("stuck")
+	}()
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- tg.Stop()
+	}()
+
+	// Wait for the thread group to start stopping, and check that only the
+	// stuck threads are reported.
+	var group StoppingGroup
+	for i := 0; i < 100 && group.Name == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+		for _, sg := range Stopping() {
+			if sg.Name == "TestThreadGroupDumpBlocked" {
+				group = sg
+			}
+		}
+	}
+	if group.Name == "" {
+		t.Fatal("thread group was not reported as stopping")
+	}
+	if len(group.Blocked) != 1 || group.Blocked[0] != "stuck (2)" {
+		t.Fatal("wrong threads reported as blocking:", group.Blocked)
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	for _, sg := range Stopping() {
+		if sg.Name == "TestThreadGroupDumpBlocked" {
+			t.Fatal("stopped thread group is still reported as stopping")
+		}
+	}
+	if len(tg.DumpBlocked()) != 0 {
+		t.Fatal("stopped thread group reports blocked threads:", tg.DumpBlocked())
+	}
+}
+
 // BenchmarkThreadGroup times how long it takes to add a ton of threads and
 // trigger goroutines that call Done.
 func BenchmarkThreadGroup(b *testing.B) {
@@ -124,6 +124,37 @@ func (b Block) MerkleRoot() crypto.Hash {
 	return tree.Root()
 }
 
+// TransactionProof returns a Merkle proof that the transaction at index i is
+// one of the leaves of the block's Merkle root. The proof consists of the index
+// of the transaction's leaf, the number of leaves, and the hashes of the
+// sibling subtrees. It can be verified with BlockHeader.VerifyTransactionProof
+// by anyone who knows the header of the block.
+func (b Block) TransactionProof(i int) (proofIndex, numLeaves uint64, hashSet []crypto.Hash) {
+	proofIndex = uint64(len(b.MinerPayouts) + i)
+	tree := crypto.NewTree()
+	tree.SetIndex(proofIndex)
+	for _, payout := range b.MinerPayouts {
+		tree.PushObject(payout)
+	}
+	for _, txn := range b.Transactions {
+		tree.PushObject(txn)
+	}
+	_, proofSet, _, numLeaves := tree.Prove()
+	// The first element of the proof set is the leaf data, which is the
+	// encoded transaction itself.
+	hashSet = make([]crypto.Hash, len(proofSet)-1)
+	for j, p := range proofSet[1:] {
+		copy(hashSet[j][:], p)
+	}
+	return proofIndex, numLeaves, hashSet
+}
+
+// VerifyTransactionProof checks a proof created by Block.TransactionProof,
+// returning true if the transaction is part of the block with this header.
+func (h BlockHeader) VerifyTransactionProof(txn Transaction, proofIndex, numLeaves uint64, hashSet []crypto.Hash) bool {
+	return crypto.VerifySegment(encoding.Marshal(txn), hashSet, numLeaves, proofIndex, h.MerkleRoot)
+}
+
 // MinerPayoutID returns the ID of the miner payout at the given index, which
 // is calculated by hashing the concatenation of the BlockID and the payout
 // index.
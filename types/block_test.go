@@ -232,6 +232,39 @@ func TestBlockMinerPayoutID(t *testing.T) {
 	}
 }
 
+// TestBlockTransactionProof checks that the transactions of a block can be
+// proven against its header, and that proofs do not verify other
+// transactions.
+func TestBlockTransactionProof(t *testing.T) {
+	b := Block{
+		MinerPayouts: []SiacoinOutput{
+			{Value: CalculateCoinbase(0)},
+			{Value: CalculateCoinbase(1)},
+		},
+	}
+	for i := 0; i < 5; i++ {
+		b.Transactions = append(b.Transactions, Transaction{ArbitraryData: [][]byte{{byte(i)}}})
+	}
+	h := b.Header()
+
+	for i, txn := range b.Transactions {
+		proofIndex, numLeaves, hashSet := b.TransactionProof(i)
+		if numLeaves != 7 {
+			t.Fatal("expected 7 leaves, got", numLeaves)
+		}
+		if !h.VerifyTransactionProof(txn, proofIndex, numLeaves, hashSet) {
+			t.Error("proof of transaction", i, "does not verify")
+		}
+		other := b.Transactions[(i+1)%len(b.Transactions)]
+		if h.VerifyTransactionProof(other, proofIndex, numLeaves, hashSet) {
+			t.Error("proof of transaction", i, "verifies a different transaction")
+		}
+		if proofIndex > 0 && h.VerifyTransactionProof(txn, proofIndex-1, numLeaves, hashSet) {
+			t.Error("proof of transaction", i, "verifies at the wrong index")
+		}
+	}
+}
+
 // TestBlockEncodes probes the MarshalSia and UnmarshalSia methods of the
 // Block type.
 func TestBlockEncoding(t *testing.T) {
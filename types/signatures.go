@@ -271,10 +271,21 @@ func (t Transaction) validCoveredFields() error {
 
 // validSignatures checks the validaty of all signatures in a transaction.
 func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
+	batch, err := t.signatureBatch(currentHeight)
+	if err != nil {
+		return err
+	}
+	return crypto.VerifyBatch(batch)
+}
+
+// signatureBatch performs every check of validSignatures except for verifying
+// the ed25519 signatures, which are returned instead so that they can be
+// verified together using crypto.VerifyBatch.
+func (t *Transaction) signatureBatch(currentHeight BlockHeight) ([]crypto.SignedHash, error) {
 	// Check that all covered fields objects follow the rules.
 	err := t.validCoveredFields()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Create the inputSignatures object for each input.
@@ -283,7 +294,7 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 		id := crypto.Hash(input.ParentID)
 		_, exists := sigMap[id]
 		if exists {
-			return ErrDoubleSpend
+			return nil, ErrDoubleSpend
 		}
 
 		sigMap[id] = &inputSignatures{
@@ -297,7 +308,7 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 		id := crypto.Hash(revision.ParentID)
 		_, exists := sigMap[id]
 		if exists {
-			return ErrDoubleSpend
+			return nil, ErrDoubleSpend
 		}
 
 		sigMap[id] = &inputSignatures{
@@ -311,7 +322,7 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 		id := crypto.Hash(input.ParentID)
 		_, exists := sigMap[id]
 		if exists {
-			return ErrDoubleSpend
+			return nil, ErrDoubleSpend
 		}
 
 		sigMap[id] = &inputSignatures{
@@ -323,24 +334,25 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 	}
 
 	// Check all of the signatures for validity.
+	var batch []crypto.SignedHash
 	for i, sig := range t.TransactionSignatures {
 		// Check that sig corresponds to an entry in sigMap.
 		inSig, exists := sigMap[crypto.Hash(sig.ParentID)]
 		if !exists || inSig.remainingSignatures == 0 {
-			return ErrFrivilousSignature
+			return nil, ErrFrivilousSignature
 		}
 		// Check that sig's key hasn't already been used.
 		_, exists = inSig.usedKeys[sig.PublicKeyIndex]
 		if exists {
-			return ErrPublicKeyOveruse
+			return nil, ErrPublicKeyOveruse
 		}
 		// Check that the public key index refers to an existing public key.
 		if sig.PublicKeyIndex >= uint64(len(inSig.possibleKeys)) {
-			return ErrInvalidPubKeyIndex
+			return nil, ErrInvalidPubKeyIndex
 		}
 		// Check that the timelock has expired.
 		if sig.Timelock > currentHeight {
-			return ErrPrematureSignature
+			return nil, ErrPrematureSignature
 		}
 
 		// Check that the signature verifies. Multiple signature schemes are
@@ -349,27 +361,27 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 		switch publicKey.Algorithm {
 		case SignatureEntropy:
 			// Entropy cannot ever be used to sign a transaction.
-			return ErrEntropyKey
+			return nil, ErrEntropyKey
 
 		case SignatureEd25519:
 			// Decode the public key and signature.
 			var edPK crypto.PublicKey
 			err := encoding.Unmarshal([]byte(publicKey.Key), &edPK)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			var edSig [crypto.SignatureSize]byte
 			err = encoding.Unmarshal([]byte(sig.Signature), &edSig)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			cryptoSig := crypto.Signature(edSig)
 
-			sigHash := t.SigHash(i)
-			err = crypto.VerifyHash(sigHash, edPK, cryptoSig)
-			if err != nil {
-				return err
-			}
+			batch = append(batch, crypto.SignedHash{
+				Hash:      t.SigHash(i),
+				PublicKey: edPK,
+				Signature: cryptoSig,
+			})
 
 		default:
 			// If the identifier is not recognized, assume that the signature
@@ -384,11 +396,11 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 	// Check that all inputs have been sufficiently signed.
 	for _, reqSigs := range sigMap {
 		if reqSigs.remainingSignatures != 0 {
-			return ErrMissingSignatures
+			return nil, ErrMissingSignatures
 		}
 	}
 
-	return nil
+	return batch, nil
 }
 
 // String defines how to print a SiaPublicKey - hex is used to keep things
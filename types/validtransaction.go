@@ -8,6 +8,7 @@ package types
 import (
 	"errors"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 )
 
@@ -265,7 +266,20 @@ func (t Transaction) validUnlockConditions(currentHeight BlockHeight) (err error
 // context, for example if the same output is spent twice in the same
 // transaction. StandaloneValid will not check that all outputs being spent are
 // legal outputs, as it has no confirmed or unconfirmed set to look at.
-func (t Transaction) StandaloneValid(currentHeight BlockHeight) (err error) {
+func (t Transaction) StandaloneValid(currentHeight BlockHeight) error {
+	batch, err := t.StandaloneValidBatch(currentHeight)
+	if err != nil {
+		return err
+	}
+	return crypto.VerifyBatch(batch)
+}
+
+// StandaloneValidBatch performs the same checks as StandaloneValid, except
+// that the ed25519 signatures of the transaction are not verified. Instead,
+// they are returned, so that the signatures of many transactions can be
+// verified at once using crypto.VerifyBatch. The transaction is only
+// StandaloneValid if the returned signatures verify.
+func (t Transaction) StandaloneValidBatch(currentHeight BlockHeight) (batch []crypto.SignedHash, err error) {
 	err = t.fitsInABlock()
 	if err != nil {
 		return
@@ -294,9 +308,5 @@ func (t Transaction) StandaloneValid(currentHeight BlockHeight) (err error) {
 	if err != nil {
 		return
 	}
-	err = t.validSignatures(currentHeight)
-	if err != nil {
-		return
-	}
-	return
+	return t.signatureBatch(currentHeight)
 }